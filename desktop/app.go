@@ -156,8 +156,104 @@ func (a *App) ExplainTopic(topic, subject, level string) (interface{}, error) {
 	return a.backend.ExplainTopic(topic, subject, level)
 }
 
-func (a *App) AnswerQuestion(question, subject, context string) (interface{}, error) {
-	return a.backend.AnswerQuestion(question, subject, context)
+// StreamChat streams a chat response as "ai:stream" events tagged with streamID
+func (a *App) StreamChat(streamID, message, contextStr string) (map[string]interface{}, error) {
+	return a.backend.StreamChat(streamID, message, contextStr)
+}
+
+// StreamExplainTopic streams a topic explanation as "ai:stream" events tagged with streamID
+func (a *App) StreamExplainTopic(streamID, topic, subject, level string) (map[string]interface{}, error) {
+	return a.backend.StreamExplainTopic(streamID, topic, subject, level)
+}
+
+// CancelAIStream stops a running AI stream
+func (a *App) CancelAIStream(streamID string) {
+	a.backend.CancelAIStream(streamID)
+}
+
+// ListAIThreads lists tutor threads, or room AI threads when roomID is set
+func (a *App) ListAIThreads(roomID string) ([]interface{}, error) {
+	return a.backend.ListAIThreads(roomID)
+}
+
+// CreateAIThread starts a conversation thread; roomID is empty for the tutor
+// and mode is "tutor" for hints instead of answers
+func (a *App) CreateAIThread(roomID, title, mode string) (map[string]interface{}, error) {
+	return a.backend.CreateAIThread(roomID, title, mode)
+}
+
+// GetAIThread gets a thread with its messages
+func (a *App) GetAIThread(threadID string) (map[string]interface{}, error) {
+	return a.backend.GetAIThread(threadID)
+}
+
+// RenameAIThread changes a thread's title
+func (a *App) RenameAIThread(threadID, title string) (map[string]interface{}, error) {
+	return a.backend.RenameAIThread(threadID, title)
+}
+
+// DeleteAIThread deletes a thread and its messages
+func (a *App) DeleteAIThread(threadID string) error {
+	return a.backend.DeleteAIThread(threadID)
+}
+
+// StreamAIThreadMessage continues a thread, streaming the reply as "ai:stream" events tagged with streamID
+func (a *App) StreamAIThreadMessage(streamID, threadID, message string) (map[string]interface{}, error) {
+	return a.backend.StreamAIThreadMessage(streamID, threadID, message)
+}
+
+// GetAIUsage returns the user's AI tokens this quota period and what is left
+func (a *App) GetAIUsage() (map[string]interface{}, error) {
+	return a.backend.GetAIUsage()
+}
+
+// GetRoomAIUsage returns a room's AI usage by student, feature and day (from/to are YYYY-MM-DD)
+func (a *App) GetRoomAIUsage(roomID, from, to string) (map[string]interface{}, error) {
+	return a.backend.GetRoomAIUsage(roomID, from, to)
+}
+
+// GetAdminAIUsage returns AI usage across all users (admins only)
+func (a *App) GetAdminAIUsage(from, to string) (map[string]interface{}, error) {
+	return a.backend.GetAdminAIUsage(from, to)
+}
+
+// GetAIPrompts lists the versioned AI prompts (admins only)
+func (a *App) GetAIPrompts() ([]interface{}, error) {
+	return a.backend.GetAIPrompts()
+}
+
+// GetAIPrompt returns a prompt with all of its versions (admins only)
+func (a *App) GetAIPrompt(name string) (map[string]interface{}, error) {
+	return a.backend.GetAIPrompt(name)
+}
+
+// CreateAIPromptVersion adds a version of a prompt (admins only)
+func (a *App) CreateAIPromptVersion(name, text, note string) (map[string]interface{}, error) {
+	return a.backend.CreateAIPromptVersion(name, text, note)
+}
+
+// ReleaseAIPrompt puts a version of a prompt in use, optionally trying a candidate on a percentage of users (admins only)
+func (a *App) ReleaseAIPrompt(name string, version, candidateVersion, candidatePercent int) (map[string]interface{}, error) {
+	return a.backend.ReleaseAIPrompt(name, version, candidateVersion, candidatePercent)
+}
+
+// RollbackAIPrompt returns a prompt to its previous release (admins only)
+func (a *App) RollbackAIPrompt(name string) (map[string]interface{}, error) {
+	return a.backend.RollbackAIPrompt(name)
+}
+
+// GetAISafetyFlags returns students' flagged AI interactions for their parent or teacher
+func (a *App) GetAISafetyFlags(roomID string, unreviewed bool) ([]interface{}, error) {
+	return a.backend.GetAISafetyFlags(roomID, unreviewed)
+}
+
+// ReviewAISafetyFlag marks a flagged AI interaction as reviewed
+func (a *App) ReviewAISafetyFlag(flagID string) (map[string]interface{}, error) {
+	return a.backend.ReviewAISafetyFlag(flagID)
+}
+
+func (a *App) AnswerQuestion(question, subject, context, mode, roomID string) (interface{}, error) {
+	return a.backend.AnswerQuestion(question, subject, context, mode, roomID)
 }
 
 // JoinRoom joins a room
@@ -266,15 +362,143 @@ func (a *App) CreateResource(roomID string, resource map[string]interface{}) (in
 	} else if subjectsArray, ok := resource["subjects"].([]string); ok {
 		res.Subjects = subjectsArray
 	}
+	res.Tags = stringList(resource["tags"])
+	res.FolderID, _ = resource["folder_id"].(string)
 	
 	return a.backend.CreateResource(roomID, res)
 }
 
+// stringList converts a string array passed from the frontend
+func stringList(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		var result []string
+		for _, item := range list {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// UploadResourceResumable uploads a file in resumable chunks; the server creates
+// the resource described by the map once the upload is stored
+func (a *App) UploadResourceResumable(roomID, filePath string, resource map[string]interface{}) (interface{}, error) {
+	res := api.Resource{}
+	res.Name, _ = resource["name"].(string)
+	res.Description, _ = resource["description"].(string)
+	res.Category, _ = resource["category"].(string)
+	res.Subject, _ = resource["subject"].(string)
+	res.Subjects = stringList(resource["subjects"])
+	res.Tags = stringList(resource["tags"])
+	res.FolderID, _ = resource["folder_id"].(string)
+	return a.backend.UploadResourceResumable(roomID, filePath, res)
+}
+
+// UploadResourceVersion uploads a file as a new version of a resource
+func (a *App) UploadResourceVersion(roomID, resourceID, filePath, changeNote string) (interface{}, error) {
+	return a.backend.UploadResourceVersion(roomID, resourceID, filePath, changeNote)
+}
+
+// GetResourceVersions gets a resource's version history
+func (a *App) GetResourceVersions(resourceID string) (interface{}, error) {
+	return a.backend.GetResourceVersions(resourceID)
+}
+
+// RestoreResourceVersion makes an earlier version of a resource current again
+func (a *App) RestoreResourceVersion(resourceID string, version int) (interface{}, error) {
+	return a.backend.RestoreResourceVersion(resourceID, version)
+}
+
+// GetPendingUploads lists interrupted uploads that can be resumed
+func (a *App) GetPendingUploads() (interface{}, error) {
+	return a.backend.GetPendingUploads()
+}
+
+// ResumeUpload continues an interrupted upload
+func (a *App) ResumeUpload(uploadID string) (interface{}, error) {
+	return a.backend.ResumeUpload(uploadID)
+}
+
+// CancelUpload discards an interrupted upload
+func (a *App) CancelUpload(uploadID string) error {
+	return a.backend.CancelUpload(uploadID)
+}
+
 // GetResources gets all resources for a room
 func (a *App) GetResources(roomID, uploaderType, category string) (interface{}, error) {
 	return a.backend.GetResources(roomID, uploaderType, category)
 }
 
+// SearchResources gets one page of a room's resources. The query map may hold
+// uploader_type, category, folder_id ("root" for unfiled), tags, subject, q,
+// sort, limit and offset.
+func (a *App) SearchResources(roomID string, query map[string]interface{}) (interface{}, error) {
+	q := api.ResourceQuery{Tags: stringList(query["tags"])}
+	q.UploaderType, _ = query["uploader_type"].(string)
+	q.Category, _ = query["category"].(string)
+	q.FolderID, _ = query["folder_id"].(string)
+	q.Subject, _ = query["subject"].(string)
+	q.Search, _ = query["q"].(string)
+	q.Sort, _ = query["sort"].(string)
+	if limit, ok := query["limit"].(float64); ok {
+		q.Limit = int(limit)
+	}
+	if offset, ok := query["offset"].(float64); ok {
+		q.Offset = int(offset)
+	}
+	return a.backend.SearchResources(roomID, q)
+}
+
+// GetResourceTags gets the tags used in a room with their counts
+func (a *App) GetResourceTags(roomID string) (interface{}, error) {
+	return a.backend.GetResourceTags(roomID)
+}
+
+// UpdateResource changes a resource's name, description, subjects, tags or folder
+func (a *App) UpdateResource(resourceID string, changes map[string]interface{}) (interface{}, error) {
+	return a.backend.UpdateResource(resourceID, changes)
+}
+
+// GetResourceFolders gets all folders of a room
+func (a *App) GetResourceFolders(roomID string) (interface{}, error) {
+	return a.backend.GetResourceFolders(roomID)
+}
+
+// CreateResourceFolder creates a folder in a room ("" parentID for the top level)
+func (a *App) CreateResourceFolder(roomID, name, parentID string) (interface{}, error) {
+	return a.backend.CreateResourceFolder(roomID, name, parentID)
+}
+
+// UpdateResourceFolder renames or moves a folder
+func (a *App) UpdateResourceFolder(roomID, folderID, name, parentID string) (interface{}, error) {
+	return a.backend.UpdateResourceFolder(roomID, folderID, name, parentID)
+}
+
+// DeleteResourceFolder deletes a folder, moving its contents up a level
+func (a *App) DeleteResourceFolder(roomID, folderID string) error {
+	return a.backend.DeleteResourceFolder(roomID, folderID)
+}
+
+// GetResourceDownloadURL gets a signed, expiring URL for a resource's file
+func (a *App) GetResourceDownloadURL(resourceID string) (string, error) {
+	return a.backend.GetResourceDownloadURL(resourceID)
+}
+
+// GetResourceViewURL gets a signed URL for showing a resource's file in the app
+func (a *App) GetResourceViewURL(resourceID string) (string, error) {
+	return a.backend.GetResourceViewURL(resourceID)
+}
+
+// RecordResourceProgress reports how far a video resource has been played
+func (a *App) RecordResourceProgress(resourceID string, position, duration float64) error {
+	return a.backend.RecordResourceProgress(resourceID, position, duration)
+}
+
 // DeleteResource deletes a resource
 func (a *App) DeleteResource(resourceID string) error {
 	return a.backend.DeleteResource(resourceID)
@@ -662,6 +886,28 @@ func (a *App) GetFriends() (interface{}, error) {
 	return a.backend.GetFriends()
 }
 
+// ============= Notifications =============
+
+// GetNotifications gets the current user's notifications
+func (a *App) GetNotifications(unreadOnly bool) (interface{}, error) {
+	return a.backend.GetNotifications(unreadOnly)
+}
+
+// MarkNotificationRead marks a notification as read
+func (a *App) MarkNotificationRead(notificationID string) error {
+	return a.backend.MarkNotificationRead(notificationID)
+}
+
+// MarkAllNotificationsRead marks all notifications as read
+func (a *App) MarkAllNotificationsRead() error {
+	return a.backend.MarkAllNotificationsRead()
+}
+
+// GetJob gets the status of a background job, such as a report being generated
+func (a *App) GetJob(jobID string) (interface{}, error) {
+	return a.backend.GetJob(jobID)
+}
+
 // ============= Room AI Coach =============
 
 // GetRoomAIStatus gets the AI training status for a room
@@ -670,15 +916,35 @@ func (a *App) GetRoomAIStatus(roomID string) (map[string]interface{}, error) {
 }
 
 // TrainRoomAI trains the room AI with selected resources
-func (a *App) TrainRoomAI(roomID string, resourceIDs []string) error {
+func (a *App) TrainRoomAI(roomID string, resourceIDs []string) (map[string]interface{}, error) {
 	return a.backend.TrainRoomAI(roomID, resourceIDs)
 }
 
+// ClearRoomAICache drops the room AI's cached answers, e.g. after resources change (room owner)
+func (a *App) ClearRoomAICache(roomID string) error {
+	return a.backend.ClearRoomAICache(roomID)
+}
+
+// GetRoomAISettings gets how the room AI behaves, e.g. whether students are tutored
+func (a *App) GetRoomAISettings(roomID string) (map[string]interface{}, error) {
+	return a.backend.GetRoomAISettings(roomID)
+}
+
+// UpdateRoomAISettings changes how the room AI behaves (room owner)
+func (a *App) UpdateRoomAISettings(roomID string, settings map[string]interface{}) (map[string]interface{}, error) {
+	return a.backend.UpdateRoomAISettings(roomID, settings)
+}
+
 // ChatWithRoomAI sends a message to the room's AI coach
 func (a *App) ChatWithRoomAI(roomID string, message string) (map[string]interface{}, error) {
 	return a.backend.ChatWithRoomAI(roomID, message)
 }
 
+// StreamChatWithRoomAI streams the room AI coach's answer as "ai:stream" events tagged with streamID
+func (a *App) StreamChatWithRoomAI(streamID, roomID, message string) (map[string]interface{}, error) {
+	return a.backend.StreamChatWithRoomAI(streamID, roomID, message)
+}
+
 // ============= Smart Study Plan =============
 
 // GenerateSmartStudyPlan generates a smart study plan using AI
@@ -745,6 +1011,21 @@ func (a *App) GetGameStats(gameID string) (interface{}, error) {
 	return a.backend.GetGameStats(gameID)
 }
 
+// GetResourceEngagement returns engagement with each teacher resource of a room
+func (a *App) GetResourceEngagement(roomID, exam, before string) (interface{}, error) {
+	return a.backend.GetResourceEngagement(roomID, exam, before)
+}
+
+// GetResourceEngagementDetail returns each student's engagement with one resource
+func (a *App) GetResourceEngagementDetail(roomID, resourceID, exam, before string) (interface{}, error) {
+	return a.backend.GetResourceEngagementDetail(roomID, resourceID, exam, before)
+}
+
+// GetStudentEngagement returns one student's engagement with a room's teacher resources
+func (a *App) GetStudentEngagement(roomID, studentID, exam, before string) (interface{}, error) {
+	return a.backend.GetStudentEngagement(roomID, studentID, exam, before)
+}
+
 // ExportAnalyticsCSV exports analytics as CSV
 func (a *App) ExportAnalyticsCSV(roomID string) error {
 	return a.backend.ExportAnalyticsCSV(roomID)
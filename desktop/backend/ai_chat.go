@@ -1,6 +1,9 @@
 package backend
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 // Chat sends a message to AI and gets a response
 func (a *WailsApp) Chat(message, context string) (interface{}, error) {
@@ -18,10 +21,191 @@ func (a *WailsApp) ExplainTopic(topic, subject, level string) (interface{}, erro
 	return a.api.AIClient.ExplainTopic(topic, subject, level)
 }
 
-// AnswerQuestion asks AI to answer a question
-func (a *WailsApp) AnswerQuestion(question, subject, context string) (interface{}, error) {
+// AnswerQuestion asks AI to answer a question; mode "tutor" starts a tutoring
+// thread instead, as does a room (roomID) that requires it
+func (a *WailsApp) AnswerQuestion(question, subject, context, mode, roomID string) (interface{}, error) {
 	if a.authToken == "" {
 		return nil, fmt.Errorf("not authenticated")
 	}
-	return a.api.AIClient.AnswerQuestion(question, subject, context)
+	return a.api.AIClient.AnswerQuestion(question, subject, context, mode, roomID)
+}
+
+// startAIStream registers a cancellable AI stream and returns its context, a
+// function sending its text to the frontend as "ai:stream" events, and a
+// function to call when it ends
+func (a *WailsApp) startAIStream(streamID string) (context.Context, func(text string), func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.aiStreams.Store(streamID, cancel)
+	emit := func(text string) {
+		a.EmitEvent("ai:stream", map[string]interface{}{
+			"id":   streamID,
+			"text": text,
+		})
+	}
+	done := func() {
+		a.aiStreams.Delete(streamID)
+		cancel()
+	}
+	return ctx, emit, done
+}
+
+// CancelAIStream stops a running AI stream; its call returns an error
+func (a *WailsApp) CancelAIStream(streamID string) {
+	if cancel, ok := a.aiStreams.LoadAndDelete(streamID); ok {
+		cancel.(context.CancelFunc)()
+	}
+}
+
+// StreamChat sends a message to AI and streams the response as "ai:stream"
+// events tagged with streamID; it returns the final response like Chat
+func (a *WailsApp) StreamChat(streamID, message, contextStr string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	ctx, emit, done := a.startAIStream(streamID)
+	defer done()
+	return a.api.AIClient.ChatStream(ctx, message, contextStr, emit)
+}
+
+// StreamExplainTopic asks AI to explain a topic and streams the explanation
+// as "ai:stream" events tagged with streamID
+func (a *WailsApp) StreamExplainTopic(streamID, topic, subject, level string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	ctx, emit, done := a.startAIStream(streamID)
+	defer done()
+	return a.api.AIClient.ExplainTopicStream(ctx, topic, subject, level, emit)
+}
+
+// ListAIThreads lists the user's tutor threads, or their room AI threads when roomID is set
+func (a *WailsApp) ListAIThreads(roomID string) ([]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.ListThreads(roomID)
+}
+
+// CreateAIThread starts a conversation thread; roomID is empty for the tutor
+// and mode is "tutor" for hints instead of answers
+func (a *WailsApp) CreateAIThread(roomID, title, mode string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.CreateThread(roomID, title, mode)
+}
+
+// GetAIThread gets a thread with its messages
+func (a *WailsApp) GetAIThread(threadID string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.GetThread(threadID)
+}
+
+// RenameAIThread changes a thread's title
+func (a *WailsApp) RenameAIThread(threadID, title string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.RenameThread(threadID, title)
+}
+
+// DeleteAIThread deletes a thread and its messages
+func (a *WailsApp) DeleteAIThread(threadID string) error {
+	if a.authToken == "" {
+		return fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.DeleteThread(threadID)
+}
+
+// StreamAIThreadMessage continues a thread and streams the reply as
+// "ai:stream" events tagged with streamID
+func (a *WailsApp) StreamAIThreadMessage(streamID, threadID, message string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	ctx, emit, done := a.startAIStream(streamID)
+	defer done()
+	return a.api.AIClient.SendThreadMessageStream(ctx, threadID, message, emit)
+}
+
+// GetAIUsage returns the user's AI tokens this quota period and what is left
+func (a *WailsApp) GetAIUsage() (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.GetUsage()
+}
+
+// GetRoomAIUsage returns a room's AI usage by student, feature and day
+func (a *WailsApp) GetRoomAIUsage(roomID, from, to string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.GetRoomUsage(roomID, from, to)
+}
+
+// GetAdminAIUsage returns AI usage across all users (admins only)
+func (a *WailsApp) GetAdminAIUsage(from, to string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.GetAdminUsage(from, to)
+}
+
+// GetAIPrompts lists the versioned AI prompts (admins only)
+func (a *WailsApp) GetAIPrompts() ([]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.GetPrompts()
+}
+
+// GetAIPrompt returns a prompt with all of its versions (admins only)
+func (a *WailsApp) GetAIPrompt(name string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.GetPrompt(name)
+}
+
+// CreateAIPromptVersion adds a version of a prompt (admins only)
+func (a *WailsApp) CreateAIPromptVersion(name, text, note string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.CreatePromptVersion(name, text, note)
+}
+
+// ReleaseAIPrompt puts a version of a prompt in use (admins only)
+func (a *WailsApp) ReleaseAIPrompt(name string, version, candidateVersion, candidatePercent int) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.ReleasePrompt(name, version, candidateVersion, candidatePercent)
+}
+
+// RollbackAIPrompt returns a prompt to its previous release (admins only)
+func (a *WailsApp) RollbackAIPrompt(name string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.RollbackPrompt(name)
+}
+
+// GetAISafetyFlags returns students' flagged AI interactions for their parent or teacher
+func (a *WailsApp) GetAISafetyFlags(roomID string, unreviewed bool) ([]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.GetSafetyFlags(roomID, unreviewed)
+}
+
+// ReviewAISafetyFlag marks a flagged AI interaction as reviewed
+func (a *WailsApp) ReviewAISafetyFlag(flagID string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.AIClient.ReviewSafetyFlag(flagID)
 }
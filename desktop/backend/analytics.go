@@ -24,6 +24,30 @@ func (a *WailsApp) GetGameStats(gameID string) (interface{}, error) {
 	return a.api.Analytics.GetGameStats(gameID)
 }
 
+// GetResourceEngagement returns engagement with each teacher resource of a room
+func (a *WailsApp) GetResourceEngagement(roomID, exam, before string) (interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Analytics.GetResourceEngagement(roomID, exam, before)
+}
+
+// GetResourceEngagementDetail returns each student's engagement with one resource
+func (a *WailsApp) GetResourceEngagementDetail(roomID, resourceID, exam, before string) (interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Analytics.GetResourceEngagementDetail(roomID, resourceID, exam, before)
+}
+
+// GetStudentEngagement returns one student's engagement with a room's teacher resources
+func (a *WailsApp) GetStudentEngagement(roomID, studentID, exam, before string) (interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Analytics.GetStudentEngagement(roomID, studentID, exam, before)
+}
+
 // ExportAnalyticsCSV exports analytics as CSV file
 func (a *WailsApp) ExportAnalyticsCSV(roomID string) error {
 	if a.authToken == "" {
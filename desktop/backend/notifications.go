@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"buddy-desktop/internal/api"
+	"fmt"
+)
+
+// GetNotifications gets the current user's notifications
+func (a *WailsApp) GetNotifications(unreadOnly bool) (*api.NotificationList, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Notifications.GetNotifications(unreadOnly)
+}
+
+// MarkNotificationRead marks a notification as read
+func (a *WailsApp) MarkNotificationRead(notificationID string) error {
+	if a.authToken == "" {
+		return fmt.Errorf("not authenticated")
+	}
+	return a.api.Notifications.MarkRead(notificationID)
+}
+
+// MarkAllNotificationsRead marks all notifications as read
+func (a *WailsApp) MarkAllNotificationsRead() error {
+	if a.authToken == "" {
+		return fmt.Errorf("not authenticated")
+	}
+	return a.api.Notifications.MarkAllRead()
+}
+
+// GetJob gets the status of a background job, such as a report being generated
+func (a *WailsApp) GetJob(jobID string) (*api.Job, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Jobs.GetJob(jobID)
+}
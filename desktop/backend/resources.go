@@ -3,6 +3,7 @@ package backend
 import (
 	"buddy-desktop/internal/api"
 	"fmt"
+	"strings"
 )
 
 // UploadFile uploads a file for a room
@@ -29,6 +30,86 @@ func (a *WailsApp) GetResources(roomID, uploaderType, category string) ([]api.Re
 	return a.api.Resource.GetResources(roomID, uploaderType, category)
 }
 
+// SearchResources gets one page of a room's resources, filtered and sorted
+func (a *WailsApp) SearchResources(roomID string, query api.ResourceQuery) (*api.ResourcePage, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.SearchResources(roomID, query)
+}
+
+// GetResourceTags gets the tags used in a room
+func (a *WailsApp) GetResourceTags(roomID string) ([]api.ResourceTagCount, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.GetTags(roomID)
+}
+
+// UpdateResource changes a resource's details, tags or folder
+func (a *WailsApp) UpdateResource(resourceID string, changes map[string]interface{}) (*api.Resource, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.UpdateResource(resourceID, changes)
+}
+
+// GetResourceFolders gets all folders of a room
+func (a *WailsApp) GetResourceFolders(roomID string) ([]api.ResourceFolder, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.GetFolders(roomID)
+}
+
+// CreateResourceFolder creates a folder in a room
+func (a *WailsApp) CreateResourceFolder(roomID, name, parentID string) (*api.ResourceFolder, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.CreateFolder(roomID, name, parentID)
+}
+
+// UpdateResourceFolder renames or moves a folder
+func (a *WailsApp) UpdateResourceFolder(roomID, folderID, name, parentID string) (*api.ResourceFolder, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.UpdateFolder(roomID, folderID, name, parentID)
+}
+
+// DeleteResourceFolder deletes a folder, moving its contents up a level
+func (a *WailsApp) DeleteResourceFolder(roomID, folderID string) error {
+	if a.authToken == "" {
+		return fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.DeleteFolder(roomID, folderID)
+}
+
+// GetResourceDownloadURL gets a signed, expiring URL for a resource's file
+func (a *WailsApp) GetResourceDownloadURL(resourceID string) (string, error) {
+	if a.authToken == "" {
+		return "", fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.GetDownloadURL(resourceID)
+}
+
+// GetResourceViewURL gets a signed URL for showing a resource's file in the app
+func (a *WailsApp) GetResourceViewURL(resourceID string) (string, error) {
+	if a.authToken == "" {
+		return "", fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.GetViewURL(resourceID)
+}
+
+// RecordResourceProgress reports how far a video resource has been played
+func (a *WailsApp) RecordResourceProgress(resourceID string, position, duration float64) error {
+	if a.authToken == "" {
+		return fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.RecordProgress(resourceID, position, duration)
+}
+
 // DeleteResource deletes a resource
 func (a *WailsApp) DeleteResource(resourceID string) error {
 	if a.authToken == "" {
@@ -44,3 +125,97 @@ func (a *WailsApp) ShareResource(resourceID string, sharedWith []string, isPubli
 	}
 	return a.api.Resource.ShareResource(resourceID, sharedWith, isPublic)
 }
+
+// UploadResourceResumable uploads a file in resumable chunks and lets the server
+// create the resource once the file is stored. Progress is emitted as
+// "upload:progress" events so the UI can follow along.
+func (a *WailsApp) UploadResourceResumable(roomID, filePath string, resource api.Resource) (*api.UploadStatus, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Uploads.Upload(roomID, filePath, resourceUploadMetadata(resource), a.emitUploadProgress)
+}
+
+// GetPendingUploads lists interrupted uploads that can be resumed
+func (a *WailsApp) GetPendingUploads() ([]api.PendingUpload, error) {
+	return a.api.Uploads.Pending()
+}
+
+// ResumeUpload continues an interrupted upload
+func (a *WailsApp) ResumeUpload(uploadID string) (*api.UploadStatus, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Uploads.Resume(uploadID, a.emitUploadProgress)
+}
+
+// CancelUpload discards an interrupted upload
+func (a *WailsApp) CancelUpload(uploadID string) error {
+	return a.api.Uploads.Cancel(uploadID)
+}
+
+func (a *WailsApp) emitUploadProgress(upload *api.PendingUpload, sent, total int64) {
+	a.EmitEvent("upload:progress", map[string]interface{}{
+		"id":        upload.ID,
+		"room_id":   upload.RoomID,
+		"file_name": upload.Metadata["filename"],
+		"sent":      sent,
+		"total":     total,
+	})
+}
+
+// resourceUploadMetadata describes the resource the server should create when the upload completes
+func resourceUploadMetadata(resource api.Resource) map[string]string {
+	metadata := map[string]string{}
+	if resource.Name != "" {
+		metadata["name"] = resource.Name
+	}
+	if resource.Description != "" {
+		metadata["description"] = resource.Description
+	}
+	if resource.Category != "" {
+		metadata["category"] = resource.Category
+	}
+	if resource.Subject != "" {
+		metadata["subject"] = resource.Subject
+	}
+	if len(resource.Subjects) > 0 {
+		metadata["subjects"] = strings.Join(resource.Subjects, ",")
+	}
+	if len(resource.Tags) > 0 {
+		metadata["tags"] = strings.Join(resource.Tags, ",")
+	}
+	if resource.FolderID != "" {
+		metadata["folder_id"] = resource.FolderID
+	}
+	return metadata
+}
+
+// UploadResourceVersion uploads a file as a new version of a resource. The server
+// notifies room members and invalidates the room AI's copy of the old file.
+func (a *WailsApp) UploadResourceVersion(roomID, resourceID, filePath, changeNote string) (*api.UploadStatus, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	metadata := map[string]string{"resource_id": resourceID}
+	if changeNote != "" {
+		metadata["change_note"] = changeNote
+	}
+	return a.api.Uploads.Upload(roomID, filePath, metadata, a.emitUploadProgress)
+}
+
+// GetResourceVersions gets a resource's version history
+func (a *WailsApp) GetResourceVersions(resourceID string) ([]api.ResourceVersion, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.GetVersions(resourceID)
+}
+
+// RestoreResourceVersion makes an earlier version of a resource current again
+func (a *WailsApp) RestoreResourceVersion(resourceID string, version int) (*api.Resource, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Resource.RestoreVersion(resourceID, version)
+}
@@ -96,13 +96,37 @@ func (a *WailsApp) GetRoomAIStatus(roomID string) (map[string]interface{}, error
 }
 
 // TrainRoomAI trains the room AI with selected resources
-func (a *WailsApp) TrainRoomAI(roomID string, resourceIDs []string) error {
+func (a *WailsApp) TrainRoomAI(roomID string, resourceIDs []string) (map[string]interface{}, error) {
 	if a.authToken == "" {
-		return fmt.Errorf("not authenticated")
+		return nil, fmt.Errorf("not authenticated")
 	}
 	return a.api.Room.TrainRoomAI(roomID, resourceIDs)
 }
 
+// ClearRoomAICache drops the room AI's cached answers (room owner)
+func (a *WailsApp) ClearRoomAICache(roomID string) error {
+	if a.authToken == "" {
+		return fmt.Errorf("not authenticated")
+	}
+	return a.api.Room.ClearRoomAICache(roomID)
+}
+
+// GetRoomAISettings gets how the room AI behaves
+func (a *WailsApp) GetRoomAISettings(roomID string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Room.GetRoomAISettings(roomID)
+}
+
+// UpdateRoomAISettings changes how the room AI behaves (room owner)
+func (a *WailsApp) UpdateRoomAISettings(roomID string, settings map[string]interface{}) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return a.api.Room.UpdateRoomAISettings(roomID, settings)
+}
+
 // ChatWithRoomAI sends a message to the room's AI coach
 func (a *WailsApp) ChatWithRoomAI(roomID string, message string) (map[string]interface{}, error) {
 	if a.authToken == "" {
@@ -110,3 +134,14 @@ func (a *WailsApp) ChatWithRoomAI(roomID string, message string) (map[string]int
 	}
 	return a.api.Room.ChatWithRoomAI(roomID, message)
 }
+
+// StreamChatWithRoomAI sends a message to the room's AI coach and streams the
+// answer as "ai:stream" events tagged with streamID; the result has the citations
+func (a *WailsApp) StreamChatWithRoomAI(streamID, roomID, message string) (map[string]interface{}, error) {
+	if a.authToken == "" {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	ctx, emit, done := a.startAIStream(streamID)
+	defer done()
+	return a.api.Room.ChatWithRoomAIStream(ctx, roomID, message, emit)
+}
@@ -3,6 +3,7 @@ package backend
 import (
 	"buddy-desktop/internal/api"
 	"context"
+	"sync"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -13,6 +14,7 @@ type WailsApp struct {
 	api        *api.Service
 	authToken  string
 	currentUser *api.User
+	aiStreams  sync.Map // Stream ID -> context.CancelFunc of running AI streams
 }
 
 // NewWailsApp creates a new Wails application instance
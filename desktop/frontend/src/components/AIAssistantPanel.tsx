@@ -1,6 +1,9 @@
-import { useState } from 'react';
-import { Sparkles, Send, X, BookOpen, Lightbulb, HelpCircle, FileText, Loader2 } from 'lucide-react';
+import { useState, useRef, useEffect } from 'react';
+import { Sparkles, Send, X, BookOpen, Lightbulb, HelpCircle, FileText, Loader2, Square } from 'lucide-react';
 import { useApp } from '../contexts/AppContext';
+import { useAIStream } from '../hooks/useAIStream';
+import { useAIThreads, AIThreadMessage, AIThreadReply } from '../hooks/useAIThreads';
+import AIThreadPicker from './AIThreadPicker';
 import Card from './ui/Card';
 import Button from './ui/Button';
 import Badge from './ui/Badge';
@@ -13,18 +16,63 @@ interface Message {
   resources?: Array<{ name: string; type: string }>;
 }
 
+const greeting = (): Message => ({
+  id: 1,
+  type: 'assistant',
+  message: 'Hi! I\'m Buddy, your AI learning assistant. How can I help you today?',
+  time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
+});
+
+// toMessages shows a stored conversation after the greeting
+const toMessages = (messages: AIThreadMessage[]): Message[] => [
+  greeting(),
+  ...messages.map((m, idx) => ({
+    id: idx + 2,
+    type: m.role,
+    message: m.content,
+    time: new Date(m.created_at).toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
+  })),
+];
+
+interface AIUsage {
+  period: 'daily' | 'monthly';
+  reset_at: string;
+  user: { used: number; limit: number; remaining?: number };
+  organization?: { used: number; limit: number; remaining?: number };
+}
+
+// quotaLeft is the tightest remaining quota, or null when there is none
+const quotaLeft = (usage: AIUsage | null): number | null => {
+  if (!usage) return null;
+  const left = [usage.user.remaining, usage.organization?.remaining].filter((r): r is number => r !== undefined);
+  return left.length ? Math.min(...left) : null;
+};
+
 export default function AIAssistantPanel() {
   const { showAIPanel, setShowAIPanel } = useApp();
   const [input, setInput] = useState('');
-  const [conversation, setConversation] = useState<Message[]>([
-    {
-      id: 1,
-      type: 'assistant',
-      message: 'Hi! I\'m Buddy, your AI learning assistant. How can I help you today?',
-      time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
-    },
-  ]);
+  const [conversation, setConversation] = useState<Message[]>([greeting()]);
+  const threads = useAIThreads();
   const [loading, setLoading] = useState(false);
+  // Text of the response being streamed
+  const [streamingText, setStreamingText] = useState('');
+  const stopped = useRef(false);
+  const aiStream = useAIStream();
+  const [usage, setUsage] = useState<AIUsage | null>(null);
+
+  const loadUsage = async () => {
+    try {
+      // @ts-ignore
+      const { GetAIUsage } = await import('../../wailsjs/go/main/App');
+      setUsage(await GetAIUsage());
+    } catch (error) {
+      console.error('Failed to load AI usage:', error);
+    }
+  };
+
+  useEffect(() => {
+    if (showAIPanel) loadUsage();
+  }, [showAIPanel]);
 
   const quickActions = [
     { icon: BookOpen, label: 'Explain Topic', color: 'primary', action: 'explain' },
@@ -46,32 +94,87 @@ export default function AIAssistantPanel() {
     const currentInput = input;
     setInput('');
     setLoading(true);
+    setStreamingText('');
+    stopped.current = false;
+    let partial = '';
 
     try {
-      // Import Chat function dynamically
-      const { Chat } = await import('../../wailsjs/go/main/App');
-      
-      const response: any = await Chat(currentInput, '');
-      
+      // @ts-ignore
+      const { StreamAIThreadMessage } = await import('../../wailsjs/go/main/App');
+
+      const thread = await threads.ensure();
+      const reply: AIThreadReply = await aiStream.run(
+        (streamID) => StreamAIThreadMessage(streamID, thread.id, currentInput),
+        (text) => {
+          partial = text;
+          setStreamingText(text);
+        },
+      );
+      threads.replied(reply);
+
       const assistantMessage: Message = {
         id: conversation.length + 2,
         type: 'assistant',
-        message: response.response || 'I apologize, but I couldn\'t generate a response. Please try again.',
+        message: reply.message?.content || 'I apologize, but I couldn\'t generate a response. Please try again.',
         time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
       };
 
       setConversation(prev => [...prev, assistantMessage]);
     } catch (error: any) {
-      console.error('AI chat error:', error);
+      if (!stopped.current) {
+        console.error('AI chat error:', error);
+      }
+      const reason = String(error?.message || error || '');
       const errorMessage: Message = {
         id: conversation.length + 2,
         type: 'assistant',
-        message: 'Sorry, I encountered an error. Please make sure you\'re connected and try again.',
+        message: stopped.current
+          ? (partial ? partial + ' …' : 'Stopped.')
+          : /quota/i.test(reason)
+            ? reason
+            : 'Sorry, I encountered an error. Please make sure you\'re connected and try again.',
         time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
       };
       setConversation(prev => [...prev, errorMessage]);
     } finally {
       setLoading(false);
+      setStreamingText('');
+      loadUsage();
+    }
+  };
+
+  const handleStop = () => {
+    stopped.current = true;
+    aiStream.cancel();
+  };
+
+  const handleOpenThread = async (threadId: string) => {
+    try {
+      setConversation(toMessages(await threads.open(threadId)));
+    } catch (error: any) {
+      alert('Failed to open conversation: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const handleNewThread = (mode: '' | 'tutor' = '') => {
+    threads.startNew(mode);
+    setConversation([greeting()]);
+  };
+
+  const handleRenameThread = async (title: string) => {
+    try {
+      await threads.rename(title);
+    } catch (error: any) {
+      alert('Failed to rename conversation: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const handleDeleteThread = async () => {
+    try {
+      await threads.remove();
+      setConversation([greeting()]);
+    } catch (error: any) {
+      alert('Failed to delete conversation: ' + (error.message || error || 'Unknown error'));
     }
   };
 
@@ -127,6 +230,19 @@ export default function AIAssistantPanel() {
         </button>
       </div>
 
+      <div className="px-4 py-3 border-b border-light-text-secondary/10 dark:border-dark-border">
+        <AIThreadPicker
+          threads={threads.threads}
+          current={threads.current}
+          newMode={threads.newMode}
+          disabled={loading}
+          onOpen={handleOpenThread}
+          onNew={handleNewThread}
+          onRename={handleRenameThread}
+          onDelete={handleDeleteThread}
+        />
+      </div>
+
       <div className="p-4 border-b border-light-text-secondary/10 dark:border-dark-border">
         <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary mb-3">
           Quick Actions
@@ -193,13 +309,19 @@ export default function AIAssistantPanel() {
         ))}
         {loading && (
           <div className="flex justify-start">
-            <div className="bg-light-bg dark:bg-dark-bg rounded-2xl p-3">
-              <div className="flex items-center gap-2">
-                <Loader2 className="w-4 h-4 text-primary animate-spin" />
-                <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">
-                  Buddy is thinking...
+            <div className="max-w-[85%] bg-light-bg dark:bg-dark-bg rounded-2xl p-3">
+              {streamingText ? (
+                <p className="text-sm whitespace-pre-line text-light-text-primary dark:text-dark-text-primary">
+                  {streamingText}
                 </p>
-              </div>
+              ) : (
+                <div className="flex items-center gap-2">
+                  <Loader2 className="w-4 h-4 text-primary animate-spin" />
+                  <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">
+                    Buddy is thinking...
+                  </p>
+                </div>
+              )}
             </div>
           </div>
         )}
@@ -216,20 +338,24 @@ export default function AIAssistantPanel() {
             disabled={loading}
             className="flex-1 px-4 py-2.5 bg-light-bg dark:bg-dark-bg border border-light-text-secondary/20 dark:border-dark-border rounded-button text-light-text-primary dark:text-dark-text-primary placeholder:text-light-text-secondary dark:placeholder:text-dark-text-secondary focus:outline-none focus:ring-2 focus:ring-primary/50 resize-none disabled:opacity-50"
           />
-          <Button 
-            className="flex-shrink-0" 
-            onClick={handleSend}
-            disabled={loading || !input.trim()}
-          >
-            {loading ? (
-              <Loader2 className="w-5 h-5 animate-spin" />
-            ) : (
+          {loading ? (
+            <Button className="flex-shrink-0" variant="secondary" onClick={handleStop} title="Stop">
+              <Square className="w-5 h-5" />
+            </Button>
+          ) : (
+            <Button
+              className="flex-shrink-0"
+              onClick={handleSend}
+              disabled={!input.trim()}
+            >
               <Send className="w-5 h-5" />
-            )}
-          </Button>
+            </Button>
+          )}
         </div>
         <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary mt-2">
-          Buddy uses AI to provide helpful learning assistance
+          {quotaLeft(usage) !== null
+            ? `${quotaLeft(usage)!.toLocaleString()} AI tokens left ${usage!.period === 'monthly' ? 'this month' : 'today'} · resets ${new Date(usage!.reset_at).toLocaleString('en-US', { month: 'short', day: 'numeric', hour: '2-digit', minute: '2-digit' })}`
+            : 'Buddy uses AI to provide helpful learning assistance'}
         </p>
       </div>
     </div>
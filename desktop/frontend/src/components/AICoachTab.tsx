@@ -1,14 +1,26 @@
 import { useState, useEffect, useRef } from 'react';
-import { Sparkles, Send, Loader2, Info, BookOpen, AlertCircle } from 'lucide-react';
+import { Sparkles, Send, Loader2, Info, BookOpen, AlertCircle, Square } from 'lucide-react';
+import { useAIStream } from '../hooks/useAIStream';
+import { useAIThreads, AIThreadMessage, AIThreadReply } from '../hooks/useAIThreads';
+import AIThreadPicker from './AIThreadPicker';
 import Card from './ui/Card';
 import Button from './ui/Button';
 import Badge from './ui/Badge';
 
+interface Citation {
+  number: number;
+  resource_id: string;
+  resource_name: string;
+  section?: string;
+  excerpt: string;
+}
+
 interface Message {
   id: number;
   type: 'user' | 'coach';
   message: string;
   time: string;
+  citations?: Citation[];
 }
 
 interface AICoachTabProps {
@@ -19,9 +31,14 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
   const [input, setInput] = useState('');
   const [conversation, setConversation] = useState<Message[]>([]);
   const [loading, setLoading] = useState(false);
+  // Text of the answer being streamed
+  const [streamingText, setStreamingText] = useState('');
+  const stopped = useRef(false);
+  const aiStream = useAIStream();
   const [aiStatus, setAiStatus] = useState<any>(null);
   const [loadingStatus, setLoadingStatus] = useState(true);
   const messagesEndRef = useRef<HTMLDivElement>(null);
+  const threads = useAIThreads(roomId);
 
   useEffect(() => {
     if (roomId) {
@@ -32,7 +49,7 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
   useEffect(() => {
     // Scroll to bottom when new messages arrive
     messagesEndRef.current?.scrollIntoView({ behavior: 'smooth' });
-  }, [conversation]);
+  }, [conversation, streamingText]);
 
   const checkAIStatus = async () => {
     setLoadingStatus(true);
@@ -44,14 +61,7 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
 
       // Add welcome message if AI is trained
       if (status.trained && conversation.length === 0) {
-        setConversation([
-          {
-            id: 1,
-            type: 'coach',
-            message: `Hi! I'm your AI Coach for this course. I've been trained on ${status.resource_count} resources. Feel free to ask me anything about the course material!`,
-            time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
-          },
-        ]);
+        setConversation([welcome(status)]);
       }
     } catch (error: any) {
       console.error('Failed to check AI status:', error);
@@ -60,6 +70,55 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
     }
   };
 
+  const welcome = (status: any): Message => ({
+    id: 1,
+    type: 'coach',
+    message: `Hi! I'm your AI Coach for this course. I've been trained on ${status?.resource_count || 0} resources. Feel free to ask me anything about the course material!`,
+    time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
+  });
+
+  // toMessages shows a stored conversation after the welcome message
+  const toMessages = (messages: AIThreadMessage[]): Message[] => [
+    welcome(aiStatus),
+    ...messages.map((m, idx) => ({
+      id: idx + 2,
+      type: m.role === 'user' ? 'user' as const : 'coach' as const,
+      message: m.content,
+      time: new Date(m.created_at).toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
+      citations: m.citations,
+    })),
+  ];
+
+  const handleOpenThread = async (threadId: string) => {
+    try {
+      setConversation(toMessages(await threads.open(threadId)));
+    } catch (error: any) {
+      alert('Failed to open conversation: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const handleNewThread = (mode: '' | 'tutor' = '') => {
+    threads.startNew(mode);
+    setConversation([welcome(aiStatus)]);
+  };
+
+  const handleRenameThread = async (title: string) => {
+    try {
+      await threads.rename(title);
+    } catch (error: any) {
+      alert('Failed to rename conversation: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const handleDeleteThread = async () => {
+    try {
+      await threads.remove();
+      setConversation([welcome(aiStatus)]);
+    } catch (error: any) {
+      alert('Failed to delete conversation: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
   const handleSend = async () => {
     if (!input.trim() || loading || !roomId) return;
 
@@ -74,35 +133,58 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
     const currentInput = input;
     setInput('');
     setLoading(true);
+    setStreamingText('');
+    stopped.current = false;
+    let partial = '';
 
     try {
       // @ts-ignore
-      const { ChatWithRoomAI } = await import('../wailsjs/go/main/App');
-      
-      const response: any = await ChatWithRoomAI(roomId, currentInput);
-      
+      const { StreamAIThreadMessage } = await import('../wailsjs/go/main/App');
+
+      // Citations arrive with the final answer
+      const thread = await threads.ensure();
+      const reply: AIThreadReply = await aiStream.run(
+        (streamID) => StreamAIThreadMessage(streamID, thread.id, currentInput),
+        (text) => {
+          partial = text;
+          setStreamingText(text);
+        },
+      );
+      threads.replied(reply);
+
       const coachMessage: Message = {
         id: conversation.length + 2,
         type: 'coach',
-        message: response.response || 'I apologize, but I couldn\'t generate a response. Please try again.',
+        message: reply.message?.content || 'I apologize, but I couldn\'t generate a response. Please try again.',
         time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
+        citations: reply.message?.citations || [],
       };
 
       setConversation(prev => [...prev, coachMessage]);
     } catch (error: any) {
-      console.error('AI Coach chat error:', error);
+      if (!stopped.current) {
+        console.error('AI Coach chat error:', error);
+      }
       const errorMessage: Message = {
         id: conversation.length + 2,
         type: 'coach',
-        message: error.message || 'Sorry, I encountered an error. Please try again later.',
+        message: stopped.current
+          ? (partial ? partial + ' …' : 'Stopped.')
+          : error.message || error || 'Sorry, I encountered an error. Please try again later.',
         time: new Date().toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' }),
       };
       setConversation(prev => [...prev, errorMessage]);
     } finally {
       setLoading(false);
+      setStreamingText('');
     }
   };
 
+  const handleStop = () => {
+    stopped.current = true;
+    aiStream.cancel();
+  };
+
   const handleKeyDown = (e: React.KeyboardEvent) => {
     if (e.key === 'Enter' && !e.shiftKey) {
       e.preventDefault();
@@ -189,6 +271,20 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
         </div>
       </div>
 
+      {/* Conversations */}
+      <div className="px-6 py-3 border-b border-light-text-secondary/10 dark:border-dark-border">
+        <AIThreadPicker
+          threads={threads.threads}
+          current={threads.current}
+          newMode={threads.newMode}
+          disabled={loading}
+          onOpen={handleOpenThread}
+          onNew={handleNewThread}
+          onRename={handleRenameThread}
+          onDelete={handleDeleteThread}
+        />
+      </div>
+
       {/* Quick Prompts */}
       {conversation.length <= 1 && (
         <div className="p-6 border-b border-light-text-secondary/10 dark:border-dark-border bg-light-card dark:bg-dark-card">
@@ -230,6 +326,20 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
               <p className={`text-sm leading-relaxed whitespace-pre-line ${msg.type === 'user' ? 'text-white' : 'text-light-text-primary dark:text-dark-text-primary'}`}>
                 {msg.message}
               </p>
+              {msg.citations && msg.citations.length > 0 && (
+                <div className="mt-3 pt-2 border-t border-light-text-secondary/10 dark:border-dark-border space-y-1">
+                  {msg.citations.map(citation => (
+                    <p
+                      key={citation.number}
+                      title={citation.excerpt}
+                      className="text-xs text-light-text-secondary dark:text-dark-text-secondary"
+                    >
+                      [{citation.number}] {citation.resource_name}
+                      {citation.section ? ` — ${citation.section}` : ''}
+                    </p>
+                  ))}
+                </div>
+              )}
               <p className={`text-xs mt-2 ${msg.type === 'user' ? 'text-white/70' : 'text-light-text-secondary dark:text-dark-text-secondary'}`}>
                 {msg.time}
               </p>
@@ -239,13 +349,19 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
         
         {loading && (
           <div className="flex justify-start">
-            <div className="bg-light-card dark:bg-dark-card border border-light-text-secondary/10 dark:border-dark-border rounded-2xl p-4">
-              <div className="flex items-center gap-2">
-                <Loader2 className="w-4 h-4 text-primary animate-spin" />
-                <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">
-                  AI Coach is thinking...
+            <div className="max-w-[75%] bg-light-card dark:bg-dark-card border border-light-text-secondary/10 dark:border-dark-border rounded-2xl p-4">
+              {streamingText ? (
+                <p className="text-sm whitespace-pre-line text-light-text-primary dark:text-dark-text-primary">
+                  {streamingText}
                 </p>
-              </div>
+              ) : (
+                <div className="flex items-center gap-2">
+                  <Loader2 className="w-4 h-4 text-primary animate-spin" />
+                  <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">
+                    AI Coach is thinking...
+                  </p>
+                </div>
+              )}
             </div>
           </div>
         )}
@@ -265,18 +381,20 @@ export default function AICoachTab({ roomId }: AICoachTabProps) {
             disabled={loading}
             className="flex-1 px-4 py-3 bg-light-bg dark:bg-dark-bg border border-light-text-secondary/20 dark:border-dark-border rounded-button text-light-text-primary dark:text-dark-text-primary placeholder:text-light-text-secondary dark:placeholder:text-dark-text-secondary focus:outline-none focus:ring-2 focus:ring-primary/50 resize-none disabled:opacity-50"
           />
-          <Button 
-            className="flex-shrink-0" 
-            size="lg"
-            onClick={handleSend}
-            disabled={loading || !input.trim()}
-          >
-            {loading ? (
-              <Loader2 className="w-5 h-5 animate-spin" />
-            ) : (
+          {loading ? (
+            <Button className="flex-shrink-0" size="lg" variant="secondary" onClick={handleStop} title="Stop">
+              <Square className="w-5 h-5" />
+            </Button>
+          ) : (
+            <Button
+              className="flex-shrink-0"
+              size="lg"
+              onClick={handleSend}
+              disabled={!input.trim()}
+            >
               <Send className="w-5 h-5" />
-            )}
-          </Button>
+            </Button>
+          )}
         </div>
         <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary mt-3 flex items-center gap-1">
           <Info className="w-3 h-3" />
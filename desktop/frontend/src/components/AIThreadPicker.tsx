@@ -0,0 +1,87 @@
+import { Plus, Pencil, Trash2, Lightbulb } from 'lucide-react';
+import type { AIThread } from '../hooks/useAIThreads';
+
+interface AIThreadPickerProps {
+  threads: AIThread[];
+  current: AIThread | null;
+  newMode?: '' | 'tutor'; // Mode of the conversation the next message starts
+  disabled?: boolean;
+  onOpen: (threadId: string) => void;
+  onNew: (mode?: '' | 'tutor') => void;
+  onRename: (title: string) => void;
+  onDelete: () => void;
+}
+
+const NEW_TUTOR_SESSION = 'new:tutor';
+
+// tutorStatus describes how far a tutoring session has got
+const tutorStatus = (thread: AIThread) => {
+  const tutor = thread.tutor;
+  if (!tutor) return 'Tutoring: hints instead of answers';
+  if (tutor.revealed) return 'Tutoring: solution shown, ask about a new problem';
+  if (tutor.hint_level === 0) return `Tutoring: have a try (${tutor.reveal_after} attempts before the solution)`;
+  return `Tutoring: hint ${tutor.hint_level} of ${tutor.reveal_after - 1}`;
+};
+
+// AIThreadPicker switches between stored AI conversations
+export default function AIThreadPicker({ threads, current, newMode = '', disabled, onOpen, onNew, onRename, onDelete }: AIThreadPickerProps) {
+  const handleRename = () => {
+    if (!current) return;
+    const title = prompt('Rename conversation:', current.title);
+    if (!title?.trim() || title.trim() === current.title) return;
+    onRename(title.trim());
+  };
+
+  const handleDelete = () => {
+    if (!current) return;
+    if (!confirm(`Delete the conversation "${current.title || 'Untitled'}"?`)) return;
+    onDelete();
+  };
+
+  const iconButton = 'p-2 rounded-button text-light-text-secondary dark:text-dark-text-secondary hover:bg-light-bg dark:hover:bg-dark-bg transition-colors disabled:opacity-50';
+
+  const handleSelect = (value: string) => {
+    if (value === NEW_TUTOR_SESSION) onNew('tutor');
+    else if (value) onOpen(value);
+    else onNew();
+  };
+
+  const tutoring = current ? current.mode === 'tutor' || !!current.tutor : newMode === 'tutor';
+
+  return (
+    <div>
+      <div className="flex items-center gap-1">
+        <select
+          value={current?.id || (newMode === 'tutor' ? NEW_TUTOR_SESSION : '')}
+          onChange={(e) => handleSelect(e.target.value)}
+          disabled={disabled}
+          className="flex-1 min-w-0 p-2 text-sm rounded-button bg-light-bg dark:bg-dark-bg border border-light-text-secondary/20 dark:border-dark-border text-light-text-primary dark:text-dark-text-primary focus:outline-none focus:ring-2 focus:ring-primary/50 disabled:opacity-50"
+        >
+          <option value="">New conversation</option>
+          <option value={NEW_TUTOR_SESSION}>New tutoring session</option>
+          {threads.map((thread) => (
+            <option key={thread.id} value={thread.id}>
+              {thread.title || 'Untitled'}
+            </option>
+          ))}
+        </select>
+        <button className={iconButton} onClick={() => onNew()} disabled={disabled || !current} title="New conversation">
+          <Plus className="w-4 h-4" />
+        </button>
+        <button className={iconButton} onClick={handleRename} disabled={disabled || !current} title="Rename">
+          <Pencil className="w-4 h-4" />
+        </button>
+        <button className={iconButton} onClick={handleDelete} disabled={disabled || !current} title="Delete">
+          <Trash2 className="w-4 h-4" />
+        </button>
+      </div>
+      {tutoring && (
+        <p className="flex items-center gap-1 mt-2 text-xs text-light-text-secondary dark:text-dark-text-secondary">
+          <Lightbulb className="w-3 h-3 text-warning" />
+          {current ? tutorStatus(current) : 'Tutoring: hints instead of answers'}
+          {current?.tutor?.forced && ' (required by your teacher)'}
+        </p>
+      )}
+    </div>
+  );
+}
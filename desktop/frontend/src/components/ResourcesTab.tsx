@@ -1,11 +1,15 @@
 import { useState, useEffect } from 'react';
-import { Upload, FileText, Video, BookOpen, FileCheck, Share2, Trash2, Filter, Loader, FolderOpen, Sparkles, Brain } from 'lucide-react';
+import { Upload, FileText, Video, BookOpen, FileCheck, Share2, Trash2, Filter, Loader, FolderOpen, Sparkles, Brain, History, Folder, FolderPlus, Pencil, Search, Tag, ChevronRight } from 'lucide-react';
 import { useAuth } from '../contexts/AuthContext';
 import Card from './ui/Card';
 import Button from './ui/Button';
 import Badge from './ui/Badge';
 import Input from './ui/Input';
 import UploadResourceModal from './modals/UploadResourceModal';
+import ResourceVersionsModal from './modals/ResourceVersionsModal';
+import VideoPlayerModal from './modals/VideoPlayerModal';
+import RoomAISettingsModal from './modals/RoomAISettingsModal';
+import OrganizeResourceModal, { ResourceFolder, folderPath } from './modals/OrganizeResourceModal';
 
 interface Resource {
   id: string;
@@ -20,9 +24,43 @@ interface Resource {
   uploader_id: string;
   is_public: boolean;
   shared_with: string[];
+  version?: number;
+  folder_id?: string;
+  tags?: string[];
+  preview?: ResourcePreview;
   created_at: string;
+  updated_at?: string;
 }
 
+interface ResourcePreview {
+  status: string;
+  thumbnail_url?: string;
+  page_count?: number;
+  duration_seconds?: number;
+  text?: string;
+}
+
+interface ResourcePage {
+  resources: Resource[];
+  total: number;
+}
+
+interface TagCount {
+  tag: string;
+  count: number;
+}
+
+// Resources fetched per request; more are loaded on demand
+const PAGE_SIZE = 30;
+
+const sortOptions = [
+  { id: '', label: 'Newest first' },
+  { id: 'oldest', label: 'Oldest first' },
+  { id: 'updated', label: 'Recently updated' },
+  { id: 'name', label: 'Name' },
+  { id: 'size', label: 'Largest first' },
+];
+
 interface SyllabusItem {
   title: string;
   description: string;
@@ -53,8 +91,21 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
 
   const [teacherResources, setTeacherResources] = useState<Resource[]>([]);
   const [studentResources, setStudentResources] = useState<Resource[]>([]);
+  const [teacherTotal, setTeacherTotal] = useState(0);
+  const [studentTotal, setStudentTotal] = useState(0);
   const [loading, setLoading] = useState(true);
+  const [hasLoaded, setHasLoaded] = useState(false);
+  const [loadingMore, setLoadingMore] = useState(false);
   const [selectedCategory, setSelectedCategory] = useState('all');
+  const [folders, setFolders] = useState<ResourceFolder[]>([]);
+  const [currentFolder, setCurrentFolder] = useState(''); // '' for the top level
+  const [searchInput, setSearchInput] = useState('');
+  const [search, setSearch] = useState('');
+  const [selectedTags, setSelectedTags] = useState<string[]>([]);
+  const [availableTags, setAvailableTags] = useState<TagCount[]>([]);
+  const [selectedSubject, setSelectedSubject] = useState('');
+  const [sort, setSort] = useState('');
+  const [organizeResource, setOrganizeResource] = useState<Resource | null>(null);
   const [showUploadModal, setShowUploadModal] = useState(false);
   const [showShareModal, setShowShareModal] = useState(false);
   const [selectedResource, setSelectedResource] = useState<Resource | null>(null);
@@ -62,14 +113,31 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
   const [trainingAI, setTrainingAI] = useState(false);
   const [aiStatus, setAiStatus] = useState<any>(null);
   const [showTrainConfirm, setShowTrainConfirm] = useState(false);
+  const [pendingUploads, setPendingUploads] = useState<any[]>([]);
+  const [resumingUpload, setResumingUpload] = useState<string | null>(null);
+  const [versionsResource, setVersionsResource] = useState<Resource | null>(null);
+  const [watchResource, setWatchResource] = useState<Resource | null>(null);
+  const [showAISettings, setShowAISettings] = useState(false);
 
   useEffect(() => {
-    loadResources();
     loadRoomSyllabus();
+    loadPendingUploads();
+    loadFolders();
+    loadTags();
     if (isTeacher) {
       loadAIStatus();
     }
-  }, [roomId, selectedCategory]);
+  }, [roomId]);
+
+  useEffect(() => {
+    loadResources();
+  }, [roomId, selectedCategory, currentFolder, search, selectedTags, selectedSubject, sort]);
+
+  // Search as the user types, once they pause
+  useEffect(() => {
+    const timer = setTimeout(() => setSearch(searchInput.trim()), 300);
+    return () => clearTimeout(timer);
+  }, [searchInput]);
 
   const loadRoomSyllabus = async () => {
     try {
@@ -92,23 +160,178 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
     }
   };
 
+  const resourceQuery = (uploaderType: string, offset: number) => ({
+    uploader_type: uploaderType,
+    category: selectedCategory,
+    // Searches cover every folder unless one is open
+    folder_id: currentFolder || (search ? '' : 'root'),
+    tags: selectedTags,
+    subject: selectedSubject,
+    q: search,
+    sort,
+    limit: PAGE_SIZE,
+    offset,
+  });
+
   const loadResources = async () => {
     setLoading(true);
     try {
       // @ts-ignore
-      const { GetResources } = await import('../wailsjs/go/main/App');
+      const { SearchResources } = await import('../wailsjs/go/main/App');
+      const empty: ResourcePage = { resources: [], total: 0 };
 
-      const [teacherRes, studentRes] = await Promise.all([
-        GetResources(roomId, 'teacher', selectedCategory).catch(() => []),
-        isStudent ? GetResources(roomId, 'student', selectedCategory).catch(() => []) : Promise.resolve([]),
+      const [teacherPage, studentPage]: ResourcePage[] = await Promise.all([
+        SearchResources(roomId, resourceQuery('teacher', 0)).catch(() => empty),
+        isStudent ? SearchResources(roomId, resourceQuery('student', 0)).catch(() => empty) : Promise.resolve(empty),
       ]);
-      
-      setTeacherResources(Array.isArray(teacherRes) ? teacherRes : []);
-      setStudentResources(Array.isArray(studentRes) ? studentRes : []);
+
+      setTeacherResources(teacherPage?.resources || []);
+      setTeacherTotal(teacherPage?.total || 0);
+      setStudentResources(studentPage?.resources || []);
+      setStudentTotal(studentPage?.total || 0);
     } catch (error) {
       console.error('Failed to load resources:', error);
     } finally {
       setLoading(false);
+      setHasLoaded(true);
+    }
+  };
+
+  const loadMore = async (uploaderType: 'teacher' | 'student') => {
+    const loaded = uploaderType === 'teacher' ? teacherResources : studentResources;
+    setLoadingMore(true);
+    try {
+      // @ts-ignore
+      const { SearchResources } = await import('../wailsjs/go/main/App');
+      const page: ResourcePage = await SearchResources(roomId, resourceQuery(uploaderType, loaded.length));
+      const more = page?.resources || [];
+      if (uploaderType === 'teacher') {
+        setTeacherResources([...loaded, ...more]);
+        setTeacherTotal(page?.total || 0);
+      } else {
+        setStudentResources([...loaded, ...more]);
+        setStudentTotal(page?.total || 0);
+      }
+    } catch (error) {
+      console.error('Failed to load more resources:', error);
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
+  const loadFolders = async () => {
+    try {
+      // @ts-ignore
+      const { GetResourceFolders } = await import('../wailsjs/go/main/App');
+      const result = await GetResourceFolders(roomId);
+      setFolders(Array.isArray(result) ? result : []);
+    } catch (error) {
+      console.error('Failed to load folders:', error);
+    }
+  };
+
+  const loadTags = async () => {
+    try {
+      // @ts-ignore
+      const { GetResourceTags } = await import('../wailsjs/go/main/App');
+      const result = await GetResourceTags(roomId);
+      setAvailableTags(Array.isArray(result) ? result : []);
+    } catch (error) {
+      console.error('Failed to load tags:', error);
+    }
+  };
+
+  const handleCreateFolder = async () => {
+    const name = prompt(currentFolder ? `New folder in ${folderPath(folders, currentFolder)}:` : 'New folder name:');
+    if (!name?.trim()) return;
+    try {
+      // @ts-ignore
+      const { CreateResourceFolder } = await import('../wailsjs/go/main/App');
+      await CreateResourceFolder(roomId, name.trim(), currentFolder);
+      loadFolders();
+    } catch (error: any) {
+      alert('Failed to create folder: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const handleRenameFolder = async () => {
+    const folder = folders.find((f) => f.id === currentFolder);
+    if (!folder) return;
+    const name = prompt('Rename folder:', folder.name);
+    if (!name?.trim() || name.trim() === folder.name) return;
+    try {
+      // @ts-ignore
+      const { UpdateResourceFolder } = await import('../wailsjs/go/main/App');
+      await UpdateResourceFolder(roomId, folder.id, name.trim(), folder.parent_id || '');
+      loadFolders();
+    } catch (error: any) {
+      alert('Failed to rename folder: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const handleDeleteFolder = async () => {
+    const folder = folders.find((f) => f.id === currentFolder);
+    if (!folder) return;
+    if (!confirm(`Delete the folder "${folder.name}"? Its files and subfolders will move up a level.`)) return;
+    try {
+      // @ts-ignore
+      const { DeleteResourceFolder } = await import('../wailsjs/go/main/App');
+      await DeleteResourceFolder(roomId, folder.id);
+      setCurrentFolder(folder.parent_id || '');
+      loadFolders();
+    } catch (error: any) {
+      alert('Failed to delete folder: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const toggleTag = (tag: string) => {
+    setSelectedTags(selectedTags.includes(tag) ? selectedTags.filter((t) => t !== tag) : [...selectedTags, tag]);
+  };
+
+  // Breadcrumb from the top level to the open folder
+  const folderTrail: ResourceFolder[] = [];
+  for (let f = folders.find((x) => x.id === currentFolder); f && folderTrail.length < 10; f = folders.find((x) => x.id === f?.parent_id)) {
+    folderTrail.unshift(f);
+  }
+  const subfolders = folders
+    .filter((f) => (f.parent_id || '') === currentFolder)
+    .sort((a, b) => a.name.localeCompare(b.name));
+
+  const loadPendingUploads = async () => {
+    try {
+      // @ts-ignore
+      const { GetPendingUploads } = await import('../wailsjs/go/main/App');
+      const pending = await GetPendingUploads();
+      setPendingUploads((Array.isArray(pending) ? pending : []).filter((u: any) => u.room_id === roomId));
+    } catch (error) {
+      console.error('Failed to load pending uploads:', error);
+    }
+  };
+
+  const handleResumeUpload = async (uploadId: string) => {
+    setResumingUpload(uploadId);
+    try {
+      // @ts-ignore
+      const { ResumeUpload } = await import('../wailsjs/go/main/App');
+      await ResumeUpload(uploadId);
+      loadResources();
+    } catch (error: any) {
+      alert('Failed to resume upload: ' + (error.message || error || 'Unknown error'));
+    } finally {
+      setResumingUpload(null);
+      loadPendingUploads();
+    }
+  };
+
+  const handleCancelUpload = async (uploadId: string) => {
+    try {
+      // @ts-ignore
+      const { CancelUpload } = await import('../wailsjs/go/main/App');
+      await CancelUpload(uploadId);
+    } catch (error) {
+      console.error('Failed to cancel upload:', error);
+    } finally {
+      loadPendingUploads();
     }
   };
 
@@ -120,6 +343,14 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
     return Math.round(bytes / Math.pow(k, i) * 100) / 100 + ' ' + sizes[i];
   };
 
+  const formatDuration = (seconds: number) => {
+    const total = Math.round(seconds);
+    const h = Math.floor(total / 3600);
+    const m = Math.floor((total % 3600) / 60);
+    const s = String(total % 60).padStart(2, '0');
+    return h > 0 ? `${h}:${String(m).padStart(2, '0')}:${s}` : `${m}:${s}`;
+  };
+
   const getCategoryIcon = (category: string) => {
     switch (category) {
       case 'video': return Video;
@@ -130,6 +361,18 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
     }
   };
 
+  const handleOpen = async (resourceId: string) => {
+    try {
+      // @ts-ignore
+      const { GetResourceDownloadURL } = await import('../wailsjs/go/main/App');
+      const url = await GetResourceDownloadURL(resourceId);
+      window.open(url, '_blank');
+    } catch (error) {
+      console.error('Failed to open resource:', error);
+      alert('Failed to open resource');
+    }
+  };
+
   const handleDelete = async (resourceId: string) => {
     if (!confirm('Are you sure you want to delete this resource?')) return;
 
@@ -139,6 +382,7 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
       await DeleteResource(resourceId);
       alert('Resource deleted successfully!');
       loadResources();
+      loadTags();
     } catch (error: any) {
       alert('Failed to delete resource: ' + error.message);
     }
@@ -173,10 +417,16 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
     setTrainingAI(true);
     try {
       // @ts-ignore
-      const { TrainRoomAI } = await import('../wailsjs/go/main/App');
-      const resourceIDs = teacherResources.map(r => r.id);
-      await TrainRoomAI(roomId, resourceIDs);
-      alert('AI Coach trained successfully! Students can now ask questions in the AI Coach tab.');
+      const { TrainRoomAI, GetResources } = await import('../wailsjs/go/main/App');
+      // Every resource, not just the filtered page on screen; the server
+      // leaves out uploaders the room doesn't train with
+      const allResources: Resource[] = await GetResources(roomId, '', 'all');
+      const resourceIDs = (allResources || []).map(r => r.id);
+      const context: any = await TrainRoomAI(roomId, resourceIDs);
+      const excluded: any[] = context?.excluded_resources || [];
+      alert(excluded.length > 0
+        ? `AI Coach trained, but ${excluded.length} resource(s) were left out:\n` + excluded.map((r) => `• ${r.resource_name}: ${r.reason}`).join('\n')
+        : 'AI Coach trained successfully! Students can now ask questions in the AI Coach tab.');
       await loadAIStatus();
     } catch (error: any) {
       console.error('Training error:', error);
@@ -186,15 +436,35 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
     }
   };
 
+  const handleClearAICache = async () => {
+    if (!confirm('Clear the AI Coach\'s saved answers? Students will get fresh answers from the current resources.')) return;
+    try {
+      // @ts-ignore
+      const { ClearRoomAICache } = await import('../wailsjs/go/main/App');
+      await ClearRoomAICache(roomId);
+      alert('Saved AI Coach answers cleared.');
+    } catch (error: any) {
+      alert('Failed to clear AI Coach answers: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
   const renderResourceCard = (resource: Resource, isOwnResource: boolean) => {
     const CategoryIcon = getCategoryIcon(resource.category);
 
     return (
       <Card key={resource.id} className="p-4 hover:bg-light-bg dark:hover:bg-dark-bg transition-colors">
         <div className="flex items-start gap-4">
-          <div className="p-3 bg-primary/10 rounded-button">
-            <CategoryIcon className="w-6 h-6 text-primary" />
-          </div>
+          {resource.preview?.thumbnail_url ? (
+            <img
+              src={resource.preview.thumbnail_url}
+              alt=""
+              className="w-20 h-20 object-cover rounded-button bg-light-bg dark:bg-dark-bg flex-shrink-0"
+            />
+          ) : (
+            <div className="p-3 bg-primary/10 rounded-button">
+              <CategoryIcon className="w-6 h-6 text-primary" />
+            </div>
+          )}
           <div className="flex-1 min-w-0">
             <div className="flex items-start justify-between mb-2">
               <div className="flex-1">
@@ -206,15 +476,59 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
                     {resource.description}
                   </p>
                 )}
+                {!resource.description && resource.preview?.text && (
+                  <p className="text-sm italic text-light-text-secondary dark:text-dark-text-secondary line-clamp-2 mb-2">
+                    {resource.preview.text}
+                  </p>
+                )}
                 {resource.subject && (
                   <Badge variant="neutral" size="sm" className="mb-2">
                     📚 {resource.subject}
                   </Badge>
                 )}
+                {resource.tags && resource.tags.length > 0 && (
+                  <div className="flex flex-wrap gap-1 mb-2">
+                    {resource.tags.map((tag) => (
+                      <button
+                        key={tag}
+                        onClick={() => !selectedTags.includes(tag) && toggleTag(tag)}
+                        className="text-xs text-primary hover:underline"
+                      >
+                        #{tag}
+                      </button>
+                    ))}
+                  </div>
+                )}
+                {search && resource.folder_id && (
+                  <p className="flex items-center gap-1 text-xs text-light-text-secondary dark:text-dark-text-secondary mb-2">
+                    <Folder className="w-3 h-3" />
+                    {folderPath(folders, resource.folder_id)}
+                  </p>
+                )}
                 <div className="flex items-center gap-3 text-xs text-light-text-secondary dark:text-dark-text-secondary">
                   <span>{formatFileSize(resource.file_size)}</span>
+                  {!!resource.preview?.page_count && (
+                    <>
+                      <span>•</span>
+                      <span>
+                        {resource.preview.page_count} {resource.file_type.includes('presentation') ? 'slides' : 'pages'}
+                      </span>
+                    </>
+                  )}
+                  {!!resource.preview?.duration_seconds && (
+                    <>
+                      <span>•</span>
+                      <span>{formatDuration(resource.preview.duration_seconds)}</span>
+                    </>
+                  )}
                   <span>•</span>
-                  <span>{new Date(resource.created_at).toLocaleDateString()}</span>
+                  <span>{new Date(resource.updated_at || resource.created_at).toLocaleDateString()}</span>
+                  {(resource.version || 1) > 1 && (
+                    <>
+                      <span>•</span>
+                      <span>v{resource.version}</span>
+                    </>
+                  )}
                   {resource.is_public && (
                     <>
                       <span>•</span>
@@ -225,14 +539,42 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
               </div>
             </div>
             <div className="flex items-center gap-2 mt-3">
+              {resource.file_type?.startsWith('video/') && (
+                <Button
+                  variant="primary"
+                  size="sm"
+                  onClick={() => setWatchResource(resource)}
+                >
+                  <Video className="w-4 h-4" />
+                  Watch
+                </Button>
+              )}
               <Button
                 variant="secondary"
                 size="sm"
-                onClick={() => window.open(resource.file_url, '_blank')}
+                onClick={() => handleOpen(resource.id)}
               >
                 <FileText className="w-4 h-4" />
                 Download
               </Button>
+              <Button
+                variant="secondary"
+                size="sm"
+                onClick={() => setVersionsResource(resource)}
+              >
+                <History className="w-4 h-4" />
+                {isOwnResource ? 'Versions' : 'History'}
+              </Button>
+              {(isOwnResource || isTeacher) && (
+                <Button
+                  variant="secondary"
+                  size="sm"
+                  onClick={() => setOrganizeResource(resource)}
+                >
+                  <Pencil className="w-4 h-4" />
+                  Organize
+                </Button>
+              )}
               {isOwnResource && isStudent && (
                 <>
                   <Button
@@ -260,7 +602,7 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
     );
   };
 
-  if (loading) {
+  if (loading && !hasLoaded) {
     return (
       <div className="flex items-center justify-center h-full">
         <Loader className="w-8 h-8 animate-spin text-primary" />
@@ -327,19 +669,75 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
               </h4>
               <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">
                 {aiStatus.trained 
-                  ? `Trained with ${aiStatus.resource_count} resources • ${aiStatus.message_count || 0} messages answered`
+                  ? aiStatus.needs_retraining
+                    ? `${aiStatus.stale_resource_count} trained resource(s) have new versions. Retrain so the AI Coach uses them.`
+                    : `Trained with ${aiStatus.resource_count} resources • ${aiStatus.message_count || 0} messages answered`
                   : 'Upload resources and train the AI to enable the AI Coach for students'}
               </p>
+              {aiStatus.excluded_resources?.length > 0 && (
+                <p className="text-xs text-warning mt-1" title={aiStatus.excluded_resources.map((r: any) => `${r.resource_name}: ${r.reason}`).join('\n')}>
+                  {aiStatus.excluded_resources.length} resource(s) left out of training
+                </p>
+              )}
             </div>
             {aiStatus.last_trained_at && (
               <Badge variant="neutral" size="sm">
                 Last trained: {new Date(aiStatus.last_trained_at).toLocaleDateString()}
               </Badge>
             )}
+            {aiStatus.trained && (
+              <Button variant="ghost" size="sm" onClick={handleClearAICache} title="Clear saved answers">
+                Clear saved answers
+              </Button>
+            )}
+            <Button variant="ghost" size="sm" onClick={() => setShowAISettings(true)} title="AI Coach settings">
+              AI settings
+            </Button>
           </div>
         </Card>
       )}
 
+      {/* Interrupted uploads */}
+      {pendingUploads.length > 0 && (
+        <Card className="p-4 space-y-3">
+          <h4 className="font-semibold text-light-text-primary dark:text-dark-text-primary">
+            Interrupted uploads
+          </h4>
+          {pendingUploads.map((upload) => (
+            <div key={upload.id} className="flex items-center justify-between gap-3">
+              <div className="min-w-0">
+                <p className="text-sm font-medium text-light-text-primary dark:text-dark-text-primary truncate">
+                  {upload.metadata?.name || upload.metadata?.filename}
+                </p>
+                <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary">
+                  {formatFileSize(upload.offset)} of {formatFileSize(upload.size)} uploaded
+                </p>
+              </div>
+              <div className="flex gap-2">
+                <Button
+                  size="sm"
+                  variant="secondary"
+                  onClick={() => handleResumeUpload(upload.id)}
+                  disabled={resumingUpload !== null}
+                >
+                  {resumingUpload === upload.id ? <Loader className="w-4 h-4 animate-spin" /> : <Upload className="w-4 h-4" />}
+                  Resume
+                </Button>
+                <Button
+                  size="sm"
+                  variant="ghost"
+                  onClick={() => handleCancelUpload(upload.id)}
+                  disabled={resumingUpload === upload.id}
+                >
+                  <Trash2 className="w-4 h-4" />
+                  Cancel
+                </Button>
+              </div>
+            </div>
+          ))}
+        </Card>
+      )}
+
       {/* Category Filter */}
       <div className="flex gap-2 overflow-x-auto pb-2 scrollbar-hide">
         {categories.map((cat) => {
@@ -358,6 +756,115 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
         })}
       </div>
 
+      {/* Folders */}
+      <div className="space-y-3">
+        <div className="flex items-center gap-1 flex-wrap text-sm">
+          <button
+            onClick={() => setCurrentFolder('')}
+            className={`flex items-center gap-1 px-2 py-1 rounded-button hover:bg-light-bg dark:hover:bg-dark-bg ${currentFolder ? 'text-light-text-secondary dark:text-dark-text-secondary' : 'font-semibold text-light-text-primary dark:text-dark-text-primary'}`}
+          >
+            <FolderOpen className="w-4 h-4" />
+            All files
+          </button>
+          {folderTrail.map((folder) => (
+            <span key={folder.id} className="flex items-center gap-1">
+              <ChevronRight className="w-4 h-4 text-light-text-secondary dark:text-dark-text-secondary" />
+              <button
+                onClick={() => setCurrentFolder(folder.id)}
+                className={`px-2 py-1 rounded-button hover:bg-light-bg dark:hover:bg-dark-bg ${folder.id === currentFolder ? 'font-semibold text-light-text-primary dark:text-dark-text-primary' : 'text-light-text-secondary dark:text-dark-text-secondary'}`}
+              >
+                {folder.name}
+              </button>
+            </span>
+          ))}
+          {isTeacher && (
+            <div className="flex gap-1 ml-auto">
+              <Button variant="ghost" size="sm" onClick={handleCreateFolder}>
+                <FolderPlus className="w-4 h-4" />
+                New Folder
+              </Button>
+              {currentFolder && (
+                <>
+                  <Button variant="ghost" size="sm" onClick={handleRenameFolder}>
+                    <Pencil className="w-4 h-4" />
+                    Rename
+                  </Button>
+                  <Button variant="ghost" size="sm" onClick={handleDeleteFolder}>
+                    <Trash2 className="w-4 h-4" />
+                    Delete
+                  </Button>
+                </>
+              )}
+            </div>
+          )}
+        </div>
+        {!search && subfolders.length > 0 && (
+          <div className="flex gap-2 flex-wrap">
+            {subfolders.map((folder) => (
+              <Button key={folder.id} variant="secondary" size="sm" onClick={() => setCurrentFolder(folder.id)}>
+                <Folder className="w-4 h-4" />
+                {folder.name}
+              </Button>
+            ))}
+          </div>
+        )}
+      </div>
+
+      {/* Search, topic and sort */}
+      <div className="flex items-center gap-3 flex-wrap">
+        <div className="flex-1 min-w-[16rem]">
+          <Input
+            icon={<Search className="w-4 h-4" />}
+            value={searchInput}
+            onChange={(e) => setSearchInput(e.target.value)}
+            placeholder="Search names, descriptions and document text..."
+          />
+        </div>
+        {roomSyllabus?.items && roomSyllabus.items.length > 0 && (
+          <select
+            value={selectedSubject}
+            onChange={(e) => setSelectedSubject(e.target.value)}
+            className="p-2.5 rounded-button bg-light-card dark:bg-dark-card border border-light-text-secondary/20 dark:border-dark-border text-light-text-primary dark:text-dark-text-primary focus:outline-none focus:ring-2 focus:ring-primary/50"
+          >
+            <option value="">All topics</option>
+            {[...roomSyllabus.items].sort((a, b) => a.order - b.order).map((item) => (
+              <option key={item.title} value={item.title}>{item.title}</option>
+            ))}
+          </select>
+        )}
+        <select
+          value={sort}
+          onChange={(e) => setSort(e.target.value)}
+          className="p-2.5 rounded-button bg-light-card dark:bg-dark-card border border-light-text-secondary/20 dark:border-dark-border text-light-text-primary dark:text-dark-text-primary focus:outline-none focus:ring-2 focus:ring-primary/50"
+        >
+          {sortOptions.map((option) => (
+            <option key={option.id} value={option.id}>
+              {option.id === '' && search ? 'Best match' : option.label}
+            </option>
+          ))}
+        </select>
+        {loading && <Loader className="w-5 h-5 animate-spin text-primary" />}
+      </div>
+
+      {availableTags.length > 0 && (
+        <div className="flex items-center gap-2 flex-wrap">
+          <Tag className="w-4 h-4 text-light-text-secondary dark:text-dark-text-secondary" />
+          {availableTags.slice(0, 20).map(({ tag, count }) => (
+            <button
+              key={tag}
+              onClick={() => toggleTag(tag)}
+              className={`px-2 py-0.5 rounded-full text-xs transition-colors ${
+                selectedTags.includes(tag)
+                  ? 'bg-primary text-white'
+                  : 'bg-primary/10 text-primary hover:bg-primary/20'
+              }`}
+            >
+              #{tag} ({count})
+            </button>
+          ))}
+        </div>
+      )}
+
       {/* Teacher Resources Section */}
       {Array.isArray(teacherResources) && teacherResources.length > 0 && (
         <div>
@@ -368,8 +875,16 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
             </h3>
           </div>
           <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
-            {teacherResources.map((resource) => renderResourceCard(resource, false))}
+            {teacherResources.map((resource) => renderResourceCard(resource, resource.uploader_id === user?.id))}
           </div>
+          {teacherResources.length < teacherTotal && (
+            <div className="flex justify-center mt-4">
+              <Button variant="secondary" onClick={() => loadMore('teacher')} disabled={loadingMore}>
+                {loadingMore && <Loader className="w-4 h-4 animate-spin" />}
+                Load more ({teacherResources.length} of {teacherTotal})
+              </Button>
+            </div>
+          )}
         </div>
       )}
 
@@ -387,6 +902,14 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
               renderResourceCard(resource, resource.uploader_id === user?.id)
             )}
           </div>
+          {studentResources.length < studentTotal && (
+            <div className="flex justify-center mt-4">
+              <Button variant="secondary" onClick={() => loadMore('student')} disabled={loadingMore}>
+                {loadingMore && <Loader className="w-4 h-4 animate-spin" />}
+                Load more ({studentResources.length} of {studentTotal})
+              </Button>
+            </div>
+          )}
         </div>
       )}
 
@@ -396,10 +919,14 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
         <Card className="p-12 text-center">
           <FolderOpen className="w-16 h-16 text-light-text-secondary dark:text-dark-text-secondary mx-auto mb-4 opacity-50" />
           <h3 className="text-lg font-semibold text-light-text-primary dark:text-dark-text-primary mb-2">
-            No resources yet
+            {search || selectedTags.length > 0 || selectedSubject || selectedCategory !== 'all'
+              ? 'No matching resources'
+              : currentFolder ? 'This folder is empty' : 'No resources yet'}
           </h3>
           <p className="text-light-text-secondary dark:text-dark-text-secondary mb-4">
-            {isTeacher
+            {search || selectedTags.length > 0 || selectedSubject
+              ? 'Try other words or clear some filters'
+              : isTeacher
               ? 'Upload course materials for your students'
               : 'Upload your own resources or wait for teacher to share materials'}
           </p>
@@ -410,15 +937,61 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
         </Card>
       )}
 
+      {/* AI Settings Modal */}
+      {showAISettings && (
+        <RoomAISettingsModal roomId={roomId} onClose={() => setShowAISettings(false)} />
+      )}
+
+      {/* Video Player Modal */}
+      {watchResource && (
+        <VideoPlayerModal resource={watchResource} onClose={() => setWatchResource(null)} />
+      )}
+
+      {/* Organize Modal */}
+      {organizeResource && (
+        <OrganizeResourceModal
+          resource={organizeResource}
+          folders={folders}
+          onClose={() => setOrganizeResource(null)}
+          onSaved={() => {
+            setOrganizeResource(null);
+            loadResources();
+            loadTags();
+          }}
+        />
+      )}
+
+      {/* Version History Modal */}
+      {versionsResource && (
+        <ResourceVersionsModal
+          roomId={roomId}
+          resource={versionsResource}
+          canEdit={versionsResource.uploader_id === user?.id}
+          onClose={() => setVersionsResource(null)}
+          onChanged={() => {
+            loadResources();
+            if (isTeacher) {
+              loadAIStatus();
+            }
+          }}
+        />
+      )}
+
       {/* Upload Modal */}
       {showUploadModal && (
         <UploadResourceModal
           roomId={roomId}
           syllabus={roomSyllabus}
-          onClose={() => setShowUploadModal(false)}
+          folderId={currentFolder}
+          onClose={() => {
+            setShowUploadModal(false);
+            loadPendingUploads();
+          }}
           onSuccess={() => {
             setShowUploadModal(false);
             loadResources();
+            loadPendingUploads();
+            loadTags();
           }}
         />
       )}
@@ -451,7 +1024,7 @@ export default function ResourcesTab({ roomId }: ResourcesTabProps) {
               </h3>
             </div>
             <p className="text-light-text-secondary dark:text-dark-text-secondary mb-2">
-              Train AI Coach with <strong>{teacherResources?.length || 0} teacher resources</strong>?
+              Train AI Coach with <strong>all teacher resources</strong> in this room?
             </p>
             <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary mb-6">
               This will allow students to ask questions about the course materials in the AI Coach tab.
@@ -0,0 +1,142 @@
+import { useState, useEffect } from 'react';
+import { ShieldCheck, ShieldAlert } from 'lucide-react';
+import Card from '../ui/Card';
+import Badge from '../ui/Badge';
+import Button from '../ui/Button';
+import { GetAISafetyFlags, ReviewAISafetyFlag } from '../../../wailsjs/go/main/App';
+
+type AISafetyFlagsProps = {
+  roomID?: string; // A teacher's room; parents leave it empty
+  studentID?: string; // Only show this child's flags
+};
+
+type SafetyFlag = {
+  id: string;
+  user_id: string;
+  student_name?: string;
+  feature: string;
+  stage: string;
+  reason: string;
+  input: string;
+  output?: string;
+  age?: number;
+  created_at: string;
+  reviewed_at?: string;
+};
+
+const stageLabels: Record<string, { label: string; variant: 'error' | 'warning' | 'neutral' }> = {
+  input: { label: 'Blocked message', variant: 'error' },
+  output: { label: 'Blocked answer', variant: 'error' },
+  provider: { label: 'Blocked by AI filter', variant: 'warning' },
+  off_topic: { label: 'Off topic', variant: 'neutral' },
+};
+
+export default function AISafetyFlags({ roomID, studentID }: AISafetyFlagsProps) {
+  const [flags, setFlags] = useState<SafetyFlag[]>([]);
+  const [loading, setLoading] = useState(true);
+  const [showReviewed, setShowReviewed] = useState(false);
+
+  useEffect(() => {
+    loadFlags();
+  }, [roomID, showReviewed]);
+
+  const loadFlags = async () => {
+    setLoading(true);
+    try {
+      setFlags((await GetAISafetyFlags(roomID || '', !showReviewed)) || []);
+    } catch (err) {
+      console.error('Failed to load flagged AI conversations:', err);
+      setFlags([]);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleReview = async (flagID: string) => {
+    try {
+      const reviewed = await ReviewAISafetyFlag(flagID);
+      setFlags((current) =>
+        showReviewed
+          ? current.map((flag) => (flag.id === flagID ? { ...flag, reviewed_at: reviewed.reviewed_at } : flag))
+          : current.filter((flag) => flag.id !== flagID)
+      );
+    } catch (error: any) {
+      alert('Failed to mark as reviewed: ' + (error.message || error || 'Unknown error'));
+    }
+  };
+
+  const shown = studentID ? flags.filter((flag) => flag.user_id === studentID) : flags;
+
+  return (
+    <Card className="p-6">
+      <div className="flex items-center justify-between mb-4">
+        <div className="flex items-center gap-3">
+          <div className="p-2 bg-error/10 rounded-button">
+            <ShieldAlert className="w-5 h-5 text-error" />
+          </div>
+          <h3 className="text-lg font-semibold text-light-text-primary dark:text-dark-text-primary">
+            Flagged AI Conversations
+          </h3>
+        </div>
+        <label className="flex items-center gap-2 text-sm text-light-text-secondary dark:text-dark-text-secondary">
+          <input type="checkbox" checked={showReviewed} onChange={(e) => setShowReviewed(e.target.checked)} />
+          Show reviewed
+        </label>
+      </div>
+
+      {loading ? (
+        <div className="text-center py-6">
+          <div className="animate-spin rounded-full h-6 w-6 border-b-2 border-primary mx-auto"></div>
+        </div>
+      ) : shown.length === 0 ? (
+        <div className="text-center py-6 text-light-text-secondary dark:text-dark-text-secondary text-sm">
+          <ShieldCheck className="w-10 h-10 mx-auto mb-2 opacity-50" />
+          Nothing to review
+        </div>
+      ) : (
+        <div className="space-y-3">
+          {shown.map((flag) => {
+            const stage = stageLabels[flag.stage] || { label: flag.stage, variant: 'neutral' as const };
+            return (
+              <div key={flag.id} className="p-3 rounded-button bg-light-bg dark:bg-dark-bg">
+                <div className="flex items-center justify-between gap-2 mb-2">
+                  <div className="flex items-center gap-2 flex-wrap">
+                    <Badge variant={stage.variant} size="sm">{stage.label}</Badge>
+                    {!studentID && (
+                      <span className="text-sm font-medium text-light-text-primary dark:text-dark-text-primary">
+                        {flag.student_name || 'Student'}
+                      </span>
+                    )}
+                    <span className="text-xs text-light-text-secondary dark:text-dark-text-secondary">
+                      {new Date(flag.created_at).toLocaleString()}
+                    </span>
+                  </div>
+                  {flag.reviewed_at ? (
+                    <Badge variant="success" size="sm">Reviewed</Badge>
+                  ) : (
+                    <Button size="sm" variant="secondary" onClick={() => handleReview(flag.id)}>
+                      Mark reviewed
+                    </Button>
+                  )}
+                </div>
+                {flag.input && (
+                  <p className="text-sm text-light-text-primary dark:text-dark-text-primary">“{flag.input}”</p>
+                )}
+                {flag.output && (
+                  <p className="text-xs mt-1 text-light-text-secondary dark:text-dark-text-secondary line-clamp-3">
+                    Answer: {flag.output}
+                  </p>
+                )}
+                {flag.reason && flag.stage !== 'off_topic' && (
+                  <p className="text-xs mt-1 text-light-text-secondary dark:text-dark-text-secondary">
+                    Reason: {flag.reason}
+                  </p>
+                )}
+              </div>
+            );
+          })}
+        </div>
+      )}
+    </Card>
+  );
+}
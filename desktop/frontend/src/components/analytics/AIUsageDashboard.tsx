@@ -0,0 +1,136 @@
+import { useState, useEffect } from 'react';
+import { Sparkles } from 'lucide-react';
+import Card from '../ui/Card';
+import { GetRoomAIUsage } from '../../../wailsjs/go/main/App';
+
+type AIUsageDashboardProps = {
+  roomID: string;
+};
+
+type UsageGroup = {
+  key: string;
+  name?: string;
+  calls: number;
+  total_tokens: number;
+  cost_usd: number;
+};
+
+type UsageReport = {
+  totals: { calls: number; total_tokens: number; cost_usd: number };
+  by_feature: UsageGroup[];
+  by_user: UsageGroup[];
+  by_day: UsageGroup[];
+};
+
+const featureLabels: Record<string, string> = {
+  chat: 'Tutor',
+  room_ai: 'Room AI',
+  games: 'Games',
+  reports: 'Reports',
+  goal_suggestions: 'Goal suggestions',
+  productivity: 'Assessments',
+  smart_plan: 'Smart plans',
+};
+
+const formatCost = (cost: number) => (cost > 0 ? `$${cost.toFixed(2)}` : '—');
+
+export default function AIUsageDashboard({ roomID }: AIUsageDashboardProps) {
+  const [report, setReport] = useState<UsageReport | null>(null);
+  const [loading, setLoading] = useState(true);
+
+  useEffect(() => {
+    if (roomID) {
+      loadUsage();
+    }
+  }, [roomID]);
+
+  const loadUsage = async () => {
+    setLoading(true);
+    try {
+      setReport(await GetRoomAIUsage(roomID, '', ''));
+    } catch (err) {
+      console.error('Failed to load AI usage:', err);
+      setReport(null);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const maxDay = Math.max(1, ...(report?.by_day || []).map((day) => day.total_tokens));
+
+  return (
+    <div className="space-y-4">
+      <div>
+        <h2 className="text-2xl font-bold text-light-text-primary dark:text-dark-text-primary">
+          AI Usage
+        </h2>
+        <p className="text-light-text-secondary dark:text-dark-text-secondary">
+          Tokens used in this room over the last 30 days
+        </p>
+      </div>
+
+      {loading ? (
+        <div className="text-center py-12">
+          <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-primary mx-auto mb-4"></div>
+        </div>
+      ) : !report || report.totals.calls === 0 ? (
+        <Card className="p-12 text-center">
+          <Sparkles className="w-16 h-16 text-light-text-secondary dark:text-dark-text-secondary mx-auto mb-4 opacity-50" />
+          <p className="text-light-text-secondary dark:text-dark-text-secondary">
+            No AI use in this room yet
+          </p>
+        </Card>
+      ) : (
+        <div className="grid grid-cols-1 lg:grid-cols-3 gap-4">
+          <Card className="p-4">
+            <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">Total</p>
+            <p className="text-2xl font-bold text-light-text-primary dark:text-dark-text-primary">
+              {report.totals.total_tokens.toLocaleString()} tokens
+            </p>
+            <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary mb-4">
+              {report.totals.calls} requests · {formatCost(report.totals.cost_usd)}
+            </p>
+            <div className="space-y-1 text-sm text-light-text-primary dark:text-dark-text-primary">
+              {report.by_feature.map((feature) => (
+                <div key={feature.key} className="flex justify-between">
+                  <span>{featureLabels[feature.key] || feature.key}</span>
+                  <span>{feature.total_tokens.toLocaleString()}</span>
+                </div>
+              ))}
+            </div>
+          </Card>
+
+          <Card className="p-4">
+            <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary mb-2">By student</p>
+            <table className="w-full text-sm">
+              <tbody className="text-light-text-primary dark:text-dark-text-primary">
+                {report.by_user.map((user) => (
+                  <tr key={user.key || 'room'}>
+                    <td className="py-1">{user.key ? user.name || 'Unknown' : 'Room (no student)'}</td>
+                    <td className="py-1 text-right">{user.total_tokens.toLocaleString()}</td>
+                    <td className="py-1 text-right text-light-text-secondary dark:text-dark-text-secondary">{user.calls}×</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </Card>
+
+          <Card className="p-4">
+            <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary mb-2">By day</p>
+            <div className="space-y-1">
+              {report.by_day.map((day) => (
+                <div key={day.key} className="flex items-center gap-2 text-xs text-light-text-secondary dark:text-dark-text-secondary">
+                  <span className="w-20">{day.key}</span>
+                  <div className="flex-1 h-2 bg-light-bg dark:bg-dark-bg rounded">
+                    <div className="h-2 bg-primary rounded" style={{ width: `${(day.total_tokens / maxDay) * 100}%` }} />
+                  </div>
+                  <span className="w-16 text-right">{day.total_tokens.toLocaleString()}</span>
+                </div>
+              ))}
+            </div>
+          </Card>
+        </div>
+      )}
+    </div>
+  );
+}
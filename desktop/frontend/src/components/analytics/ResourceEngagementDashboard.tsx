@@ -0,0 +1,235 @@
+import { useState, useEffect } from 'react';
+import { Eye, Download, Users, PlayCircle, ChevronDown, ChevronRight, FolderOpen } from 'lucide-react';
+import Card from '../ui/Card';
+import Badge from '../ui/Badge';
+import { GetRoom, GetResourceEngagement, GetResourceEngagementDetail } from '../../../wailsjs/go/main/App';
+
+type ResourceEngagementDashboardProps = {
+  roomID: string;
+};
+
+type ExamDate = {
+  title: string;
+  date: string;
+};
+
+type ResourceSummary = {
+  resource_id: string;
+  name: string;
+  category: string;
+  file_type: string;
+  views: number;
+  downloads: number;
+  students_opened: number;
+  students_total: number;
+  open_rate: number;
+  avg_watch_percent?: number;
+  completed?: number;
+  last_opened_at?: string;
+};
+
+type StudentRow = {
+  user_id: string;
+  name: string;
+  opened: boolean;
+  views: number;
+  downloads: number;
+  first_opened_at?: string;
+  watched_percent?: number;
+  completed: boolean;
+};
+
+type ResourceDetail = ResourceSummary & {
+  students: StudentRow[];
+  not_opened: { user_id: string; name: string }[];
+};
+
+export default function ResourceEngagementDashboard({ roomID }: ResourceEngagementDashboardProps) {
+  const [exams, setExams] = useState<ExamDate[]>([]);
+  const [exam, setExam] = useState('');
+  const [resources, setResources] = useState<ResourceSummary[]>([]);
+  const [studentsTotal, setStudentsTotal] = useState(0);
+  const [loading, setLoading] = useState(true);
+  const [expanded, setExpanded] = useState('');
+  const [detail, setDetail] = useState<ResourceDetail | null>(null);
+
+  useEffect(() => {
+    if (roomID) {
+      setExam('');
+      loadExams();
+    }
+  }, [roomID]);
+
+  useEffect(() => {
+    if (roomID) {
+      loadEngagement();
+    }
+  }, [roomID, exam]);
+
+  const loadExams = async () => {
+    try {
+      const room: any = await GetRoom(roomID);
+      setExams(Array.isArray(room?.exam_dates) ? room.exam_dates : []);
+    } catch (err) {
+      console.error('Failed to load exam dates:', err);
+      setExams([]);
+    }
+  };
+
+  const loadEngagement = async () => {
+    setLoading(true);
+    setExpanded('');
+    setDetail(null);
+    try {
+      const report: any = await GetResourceEngagement(roomID, exam, '');
+      setResources(report?.resources || []);
+      setStudentsTotal(report?.students_total || 0);
+    } catch (err) {
+      console.error('Failed to load resource engagement:', err);
+      setResources([]);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const toggleResource = async (resourceID: string) => {
+    if (expanded === resourceID) {
+      setExpanded('');
+      return;
+    }
+    setExpanded(resourceID);
+    setDetail(null);
+    try {
+      const report: any = await GetResourceEngagementDetail(roomID, resourceID, exam, '');
+      setDetail(report);
+    } catch (err) {
+      console.error('Failed to load resource engagement:', err);
+    }
+  };
+
+  const rateVariant = (rate: number) => (rate >= 70 ? 'success' : rate >= 40 ? 'warning' : 'error');
+
+  return (
+    <div className="space-y-4">
+      <div className="flex items-center justify-between">
+        <div>
+          <h2 className="text-2xl font-bold text-light-text-primary dark:text-dark-text-primary">
+            Resource Engagement
+          </h2>
+          <p className="text-light-text-secondary dark:text-dark-text-secondary">
+            Which students open your materials ({studentsTotal} students)
+          </p>
+        </div>
+        {exams.length > 0 && (
+          <select
+            className="px-3 py-2 border border-light-border dark:border-dark-border rounded-lg bg-light-bg dark:bg-dark-bg text-light-text-primary dark:text-dark-text-primary text-sm"
+            value={exam}
+            onChange={(e) => setExam(e.target.value)}
+          >
+            <option value="">Any time</option>
+            {exams.map((examDate) => (
+              <option key={examDate.title} value={examDate.title}>
+                Before {examDate.title} ({new Date(examDate.date).toLocaleDateString()})
+              </option>
+            ))}
+          </select>
+        )}
+      </div>
+
+      {loading ? (
+        <div className="text-center py-12">
+          <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-primary mx-auto mb-4"></div>
+        </div>
+      ) : resources.length === 0 ? (
+        <Card className="p-12 text-center">
+          <FolderOpen className="w-16 h-16 text-light-text-secondary dark:text-dark-text-secondary mx-auto mb-4 opacity-50" />
+          <p className="text-light-text-secondary dark:text-dark-text-secondary">
+            Upload resources to see how students use them
+          </p>
+        </Card>
+      ) : (
+        <Card className="p-2">
+          {resources.map((resource) => (
+            <div key={resource.resource_id} className="border-b last:border-b-0 border-light-border dark:border-dark-border">
+              <button
+                onClick={() => toggleResource(resource.resource_id)}
+                className="w-full flex items-center gap-4 p-3 text-left hover:bg-light-bg dark:hover:bg-dark-bg rounded-lg transition-colors"
+              >
+                {expanded === resource.resource_id ? (
+                  <ChevronDown className="w-4 h-4 text-light-text-secondary dark:text-dark-text-secondary" />
+                ) : (
+                  <ChevronRight className="w-4 h-4 text-light-text-secondary dark:text-dark-text-secondary" />
+                )}
+                <div className="flex-1 min-w-0">
+                  <p className="font-medium text-light-text-primary dark:text-dark-text-primary truncate">
+                    {resource.name}
+                  </p>
+                  <div className="flex items-center gap-4 text-xs text-light-text-secondary dark:text-dark-text-secondary">
+                    <span className="flex items-center gap-1"><Users className="w-3 h-3" />{resource.students_opened}/{resource.students_total} opened</span>
+                    <span className="flex items-center gap-1"><Eye className="w-3 h-3" />{resource.views} views</span>
+                    <span className="flex items-center gap-1"><Download className="w-3 h-3" />{resource.downloads} downloads</span>
+                    {resource.avg_watch_percent !== undefined && (
+                      <span className="flex items-center gap-1">
+                        <PlayCircle className="w-3 h-3" />
+                        {resource.avg_watch_percent}% watched on average, {resource.completed || 0} finished
+                      </span>
+                    )}
+                  </div>
+                </div>
+                <Badge variant={rateVariant(resource.open_rate)}>{resource.open_rate}%</Badge>
+              </button>
+
+              {expanded === resource.resource_id && (
+                <div className="px-10 pb-4">
+                  {!detail ? (
+                    <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">Loading...</p>
+                  ) : (
+                    <div className="space-y-3">
+                      {detail.not_opened.length > 0 ? (
+                        <p className="text-sm text-light-text-primary dark:text-dark-text-primary">
+                          <strong>Not opened{exam ? ` before ${exam}` : ''}:</strong>{' '}
+                          {detail.not_opened.map((s) => s.name || 'Unknown').join(', ')}
+                        </p>
+                      ) : (
+                        <p className="text-sm text-success">Every student has opened this resource.</p>
+                      )}
+                      <table className="w-full text-sm">
+                        <thead>
+                          <tr className="text-left text-light-text-secondary dark:text-dark-text-secondary">
+                            <th className="py-1 font-medium">Student</th>
+                            <th className="py-1 font-medium">First opened</th>
+                            <th className="py-1 font-medium">Views</th>
+                            <th className="py-1 font-medium">Downloads</th>
+                            {detail.avg_watch_percent !== undefined && <th className="py-1 font-medium">Watched</th>}
+                          </tr>
+                        </thead>
+                        <tbody className="text-light-text-primary dark:text-dark-text-primary">
+                          {detail.students.map((student) => (
+                            <tr key={student.user_id}>
+                              <td className="py-1">{student.name || 'Unknown'}</td>
+                              <td className="py-1">
+                                {student.first_opened_at ? new Date(student.first_opened_at).toLocaleString() : '—'}
+                              </td>
+                              <td className="py-1">{student.views}</td>
+                              <td className="py-1">{student.downloads}</td>
+                              {detail.avg_watch_percent !== undefined && (
+                                <td className="py-1">
+                                  {student.watched_percent ? `${student.watched_percent}%` : '—'}
+                                  {student.completed && ' ✓'}
+                                </td>
+                              )}
+                            </tr>
+                          ))}
+                        </tbody>
+                      </table>
+                    </div>
+                  )}
+                </div>
+              )}
+            </div>
+          ))}
+        </Card>
+      )}
+    </div>
+  );
+}
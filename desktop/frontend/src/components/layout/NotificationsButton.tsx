@@ -0,0 +1,114 @@
+import { useState, useEffect } from 'react';
+import { Bell } from 'lucide-react';
+
+interface Notification {
+  id: string;
+  type: string;
+  title: string;
+  body: string;
+  read: boolean;
+  created_at: string;
+}
+
+// How often unread notifications are checked
+const POLL_INTERVAL_MS = 60 * 1000;
+
+export default function NotificationsButton() {
+  const [open, setOpen] = useState(false);
+  const [notifications, setNotifications] = useState<Notification[]>([]);
+  const [unreadCount, setUnreadCount] = useState(0);
+
+  useEffect(() => {
+    loadNotifications();
+    const interval = setInterval(loadNotifications, POLL_INTERVAL_MS);
+    return () => clearInterval(interval);
+  }, []);
+
+  const loadNotifications = async () => {
+    try {
+      // @ts-ignore
+      const { GetNotifications } = await import('../../../wailsjs/go/main/App');
+      const result: any = await GetNotifications(false);
+      setNotifications(Array.isArray(result?.notifications) ? result.notifications : []);
+      setUnreadCount(result?.unread_count || 0);
+    } catch (error) {
+      // Not logged in yet or server unreachable; try again on the next poll
+    }
+  };
+
+  const handleOpen = async (notification: Notification) => {
+    if (notification.read) return;
+    try {
+      // @ts-ignore
+      const { MarkNotificationRead } = await import('../../../wailsjs/go/main/App');
+      await MarkNotificationRead(notification.id);
+      loadNotifications();
+    } catch (error) {
+      console.error('Failed to mark notification as read:', error);
+    }
+  };
+
+  const handleMarkAllRead = async () => {
+    try {
+      // @ts-ignore
+      const { MarkAllNotificationsRead } = await import('../../../wailsjs/go/main/App');
+      await MarkAllNotificationsRead();
+      loadNotifications();
+    } catch (error) {
+      console.error('Failed to mark notifications as read:', error);
+    }
+  };
+
+  return (
+    <div className="relative">
+      <button
+        onClick={() => setOpen(!open)}
+        className="w-full flex items-center gap-3 px-4 py-3 rounded-button text-light-text-secondary dark:text-dark-text-secondary hover:bg-gradient-card dark:hover:bg-gradient-card-dark hover:text-primary transition-all duration-300 hover:scale-105"
+      >
+        <Bell className="w-5 h-5" />
+        <span className="font-medium flex-1 text-left">Notifications</span>
+        {unreadCount > 0 && (
+          <span className="min-w-[1.25rem] px-1.5 rounded-full bg-primary text-white text-xs font-semibold text-center">
+            {unreadCount}
+          </span>
+        )}
+      </button>
+
+      {open && (
+        <div className="absolute bottom-full left-0 mb-2 w-full max-h-96 overflow-y-auto bg-light-card dark:bg-dark-card border border-light-text-secondary/10 dark:border-dark-border rounded-card shadow-soft z-20">
+          <div className="flex items-center justify-between px-4 py-3 border-b border-light-text-secondary/10 dark:border-dark-border">
+            <span className="font-semibold text-light-text-primary dark:text-dark-text-primary">Notifications</span>
+            {unreadCount > 0 && (
+              <button onClick={handleMarkAllRead} className="text-xs text-primary hover:underline">
+                Mark all as read
+              </button>
+            )}
+          </div>
+          {notifications.length === 0 ? (
+            <p className="px-4 py-6 text-sm text-center text-light-text-secondary dark:text-dark-text-secondary">
+              You're all caught up
+            </p>
+          ) : (
+            notifications.map((notification) => (
+              <button
+                key={notification.id}
+                onClick={() => handleOpen(notification)}
+                className={`w-full text-left px-4 py-3 border-b border-light-text-secondary/5 dark:border-dark-border/50 hover:bg-light-bg dark:hover:bg-dark-bg ${
+                  notification.read ? 'opacity-60' : ''
+                }`}
+              >
+                <p className="text-sm font-medium text-light-text-primary dark:text-dark-text-primary">
+                  {notification.title}
+                </p>
+                <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">{notification.body}</p>
+                <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary mt-1">
+                  {new Date(notification.created_at).toLocaleString()}
+                </p>
+              </button>
+            ))
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
@@ -2,6 +2,7 @@ import { Home, BookOpen, Trophy, Calendar, Users, Moon, Sun, Settings } from 'lu
 import { useApp } from '../../contexts/AppContext';
 import { useTheme } from '../../contexts/ThemeContext';
 import Avatar from '../ui/Avatar';
+import NotificationsButton from './NotificationsButton';
 import { useAuth } from '../../contexts/AuthContext';
 import logoImage from '../../images/icon.png';
 
@@ -78,6 +79,8 @@ export default function Sidebar() {
       </nav>
 
       <div className="relative z-10 p-4 border-t border-light-text-secondary/10 dark:border-dark-border space-y-3">
+        <NotificationsButton />
+
         <button
           onClick={toggleTheme}
           className="w-full flex items-center gap-3 px-4 py-3 rounded-button text-light-text-secondary dark:text-dark-text-secondary hover:bg-gradient-card dark:hover:bg-gradient-card-dark hover:text-primary transition-all duration-300 hover:scale-105"
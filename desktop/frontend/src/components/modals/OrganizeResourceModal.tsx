@@ -0,0 +1,133 @@
+import { useState } from 'react';
+import { X } from 'lucide-react';
+import Modal from '../ui/Modal';
+import Button from '../ui/Button';
+import Input from '../ui/Input';
+
+export interface ResourceFolder {
+  id: string;
+  name: string;
+  parent_id?: string;
+}
+
+interface OrganizeResourceModalProps {
+  resource: { id: string; name: string; tags?: string[]; folder_id?: string };
+  folders: ResourceFolder[];
+  onClose: () => void;
+  onSaved: () => void;
+}
+
+// folderPath labels a folder with its ancestors, e.g. "Unit 1 / Labs"
+export function folderPath(folders: ResourceFolder[], id?: string): string {
+  const names: string[] = [];
+  let current = folders.find((f) => f.id === id);
+  while (current && names.length < 10) {
+    names.unshift(current.name);
+    current = folders.find((f) => f.id === current?.parent_id);
+  }
+  return names.join(' / ');
+}
+
+export default function OrganizeResourceModal({ resource, folders, onClose, onSaved }: OrganizeResourceModalProps) {
+  const [name, setName] = useState(resource.name);
+  const [folderId, setFolderId] = useState(resource.folder_id || '');
+  const [tags, setTags] = useState<string[]>(resource.tags || []);
+  const [tagInput, setTagInput] = useState('');
+  const [saving, setSaving] = useState(false);
+
+  const addTag = () => {
+    const tag = tagInput.trim().toLowerCase();
+    if (tag && !tags.includes(tag)) {
+      setTags([...tags, tag]);
+    }
+    setTagInput('');
+  };
+
+  const handleSave = async () => {
+    if (!name.trim()) return;
+    setSaving(true);
+    try {
+      // @ts-ignore
+      const { UpdateResource } = await import('../../../wailsjs/go/main/App');
+      await UpdateResource(resource.id, { name: name.trim(), folder_id: folderId, tags });
+      onSaved();
+    } catch (error: any) {
+      alert('Failed to save: ' + (error.message || error || 'Unknown error'));
+    } finally {
+      setSaving(false);
+    }
+  };
+
+  const sortedFolders = [...folders].sort((a, b) => folderPath(folders, a.id).localeCompare(folderPath(folders, b.id)));
+
+  return (
+    <Modal
+      isOpen={true}
+      onClose={onClose}
+      title="Organize Resource"
+      footer={
+        <div className="flex justify-end gap-3">
+          <Button variant="secondary" onClick={onClose} disabled={saving}>
+            Cancel
+          </Button>
+          <Button onClick={handleSave} disabled={saving || !name.trim()}>
+            {saving ? 'Saving...' : 'Save'}
+          </Button>
+        </div>
+      }
+    >
+      <div className="space-y-4">
+        <Input label="Name" value={name} onChange={(e) => setName(e.target.value)} />
+
+        <div>
+          <label className="block text-sm font-medium text-light-text-primary dark:text-dark-text-primary mb-1.5">
+            Folder
+          </label>
+          <select
+            value={folderId}
+            onChange={(e) => setFolderId(e.target.value)}
+            className="w-full p-3 rounded-lg bg-light-bg dark:bg-dark-bg border border-light-border dark:border-dark-border text-light-text-primary dark:text-dark-text-primary focus:outline-none focus:ring-2 focus:ring-light-primary dark:focus:ring-dark-primary"
+          >
+            <option value="">No folder</option>
+            {sortedFolders.map((folder) => (
+              <option key={folder.id} value={folder.id}>
+                {folderPath(folders, folder.id)}
+              </option>
+            ))}
+          </select>
+        </div>
+
+        <div>
+          <label className="block text-sm font-medium text-light-text-primary dark:text-dark-text-primary mb-1.5">
+            Tags
+          </label>
+          <div className="flex flex-wrap gap-2 mb-2">
+            {tags.map((tag) => (
+              <span
+                key={tag}
+                className="inline-flex items-center gap-1 px-2 py-1 rounded-full bg-primary/10 text-primary text-sm"
+              >
+                #{tag}
+                <button onClick={() => setTags(tags.filter((t) => t !== tag))} aria-label={`Remove ${tag}`}>
+                  <X className="w-3 h-3" />
+                </button>
+              </span>
+            ))}
+          </div>
+          <Input
+            value={tagInput}
+            onChange={(e) => setTagInput(e.target.value)}
+            onKeyDown={(e) => {
+              if (e.key === 'Enter' || e.key === ',') {
+                e.preventDefault();
+                addTag();
+              }
+            }}
+            onBlur={addTag}
+            placeholder="Type a tag and press Enter"
+          />
+        </div>
+      </div>
+    </Modal>
+  );
+}
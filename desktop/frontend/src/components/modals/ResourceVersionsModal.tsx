@@ -0,0 +1,179 @@
+import { useState, useEffect } from 'react';
+import { Upload, RotateCcw, Loader, History } from 'lucide-react';
+import Modal from '../ui/Modal';
+import Button from '../ui/Button';
+import Input from '../ui/Input';
+import Badge from '../ui/Badge';
+import { EventsOn, EventsOff } from '../../../wailsjs/runtime/runtime';
+
+interface ResourceVersion {
+  id: string;
+  version: number;
+  file_size: number;
+  change_note?: string;
+  restored_from?: number;
+  created_at: string;
+}
+
+interface ResourceVersionsModalProps {
+  roomId: string;
+  resource: { id: string; name: string; version?: number };
+  canEdit: boolean; // Only the uploader can add or restore versions
+  onClose: () => void;
+  onChanged: () => void;
+}
+
+export default function ResourceVersionsModal({ roomId, resource, canEdit, onClose, onChanged }: ResourceVersionsModalProps) {
+  const [versions, setVersions] = useState<ResourceVersion[]>([]);
+  const [loading, setLoading] = useState(true);
+  const [busy, setBusy] = useState(false);
+  const [progress, setProgress] = useState<number | null>(null);
+  const [changeNote, setChangeNote] = useState('');
+
+  useEffect(() => {
+    loadVersions();
+  }, [resource.id]);
+
+  const loadVersions = async () => {
+    setLoading(true);
+    try {
+      // @ts-ignore
+      const { GetResourceVersions } = await import('../../../wailsjs/go/main/App');
+      const result = await GetResourceVersions(resource.id);
+      setVersions(Array.isArray(result) ? result : []);
+    } catch (error) {
+      console.error('Failed to load versions:', error);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const currentVersion = versions.length > 0 ? versions[0].version : resource.version || 1;
+
+  const handleUploadVersion = async () => {
+    try {
+      // @ts-ignore
+      const { OpenFileDialog, UploadResourceVersion } = await import('../../../wailsjs/go/main/App');
+      const filePath = await OpenFileDialog();
+      if (!filePath) return;
+
+      setBusy(true);
+      setProgress(0);
+      EventsOn('upload:progress', (p: { sent: number; total: number }) => {
+        setProgress(p.total > 0 ? Math.round((p.sent / p.total) * 100) : 0);
+      });
+      const status: any = await UploadResourceVersion(roomId, resource.id, filePath, changeNote.trim());
+      alert(status?.scan_status === 'pending'
+        ? 'New version uploaded! It will be available once the malware scan finishes.'
+        : 'New version uploaded. Room members have been notified.');
+      setChangeNote('');
+      await loadVersions();
+      onChanged();
+    } catch (error: any) {
+      alert('Failed to upload new version: ' + (error.message || error || 'Unknown error'));
+    } finally {
+      EventsOff('upload:progress');
+      setProgress(null);
+      setBusy(false);
+    }
+  };
+
+  const handleRestore = async (version: number) => {
+    if (!confirm(`Restore version ${version}? It will be added as a new version.`)) return;
+
+    setBusy(true);
+    try {
+      // @ts-ignore
+      const { RestoreResourceVersion } = await import('../../../wailsjs/go/main/App');
+      await RestoreResourceVersion(resource.id, version);
+      await loadVersions();
+      onChanged();
+    } catch (error: any) {
+      alert('Failed to restore version: ' + (error.message || error || 'Unknown error'));
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const formatFileSize = (bytes: number) => {
+    if (!bytes) return '0 Bytes';
+    const k = 1024;
+    const sizes = ['Bytes', 'KB', 'MB', 'GB'];
+    const i = Math.floor(Math.log(bytes) / Math.log(k));
+    return Math.round(bytes / Math.pow(k, i) * 100) / 100 + ' ' + sizes[i];
+  };
+
+  return (
+    <Modal isOpen={true} onClose={onClose} title={`Versions of ${resource.name}`} size="lg">
+      <div className="space-y-4">
+        {canEdit && (
+          <div className="flex items-end gap-3">
+            <div className="flex-1">
+              <Input
+                label="What changed?"
+                value={changeNote}
+                onChange={(e) => setChangeNote(e.target.value)}
+                placeholder="e.g. Fixed typo on page 3"
+                disabled={busy}
+              />
+            </div>
+            <Button onClick={handleUploadVersion} disabled={busy}>
+              {progress !== null ? (
+                <>
+                  <Loader className="w-4 h-4 animate-spin" />
+                  {progress}%
+                </>
+              ) : (
+                <>
+                  <Upload className="w-4 h-4" />
+                  Upload New Version
+                </>
+              )}
+            </Button>
+          </div>
+        )}
+
+        {loading ? (
+          <div className="flex justify-center py-8">
+            <Loader className="w-6 h-6 animate-spin text-primary" />
+          </div>
+        ) : (
+          <div className="space-y-2">
+            {versions.map((v) => (
+              <div
+                key={v.id || v.version}
+                className="flex items-center justify-between gap-3 p-3 rounded-button bg-light-bg dark:bg-dark-bg"
+              >
+                <div className="flex items-start gap-3 min-w-0">
+                  <History className="w-4 h-4 mt-1 text-light-text-secondary dark:text-dark-text-secondary" />
+                  <div className="min-w-0">
+                    <div className="flex items-center gap-2">
+                      <span className="font-medium text-light-text-primary dark:text-dark-text-primary">
+                        Version {v.version}
+                      </span>
+                      {v.version === currentVersion && <Badge variant="success" size="sm">Current</Badge>}
+                    </div>
+                    {v.change_note && (
+                      <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary truncate">
+                        {v.change_note}
+                      </p>
+                    )}
+                    <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary">
+                      {formatFileSize(v.file_size)} • {new Date(v.created_at).toLocaleString()}
+                    </p>
+                  </div>
+                </div>
+                {canEdit && v.version !== currentVersion && (
+                  <Button size="sm" variant="secondary" onClick={() => handleRestore(v.version)} disabled={busy}>
+                    <RotateCcw className="w-4 h-4" />
+                    Restore
+                  </Button>
+                )}
+              </div>
+            ))}
+          </div>
+        )}
+      </div>
+    </Modal>
+  );
+}
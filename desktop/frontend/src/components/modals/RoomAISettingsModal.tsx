@@ -0,0 +1,344 @@
+import { useState, useEffect } from 'react';
+import { Loader, Plus, Save, Trash2 } from 'lucide-react';
+import Modal from '../ui/Modal';
+import Button from '../ui/Button';
+import Input from '../ui/Input';
+
+export interface RoomAISettings {
+  tutor_mode: 'optional' | 'always' | 'assignments';
+  tutor_assignment_ids?: string[];
+  tutor_reveal_after?: number;
+  training_uploader_types?: string[]; // Empty for teacher and student resources
+  assistant_name?: string;
+  persona?: string;
+  language?: string; // Empty to answer in the student's language
+  scope?: 'syllabus' | 'general';
+  answer_style?: 'balanced' | 'brief' | 'detailed' | 'socratic';
+  solve_assignments?: boolean;
+  blackout_windows?: BlackoutWindow[];
+}
+
+// BlackoutWindow is a time, such as an exam, when the AI Coach doesn't answer students
+export interface BlackoutWindow {
+  start: string;
+  end: string;
+  reason?: string;
+}
+
+interface RoomAssignment {
+  id: string;
+  title: string;
+  due_date: string;
+}
+
+interface RoomAISettingsModalProps {
+  roomId: string;
+  onClose: () => void;
+}
+
+const selectClass = 'w-full px-4 py-3 bg-light-bg dark:bg-dark-bg border border-light-text-secondary/20 dark:border-dark-border rounded-button text-light-text-primary dark:text-dark-text-primary focus:outline-none focus:ring-2 focus:ring-primary/50';
+const labelClass = 'block text-sm font-medium text-light-text-primary dark:text-dark-text-primary mb-2';
+
+// toLocalInput formats a time for a datetime-local input
+const toLocalInput = (iso: string) => {
+  if (!iso) return '';
+  const date = new Date(iso);
+  return new Date(date.getTime() - date.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+};
+
+// fromLocalInput reads a datetime-local input back as an ISO time
+const fromLocalInput = (value: string) => (value ? new Date(value).toISOString() : '');
+
+// windowProblem says what stops a blackout window from being saved, or returns ''
+const windowProblem = (window: BlackoutWindow) => {
+  if (!window.start || !window.end) return 'Choose when the pause starts and ends';
+  if (new Date(window.end) <= new Date(window.start)) return 'The pause must end after it starts';
+  return '';
+};
+
+// RoomAISettingsModal lets a room's teacher choose how its AI Coach answers students
+export default function RoomAISettingsModal({ roomId, onClose }: RoomAISettingsModalProps) {
+  const [settings, setSettings] = useState<RoomAISettings>({ tutor_mode: 'optional', tutor_reveal_after: 3 });
+  const [assignments, setAssignments] = useState<RoomAssignment[]>([]);
+  const [loading, setLoading] = useState(true);
+  const [saving, setSaving] = useState(false);
+  const [checkWindows, setCheckWindows] = useState(false);
+
+  useEffect(() => {
+    loadSettings();
+  }, [roomId]);
+
+  const loadSettings = async () => {
+    setLoading(true);
+    try {
+      // @ts-ignore
+      const { GetRoomAISettings, GetAssignments } = await import('../../../wailsjs/go/main/App');
+      const [current, list] = await Promise.all([GetRoomAISettings(roomId), GetAssignments(roomId)]);
+      if (current) setSettings(current);
+      setAssignments(Array.isArray(list) ? list : []);
+    } catch (error) {
+      console.error('Failed to load AI settings:', error);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const toggleAssignment = (id: string) => {
+    const ids = settings.tutor_assignment_ids || [];
+    setSettings({
+      ...settings,
+      tutor_assignment_ids: ids.includes(id) ? ids.filter((a) => a !== id) : [...ids, id],
+    });
+  };
+
+  // Training with no uploader types listed uses both
+  const trainsWith = (uploaderType: string) =>
+    !settings.training_uploader_types?.length || settings.training_uploader_types.includes(uploaderType);
+
+  const toggleUploaderType = (uploaderType: string) => {
+    const types = ['teacher', 'student'].filter((t) => (t === uploaderType ? !trainsWith(t) : trainsWith(t)));
+    if (types.length === 0) return; // The AI needs something to train with
+    setSettings({ ...settings, training_uploader_types: types.length === 2 ? [] : types });
+  };
+
+  const windows = settings.blackout_windows || [];
+
+  const updateWindow = (index: number, change: Partial<BlackoutWindow>) => {
+    setSettings({ ...settings, blackout_windows: windows.map((w, i) => (i === index ? { ...w, ...change } : w)) });
+  };
+
+  const handleSave = async () => {
+    // Windows left completely blank are dropped; the rest must be complete
+    const filled = windows.filter((w) => w.start || w.end || w.reason?.trim());
+    if (filled.length !== windows.length) {
+      setSettings({ ...settings, blackout_windows: filled });
+    }
+    if (filled.some((w) => windowProblem(w))) {
+      setCheckWindows(true);
+      return;
+    }
+
+    setSaving(true);
+    try {
+      // @ts-ignore
+      const { UpdateRoomAISettings } = await import('../../../wailsjs/go/main/App');
+      await UpdateRoomAISettings(roomId, { ...settings, blackout_windows: filled });
+      onClose();
+    } catch (error: any) {
+      alert('Failed to save AI settings: ' + (error.message || error || 'Unknown error'));
+    } finally {
+      setSaving(false);
+    }
+  };
+
+  return (
+    <Modal isOpen={true} onClose={onClose} title="AI Coach Settings" size="lg">
+      {loading ? (
+        <div className="flex justify-center py-8">
+          <Loader className="w-6 h-6 animate-spin text-primary" />
+        </div>
+      ) : (
+        <div className="space-y-6">
+          <div className="grid grid-cols-2 gap-4">
+            <Input
+              label="Assistant name"
+              value={settings.assistant_name || ''}
+              onChange={(e) => setSettings({ ...settings, assistant_name: e.target.value })}
+              placeholder="AI Coach"
+              maxLength={40}
+            />
+            <Input
+              label="Answer in"
+              value={settings.language || ''}
+              onChange={(e) => setSettings({ ...settings, language: e.target.value })}
+              placeholder="The student's language"
+              maxLength={30}
+            />
+          </div>
+
+          <Input
+            label="Persona"
+            value={settings.persona || ''}
+            onChange={(e) => setSettings({ ...settings, persona: e.target.value })}
+            placeholder="e.g., a patient lab partner who uses everyday examples"
+            maxLength={300}
+          />
+
+          <div className="grid grid-cols-2 gap-4">
+            <div>
+              <label className={labelClass}>Scope</label>
+              <select
+                value={settings.scope || 'syllabus'}
+                onChange={(e) => setSettings({ ...settings, scope: e.target.value as RoomAISettings['scope'] })}
+                className={selectClass}
+              >
+                <option value="syllabus">Only this course's content</option>
+                <option value="general">General knowledge too</option>
+              </select>
+            </div>
+            <div>
+              <label className={labelClass}>Answer style</label>
+              <select
+                value={settings.answer_style || 'balanced'}
+                onChange={(e) => setSettings({ ...settings, answer_style: e.target.value as RoomAISettings['answer_style'] })}
+                className={selectClass}
+              >
+                <option value="balanced">Balanced</option>
+                <option value="brief">Brief</option>
+                <option value="detailed">Detailed</option>
+                <option value="socratic">Socratic questions</option>
+              </select>
+            </div>
+          </div>
+
+          <label className="flex items-center gap-2 text-sm text-light-text-primary dark:text-dark-text-primary">
+            <input
+              type="checkbox"
+              checked={settings.solve_assignments !== false}
+              onChange={(e) => setSettings({ ...settings, solve_assignments: e.target.checked })}
+              className="w-4 h-4 rounded"
+            />
+            Allow answers to assignment questions
+          </label>
+
+          <div>
+            <label className={labelClass}>Tutoring</label>
+            <select
+              value={settings.tutor_mode}
+              onChange={(e) => setSettings({ ...settings, tutor_mode: e.target.value as RoomAISettings['tutor_mode'] })}
+              className={selectClass}
+            >
+              <option value="optional">Students choose whether to be tutored</option>
+              <option value="always">Always give hints instead of answers</option>
+              <option value="assignments">Give hints instead of answers while assignments are open</option>
+            </select>
+          </div>
+
+          {settings.tutor_mode === 'assignments' && (
+            <div>
+              <label className={labelClass}>Assignments</label>
+              {assignments.length === 0 ? (
+                <p className="text-sm text-light-text-secondary dark:text-dark-text-secondary">
+                  This room has no assignments yet.
+                </p>
+              ) : (
+                <div className="space-y-2 max-h-48 overflow-y-auto">
+                  {assignments.map((assignment) => (
+                    <label key={assignment.id} className="flex items-center gap-2 text-sm text-light-text-primary dark:text-dark-text-primary">
+                      <input
+                        type="checkbox"
+                        checked={(settings.tutor_assignment_ids || []).includes(assignment.id)}
+                        onChange={() => toggleAssignment(assignment.id)}
+                        className="w-4 h-4 rounded"
+                      />
+                      {assignment.title}
+                      <span className="text-xs text-light-text-secondary dark:text-dark-text-secondary">
+                        due {new Date(assignment.due_date).toLocaleDateString()}
+                      </span>
+                    </label>
+                  ))}
+                </div>
+              )}
+            </div>
+          )}
+
+          {settings.tutor_mode !== 'optional' && (
+            <Input
+              type="number"
+              label="Attempts before the solution is shown"
+              min={1}
+              max={10}
+              value={settings.tutor_reveal_after || 3}
+              onChange={(e) => setSettings({ ...settings, tutor_reveal_after: parseInt(e.target.value) || 0 })}
+            />
+          )}
+
+          <div>
+            <label className={labelClass}>Train the AI Coach with</label>
+            <div className="flex gap-6">
+              {[['teacher', 'Teacher resources'], ['student', 'Student uploads']].map(([uploaderType, label]) => (
+                <label key={uploaderType} className="flex items-center gap-2 text-sm text-light-text-primary dark:text-dark-text-primary">
+                  <input
+                    type="checkbox"
+                    checked={trainsWith(uploaderType)}
+                    onChange={() => toggleUploaderType(uploaderType)}
+                    className="w-4 h-4 rounded"
+                  />
+                  {label}
+                </label>
+              ))}
+            </div>
+            <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary mt-2">
+              Resources that try to give the AI instructions are always left out. Retrain to apply changes.
+            </p>
+          </div>
+
+          <div>
+            <label className={labelClass}>Pause the AI Coach for students</label>
+            <div className="space-y-2">
+              {windows.map((window, index) => {
+                const problem = checkWindows ? windowProblem(window) : '';
+                return (
+                  <div key={index}>
+                    <div className="flex items-center gap-2">
+                      <Input
+                        type="datetime-local"
+                        value={toLocalInput(window.start)}
+                        onChange={(e) => updateWindow(index, { start: fromLocalInput(e.target.value) })}
+                        className={problem && !window.start ? 'border-error' : ''}
+                      />
+                      <Input
+                        type="datetime-local"
+                        value={toLocalInput(window.end)}
+                        onChange={(e) => updateWindow(index, { end: fromLocalInput(e.target.value) })}
+                        className={problem ? 'border-error' : ''}
+                      />
+                      <Input
+                        value={window.reason || ''}
+                        onChange={(e) => updateWindow(index, { reason: e.target.value })}
+                        placeholder="e.g., Midterm"
+                        maxLength={120}
+                      />
+                      <Button
+                        variant="ghost"
+                        onClick={() => setSettings({ ...settings, blackout_windows: windows.filter((_, i) => i !== index) })}
+                      >
+                        <Trash2 className="w-4 h-4" />
+                      </Button>
+                    </div>
+                    {problem && (
+                      <p className="mt-1 text-sm text-error">
+                        Window {index + 1}: {problem}
+                      </p>
+                    )}
+                  </div>
+                );
+              })}
+            </div>
+            <Button
+              variant="ghost"
+              onClick={() => setSettings({ ...settings, blackout_windows: [...windows, { start: '', end: '' }] })}
+              disabled={windows.length >= 20}
+            >
+              <Plus className="w-4 h-4" />
+              Add blackout window
+            </Button>
+            <p className="text-xs text-light-text-secondary dark:text-dark-text-secondary mt-2">
+              Students can't ask the AI Coach during these times, such as exams. You still can.
+            </p>
+          </div>
+
+          <div className="flex justify-end gap-3">
+            <Button variant="ghost" onClick={onClose} disabled={saving}>
+              Cancel
+            </Button>
+            <Button onClick={handleSave} disabled={saving}>
+              {saving ? <Loader className="w-4 h-4 animate-spin" /> : <Save className="w-4 h-4" />}
+              Save
+            </Button>
+          </div>
+        </div>
+      )}
+    </Modal>
+  );
+}
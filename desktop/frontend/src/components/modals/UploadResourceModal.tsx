@@ -4,6 +4,7 @@ import Modal from '../ui/Modal';
 import Button from '../ui/Button';
 import Input from '../ui/Input';
 import Card from '../ui/Card';
+import { EventsOn, EventsOff } from '../../../wailsjs/runtime/runtime';
 
 interface SyllabusItem {
   title: string;
@@ -19,22 +20,12 @@ interface Syllabus {
 interface UploadResourceModalProps {
   roomId: string;
   syllabus: Syllabus | null;
+  folderId?: string; // Folder the resource is filed in; empty for the top level
   onClose: () => void;
   onSuccess: () => void;
 }
 
-interface Resource {
-  name: string;
-  description: string;
-  category: string;
-  subject?: string;
-  subjects?: string[];
-  file_url: string;
-  file_type: string;
-  file_size: number;
-}
-
-export default function UploadResourceModal({ roomId, syllabus, onClose, onSuccess }: UploadResourceModalProps) {
+export default function UploadResourceModal({ roomId, syllabus, folderId = '', onClose, onSuccess }: UploadResourceModalProps) {
   const [loading, setLoading] = useState(false);
   const [formData, setFormData] = useState({
     name: '',
@@ -42,9 +33,11 @@ export default function UploadResourceModal({ roomId, syllabus, onClose, onSucce
     category: 'notes',
     subject: '',
     subjects: [] as string[],
+    tags: '',
   });
   const [selectedFile, setSelectedFile] = useState<File | null>(null);
   const [selectedFilePath, setSelectedFilePath] = useState<string | null>(null);
+  const [progress, setProgress] = useState(0);
 
   const categories = [
     { id: 'video', label: 'Video', icon: Video },
@@ -122,51 +115,37 @@ export default function UploadResourceModal({ roomId, syllabus, onClose, onSucce
     }
 
     setLoading(true);
+    setProgress(0);
+    const onProgress = (p: { sent: number; total: number }) => {
+      setProgress(p.total > 0 ? Math.round((p.sent / p.total) * 100) : 0);
+    };
+    EventsOn('upload:progress', onProgress);
     try {
-          // @ts-ignore
-          const { UploadFile, CreateResource } = await import('../../../wailsjs/go/main/App');
-      
-      // First upload the file
-      const uploadResult: any = await UploadFile(roomId, selectedFilePath);
-      
-      if (!uploadResult?.file_url) {
-        throw new Error('File upload failed');
-      }
+      // @ts-ignore
+      const { UploadResourceResumable } = await import('../../../wailsjs/go/main/App');
 
-      // Get file info from path
+      // The file is sent in resumable chunks; the server creates the resource
+      // once the upload is stored, so an interrupted upload can be resumed later
       const fileName = selectedFilePath.split('/').pop() || selectedFilePath.split('\\').pop() || 'file';
-      const fileExtension = fileName.split('.').pop()?.toLowerCase() || '';
-      const mimeTypes: { [key: string]: string } = {
-        'pdf': 'application/pdf',
-        'doc': 'application/msword',
-        'docx': 'application/vnd.openxmlformats-officedocument.wordprocessingml.document',
-        'txt': 'text/plain',
-        'mp4': 'video/mp4',
-        'mp3': 'audio/mpeg',
-        'jpg': 'image/jpeg',
-        'jpeg': 'image/jpeg',
-        'png': 'image/png',
-      };
-      const fileType = mimeTypes[fileExtension] || 'application/octet-stream';
-
-      // Then create the resource
-      const resourceData: Resource = {
+      const status: any = await UploadResourceResumable(roomId, selectedFilePath, {
         name: formData.name || fileName,
         description: formData.description,
         category: formData.category,
         subject: formData.subject || '', // Legacy support
         subjects: formData.subjects || [], // New: multiple subjects
-        file_url: uploadResult.file_url,
-        file_type: fileType,
-        file_size: uploadResult.file_size || 0,
-      };
-      await CreateResource(roomId, resourceData);
+        tags: formData.tags.split(',').map(t => t.trim()).filter(Boolean),
+        folder_id: folderId,
+      });
 
-      alert('Resource uploaded successfully!');
+      alert(status?.scan_status === 'pending'
+        ? 'Resource uploaded! It will be available once the malware scan finishes.'
+        : 'Resource uploaded successfully!');
       onSuccess();
     } catch (error: any) {
-      alert('Failed to upload resource: ' + (error.message || 'Unknown error'));
+      alert('Failed to upload resource: ' + (error.message || error || 'Unknown error') +
+        '. You can resume it from the resources list.');
     } finally {
+      EventsOff('upload:progress');
       setLoading(false);
     }
   };
@@ -230,6 +209,19 @@ export default function UploadResourceModal({ roomId, syllabus, onClose, onSucce
           />
         </div>
 
+        <div>
+          <label className="block text-sm font-medium text-light-text-primary dark:text-dark-text-primary mb-2">
+            Tags
+          </label>
+          <input
+            type="text"
+            value={formData.tags}
+            onChange={(e) => setFormData({ ...formData, tags: e.target.value })}
+            placeholder="e.g. exam prep, week 3"
+            className="w-full px-4 py-2 bg-light-bg dark:bg-dark-bg border border-light-text-secondary/20 dark:border-dark-border rounded-button focus:outline-none focus:border-primary transition-colors text-light-text-primary dark:text-dark-text-primary"
+          />
+        </div>
+
         <div>
           <label className="block text-sm font-medium text-light-text-primary dark:text-dark-text-primary mb-2">
             Category *
@@ -308,7 +300,7 @@ export default function UploadResourceModal({ roomId, syllabus, onClose, onSucce
             {loading ? (
               <>
                 <Loader className="w-5 h-5 animate-spin" />
-                Uploading...
+                Uploading... {progress}%
               </>
             ) : (
               <>
@@ -0,0 +1,85 @@
+import { useEffect, useRef, useState } from 'react';
+import { Loader } from 'lucide-react';
+import Modal from '../ui/Modal';
+
+interface VideoPlayerModalProps {
+  resource: { id: string; name: string };
+  onClose: () => void;
+}
+
+// How often watch progress is reported while playing, in milliseconds
+const PROGRESS_INTERVAL = 15000;
+
+export default function VideoPlayerModal({ resource, onClose }: VideoPlayerModalProps) {
+  const videoRef = useRef<HTMLVideoElement>(null);
+  // Latest playback position, kept outside the element so it can still be sent on close
+  const progress = useRef({ position: 0, duration: 0, reported: 0 });
+  const [url, setUrl] = useState('');
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    const load = async () => {
+      try {
+        // @ts-ignore
+        const { GetResourceViewURL } = await import('../../../wailsjs/go/main/App');
+        setUrl(await GetResourceViewURL(resource.id));
+      } catch (err: any) {
+        setError(err?.message || String(err) || 'Failed to load video');
+      }
+    };
+    load();
+  }, [resource.id]);
+
+  const trackProgress = () => {
+    const video = videoRef.current;
+    if (video && Number.isFinite(video.duration)) {
+      progress.current.position = video.currentTime;
+      progress.current.duration = video.duration;
+    }
+  };
+
+  const reportProgress = async () => {
+    const { position, duration, reported } = progress.current;
+    if (!position || position === reported) return;
+    progress.current.reported = position;
+    try {
+      // @ts-ignore
+      const { RecordResourceProgress } = await import('../../../wailsjs/go/main/App');
+      await RecordResourceProgress(resource.id, position, duration);
+    } catch (err) {
+      console.error('Failed to record progress:', err);
+    }
+  };
+
+  // Report periodically while the position changes, and once more when closing
+  useEffect(() => {
+    const timer = setInterval(reportProgress, PROGRESS_INTERVAL);
+    return () => {
+      clearInterval(timer);
+      reportProgress();
+    };
+  }, [resource.id]);
+
+  return (
+    <Modal isOpen={true} onClose={onClose} title={resource.name} size="lg">
+      {error ? (
+        <p className="text-center py-12 text-error">{error}</p>
+      ) : url ? (
+        <video
+          ref={videoRef}
+          src={url}
+          controls
+          autoPlay
+          className="w-full rounded-lg bg-black"
+          onTimeUpdate={trackProgress}
+          onPause={reportProgress}
+          onEnded={reportProgress}
+        />
+      ) : (
+        <div className="flex justify-center py-12">
+          <Loader className="w-8 h-8 animate-spin text-primary" />
+        </div>
+      )}
+    </Modal>
+  );
+}
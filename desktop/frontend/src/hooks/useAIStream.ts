@@ -0,0 +1,43 @@
+import { useEffect, useRef } from 'react';
+// @ts-ignore
+import { EventsOn } from '../../wailsjs/runtime/runtime';
+// @ts-ignore
+import { CancelAIStream } from '../../wailsjs/go/main/App';
+
+type StreamChunk = { id: string; text: string };
+
+// useAIStream runs streaming AI calls: the backend sends the text as
+// "ai:stream" events tagged with the stream's ID while the call is running,
+// and the call resolves with the final result. A running stream is cancelled
+// when the component unmounts.
+export function useAIStream() {
+  const current = useRef('');
+
+  useEffect(() => () => cancel(), []);
+
+  const run = async <T,>(start: (streamID: string) => Promise<T>, onText: (text: string) => void): Promise<T> => {
+    const streamID = `${Date.now()}-${Math.random().toString(36).slice(2)}`;
+    current.current = streamID;
+    let text = '';
+    const off = EventsOn('ai:stream', (chunk: StreamChunk) => {
+      if (chunk.id !== streamID) return;
+      text += chunk.text;
+      onText(text);
+    });
+    try {
+      return await start(streamID);
+    } finally {
+      off();
+      if (current.current === streamID) current.current = '';
+    }
+  };
+
+  const cancel = () => {
+    if (current.current) {
+      CancelAIStream(current.current);
+      current.current = '';
+    }
+  };
+
+  return { run, cancel };
+}
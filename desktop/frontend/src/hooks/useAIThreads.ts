@@ -0,0 +1,97 @@
+import { useEffect, useState } from 'react';
+// @ts-ignore
+import { ListAIThreads, CreateAIThread, GetAIThread, RenameAIThread, DeleteAIThread } from '../../wailsjs/go/main/App';
+
+export interface TutorState {
+  question: string;
+  hint_level: number;
+  attempts: number;
+  reveal_after: number;
+  revealed: boolean;
+  forced?: boolean; // Required by the room's teacher
+}
+
+export interface AIThread {
+  id: string;
+  title: string;
+  room_id?: string;
+  mode?: '' | 'tutor';
+  tutor?: TutorState;
+  message_count: number;
+  updated_at: string;
+}
+
+export interface AIThreadMessage {
+  id: string;
+  role: 'user' | 'assistant';
+  content: string;
+  citations?: any[];
+  created_at: string;
+}
+
+export interface AIThreadReply {
+  thread: AIThread;
+  message: AIThreadMessage;
+}
+
+// useAIThreads keeps the stored conversations with the general tutor (no
+// roomId) or a room's AI, and which one is open; nothing is loaded while
+// roomId is null. A new conversation is only created on the server when its
+// first message is sent, in the mode chosen with startNew.
+export function useAIThreads(roomId: string | null = '') {
+  const [threads, setThreads] = useState<AIThread[]>([]);
+  const [current, setCurrent] = useState<AIThread | null>(null);
+  const [newMode, setNewMode] = useState<'' | 'tutor'>('');
+
+  useEffect(() => {
+    setCurrent(null);
+    setThreads([]);
+    if (roomId === null) return;
+    ListAIThreads(roomId)
+      .then((list: AIThread[]) => setThreads(list || []))
+      .catch((error: any) => console.error('Failed to load conversations:', error));
+  }, [roomId]);
+
+  // open loads a thread and returns its messages
+  const open = async (threadId: string): Promise<AIThreadMessage[]> => {
+    const detail = await GetAIThread(threadId);
+    setCurrent(detail);
+    return detail.messages || [];
+  };
+
+  const startNew = (mode: '' | 'tutor' = '') => {
+    setCurrent(null);
+    setNewMode(mode);
+  };
+
+  // ensure returns the open thread, creating it for the first message
+  const ensure = async (): Promise<AIThread> => {
+    if (current) return current;
+    if (roomId === null) throw new Error('No room selected');
+    const thread: AIThread = await CreateAIThread(roomId, '', newMode);
+    setCurrent(thread);
+    return thread;
+  };
+
+  // replied records a finished exchange, moving the thread to the top
+  const replied = (reply: AIThreadReply) => {
+    setCurrent(reply.thread);
+    setThreads((prev) => [reply.thread, ...prev.filter((t) => t.id !== reply.thread.id)]);
+  };
+
+  const rename = async (title: string) => {
+    if (!current) return;
+    const thread: AIThread = await RenameAIThread(current.id, title);
+    setCurrent(thread);
+    setThreads((prev) => prev.map((t) => (t.id === thread.id ? thread : t)));
+  };
+
+  const remove = async () => {
+    if (!current) return;
+    await DeleteAIThread(current.id);
+    setThreads((prev) => prev.filter((t) => t.id !== current.id));
+    setCurrent(null);
+  };
+
+  return { threads, current, newMode, open, startNew, ensure, replied, rename, remove };
+}
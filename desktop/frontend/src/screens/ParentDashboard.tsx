@@ -6,6 +6,7 @@ import ProgressBar from '../components/ui/ProgressBar';
 import Avatar from '../components/ui/Avatar';
 import Button from '../components/ui/Button';
 import AddChildModal from '../components/modals/AddChildModal';
+import AISafetyFlags from '../components/analytics/AISafetyFlags';
 
 export default function ParentDashboard() {
   const [loading, setLoading] = useState(true);
@@ -337,6 +338,10 @@ export default function ParentDashboard() {
                   </Card>
                 </div>
               </div>
+
+              <div className="mt-6">
+                <AISafetyFlags studentID={selectedChild.id} />
+              </div>
             </>
           )}
         </>
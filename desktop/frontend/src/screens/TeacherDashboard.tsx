@@ -6,6 +6,9 @@ import ProgressBar from '../components/ui/ProgressBar';
 import Avatar from '../components/ui/Avatar';
 import Button from '../components/ui/Button';
 import GameAnalyticsDashboard from '../components/analytics/GameAnalyticsDashboard';
+import ResourceEngagementDashboard from '../components/analytics/ResourceEngagementDashboard';
+import AIUsageDashboard from '../components/analytics/AIUsageDashboard';
+import AISafetyFlags from '../components/analytics/AISafetyFlags';
 import { useApp } from '../contexts/AppContext';
 
 export default function TeacherDashboard() {
@@ -369,6 +372,15 @@ export default function TeacherDashboard() {
             )}
           </div>
           <GameAnalyticsDashboard roomID={selectedRoomForAnalytics || classrooms[0]?.id} />
+          <div className="mt-8">
+            <ResourceEngagementDashboard roomID={selectedRoomForAnalytics || classrooms[0]?.id} />
+          </div>
+          <div className="mt-8">
+            <AIUsageDashboard roomID={selectedRoomForAnalytics || classrooms[0]?.id} />
+          </div>
+          <div className="mt-8">
+            <AISafetyFlags roomID={selectedRoomForAnalytics || classrooms[0]?.id} />
+          </div>
         </div>
       )}
     </div>
@@ -6,14 +6,24 @@ export function AcceptFriendRequest(arg1:string):Promise<void>;
 
 export function AddCourseToStudyPlan(arg1:string,arg2:Record<string, any>):Promise<any>;
 
-export function AnswerQuestion(arg1:string,arg2:string,arg3:string):Promise<any>;
+export function AnswerQuestion(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<any>;
+
+export function CancelAIStream(arg1:string):Promise<void>;
+
+export function CancelUpload(arg1:string):Promise<void>;
 
 export function Chat(arg1:string,arg2:string):Promise<any>;
 
 export function ChatWithRoomAI(arg1:string,arg2:string):Promise<Record<string, any>>;
 
+export function ClearRoomAICache(arg1:string):Promise<void>;
+
 export function CompleteAssessment(arg1:Record<string, any>):Promise<any>;
 
+export function CreateAIPromptVersion(arg1:string,arg2:string,arg3:string):Promise<Record<string, any>>;
+
+export function CreateAIThread(arg1:string,arg2:string,arg3:string):Promise<Record<string, any>>;
+
 export function CreateAssignment(arg1:string,arg2:string,arg3:string,arg4:any,arg5:number,arg6:string,arg7:any):Promise<any>;
 
 export function CreateChild(arg1:string,arg2:string,arg3:string,arg4:number):Promise<any>;
@@ -24,6 +34,8 @@ export function CreateMilestone(arg1:Record<string, any>):Promise<any>;
 
 export function CreateResource(arg1:string,arg2:Record<string, any>):Promise<any>;
 
+export function CreateResourceFolder(arg1:string,arg2:string,arg3:string):Promise<any>;
+
 export function CreateRoom(arg1:string,arg2:string,arg3:string,arg4:any,arg5:boolean,arg6:number):Promise<any>;
 
 export function CreateScheduleBlock(arg1:string,arg2:Record<string, any>):Promise<any>;
@@ -32,12 +44,16 @@ export function CreateSmartStudyPlan(arg1:Record<string, any>):Promise<any>;
 
 export function CreateStudyPlan(arg1:Record<string, any>):Promise<any>;
 
+export function DeleteAIThread(arg1:string):Promise<void>;
+
 export function DeleteAssignment(arg1:string):Promise<void>;
 
 export function DeleteGoal(arg1:string):Promise<void>;
 
 export function DeleteResource(arg1:string):Promise<void>;
 
+export function DeleteResourceFolder(arg1:string,arg2:string):Promise<void>;
+
 export function DeleteScheduleBlock(arg1:string,arg2:string):Promise<void>;
 
 export function DownloadGameBundle(arg1:string):Promise<string>;
@@ -60,10 +76,22 @@ export function GenerateSyllabusFromFile(arg1:string,arg2:string,arg3:string):Pr
 
 export function GenerateSyllabusFromTopics(arg1:Array<string>,arg2:string,arg3:string):Promise<any>;
 
+export function GetAIPrompt(arg1:string):Promise<Record<string, any>>;
+
+export function GetAIPrompts():Promise<Array<any>>;
+
+export function GetAISafetyFlags(arg1:string,arg2:boolean):Promise<Array<any>>;
+
+export function GetAIThread(arg1:string):Promise<Record<string, any>>;
+
+export function GetAIUsage():Promise<Record<string, any>>;
+
 export function GetActiveChallenges():Promise<Array<backend.Challenge>>;
 
 export function GetActiveStudySession():Promise<any>;
 
+export function GetAdminAIUsage(arg1:string,arg2:string):Promise<Record<string, any>>;
+
 export function GetAssignment(arg1:string):Promise<any>;
 
 export function GetAssignments(arg1:string):Promise<any>;
@@ -90,6 +118,8 @@ export function GetGoals(arg1:any,arg2:any):Promise<any>;
 
 export function GetIncomingFriendRequests():Promise<any>;
 
+export function GetJob(arg1:string):Promise<any>;
+
 export function GetLeaderboard(arg1:string,arg2:number):Promise<any>;
 
 export function GetMessages(arg1:string):Promise<any>;
@@ -106,16 +136,38 @@ export function GetMyRooms(arg1:string):Promise<any>;
 
 export function GetMyStudyPlans():Promise<any>;
 
+export function GetNotifications(arg1:boolean):Promise<any>;
+
+export function GetPendingUploads():Promise<any>;
+
 export function GetReport(arg1:string):Promise<any>;
 
 export function GetReports():Promise<any>;
 
+export function GetResourceDownloadURL(arg1:string):Promise<string>;
+
+export function GetResourceEngagement(arg1:string,arg2:string,arg3:string):Promise<any>;
+
+export function GetResourceEngagementDetail(arg1:string,arg2:string,arg3:string,arg4:string):Promise<any>;
+
+export function GetResourceFolders(arg1:string):Promise<any>;
+
+export function GetResourceTags(arg1:string):Promise<any>;
+
+export function GetResourceVersions(arg1:string):Promise<any>;
+
+export function GetResourceViewURL(arg1:string):Promise<string>;
+
 export function GetResources(arg1:string,arg2:string,arg3:string):Promise<any>;
 
 export function GetRoom(arg1:string):Promise<any>;
 
+export function GetRoomAISettings(arg1:string):Promise<Record<string, any>>;
+
 export function GetRoomAIStatus(arg1:string):Promise<Record<string, any>>;
 
+export function GetRoomAIUsage(arg1:string,arg2:string,arg3:string):Promise<Record<string, any>>;
+
 export function GetRoomAnalytics(arg1:string):Promise<any>;
 
 export function GetRoomGames(arg1:string):Promise<any>;
@@ -126,6 +178,8 @@ export function GetRooms(arg1:string):Promise<any>;
 
 export function GetScheduleBlocks(arg1:string):Promise<any>;
 
+export function GetStudentEngagement(arg1:string,arg2:string,arg3:string,arg4:string):Promise<any>;
+
 export function GetStudyPlanCourses(arg1:string):Promise<any>;
 
 export function GetTodayGoals():Promise<any>;
@@ -142,24 +196,46 @@ export function IsAuthenticated():Promise<boolean>;
 
 export function JoinRoom(arg1:string):Promise<void>;
 
+export function ListAIThreads(arg1:string):Promise<Array<any>>;
+
 export function LogStudySession(arg1:string,arg2:number):Promise<void>;
 
 export function Login(arg1:string,arg2:string):Promise<backend.AuthResponse>;
 
 export function Logout():Promise<void>;
 
+export function MarkAllNotificationsRead():Promise<void>;
+
+export function MarkNotificationRead(arg1:string):Promise<void>;
+
 export function OpenFileDialog():Promise<string>;
 
 export function PauseStudySession():Promise<void>;
 
 export function PlayGame(arg1:string,arg2:Array<string>,arg3:number):Promise<any>;
 
+export function RecordResourceProgress(arg1:string,arg2:number,arg3:number):Promise<void>;
+
 export function RejectFriendRequest(arg1:string):Promise<void>;
 
+export function ReleaseAIPrompt(arg1:string,arg2:number,arg3:number,arg4:number):Promise<Record<string, any>>;
+
+export function RenameAIThread(arg1:string,arg2:string):Promise<Record<string, any>>;
+
+export function RestoreResourceVersion(arg1:string,arg2:number):Promise<any>;
+
 export function ResumeStudySession():Promise<void>;
 
+export function ResumeUpload(arg1:string):Promise<any>;
+
+export function ReviewAISafetyFlag(arg1:string):Promise<Record<string, any>>;
+
+export function RollbackAIPrompt(arg1:string):Promise<Record<string, any>>;
+
 export function SaveTextToDownloads(arg1:string,arg2:string):Promise<string>;
 
+export function SearchResources(arg1:string,arg2:Record<string, any>):Promise<any>;
+
 export function SendFriendRequest(arg1:string):Promise<void>;
 
 export function SendMessage(arg1:string,arg2:string):Promise<any>;
@@ -174,9 +250,17 @@ export function StartStudySession(arg1:string,arg2:string):Promise<any>;
 
 export function StopStudySession(arg1:string,arg2:number):Promise<any>;
 
+export function StreamAIThreadMessage(arg1:string,arg2:string,arg3:string):Promise<Record<string, any>>;
+
+export function StreamChat(arg1:string,arg2:string,arg3:string):Promise<Record<string, any>>;
+
+export function StreamChatWithRoomAI(arg1:string,arg2:string,arg3:string):Promise<Record<string, any>>;
+
+export function StreamExplainTopic(arg1:string,arg2:string,arg3:string,arg4:string):Promise<Record<string, any>>;
+
 export function ToggleGoalComplete(arg1:string):Promise<void>;
 
-export function TrainRoomAI(arg1:string,arg2:Array<string>):Promise<void>;
+export function TrainRoomAI(arg1:string,arg2:Array<string>):Promise<Record<string, any>>;
 
 export function UpdateAssignment(arg1:string,arg2:string,arg3:string,arg4:any,arg5:number,arg6:string,arg7:any):Promise<any>;
 
@@ -184,6 +268,12 @@ export function UpdateMilestoneProgress(arg1:string,arg2:number):Promise<void>;
 
 export function UpdateMyProfile(arg1:Record<string, any>):Promise<any>;
 
+export function UpdateResource(arg1:string,arg2:Record<string, any>):Promise<any>;
+
+export function UpdateResourceFolder(arg1:string,arg2:string,arg3:string,arg4:string):Promise<any>;
+
+export function UpdateRoomAISettings(arg1:string,arg2:Record<string, any>):Promise<Record<string, any>>;
+
 export function UpdateRoomExamDates(arg1:string,arg2:any):Promise<any>;
 
 export function UpdateRoomSyllabus(arg1:string,arg2:any):Promise<any>;
@@ -193,3 +283,7 @@ export function UpdateScheduleBlock(arg1:string,arg2:string,arg3:Record<string,
 export function UpdateStudyPlanProgress(arg1:string,arg2:number):Promise<void>;
 
 export function UploadFile(arg1:string,arg2:string):Promise<any>;
+
+export function UploadResourceResumable(arg1:string,arg2:string,arg3:Record<string, any>):Promise<any>;
+
+export function UploadResourceVersion(arg1:string,arg2:string,arg3:string,arg4:string):Promise<any>;
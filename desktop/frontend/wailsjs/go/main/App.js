@@ -10,8 +10,16 @@ export function AddCourseToStudyPlan(arg1, arg2) {
   return window['go']['main']['App']['AddCourseToStudyPlan'](arg1, arg2);
 }
 
-export function AnswerQuestion(arg1, arg2, arg3) {
-  return window['go']['main']['App']['AnswerQuestion'](arg1, arg2, arg3);
+export function AnswerQuestion(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['AnswerQuestion'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function CancelAIStream(arg1) {
+  return window['go']['main']['App']['CancelAIStream'](arg1);
+}
+
+export function CancelUpload(arg1) {
+  return window['go']['main']['App']['CancelUpload'](arg1);
 }
 
 export function Chat(arg1, arg2) {
@@ -22,10 +30,22 @@ export function ChatWithRoomAI(arg1, arg2) {
   return window['go']['main']['App']['ChatWithRoomAI'](arg1, arg2);
 }
 
+export function ClearRoomAICache(arg1) {
+  return window['go']['main']['App']['ClearRoomAICache'](arg1);
+}
+
 export function CompleteAssessment(arg1) {
   return window['go']['main']['App']['CompleteAssessment'](arg1);
 }
 
+export function CreateAIPromptVersion(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CreateAIPromptVersion'](arg1, arg2, arg3);
+}
+
+export function CreateAIThread(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CreateAIThread'](arg1, arg2, arg3);
+}
+
 export function CreateAssignment(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
   return window['go']['main']['App']['CreateAssignment'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
 }
@@ -46,6 +66,10 @@ export function CreateResource(arg1, arg2) {
   return window['go']['main']['App']['CreateResource'](arg1, arg2);
 }
 
+export function CreateResourceFolder(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CreateResourceFolder'](arg1, arg2, arg3);
+}
+
 export function CreateRoom(arg1, arg2, arg3, arg4, arg5, arg6) {
   return window['go']['main']['App']['CreateRoom'](arg1, arg2, arg3, arg4, arg5, arg6);
 }
@@ -62,6 +86,10 @@ export function CreateStudyPlan(arg1) {
   return window['go']['main']['App']['CreateStudyPlan'](arg1);
 }
 
+export function DeleteAIThread(arg1) {
+  return window['go']['main']['App']['DeleteAIThread'](arg1);
+}
+
 export function DeleteAssignment(arg1) {
   return window['go']['main']['App']['DeleteAssignment'](arg1);
 }
@@ -74,6 +102,10 @@ export function DeleteResource(arg1) {
   return window['go']['main']['App']['DeleteResource'](arg1);
 }
 
+export function DeleteResourceFolder(arg1, arg2) {
+  return window['go']['main']['App']['DeleteResourceFolder'](arg1, arg2);
+}
+
 export function DeleteScheduleBlock(arg1, arg2) {
   return window['go']['main']['App']['DeleteScheduleBlock'](arg1, arg2);
 }
@@ -118,6 +150,26 @@ export function GenerateSyllabusFromTopics(arg1, arg2, arg3) {
   return window['go']['main']['App']['GenerateSyllabusFromTopics'](arg1, arg2, arg3);
 }
 
+export function GetAIPrompt(arg1) {
+  return window['go']['main']['App']['GetAIPrompt'](arg1);
+}
+
+export function GetAIPrompts() {
+  return window['go']['main']['App']['GetAIPrompts']();
+}
+
+export function GetAISafetyFlags(arg1, arg2) {
+  return window['go']['main']['App']['GetAISafetyFlags'](arg1, arg2);
+}
+
+export function GetAIThread(arg1) {
+  return window['go']['main']['App']['GetAIThread'](arg1);
+}
+
+export function GetAIUsage() {
+  return window['go']['main']['App']['GetAIUsage']();
+}
+
 export function GetActiveChallenges() {
   return window['go']['main']['App']['GetActiveChallenges']();
 }
@@ -126,6 +178,10 @@ export function GetActiveStudySession() {
   return window['go']['main']['App']['GetActiveStudySession']();
 }
 
+export function GetAdminAIUsage(arg1, arg2) {
+  return window['go']['main']['App']['GetAdminAIUsage'](arg1, arg2);
+}
+
 export function GetAssignment(arg1) {
   return window['go']['main']['App']['GetAssignment'](arg1);
 }
@@ -178,6 +234,10 @@ export function GetIncomingFriendRequests() {
   return window['go']['main']['App']['GetIncomingFriendRequests']();
 }
 
+export function GetJob(arg1) {
+  return window['go']['main']['App']['GetJob'](arg1);
+}
+
 export function GetLeaderboard(arg1, arg2) {
   return window['go']['main']['App']['GetLeaderboard'](arg1, arg2);
 }
@@ -210,6 +270,14 @@ export function GetMyStudyPlans() {
   return window['go']['main']['App']['GetMyStudyPlans']();
 }
 
+export function GetNotifications(arg1) {
+  return window['go']['main']['App']['GetNotifications'](arg1);
+}
+
+export function GetPendingUploads() {
+  return window['go']['main']['App']['GetPendingUploads']();
+}
+
 export function GetReport(arg1) {
   return window['go']['main']['App']['GetReport'](arg1);
 }
@@ -218,6 +286,34 @@ export function GetReports() {
   return window['go']['main']['App']['GetReports']();
 }
 
+export function GetResourceDownloadURL(arg1) {
+  return window['go']['main']['App']['GetResourceDownloadURL'](arg1);
+}
+
+export function GetResourceEngagement(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetResourceEngagement'](arg1, arg2, arg3);
+}
+
+export function GetResourceEngagementDetail(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetResourceEngagementDetail'](arg1, arg2, arg3, arg4);
+}
+
+export function GetResourceFolders(arg1) {
+  return window['go']['main']['App']['GetResourceFolders'](arg1);
+}
+
+export function GetResourceTags(arg1) {
+  return window['go']['main']['App']['GetResourceTags'](arg1);
+}
+
+export function GetResourceVersions(arg1) {
+  return window['go']['main']['App']['GetResourceVersions'](arg1);
+}
+
+export function GetResourceViewURL(arg1) {
+  return window['go']['main']['App']['GetResourceViewURL'](arg1);
+}
+
 export function GetResources(arg1, arg2, arg3) {
   return window['go']['main']['App']['GetResources'](arg1, arg2, arg3);
 }
@@ -226,10 +322,18 @@ export function GetRoom(arg1) {
   return window['go']['main']['App']['GetRoom'](arg1);
 }
 
+export function GetRoomAISettings(arg1) {
+  return window['go']['main']['App']['GetRoomAISettings'](arg1);
+}
+
 export function GetRoomAIStatus(arg1) {
   return window['go']['main']['App']['GetRoomAIStatus'](arg1);
 }
 
+export function GetRoomAIUsage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetRoomAIUsage'](arg1, arg2, arg3);
+}
+
 export function GetRoomAnalytics(arg1) {
   return window['go']['main']['App']['GetRoomAnalytics'](arg1);
 }
@@ -250,6 +354,10 @@ export function GetScheduleBlocks(arg1) {
   return window['go']['main']['App']['GetScheduleBlocks'](arg1);
 }
 
+export function GetStudentEngagement(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetStudentEngagement'](arg1, arg2, arg3, arg4);
+}
+
 export function GetStudyPlanCourses(arg1) {
   return window['go']['main']['App']['GetStudyPlanCourses'](arg1);
 }
@@ -282,6 +390,10 @@ export function JoinRoom(arg1) {
   return window['go']['main']['App']['JoinRoom'](arg1);
 }
 
+export function ListAIThreads(arg1) {
+  return window['go']['main']['App']['ListAIThreads'](arg1);
+}
+
 export function LogStudySession(arg1, arg2) {
   return window['go']['main']['App']['LogStudySession'](arg1, arg2);
 }
@@ -294,6 +406,14 @@ export function Logout() {
   return window['go']['main']['App']['Logout']();
 }
 
+export function MarkAllNotificationsRead() {
+  return window['go']['main']['App']['MarkAllNotificationsRead']();
+}
+
+export function MarkNotificationRead(arg1) {
+  return window['go']['main']['App']['MarkNotificationRead'](arg1);
+}
+
 export function OpenFileDialog() {
   return window['go']['main']['App']['OpenFileDialog']();
 }
@@ -306,18 +426,50 @@ export function PlayGame(arg1, arg2, arg3) {
   return window['go']['main']['App']['PlayGame'](arg1, arg2, arg3);
 }
 
+export function RecordResourceProgress(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RecordResourceProgress'](arg1, arg2, arg3);
+}
+
 export function RejectFriendRequest(arg1) {
   return window['go']['main']['App']['RejectFriendRequest'](arg1);
 }
 
+export function ReleaseAIPrompt(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['ReleaseAIPrompt'](arg1, arg2, arg3, arg4);
+}
+
+export function RenameAIThread(arg1, arg2) {
+  return window['go']['main']['App']['RenameAIThread'](arg1, arg2);
+}
+
+export function RestoreResourceVersion(arg1, arg2) {
+  return window['go']['main']['App']['RestoreResourceVersion'](arg1, arg2);
+}
+
 export function ResumeStudySession() {
   return window['go']['main']['App']['ResumeStudySession']();
 }
 
+export function ResumeUpload(arg1) {
+  return window['go']['main']['App']['ResumeUpload'](arg1);
+}
+
+export function ReviewAISafetyFlag(arg1) {
+  return window['go']['main']['App']['ReviewAISafetyFlag'](arg1);
+}
+
+export function RollbackAIPrompt(arg1) {
+  return window['go']['main']['App']['RollbackAIPrompt'](arg1);
+}
+
 export function SaveTextToDownloads(arg1, arg2) {
   return window['go']['main']['App']['SaveTextToDownloads'](arg1, arg2);
 }
 
+export function SearchResources(arg1, arg2) {
+  return window['go']['main']['App']['SearchResources'](arg1, arg2);
+}
+
 export function SendFriendRequest(arg1) {
   return window['go']['main']['App']['SendFriendRequest'](arg1);
 }
@@ -346,6 +498,22 @@ export function StopStudySession(arg1, arg2) {
   return window['go']['main']['App']['StopStudySession'](arg1, arg2);
 }
 
+export function StreamAIThreadMessage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['StreamAIThreadMessage'](arg1, arg2, arg3);
+}
+
+export function StreamChat(arg1, arg2, arg3) {
+  return window['go']['main']['App']['StreamChat'](arg1, arg2, arg3);
+}
+
+export function StreamChatWithRoomAI(arg1, arg2, arg3) {
+  return window['go']['main']['App']['StreamChatWithRoomAI'](arg1, arg2, arg3);
+}
+
+export function StreamExplainTopic(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['StreamExplainTopic'](arg1, arg2, arg3, arg4);
+}
+
 export function ToggleGoalComplete(arg1) {
   return window['go']['main']['App']['ToggleGoalComplete'](arg1);
 }
@@ -366,6 +534,18 @@ export function UpdateMyProfile(arg1) {
   return window['go']['main']['App']['UpdateMyProfile'](arg1);
 }
 
+export function UpdateResource(arg1, arg2) {
+  return window['go']['main']['App']['UpdateResource'](arg1, arg2);
+}
+
+export function UpdateResourceFolder(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['UpdateResourceFolder'](arg1, arg2, arg3, arg4);
+}
+
+export function UpdateRoomAISettings(arg1, arg2) {
+  return window['go']['main']['App']['UpdateRoomAISettings'](arg1, arg2);
+}
+
 export function UpdateRoomExamDates(arg1, arg2) {
   return window['go']['main']['App']['UpdateRoomExamDates'](arg1, arg2);
 }
@@ -385,3 +565,11 @@ export function UpdateStudyPlanProgress(arg1, arg2) {
 export function UploadFile(arg1, arg2) {
   return window['go']['main']['App']['UploadFile'](arg1, arg2);
 }
+
+export function UploadResourceResumable(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UploadResourceResumable'](arg1, arg2, arg3);
+}
+
+export function UploadResourceVersion(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['UploadResourceVersion'](arg1, arg2, arg3, arg4);
+}
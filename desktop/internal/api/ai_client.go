@@ -1,5 +1,13 @@
 package api
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
 // AIClientService handles AI chat API calls
 type AIClientService struct {
 	client *Client
@@ -21,6 +29,63 @@ func (s *AIClientService) Chat(message, context string) (interface{}, error) {
 	return out, err
 }
 
+// ChatStream sends a chat message and calls onDelta with the response as it is
+// generated; it returns the final result, like Chat
+func (s *AIClientService) ChatStream(ctx context.Context, message, contextStr string, onDelta func(text string)) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"message": message,
+		"context": contextStr,
+	}
+	return streamText(ctx, s.client, "/ai/chat/stream", payload, onDelta)
+}
+
+// ExplainTopicStream asks AI to explain a topic and calls onDelta with the
+// explanation as it is generated
+func (s *AIClientService) ExplainTopicStream(ctx context.Context, topic, subject, level string, onDelta func(text string)) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"topic":   topic,
+		"subject": subject,
+		"level":   level,
+	}
+	return streamText(ctx, s.client, "/ai/explain/stream", payload, onDelta)
+}
+
+// streamText reads a streamed AI response: "delta" events go to onDelta and
+// the "done" event is the result
+func streamText(ctx context.Context, client *Client, endpoint string, payload interface{}, onDelta func(text string)) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := client.PostStream(ctx, endpoint, payload, func(event string, data []byte) error {
+		switch event {
+		case "delta":
+			var delta struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(data, &delta); err != nil {
+				return fmt.Errorf("failed to decode stream: %w", err)
+			}
+			onDelta(delta.Text)
+		case "done":
+			if err := json.Unmarshal(data, &result); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+		case "error":
+			var failure struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal(data, &failure)
+			return errors.New(failure.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, errors.New("stream ended before the response was complete")
+	}
+	return result, nil
+}
+
 // ExplainTopic asks AI to explain a topic
 func (s *AIClientService) ExplainTopic(topic, subject, level string) (interface{}, error) {
 	payload := map[string]interface{}{
@@ -33,14 +98,172 @@ func (s *AIClientService) ExplainTopic(topic, subject, level string) (interface{
 	return out, err
 }
 
-// AnswerQuestion asks AI to answer a question
-func (s *AIClientService) AnswerQuestion(question, subject, context string) (interface{}, error) {
+// AnswerQuestion asks AI to answer a question; mode "tutor" starts a tutoring
+// thread instead, as does a room (roomID) that requires it
+func (s *AIClientService) AnswerQuestion(question, subject, context, mode, roomID string) (interface{}, error) {
 	payload := map[string]interface{}{
 		"question": question,
 		"subject":  subject,
 		"context":  context,
+		"mode":     mode,
+		"room_id":  roomID,
 	}
 	var out interface{}
 	err := s.client.Post("/ai/answer", payload, &out)
 	return out, err
 }
+
+// ListThreads lists the user's tutor threads, or their threads with a room's
+// AI when roomID is set
+func (s *AIClientService) ListThreads(roomID string) ([]interface{}, error) {
+	endpoint := "/ai/threads"
+	if roomID != "" {
+		endpoint += "?room_id=" + url.QueryEscape(roomID)
+	}
+	var out []interface{}
+	err := s.client.Get(endpoint, &out)
+	return out, err
+}
+
+// CreateThread starts a conversation thread; roomID is empty for the tutor
+// and mode is "tutor" for hints instead of answers
+func (s *AIClientService) CreateThread(roomID, title, mode string) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"room_id": roomID,
+		"title":   title,
+		"mode":    mode,
+	}
+	var out map[string]interface{}
+	err := s.client.Post("/ai/threads", payload, &out)
+	return out, err
+}
+
+// GetThread gets a thread with its messages
+func (s *AIClientService) GetThread(threadID string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Get("/ai/threads/"+threadID, &out)
+	return out, err
+}
+
+// RenameThread changes a thread's title
+func (s *AIClientService) RenameThread(threadID, title string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Put("/ai/threads/"+threadID, map[string]interface{}{"title": title}, &out)
+	return out, err
+}
+
+// DeleteThread deletes a thread and its messages
+func (s *AIClientService) DeleteThread(threadID string) error {
+	return s.client.Delete("/ai/threads/" + threadID)
+}
+
+// SendThreadMessageStream continues a thread and calls onDelta with the reply
+// as it is generated; the result holds the stored reply and updated thread
+func (s *AIClientService) SendThreadMessageStream(ctx context.Context, threadID, message string, onDelta func(text string)) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"message": message,
+	}
+	return streamText(ctx, s.client, "/ai/threads/"+threadID+"/messages/stream", payload, onDelta)
+}
+
+// GetUsage returns the user's AI tokens this quota period and what is left
+func (s *AIClientService) GetUsage() (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Get("/ai/usage", &out)
+	return out, err
+}
+
+// GetRoomUsage returns a room's AI usage by student, feature and day; from and
+// to are YYYY-MM-DD and may be empty for the last 30 days
+func (s *AIClientService) GetRoomUsage(roomID, from, to string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Get("/rooms/"+roomID+"/ai/usage"+usageRangeQuery(from, to), &out)
+	return out, err
+}
+
+// GetAdminUsage returns AI usage across all users (admins only)
+func (s *AIClientService) GetAdminUsage(from, to string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Get("/admin/ai/usage"+usageRangeQuery(from, to), &out)
+	return out, err
+}
+
+// GetPrompts lists the versioned AI prompts and the versions in use (admins only)
+func (s *AIClientService) GetPrompts() ([]interface{}, error) {
+	var out []interface{}
+	err := s.client.Get("/admin/prompts", &out)
+	return out, err
+}
+
+// GetPrompt returns a prompt with all of its versions (admins only)
+func (s *AIClientService) GetPrompt(name string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Get("/admin/prompts/"+url.PathEscape(name), &out)
+	return out, err
+}
+
+// CreatePromptVersion adds a version of a prompt without releasing it (admins only)
+func (s *AIClientService) CreatePromptVersion(name, text, note string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Post("/admin/prompts/"+url.PathEscape(name)+"/versions", map[string]string{"text": text, "note": note}, &out)
+	return out, err
+}
+
+// ReleasePrompt puts a version of a prompt in use, optionally trying a
+// candidate version on a percentage of users (admins only)
+func (s *AIClientService) ReleasePrompt(name string, version, candidateVersion, candidatePercent int) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Put("/admin/prompts/"+url.PathEscape(name)+"/release", map[string]int{
+		"version":           version,
+		"candidate_version": candidateVersion,
+		"candidate_percent": candidatePercent,
+	}, &out)
+	return out, err
+}
+
+// RollbackPrompt returns a prompt to the version used before its last release (admins only)
+func (s *AIClientService) RollbackPrompt(name string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Post("/admin/prompts/"+url.PathEscape(name)+"/rollback", nil, &out)
+	return out, err
+}
+
+// GetSafetyFlags returns students' flagged AI interactions: a parent's
+// children, or a teacher's rooms (roomID may be empty for all of them)
+func (s *AIClientService) GetSafetyFlags(roomID string, unreviewed bool) ([]interface{}, error) {
+	query := url.Values{}
+	if roomID != "" {
+		query.Set("room_id", roomID)
+	}
+	if unreviewed {
+		query.Set("unreviewed", "true")
+	}
+	endpoint := "/ai/safety/flags"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var out []interface{}
+	err := s.client.Get(endpoint, &out)
+	return out, err
+}
+
+// ReviewSafetyFlag marks a flagged AI interaction as reviewed
+func (s *AIClientService) ReviewSafetyFlag(flagID string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Put("/ai/safety/flags/"+flagID+"/review", nil, &out)
+	return out, err
+}
+
+func usageRangeQuery(from, to string) string {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"io"
+	"net/url"
 )
 
 // AnalyticsService handles analytics API calls
@@ -35,6 +36,51 @@ func (s *AnalyticsService) GetRoomAnalytics(roomID string) (interface{}, error)
 	return analytics, nil
 }
 
+// GetResourceEngagement returns engagement with each teacher resource of a room.
+// exam (an exam title) or before (RFC 3339) limits "opened" to before that time.
+func (s *AnalyticsService) GetResourceEngagement(roomID, exam, before string) (interface{}, error) {
+	var report interface{}
+	path := fmt.Sprintf("/rooms/%s/analytics/resources%s", roomID, engagementCutoffQuery(exam, before))
+	if err := s.client.Get(path, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetResourceEngagementDetail returns each student's engagement with one resource
+func (s *AnalyticsService) GetResourceEngagementDetail(roomID, resourceID, exam, before string) (interface{}, error) {
+	var report interface{}
+	path := fmt.Sprintf("/rooms/%s/analytics/resources/%s%s", roomID, resourceID, engagementCutoffQuery(exam, before))
+	if err := s.client.Get(path, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetStudentEngagement returns one student's engagement with a room's teacher resources
+func (s *AnalyticsService) GetStudentEngagement(roomID, studentID, exam, before string) (interface{}, error) {
+	var report interface{}
+	path := fmt.Sprintf("/rooms/%s/analytics/students/%s/resources%s", roomID, studentID, engagementCutoffQuery(exam, before))
+	if err := s.client.Get(path, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func engagementCutoffQuery(exam, before string) string {
+	query := url.Values{}
+	if exam != "" {
+		query.Set("exam", exam)
+	}
+	if before != "" {
+		query.Set("before", before)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
 // ExportCSV exports analytics as CSV
 func (s *AnalyticsService) ExportCSV(roomID string) ([]byte, error) {
 	path := fmt.Sprintf("/rooms/%s/analytics/export", roomID)
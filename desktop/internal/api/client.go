@@ -1,14 +1,18 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"buddy-desktop/internal/config"
@@ -16,9 +20,10 @@ import (
 
 // Client represents the API client
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	authToken  string
+	baseURL      string
+	httpClient   *http.Client
+	streamClient *http.Client // No overall timeout, for responses streamed over a long time
+	authToken    string
 }
 
 // NewClient creates a new API client
@@ -29,6 +34,7 @@ func NewClient() *Client {
 		httpClient: &http.Client{
 			Timeout: time.Duration(cfg.APITimeout) * time.Second,
 		},
+		streamClient: &http.Client{},
 	}
 }
 
@@ -37,6 +43,22 @@ func (c *Client) SetAuthToken(token string) {
 	c.authToken = token
 }
 
+// ServerURL resolves a server-relative path (e.g. a signed "/files/..." URL) against the API host
+func (c *Client) ServerURL(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return path
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	return base.ResolveReference(ref).String()
+}
+
 // Get makes a GET request
 func (c *Client) Get(endpoint string, response interface{}) error {
 	return c.request(http.MethodGet, endpoint, nil, response)
@@ -52,6 +74,11 @@ func (c *Client) Put(endpoint string, body interface{}, response interface{}) er
 	return c.request(http.MethodPut, endpoint, body, response)
 }
 
+// Patch makes a PATCH request
+func (c *Client) Patch(endpoint string, body interface{}, response interface{}) error {
+	return c.request(http.MethodPatch, endpoint, body, response)
+}
+
 // Delete makes a DELETE request
 func (c *Client) Delete(endpoint string) error {
 	return c.request(http.MethodDelete, endpoint, nil, nil)
@@ -59,6 +86,11 @@ func (c *Client) Delete(endpoint string) error {
 
 // request performs an HTTP request
 func (c *Client) request(method, endpoint string, body interface{}, response interface{}) error {
+	return c.requestWithHeaders(method, endpoint, nil, body, response)
+}
+
+// requestWithHeaders performs an HTTP request with extra headers
+func (c *Client) requestWithHeaders(method, endpoint string, headers map[string]string, body interface{}, response interface{}) error {
 	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
 
 	var reqBody io.Reader
@@ -80,6 +112,9 @@ func (c *Client) request(method, endpoint string, body interface{}, response int
 	if c.authToken != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
 	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
 	// Perform request
 	resp, err := c.httpClient.Do(req)
@@ -104,6 +139,69 @@ func (c *Client) request(method, endpoint string, body interface{}, response int
 	return nil
 }
 
+// PostStream makes a POST request answered with server-sent events and calls
+// onEvent for each event until the stream ends, onEvent fails or ctx is
+// cancelled. There is no overall timeout: ctx bounds the stream.
+func (c *Client) PostStream(ctx context.Context, endpoint string, body interface{}, onEvent func(event string, data []byte) error) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if c.authToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	}
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	event := "message"
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line ends the event
+			if len(data) > 0 {
+				if err := onEvent(event, data); err != nil {
+					return err
+				}
+			}
+			event, data = "message", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream interrupted: %w", err)
+	}
+	if len(data) > 0 {
+		return onEvent(event, data)
+	}
+	return nil
+}
+
 // UploadFile uploads a file using multipart/form-data
 func (c *Client) UploadFile(endpoint, filePath string, response interface{}) error {
 	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
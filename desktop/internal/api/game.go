@@ -57,7 +57,7 @@ type GenerateGameRequest struct {
 	QuestionCount int    `json:"question_count"`
 }
 
-// GenerateGame generates a new game
+// GenerateGame generates a new game, waiting for the server's job to finish
 func (s *GameService) GenerateGame(roomID string, gameType, subject, difficulty string, questionCount int) (interface{}, error) {
 	req := GenerateGameRequest{
 		GameType:      gameType,
@@ -68,7 +68,7 @@ func (s *GameService) GenerateGame(roomID string, gameType, subject, difficulty
 
 	var result interface{}
 	path := fmt.Sprintf("/rooms/%s/games", roomID)
-	if err := s.client.Post(path, req, &result); err != nil {
+	if err := s.client.RunJob(path, req, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
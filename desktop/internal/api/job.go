@@ -0,0 +1,104 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	jobPollInterval = 2 * time.Second
+	jobWaitLimit    = 15 * time.Minute
+	jobSubmitTries  = 3
+)
+
+// Job is slow work, such as generating a report, that the server runs in the background
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	RoomID      string          `json:"room_id,omitempty"`
+	Status      string          `json:"status"` // "queued", "running", "succeeded" or "failed"
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	CreatedAt   string          `json:"created_at"`
+	FinishedAt  string          `json:"finished_at,omitempty"`
+}
+
+// JobService handles background job API calls
+type JobService struct {
+	client *Client
+}
+
+// NewJobService creates a new job service
+func NewJobService(client *Client) *JobService {
+	return &JobService{client: client}
+}
+
+// GetJob gets the status of a job, with its result once it succeeded
+func (s *JobService) GetJob(jobID string) (*Job, error) {
+	var job Job
+	if err := s.client.Get("/jobs/"+jobID, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RunJob starts a job with a POST request, waits for it to finish and
+// decodes its result into response. The request is sent with an idempotency
+// key, so resending it after a network error doesn't start a second job.
+func (c *Client) RunJob(endpoint string, body interface{}, response interface{}) error {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Idempotency-Key": key}
+
+	var job Job
+	for try := 1; ; try++ {
+		err = c.requestWithHeaders(http.MethodPost, endpoint, headers, body, &job)
+		var netErr *url.Error
+		if err == nil || try == jobSubmitTries || !errors.As(err, &netErr) {
+			break
+		}
+		time.Sleep(time.Duration(try) * time.Second)
+	}
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(jobWaitLimit)
+	for job.Status != "succeeded" {
+		if job.Status == "failed" {
+			return fmt.Errorf("job failed: %s", job.Error)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("job %s is still %s, check back later", job.ID, job.Status)
+		}
+		time.Sleep(jobPollInterval)
+		if err := c.Get("/jobs/"+job.ID, &job); err != nil {
+			return err
+		}
+	}
+
+	if response != nil && len(job.Result) > 0 {
+		if err := json.Unmarshal(job.Result, response); err != nil {
+			return fmt.Errorf("failed to decode job result: %w", err)
+		}
+	}
+	return nil
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
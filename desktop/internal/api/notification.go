@@ -0,0 +1,53 @@
+package api
+
+// Notification is an in-app notice, such as an updated room resource
+type Notification struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"` // "resource_updated", "job_succeeded" or "job_failed"
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	RoomID     string `json:"room_id,omitempty"`
+	ResourceID string `json:"resource_id,omitempty"`
+	JobID      string `json:"job_id,omitempty"`
+	Read       bool   `json:"read"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// NotificationList is a page of notifications with the unread total
+type NotificationList struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`
+}
+
+// NotificationService handles notification API calls
+type NotificationService struct {
+	client *Client
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(client *Client) *NotificationService {
+	return &NotificationService{client: client}
+}
+
+// GetNotifications gets the current user's notifications, newest first
+func (s *NotificationService) GetNotifications(unreadOnly bool) (*NotificationList, error) {
+	endpoint := "/notifications"
+	if unreadOnly {
+		endpoint += "?unread=true"
+	}
+	var list NotificationList
+	if err := s.client.Get(endpoint, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// MarkRead marks a notification as read
+func (s *NotificationService) MarkRead(notificationID string) error {
+	return s.client.Post("/notifications/"+notificationID+"/read", nil, nil)
+}
+
+// MarkAllRead marks all notifications as read
+func (s *NotificationService) MarkAllRead() error {
+	return s.client.Post("/notifications/read-all", nil, nil)
+}
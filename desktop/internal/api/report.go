@@ -10,13 +10,13 @@ func NewReportService(client *Client) *ReportService {
 	return &ReportService{client: client}
 }
 
-// GenerateReport generates a new performance report
+// GenerateReport generates a new performance report, waiting for the server's job to finish
 func (s *ReportService) GenerateReport(reportType string) (interface{}, error) {
 	payload := map[string]interface{}{
 		"report_type": reportType,
 	}
 	var out interface{}
-	err := s.client.Post("/reports/generate", payload, &out)
+	err := s.client.RunJob("/reports/generate", payload, &out)
 	return out, err
 }
 
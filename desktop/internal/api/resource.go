@@ -1,5 +1,12 @@
 package api
 
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
 // Resource represents a resource model
 type Resource struct {
 	ID           string   `json:"id"`
@@ -16,7 +23,73 @@ type Resource struct {
 	Subjects     []string  `json:"subjects,omitempty"` // Related syllabus topics/subjects (multiple)
 	IsPublic     bool     `json:"is_public"`
 	SharedWith   []string `json:"shared_with,omitempty"`
+	FolderID     string   `json:"folder_id,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Version      int      `json:"version"`
+	Preview      *ResourcePreview `json:"preview,omitempty"`
 	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at,omitempty"`
+}
+
+// ResourcePreview is a thumbnail and metadata generated by the server
+type ResourcePreview struct {
+	Status          string  `json:"status"` // "ready", "failed", "unsupported"
+	ThumbnailURL    string  `json:"thumbnail_url,omitempty"`
+	Width           int     `json:"width,omitempty"`
+	Height          int     `json:"height,omitempty"`
+	PageCount       int     `json:"page_count,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Text            string  `json:"text,omitempty"`
+}
+
+// ResourceVersion is one file revision of a resource
+type ResourceVersion struct {
+	ID           string `json:"id"`
+	ResourceID   string `json:"resource_id"`
+	Version      int    `json:"version"`
+	FileURL      string `json:"file_url"`
+	FileType     string `json:"file_type"`
+	FileSize     int64  `json:"file_size"`
+	ChangeNote   string `json:"change_note,omitempty"`
+	RestoredFrom int    `json:"restored_from,omitempty"`
+	CreatedBy    string `json:"created_by"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// ResourceFolder is a folder of room resources
+type ResourceFolder struct {
+	ID        string `json:"id"`
+	RoomID    string `json:"room_id"`
+	ParentID  string `json:"parent_id,omitempty"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ResourceTagCount is a tag used in a room and how many resources have it
+type ResourceTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// ResourceQuery filters, sorts and pages a resource listing
+type ResourceQuery struct {
+	UploaderType string   `json:"uploader_type,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	FolderID     string   `json:"folder_id,omitempty"` // "root" for resources outside folders
+	Tags         []string `json:"tags,omitempty"`
+	Subject      string   `json:"subject,omitempty"`
+	Search       string   `json:"q,omitempty"`
+	Sort         string   `json:"sort,omitempty"`
+	Limit        int      `json:"limit,omitempty"`
+	Offset       int      `json:"offset,omitempty"`
+}
+
+// ResourcePage is one page of a resource listing
+type ResourcePage struct {
+	Resources []Resource `json:"resources"`
+	Total     int64      `json:"total"`
+	Limit     int        `json:"limit"`
+	Offset    int        `json:"offset"`
 }
 
 // ResourceService handles resource API calls
@@ -43,15 +116,131 @@ func (s *ResourceService) CreateResource(roomID string, resource Resource) (*Res
 	return &result, err
 }
 
-// GetResources gets all resources for a room
+// SearchResources gets one page of a room's resources
+func (s *ResourceService) SearchResources(roomID string, query ResourceQuery) (*ResourcePage, error) {
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"uploader_type": query.UploaderType,
+		"category":      query.Category,
+		"folder_id":     query.FolderID,
+		"tags":          strings.Join(query.Tags, ","),
+		"subject":       query.Subject,
+		"q":             query.Search,
+		"sort":          query.Sort,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset > 0 {
+		params.Set("offset", strconv.Itoa(query.Offset))
+	}
+
+	var page ResourcePage
+	if err := s.client.Get("/rooms/"+roomID+"/resources?"+params.Encode(), &page); err != nil {
+		return nil, err
+	}
+	// Thumbnail URLs are server-relative
+	for i := range page.Resources {
+		if p := page.Resources[i].Preview; p != nil && p.ThumbnailURL != "" {
+			p.ThumbnailURL = s.client.ServerURL(p.ThumbnailURL)
+		}
+	}
+	return &page, nil
+}
+
+// GetResources gets all resources for a room, following pages
 func (s *ResourceService) GetResources(roomID, uploaderType, category string) ([]Resource, error) {
-	var resources []Resource
-	query := "?uploader_type=" + uploaderType
-	if category != "" && category != "all" {
-		query += "&category=" + category
+	query := ResourceQuery{UploaderType: uploaderType, Category: category, Limit: 200}
+	resources := []Resource{}
+	for {
+		page, err := s.SearchResources(roomID, query)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, page.Resources...)
+		if len(page.Resources) == 0 || int64(len(resources)) >= page.Total {
+			return resources, nil
+		}
+		query.Offset = len(resources)
 	}
-	err := s.client.Get("/rooms/"+roomID+"/resources"+query, &resources)
-	return resources, err
+}
+
+// GetTags gets the tags used in a room, most used first
+func (s *ResourceService) GetTags(roomID string) ([]ResourceTagCount, error) {
+	var tags []ResourceTagCount
+	err := s.client.Get("/rooms/"+roomID+"/resources/tags", &tags)
+	return tags, err
+}
+
+// UpdateResource changes a resource's name, description, subjects, tags or folder.
+// Only the keys present in changes are updated.
+func (s *ResourceService) UpdateResource(resourceID string, changes map[string]interface{}) (*Resource, error) {
+	var result Resource
+	err := s.client.Patch("/resources/"+resourceID, changes, &result)
+	return &result, err
+}
+
+// GetFolders gets all folders of a room
+func (s *ResourceService) GetFolders(roomID string) ([]ResourceFolder, error) {
+	var folders []ResourceFolder
+	err := s.client.Get("/rooms/"+roomID+"/resources/folders", &folders)
+	return folders, err
+}
+
+// CreateFolder creates a folder; an empty parentID creates it at the top level
+func (s *ResourceService) CreateFolder(roomID, name, parentID string) (*ResourceFolder, error) {
+	var folder ResourceFolder
+	err := s.client.Post("/rooms/"+roomID+"/resources/folders", map[string]string{"name": name, "parent_id": parentID}, &folder)
+	return &folder, err
+}
+
+// UpdateFolder renames a folder and moves it under parentID ("" for the top level)
+func (s *ResourceService) UpdateFolder(roomID, folderID, name, parentID string) (*ResourceFolder, error) {
+	var folder ResourceFolder
+	err := s.client.Put("/rooms/"+roomID+"/resources/folders/"+folderID, map[string]string{"name": name, "parent_id": parentID}, &folder)
+	return &folder, err
+}
+
+// DeleteFolder deletes a folder; its contents move up a level
+func (s *ResourceService) DeleteFolder(roomID, folderID string) error {
+	return s.client.Delete("/rooms/" + roomID + "/resources/folders/" + folderID)
+}
+
+// GetDownloadURL gets a short-lived signed URL for a resource's file
+func (s *ResourceService) GetDownloadURL(resourceID string) (string, error) {
+	var result struct {
+		URL string `json:"url"`
+	}
+	if err := s.client.Get("/resources/"+resourceID+"/download-url", &result); err != nil {
+		return "", err
+	}
+	return s.client.ServerURL(result.URL), nil
+}
+
+// GetViewURL gets a signed URL for showing a resource's file in the app; the
+// server counts it as a view rather than a download
+func (s *ResourceService) GetViewURL(resourceID string) (string, error) {
+	var result struct {
+		URL string `json:"url"`
+	}
+	if err := s.client.Get("/resources/"+resourceID+"/download-url?for=view", &result); err != nil {
+		return "", err
+	}
+	return s.client.ServerURL(result.URL), nil
+}
+
+// RecordProgress reports how far a video resource has been played
+func (s *ResourceService) RecordProgress(resourceID string, position, duration float64) error {
+	body := map[string]interface{}{
+		"event":            "progress",
+		"position_seconds": position,
+		"duration_seconds": duration,
+	}
+	return s.client.Post("/resources/"+resourceID+"/engagement", body, nil)
 }
 
 // DeleteResource deletes a resource
@@ -67,3 +256,23 @@ func (s *ResourceService) ShareResource(resourceID string, sharedWith []string,
 	}
 	return s.client.Post("/resources/"+resourceID+"/share", data, nil)
 }
+
+// GetVersions gets a resource's version history, newest first
+func (s *ResourceService) GetVersions(resourceID string) ([]ResourceVersion, error) {
+	var result struct {
+		Versions []ResourceVersion `json:"versions"`
+	}
+	if err := s.client.Get("/resources/"+resourceID+"/versions", &result); err != nil {
+		return nil, err
+	}
+	return result.Versions, nil
+}
+
+// RestoreVersion makes an earlier version of a resource current again
+func (s *ResourceService) RestoreVersion(resourceID string, version int) (*Resource, error) {
+	var resource Resource
+	if err := s.client.Post(fmt.Sprintf("/resources/%s/versions/%d/restore", resourceID, version), nil, &resource); err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
@@ -1,6 +1,9 @@
 package api
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // WeeklySchedule represents a weekly class schedule
 type WeeklySchedule struct {
@@ -204,12 +207,33 @@ type TrainRoomAIRequest struct {
 	ResourceIDs []string `json:"resource_ids"`
 }
 
-// TrainRoomAI trains the room AI with selected resources
-func (s *RoomService) TrainRoomAI(roomID string, resourceIDs []string) error {
+// TrainRoomAI trains the room AI with selected resources, waiting for the server's job to finish
+func (s *RoomService) TrainRoomAI(roomID string, resourceIDs []string) (map[string]interface{}, error) {
 	req := TrainRoomAIRequest{
 		ResourceIDs: resourceIDs,
 	}
-	return s.client.Post("/rooms/"+roomID+"/ai/train", req, nil)
+	var context map[string]interface{}
+	err := s.client.RunJob("/rooms/"+roomID+"/ai/train", req, &context)
+	return context, err
+}
+
+// ClearRoomAICache drops the room AI's cached answers
+func (s *RoomService) ClearRoomAICache(roomID string) error {
+	return s.client.Delete("/rooms/" + roomID + "/ai/cache")
+}
+
+// GetRoomAISettings gets how the room AI behaves
+func (s *RoomService) GetRoomAISettings(roomID string) (map[string]interface{}, error) {
+	var settings map[string]interface{}
+	err := s.client.Get("/rooms/"+roomID+"/ai/settings", &settings)
+	return settings, err
+}
+
+// UpdateRoomAISettings changes how the room AI behaves (room owner)
+func (s *RoomService) UpdateRoomAISettings(roomID string, settings map[string]interface{}) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := s.client.Put("/rooms/"+roomID+"/ai/settings", settings, &out)
+	return out, err
 }
 
 // ChatWithRoomAIRequest represents a message to the room AI
@@ -226,3 +250,12 @@ func (s *RoomService) ChatWithRoomAI(roomID string, message string) (map[string]
 	err := s.client.Post("/rooms/"+roomID+"/ai/chat", req, &response)
 	return response, err
 }
+
+// ChatWithRoomAIStream sends a message to the room's AI coach and calls
+// onDelta with the answer as it is generated; the result includes citations
+func (s *RoomService) ChatWithRoomAIStream(ctx context.Context, roomID, message string, onDelta func(text string)) (map[string]interface{}, error) {
+	req := ChatWithRoomAIRequest{
+		Message: message,
+	}
+	return streamText(ctx, s.client, "/rooms/"+roomID+"/ai/chat/stream", req, onDelta)
+}
@@ -18,6 +18,9 @@ type Service struct {
 	SmartPlan  *SmartPlanService
 	Game       *GameService
 	Analytics  *AnalyticsService
+	Uploads    *ResumableUploader
+	Notifications *NotificationService
+	Jobs       *JobService
 }
 
 // NewService creates a new service with all API services
@@ -40,6 +43,9 @@ func NewService() *Service {
 		SmartPlan:  NewSmartPlanService(client),
 		Game:       NewGameService(client),
 		Analytics:  NewAnalyticsService(client),
+		Uploads:    NewResumableUploader(client),
+		Notifications: NewNotificationService(client),
+		Jobs:       NewJobService(client),
 	}
 }
 
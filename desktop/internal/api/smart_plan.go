@@ -57,10 +57,10 @@ type CreateSmartPlanRequest struct {
 	Milestones     []MilestonePreview     `json:"milestones"`
 }
 
-// GenerateSmartPlan generates a smart study plan using AI
+// GenerateSmartPlan generates a smart study plan using AI, waiting for the server's job to finish
 func (s *SmartPlanService) GenerateSmartPlan(req GenerateSmartPlanRequest) (*GeneratedPlanResponse, error) {
 	var response GeneratedPlanResponse
-	err := s.client.Post("/smart-plan/generate", req, &response)
+	err := s.client.RunJob("/smart-plan/generate", req, &response)
 	return &response, err
 }
 
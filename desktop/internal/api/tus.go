@@ -0,0 +1,465 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tusVersion = "1.0.0"
+
+	// resumableChunkSize is sent per PATCH; small enough to retry cheaply on flaky networks
+	resumableChunkSize = 5 << 20
+	// resumableRetries is how many times a chunk is retried before giving up
+	resumableRetries = 6
+	// resumableProcessingTimeout bounds how long to wait for the server to scan and store the file
+	resumableProcessingTimeout = 30 * time.Minute
+)
+
+// errUploadGone means the server no longer knows the upload (expired or removed)
+var errUploadGone = errors.New("upload no longer exists on the server")
+
+// PendingUpload is a resumable upload saved on disk so it can continue after an app restart
+type PendingUpload struct {
+	ID        string            `json:"id"`  // Fingerprint of file + room
+	URL       string            `json:"url"` // Upload session URL on the server
+	FilePath  string            `json:"file_path"`
+	Size      int64             `json:"size"`
+	ModTime   time.Time         `json:"mod_time"`
+	RoomID    string            `json:"room_id"`
+	Metadata  map[string]string `json:"metadata"`
+	Offset    int64             `json:"offset"` // Last offset confirmed by the server
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// UploadStatus is the server's view of a resumable upload
+type UploadStatus struct {
+	ID         string `json:"id"`
+	RoomID     string `json:"room_id"`
+	Length     int64  `json:"length"`
+	Offset     int64  `json:"offset"`
+	Status     string `json:"status"` // "uploading", "processing", "completed", "failed"
+	Error      string `json:"error,omitempty"`
+	FileURL    string `json:"file_url,omitempty"`
+	ScanStatus string `json:"scan_status,omitempty"`
+	ResourceID string `json:"resource_id,omitempty"`
+}
+
+// UploadProgress reports bytes confirmed by the server
+type UploadProgress func(upload *PendingUpload, sent, total int64)
+
+// ResumableUploader uploads large files in chunks using the tus 1.0 protocol
+type ResumableUploader struct {
+	client     *Client
+	httpClient *http.Client // No overall timeout: chunks are bounded individually
+	statePath  string
+	mu         sync.Mutex
+}
+
+// NewResumableUploader creates an uploader that keeps its state in the user config directory
+func NewResumableUploader(client *Client) *ResumableUploader {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return &ResumableUploader{
+		client:     client,
+		httpClient: &http.Client{},
+		statePath:  filepath.Join(dir, "buddy", "pending-uploads.json"),
+	}
+}
+
+// Pending lists uploads that were interrupted and can be resumed
+func (u *ResumableUploader) Pending() ([]PendingUpload, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	state, err := u.load()
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]PendingUpload, 0, len(state))
+	for _, upload := range state {
+		pending = append(pending, *upload)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending, nil
+}
+
+// Upload uploads a file to a room, continuing a previous attempt for the same
+// file if one was interrupted. Metadata may describe the resource to create.
+func (u *ResumableUploader) Upload(roomID, filePath string, metadata map[string]string, progress UploadProgress) (*UploadStatus, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	fields := map[string]string{"room_id": roomID, "filename": filepath.Base(filePath)}
+	for k, v := range metadata {
+		fields[k] = v
+	}
+
+	upload := &PendingUpload{
+		ID:        uploadFingerprint(roomID, filePath, info),
+		FilePath:  filePath,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		RoomID:    roomID,
+		Metadata:  fields,
+		CreatedAt: time.Now(),
+	}
+	if saved := u.get(upload.ID); saved != nil {
+		upload = saved
+	}
+	return u.run(upload, progress)
+}
+
+// Resume continues a pending upload by ID
+func (u *ResumableUploader) Resume(id string, progress UploadProgress) (*UploadStatus, error) {
+	upload := u.get(id)
+	if upload == nil {
+		return nil, fmt.Errorf("no pending upload %s", id)
+	}
+	info, err := os.Stat(upload.FilePath)
+	if err != nil || info.Size() != upload.Size || !info.ModTime().Equal(upload.ModTime) {
+		return nil, fmt.Errorf("file %s has changed or is missing; start a new upload", upload.FilePath)
+	}
+	return u.run(upload, progress)
+}
+
+// Cancel stops a pending upload and tells the server to discard it
+func (u *ResumableUploader) Cancel(id string) error {
+	upload := u.get(id)
+	if upload == nil {
+		return nil
+	}
+	if upload.URL != "" {
+		req, err := u.newRequest(context.Background(), http.MethodDelete, upload.URL, nil)
+		if err == nil {
+			if resp, err := u.httpClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	return u.remove(id)
+}
+
+func (u *ResumableUploader) run(upload *PendingUpload, progress UploadProgress) (*UploadStatus, error) {
+	if upload.URL != "" {
+		offset, err := u.head(upload.URL)
+		if err == errUploadGone {
+			upload.URL = ""
+		} else if err != nil {
+			return nil, err
+		} else {
+			upload.Offset = offset
+		}
+	}
+	if upload.URL == "" {
+		location, err := u.create(upload)
+		if err != nil {
+			return nil, err
+		}
+		upload.URL = location
+		upload.Offset = 0
+	}
+	if err := u.save(upload); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(upload.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	for upload.Offset < upload.Size {
+		if progress != nil {
+			progress(upload, upload.Offset, upload.Size)
+		}
+		offset, err := u.patchWithRetry(file, upload)
+		if err != nil {
+			// State stays on disk so the upload can be resumed later
+			return nil, err
+		}
+		upload.Offset = offset
+		u.save(upload)
+	}
+	if progress != nil {
+		progress(upload, upload.Size, upload.Size)
+	}
+
+	status, err := u.waitForProcessing(upload.URL)
+	if err != nil {
+		return nil, err
+	}
+	u.remove(upload.ID)
+	if status.Status == "failed" {
+		return status, fmt.Errorf("upload failed: %s", status.Error)
+	}
+	return status, nil
+}
+
+// create starts an upload session and returns its absolute URL
+func (u *ResumableUploader) create(upload *PendingUpload) (string, error) {
+	req, err := u.newRequest(context.Background(), http.MethodPost, u.client.baseURL+"/uploads/tus", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	req.Header.Set("Upload-Metadata", encodeTusMetadata(upload.Metadata))
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", apiError(resp)
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("server did not return an upload location")
+	}
+	return u.client.ServerURL(location), nil
+}
+
+// head asks the server how many bytes it has
+func (u *ResumableUploader) head(url string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := u.newRequest(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	case http.StatusNotFound, http.StatusGone:
+		return 0, errUploadGone
+	default:
+		return 0, fmt.Errorf("API error: %s", resp.Status)
+	}
+}
+
+// patchWithRetry sends the chunk at the current offset, backing off and
+// re-syncing the offset with the server after failures
+func (u *ResumableUploader) patchWithRetry(file *os.File, upload *PendingUpload) (int64, error) {
+	var lastErr error
+	for attempt := 0; attempt < resumableRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+			if offset, err := u.head(upload.URL); err == nil {
+				upload.Offset = offset
+				if offset >= upload.Size {
+					return offset, nil
+				}
+			} else if err == errUploadGone {
+				return 0, err
+			}
+		}
+
+		offset, err := u.patch(file, upload)
+		if err == nil {
+			return offset, nil
+		}
+		lastErr = err
+	}
+	return 0, fmt.Errorf("upload interrupted after %d attempts: %w", resumableRetries, lastErr)
+}
+
+func (u *ResumableUploader) patch(file *os.File, upload *PendingUpload) (int64, error) {
+	length := upload.Size - upload.Offset
+	if length > resumableChunkSize {
+		length = resumableChunkSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	body := io.NewSectionReader(file, upload.Offset, length)
+	req, err := u.newRequest(ctx, http.MethodPatch, upload.URL, body)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, apiError(resp)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// waitForProcessing polls until the server has scanned and stored the file
+func (u *ResumableUploader) waitForProcessing(url string) (*UploadStatus, error) {
+	deadline := time.Now().Add(resumableProcessingTimeout)
+	for {
+		var status UploadStatus
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		req, err := u.newRequest(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		req.Header.Del("Tus-Resumable")
+		resp, err := u.httpClient.Do(req)
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				err = json.NewDecoder(resp.Body).Decode(&status)
+			} else {
+				err = apiError(resp)
+			}
+			resp.Body.Close()
+		}
+		cancel()
+
+		if err == nil && status.Status != "processing" {
+			return &status, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("timed out waiting for the server to process the upload")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (u *ResumableUploader) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+	if u.client.authToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", u.client.authToken))
+	}
+	return req, nil
+}
+
+func apiError(resp *http.Response) error {
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
+}
+
+// encodeTusMetadata builds an Upload-Metadata header
+func encodeTusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(metadata[k])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// uploadFingerprint identifies an upload of a particular file version to a room
+func uploadFingerprint(roomID, filePath string, info os.FileInfo) string {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		abs = filePath
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d", roomID, abs, info.Size(), info.ModTime().UnixNano())))
+	return hex.EncodeToString(sum[:8])
+}
+
+// State file helpers
+
+func (u *ResumableUploader) load() (map[string]*PendingUpload, error) {
+	state := make(map[string]*PendingUpload)
+	data, err := os.ReadFile(u.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		// Corrupt state only loses the ability to resume
+		return make(map[string]*PendingUpload), nil
+	}
+	return state, nil
+}
+
+func (u *ResumableUploader) write(state map[string]*PendingUpload) error {
+	if err := os.MkdirAll(filepath.Dir(u.statePath), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := u.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.statePath)
+}
+
+func (u *ResumableUploader) get(id string) *PendingUpload {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	state, err := u.load()
+	if err != nil {
+		return nil
+	}
+	return state[id]
+}
+
+func (u *ResumableUploader) save(upload *PendingUpload) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	state, err := u.load()
+	if err != nil {
+		return err
+	}
+	copied := *upload
+	state[upload.ID] = &copied
+	return u.write(state)
+}
+
+func (u *ResumableUploader) remove(id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	state, err := u.load()
+	if err != nil {
+		return err
+	}
+	delete(state, id)
+	return u.write(state)
+}
@@ -0,0 +1,168 @@
+// Command ai-eval runs the AI features (game questions, reports, goal
+// suggestions, room AI chat, explanations, answers, smart plans and study
+// assessments) over a fixed set of inputs and scores the outputs with
+// deterministic checks: schema validity, question and option counts, answers
+// contained in their options, duplicates, reading level, room AI refusals and
+// citations, and plan hours and dates.
+// Given a second run it writes a report comparing the two, so a prompt or
+// model change can be checked before it is released.
+//
+// Runs are named by provider ("gemini", "openai" or "fake", optionally with
+// ":model") and prompt versions. A prompt is pinned to a stored version with
+// name@version (read from MongoDB) or to an unsaved template with name=path;
+// prompts that aren't pinned use their built-in version, not the live
+// release, and the report lists the version each run used:
+//
+//	go run ./cmd/ai-eval -a fake
+//	go run ./cmd/ai-eval -a gemini -b gemini -b-prompts game_quiz@2 -out eval.md
+//	go run ./cmd/ai-eval -a gemini:gemini-2.5-flash -b openai:llama3.1 -json eval.json
+//
+// Providers are configured with the same environment variables as the server
+// (GEMINI_API_KEY, OPENAI_BASE_URL, ...).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"buddy-server/config"
+	"buddy-server/database"
+	"buddy-server/services"
+)
+
+func main() {
+	fixturesPath := flag.String("fixtures", "cmd/ai-eval/fixtures.json", "JSON file of inputs for each AI feature")
+	fakeScript := flag.String("fake-script", "cmd/ai-eval/fake_script.json", "replies of the fake provider")
+	a := flag.String("a", "fake", "provider[:model] of the first run")
+	aPrompts := flag.String("a-prompts", "", "prompt versions of the first run: comma-separated name@version or name=path")
+	b := flag.String("b", "", "provider[:model] of the run to compare with; empty for a single run")
+	bPrompts := flag.String("b-prompts", "", "prompt versions of the second run")
+	out := flag.String("out", "", "write the Markdown report here instead of to stdout")
+	jsonOut := flag.String("json", "", "also write every output and check as JSON here")
+	flag.Parse()
+
+	data, err := os.ReadFile(*fixturesPath)
+	if err != nil {
+		log.Fatal("Reading fixtures: ", err)
+	}
+	var fixtures services.EvalFixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		log.Fatalf("Invalid fixtures %s: %v", *fixturesPath, err)
+	}
+
+	cfg := config.Load()
+	cfg.LLM.FakeScript = *fakeScript
+	var db *database.DB
+	if needsDatabase(*aPrompts) || needsDatabase(*bPrompts) {
+		db = database.Connect(cfg.MongoURI)
+		defer db.Close()
+	}
+
+	ctx := context.Background()
+	reportA := runEval(ctx, cfg.LLM, db, *a, *aPrompts, fixtures)
+	var reportB *services.EvalReport
+	if *b != "" {
+		reportB = runEval(ctx, cfg.LLM, db, *b, *bPrompts, fixtures)
+		if reportA.Label == reportB.Label {
+			reportA.Label, reportB.Label = "A: "+reportA.Label, "B: "+reportB.Label
+		}
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			log.Fatal(err)
+		}
+		defer w.Close()
+	}
+	if err := services.WriteEvalReport(w, reportA, reportB); err != nil {
+		log.Fatal(err)
+	}
+
+	if *jsonOut != "" {
+		runs := []*services.EvalReport{reportA}
+		if reportB != nil {
+			runs = append(runs, reportB)
+		}
+		data, err := json.MarshalIndent(runs, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// runEval runs the fixtures through one provider and set of prompt versions
+func runEval(ctx context.Context, llm config.LLMConfig, db *database.DB, selection, prompts string, fixtures services.EvalFixtures) *services.EvalReport {
+	llm.Default = config.ParseLLMSelection(selection)
+	llm.Features = nil
+	providers := services.NewLLMProviders(llm)
+	defer providers.Close()
+	provider, err := providers.For(services.LLMFeatureGames)
+	if err != nil {
+		log.Fatalf("Provider %s: %v", selection, err)
+	}
+
+	registry := services.NewPromptRegistry(db)
+	for _, pin := range splitList(prompts) {
+		if err := pinPrompt(registry, pin); err != nil {
+			log.Fatalf("Prompt %s: %v", pin, err)
+		}
+	}
+
+	label := selection
+	if prompts != "" {
+		label += " (" + prompts + ")"
+	}
+	log.Printf("Evaluating %s", label)
+	return services.RunEval(ctx, label, provider, registry, fixtures)
+}
+
+// pinPrompt pins name@version to a stored version or name=path to a template file
+func pinPrompt(registry *services.PromptRegistry, pin string) error {
+	if name, path, ok := strings.Cut(pin, "="); ok {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return registry.PinText(name, filepath.Base(path), string(text))
+	}
+	name, version, ok := strings.Cut(pin, "@")
+	if !ok {
+		return fmt.Errorf("expected name@version or name=path")
+	}
+	n, err := strconv.Atoi(version)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid version %q", version)
+	}
+	return registry.Pin(name, n)
+}
+
+// needsDatabase reports whether any pin is a stored version after the built-in one
+func needsDatabase(prompts string) bool {
+	for _, pin := range splitList(prompts) {
+		if _, version, ok := strings.Cut(pin, "@"); ok && !strings.Contains(pin, "=") && version != "1" {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,96 @@
+// Command migrate-storage copies uploaded files between storage backends,
+// e.g. from the local uploads directory to an S3-compatible bucket.
+//
+// Backends are configured with the same environment variables as the server
+// (STORAGE_LOCAL_DIR, S3_ENDPOINT, S3_BUCKET, ...):
+//
+//	go run ./cmd/migrate-storage -from local -to s3
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"buddy-server/config"
+	"buddy-server/storage"
+)
+
+func main() {
+	from := flag.String("from", "local", "source backend (local or s3)")
+	to := flag.String("to", "s3", "destination backend (local or s3)")
+	prefix := flag.String("prefix", "", "only migrate keys starting with this prefix (e.g. rooms/)")
+	dryRun := flag.Bool("dry-run", false, "list the objects that would be copied without copying them")
+	deleteSource := flag.Bool("delete-source", false, "delete each object from the source after it is copied")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatal("-from and -to must name different backends")
+	}
+
+	cfg := config.Load()
+	src := openBackend(cfg.Storage, *from)
+	dst := openBackend(cfg.Storage, *to)
+
+	ctx := context.Background()
+	var copied, skipped, failed int
+	err := src.List(ctx, *prefix, func(info storage.ObjectInfo) error {
+		// Objects already present with the same size were migrated by an earlier run
+		if existing, err := dst.Stat(ctx, info.Key); err == nil && existing.Size == info.Size {
+			skipped++
+			return nil
+		}
+
+		if *dryRun {
+			fmt.Printf("would copy %s (%d bytes)\n", info.Key, info.Size)
+			copied++
+			return nil
+		}
+
+		if err := copyObject(ctx, src, dst, info); err != nil {
+			log.Printf("copy %s: %v", info.Key, err)
+			failed++
+			return nil
+		}
+		copied++
+		fmt.Printf("copied %s (%d bytes)\n", info.Key, info.Size)
+
+		if *deleteSource {
+			if err := src.Delete(ctx, info.Key); err != nil {
+				log.Printf("delete %s from source: %v", info.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal("Listing source failed: ", err)
+	}
+
+	fmt.Printf("%d copied, %d already present, %d failed\n", copied, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// openBackend creates one backend from the shared storage configuration
+func openBackend(cfg storage.Config, backend string) storage.Storage {
+	cfg.Backend = backend
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", backend, err)
+	}
+	return store
+}
+
+// copyObject streams a single object from src to dst
+func copyObject(ctx context.Context, src, dst storage.Storage, info storage.ObjectInfo) error {
+	reader, obj, err := src.Get(ctx, info.Key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return dst.Put(ctx, info.Key, reader, obj.Size, obj.ContentType)
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// AI quota periods
+const (
+	AIQuotaDaily   = "daily"   // Resets at midnight UTC
+	AIQuotaMonthly = "monthly" // Resets on the first of the month, UTC
+)
+
+// LLMSelection picks a provider and, optionally, a model for it
+type LLMSelection struct {
+	Provider string // "gemini", "openai" (any OpenAI-compatible API) or "fake"
+	Model    string // Empty for the provider's default model
+}
+
+// ParseLLMSelection parses "provider" or "provider:model"
+func ParseLLMSelection(value string) LLMSelection {
+	provider, model, _ := strings.Cut(strings.TrimSpace(value), ":")
+	return LLMSelection{Provider: strings.ToLower(strings.TrimSpace(provider)), Model: strings.TrimSpace(model)}
+}
+
+// LLMConfig configures the language model providers and which one each feature uses
+type LLMConfig struct {
+	Default  LLMSelection            // Used by features without an override
+	Features map[string]LLMSelection // Per-feature overrides, keyed by feature name such as "room_ai"
+
+	GeminiAPIKey  string
+	OpenAIBaseURL string // e.g. "https://api.openai.com/v1" or "http://localhost:11434/v1" for Ollama
+	OpenAIAPIKey  string // Optional for local servers
+	FakeScript    string // Path to a JSON script for the fake provider; empty for canned replies
+
+	SafetyThreshold string // Gemini content filter: "low", "medium", "high" or "none"; empty for Gemini's default
+}
+
+// Selection returns the provider and model for a feature. Without an explicit
+// default, Gemini is used when an API key is set.
+func (c LLMConfig) Selection(feature string) LLMSelection {
+	selection := c.Default
+	if override := c.Features[feature]; override.Provider != "" {
+		selection = override
+	} else if override.Model != "" {
+		selection.Model = override.Model
+	}
+	if selection.Provider == "" && c.GeminiAPIKey != "" {
+		selection.Provider = "gemini"
+	}
+	return selection
+}
+
+// AIPrice is what a model costs in US dollars per million tokens
+type AIPrice struct {
+	Input  float64
+	Output float64
+}
+
+// AIQuotaConfig limits the language model tokens users spend per period and
+// prices the calls for reports. Organizations are users' schools.
+type AIQuotaConfig struct {
+	Period             string             // AIQuotaDaily or AIQuotaMonthly
+	RoleTokens         map[string]int64   // Per user, by role; 0 or missing is unlimited
+	OrganizationTokens int64              // Per organization; 0 is unlimited
+	Organizations      map[string]int64   // Per-organization overrides of OrganizationTokens, by lowercase school name
+	Prices             map[string]AIPrice // By model name prefix; the longest match wins
+}
+
+// AICacheConfig selects the AI features whose answers are reused for identical requests
+type AICacheConfig struct {
+	Features   map[string]bool // Features that opted in; none by default
+	TTL        time.Duration   // How long an answer is reused
+	MaxEntries int             // The oldest answers are dropped beyond this
+}
+
+// AISafetyConfig holds the block lists checked for students; terms match whole
+// words, ignoring case
+type AISafetyConfig struct {
+	BlockedInput  []string // Checked against what the student writes
+	BlockedOutput []string // Checked against the model's answers
+}
@@ -0,0 +1,56 @@
+package config
+
+import "testing"
+
+func TestParseLLMSelection(t *testing.T) {
+	cases := map[string]LLMSelection{
+		"gemini":               {Provider: "gemini"},
+		" OpenAI:llama3.1:8b ": {Provider: "openai", Model: "llama3.1:8b"},
+		":gemini-2.5-pro":      {Model: "gemini-2.5-pro"},
+		"":                     {},
+	}
+	for value, want := range cases {
+		if got := ParseLLMSelection(value); got != want {
+			t.Errorf("ParseLLMSelection(%q) = %+v, want %+v", value, got, want)
+		}
+	}
+}
+
+func TestLLMConfigSelection(t *testing.T) {
+	config := LLMConfig{
+		GeminiAPIKey: "key",
+		Features: map[string]LLMSelection{
+			"room_ai": {Provider: "openai", Model: "llama3.1"},
+			"games":   {Model: "gemini-2.5-pro"},
+		},
+	}
+	if got := config.Selection("chat"); got != (LLMSelection{Provider: "gemini"}) {
+		t.Errorf("Expected Gemini by default when a key is set, got %+v", got)
+	}
+	if got := config.Selection("room_ai"); got != (LLMSelection{Provider: "openai", Model: "llama3.1"}) {
+		t.Errorf("Expected the room AI override, got %+v", got)
+	}
+	if got := config.Selection("games"); got != (LLMSelection{Provider: "gemini", Model: "gemini-2.5-pro"}) {
+		t.Errorf("Expected a model-only override to keep the default provider, got %+v", got)
+	}
+	if got := (LLMConfig{}).Selection("chat"); got.Provider != "" {
+		t.Errorf("Expected no provider without configuration, got %+v", got)
+	}
+}
+
+func TestLLMFeatureSelections(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "gemini")
+	t.Setenv("LLM_FAKE_SCRIPT", "script.json")
+	t.Setenv("LLM_ROOM_AI", "openai:llama3.1")
+
+	selections := llmFeatureSelections()
+	if got := selections["room_ai"]; got != (LLMSelection{Provider: "openai", Model: "llama3.1"}) {
+		t.Errorf("Expected the room AI override, got %+v", got)
+	}
+	if _, ok := selections["provider"]; ok {
+		t.Error("LLM_PROVIDER is the default, not a feature override")
+	}
+	if _, ok := selections["fake_script"]; ok {
+		t.Error("LLM_FAKE_SCRIPT is not a feature override")
+	}
+}
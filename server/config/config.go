@@ -1,9 +1,16 @@
 package config
 
 import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"buddy-server/storage"
 
 	"github.com/joho/godotenv"
 )
@@ -14,8 +21,37 @@ type Config struct {
 	Env            string
 	MongoURI       string
 	JWTSecret      string
-	GeminiAPIKey   string
 	AllowedOrigins []string
+
+	// LLM selects the language model provider and model of each AI feature
+	LLM LLMConfig
+
+	// AIQuota limits the AI tokens users and schools spend and prices them for usage reports
+	AIQuota AIQuotaConfig
+	// AISafety holds the block lists checked for students' AI requests and answers
+	AISafety AISafetyConfig
+	// AICache reuses answers to identical AI requests for the features that opt in
+	AICache AICacheConfig
+
+	// AdminEmails are the accounts allowed to see server-wide reports
+	AdminEmails []string
+
+	// EmbeddingProvider selects room AI embeddings: "gemini", "local" or empty for automatic
+	EmbeddingProvider string
+
+	// DownloadURLSecret signs expiring download URLs (derived from JWTSecret by default)
+	DownloadURLSecret string
+
+	// Upload limits in bytes (0 disables a limit)
+	MaxUploadSize    int64
+	RoomStorageQuota int64
+	UserStorageQuota int64
+
+	// ClamdAddress enables malware scanning of uploads, e.g. "tcp://localhost:3310"
+	ClamdAddress string
+
+	// Storage selects where uploaded files are kept: local disk or an S3-compatible bucket
+	Storage storage.Config
 }
 
 // Load loads configuration from environment variables
@@ -30,14 +66,87 @@ func Load() *Config {
 	if mongoURI == "" {
 		mongoURI = getEnv("MONGO_URI", "mongodb://localhost:27017/buddy")
 	}
+	jwtSecret := getEnv("JWT_SECRET", "default-secret-change-in-production")
 	return &Config{
 		Port:           getEnv("PORT", "8080"),
 		Env:            getEnv("ENV", "development"),
 		MongoURI:       mongoURI,
-		JWTSecret:      getEnv("JWT_SECRET", "default-secret-change-in-production"),
-		GeminiAPIKey:   getEnv("GEMINI_API_KEY", ""),
+		JWTSecret:      jwtSecret,
 		AllowedOrigins: strings.Split(getEnv("ALLOWED_ORIGINS", "http://localhost:34115,http://localhost:5173"), ","),
+
+		LLM: LLMConfig{
+			Default:       ParseLLMSelection(getEnv("LLM_PROVIDER", "")),
+			Features:      llmFeatureSelections(),
+			GeminiAPIKey:  getEnv("GEMINI_API_KEY", ""),
+			OpenAIBaseURL: getEnv("OPENAI_BASE_URL", ""),
+			OpenAIAPIKey:  getEnv("OPENAI_API_KEY", ""),
+			FakeScript:    getEnv("LLM_FAKE_SCRIPT", ""),
+
+			SafetyThreshold: getEnv("AI_SAFETY_THRESHOLD", ""),
+		},
+
+		AIQuota: AIQuotaConfig{
+			Period: getEnv("AI_QUOTA_PERIOD", AIQuotaDaily),
+			RoleTokens: map[string]int64{
+				"student": getEnvInt64("AI_QUOTA_STUDENT_TOKENS", 0),
+				"teacher": getEnvInt64("AI_QUOTA_TEACHER_TOKENS", 0),
+				"parent":  getEnvInt64("AI_QUOTA_PARENT_TOKENS", 0),
+			},
+			OrganizationTokens: getEnvInt64("AI_QUOTA_ORG_TOKENS", 0),
+			Organizations:      organizationQuotas(getEnv("AI_QUOTA_ORGS", "")),
+			Prices:             modelPrices(getEnv("AI_PRICES", "")),
+		},
+		AISafety: AISafetyConfig{
+			BlockedInput:  blocklist("AI_SAFETY_INPUT_BLOCKLIST"),
+			BlockedOutput: blocklist("AI_SAFETY_OUTPUT_BLOCKLIST"),
+		},
+		AICache: AICacheConfig{
+			Features:   featureSet(getEnv("AI_CACHE_FEATURES", "")),
+			TTL:        getEnvDuration("AI_CACHE_TTL", 24*time.Hour),
+			MaxEntries: int(getEnvInt64("AI_CACHE_MAX_ENTRIES", 1000)),
+		},
+		AdminEmails: splitList(getEnv("ADMIN_EMAILS", "")),
+
+		EmbeddingProvider: getEnv("EMBEDDING_PROVIDER", ""),
+
+		DownloadURLSecret: downloadURLSecret(jwtSecret),
+
+		MaxUploadSize:    getEnvMB("MAX_UPLOAD_SIZE_MB", 200),
+		RoomStorageQuota: getEnvMB("ROOM_STORAGE_QUOTA_MB", 5120),
+		UserStorageQuota: getEnvMB("USER_STORAGE_QUOTA_MB", 1024),
+		ClamdAddress:     getEnv("CLAMD_ADDRESS", ""),
+
+		Storage: storage.Config{
+			Backend:  getEnv("STORAGE_BACKEND", "local"),
+			LocalDir: getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+			S3: storage.S3Config{
+				Endpoint:  getEnv("S3_ENDPOINT", ""),
+				Region:    getEnv("S3_REGION", "us-east-1"),
+				Bucket:    getEnv("S3_BUCKET", ""),
+				AccessKey: getEnv("S3_ACCESS_KEY", ""),
+				SecretKey: getEnv("S3_SECRET_KEY", ""),
+				PathStyle: getEnvBool("S3_PATH_STYLE", true),
+			},
+		},
+	}
+}
+
+// downloadURLSecret is DOWNLOAD_URL_SECRET, or a key derived from the JWT
+// secret with HKDF so the two never share a key and a download URL key can't
+// sign auth tokens
+func downloadURLSecret(jwtSecret string) string {
+	secret := getEnv("DOWNLOAD_URL_SECRET", "")
+	if secret != "" && secret != jwtSecret {
+		return secret
 	}
+	if secret != "" {
+		log.Println("DOWNLOAD_URL_SECRET is the same as JWT_SECRET; deriving a separate key instead")
+	}
+	key, err := hkdf.Key(sha256.New, []byte(jwtSecret), nil, "buddy download URLs", 32)
+	if err != nil {
+		log.Fatal("Deriving the download URL secret: ", err)
+	}
+	return hex.EncodeToString(key)
 }
 
 // getEnv gets an environment variable or returns default value
@@ -47,3 +156,128 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// llmFeatureSelections reads per-feature overrides such as LLM_ROOM_AI=openai:llama3.1,
+// keyed by the lower-case feature name; the services warn about unknown features
+func llmFeatureSelections() map[string]LLMSelection {
+	selections := make(map[string]LLMSelection)
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		feature, ok := strings.CutPrefix(key, "LLM_")
+		if !ok || key == "LLM_PROVIDER" || key == "LLM_FAKE_SCRIPT" || value == "" {
+			continue
+		}
+		selections[strings.ToLower(feature)] = ParseLLMSelection(value)
+	}
+	return selections
+}
+
+// getEnvBool gets a boolean environment variable or returns default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvInt64 gets a non-negative integer from the environment or returns default value
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvDuration gets a positive duration such as 12h from the environment or returns default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// featureSet reads a comma-separated list of AI features
+func featureSet(value string) map[string]bool {
+	features := make(map[string]bool)
+	for _, feature := range splitList(strings.ToLower(value)) {
+		features[feature] = true
+	}
+	return features
+}
+
+// blocklist reads the AI safety terms from an environment variable plus the
+// terms shared by inputs and outputs in AI_SAFETY_BLOCKLIST and
+// AI_SAFETY_BLOCKLIST_FILE (one term per line, # for comments)
+func blocklist(key string) []string {
+	terms := append(splitList(getEnv(key, "")), splitList(getEnv("AI_SAFETY_BLOCKLIST", ""))...)
+	path := getEnv("AI_SAFETY_BLOCKLIST_FILE", "")
+	if path == "" {
+		return terms
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Warning: failed to read AI_SAFETY_BLOCKLIST_FILE: %v", err)
+		return terms
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			terms = append(terms, line)
+		}
+	}
+	return terms
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// organizationQuotas parses per-school token quotas such as
+// "Lincoln High=2000000;Westside Academy=0" (0 is unlimited)
+func organizationQuotas(value string) map[string]int64 {
+	quotas := make(map[string]int64)
+	for _, entry := range strings.Split(value, ";") {
+		name, tokens, ok := strings.Cut(entry, "=")
+		limit, err := strconv.ParseInt(strings.TrimSpace(tokens), 10, 64)
+		if !ok || err != nil || limit < 0 || strings.TrimSpace(name) == "" {
+			if strings.TrimSpace(entry) != "" {
+				log.Printf("Ignoring invalid AI_QUOTA_ORGS entry %q", entry)
+			}
+			continue
+		}
+		quotas[strings.ToLower(strings.TrimSpace(name))] = limit
+	}
+	return quotas
+}
+
+// modelPrices parses model prices in US dollars per million input/output
+// tokens, such as "gemini-2.5-flash=0.30/2.50,gpt-4o-mini=0.15/0.60"
+func modelPrices(value string) map[string]AIPrice {
+	prices := make(map[string]AIPrice)
+	for _, entry := range splitList(value) {
+		model, price, ok := strings.Cut(entry, "=")
+		input, output, ok2 := strings.Cut(price, "/")
+		in, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		out, err2 := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if !ok || !ok2 || err != nil || err2 != nil || strings.TrimSpace(model) == "" {
+			log.Printf("Ignoring invalid AI_PRICES entry %q", entry)
+			continue
+		}
+		prices[strings.TrimSpace(model)] = AIPrice{Input: in, Output: out}
+	}
+	return prices
+}
+
+// getEnvMB gets a size in megabytes from the environment and returns it in bytes
+func getEnvMB(key string, defaultMB int64) int64 {
+	if value, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && value >= 0 {
+		return value << 20
+	}
+	return defaultMB << 20
+}
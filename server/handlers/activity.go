@@ -200,7 +200,8 @@ func (h *ActivityHandler) GenerateAssessment(c *gin.Context) {
 		return
 	}
 
-	questions, err := h.productivityService.GenerateAssessmentQuestions(req.Subject, req.Notes, req.DurationMinutes)
+	userID, _ := c.Get("user_id")
+	questions, err := h.productivityService.GenerateAssessmentQuestions(userID.(string), req.Subject, req.Notes, req.DurationMinutes)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -12,16 +12,31 @@ import (
 
 // AIHandler handles AI-related endpoints
 type AIHandler struct {
-	geminiService *services.GeminiService
+	aiService     *services.AIService
+	threadService *services.AIThreadService
 }
 
 // NewAIHandler creates a new AI handler
-func NewAIHandler(geminiService *services.GeminiService) *AIHandler {
+func NewAIHandler(aiService *services.AIService) *AIHandler {
 	return &AIHandler{
-		geminiService: geminiService,
+		aiService: aiService,
 	}
 }
 
+// SetThreadService sets the thread service used to tutor homework questions
+func (h *AIHandler) SetThreadService(threadService *services.AIThreadService) {
+	h.threadService = threadService
+}
+
+// available writes a 503 response when no model is configured for these endpoints
+func (h *AIHandler) available(c *gin.Context) bool {
+	if h.aiService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": services.ErrLLMNotConfigured.Error()})
+		return false
+	}
+	return true
+}
+
 // ChatRequest represents a chat request
 type ChatRequest struct {
 	Message string `json:"message" binding:"required"`
@@ -30,13 +45,17 @@ type ChatRequest struct {
 
 // Chat handles chat requests
 func (h *AIHandler) Chat(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	response, err := h.geminiService.Chat(req.Message, req.Context)
+	response, err := h.aiService.Chat(c.Request.Context(), req.Message, req.Context)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -45,6 +64,23 @@ func (h *AIHandler) Chat(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"response": response})
 }
 
+// ChatStream streams a chat response as server-sent events
+func (h *AIHandler) ChatStream(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := newSSEStream(c)
+	response, err := h.aiService.ChatStream(c.Request.Context(), req.Message, req.Context, stream.Delta)
+	stream.Finish(gin.H{"response": response}, err)
+}
+
 // ExplainRequest represents an explain topic request
 type ExplainRequest struct {
 	Topic   string `json:"topic" binding:"required"`
@@ -54,13 +90,17 @@ type ExplainRequest struct {
 
 // ExplainTopic handles topic explanation requests
 func (h *AIHandler) ExplainTopic(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
 	var req ExplainRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	explanation, err := h.geminiService.ExplainTopic(req.Topic, req.Subject, req.Level)
+	explanation, err := h.aiService.ExplainTopic(c.Request.Context(), req.Topic, req.Subject, req.Level)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -69,28 +109,76 @@ func (h *AIHandler) ExplainTopic(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"explanation": explanation})
 }
 
-// QuestionRequest represents a question answering request
+// ExplainTopicStream streams a topic explanation as server-sent events
+func (h *AIHandler) ExplainTopicStream(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	var req ExplainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := newSSEStream(c)
+	explanation, err := h.aiService.ExplainTopicStream(c.Request.Context(), req.Topic, req.Subject, req.Level, stream.Delta)
+	stream.Finish(gin.H{"explanation": explanation}, err)
+}
+
+// QuestionRequest represents a question answering request; mode "tutor"
+// asks for hints instead of the answer, and room_id asks the room's AI
 type QuestionRequest struct {
 	Question string `json:"question" binding:"required"`
 	Subject  string `json:"subject" binding:"required"`
 	Context  string `json:"context"`
+	Mode     string `json:"mode" binding:"omitempty,oneof=answer tutor"`
+	RoomID   string `json:"room_id"`
 }
 
 // AnswerQuestion handles question answering requests
 func (h *AIHandler) AnswerQuestion(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
 	var req QuestionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	answer, err := h.geminiService.AnswerQuestion(req.Question, req.Subject, req.Context)
+	// Students are tutored when they ask to be or their room requires it
+	if h.threadService != nil {
+		userID, _ := c.Get("user_id")
+		reply, err := h.threadService.TutorQuestion(c.Request.Context(), userID.(string), req.RoomID,
+			tutorMessage(req), req.Mode == "tutor", nil)
+		if err != nil {
+			c.JSON(threadErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if reply != nil {
+			c.JSON(http.StatusOK, gin.H{"answer": reply.Message.Content, "mode": "tutor", "thread": reply.Thread})
+			return
+		}
+	}
+
+	answer, err := h.aiService.AnswerQuestion(c.Request.Context(), req.Question, req.Subject, req.Context)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"answer": answer})
+	c.JSON(http.StatusOK, gin.H{"answer": answer, "mode": "answer"})
+}
+
+// tutorMessage is the first message of a tutored question
+func tutorMessage(req QuestionRequest) string {
+	message := req.Question + "\n\nSubject: " + req.Subject
+	if req.Context != "" {
+		message += "\nContext: " + req.Context
+	}
+	return message
 }
 
 // GenerateQuestionsRequest represents a question generation request
@@ -103,13 +191,17 @@ type GenerateQuestionsRequest struct {
 
 // GenerateQuestions handles question generation requests
 func (h *AIHandler) GenerateQuestions(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
 	var req GenerateQuestionsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	questions, err := h.geminiService.GenerateQuestions(req.Topic, req.Subject, req.Difficulty, req.Count)
+	questions, err := h.aiService.GenerateQuestions(c.Request.Context(), req.Topic, req.Subject, req.Difficulty, req.Count)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -126,13 +218,17 @@ type SummarizeRequest struct {
 
 // Summarize handles content summarization requests
 func (h *AIHandler) Summarize(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
 	var req SummarizeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	summary, err := h.geminiService.SummarizeContent(req.Content, req.MaxLength)
+	summary, err := h.aiService.SummarizeContent(c.Request.Context(), req.Content, req.MaxLength)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -150,13 +246,17 @@ type GenerateSyllabusFromFileRequest struct {
 
 // GenerateSyllabusFromFile handles syllabus generation from file content
 func (h *AIHandler) GenerateSyllabusFromFile(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
 	var req GenerateSyllabusFromFileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	jsonStr, err := h.geminiService.GenerateSyllabusFromFile(req.FileContent, req.Subject, req.CourseName)
+	jsonStr, err := h.aiService.GenerateSyllabusFromFile(c.Request.Context(), req.FileContent, req.Subject, req.CourseName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -182,13 +282,17 @@ type GenerateSyllabusFromTopicsRequest struct {
 
 // GenerateSyllabusFromTopics handles syllabus generation from topics list
 func (h *AIHandler) GenerateSyllabusFromTopics(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
 	var req GenerateSyllabusFromTopicsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	jsonStr, err := h.geminiService.GenerateSyllabusFromTopics(req.Topics, req.Subject, req.CourseName)
+	jsonStr, err := h.aiService.GenerateSyllabusFromTopics(c.Request.Context(), req.Topics, req.Subject, req.CourseName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
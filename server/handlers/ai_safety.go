@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AISafetyHandler lets parents and teachers review students' flagged AI interactions
+type AISafetyHandler struct {
+	safetyService *services.AISafetyService
+}
+
+// NewAISafetyHandler creates a new AI safety handler
+func NewAISafetyHandler(safetyService *services.AISafetyService) *AISafetyHandler {
+	return &AISafetyHandler{safetyService: safetyService}
+}
+
+// GetFlags lists the flagged interactions of a parent's children or in a
+// teacher's rooms (?room_id= for one room, ?unreviewed=true to hide reviewed ones)
+func (h *AISafetyHandler) GetFlags(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	flags, err := h.safetyService.ListFlags(userID.(string), c.Query("room_id"), c.Query("unreviewed") == "true")
+	if err != nil {
+		c.JSON(safetyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, flags)
+}
+
+// ReviewFlag marks a flagged interaction as reviewed
+func (h *AISafetyHandler) ReviewFlag(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	flag, err := h.safetyService.ReviewFlag(userID.(string), c.Param("flag_id"))
+	if err != nil {
+		c.JSON(safetyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// safetyErrorStatus maps AI safety review errors to HTTP status codes
+func safetyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrSafetyFlagNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSafetyReviewPermission):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIHandler_ChatStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	post := func(handler *AIHandler, body string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.POST("/ai/chat/stream", handler.ChatStream)
+		req := httptest.NewRequest(http.MethodPost, "/ai/chat/stream", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	provider := services.NewScriptedLLMProvider([]services.ScriptedReply{
+		{Match: "fail", Error: "model overloaded"},
+		{Reply: "Photosynthesis makes sugar."},
+	})
+	handler := NewAIHandler(services.NewAIService(provider))
+
+	w := post(handler, `{"message": "What is photosynthesis?"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"))
+	body := w.Body.String()
+	assert.Equal(t, 3, strings.Count(body, "event:delta"))
+	assert.Contains(t, body, `data:{"text":"Photosynthesis "}`)
+	assert.Contains(t, body, "event:done\ndata:{\"response\":\"Photosynthesis makes sugar.\"}")
+
+	// Errors before the first delta are plain JSON responses
+	w = post(handler, `{"message": "please fail"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error": "model overloaded"}`, w.Body.String())
+
+	w = post(handler, `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = post(NewAIHandler(nil), `{"message": "Hi"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
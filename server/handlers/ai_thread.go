@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AIThreadHandler handles stored conversations with the tutor and room AIs
+type AIThreadHandler struct {
+	threadService *services.AIThreadService
+}
+
+// NewAIThreadHandler creates a new AI thread handler
+func NewAIThreadHandler(threadService *services.AIThreadService) *AIThreadHandler {
+	return &AIThreadHandler{threadService: threadService}
+}
+
+// CreateThreadRequest starts a thread; room_id is empty for the general tutor
+// and mode is "tutor" for hints instead of answers
+type CreateThreadRequest struct {
+	RoomID string `json:"room_id"`
+	Title  string `json:"title"`
+	Mode   string `json:"mode"`
+}
+
+// ThreadMessageRequest continues a thread
+type ThreadMessageRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// GetThreads lists the user's tutor threads, or their threads in a room (?room_id=)
+func (h *AIThreadHandler) GetThreads(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	threads, err := h.threadService.ListThreads(userID.(string), c.Query("room_id"))
+	if err != nil {
+		c.JSON(threadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, threads)
+}
+
+// CreateThread starts a thread
+func (h *AIThreadHandler) CreateThread(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateThreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	thread, err := h.threadService.CreateThread(userID.(string), req.RoomID, req.Title, req.Mode)
+	if err != nil {
+		c.JSON(threadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, thread)
+}
+
+// GetThread gets a thread with its messages
+func (h *AIThreadHandler) GetThread(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	thread, err := h.threadService.GetThread(userID.(string), c.Param("thread_id"))
+	if err != nil {
+		c.JSON(threadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, thread)
+}
+
+// RenameThread changes a thread's title
+func (h *AIThreadHandler) RenameThread(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req struct {
+		Title string `json:"title" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	thread, err := h.threadService.RenameThread(userID.(string), c.Param("thread_id"), req.Title)
+	if err != nil {
+		c.JSON(threadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, thread)
+}
+
+// DeleteThread deletes a thread and its messages
+func (h *AIThreadHandler) DeleteThread(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.threadService.DeleteThread(userID.(string), c.Param("thread_id")); err != nil {
+		c.JSON(threadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Thread deleted"})
+}
+
+// SendMessage continues a thread and returns the reply
+func (h *AIThreadHandler) SendMessage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req ThreadMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reply, err := h.threadService.SendMessage(c.Request.Context(), userID.(string), c.Param("thread_id"), req.Message, nil)
+	if err != nil {
+		c.JSON(threadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reply)
+}
+
+// SendMessageStream continues a thread, streaming the reply as server-sent
+// events; the final event carries the stored reply and the updated thread
+func (h *AIThreadHandler) SendMessageStream(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req ThreadMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := newSSEStream(c)
+	stream.errorStatus = threadErrorStatus
+	reply, err := h.threadService.SendMessage(c.Request.Context(), userID.(string), c.Param("thread_id"), req.Message, stream.Delta)
+	stream.Finish(reply, err)
+}
+
+// threadErrorStatus maps AI thread service errors to HTTP status codes
+func threadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrThreadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrRoomAccessDenied), errors.Is(err, services.ErrRoomAIBlackout):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrThreadTitleRequired), errors.Is(err, services.ErrInvalidThreadMode):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrLLMNotConfigured):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AIUsageHandler reports AI token usage and cost
+type AIUsageHandler struct {
+	usageService *services.AIUsageService
+}
+
+// NewAIUsageHandler creates a new AI usage handler
+func NewAIUsageHandler(usageService *services.AIUsageService) *AIUsageHandler {
+	return &AIUsageHandler{usageService: usageService}
+}
+
+// GetMyUsage reports the current user's usage and remaining quota for this period
+func (h *AIUsageHandler) GetMyUsage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	usage, err := h.usageService.GetUserUsage(userID.(string))
+	if err != nil {
+		c.JSON(usageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// GetRoomUsage reports the AI usage in a room by student, feature and day (?from=&to=, room owner)
+func (h *AIUsageHandler) GetRoomUsage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	report, err := h.usageService.GetRoomUsage(c.Param("id"), userID.(string), c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(usageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetUsageReport reports all AI usage and cost (?from=&to=, admins)
+func (h *AIUsageHandler) GetUsageReport(c *gin.Context) {
+	report, err := h.usageService.GetUsageReport(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(usageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// usageErrorStatus maps AI usage report errors to HTTP status codes
+func usageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidUsageRange):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrAnalyticsPermission):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrRoomNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
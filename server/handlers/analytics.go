@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,15 +11,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AnalyticsHandler handles game analytics endpoints
+// AnalyticsHandler handles game and resource engagement analytics endpoints
 type AnalyticsHandler struct {
-	analyticsService *services.GameAnalyticsService
+	analyticsService  *services.GameAnalyticsService
+	engagementService *services.ResourceEngagementService
 }
 
 // NewAnalyticsHandler creates a new analytics handler
-func NewAnalyticsHandler(analyticsService *services.GameAnalyticsService) *AnalyticsHandler {
+func NewAnalyticsHandler(analyticsService *services.GameAnalyticsService, engagementService *services.ResourceEngagementService) *AnalyticsHandler {
 	return &AnalyticsHandler{
-		analyticsService: analyticsService,
+		analyticsService:  analyticsService,
+		engagementService: engagementService,
 	}
 }
 
@@ -95,3 +98,54 @@ func (h *AnalyticsHandler) generateCSV(analytics *services.RoomGameAnalytics) st
 
 	return sb.String()
 }
+
+// GetResourceEngagement reports how students use each teacher resource of a room.
+// ?exam=<title> or ?before=<RFC 3339 time> counts only resources opened before then.
+func (h *AnalyticsHandler) GetResourceEngagement(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	report, err := h.engagementService.GetRoomEngagement(c.Param("id"), userID.(string), c.Query("before"), c.Query("exam"))
+	if err != nil {
+		c.JSON(engagementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetResourceEngagementDetail reports each student's use of one resource,
+// including who hasn't opened it (before the exam or time given)
+func (h *AnalyticsHandler) GetResourceEngagementDetail(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	report, err := h.engagementService.GetResourceEngagement(c.Param("id"), c.Param("resource_id"), userID.(string), c.Query("before"), c.Query("exam"))
+	if err != nil {
+		c.JSON(engagementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetStudentEngagement reports one student's use of a room's teacher resources
+func (h *AnalyticsHandler) GetStudentEngagement(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	report, err := h.engagementService.GetStudentEngagement(c.Param("id"), c.Param("user_id"), userID.(string), c.Query("before"), c.Query("exam"))
+	if err != nil {
+		c.JSON(engagementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// engagementErrorStatus maps engagement report errors to HTTP status codes
+func engagementErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAnalyticsPermission):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrRoomNotFound), errors.Is(err, services.ErrResourceNotFound),
+		errors.Is(err, services.ErrStudentNotFound), errors.Is(err, services.ErrExamNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
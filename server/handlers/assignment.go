@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
+	"buddy-server/models"
 	"buddy-server/services"
 
 	"github.com/gin-gonic/gin"
@@ -29,6 +32,7 @@ type CreateAssignmentRequest struct {
 	TotalPoints    int      `json:"total_points"`
 	AssignmentType string   `json:"assignment_type" binding:"required"` // "homework", "quiz", "project", "exam"
 	Subjects       []string `json:"subjects,omitempty"` // Related syllabus topics/subjects (multiple)
+	PeerReview     *models.PeerReviewConfig `json:"peer_review,omitempty"` // Project assignments only
 }
 
 // CreateAssignment creates a new assignment
@@ -64,6 +68,7 @@ func (h *AssignmentHandler) CreateAssignment(c *gin.Context) {
 		req.TotalPoints,
 		req.AssignmentType,
 		req.Subjects,
+		req.PeerReview,
 	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -107,6 +112,9 @@ type UpdateAssignmentRequest struct {
 	TotalPoints    int      `json:"total_points"`
 	AssignmentType string   `json:"assignment_type" binding:"required"`
 	Subjects       []string `json:"subjects,omitempty"` // Related syllabus topics/subjects (multiple)
+	// Project assignments only: a configuration replaces the current one and
+	// null removes it; leaving it out keeps it
+	PeerReview json.RawMessage `json:"peer_review,omitempty"`
 }
 
 // UpdateAssignment updates an assignment
@@ -133,6 +141,14 @@ func (h *AssignmentHandler) UpdateAssignment(c *gin.Context) {
 		return
 	}
 
+	var peerReview *models.PeerReviewConfig
+	if len(req.PeerReview) > 0 {
+		if err := json.Unmarshal(req.PeerReview, &peerReview); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid peer_review: " + err.Error()})
+			return
+		}
+	}
+
 	assignment, err := h.assignmentService.UpdateAssignment(
 		assignmentID,
 		teacherID.(string),
@@ -142,9 +158,11 @@ func (h *AssignmentHandler) UpdateAssignment(c *gin.Context) {
 		req.TotalPoints,
 		req.AssignmentType,
 		req.Subjects,
+		peerReview,
+		len(req.PeerReview) > 0,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(assignmentErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -170,3 +188,71 @@ func (h *AssignmentHandler) DeleteAssignment(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Assignment deleted successfully"})
 }
+
+// SubmitAssignmentRequest represents a student submission request
+type SubmitAssignmentRequest struct {
+	Content string `json:"content"`
+	FileURL string `json:"file_url"` // URL returned by the resource upload endpoint
+}
+
+// SubmitAssignment submits (or resubmits) the current student's work
+func (h *AssignmentHandler) SubmitAssignment(c *gin.Context) {
+	assignmentID := c.Param("assignment_id")
+	studentID, _ := c.Get("user_id")
+	userRole, _ := c.Get("user_role")
+
+	if userRole != "student" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only students can submit assignments"})
+		return
+	}
+
+	var req SubmitAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	submission, err := h.assignmentService.SubmitAssignment(assignmentID, studentID.(string), req.Content, req.FileURL)
+	if err != nil {
+		c.JSON(assignmentErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, submission)
+}
+
+// GetSubmissions gets all submissions for an assignment (the room's teacher only)
+func (h *AssignmentHandler) GetSubmissions(c *gin.Context) {
+	assignmentID := c.Param("assignment_id")
+	userID, _ := c.Get("user_id")
+	userRole, _ := c.Get("user_role")
+
+	if userRole != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only teachers can view submissions"})
+		return
+	}
+
+	submissions, err := h.assignmentService.GetSubmissionsForOwner(assignmentID, userID.(string))
+	if err != nil {
+		c.JSON(assignmentErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, submissions)
+}
+
+// assignmentErrorStatus maps assignment errors to HTTP status codes, using
+// fallback for the rest
+func assignmentErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrAssignmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAssignmentAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrAssignmentPastDue), errors.Is(err, services.ErrPeerReviewsAssigned),
+		errors.Is(err, services.ErrPeerReviewLocked), errors.Is(err, services.ErrInvalidSubmissionFile):
+		return http.StatusBadRequest
+	default:
+		return fallback
+	}
+}
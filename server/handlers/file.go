@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"buddy-server/services"
+	"buddy-server/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// downloadURLTTL is how long signed download URLs stay valid
+const downloadURLTTL = 15 * time.Minute
+
+// previewURLTTL is how long signed thumbnail URLs in resource listings stay valid
+const previewURLTTL = time.Hour
+
+// FileHandler serves stored files: public avatars, signed download URLs and
+// signed URLs for room files referenced by messages and submissions
+type FileHandler struct {
+	store           storage.Storage
+	signer          *storage.URLSigner
+	resourceService *services.ResourceService
+}
+
+// NewFileHandler creates a new file handler
+func NewFileHandler(store storage.Storage, signer *storage.URLSigner, resourceService *services.ResourceService) *FileHandler {
+	return &FileHandler{
+		store:           store,
+		signer:          signer,
+		resourceService: resourceService,
+	}
+}
+
+// ServeAvatar serves profile pictures, the only files that are public under /uploads/
+func (h *FileHandler) ServeAvatar(c *gin.Context) {
+	key, err := storage.CleanKey(c.Param("filepath"))
+	if err != nil || !strings.HasPrefix(key, "avatars/") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	h.serveKey(c, key, "")
+}
+
+// ServeSignedFile serves a file addressed by a signed, expiring URL
+func (h *FileHandler) ServeSignedFile(c *gin.Context) {
+	key, err := storage.CleanKey(c.Param("filepath"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	if err := h.signer.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	h.serveKey(c, key, "")
+}
+
+// GetFileURL returns a signed download URL for a room file (message attachment, submission file)
+func (h *FileHandler) GetFileURL(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	key, err := h.resourceService.AuthorizeFileURL(c.Query("file_url"), userID.(string))
+	if errors.Is(err, services.ErrFileAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url, expiresAt := h.signer.SignedURL(key, downloadURLTTL)
+	c.JSON(http.StatusOK, gin.H{"url": url, "expires_at": expiresAt})
+}
+
+func (h *FileHandler) serveKey(c *gin.Context, key, filename string) {
+	reader, info, err := storage.Open(c.Request.Context(), h.store, key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer reader.Close()
+
+	serveObject(c, reader, info, filename)
+}
+
+// serveObject streams a stored object, answering conditional and range requests
+// (needed for seeking in videos)
+func serveObject(c *gin.Context, reader io.ReadSeeker, info *storage.ObjectInfo, filename string) {
+	if filename == "" {
+		filename = path.Base(info.Key)
+	}
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	c.Header("Cache-Control", "private, max-age=300")
+	// Uploaded HTML/SVG must not run scripts in the API's origin
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "sandbox")
+	http.ServeContent(c.Writer, c.Request, filename, info.ModTime, reader)
+}
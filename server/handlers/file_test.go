@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"buddy-server/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileHandler_SignedURLs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := storage.NewLocalStorage(t.TempDir())
+	store.Put(context.Background(), "rooms/r1/lecture.mp4", strings.NewReader("0123456789"), 10, "video/mp4")
+	store.Put(context.Background(), "avatars/u1/face.png", strings.NewReader("png"), 3, "image/png")
+
+	signer := storage.NewURLSigner("test-secret")
+	handler := NewFileHandler(store, signer, nil)
+
+	router := gin.New()
+	router.GET("/uploads/*filepath", handler.ServeAvatar)
+	router.GET("/files/*filepath", handler.ServeSignedFile)
+
+	get := func(url string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Room files are no longer public
+	assert.Equal(t, http.StatusNotFound, get("/uploads/rooms/r1/lecture.mp4", nil).Code)
+	assert.Equal(t, http.StatusOK, get("/uploads/avatars/u1/face.png", nil).Code)
+
+	url, _ := signer.SignedURL("rooms/r1/lecture.mp4", time.Minute)
+	w := get(url, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+
+	// Range requests for seeking in videos
+	w = get(url, http.Header{"Range": {"bytes=4-6"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "456", w.Body.String())
+	assert.Equal(t, "bytes 4-6/10", w.Header().Get("Content-Range"))
+
+	// The signature only covers the key it was issued for
+	tampered := strings.Replace(url, "lecture.mp4", "other.mp4", 1)
+	assert.Equal(t, http.StatusForbidden, get(tampered, nil).Code)
+	assert.Equal(t, http.StatusForbidden, get("/files/rooms/r1/lecture.mp4", nil).Code)
+
+	assert.Equal(t, "Notes.pdf", resourceFileName("Notes", "rooms/r1/123_notes.pdf"))
+	assert.Equal(t, "Notes.PDF", resourceFileName("Notes.PDF", "rooms/r1/123_notes.pdf"))
+}
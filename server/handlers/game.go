@@ -13,6 +13,7 @@ import (
 type GameHandler struct {
 	gameService     *services.GameService
 	templateService *services.GameTemplateService
+	jobService      *services.JobService
 }
 
 // NewGameHandler creates a new game handler
@@ -23,6 +24,11 @@ func NewGameHandler(gameService *services.GameService, templateService *services
 	}
 }
 
+// SetJobService makes game generation run as a background job
+func (h *GameHandler) SetJobService(jobService *services.JobService) {
+	h.jobService = jobService
+}
+
 // GenerateGameRequest represents a game generation request
 type GenerateGameRequest struct {
 	GameType      string `json:"game_type" binding:"required,oneof=quiz flashcards fill_blank fill-blank matching"`
@@ -57,7 +63,23 @@ func (h *GameHandler) GenerateGame(c *gin.Context) {
 		gameType = "fill_blank"
 	}
 
+	if h.jobService != nil {
+		enqueueJob(c, h.jobService, services.JobRequest{
+			Type:   services.JobTypeGame,
+			RoomID: roomID,
+			Params: services.GameJobParams{
+				RoomID:        roomID,
+				GameType:      gameType,
+				Subject:       req.Subject,
+				Difficulty:    req.Difficulty,
+				QuestionCount: questionCount,
+			},
+		})
+		return
+	}
+
 	game, err := h.gameService.GenerateGame(
+		c.Request.Context(),
 		roomID,
 		userID.(string),
 		gameType,
@@ -197,7 +219,7 @@ func (h *GameHandler) DownloadBundle(c *gin.Context) {
 		return
 	}
 
-	bundlePath, err := h.gameService.EnsureBundle(gameID)
+	bundle, info, err := h.gameService.OpenBundle(c.Request.Context(), gameID)
 	if err != nil {
 		if err.Error() == "game not found" || err.Error() == "invalid game ID" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "game not found"})
@@ -206,6 +228,9 @@ func (h *GameHandler) DownloadBundle(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer bundle.Close()
 
-	c.FileAttachment(bundlePath, fmt.Sprintf("game-%s.zip", gameID))
+	c.DataFromReader(http.StatusOK, info.Size, "application/zip", bundle, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="game-%s.zip"`, gameID),
+	})
 }
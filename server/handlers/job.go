@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler reports on background jobs
+type JobHandler struct {
+	jobService *services.JobService
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// GetJob gets the status of one of the user's jobs, with its result once it succeeded
+func (h *JobHandler) GetJob(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	job, err := h.jobService.GetJob(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// enqueueJob queues a job for the current user and responds with it. The
+// Idempotency-Key header makes a retried request return the same job, and
+// ?notify=true asks for a notification when the job finishes.
+func enqueueJob(c *gin.Context, jobService *services.JobService, req services.JobRequest) {
+	userID, _ := c.Get("user_id")
+	req.UserID = userID.(string)
+	req.IdempotencyKey = c.GetHeader("Idempotency-Key")
+	req.Notify, _ = strconv.ParseBool(c.Query("notify"))
+
+	job, err := jobService.Enqueue(req)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// jobErrorStatus maps job service errors to HTTP status codes
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidJob):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrIdempotencyKeyReused):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler handles in-app notification endpoints
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// GetNotifications lists the current user's notifications (?unread=true, ?limit=)
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	unreadOnly := c.Query("unread") == "true"
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+
+	notifications, err := h.notificationService.GetNotifications(userID.(string), unreadOnly, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	unread, err := h.notificationService.CountUnread(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications, "unread_count": unread})
+}
+
+// MarkRead marks a notification as read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.notificationService.MarkRead(c.Param("id"), userID.(string)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllRead marks all of the current user's notifications as read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.notificationService.MarkAllRead(userID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"buddy-server/models"
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PeerReviewHandler handles peer review endpoints
+type PeerReviewHandler struct {
+	peerReviewService *services.PeerReviewService
+}
+
+// NewPeerReviewHandler creates a new peer review handler
+func NewPeerReviewHandler(peerReviewService *services.PeerReviewService) *PeerReviewHandler {
+	return &PeerReviewHandler{
+		peerReviewService: peerReviewService,
+	}
+}
+
+// GetMyReviews returns the reviews assigned to the current student
+func (h *PeerReviewHandler) GetMyReviews(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	tasks, err := h.peerReviewService.GetMyReviews(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+// SubmitReviewRequest represents a peer review submission
+type SubmitReviewRequest struct {
+	Scores  []models.RubricScore `json:"scores" binding:"required"`
+	Comment string               `json:"comment"`
+}
+
+// SubmitReview submits rubric scores for an assigned review
+func (h *PeerReviewHandler) SubmitReview(c *gin.Context) {
+	reviewID := c.Param("review_id")
+	userID, _ := c.Get("user_id")
+
+	var req SubmitReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := h.peerReviewService.SubmitReview(reviewID, userID.(string), req.Scores, req.Comment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// GetPeerReviewSummary returns aggregated peer scores for an assignment (teacher only)
+func (h *PeerReviewHandler) GetPeerReviewSummary(c *gin.Context) {
+	assignmentID := c.Param("assignment_id")
+	teacherID, _ := c.Get("user_id")
+	userRole, _ := c.Get("user_role")
+
+	if userRole != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only teachers can view peer review results"})
+		return
+	}
+
+	summary, err := h.peerReviewService.GetPeerReviewSummary(assignmentID, teacherID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromptHandler lets admins manage the versions of the AI prompts
+type PromptHandler struct {
+	prompts *services.PromptRegistry
+}
+
+// NewPromptHandler creates a new prompt handler
+func NewPromptHandler(prompts *services.PromptRegistry) *PromptHandler {
+	return &PromptHandler{prompts: prompts}
+}
+
+// CreatePromptVersionRequest adds a version of a prompt
+type CreatePromptVersionRequest struct {
+	Text string `json:"text" binding:"required"`
+	Note string `json:"note"`
+}
+
+// GetPrompts lists the prompts and the versions in use
+func (h *PromptHandler) GetPrompts(c *gin.Context) {
+	prompts, err := h.prompts.ListPrompts()
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prompts)
+}
+
+// GetPrompt gets a prompt with all of its versions
+func (h *PromptHandler) GetPrompt(c *gin.Context) {
+	prompt, err := h.prompts.GetPrompt(c.Param("name"))
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prompt)
+}
+
+// CreateVersion adds a version of a prompt without releasing it
+func (h *PromptHandler) CreateVersion(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreatePromptVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, err := h.prompts.CreateVersion(c.Param("name"), req.Text, req.Note, userID.(string))
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, version)
+}
+
+// SetRelease rolls a prompt forward or back to a version, optionally trying
+// a candidate version on a percentage of users
+func (h *PromptHandler) SetRelease(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req services.PromptReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := h.prompts.SetRelease(c.Param("name"), req, userID.(string))
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// Rollback returns a prompt to the version it used before the last release
+func (h *PromptHandler) Rollback(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	summary, err := h.prompts.Rollback(c.Param("name"), userID.(string))
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// promptErrorStatus maps prompt registry errors to HTTP status codes
+func promptErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPromptNotFound), errors.Is(err, services.ErrPromptVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidPrompt):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -11,6 +11,7 @@ import (
 // ReportHandler handles report-related endpoints
 type ReportHandler struct {
 	reportService *services.AIReportService
+	jobService    *services.JobService
 }
 
 // NewReportHandler creates a new report handler
@@ -20,6 +21,11 @@ func NewReportHandler(reportService *services.AIReportService) *ReportHandler {
 	}
 }
 
+// SetJobService makes report generation run as a background job
+func (h *ReportHandler) SetJobService(jobService *services.JobService) {
+	h.jobService = jobService
+}
+
 // GenerateReportRequest represents a report generation request
 type GenerateReportRequest struct {
 	ReportType string `json:"report_type" binding:"required,oneof=daily weekly monthly"`
@@ -40,7 +46,15 @@ func (h *ReportHandler) GenerateReport(c *gin.Context) {
 		return
 	}
 
-	report, err := h.reportService.GenerateStudentReport(userID.(string), req.ReportType)
+	if h.jobService != nil {
+		enqueueJob(c, h.jobService, services.JobRequest{
+			Type:   services.JobTypeReport,
+			Params: services.ReportJobParams{ReportType: req.ReportType},
+		})
+		return
+	}
+
+	report, err := h.reportService.GenerateStudentReport(c.Request.Context(), userID.(string), req.ReportType)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1,26 +1,37 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 	"strings"
 
+	"buddy-server/models"
 	"buddy-server/services"
+	"buddy-server/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
 // ResourceHandler handles resource-related endpoints
 type ResourceHandler struct {
-	resourceService *services.ResourceService
+	resourceService   *services.ResourceService
+	extractionService *services.ResourceExtractionService
+	uploadService     *services.UploadService
+	engagementService *services.ResourceEngagementService
+	signer            *storage.URLSigner
 }
 
 // NewResourceHandler creates a new resource handler
-func NewResourceHandler(resourceService *services.ResourceService) *ResourceHandler {
+func NewResourceHandler(resourceService *services.ResourceService, extractionService *services.ResourceExtractionService, uploadService *services.UploadService, engagementService *services.ResourceEngagementService, signer *storage.URLSigner) *ResourceHandler {
 	return &ResourceHandler{
-		resourceService: resourceService,
+		resourceService:   resourceService,
+		extractionService: extractionService,
+		uploadService:     uploadService,
+		engagementService: engagementService,
+		signer:            signer,
 	}
 }
 
@@ -34,6 +45,8 @@ type CreateResourceRequest struct {
 	FileURL     string   `json:"file_url" binding:"required"` // URL of uploaded file
 	FileType    string   `json:"file_type" binding:"required"` // MIME type
 	FileSize    int64    `json:"file_size"`
+	Tags        []string `json:"tags,omitempty"`      // Free-form tags
+	FolderID    string   `json:"folder_id,omitempty"` // Room folder; empty for the top level
 }
 
 // CreateResource creates a new resource
@@ -53,6 +66,13 @@ func (h *ResourceHandler) CreateResource(c *gin.Context) {
 		return
 	}
 
+	if upload, ok := h.checkRoomUpload(c, roomID, userID.(string), req.Category, req.FileURL); !ok {
+		return
+	} else if upload != nil {
+		req.FileType = upload.ContentType
+		req.FileSize = upload.Size
+	}
+
 	// Use Subjects if provided, otherwise fall back to Subject (legacy)
 	subjects := req.Subjects
 	if len(subjects) == 0 && req.Subject != "" {
@@ -71,7 +91,17 @@ func (h *ResourceHandler) CreateResource(c *gin.Context) {
 		req.Subject, // Legacy support
 		subjects,    // New: multiple subjects
 		uploaderType,
+		req.Tags,
+		req.FolderID,
 	)
+	if errors.Is(err, services.ErrRoomAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrFolderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -80,39 +110,315 @@ func (h *ResourceHandler) CreateResource(c *gin.Context) {
 	c.JSON(http.StatusCreated, resource)
 }
 
-// GetResources gets all resources for a room
+// checkRoomUpload checks that a stored file comes from a clean upload the user
+// made to this room and fits the category. Its type and size should be taken
+// from the returned upload rather than the client; files stored elsewhere
+// return nil.
+func (h *ResourceHandler) checkRoomUpload(c *gin.Context, roomID, userID, category, fileURL string) (*models.Upload, bool) {
+	if _, ok := storage.KeyFromURL(fileURL); !ok {
+		return nil, true
+	}
+	upload, err := h.uploadService.GetUploadByURL(fileURL)
+	if err != nil || upload.RoomID.Hex() != roomID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File was not uploaded to this room"})
+		return nil, false
+	}
+	if uploaded, err := h.uploadService.UploadedBy(roomID, fileURL, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	} else if !uploaded {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File was not uploaded by you"})
+		return nil, false
+	}
+	if upload.ScanStatus == "infected" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": services.ErrMalwareDetected.Error()})
+		return nil, false
+	}
+	if !services.IsUploadTypeAllowed(category, upload.ContentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "This file type is not allowed for the " + category + " category"})
+		return nil, false
+	}
+	return upload, true
+}
+
+// GetResources lists a room's resources. Query parameters: uploader_type,
+// category, folder_id ("root" for unfiled), tags (comma-separated, all must
+// match), subject, q (full-text search), sort, limit and offset.
 func (h *ResourceHandler) GetResources(c *gin.Context) {
-	roomID := c.Param("id")
-	uploaderType := c.Query("uploader_type") // "teacher" or "student"
-	category := c.DefaultQuery("category", "all")
 	userID, _ := c.Get("user_id")
 
-	var userIDStr *string
-	if userID != nil {
-		uid := userID.(string)
-		userIDStr = &uid
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	query := services.ResourceQuery{
+		UploaderType: c.Query("uploader_type"), // "teacher" or "student"
+		Category:     c.DefaultQuery("category", "all"),
+		FolderID:     c.Query("folder_id"),
+		Subject:      c.Query("subject"),
+		Search:       c.Query("q"),
+		Sort:         c.Query("sort"),
+		Limit:        limit,
+		Offset:       offset,
+	}
+	if tags := c.Query("tags"); tags != "" {
+		query.Tags = strings.Split(tags, ",")
+	}
+
+	page, err := h.resourceService.SearchResources(c.Param("id"), userID.(string), query)
+	if errors.Is(err, services.ErrRoomAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrFolderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range page.Resources {
+		h.signPreview(&page.Resources[i])
 	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GetResourceTags lists the tags used in a room with their resource counts
+func (h *ResourceHandler) GetResourceTags(c *gin.Context) {
+	userID, _ := c.Get("user_id")
 
-	resources, err := h.resourceService.GetResources(roomID, uploaderType, category, userIDStr)
+	tags, err := h.resourceService.GetResourceTags(c.Param("id"), userID.(string))
+	if errors.Is(err, services.ErrRoomAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// UpdateResourceRequest changes a resource's details; omitted fields are unchanged
+type UpdateResourceRequest struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Subjects    *[]string `json:"subjects"`
+	Tags        *[]string `json:"tags"`
+	FolderID    *string   `json:"folder_id"` // "" moves the resource to the top level
+}
+
+// UpdateResource renames, retags or moves a resource (uploader or room owner)
+func (h *ResourceHandler) UpdateResource(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req UpdateResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resource, err := h.resourceService.UpdateResource(c.Param("resource_id"), userID.(string), services.ResourceUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		Subjects:    req.Subjects,
+		Tags:        req.Tags,
+		FolderID:    req.FolderID,
+	})
+	if errors.Is(err, services.ErrResourceNotFound) || errors.Is(err, services.ErrFolderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, resources)
+	h.signPreview(resource)
+	c.JSON(http.StatusOK, resource)
+}
+
+// signPreview fills in a signed URL for the resource's thumbnail
+func (h *ResourceHandler) signPreview(resource *models.Resource) {
+	if resource.Preview != nil && resource.Preview.ThumbnailKey != "" {
+		resource.Preview.ThumbnailURL, _ = h.signer.SignedURL(resource.Preview.ThumbnailKey, previewURLTTL)
+	}
 }
 
 // GetResource gets a single resource by ID
 func (h *ResourceHandler) GetResource(c *gin.Context) {
-	resourceID := c.Param("resource_id")
+	resource, ok := h.resourceForUser(c)
+	if !ok {
+		return
+	}
+
+	h.signPreview(resource)
+	c.JSON(http.StatusOK, resource)
+}
+
+// GetResourceText returns the text extracted from a resource's file
+func (h *ResourceHandler) GetResourceText(c *gin.Context) {
+	resource, ok := h.resourceForUser(c)
+	if !ok {
+		return
+	}
 
-	resource, err := h.resourceService.GetResource(resourceID)
+	text, err := h.extractionService.GetResourceText(resource)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, resource)
+	c.JSON(http.StatusOK, text)
+}
+
+// DownloadResource streams a resource's file to a user allowed to see it
+func (h *ResourceHandler) DownloadResource(c *gin.Context) {
+	resource, ok := h.resourceForUser(c)
+	if !ok {
+		return
+	}
+
+	reader, info, err := h.resourceService.OpenResourceFile(c.Request.Context(), resource)
+	if err == storage.ErrNotFound {
+		h.fileUnavailable(c, resource.FileURL)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer reader.Close()
+
+	h.recordDownload(c, resource)
+	serveObject(c, reader, info, resourceFileName(resource.Name, info.Key))
+}
+
+// GetResourceDownloadURL returns a short-lived signed URL for a resource's file,
+// usable without an Authorization header (e.g. as a <video> source). It counts
+// as a download, or as a view with ?for=view when the file is shown in the app.
+func (h *ResourceHandler) GetResourceDownloadURL(c *gin.Context) {
+	resource, ok := h.resourceForUser(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.resourceService.ResourceFileKey(resource)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if upload, err := h.uploadService.GetUploadByURL(resource.FileURL); err == nil && upload.ScanStatus != "clean" {
+		h.fileUnavailable(c, resource.FileURL)
+		return
+	}
+
+	if c.Query("for") == "view" {
+		h.recordEngagement(c, resource, "view")
+	} else {
+		h.recordEngagement(c, resource, "download")
+	}
+	url, expiresAt := h.signer.SignedURL(key, downloadURLTTL)
+	c.JSON(http.StatusOK, gin.H{"url": url, "expires_at": expiresAt})
+}
+
+// EngagementRequest reports use of a resource seen by the client: a view, or
+// how far a video has been played
+type EngagementRequest struct {
+	Event           string  `json:"event" binding:"required"` // "view", "download" or "progress"
+	PositionSeconds float64 `json:"position_seconds"`         // progress: current playback position
+	DurationSeconds float64 `json:"duration_seconds"`         // progress: length of the video
+}
+
+// RecordEngagement records a view, download or video progress for the current user
+func (h *ResourceHandler) RecordEngagement(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req EngagementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resource, ok := h.resourceForUser(c)
+	if !ok {
+		return
+	}
+
+	var err error
+	if req.Event == "progress" {
+		err = h.engagementService.RecordProgress(resource, userID.(string), req.PositionSeconds, req.DurationSeconds)
+	} else {
+		err = h.engagementService.RecordEvent(resource, userID.(string), req.Event)
+	}
+	if errors.Is(err, services.ErrInvalidEngagement) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// recordEngagement records a view or download served by the API; failures
+// are logged, never keeping the user from the file
+func (h *ResourceHandler) recordEngagement(c *gin.Context, resource *models.Resource, event string) {
+	userID, _ := c.Get("user_id")
+	if err := h.engagementService.RecordEvent(resource, userID.(string), event); err != nil {
+		log.Printf("Failed to record %s of resource %s: %v", event, resource.ID.Hex(), err)
+	}
+}
+
+// recordDownload counts a download served by the API. Players fetch videos in
+// many ranges; only the request for the start counts.
+func (h *ResourceHandler) recordDownload(c *gin.Context, resource *models.Resource) {
+	if rangeHeader := c.GetHeader("Range"); rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-") {
+		h.recordEngagement(c, resource, "download")
+	}
+}
+
+// fileUnavailable explains why a resource's file can't be served: still being
+// scanned, rejected by the scanner, or missing
+func (h *ResourceHandler) fileUnavailable(c *gin.Context, fileURL string) {
+	upload, err := h.uploadService.GetUploadByURL(fileURL)
+	switch {
+	case err == nil && upload.ScanStatus == "pending":
+		c.JSON(http.StatusConflict, gin.H{"error": "File is awaiting a malware scan, try again shortly", "scan_status": upload.ScanStatus})
+	case err == nil && upload.ScanStatus == "infected":
+		c.JSON(http.StatusGone, gin.H{"error": services.ErrMalwareDetected.Error(), "scan_status": upload.ScanStatus})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	}
+}
+
+// resourceForUser loads the requested resource, writing a 404/403 response if
+// it doesn't exist or the current user may not access it
+func (h *ResourceHandler) resourceForUser(c *gin.Context) (*models.Resource, bool) {
+	userID, _ := c.Get("user_id")
+
+	resource, err := h.resourceService.GetResourceForUser(c.Param("resource_id"), userID.(string))
+	if errors.Is(err, services.ErrFileAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		return nil, false
+	}
+	return resource, true
+}
+
+// resourceFileName names a download after the resource, keeping the file's extension
+func resourceFileName(name, key string) string {
+	ext := path.Ext(key)
+	if name == "" {
+		return path.Base(key)
+	}
+	if ext != "" && !strings.HasSuffix(strings.ToLower(name), strings.ToLower(ext)) {
+		return name + ext
+	}
+	return name
 }
 
 // DeleteResource deletes a resource
@@ -129,6 +435,129 @@ func (h *ResourceHandler) DeleteResource(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Resource deleted successfully"})
 }
 
+// AddVersionRequest represents a new file version for a resource
+type AddVersionRequest struct {
+	FileURL    string `json:"file_url" binding:"required"` // URL of uploaded file
+	FileType   string `json:"file_type"`                   // Ignored for uploaded files
+	FileSize   int64  `json:"file_size"`
+	ChangeNote string `json:"change_note"` // e.g. "Fixed typo on page 3"
+}
+
+// AddResourceVersion replaces a resource's file with a new version (uploader only)
+func (h *ResourceHandler) AddResourceVersion(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req AddVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resource, err := h.resourceService.GetResource(c.Param("resource_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		return
+	}
+	if upload, ok := h.checkRoomUpload(c, resource.RoomID.Hex(), userID.(string), resource.Category, req.FileURL); !ok {
+		return
+	} else if upload != nil {
+		req.FileType = upload.ContentType
+		req.FileSize = upload.Size
+	}
+
+	resource, err = h.resourceService.AddResourceVersion(resource.ID.Hex(), userID.(string), req.FileURL, req.FileType, req.FileSize, req.ChangeNote)
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resource)
+}
+
+// GetResourceVersions lists a resource's versions, newest first
+func (h *ResourceHandler) GetResourceVersions(c *gin.Context) {
+	resource, ok := h.resourceForUser(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.resourceService.GetResourceVersions(resource.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"current_version": resource.Version, "versions": versions})
+}
+
+// RestoreResourceVersion makes an earlier version current again (uploader only)
+func (h *ResourceHandler) RestoreResourceVersion(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	resource, err := h.resourceService.RestoreResourceVersion(c.Param("resource_id"), userID.(string), version)
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resource)
+}
+
+// DownloadResourceVersion streams the file of one version of a resource
+func (h *ResourceHandler) DownloadResourceVersion(c *gin.Context) {
+	resource, ok := h.resourceForUser(c)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+	v, err := h.resourceService.GetResourceVersion(resource.ID.Hex(), version)
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	versioned := *resource
+	versioned.FileURL = v.FileURL
+	reader, info, err := h.resourceService.OpenResourceFile(c.Request.Context(), &versioned)
+	if err == storage.ErrNotFound {
+		h.fileUnavailable(c, v.FileURL)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer reader.Close()
+
+	h.recordDownload(c, resource)
+	serveObject(c, reader, info, resourceFileName(resource.Name, info.Key))
+}
+
+// versionErrorStatus maps resource version errors to HTTP statuses
+func versionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrResourceNotFound), errors.Is(err, services.ErrVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrVersionUnchanged):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // ShareResourceRequest represents a resource sharing request
 type ShareResourceRequest struct {
 	SharedWith []string `json:"shared_with"` // Array of user IDs
@@ -158,39 +587,79 @@ func (h *ResourceHandler) ShareResource(c *gin.Context) {
 // UploadFile handles file upload
 func (h *ResourceHandler) UploadFile(c *gin.Context) {
 	roomID := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	// Only the room's members can store files in it; check before the body is read
+	if err := h.uploadService.CheckRoomAccess(roomID, userID.(string)); err != nil {
+		if errors.Is(err, services.ErrRoomAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// Refuse oversized bodies before they are spooled to disk
+	if limit := h.uploadService.Limits().MaxFileSize; limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1<<20)
+	}
 
 	// Get file from form
 	file, err := c.FormFile("file")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrFileTooLarge.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
 		return
 	}
 
-	// Create uploads directory if it doesn't exist
-	uploadDir := filepath.Join("uploads", "rooms", roomID)
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
 		return
 	}
+	defer src.Close()
 
-	// Generate unique filename
-	filename := fmt.Sprintf("%d_%s", file.Size, file.Filename)
-	filename = strings.ReplaceAll(filename, " ", "_")
-	filePath := filepath.Join(uploadDir, filename)
-
-	// Save file
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+	// Save file (the optional category narrows the allowed file types)
+	upload, duplicate, err := h.uploadService.SaveRoomFile(roomID, userID.(string), file.Filename, c.PostForm("category"), src, file.Size)
+	switch {
+	case errors.Is(err, services.ErrFileTooLarge), errors.Is(err, services.ErrQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrFileTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrMalwareDetected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 		return
 	}
 
-	// Return file URL (relative path)
-	fileURL := fmt.Sprintf("/uploads/rooms/%s/%s", roomID, filename)
-
 	c.JSON(http.StatusOK, gin.H{
-		"file_url":  fileURL,
-		"file_name": file.Filename,
-		"file_size": file.Size,
-		"file_type": file.Header.Get("Content-Type"),
+		"file_url":    upload.FileURL,
+		"file_name":   file.Filename,
+		"file_size":   upload.Size,
+		"file_type":   upload.ContentType,
+		"hash":        upload.Hash,
+		"scan_status": upload.ScanStatus,
+		"duplicate":   duplicate,
 	})
 }
+
+// GetStorageUsage returns the room's and current user's storage use and quotas
+func (h *ResourceHandler) GetStorageUsage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	usage, err := h.uploadService.GetStorageUsage(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResourceFolderHandler handles the folder hierarchy of room resources
+type ResourceFolderHandler struct {
+	folderService *services.ResourceFolderService
+}
+
+// NewResourceFolderHandler creates a new resource folder handler
+func NewResourceFolderHandler(folderService *services.ResourceFolderService) *ResourceFolderHandler {
+	return &ResourceFolderHandler{folderService: folderService}
+}
+
+// FolderRequest creates or changes a folder; on update omitted fields are unchanged
+type FolderRequest struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parent_id"` // "" for the top level
+}
+
+// GetFolders lists a room's folders (room members)
+func (h *ResourceFolderHandler) GetFolders(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	folders, err := h.folderService.GetFolders(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, folders)
+}
+
+// CreateFolder creates a folder (room owner)
+func (h *ResourceFolderHandler) CreateFolder(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	parentID := ""
+	if req.ParentID != nil {
+		parentID = *req.ParentID
+	}
+
+	folder, err := h.folderService.CreateFolder(c.Param("id"), userID.(string), *req.Name, parentID)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, folder)
+}
+
+// UpdateFolder renames or moves a folder (room owner)
+func (h *ResourceFolderHandler) UpdateFolder(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.folderService.UpdateFolder(c.Param("id"), c.Param("folder_id"), userID.(string), req.Name, req.ParentID)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder deletes a folder, moving its contents up a level (room owner)
+func (h *ResourceFolderHandler) DeleteFolder(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.folderService.DeleteFolder(c.Param("id"), c.Param("folder_id"), userID.(string)); err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted"})
+}
+
+// folderErrorStatus maps folder service errors to HTTP status codes
+func folderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrRoomAccessDenied), errors.Is(err, services.ErrFolderPermission):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrFolderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrFolderExists):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,6 +16,7 @@ import (
 // RoomHandler handles room-related endpoints
 type RoomHandler struct {
 	roomService *services.RoomService
+	jobService  *services.JobService
 }
 
 // NewRoomHandler creates a new room handler
@@ -24,6 +26,11 @@ func NewRoomHandler(roomService *services.RoomService) *RoomHandler {
 	}
 }
 
+// SetJobService makes room AI training run as a background job
+func (h *RoomHandler) SetJobService(jobService *services.JobService) {
+	h.jobService = jobService
+}
+
 // WeeklyScheduleRequest represents a weekly schedule entry
 type WeeklyScheduleRequest struct {
 	Day       string `json:"day"`
@@ -339,9 +346,10 @@ func (h *RoomHandler) GetMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, messages)
 }
 
-// TrainRoomAI trains the room's AI with selected resources
+// TrainRoomAI trains the room's AI with selected resources (room owner)
 func (h *RoomHandler) TrainRoomAI(c *gin.Context) {
 	roomID := c.Param("id")
+	userID, _ := c.Get("user_id")
 	
 	var req struct {
 		ResourceIDs []string `json:"resource_ids" binding:"required"`
@@ -352,9 +360,23 @@ func (h *RoomHandler) TrainRoomAI(c *gin.Context) {
 		return
 	}
 	
-	context, err := h.roomService.TrainRoomAI(roomID, req.ResourceIDs)
+	if err := h.roomService.RequireRoomAIOwner(roomID, userID.(string)); err != nil {
+		c.JSON(roomAISettingsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	
+	if h.jobService != nil {
+		enqueueJob(c, h.jobService, services.JobRequest{
+			Type:   services.JobTypeRoomAITraining,
+			RoomID: roomID,
+			Params: services.RoomAITrainingJobParams{ResourceIDs: req.ResourceIDs},
+		})
+		return
+	}
+	
+	context, err := h.roomService.TrainRoomAI(c.Request.Context(), roomID, userID.(string), req.ResourceIDs)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(roomAISettingsErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	
@@ -374,13 +396,102 @@ func (h *RoomHandler) ChatWithRoomAI(c *gin.Context) {
 		return
 	}
 	
-	response, err := h.roomService.ChatWithRoomAI(roomID, req.Message)
+	answer, err := h.roomService.ChatWithRoomAIStream(c.Request.Context(), roomID, req.Message, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(roomAIChatErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{"response": response})
+	c.JSON(http.StatusOK, answer)
+}
+
+// ChatWithRoomAIStream streams the room AI's answer as server-sent events; the
+// final event carries the whole answer with its citations
+func (h *RoomHandler) ChatWithRoomAIStream(c *gin.Context) {
+	roomID := c.Param("id")
+
+	var req struct {
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := newSSEStream(c)
+	stream.errorStatus = roomAIChatErrorStatus
+	answer, err := h.roomService.ChatWithRoomAIStream(c.Request.Context(), roomID, req.Message, stream.Delta)
+	stream.Finish(answer, err)
+}
+
+// roomAIChatErrorStatus maps room AI chat errors to HTTP status codes
+func roomAIChatErrorStatus(err error) int {
+	if errors.Is(err, services.ErrRoomAIBlackout) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// ClearRoomAICache drops the room AI's cached answers (room owner)
+func (h *RoomHandler) ClearRoomAICache(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	removed, err := h.roomService.ClearRoomAICache(c.Param("id"), userID.(string))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrRoomAIPermission) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Room AI cache cleared", "removed": removed})
+}
+
+// GetRoomAISettings gets how the room AI behaves (room members)
+func (h *RoomHandler) GetRoomAISettings(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	settings, err := h.roomService.GetRoomAISettings(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(roomAISettingsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateRoomAISettings changes how the room AI behaves, e.g. requiring
+// students to be tutored (room owner)
+func (h *RoomHandler) UpdateRoomAISettings(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req models.RoomAISettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.roomService.UpdateRoomAISettings(c.Param("id"), userID.(string), req)
+	if err != nil {
+		c.JSON(roomAISettingsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// roomAISettingsErrorStatus maps room AI settings errors to HTTP status codes
+func roomAISettingsErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrRoomAIPermission), errors.Is(err, services.ErrRoomAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidRoomAISettings):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // GetRoomAIStatus gets the AI training status for a room
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimilarityHandler handles submission similarity endpoints
+type SimilarityHandler struct {
+	similarityService *services.SimilarityService
+}
+
+// NewSimilarityHandler creates a new similarity handler
+func NewSimilarityHandler(similarityService *services.SimilarityService) *SimilarityHandler {
+	return &SimilarityHandler{
+		similarityService: similarityService,
+	}
+}
+
+// StartSimilarityRequest represents a similarity analysis request
+type StartSimilarityRequest struct {
+	Threshold float64 `json:"threshold"` // 0-1 Jaccard similarity; 0 = default
+}
+
+// StartAnalysis starts a similarity analysis job for an assignment (teacher only)
+func (h *SimilarityHandler) StartAnalysis(c *gin.Context) {
+	assignmentID := c.Param("assignment_id")
+	teacherID, _ := c.Get("user_id")
+	userRole, _ := c.Get("user_role")
+
+	if userRole != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only teachers can run similarity analysis"})
+		return
+	}
+
+	var req StartSimilarityRequest
+	// Body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	report, err := h.similarityService.StartAnalysis(assignmentID, teacherID.(string), req.Threshold)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, report)
+}
+
+// GetLatestReport returns the latest similarity report for an assignment (teacher only)
+func (h *SimilarityHandler) GetLatestReport(c *gin.Context) {
+	assignmentID := c.Param("assignment_id")
+	teacherID, _ := c.Get("user_id")
+	userRole, _ := c.Get("user_role")
+
+	if userRole != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only teachers can view similarity reports"})
+		return
+	}
+
+	report, err := h.similarityService.GetLatestReport(assignmentID, teacherID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
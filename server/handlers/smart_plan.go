@@ -12,6 +12,7 @@ import (
 // SmartPlanHandler handles smart study plan endpoints
 type SmartPlanHandler struct {
 	smartPlanService *services.SmartPlanService
+	jobService       *services.JobService
 }
 
 // NewSmartPlanHandler creates a new smart plan handler
@@ -21,6 +22,11 @@ func NewSmartPlanHandler(smartPlanService *services.SmartPlanService) *SmartPlan
 	}
 }
 
+// SetJobService makes plan generation run as a background job
+func (h *SmartPlanHandler) SetJobService(jobService *services.JobService) {
+	h.jobService = jobService
+}
+
 // GenerateSmartPlanRequest represents the request to generate a smart plan
 type GenerateSmartPlanRequest struct {
 	Subject     string  `json:"subject" binding:"required"`
@@ -39,7 +45,25 @@ func (h *SmartPlanHandler) GenerateSmartPlan(c *gin.Context) {
 		return
 	}
 
+	if h.jobService != nil {
+		enqueueJob(c, h.jobService, services.JobRequest{
+			Type: services.JobTypeSmartPlan,
+			Params: services.SmartPlanJobParams{
+				Subject:     req.Subject,
+				Goals:       req.Goals,
+				Description: req.Description,
+				WeeklyHours: req.WeeklyHours,
+				StartDate:   req.StartDate,
+				EndDate:     req.EndDate,
+			},
+		})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
 	plan, err := h.smartPlanService.GenerateSmartPlan(
+		c.Request.Context(),
+		userID.(string),
 		req.Subject,
 		req.Goals,
 		req.Description,
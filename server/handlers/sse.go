@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseStream sends generated text as server-sent events: "delta" events with
+// {"text": ...} while generating, then one "done" event with the result or an
+// "error" event. The stream starts with the first event, so an error before
+// any text is still sent as a normal JSON error response.
+type sseStream struct {
+	c       *gin.Context
+	started bool
+
+	// errorStatus picks the status of an error sent before the stream starts; 500 when nil
+	errorStatus func(error) int
+}
+
+func newSSEStream(c *gin.Context) *sseStream {
+	return &sseStream{c: c}
+}
+
+func (s *sseStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	header := s.c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Stop reverse proxies from buffering the stream
+	s.c.Status(http.StatusOK)
+}
+
+// Delta sends a piece of generated text. It fails once the client has
+// disconnected, which stops the generation.
+func (s *sseStream) Delta(text string) error {
+	if err := s.c.Request.Context().Err(); err != nil {
+		return err
+	}
+	s.start()
+	s.c.SSEvent("delta", gin.H{"text": text})
+	s.c.Writer.Flush()
+	return nil
+}
+
+// Finish ends the stream with the result, or with err when generation failed
+func (s *sseStream) Finish(result interface{}, err error) {
+	if s.c.Request.Context().Err() != nil {
+		// The client disconnected; nobody is listening any more
+		return
+	}
+	if err != nil {
+		if !s.started {
+			status := http.StatusInternalServerError
+			if s.errorStatus != nil {
+				status = s.errorStatus(err)
+			}
+			s.c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("AI stream failed: %v", err)
+		s.c.SSEvent("error", gin.H{"error": err.Error()})
+		s.c.Writer.Flush()
+		return
+	}
+	s.start()
+	s.c.SSEvent("done", result)
+	s.c.Writer.Flush()
+}
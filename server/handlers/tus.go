@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"buddy-server/models"
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusBasePath is where resumable upload sessions are addressed
+const tusBasePath = "/api/uploads/tus/"
+
+// TusHandler serves the tus 1.0 resumable upload protocol
+type TusHandler struct {
+	tusService    *services.TusService
+	uploadService *services.UploadService
+}
+
+// NewTusHandler creates a new tus handler
+func NewTusHandler(tusService *services.TusService, uploadService *services.UploadService) *TusHandler {
+	return &TusHandler{
+		tusService:    tusService,
+		uploadService: uploadService,
+	}
+}
+
+// RequireTusVersion rejects requests for tus versions other than 1.0.0
+func (h *TusHandler) RequireTusVersion(c *gin.Context) {
+	c.Header("Tus-Resumable", services.TusVersion)
+	if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != services.TusVersion {
+		c.Header("Tus-Version", services.TusVersion)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported tus version"})
+		return
+	}
+	c.Next()
+}
+
+// Options describes the server's tus capabilities
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header("Tus-Version", services.TusVersion)
+	c.Header("Tus-Extension", services.TusExtensions)
+	if maxSize := h.uploadService.Limits().MaxFileSize; maxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Create starts an upload (tus creation extension). Upload-Metadata must
+// include room_id and filename; name, category, description and subjects
+// create a resource once the upload completes.
+func (h *TusHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	userRole, _ := c.Get("user_role")
+
+	uploaderType := "student"
+	if userRole == "teacher" {
+		uploaderType = "teacher"
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Defer-Length is not supported"})
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length"})
+		return
+	}
+	metadata, err := services.ParseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.tusService.CreateSession(userID.(string), uploaderType, length, metadata)
+	if errors.Is(err, services.ErrFileTooLarge) || errors.Is(err, services.ErrQuotaExceeded) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrResourceNotFound) || errors.Is(err, services.ErrFolderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrRoomAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", tusBasePath+session.ID.Hex())
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// Head reports how much of an upload the server has received
+func (h *TusHandler) Head(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	session, err := h.tusService.GetSession(c.Param("upload_id"), userID.(string))
+	if err != nil {
+		c.Status(tusErrorStatus(err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	setUploadExpires(c, session)
+	c.Status(http.StatusOK)
+}
+
+// Patch appends a chunk at Upload-Offset
+func (h *TusHandler) Patch(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Offset"})
+		return
+	}
+
+	session, err := h.tusService.WriteChunk(c.Param("upload_id"), userID.(string), offset, c.Request.Body)
+	if session != nil {
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	}
+	if err != nil && (session == nil || session.Offset == offset) {
+		c.JSON(tusErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Partial chunks (connection dropped mid-request) were kept: report the new offset
+	setUploadExpires(c, session)
+	c.Status(http.StatusNoContent)
+}
+
+// Terminate cancels an upload (tus termination extension)
+func (h *TusHandler) Terminate(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.tusService.Terminate(c.Param("upload_id"), userID.(string)); err != nil {
+		c.JSON(tusErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetStatus returns an upload session as JSON, including the file URL and
+// resource ID once processing has finished
+func (h *TusHandler) GetStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	session, err := h.tusService.GetSession(c.Param("upload_id"), userID.(string))
+	if err != nil {
+		c.JSON(tusErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func setUploadExpires(c *gin.Context, session *models.UploadSession) {
+	if session.Status == "uploading" {
+		c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func tusErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrUploadExpired):
+		return http.StatusGone
+	case errors.Is(err, services.ErrUploadOffsetMismatch), errors.Is(err, services.ErrUploadComplete):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUploadExceedsLength):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
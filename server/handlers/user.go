@@ -91,6 +91,32 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
 
+// UploadAvatar uploads a new avatar image for the current user
+func (h *UserHandler) UploadAvatar(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer src.Close()
+
+	avatarURL, err := h.userService.SaveAvatar(userID.(string), src, file.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"avatar_url": avatarURL})
+}
+
 // GetUserStats gets user statistics
 func (h *UserHandler) GetUserStats(c *gin.Context) {
 	userID := c.Param("id")
@@ -2,12 +2,14 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"buddy-server/config"
 	"buddy-server/database"
 	"buddy-server/handlers"
 	"buddy-server/middleware"
 	"buddy-server/services"
+	"buddy-server/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,15 +22,48 @@ func main() {
 	db := database.Connect(cfg.MongoURI)
 	defer db.Close()
 
+	// Open file storage (local disk or S3-compatible bucket)
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+	urlSigner := storage.NewURLSigner(cfg.DownloadURLSecret)
+
 	// Initialize services
 	authService := services.NewAuthService(db, cfg.JWTSecret)
-	userService := services.NewUserService(db)
+	userService := services.NewUserService(db, store)
 	rewardEngine := services.NewRewardEngine(db)
 	_ = rewardEngine.EnsureDefaultBadges()
 	rewardService := services.NewRewardService(db, rewardEngine)
 	roomService := services.NewRoomService(db, rewardService)
 	studyPlanService := services.NewStudyPlanService(db)
-	resourceService := services.NewResourceService(db)
+	resourceService := services.NewResourceService(db, store)
+	resourceExtractionService := services.NewResourceExtractionService(db, store)
+	resourceExtractionService.Start(2, 15*time.Minute)
+	resourceService.SetExtractionService(resourceExtractionService)
+	resourcePreviewService := services.NewResourcePreviewService(db, store)
+	resourcePreviewService.Start(1, 15*time.Minute)
+	resourceService.SetPreviewService(resourcePreviewService)
+	malwareScanner, err := services.NewMalwareScanner(cfg.ClamdAddress)
+	if err != nil {
+		log.Fatal("Failed to initialize malware scanner:", err)
+	}
+	if cfg.ClamdAddress == "" {
+		log.Println("Warning: CLAMD_ADDRESS not set, uploads will not be scanned for malware")
+	}
+	uploadService := services.NewUploadService(db, store, malwareScanner, services.UploadLimits{
+		MaxFileSize: cfg.MaxUploadSize,
+		RoomQuota:   cfg.RoomStorageQuota,
+		UserQuota:   cfg.UserStorageQuota,
+	})
+	uploadService.SetExtractionService(resourceExtractionService)
+	uploadService.SetPreviewService(resourcePreviewService)
+	uploadService.Start(5 * time.Minute)
+	resourceService.SetUploadService(uploadService)
+	notificationService := services.NewNotificationService(db)
+	resourceService.SetNotificationService(notificationService)
+	tusService := services.NewTusService(db, store, uploadService, resourceService)
+	tusService.Start(time.Hour)
 	assignmentService := services.NewAssignmentService(db, roomService)
 	// Set assignment service in room service to avoid circular dependency
 	roomService.SetAssignmentService(assignmentService)
@@ -37,55 +72,116 @@ func main() {
 	badgeService := services.NewBadgeService(db)
 	activityService := services.NewActivityService(db, rewardService, userService)
 	friendService := services.NewFriendService(db)
-
-	geminiService, err := services.NewGeminiService(cfg.GeminiAPIKey)
-	if err != nil {
-		log.Println("Warning: Gemini service initialization failed:", err)
-		log.Println("AI features will be unavailable")
-	}
-	defer func() {
-		if geminiService != nil {
-			geminiService.Close()
+	similarityService := services.NewSimilarityService(db, assignmentService, store)
+	peerReviewService := services.NewPeerReviewService(db, assignmentService, rewardService)
+	peerReviewService.StartScheduler(5 * time.Minute)
+
+	// Each AI feature gets the provider and model configured for it, metered
+	// so token usage can be reported and limited, cached if it opted in and
+	// held to the safety policy for students
+	llmProviders := services.NewLLMProviders(cfg.LLM)
+	defer llmProviders.Close()
+	aiUsageService := services.NewAIUsageService(db, cfg.AIQuota)
+	aiCache := services.NewAICache(cfg.AICache)
+	aiSafetyService := services.NewAISafetyService(db, cfg.AISafety)
+	promptRegistry := services.NewPromptRegistry(db)
+	aiServiceFor := func(feature string) *services.AIService {
+		provider, err := llmProviders.For(feature)
+		if err != nil {
+			log.Printf("Warning: %s AI unavailable: %v", feature, err)
+			return nil
 		}
-	}()
+		log.Printf("%s AI uses %s", feature, provider.Name())
+		metered := aiUsageService.Meter(feature, provider)
+		aiService := services.NewAIService(aiSafetyService.Guard(feature, aiCache.Wrap(feature, metered)))
+		aiService.SetPrompts(promptRegistry)
+		return aiService
+	}
+	gemini, _ := llmProviders.Gemini()
 
 	// Initialize AI-powered services
 	activityQueryService := services.NewActivityQueryService(db)
-	productivityService := services.NewProductivityService(db, geminiService)
-	aiReportService := services.NewAIReportService(db, geminiService, activityQueryService, productivityService)
-	goalSuggestionService := services.NewGoalSuggestionService(db, geminiService, activityQueryService, productivityService)
-	roomAIService := services.NewRoomAIService(db, geminiService)
+	productivityService := services.NewProductivityService(db, aiServiceFor(services.LLMFeatureProductivity))
+	aiReportService := services.NewAIReportService(db, aiServiceFor(services.LLMFeatureReports), activityQueryService, productivityService)
+	goalSuggestionService := services.NewGoalSuggestionService(db, aiServiceFor(services.LLMFeatureGoalSuggestions), activityQueryService, productivityService)
+	roomAIService := services.NewRoomAIService(db, aiServiceFor(services.LLMFeatureRoomAI))
+	roomAIService.SetExtractionService(resourceExtractionService)
+	embeddingProvider, err := services.NewEmbeddingProvider(cfg.EmbeddingProvider, gemini)
+	if err != nil {
+		log.Println("Warning: embedding provider unavailable, using local embeddings:", err)
+		embeddingProvider = services.NewHashEmbeddingProvider(0)
+	}
+	roomIndexService := services.NewRoomIndexService(db, embeddingProvider)
+	roomAIService.SetIndexService(roomIndexService)
+	roomAIService.SetCache(aiCache)
+	resourceService.SetIndexService(roomIndexService)
 	
 	// Initialize game services
 	gameTemplateService := services.NewGameTemplateService()
-	gamePackager := services.NewGamePackager("./uploads")
-	gameService := services.NewGameService(db, geminiService, gameTemplateService, gamePackager)
+	gamePackager := services.NewGamePackager(store)
+	gameService := services.NewGameService(db, aiServiceFor(services.LLMFeatureGames), gameTemplateService, gamePackager)
 	multiplayerService := services.NewMultiplayerService(db)
 	gameAnalyticsService := services.NewGameAnalyticsService(db)
+	resourceEngagementService := services.NewResourceEngagementService(db)
 	
-	smartPlanService := services.NewSmartPlanService(db, geminiService, studyPlanService, goalService)
+	smartPlanService := services.NewSmartPlanService(db, aiServiceFor(services.LLMFeatureSmartPlan), studyPlanService, goalService)
 	
 	// Set room AI service (to avoid circular dependency)
 	roomService.SetRoomAIService(roomAIService)
 
+	// Slow AI generation runs as background jobs
+	jobService := services.NewJobService(db)
+	jobService.SetNotificationService(notificationService)
+	if aiReportService != nil {
+		jobService.Register(services.JobTypeReport, aiReportService.RunReportJob)
+	}
+	if gameService != nil {
+		jobService.Register(services.JobTypeGame, gameService.RunGameJob)
+	}
+	if smartPlanService != nil {
+		jobService.Register(services.JobTypeSmartPlan, smartPlanService.RunSmartPlanJob)
+	}
+	if roomAIService != nil {
+		jobService.Register(services.JobTypeRoomAITraining, roomService.RunRoomAITrainingJob)
+	}
+	jobService.Start(2, 30*time.Second)
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
 	roomHandler := handlers.NewRoomHandler(roomService)
 	studyPlanHandler := handlers.NewStudyPlanHandler(studyPlanService)
-	resourceHandler := handlers.NewResourceHandler(resourceService)
+	resourceFolderHandler := handlers.NewResourceFolderHandler(services.NewResourceFolderService(db))
+	resourceHandler := handlers.NewResourceHandler(resourceService, resourceExtractionService, uploadService, resourceEngagementService, urlSigner)
 	assignmentHandler := handlers.NewAssignmentHandler(assignmentService)
 	goalHandler := handlers.NewGoalHandler(goalService, goalSuggestionService)
 	leaderboardHandler := handlers.NewLeaderboardHandler(leaderboardService)
 	badgeHandler := handlers.NewBadgeHandler(badgeService)
 	activityHandler := handlers.NewActivityHandler(activityService, activityQueryService, productivityService)
 	friendHandler := handlers.NewFriendHandler(friendService)
-	aiHandler := handlers.NewAIHandler(geminiService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	similarityHandler := handlers.NewSimilarityHandler(similarityService)
+	peerReviewHandler := handlers.NewPeerReviewHandler(peerReviewService)
+	chatAI := aiServiceFor(services.LLMFeatureChat)
+	aiHandler := handlers.NewAIHandler(chatAI)
+	aiThreadService := services.NewAIThreadService(db, chatAI, roomAIService)
+	aiHandler.SetThreadService(aiThreadService)
+	aiThreadHandler := handlers.NewAIThreadHandler(aiThreadService)
+	aiUsageHandler := handlers.NewAIUsageHandler(aiUsageService)
+	aiSafetyHandler := handlers.NewAISafetyHandler(aiSafetyService)
+	promptHandler := handlers.NewPromptHandler(promptRegistry)
 	reportHandler := handlers.NewReportHandler(aiReportService)
 	gameHandler := handlers.NewGameHandler(gameService, gameTemplateService)
 	matchHandler := handlers.NewMatchHandler(multiplayerService)
-	analyticsHandler := handlers.NewAnalyticsHandler(gameAnalyticsService)
+	analyticsHandler := handlers.NewAnalyticsHandler(gameAnalyticsService, resourceEngagementService)
 	smartPlanHandler := handlers.NewSmartPlanHandler(smartPlanService)
+	fileHandler := handlers.NewFileHandler(store, urlSigner, resourceService)
+	tusHandler := handlers.NewTusHandler(tusService, uploadService)
+	jobHandler := handlers.NewJobHandler(jobService)
+	reportHandler.SetJobService(jobService)
+	gameHandler.SetJobService(jobService)
+	smartPlanHandler.SetJobService(jobService)
+	roomHandler.SetJobService(jobService)
 
 	// Initialize Gin router
 	if cfg.Env == "production" {
@@ -97,8 +193,11 @@ func main() {
 	// CORS middleware
 	router.Use(middleware.CORS(cfg.AllowedOrigins))
 
-	// Static file serving for uploads
-	router.Static("/uploads", "./uploads")
+	// Uploaded files: avatars are public, everything else needs a signed URL
+	router.GET("/uploads/*filepath", fileHandler.ServeAvatar)
+	router.HEAD("/uploads/*filepath", fileHandler.ServeAvatar)
+	router.GET("/files/*filepath", fileHandler.ServeSignedFile)
+	router.HEAD("/files/*filepath", fileHandler.ServeSignedFile)
 
 	// Public routes
 	api := router.Group("/api")
@@ -113,11 +212,16 @@ func main() {
 
 		// Public study plans (challenges)
 		api.GET("/studyplans/public", studyPlanHandler.GetPublicStudyPlans)
+
+		// Resumable upload capability discovery (tus)
+		api.OPTIONS("/uploads/tus", tusHandler.Options)
 	}
 
 	// Protected routes
 	protected := api.Group("")
 	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	// Routes that call a language model answer 429 once the user's or school's quota is used up
+	aiQuota := middleware.AIQuotaMiddleware(aiUsageService)
 	{
 		// Auth
 		protected.GET("/auth/me", authHandler.GetCurrentUser)
@@ -126,6 +230,7 @@ func main() {
 		// User
 		protected.GET("/users/me/profile", userHandler.GetMyProfile)
 		protected.PUT("/users/me/profile", userHandler.UpdateProfile)
+		protected.POST("/users/me/avatar", userHandler.UploadAvatar)
 		protected.GET("/users/me/stats", userHandler.GetMyStats)
 		protected.POST("/users/me/xp", userHandler.AddXP)
 		protected.GET("/users/me/children", userHandler.GetChildren)      // Get parent's children
@@ -146,7 +251,7 @@ func main() {
 		protected.PUT("/users/me/study-session/idle", activityHandler.SetIdleStatus)
 		protected.POST("/users/me/study-session/stop", activityHandler.StopStudySession)
 		// AI Assessment
-		protected.POST("/study-session/assess", activityHandler.GenerateAssessment)
+		protected.POST("/study-session/assess", aiQuota, activityHandler.GenerateAssessment)
 		protected.POST("/study-session/complete-assessment", activityHandler.CompleteAssessment)
 
 		// Friends
@@ -159,6 +264,14 @@ func main() {
 		protected.GET("/users/:id/profile", userHandler.GetProfile)
 		protected.GET("/users/:id/stats", userHandler.GetUserStats)
 
+		// Notifications
+		protected.GET("/notifications", notificationHandler.GetNotifications)
+		protected.POST("/notifications/read-all", notificationHandler.MarkAllRead)
+		protected.POST("/notifications/:id/read", notificationHandler.MarkRead)
+
+		// Background jobs
+		protected.GET("/jobs/:id", jobHandler.GetJob)
+
 		// Rooms
 		protected.POST("/rooms", roomHandler.CreateRoom)
 		protected.GET("/rooms/my", roomHandler.GetMyRooms)                  // Teacher's own rooms
@@ -172,10 +285,37 @@ func main() {
 		// Resources
 		protected.POST("/rooms/:id/resources/upload", resourceHandler.UploadFile)      // File upload
 		protected.POST("/rooms/:id/resources", resourceHandler.CreateResource)         // Create resource
-		protected.GET("/rooms/:id/resources", resourceHandler.GetResources)            // Get all resources
+		protected.GET("/rooms/:id/resources", resourceHandler.GetResources)            // Filter, search, sort and page resources
+		protected.GET("/rooms/:id/resources/storage", resourceHandler.GetStorageUsage) // Storage use and quotas
+		protected.GET("/rooms/:id/resources/tags", resourceHandler.GetResourceTags)    // Tags in use, with counts
+		protected.GET("/rooms/:id/resources/folders", resourceFolderHandler.GetFolders)                // Folder tree (flat, with parent_id)
+		protected.POST("/rooms/:id/resources/folders", resourceFolderHandler.CreateFolder)              // Room owner
+		protected.PUT("/rooms/:id/resources/folders/:folder_id", resourceFolderHandler.UpdateFolder)    // Rename or move (room owner)
+		protected.DELETE("/rooms/:id/resources/folders/:folder_id", resourceFolderHandler.DeleteFolder) // Contents move up a level (room owner)
 		protected.GET("/resources/:resource_id", resourceHandler.GetResource)          // Get single resource
+		protected.GET("/resources/:resource_id/text", resourceHandler.GetResourceText) // Extracted file text
+		protected.GET("/resources/:resource_id/download", resourceHandler.DownloadResource)         // Stream file (supports Range)
+		protected.GET("/resources/:resource_id/download-url", resourceHandler.GetResourceDownloadURL) // Signed, expiring URL
+		protected.POST("/resources/:resource_id/engagement", resourceHandler.RecordEngagement)        // Client-reported view or video progress
+		protected.GET("/files/url", fileHandler.GetFileURL)                                          // Signed URL for a room file
+		protected.PATCH("/resources/:resource_id", resourceHandler.UpdateResource)     // Rename, tag or move to a folder
 		protected.DELETE("/resources/:resource_id", resourceHandler.DeleteResource)    // Delete resource
 		protected.POST("/resources/:resource_id/share", resourceHandler.ShareResource) // Share resource
+		protected.GET("/resources/:resource_id/versions", resourceHandler.GetResourceVersions)                      // Version history
+		protected.POST("/resources/:resource_id/versions", resourceHandler.AddResourceVersion)                      // Upload a new version (uploader)
+		protected.POST("/resources/:resource_id/versions/:version/restore", resourceHandler.RestoreResourceVersion) // Restore a version (uploader)
+		protected.GET("/resources/:resource_id/versions/:version/download", resourceHandler.DownloadResourceVersion) // Stream a version's file
+
+		// Resumable uploads (tus 1.0)
+		tus := protected.Group("/uploads/tus")
+		tus.Use(tusHandler.RequireTusVersion)
+		{
+			tus.POST("", tusHandler.Create)
+			tus.HEAD("/:upload_id", tusHandler.Head)
+			tus.PATCH("/:upload_id", tusHandler.Patch)
+			tus.DELETE("/:upload_id", tusHandler.Terminate)
+		}
+		protected.GET("/uploads/tus/:upload_id", tusHandler.GetStatus) // Processing result (file URL, resource ID)
 
 		// Assignments
 		protected.POST("/rooms/:id/assignments", assignmentHandler.CreateAssignment)           // Create assignment
@@ -183,6 +323,15 @@ func main() {
 		protected.GET("/assignments/:assignment_id", assignmentHandler.GetAssignment)          // Get single assignment
 		protected.PUT("/assignments/:assignment_id", assignmentHandler.UpdateAssignment)      // Update assignment
 		protected.DELETE("/assignments/:assignment_id", assignmentHandler.DeleteAssignment)   // Delete assignment
+		protected.POST("/assignments/:assignment_id/submissions", assignmentHandler.SubmitAssignment) // Submit work (student)
+		protected.GET("/assignments/:assignment_id/submissions", assignmentHandler.GetSubmissions)    // List submissions (teacher)
+		protected.POST("/assignments/:assignment_id/similarity", similarityHandler.StartAnalysis)     // Start similarity analysis (teacher)
+		protected.GET("/assignments/:assignment_id/similarity", similarityHandler.GetLatestReport)    // Latest similarity report (teacher)
+		protected.GET("/assignments/:assignment_id/peer-reviews", peerReviewHandler.GetPeerReviewSummary) // Aggregated peer scores (teacher)
+
+		// Peer Reviews
+		protected.GET("/peer-reviews/my", peerReviewHandler.GetMyReviews)                 // Reviews assigned to me
+		protected.POST("/peer-reviews/:review_id/submit", peerReviewHandler.SubmitReview) // Submit rubric scores
 
 		// Room Exam Dates
 		protected.PUT("/rooms/:id/exam-dates", roomHandler.UpdateRoomExamDates) // Update room exam dates (owner only)
@@ -202,7 +351,7 @@ func main() {
 		protected.POST("/goals", goalHandler.CreateGoal)
 		protected.GET("/goals", goalHandler.GetGoals)
 		protected.GET("/goals/today", goalHandler.GetTodayGoals)
-		protected.POST("/goals/daily/generate", goalHandler.GenerateDailyGoals)
+		protected.POST("/goals/daily/generate", aiQuota, goalHandler.GenerateDailyGoals)
 		protected.POST("/goals/:id/toggle", goalHandler.ToggleGoalComplete)
 		protected.DELETE("/goals/:id", goalHandler.DeleteGoal)
 		protected.GET("/milestones", goalHandler.GetMilestones)
@@ -211,14 +360,14 @@ func main() {
 
 		// Reports
 		if aiReportService != nil {
-			protected.POST("/reports/generate", reportHandler.GenerateReport)
+			protected.POST("/reports/generate", aiQuota, reportHandler.GenerateReport)
 			protected.GET("/reports", reportHandler.GetReports)
 			protected.GET("/reports/:id", reportHandler.GetReport)
 		}
 
 		// Goal Suggestions
 		if goalSuggestionService != nil {
-			protected.POST("/goal-suggestions/generate", goalHandler.GenerateGoalSuggestions)
+			protected.POST("/goal-suggestions/generate", aiQuota, goalHandler.GenerateGoalSuggestions)
 			protected.GET("/goal-suggestions", goalHandler.GetGoalSuggestions)
 			protected.POST("/goal-suggestions/:id/accept", goalHandler.AcceptGoalSuggestion)
 			protected.DELETE("/goal-suggestions/:id", goalHandler.DismissGoalSuggestion)
@@ -226,9 +375,13 @@ func main() {
 
 		// Room AI
 		if roomAIService != nil {
-			protected.POST("/rooms/:id/ai/train", roomHandler.TrainRoomAI)
-			protected.POST("/rooms/:id/ai/chat", roomHandler.ChatWithRoomAI)
+			protected.POST("/rooms/:id/ai/train", aiQuota, roomHandler.TrainRoomAI)
+			protected.POST("/rooms/:id/ai/chat", aiQuota, roomHandler.ChatWithRoomAI)
+			protected.POST("/rooms/:id/ai/chat/stream", aiQuota, roomHandler.ChatWithRoomAIStream)
 			protected.GET("/rooms/:id/ai/status", roomHandler.GetRoomAIStatus)
+			protected.DELETE("/rooms/:id/ai/cache", roomHandler.ClearRoomAICache)
+			protected.GET("/rooms/:id/ai/settings", roomHandler.GetRoomAISettings)
+			protected.PUT("/rooms/:id/ai/settings", roomHandler.UpdateRoomAISettings) // Room owner
 		}
 
 		// Game Templates
@@ -237,7 +390,7 @@ func main() {
 
 		// Games
 		if gameService != nil {
-			protected.POST("/rooms/:id/games", gameHandler.GenerateGame)
+			protected.POST("/rooms/:id/games", aiQuota, gameHandler.GenerateGame)
 			protected.GET("/rooms/:id/games", gameHandler.GetRoomGames)
 			protected.GET("/games/:game_id", gameHandler.GetGame)
 			protected.GET("/games/:game_id/bundle", gameHandler.DownloadBundle)
@@ -252,25 +405,53 @@ func main() {
 		protected.POST("/matches/:match_id/join", matchHandler.JoinMatch)
 		protected.GET("/ws/match/:match_id", matchHandler.HandleWebSocket)
 
-		// Game Analytics
+		// Game and resource engagement analytics
 		protected.GET("/games/:game_id/stats", analyticsHandler.GetGameStats)
 		protected.GET("/rooms/:id/analytics", analyticsHandler.GetRoomAnalytics)
 		protected.GET("/rooms/:id/analytics/export", analyticsHandler.ExportCSV)
-
-		// AI (if available)
-		if geminiService != nil {
-		protected.POST("/ai/chat", aiHandler.Chat)
-		protected.POST("/ai/explain", aiHandler.ExplainTopic)
-		protected.POST("/ai/answer", aiHandler.AnswerQuestion)
-		protected.POST("/ai/questions", aiHandler.GenerateQuestions)
-		protected.POST("/ai/summarize", aiHandler.Summarize)
-		protected.POST("/ai/syllabus/from-file", aiHandler.GenerateSyllabusFromFile)
-		protected.POST("/ai/syllabus/from-topics", aiHandler.GenerateSyllabusFromTopics)
-		}
+		protected.GET("/rooms/:id/analytics/resources", analyticsHandler.GetResourceEngagement)                    // Engagement per resource (room owner)
+		protected.GET("/rooms/:id/analytics/resources/:resource_id", analyticsHandler.GetResourceEngagementDetail) // Per student, incl. who hasn't opened it
+		protected.GET("/rooms/:id/analytics/students/:user_id/resources", analyticsHandler.GetStudentEngagement)   // One student across resources
+
+		// AI (503 when no model is configured for chat)
+		protected.POST("/ai/chat", aiQuota, aiHandler.Chat)
+		protected.POST("/ai/chat/stream", aiQuota, aiHandler.ChatStream)
+		protected.POST("/ai/explain", aiQuota, aiHandler.ExplainTopic)
+		protected.POST("/ai/explain/stream", aiQuota, aiHandler.ExplainTopicStream)
+		protected.POST("/ai/answer", aiQuota, aiHandler.AnswerQuestion)
+		protected.POST("/ai/questions", aiQuota, aiHandler.GenerateQuestions)
+		protected.POST("/ai/summarize", aiQuota, aiHandler.Summarize)
+		protected.POST("/ai/syllabus/from-file", aiQuota, aiHandler.GenerateSyllabusFromFile)
+		protected.POST("/ai/syllabus/from-topics", aiQuota, aiHandler.GenerateSyllabusFromTopics)
+
+		// AI conversation threads (tutor, or room AI with room_id)
+		protected.GET("/ai/threads", aiThreadHandler.GetThreads) // ?room_id= for room AI threads
+		protected.POST("/ai/threads", aiThreadHandler.CreateThread)
+		protected.GET("/ai/threads/:thread_id", aiThreadHandler.GetThread) // With messages
+		protected.PUT("/ai/threads/:thread_id", aiThreadHandler.RenameThread)
+		protected.DELETE("/ai/threads/:thread_id", aiThreadHandler.DeleteThread)
+		protected.POST("/ai/threads/:thread_id/messages", aiQuota, aiThreadHandler.SendMessage)
+		protected.POST("/ai/threads/:thread_id/messages/stream", aiQuota, aiThreadHandler.SendMessageStream)
+
+		// AI usage and cost
+		protected.GET("/ai/usage", aiUsageHandler.GetMyUsage)              // This period, with remaining quota
+		protected.GET("/rooms/:id/ai/usage", aiUsageHandler.GetRoomUsage) // By student, feature and day (room owner)
+		// Flagged AI interactions of students (their parents and room teachers)
+		protected.GET("/ai/safety/flags", aiSafetyHandler.GetFlags)
+		protected.PUT("/ai/safety/flags/:flag_id/review", aiSafetyHandler.ReviewFlag)
+
+		admin := protected.Group("/admin", middleware.AdminMiddleware(cfg.AdminEmails))
+		admin.GET("/ai/usage", aiUsageHandler.GetUsageReport) // By feature, model, role, school, user and day
+		// Versioned AI prompts
+		admin.GET("/prompts", promptHandler.GetPrompts)
+		admin.GET("/prompts/:name", promptHandler.GetPrompt)
+		admin.POST("/prompts/:name/versions", promptHandler.CreateVersion)
+		admin.PUT("/prompts/:name/release", promptHandler.SetRelease)
+		admin.POST("/prompts/:name/rollback", promptHandler.Rollback)
 
 		// Smart Study Plan
 		if smartPlanService != nil {
-			protected.POST("/smart-plan/generate", smartPlanHandler.GenerateSmartPlan)
+			protected.POST("/smart-plan/generate", aiQuota, smartPlanHandler.GenerateSmartPlan)
 			protected.POST("/smart-plan/create", smartPlanHandler.CreateSmartPlan)
 		}
 	}
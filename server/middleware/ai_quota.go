@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"buddy-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AIQuotaMiddleware answers 429 with the reset time once the user or their
+// school has used up its AI tokens for the period, and attributes the AI calls
+// made while handling the request to the user
+func AIQuotaMiddleware(usageService *services.AIUsageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+
+		if err := usageService.CheckQuota(userID); err != nil {
+			var quotaErr *services.AIQuotaError
+			if !errors.As(err, &quotaErr) {
+				// Usage that can't be checked shouldn't take AI features down
+				log.Printf("Failed to check AI quota: %v", err)
+			} else {
+				retryAfter := int(math.Ceil(time.Until(quotaErr.ResetAt).Seconds()))
+				c.Header("Retry-After", strconv.Itoa(retryAfter))
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error":    quotaErr.Error(),
+					"scope":    quotaErr.Scope,
+					"period":   quotaErr.Period,
+					"limit":    quotaErr.Limit,
+					"used":     quotaErr.Used,
+					"reset_at": quotaErr.ResetAt,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Request = c.Request.WithContext(services.WithAIUsage(c.Request.Context(), userID, ""))
+		c.Next()
+	}
+}
+
+// AdminMiddleware only lets through the users whose emails are listed as admins
+func AdminMiddleware(adminEmails []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := strings.ToLower(c.GetString("user_email"))
+		for _, admin := range adminEmails {
+			if email != "" && strings.ToLower(strings.TrimSpace(admin)) == email {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
@@ -11,7 +11,7 @@ type AIQuestion struct {
 	Question      string   `json:"question" bson:"question"`
 	Options       []string `json:"options,omitempty" bson:"options,omitempty"` // For multiple choice
 	CorrectAnswer string   `json:"correct_answer" bson:"correct_answer"`
-	QuestionType  string   `json:"question_type" bson:"question_type"` // "multiple_choice", "short_answer"
+	QuestionType  string   `json:"question_type" bson:"question_type" schema:"enum=multiple_choice|short_answer"`
 }
 
 // StudyPlanComment represents AI assessment of a study session
@@ -54,6 +54,8 @@ type StudentReport struct {
 	GoalsCompleted        int     `json:"goals_completed" bson:"goals_completed"`
 	MilestonesProgress    float64 `json:"milestones_progress" bson:"milestones_progress"`
 
+	PromptVersion string `json:"prompt_version,omitempty" bson:"prompt_version,omitempty"` // e.g. "student_report@2"
+
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 }
 
@@ -64,8 +66,10 @@ type RoomAIContext struct {
 
 	// Training data from resources
 	TrainedResourceIDs []primitive.ObjectID `json:"trained_resource_ids" bson:"trained_resource_ids"`
+	StaleResourceIDs   []primitive.ObjectID `json:"stale_resource_ids,omitempty" bson:"stale_resource_ids,omitempty"` // Trained resources changed since training
 	TrainingContent    string               `json:"training_content" bson:"training_content"` // Concatenated content
 	Syllabus           *Syllabus            `json:"syllabus,omitempty" bson:"syllabus,omitempty"`
+	ExcludedResources  []ExcludedResource   `json:"excluded_resources,omitempty" bson:"excluded_resources,omitempty"` // Selected resources left out of training
 
 	// Conversation history
 	MessageCount  int       `json:"message_count" bson:"message_count"`
@@ -75,6 +79,102 @@ type RoomAIContext struct {
 	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
 }
 
+// ExcludedResource is a resource selected for training that the room AI was
+// not trained with
+type ExcludedResource struct {
+	ResourceID   primitive.ObjectID `json:"resource_id" bson:"resource_id"`
+	ResourceName string             `json:"resource_name" bson:"resource_name"`
+	UploaderType string             `json:"uploader_type" bson:"uploader_type"`
+	Reason       string             `json:"reason" bson:"reason"` // Why it was left out, e.g. the instruction it contains
+}
+
+// ResourceChunk is an embedded passage of a resource's text, used for room AI retrieval
+type ResourceChunk struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RoomID       primitive.ObjectID `json:"room_id" bson:"room_id"`
+	ResourceID   primitive.ObjectID `json:"resource_id" bson:"resource_id"`
+	ResourceName string             `json:"resource_name" bson:"resource_name"`
+	Section      string             `json:"section,omitempty" bson:"section,omitempty"` // "Page 3", "Slide 2", heading
+	ChunkIndex   int                `json:"chunk_index" bson:"chunk_index"`
+	Text         string             `json:"text" bson:"text"`
+	Embedding    []float32          `json:"-" bson:"embedding"`
+	Provider     string             `json:"provider" bson:"provider"`         // Embedding provider that produced the vector
+	ExtractedAt  time.Time          `json:"extracted_at" bson:"extracted_at"` // ResourceText version the chunk was built from
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Citation points an answer back to the resource passage it used
+type Citation struct {
+	Number       int                `json:"number" bson:"number"` // [n] marker used in the answer
+	ResourceID   primitive.ObjectID `json:"resource_id" bson:"resource_id"`
+	ResourceName string             `json:"resource_name" bson:"resource_name"`
+	Section      string             `json:"section,omitempty" bson:"section,omitempty"`
+	Excerpt      string             `json:"excerpt" bson:"excerpt"`
+	Score        float64            `json:"score" bson:"score"`
+}
+
+// RoomAIAnswer is a room AI response with the sources it drew on
+type RoomAIAnswer struct {
+	Response  string     `json:"response"`
+	Citations []Citation `json:"citations"`
+}
+
+// AIThreadModeTutor threads guide the student with hints instead of answering
+const AIThreadModeTutor = "tutor"
+
+// AIThread is a stored conversation between a user and the general tutor
+// (RoomID nil) or a room's AI
+type AIThread struct {
+	ID     primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserID primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	RoomID *primitive.ObjectID `json:"room_id,omitempty" bson:"room_id,omitempty"`
+	Title  string              `json:"title" bson:"title"`
+	Mode   string              `json:"mode,omitempty" bson:"mode,omitempty"`   // "" to answer, "tutor"
+	Tutor  *TutorState         `json:"tutor,omitempty" bson:"tutor,omitempty"` // The problem being tutored, if any
+
+	// Older messages are folded into Summary so long threads stay within the model's context
+	Summary         string `json:"summary,omitempty" bson:"summary,omitempty"`
+	SummarizedCount int    `json:"summarized_count" bson:"summarized_count"` // Oldest messages covered by Summary
+	MessageCount    int    `json:"message_count" bson:"message_count"`
+
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"` // Last message
+}
+
+// TutorState tracks a tutored problem: each attempt raises the hint level
+// until the solution is revealed
+type TutorState struct {
+	Question     string              `json:"question" bson:"question"`
+	HintLevel    int                 `json:"hint_level" bson:"hint_level"` // 0 asks the student to try; RevealAfter shows the solution
+	Attempts     int                 `json:"attempts" bson:"attempts"`
+	RevealAfter  int                 `json:"reveal_after" bson:"reveal_after"`
+	Revealed     bool                `json:"revealed" bson:"revealed"`
+	Forced       bool                `json:"forced,omitempty" bson:"forced,omitempty"` // Required by the room's settings
+	AssignmentID *primitive.ObjectID `json:"assignment_id,omitempty" bson:"assignment_id,omitempty"`
+}
+
+// AIThreadMessage is one turn of an AI thread
+type AIThreadMessage struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ThreadID  primitive.ObjectID `json:"thread_id" bson:"thread_id"`
+	Role      string             `json:"role" bson:"role"` // "user", "assistant"
+	Content   string             `json:"content" bson:"content"`
+	Citations []Citation         `json:"citations,omitempty" bson:"citations,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// AIThreadDetail is a thread with its messages, oldest first
+type AIThreadDetail struct {
+	AIThread
+	Messages []AIThreadMessage `json:"messages"`
+}
+
+// AIThreadReply is the result of sending a message to a thread
+type AIThreadReply struct {
+	Thread  *AIThread        `json:"thread"`
+	Message *AIThreadMessage `json:"message"` // The assistant's reply
+}
+
 // GameQuestion represents a question in an AI-generated game
 type GameQuestion struct {
 	Question      string   `json:"question" bson:"question"`
@@ -173,6 +273,7 @@ type AIGame struct {
 	Questions []GameQuestion `json:"questions,omitempty" bson:"questions,omitempty"`
 	Scenarios []GameScenario `json:"scenarios,omitempty" bson:"scenarios,omitempty"` // For arcade/racing
 	Puzzles   []PuzzleConfig `json:"puzzles,omitempty" bson:"puzzles,omitempty"`     // For puzzle games
+	PromptVersion string     `json:"prompt_version,omitempty" bson:"prompt_version,omitempty"` // Prompt the content was generated with, e.g. "game_quiz@2"
 
 	// Configuration
 	Config  GameConfig  `json:"config" bson:"config"`
@@ -223,6 +324,7 @@ type GoalSuggestion struct {
 	Subject     string             `json:"subject" bson:"subject"`
 	Priority    string             `json:"priority" bson:"priority"` // "high", "medium", "low"
 	Reasoning   string             `json:"reasoning" bson:"reasoning"` // Why AI suggested this
+	PromptVersion string           `json:"prompt_version,omitempty" bson:"prompt_version,omitempty"` // e.g. "goal_suggestions@2"
 	AcceptedAt  *time.Time         `json:"accepted_at,omitempty" bson:"accepted_at,omitempty"`
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Stages of an AI interaction at which the safety policy flags it
+const (
+	AISafetyStageInput    = "input"     // The student's message matched the input block list
+	AISafetyStageOutput   = "output"    // The answer matched the output block list
+	AISafetyStageProvider = "provider"  // The provider's own safety filters refused it
+	AISafetyStageOffTopic = "off_topic" // The room AI declined a question unrelated to its course
+)
+
+// AISafetyFlag records a student's AI interaction that was blocked or declined,
+// for their parent and the room's teacher to review
+type AISafetyFlag struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserID      primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	StudentName string              `json:"student_name,omitempty" bson:"-"`
+	RoomID      *primitive.ObjectID `json:"room_id,omitempty" bson:"room_id,omitempty"`
+	Feature     string              `json:"feature" bson:"feature"`
+	Stage       string              `json:"stage" bson:"stage"`
+	Reason      string              `json:"reason" bson:"reason"` // The matched term or the provider's explanation
+	Input       string              `json:"input" bson:"input"`   // What the student wrote, shortened
+	Output      string              `json:"output,omitempty" bson:"output,omitempty"`
+	Age         int                 `json:"age,omitempty" bson:"age,omitempty"` // The student's age at the time, 0 if unknown
+	CreatedAt   time.Time           `json:"created_at" bson:"created_at"`
+	ReviewedBy  *primitive.ObjectID `json:"reviewed_by,omitempty" bson:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time          `json:"reviewed_at,omitempty" bson:"reviewed_at,omitempty"`
+}
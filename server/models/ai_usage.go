@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AIUsageRecord is the token usage of one language model call
+type AIUsageRecord struct {
+	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserID       *primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"` // nil for calls not made for a user
+	RoomID       *primitive.ObjectID `json:"room_id,omitempty" bson:"room_id,omitempty"`
+	Role         string              `json:"role,omitempty" bson:"role,omitempty"`                 // The user's role at the time
+	Organization string              `json:"organization,omitempty" bson:"organization,omitempty"` // The user's school at the time
+	Feature      string              `json:"feature" bson:"feature"`
+	Provider     string              `json:"provider" bson:"provider"`
+	Model        string              `json:"model" bson:"model"`
+	InputTokens  int                 `json:"input_tokens" bson:"input_tokens"`
+	OutputTokens int                 `json:"output_tokens" bson:"output_tokens"`
+	TotalTokens  int                 `json:"total_tokens" bson:"total_tokens"`
+	CostUSD      float64             `json:"cost_usd" bson:"cost_usd"`                       // Estimated from the configured model prices
+	Estimated    bool                `json:"estimated,omitempty" bson:"estimated,omitempty"` // Tokens counted from the text because the call stopped early
+	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// AIUsageTotals sums the usage of a set of calls
+type AIUsageTotals struct {
+	Calls        int64   `json:"calls" bson:"calls"`
+	InputTokens  int64   `json:"input_tokens" bson:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens" bson:"output_tokens"`
+	TotalTokens  int64   `json:"total_tokens" bson:"total_tokens"`
+	CostUSD      float64 `json:"cost_usd" bson:"cost_usd"`
+}
+
+// AIUsageGroup is the usage of the calls sharing a key (a feature, model, day, user...)
+type AIUsageGroup struct {
+	Key           string `json:"key" bson:"_id"`
+	Name          string `json:"name,omitempty" bson:"-"` // Display name where the key is an ID
+	AIUsageTotals `bson:",inline"`
+}
@@ -17,10 +17,50 @@ type Assignment struct {
 	TotalPoints    int                `json:"total_points" bson:"total_points"`
 	AssignmentType string             `json:"assignment_type" bson:"assignment_type"` // "homework", "quiz", "project", "exam"
 	Subjects       []string           `json:"subjects,omitempty" bson:"subjects,omitempty"` // Related syllabus topics/subjects (multiple)
+	PeerReview     *PeerReviewConfig  `json:"peer_review,omitempty" bson:"peer_review,omitempty"` // Only for "project" assignments
 	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
+// RubricCriterion is one scored criterion of a peer review rubric
+type RubricCriterion struct {
+	Title       string `json:"title" bson:"title"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	MaxPoints   int    `json:"max_points" bson:"max_points"`
+}
+
+// PeerReviewConfig configures student-to-student reviews for an assignment
+type PeerReviewConfig struct {
+	Enabled        bool              `json:"enabled" bson:"enabled"`
+	ReviewerCount  int               `json:"reviewer_count" bson:"reviewer_count"`   // Reviewers per submission
+	Anonymous      bool              `json:"anonymous" bson:"anonymous"`             // Hide author identity from reviewers
+	Rubric         []RubricCriterion `json:"rubric" bson:"rubric"`
+	ReviewDeadline time.Time         `json:"review_deadline" bson:"review_deadline"` // Must be after the assignment due date
+	AssignedAt     *time.Time        `json:"assigned_at,omitempty" bson:"assigned_at,omitempty"` // Set once review pairs are created
+}
+
+// RubricScore is a reviewer's score for one rubric criterion
+type RubricScore struct {
+	Criterion string `json:"criterion" bson:"criterion"`
+	Points    int    `json:"points" bson:"points"`
+}
+
+// PeerReview is one reviewer's review of one submission
+type PeerReview struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	AssignmentID primitive.ObjectID `json:"assignment_id" bson:"assignment_id"`
+	SubmissionID primitive.ObjectID `json:"submission_id" bson:"submission_id"`
+	ReviewerID   primitive.ObjectID `json:"reviewer_id" bson:"reviewer_id"`
+	AuthorID     primitive.ObjectID `json:"author_id" bson:"author_id"`
+	Status       string             `json:"status" bson:"status"`                // "pending", "completed"
+	Scores       []RubricScore      `json:"scores,omitempty" bson:"scores,omitempty"`
+	TotalScore   int                `json:"total_score" bson:"total_score"`
+	Comment      string             `json:"comment,omitempty" bson:"comment,omitempty"`
+	DueAt        time.Time          `json:"due_at" bson:"due_at"`
+	CompletedAt  *time.Time         `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+}
+
 // Submission represents a student submission
 type Submission struct {
 	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a slow piece of work, such as generating a report, run in the
+// background for a user. Failed attempts are retried with backoff.
+type Job struct {
+	ID             primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Type           string              `json:"type" bson:"type"` // "report", "game", "smart_plan" or "room_ai_training"
+	UserID         primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	RoomID         *primitive.ObjectID `json:"room_id,omitempty" bson:"room_id,omitempty"`
+	Status         string              `json:"status" bson:"status"`
+	Params         json.RawMessage     `json:"-" bson:"params,omitempty"`
+	Result         json.RawMessage     `json:"result,omitempty" bson:"result,omitempty"` // The job type's response, once it succeeded
+	Error          string              `json:"error,omitempty" bson:"error,omitempty"`
+	Attempts       int                 `json:"attempts" bson:"attempts"`
+	MaxAttempts    int                 `json:"max_attempts" bson:"max_attempts"`
+	IdempotencyKey string              `json:"idempotency_key,omitempty" bson:"idempotency_key,omitempty"`
+	Notify         bool                `json:"notify" bson:"notify"` // Notify the user when the job finishes
+	RunAt          time.Time           `json:"run_at" bson:"run_at"`
+	LockedUntil    *time.Time          `json:"-" bson:"locked_until,omitempty"`
+	CreatedAt      time.Time           `json:"created_at" bson:"created_at"`
+	StartedAt      *time.Time          `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt     *time.Time          `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
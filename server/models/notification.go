@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification is an in-app notice for a user, such as an updated room resource
+type Notification struct {
+	ID         primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserID     primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	Type       string              `json:"type" bson:"type"` // "resource_updated", "job_succeeded" or "job_failed"
+	Title      string              `json:"title" bson:"title"`
+	Body       string              `json:"body" bson:"body"`
+	RoomID     *primitive.ObjectID `json:"room_id,omitempty" bson:"room_id,omitempty"`
+	ResourceID *primitive.ObjectID `json:"resource_id,omitempty" bson:"resource_id,omitempty"`
+	JobID      *primitive.ObjectID `json:"job_id,omitempty" bson:"job_id,omitempty"`
+	Read       bool                `json:"read" bson:"read"`
+	CreatedAt  time.Time           `json:"created_at" bson:"created_at"`
+	ReadAt     *time.Time          `json:"read_at,omitempty" bson:"read_at,omitempty"`
+}
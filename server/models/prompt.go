@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PromptVariable is a value a prompt template is filled in with
+type PromptVariable struct {
+	Name string `json:"name" bson:"name"`
+	Type string `json:"type" bson:"type"` // "string", "int", "float", "list" or "any"
+}
+
+// PromptTemplate is one version of a named prompt. Version 1 of every prompt
+// is built into the server; later versions are added by admins.
+type PromptTemplate struct {
+	ID        primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	Name      string              `json:"name" bson:"name"`
+	Version   int                 `json:"version" bson:"version"`
+	Text      string              `json:"text" bson:"text"` // Go text/template over the prompt's variables
+	Note      string              `json:"note,omitempty" bson:"note,omitempty"`
+	Builtin   bool                `json:"builtin" bson:"-"`
+	CreatedBy *primitive.ObjectID `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// PromptRelease records which version of a prompt is in use. A candidate
+// version can be tried on a percentage of users before it is released.
+type PromptRelease struct {
+	Name             string              `json:"name" bson:"_id"`
+	Version          int                 `json:"version" bson:"version"`
+	PreviousVersion  int                 `json:"previous_version,omitempty" bson:"previous_version,omitempty"`
+	CandidateVersion int                 `json:"candidate_version,omitempty" bson:"candidate_version,omitempty"`
+	CandidatePercent int                 `json:"candidate_percent,omitempty" bson:"candidate_percent,omitempty"`
+	StoredVersions   int                 `json:"-" bson:"stored_versions"` // Versions added after the built-in one
+	UpdatedBy        *primitive.ObjectID `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	UpdatedAt        time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// PromptSummary describes a prompt and its release for admins
+type PromptSummary struct {
+	Name          string           `json:"name"`
+	Variables     []PromptVariable `json:"variables"`
+	LatestVersion int              `json:"latest_version"`
+	Release       PromptRelease    `json:"release"`
+}
+
+// PromptDetail is a prompt with all of its versions, newest first
+type PromptDetail struct {
+	PromptSummary
+	Versions []PromptTemplate `json:"versions"`
+}
@@ -22,6 +22,146 @@ type Resource struct {
 	Subjects    []string            `json:"subjects,omitempty" bson:"subjects,omitempty"` // Related syllabus topics/subjects (multiple)
 	IsPublic    bool               `json:"is_public" bson:"is_public"` // For student resources: shareable with others
 	SharedWith  []primitive.ObjectID `json:"shared_with,omitempty" bson:"shared_with,omitempty"` // For student resources: specific users
+	FolderID    *primitive.ObjectID `json:"folder_id,omitempty" bson:"folder_id,omitempty"` // Room folder; nil at the top level
+	Tags        []string           `json:"tags,omitempty" bson:"tags,omitempty"` // Free-form, lowercase
+	Version     int                `json:"version" bson:"version,omitempty"` // Current version number (0 on resources created before versioning)
+	Preview     *ResourcePreview   `json:"preview,omitempty" bson:"preview,omitempty"` // Generated in the background; missing until then
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
 }
+
+// ResourceFolder organizes a room's resources; folders nest through ParentID
+type ResourceFolder struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	RoomID    primitive.ObjectID  `json:"room_id" bson:"room_id"`
+	ParentID  *primitive.ObjectID `json:"parent_id,omitempty" bson:"parent_id,omitempty"` // nil for top-level folders
+	Name      string              `json:"name" bson:"name"`
+	CreatedBy primitive.ObjectID  `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// ResourcePreview is a thumbnail and metadata derived from a resource's file
+type ResourcePreview struct {
+	Status       string    `json:"status" bson:"status"` // "ready", "failed", "unsupported"
+	Error        string    `json:"error,omitempty" bson:"error,omitempty"`
+	SourceURL    string    `json:"-" bson:"source_url"`                         // Resource.FileURL the preview was generated from
+	ThumbnailKey string    `json:"-" bson:"thumbnail_key,omitempty"`            // Storage key of the JPEG thumbnail
+	ThumbnailURL string    `json:"thumbnail_url,omitempty" bson:"-"`            // Signed when the resource is served
+	Width        int       `json:"width,omitempty" bson:"width,omitempty"`       // Image pixels or first page points
+	Height       int       `json:"height,omitempty" bson:"height,omitempty"`
+	PageCount    int       `json:"page_count,omitempty" bson:"page_count,omitempty"` // Pages or slides
+	Duration     float64   `json:"duration_seconds,omitempty" bson:"duration_seconds,omitempty"`
+	Text         string    `json:"text,omitempty" bson:"text,omitempty"` // Opening text when there is no thumbnail
+	GeneratedAt  time.Time `json:"generated_at" bson:"generated_at"`
+}
+
+// ResourceVersion is one file revision of a resource. The resource always points
+// at its latest version; restoring an older one adds a new version with its file.
+type ResourceVersion struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ResourceID   primitive.ObjectID `json:"resource_id" bson:"resource_id"`
+	RoomID       primitive.ObjectID `json:"room_id" bson:"room_id"`
+	Version      int                `json:"version" bson:"version"`
+	FileURL      string             `json:"file_url" bson:"file_url"`
+	FileType     string             `json:"file_type" bson:"file_type"`
+	FileSize     int64              `json:"file_size" bson:"file_size"`
+	ChangeNote   string             `json:"change_note,omitempty" bson:"change_note,omitempty"`
+	RestoredFrom int                `json:"restored_from,omitempty" bson:"restored_from,omitempty"` // Version whose file was restored
+	CreatedBy    primitive.ObjectID `json:"created_by" bson:"created_by"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// TextSection is a located part of a document's text (a PDF page, a slide, a heading)
+type TextSection struct {
+	Label string `json:"label" bson:"label"` // e.g. "Page 3", "Slide 2", "Introduction"
+	Text  string `json:"text" bson:"text"`
+}
+
+// ResourceText stores the text extracted from a resource's file
+type ResourceText struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ResourceID  primitive.ObjectID `json:"resource_id" bson:"resource_id"`
+	RoomID      primitive.ObjectID `json:"room_id" bson:"room_id"`
+	Status      string             `json:"status" bson:"status"` // "ready", "failed", "unsupported"
+	Error       string             `json:"error,omitempty" bson:"error,omitempty"`
+	SourceURL   string             `json:"source_url" bson:"source_url"` // Resource.FileURL the text was extracted from
+	Sections    []TextSection      `json:"sections" bson:"sections"`
+	PageCount   int                `json:"page_count,omitempty" bson:"page_count,omitempty"`
+	CharCount   int                `json:"char_count" bson:"char_count"`
+	Truncated   bool               `json:"truncated,omitempty" bson:"truncated,omitempty"` // Text past maxStoredTextBytes was dropped
+	ExtractedAt time.Time          `json:"extracted_at" bson:"extracted_at"`
+	Attempts    int                `json:"-" bson:"attempts,omitempty"` // Failed extractions of SourceURL in a row
+	RetryAt     *time.Time         `json:"-" bson:"retry_at,omitempty"` // When the sweep retries a failed extraction; unset once out of attempts
+}
+
+// Upload records a file uploaded to a room. Files are addressed by content hash,
+// so uploading the same content to a room twice reuses the stored object.
+type Upload struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RoomID        primitive.ObjectID `json:"room_id" bson:"room_id"`
+	UploaderID    primitive.ObjectID   `json:"uploader_id" bson:"uploader_id"` // Who stored it, and whose quota it counts against
+	UploaderIDs   []primitive.ObjectID `json:"-" bson:"uploader_ids,omitempty"` // Everyone who uploaded this content
+	Hash          string             `json:"hash" bson:"hash"` // SHA-256 of the content
+	Key           string             `json:"-" bson:"key"`            // Storage key once the scan passes
+	QuarantineKey string             `json:"-" bson:"quarantine_key"` // Storage key while awaiting the scan
+	FileURL       string             `json:"file_url" bson:"file_url"`
+	FileName      string             `json:"file_name" bson:"file_name"`
+	ContentType   string             `json:"content_type" bson:"content_type"` // Sniffed from the content
+	Size          int64              `json:"size" bson:"size"`
+	ScanStatus    string             `json:"scan_status" bson:"scan_status"` // "pending", "clean", "infected"
+	Threat        string             `json:"threat,omitempty" bson:"threat,omitempty"`
+	Scanner       string             `json:"scanner,omitempty" bson:"scanner,omitempty"`
+	ScanError     string             `json:"-" bson:"scan_error,omitempty"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	ScannedAt     *time.Time         `json:"scanned_at,omitempty" bson:"scanned_at,omitempty"`
+}
+
+// UploadSession tracks a resumable (tus) upload. Received chunks are kept in
+// storage until the upload completes and is turned into an Upload and Resource.
+type UploadSession struct {
+	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	RoomID       primitive.ObjectID  `json:"room_id" bson:"room_id"`
+	UserID       primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	UploaderType string              `json:"uploader_type" bson:"uploader_type"`
+	Length       int64               `json:"length" bson:"length"`
+	Offset       int64               `json:"offset" bson:"offset"`
+	Metadata     map[string]string   `json:"metadata" bson:"metadata"`
+	Status       string              `json:"status" bson:"status"` // "uploading", "processing", "completed", "failed"
+	Error        string              `json:"error,omitempty" bson:"error,omitempty"`
+	FileURL      string              `json:"file_url,omitempty" bson:"file_url,omitempty"`
+	ScanStatus   string              `json:"scan_status,omitempty" bson:"scan_status,omitempty"`
+	ResourceID   *primitive.ObjectID `json:"resource_id,omitempty" bson:"resource_id,omitempty"`
+	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at" bson:"updated_at"`
+	ExpiresAt    time.Time           `json:"expires_at" bson:"expires_at"`
+}
+
+// ResourceEvent is one recorded view or download of a resource by a room member
+type ResourceEvent struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ResourceID primitive.ObjectID `json:"resource_id" bson:"resource_id"`
+	RoomID     primitive.ObjectID `json:"room_id" bson:"room_id"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Type       string             `json:"type" bson:"type"` // "view" or "download"
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// ResourceEngagement sums up how one member has used one resource; it is
+// updated with every event and with the watch progress reported by clients
+type ResourceEngagement struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ResourceID      primitive.ObjectID `json:"resource_id" bson:"resource_id"`
+	RoomID          primitive.ObjectID `json:"room_id" bson:"room_id"`
+	UserID          primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Views           int                `json:"views" bson:"views"`
+	Downloads       int                `json:"downloads" bson:"downloads"`
+	FirstOpenedAt   *time.Time         `json:"first_opened_at,omitempty" bson:"first_opened_at,omitempty"`
+	LastOpenedAt    *time.Time         `json:"last_opened_at,omitempty" bson:"last_opened_at,omitempty"`
+	LastViewAt      *time.Time         `json:"-" bson:"last_view_at,omitempty"`     // Repeated views within a few minutes count once
+	LastDownloadAt  *time.Time         `json:"-" bson:"last_download_at,omitempty"` // Likewise for downloads
+	WatchedSeconds  float64            `json:"watched_seconds,omitempty" bson:"watched_seconds,omitempty"`   // Furthest position reached in a video
+	DurationSeconds float64            `json:"duration_seconds,omitempty" bson:"duration_seconds,omitempty"` // Video length as reported by the player
+	Completed       bool               `json:"completed" bson:"completed"`
+	UpdatedAt       time.Time          `json:"updated_at" bson:"updated_at"`
+}
@@ -34,6 +34,53 @@ type ExamDate struct {
 	Subject     string    `json:"subject,omitempty" bson:"subject,omitempty"`          // Related syllabus topic/subject
 }
 
+// When the room AI tutors instead of answering
+const (
+	RoomTutorModeOptional    = "optional"    // Students choose (default)
+	RoomTutorModeAlways      = "always"      // Every student question is tutored
+	RoomTutorModeAssignments = "assignments" // Tutored while one of TutorAssignmentIDs is open
+)
+
+// What the room AI may answer
+const (
+	RoomAIScopeSyllabus = "syllabus" // Only the course content (default)
+	RoomAIScopeGeneral  = "general"  // General knowledge too, saying when it goes beyond the course
+)
+
+// How the room AI answers
+const (
+	RoomAIStyleBalanced = "balanced" // Default
+	RoomAIStyleBrief    = "brief"
+	RoomAIStyleDetailed = "detailed"
+	RoomAIStyleSocratic = "socratic" // Guiding questions instead of answers
+)
+
+// AIBlackoutWindow is a time, such as an exam, when the room AI doesn't answer students
+type AIBlackoutWindow struct {
+	Start  time.Time `json:"start" bson:"start"`
+	End    time.Time `json:"end" bson:"end"`
+	Reason string    `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+// RoomAISettings configures a room's AI assistant
+type RoomAISettings struct {
+	AssistantName string `json:"assistant_name,omitempty" bson:"assistant_name,omitempty"`
+	Persona       string `json:"persona,omitempty" bson:"persona,omitempty"`   // Who the assistant is, e.g. "a patient lab partner"
+	Language      string `json:"language,omitempty" bson:"language,omitempty"` // Language of every answer; empty for the student's
+	Scope         string `json:"scope,omitempty" bson:"scope,omitempty"`
+	AnswerStyle   string `json:"answer_style,omitempty" bson:"answer_style,omitempty"`
+	// Whether it may give the answers to the room's assignment questions; nil for true
+	SolveAssignments *bool              `json:"solve_assignments,omitempty" bson:"solve_assignments,omitempty"`
+	BlackoutWindows  []AIBlackoutWindow `json:"blackout_windows,omitempty" bson:"blackout_windows,omitempty"`
+
+	TutorMode          string               `json:"tutor_mode" bson:"tutor_mode"`
+	TutorAssignmentIDs []primitive.ObjectID `json:"tutor_assignment_ids,omitempty" bson:"tutor_assignment_ids,omitempty"` // Open from creation until their due date
+	TutorRevealAfter   int                  `json:"tutor_reveal_after,omitempty" bson:"tutor_reveal_after,omitempty"`     // Attempts before the solution is shown; 0 for the default
+	// Uploader types ("teacher", "student") whose resources the room AI is trained with; empty for both
+	TrainingUploaderTypes []string `json:"training_uploader_types,omitempty" bson:"training_uploader_types,omitempty"`
+	UpdatedAt          time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
 // Room represents a study room or classroom
 type Room struct {
 	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
@@ -54,6 +101,7 @@ type Room struct {
 	RegistrationEnd  *time.Time        `json:"registration_end,omitempty" bson:"registration_end,omitempty"`
 	Syllabus         *Syllabus         `json:"syllabus,omitempty" bson:"syllabus,omitempty"`           // Structured syllabus with topics
 	ExamDates        []ExamDate        `json:"exam_dates,omitempty" bson:"exam_dates,omitempty"`      // Exam dates for the course
+	AISettings       *RoomAISettings   `json:"ai_settings,omitempty" bson:"ai_settings,omitempty"`    // How the room AI behaves
 	
 	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
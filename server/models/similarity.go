@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OverlapPassage is a passage that appears in both submissions of a pair.
+// Offsets are byte offsets into each submission's extracted text.
+type OverlapPassage struct {
+	Text   string `json:"text" bson:"text"` // Passage as it appears in submission A
+	StartA int    `json:"start_a" bson:"start_a"`
+	EndA   int    `json:"end_a" bson:"end_a"`
+	StartB int    `json:"start_b" bson:"start_b"`
+	EndB   int    `json:"end_b" bson:"end_b"`
+	Words  int    `json:"words" bson:"words"`
+}
+
+// SimilarityPair represents two submissions that look suspiciously alike
+type SimilarityPair struct {
+	SubmissionA primitive.ObjectID `json:"submission_a" bson:"submission_a"`
+	SubmissionB primitive.ObjectID `json:"submission_b" bson:"submission_b"`
+	StudentA    primitive.ObjectID `json:"student_a" bson:"student_a"`
+	StudentB    primitive.ObjectID `json:"student_b" bson:"student_b"`
+	Similarity  float64            `json:"similarity" bson:"similarity"` // Jaccard similarity of word shingles (0-1)
+	Passages    []OverlapPassage   `json:"passages" bson:"passages"`
+}
+
+// SimilarityReport is the result of a similarity analysis job for an assignment
+type SimilarityReport struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	AssignmentID primitive.ObjectID `json:"assignment_id" bson:"assignment_id"`
+	RoomID       primitive.ObjectID `json:"room_id" bson:"room_id"`
+	RequestedBy  primitive.ObjectID `json:"requested_by" bson:"requested_by"`
+	Status       string             `json:"status" bson:"status"` // "running", "completed", "failed"
+	Error        string             `json:"error,omitempty" bson:"error,omitempty"`
+	Threshold    float64            `json:"threshold" bson:"threshold"`
+
+	SubmissionCount int              `json:"submission_count" bson:"submission_count"`
+	SkippedFiles    []string         `json:"skipped_files,omitempty" bson:"skipped_files,omitempty"` // Files whose text could not be extracted
+	Pairs           []SimilarityPair `json:"pairs" bson:"pairs"`                                     // Sorted by similarity, highest first
+
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"buddy-server/config"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultAICacheTTL        = 24 * time.Hour
+	defaultAICacheMaxEntries = 1000
+)
+
+// AICache reuses model answers for requests with the same prompt, model and
+// parameters, and collapses identical requests in flight into one call.
+// Answers are kept in memory, so each server instance has its own cache.
+type AICache struct {
+	config config.AICacheConfig
+	group  singleflight.Group
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*aiCacheEntry
+}
+
+type aiCacheEntry struct {
+	resp      LLMResponse
+	roomID    string // The room the answer was generated for, if any
+	storedAt  time.Time
+	expiresAt time.Time
+}
+
+// NewAICache creates a new AI answer cache; unknown features are ignored with a warning
+func NewAICache(config config.AICacheConfig) *AICache {
+	features := make(map[string]bool, len(config.Features))
+	for feature, enabled := range config.Features {
+		if !isLLMFeature(feature) {
+			log.Printf("Ignoring unknown AI feature %q", feature)
+			continue
+		}
+		features[feature] = enabled
+	}
+	config.Features = features
+	if config.TTL <= 0 {
+		config.TTL = defaultAICacheTTL
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = defaultAICacheMaxEntries
+	}
+	return &AICache{
+		config:  config,
+		now:     time.Now,
+		entries: make(map[string]*aiCacheEntry),
+	}
+}
+
+// Wrap caches a feature's provider if the feature opted in. Wrap it around
+// the metered provider so answers from the cache use no tokens.
+func (c *AICache) Wrap(feature string, provider LLMProvider) LLMProvider {
+	if c == nil || !c.config.Features[feature] {
+		return provider
+	}
+	return &cachedLLMProvider{LLMProvider: provider, feature: feature, cache: c}
+}
+
+// InvalidateRoom drops the answers generated for a room and returns how many there were
+func (c *AICache) InvalidateRoom(roomID string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, entry := range c.entries {
+		if entry.roomID == roomID {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (c *AICache) get(key string) (*LLMResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	resp := entry.resp
+	return &resp, true
+}
+
+func (c *AICache) put(key, roomID string, resp *LLMResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.config.MaxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = &aiCacheEntry{
+		resp:      *resp,
+		roomID:    roomID,
+		storedAt:  now,
+		expiresAt: now.Add(c.config.TTL),
+	}
+}
+
+// evict drops expired answers, or the oldest one if none have expired
+func (c *AICache) evict(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.storedAt.Before(oldest) {
+			oldestKey, oldest = key, entry.storedAt
+		}
+	}
+	if len(c.entries) >= c.config.MaxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// aiCacheKey identifies a request by feature, provider and model and
+// everything sent to the model; case and runs of whitespace in the text don't matter
+func aiCacheKey(feature, provider string, req LLMRequest) string {
+	history := make([]LLMMessage, len(req.History))
+	for i, message := range req.History {
+		history[i] = LLMMessage{Role: message.Role, Content: normalizePrompt(message.Content)}
+	}
+	data, _ := json.Marshal(struct {
+		Feature     string
+		Provider    string
+		System      string
+		History     []LLMMessage
+		Prompt      string
+		Temperature *float32
+		MaxTokens   int
+		JSON        bool
+		Schema      *JSONSchema
+	}{feature, provider, normalizePrompt(req.System), history, normalizePrompt(req.Prompt),
+		req.Temperature, req.MaxTokens, req.JSON, req.Schema})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizePrompt(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// cachedLLMProvider answers from the cache when it can and shares one call
+// between identical concurrent requests
+type cachedLLMProvider struct {
+	LLMProvider
+	feature string
+	cache   *AICache
+}
+
+// Generate returns a cached answer or generates and caches one
+func (p *cachedLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	return p.generate(ctx, req, nil)
+}
+
+// GenerateStream streams a generated answer; a cached or shared answer is sent as one delta
+func (p *cachedLLMProvider) GenerateStream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	return p.generate(ctx, req, onDelta)
+}
+
+// SupportsSchema passes on whether the wrapped provider takes JSON schemas
+func (p *cachedLLMProvider) SupportsSchema() bool {
+	native, ok := p.LLMProvider.(schemaLLMProvider)
+	return ok && native.SupportsSchema()
+}
+
+func (p *cachedLLMProvider) generate(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	key := aiCacheKey(p.feature, p.Name(), req)
+	if resp, ok := p.cache.get(key); ok {
+		return resp, sendWhole(resp, onDelta)
+	}
+
+	leader := false
+	result, err, _ := p.cache.group.Do(key, func() (interface{}, error) {
+		leader = true
+		resp, err := p.call(ctx, req, onDelta)
+		if err != nil {
+			return nil, err
+		}
+		roomID := ""
+		if scope := aiUsageFrom(ctx); scope.roomID != nil {
+			roomID = scope.roomID.Hex()
+		}
+		p.cache.put(key, roomID, resp)
+		return resp, nil
+	})
+	if leader {
+		if err != nil {
+			return nil, err
+		}
+		return result.(*LLMResponse), nil
+	}
+
+	// The shared call may have failed only because its requester went away
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return p.call(ctx, req, onDelta)
+	}
+	resp := *result.(*LLMResponse)
+	return &resp, sendWhole(&resp, onDelta)
+}
+
+func (p *cachedLLMProvider) call(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	if onDelta == nil {
+		return p.LLMProvider.Generate(ctx, req)
+	}
+	return generateStream(ctx, p.LLMProvider, req, onDelta)
+}
+
+func sendWhole(resp *LLMResponse, onDelta func(delta string) error) error {
+	if onDelta == nil || resp.Text == "" {
+		return nil
+	}
+	return onDelta(resp.Text)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"buddy-server/config"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// countingLLMProvider answers every prompt with the same text, optionally
+// waiting for release first, and counts its calls
+type countingLLMProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (p *countingLLMProvider) Name() string { return "fake:counting" }
+
+func (p *countingLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	p.calls.Add(1)
+	if p.release != nil {
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &LLMResponse{Text: "Photosynthesis turns light into sugar", Model: "counting", OutputTokens: 5}, nil
+}
+
+func TestAICacheReusesAnswers(t *testing.T) {
+	cache := NewAICache(config.AICacheConfig{Features: map[string]bool{LLMFeatureChat: true}, TTL: time.Hour})
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	fake := &countingLLMProvider{}
+	provider := cache.Wrap(LLMFeatureChat, fake)
+	if cache.Wrap(LLMFeatureGames, fake) != LLMProvider(fake) {
+		t.Error("Expected features that didn't opt in to be left uncached")
+	}
+
+	ctx := context.Background()
+	if _, err := provider.Generate(ctx, LLMRequest{Prompt: "Explain  Photosynthesis"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := provider.Generate(ctx, LLMRequest{Prompt: "explain photosynthesis\n"})
+	if err != nil || resp.Text != "Photosynthesis turns light into sugar" {
+		t.Fatalf("Unexpected cached answer %+v, %v", resp, err)
+	}
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("Expected case and whitespace to be ignored, got %d calls", calls)
+	}
+
+	var streamed []string
+	if _, err := generateStream(ctx, provider, LLMRequest{Prompt: "Explain photosynthesis"}, func(delta string) error {
+		streamed = append(streamed, delta)
+		return nil
+	}); err != nil || len(streamed) != 1 || fake.calls.Load() != 1 {
+		t.Errorf("Expected the cached answer streamed whole, got %q, %v", streamed, err)
+	}
+
+	temperature := float32(0.9)
+	provider.Generate(ctx, LLMRequest{Prompt: "Explain photosynthesis", Temperature: &temperature})
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("Expected different parameters to miss the cache, got %d calls", calls)
+	}
+
+	now = now.Add(time.Hour)
+	provider.Generate(ctx, LLMRequest{Prompt: "Explain photosynthesis"})
+	if calls := fake.calls.Load(); calls != 3 {
+		t.Errorf("Expected an expired answer to be regenerated, got %d calls", calls)
+	}
+}
+
+func TestAICacheCollapsesConcurrentRequests(t *testing.T) {
+	cache := NewAICache(config.AICacheConfig{Features: map[string]bool{LLMFeatureChat: true}})
+	fake := &countingLLMProvider{release: make(chan struct{})}
+	provider := cache.Wrap(LLMFeatureChat, fake)
+
+	var wg sync.WaitGroup
+	texts := make([]string, 5)
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if resp, err := provider.Generate(context.Background(), LLMRequest{Prompt: "Explain osmosis"}); err == nil {
+				texts[i] = resp.Text
+			}
+		}(i)
+	}
+	// Let every request reach the shared call before it completes
+	for fake.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(fake.release)
+	wg.Wait()
+
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("Expected one call for identical concurrent requests, got %d", calls)
+	}
+	for i, text := range texts {
+		if text == "" {
+			t.Errorf("Request %d got no answer", i)
+		}
+	}
+}
+
+func TestAICacheDoesNotKeepErrors(t *testing.T) {
+	cache := NewAICache(config.AICacheConfig{Features: map[string]bool{LLMFeatureChat: true}})
+	fake := &countingLLMProvider{err: errors.New("overloaded")}
+	provider := cache.Wrap(LLMFeatureChat, fake)
+
+	provider.Generate(context.Background(), LLMRequest{Prompt: "Explain osmosis"})
+	fake.err = nil
+	if _, err := provider.Generate(context.Background(), LLMRequest{Prompt: "Explain osmosis"}); err != nil || fake.calls.Load() != 2 {
+		t.Errorf("Expected a failed call to be retried, got %v after %d calls", err, fake.calls.Load())
+	}
+}
+
+func TestAICacheInvalidateRoom(t *testing.T) {
+	cache := NewAICache(config.AICacheConfig{Features: map[string]bool{LLMFeatureRoomAI: true}})
+	fake := &countingLLMProvider{}
+	provider := cache.Wrap(LLMFeatureRoomAI, fake)
+
+	roomID, otherRoomID := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+	provider.Generate(WithAIUsage(context.Background(), "", roomID), LLMRequest{Prompt: "What is on the exam?"})
+	provider.Generate(WithAIUsage(context.Background(), "", otherRoomID), LLMRequest{Prompt: "What is due Friday?"})
+
+	if removed := cache.InvalidateRoom(roomID); removed != 1 {
+		t.Errorf("Expected the room's one answer removed, got %d", removed)
+	}
+	provider.Generate(WithAIUsage(context.Background(), "", roomID), LLMRequest{Prompt: "What is on the exam?"})
+	provider.Generate(WithAIUsage(context.Background(), "", otherRoomID), LLMRequest{Prompt: "What is due Friday?"})
+	if calls := fake.calls.Load(); calls != 3 {
+		t.Errorf("Expected only the invalidated room to regenerate, got %d calls", calls)
+	}
+}
+
+func TestAICacheEvictsOldest(t *testing.T) {
+	cache := NewAICache(config.AICacheConfig{Features: map[string]bool{LLMFeatureChat: true}, MaxEntries: 2})
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	fake := &countingLLMProvider{}
+	provider := cache.Wrap(LLMFeatureChat, fake)
+
+	for _, prompt := range []string{"first", "second", "third", "second"} {
+		provider.Generate(context.Background(), LLMRequest{Prompt: prompt})
+	}
+	if calls := fake.calls.Load(); calls != 3 {
+		t.Errorf("Expected the newer answers kept, got %d calls", calls)
+	}
+	provider.Generate(context.Background(), LLMRequest{Prompt: "first"})
+	if calls := fake.calls.Load(); calls != 4 {
+		t.Errorf("Expected the oldest answer evicted, got %d calls", calls)
+	}
+}
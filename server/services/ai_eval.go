@@ -0,0 +1,797 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"buddy-server/models"
+)
+
+// Evaluation checks
+const (
+	EvalCheckSchema          = "schema"            // The output decoded on the first try
+	EvalCheckQuestionCount   = "question_count"    // As many questions as were asked for
+	EvalCheckOptionCount     = "option_count"      // Every quiz question has the expected number of options
+	EvalCheckAnswerInOptions = "answer_in_options" // Every quiz answer is one of its options
+	EvalCheckNoDuplicates    = "no_duplicates"     // No question, suggestion or recommendation repeats
+	EvalCheckReadingLevel    = "reading_level"     // Text is at or below the case's grade level
+	EvalCheckSuggestionCount = "suggestion_count"  // 3-5 goal suggestions, as the prompt asks
+	EvalCheckAnswered        = "answered"          // A non-empty answer, and for the room AI not a refusal
+	EvalCheckOffTopic        = "off_topic"         // The room AI declines a question outside its course
+	EvalCheckCitations       = "citations"         // The room AI cites an excerpt and only excerpts it was given
+	EvalCheckBlockCount      = "block_count"       // 5-7 schedule blocks, as the prompt asks
+	EvalCheckPlanHours       = "plan_hours"        // The blocks add up to the weekly hours, within a quarter
+	EvalCheckMilestoneDates  = "milestone_dates"   // Every milestone falls within the plan
+)
+
+const defaultQuizOptions = 4
+
+// EvalFixtures are the inputs an evaluation runs each AI feature on
+type EvalFixtures struct {
+	Games           []GameEvalCase       `json:"games"`
+	Reports         []PromptEvalCase     `json:"reports"`
+	GoalSuggestions []PromptEvalCase     `json:"goal_suggestions"`
+	RoomChat        []RoomChatEvalCase   `json:"room_chat"`
+	Explanations    []ExplainEvalCase    `json:"explanations"`
+	Answers         []AnswerEvalCase     `json:"answers"`
+	SmartPlans      []SmartPlanEvalCase  `json:"smart_plans"`
+	Assessments     []AssessmentEvalCase `json:"assessments"`
+}
+
+// GameEvalCase asks for one set of game questions
+type GameEvalCase struct {
+	Name       string  `json:"name"`
+	GameType   string  `json:"game_type"` // "quiz", "flashcards", "fill_blank" or "matching"
+	Subject    string  `json:"subject"`
+	Difficulty string  `json:"difficulty"`
+	Count      int     `json:"count"`
+	Syllabus   string  `json:"syllabus,omitempty"`
+	Options    int     `json:"options,omitempty"`   // Options per quiz question; 4 when unset
+	MaxGrade   float64 `json:"max_grade,omitempty"` // Highest reading grade level allowed; 0 skips the check
+}
+
+// PromptEvalCase fills in a prompt's variables directly, e.g. the data a
+// student report is written from
+type PromptEvalCase struct {
+	Name     string                 `json:"name"`
+	Vars     map[string]interface{} `json:"vars"`
+	MaxGrade float64                `json:"max_grade,omitempty"`
+}
+
+// RoomChatEvalCase asks a room's AI a question with the excerpts retrieval
+// would have found
+type RoomChatEvalCase struct {
+	Name      string                 `json:"name"`
+	Question  string                 `json:"question"`
+	Resources string                 `json:"resources"` // The room's resource catalogue
+	Excerpts  []EvalExcerpt          `json:"excerpts"`
+	Syllabus  string                 `json:"syllabus,omitempty"`
+	Settings  *models.RoomAISettings `json:"settings,omitempty"`
+	OffTopic  bool                   `json:"off_topic,omitempty"` // The question is outside the course and should be declined
+	MaxGrade  float64                `json:"max_grade,omitempty"`
+}
+
+// EvalExcerpt is a passage of a room resource
+type EvalExcerpt struct {
+	Resource string `json:"resource"`
+	Section  string `json:"section,omitempty"`
+	Text     string `json:"text"`
+}
+
+// ExplainEvalCase asks for an explanation of a topic
+type ExplainEvalCase struct {
+	Name     string  `json:"name"`
+	Topic    string  `json:"topic"`
+	Subject  string  `json:"subject"`
+	Level    string  `json:"level"`
+	MaxGrade float64 `json:"max_grade,omitempty"`
+}
+
+// AnswerEvalCase asks a question outside a room
+type AnswerEvalCase struct {
+	Name     string  `json:"name"`
+	Question string  `json:"question"`
+	Subject  string  `json:"subject"`
+	Context  string  `json:"context,omitempty"`
+	MaxGrade float64 `json:"max_grade,omitempty"`
+}
+
+// SmartPlanEvalCase asks for a study plan
+type SmartPlanEvalCase struct {
+	Name        string  `json:"name"`
+	Subject     string  `json:"subject"`
+	Goals       string  `json:"goals"`
+	Description string  `json:"description"`
+	WeeklyHours float64 `json:"weekly_hours"`
+	StartDate   string  `json:"start_date"` // YYYY-MM-DD
+	EndDate     string  `json:"end_date"`
+}
+
+// AssessmentEvalCase asks for the questions that assess a study session
+type AssessmentEvalCase struct {
+	Name            string  `json:"name"`
+	Subject         string  `json:"subject"`
+	Notes           string  `json:"notes"`
+	DurationMinutes int     `json:"duration_minutes"`
+	MaxGrade        float64 `json:"max_grade,omitempty"`
+}
+
+// EvalCheck is the outcome of one check on one output
+type EvalCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// EvalCaseResult is what one fixture produced and how it scored
+type EvalCaseResult struct {
+	Feature       string          `json:"feature"`
+	Name          string          `json:"name"`
+	PromptVersion string          `json:"prompt_version,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Checks        []EvalCheck     `json:"checks"`
+	Calls         int             `json:"calls"` // Model calls, including repairs of invalid output
+	InputTokens   int             `json:"input_tokens"`
+	OutputTokens  int             `json:"output_tokens"`
+	DurationMS    int64           `json:"duration_ms"`
+	Output        json.RawMessage `json:"output,omitempty"`
+}
+
+// EvalReport is the result of running the fixtures through one provider and
+// set of prompt versions
+type EvalReport struct {
+	Label     string            `json:"label"`
+	Provider  string            `json:"provider"`
+	Prompts   map[string]string `json:"prompts"` // Version of each prompt, by name
+	StartedAt time.Time         `json:"started_at"`
+	Cases     []EvalCaseResult  `json:"cases"`
+}
+
+// RunEval runs every fixture through the provider with the registry's pinned
+// prompt versions and scores the outputs. Prompts that aren't pinned use
+// their built-in version rather than the release, so a run doesn't change
+// with what is live.
+func RunEval(ctx context.Context, label string, provider LLMProvider, prompts *PromptRegistry, fixtures EvalFixtures) *EvalReport {
+	if prompts == nil {
+		prompts = NewPromptRegistry(nil)
+	}
+	prompts.PinBuiltins()
+
+	counter := &evalLLMProvider{LLMProvider: provider}
+	ai := NewAIService(counter)
+	ai.SetPrompts(prompts)
+	report := &EvalReport{Label: label, Provider: provider.Name(), Prompts: prompts.PinnedVersions(), StartedAt: time.Now()}
+
+	run := func(feature, name string, generate func() (interface{}, string, error), score func(output interface{}) []EvalCheck) {
+		counter.reset()
+		started := time.Now()
+		output, version, err := generate()
+		result := EvalCaseResult{Feature: feature, Name: name, PromptVersion: version, DurationMS: time.Since(started).Milliseconds()}
+		result.Calls, result.InputTokens, result.OutputTokens = counter.usage()
+		_, text := output.(string) // Text answers have no schema
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+			result.Checks = []EvalCheck{{Name: EvalCheckSchema, Detail: err.Error()}}
+		case text:
+			result.Output, _ = json.Marshal(output)
+			result.Checks = score(output)
+		default:
+			result.Output, _ = json.Marshal(output)
+			schema := EvalCheck{Name: EvalCheckSchema, Passed: result.Calls <= 1}
+			if !schema.Passed {
+				schema.Detail = fmt.Sprintf("valid after %d repairs", result.Calls-1)
+			}
+			result.Checks = append([]EvalCheck{schema}, score(output)...)
+		}
+		report.Cases = append(report.Cases, result)
+	}
+
+	for _, c := range fixtures.Games {
+		c := c
+		run("game_"+c.GameType, c.Name, func() (interface{}, string, error) {
+			set, err := ai.GenerateGameQuestions(ctx, c.GameType, c.Subject, c.Difficulty, c.Count, c.Syllabus)
+			if err != nil {
+				return nil, "", err
+			}
+			return set, set.PromptVersion, nil
+		}, func(output interface{}) []EvalCheck {
+			return scoreGameQuestions(c, output.(*GameQuestionSet))
+		})
+	}
+	for _, c := range fixtures.Reports {
+		c := c
+		run(PromptStudentReport, c.Name, func() (interface{}, string, error) {
+			draft, err := ai.GenerateStudentReport(ctx, "", coercePromptVars(PromptStudentReport, c.Vars))
+			if err != nil {
+				return nil, "", err
+			}
+			return draft, draft.PromptVersion, nil
+		}, func(output interface{}) []EvalCheck {
+			return scoreStudentReport(c, output.(*StudentReportDraft))
+		})
+	}
+	for _, c := range fixtures.GoalSuggestions {
+		c := c
+		run(PromptGoalSuggestions, c.Name, func() (interface{}, string, error) {
+			drafts, err := ai.GenerateDailyGoalSuggestions(ctx, coercePromptVars(PromptGoalSuggestions, c.Vars))
+			if err != nil {
+				return nil, "", err
+			}
+			return drafts, drafts.PromptVersion, nil
+		}, func(output interface{}) []EvalCheck {
+			return scoreGoalSuggestions(c, output.(*GoalSuggestionDrafts))
+		})
+	}
+	for _, c := range fixtures.RoomChat {
+		c := c
+		chunks := make([]scoredChunk, len(c.Excerpts))
+		for i, excerpt := range c.Excerpts {
+			chunks[i].Chunk = models.ResourceChunk{ResourceName: excerpt.Resource, Section: excerpt.Section, Text: excerpt.Text}
+		}
+		run("room_chat", c.Name, func() (interface{}, string, error) {
+			answer, err := ai.GenerateRoomAIResponse(ctx, c.Question, c.Resources, formatSources(chunks), c.Syllabus, &RoomAssistant{Settings: c.Settings})
+			return answer, "", err
+		}, func(output interface{}) []EvalCheck {
+			return scoreRoomChat(c, output.(string))
+		})
+	}
+	for _, c := range fixtures.Explanations {
+		c := c
+		run("explain", c.Name, func() (interface{}, string, error) {
+			explanation, err := ai.ExplainTopic(ctx, c.Topic, c.Subject, c.Level)
+			return explanation, "", err
+		}, func(output interface{}) []EvalCheck {
+			return scoreText(output.(string), c.MaxGrade)
+		})
+	}
+	for _, c := range fixtures.Answers {
+		c := c
+		run("answer", c.Name, func() (interface{}, string, error) {
+			answer, err := ai.AnswerQuestion(ctx, c.Question, c.Subject, c.Context)
+			return answer, "", err
+		}, func(output interface{}) []EvalCheck {
+			return scoreText(output.(string), c.MaxGrade)
+		})
+	}
+	for _, c := range fixtures.SmartPlans {
+		c := c
+		run("smart_plan", c.Name, func() (interface{}, string, error) {
+			plan, err := ai.GenerateSmartStudyPlan(ctx, c.Subject, c.Goals, c.Description, c.WeeklyHours, c.StartDate, c.EndDate)
+			return plan, "", err
+		}, func(output interface{}) []EvalCheck {
+			return scoreSmartPlan(c, output.(*GeneratedPlanResponse))
+		})
+	}
+	for _, c := range fixtures.Assessments {
+		c := c
+		run("assessment", c.Name, func() (interface{}, string, error) {
+			set, err := ai.GenerateStudyAssessmentQuestions(ctx, c.Subject, c.Notes, c.DurationMinutes)
+			return set, "", err
+		}, func(output interface{}) []EvalCheck {
+			return scoreAssessment(c, output.(*AssessmentQuestionSet))
+		})
+	}
+	return report
+}
+
+// evalLLMProvider counts the calls and tokens of each evaluated case
+type evalLLMProvider struct {
+	LLMProvider
+
+	mu                         sync.Mutex
+	calls, inTokens, outTokens int
+}
+
+func (p *evalLLMProvider) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls, p.inTokens, p.outTokens = 0, 0, 0
+}
+
+func (p *evalLLMProvider) usage() (calls, inTokens, outTokens int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls, p.inTokens, p.outTokens
+}
+
+// Generate calls the provider and counts the call
+func (p *evalLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.LLMProvider.Generate(ctx, req)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if resp != nil {
+		p.inTokens += resp.InputTokens
+		p.outTokens += resp.OutputTokens
+	}
+	return resp, err
+}
+
+// SupportsSchema passes on whether the wrapped provider takes JSON schemas
+func (p *evalLLMProvider) SupportsSchema() bool {
+	native, ok := p.LLMProvider.(schemaLLMProvider)
+	return ok && native.SupportsSchema()
+}
+
+// coercePromptVars converts numbers read from JSON to the integer variables
+// a prompt declares
+func coercePromptVars(name string, vars map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(vars))
+	for key, value := range vars {
+		out[key] = value
+	}
+	for _, v := range builtinPrompts[name].variables {
+		if f, ok := out[v.Name].(float64); ok && v.Type == "int" && f == float64(int(f)) {
+			out[v.Name] = int(f)
+		}
+	}
+	return out
+}
+
+func scoreGameQuestions(c GameEvalCase, set *GameQuestionSet) []EvalCheck {
+	count := EvalCheck{Name: EvalCheckQuestionCount, Passed: len(set.Questions) == c.Count}
+	if !count.Passed {
+		count.Detail = fmt.Sprintf("asked for %d, got %d", c.Count, len(set.Questions))
+	}
+	checks := []EvalCheck{count}
+
+	if c.GameType == "quiz" {
+		want := c.Options
+		if want == 0 {
+			want = defaultQuizOptions
+		}
+		options := EvalCheck{Name: EvalCheckOptionCount, Passed: true}
+		answers := EvalCheck{Name: EvalCheckAnswerInOptions, Passed: true}
+		for i, q := range set.Questions {
+			if len(q.Options) != want && options.Passed {
+				options.Passed = false
+				options.Detail = fmt.Sprintf("question %d has %d options, expected %d", i+1, len(q.Options), want)
+			}
+			if !answerInOptions(q.CorrectAnswer, q.Options) && answers.Passed {
+				answers.Passed = false
+				answers.Detail = fmt.Sprintf("question %d: %q is not an option", i+1, q.CorrectAnswer)
+			}
+		}
+		checks = append(checks, options, answers)
+	}
+
+	texts := make([]string, 0, len(set.Questions))
+	for _, q := range set.Questions {
+		texts = append(texts, q.Question)
+	}
+	checks = append(checks, duplicatesCheck("question", texts))
+	if c.MaxGrade > 0 {
+		checks = append(checks, readingLevelCheck(strings.Join(texts, "\n"), c.MaxGrade))
+	}
+	return checks
+}
+
+func scoreStudentReport(c PromptEvalCase, draft *StudentReportDraft) []EvalCheck {
+	checks := []EvalCheck{duplicatesCheck("recommendation", draft.Recommendations)}
+	if c.MaxGrade > 0 {
+		text := draft.Summary + "\n" + strings.Join(draft.Recommendations, "\n")
+		checks = append(checks, readingLevelCheck(text, c.MaxGrade))
+	}
+	return checks
+}
+
+func scoreGoalSuggestions(c PromptEvalCase, drafts *GoalSuggestionDrafts) []EvalCheck {
+	n := len(drafts.Suggestions)
+	count := EvalCheck{Name: EvalCheckSuggestionCount, Passed: n >= 3 && n <= 5}
+	if !count.Passed {
+		count.Detail = fmt.Sprintf("got %d suggestions", n)
+	}
+	titles := make([]string, 0, n)
+	descriptions := make([]string, 0, n)
+	for _, s := range drafts.Suggestions {
+		titles = append(titles, s.Title)
+		descriptions = append(descriptions, s.Title+". "+s.Description)
+	}
+	checks := []EvalCheck{count, duplicatesCheck("suggestion", titles)}
+	if c.MaxGrade > 0 {
+		checks = append(checks, readingLevelCheck(strings.Join(descriptions, "\n"), c.MaxGrade))
+	}
+	return checks
+}
+
+// scoreText checks a free-text answer, such as an explanation
+func scoreText(text string, maxGrade float64) []EvalCheck {
+	checks := []EvalCheck{{Name: EvalCheckAnswered, Passed: strings.TrimSpace(text) != ""}}
+	if !checks[0].Passed {
+		checks[0].Detail = "empty answer"
+	}
+	if maxGrade > 0 {
+		checks = append(checks, readingLevelCheck(text, maxGrade))
+	}
+	return checks
+}
+
+func scoreRoomChat(c RoomChatEvalCase, answer string) []EvalCheck {
+	declined := strings.TrimSpace(answer) == RoomAIOffTopicReply
+	if c.OffTopic {
+		check := EvalCheck{Name: EvalCheckOffTopic, Passed: declined}
+		if !declined {
+			check.Detail = "answered a question outside the course"
+		}
+		return []EvalCheck{check}
+	}
+
+	checks := scoreText(answer, c.MaxGrade)
+	if declined {
+		checks[0] = EvalCheck{Name: EvalCheckAnswered, Detail: "declined a question about the course"}
+	}
+	citations := EvalCheck{Name: EvalCheckCitations, Passed: true}
+	cited := 0
+	for _, m := range citationMarkerPattern.FindAllStringSubmatch(answer, -1) {
+		for _, part := range strings.Split(m[1], ",") {
+			n, _ := strconv.Atoi(strings.TrimSpace(part))
+			if n < 1 || n > len(c.Excerpts) {
+				citations = EvalCheck{Name: EvalCheckCitations, Detail: fmt.Sprintf("cites [%d] but was given %d excerpts", n, len(c.Excerpts))}
+			}
+			cited++
+		}
+	}
+	if cited == 0 && len(c.Excerpts) > 0 {
+		citations = EvalCheck{Name: EvalCheckCitations, Detail: "cites no excerpt"}
+	}
+	return append(checks, citations)
+}
+
+func scoreSmartPlan(c SmartPlanEvalCase, plan *GeneratedPlanResponse) []EvalCheck {
+	n := len(plan.ScheduleBlocks)
+	blocks := EvalCheck{Name: EvalCheckBlockCount, Passed: n >= 5 && n <= 7}
+	if !blocks.Passed {
+		blocks.Detail = fmt.Sprintf("got %d blocks", n)
+	}
+
+	// Validate has checked the block times
+	var hours float64
+	for _, block := range plan.ScheduleBlocks {
+		start, _ := time.Parse("15:04", block.StartTime)
+		end, _ := time.Parse("15:04", block.EndTime)
+		hours += end.Sub(start).Hours()
+	}
+	planHours := EvalCheck{
+		Name:   EvalCheckPlanHours,
+		Passed: math.Abs(hours-c.WeeklyHours) <= c.WeeklyHours/4,
+		Detail: fmt.Sprintf("%.1f hours a week, asked for %.1f", hours, c.WeeklyHours),
+	}
+
+	dates := EvalCheck{Name: EvalCheckMilestoneDates, Passed: true}
+	for i, milestone := range plan.Milestones {
+		// YYYY-MM-DD dates compare as strings
+		if milestone.TargetDate < c.StartDate || milestone.TargetDate > c.EndDate {
+			dates = EvalCheck{Name: EvalCheckMilestoneDates, Detail: fmt.Sprintf("milestone %d is due %s, outside %s to %s", i+1, milestone.TargetDate, c.StartDate, c.EndDate)}
+			break
+		}
+	}
+	return []EvalCheck{blocks, planHours, dates}
+}
+
+func scoreAssessment(c AssessmentEvalCase, set *AssessmentQuestionSet) []EvalCheck {
+	n := len(set.Questions)
+	count := EvalCheck{Name: EvalCheckQuestionCount, Passed: n >= 3 && n <= 5}
+	if !count.Passed {
+		count.Detail = fmt.Sprintf("asked for 3-5, got %d", n)
+	}
+
+	answers := EvalCheck{Name: EvalCheckAnswerInOptions, Passed: true}
+	texts := make([]string, 0, n)
+	for i, q := range set.Questions {
+		if q.QuestionType == "multiple_choice" && !answerInOptions(q.CorrectAnswer, q.Options) && answers.Passed {
+			answers.Passed = false
+			answers.Detail = fmt.Sprintf("question %d: %q is not an option", i+1, q.CorrectAnswer)
+		}
+		texts = append(texts, q.Question)
+	}
+	checks := []EvalCheck{count, answers, duplicatesCheck("question", texts)}
+	if c.MaxGrade > 0 {
+		checks = append(checks, readingLevelCheck(strings.Join(texts, "\n"), c.MaxGrade))
+	}
+	return checks
+}
+
+func duplicatesCheck(what string, texts []string) EvalCheck {
+	seen := make(map[string]bool, len(texts))
+	for _, text := range texts {
+		key := normalizeEvalText(text)
+		if seen[key] {
+			return EvalCheck{Name: EvalCheckNoDuplicates, Detail: fmt.Sprintf("%s repeated: %q", what, text)}
+		}
+		seen[key] = true
+	}
+	return EvalCheck{Name: EvalCheckNoDuplicates, Passed: true}
+}
+
+func readingLevelCheck(text string, maxGrade float64) EvalCheck {
+	grade := ReadingGrade(text)
+	return EvalCheck{
+		Name:   EvalCheckReadingLevel,
+		Passed: grade <= maxGrade,
+		Detail: fmt.Sprintf("grade %.1f, at most %.1f", grade, maxGrade),
+	}
+}
+
+// normalizeEvalText compares text ignoring case, punctuation and spacing
+func normalizeEvalText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// ReadingGrade estimates the US school grade needed to read English text
+// with the Flesch-Kincaid formula; 0 for text without words
+func ReadingGrade(text string) float64 {
+	sentences := 0
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			// A line counts as a sentence even without closing punctuation
+			sentences += max(countSentenceEnds(line), 1)
+		}
+	}
+
+	words, syllables := 0, 0
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' }) {
+		words++
+		syllables += countSyllables(word)
+	}
+	if words == 0 {
+		return 0
+	}
+	grade := 0.39*float64(words)/float64(sentences) + 11.8*float64(syllables)/float64(words) - 15.59
+	return max(grade, 0)
+}
+
+// countSentenceEnds counts runs of ".", "!" and "?" that end a word
+func countSentenceEnds(line string) int {
+	runes := []rune(line)
+	count := 0
+	for i, r := range runes {
+		if !strings.ContainsRune(".!?", r) {
+			continue
+		}
+		if i+1 == len(runes) || unicode.IsSpace(runes[i+1]) {
+			count++
+		}
+	}
+	return count
+}
+
+// countSyllables counts groups of vowels, not counting a silent final "e"
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count, inVowels := 0, false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !inVowels {
+			count++
+		}
+		inVowels = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	return max(count, 1)
+}
+
+// evalRates is the pass rate of each feature's checks, keyed by feature then check
+func evalRates(report *EvalReport) map[string]map[string][2]int {
+	rates := make(map[string]map[string][2]int)
+	for _, c := range report.Cases {
+		if rates[c.Feature] == nil {
+			rates[c.Feature] = make(map[string][2]int)
+		}
+		for _, check := range c.Checks {
+			r := rates[c.Feature][check.Name]
+			if check.Passed {
+				r[0]++
+			}
+			r[1]++
+			rates[c.Feature][check.Name] = r
+		}
+	}
+	return rates
+}
+
+// WriteEvalReport writes a Markdown report of an evaluation; given a second
+// run it compares the two, listing every case whose checks changed
+func WriteEvalReport(w io.Writer, a, b *EvalReport) error {
+	runs := []*EvalReport{a}
+	if b != nil {
+		runs = append(runs, b)
+	}
+
+	var sb strings.Builder
+	if b == nil {
+		fmt.Fprintf(&sb, "# AI evaluation: %s\n\n", a.Label)
+	} else {
+		fmt.Fprintf(&sb, "# AI evaluation: %s vs %s\n\n", a.Label, b.Label)
+	}
+
+	sb.WriteString("| Run | Provider | Cases | Errors | Checks passed | Model calls | Tokens (in/out) |\n")
+	sb.WriteString("|-----|----------|-------|--------|---------------|-------------|-----------------|\n")
+	for _, run := range runs {
+		errors, passed, total, calls, in, out := 0, 0, 0, 0, 0, 0
+		for _, c := range run.Cases {
+			if c.Error != "" {
+				errors++
+			}
+			for _, check := range c.Checks {
+				if check.Passed {
+					passed++
+				}
+				total++
+			}
+			calls += c.Calls
+			in += c.InputTokens
+			out += c.OutputTokens
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %d | %d | %s | %d | %d/%d |\n",
+			run.Label, run.Provider, len(run.Cases), errors, percent(passed, total), calls, in, out)
+	}
+
+	// The version each run resolved every prompt to
+	prompts := map[string]bool{}
+	for _, run := range runs {
+		for name := range run.Prompts {
+			prompts[name] = true
+		}
+	}
+	if b == nil {
+		sb.WriteString("\n| Prompt | Version |\n|--------|---------|\n")
+	} else {
+		fmt.Fprintf(&sb, "\n| Prompt | %s | %s |\n|--------|---|---|\n", a.Label, b.Label)
+	}
+	for _, name := range sortedKeys(prompts) {
+		sb.WriteString("| " + name)
+		for _, run := range runs {
+			version := run.Prompts[name]
+			if version == "" {
+				version = "-"
+			}
+			sb.WriteString(" | " + version)
+		}
+		sb.WriteString(" |\n")
+	}
+
+	sb.WriteString("\n## Checks\n\n")
+	if b == nil {
+		sb.WriteString("| Feature | Check | Passed |\n|---------|-------|--------|\n")
+	} else {
+		fmt.Fprintf(&sb, "| Feature | Check | %s | %s | Change |\n|---------|-------|---|---|--------|\n", a.Label, b.Label)
+	}
+	ratesA, ratesB := evalRates(a), map[string]map[string][2]int{}
+	if b != nil {
+		ratesB = evalRates(b)
+	}
+	for _, feature := range unionKeys(ratesA, ratesB) {
+		checks := map[string]bool{}
+		for name := range ratesA[feature] {
+			checks[name] = true
+		}
+		for name := range ratesB[feature] {
+			checks[name] = true
+		}
+		for _, name := range sortedKeys(checks) {
+			ra, rb := ratesA[feature][name], ratesB[feature][name]
+			if b == nil {
+				fmt.Fprintf(&sb, "| %s | %s | %s |\n", feature, name, fraction(ra))
+				continue
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", feature, name, fraction(ra), fraction(rb), rateChange(ra, rb))
+		}
+	}
+
+	if b == nil {
+		sb.WriteString("\n## Failures\n\n")
+		failures := 0
+		for _, c := range a.Cases {
+			for _, check := range c.Checks {
+				if !check.Passed {
+					fmt.Fprintf(&sb, "- %s / %s: %s failed (%s)\n", c.Feature, c.Name, check.Name, check.Detail)
+					failures++
+				}
+			}
+		}
+		if failures == 0 {
+			sb.WriteString("None.\n")
+		}
+	} else {
+		sb.WriteString("\n## Changed cases\n\n")
+		sb.WriteString(changedCases(a, b))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// changedCases lists the checks that passed in one run and failed in the other
+func changedCases(a, b *EvalReport) string {
+	type key struct{ feature, name string }
+	inB := make(map[key]EvalCaseResult, len(b.Cases))
+	for _, c := range b.Cases {
+		inB[key{c.Feature, c.Name}] = c
+	}
+
+	var sb strings.Builder
+	for _, ca := range a.Cases {
+		cb, ok := inB[key{ca.Feature, ca.Name}]
+		if !ok {
+			continue
+		}
+		checksB := make(map[string]EvalCheck, len(cb.Checks))
+		for _, check := range cb.Checks {
+			checksB[check.Name] = check
+		}
+		for _, check := range ca.Checks {
+			other, ok := checksB[check.Name]
+			if !ok || other.Passed == check.Passed {
+				continue
+			}
+			change, detail := "regressed", other.Detail
+			if other.Passed {
+				change, detail = "improved", check.Detail
+			}
+			fmt.Fprintf(&sb, "- %s / %s: %s %s (%s)\n", ca.Feature, ca.Name, check.Name, change, detail)
+		}
+	}
+	if sb.Len() == 0 {
+		return "None.\n"
+	}
+	return sb.String()
+}
+
+func fraction(r [2]int) string {
+	if r[1] == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d", r[0], r[1])
+}
+
+func percent(passed, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d (%.0f%%)", passed, total, 100*float64(passed)/float64(total))
+}
+
+func rateChange(a, b [2]int) string {
+	if a[1] == 0 || b[1] == 0 {
+		return "-"
+	}
+	delta := 100*float64(b[0])/float64(b[1]) - 100*float64(a[0])/float64(a[1])
+	if delta == 0 {
+		return "="
+	}
+	return fmt.Sprintf("%+.0f%%", delta)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func unionKeys(a, b map[string]map[string][2]int) []string {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return sortedKeys(keys)
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"buddy-server/models"
+)
+
+func checkByName(checks []EvalCheck, name string) *EvalCheck {
+	for i := range checks {
+		if checks[i].Name == name {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+func TestScoreGameQuestions(t *testing.T) {
+	c := GameEvalCase{GameType: "quiz", Count: 2, MaxGrade: 8}
+	good := &GameQuestionSet{Questions: []models.GameQuestion{
+		{Question: "What is 2 + 2?", Options: []string{"3", "4", "5", "6"}, CorrectAnswer: "4"},
+		{Question: "What is 3 + 3?", Options: []string{"5", "6", "7", "8"}, CorrectAnswer: " 6"},
+	}}
+	for _, check := range scoreGameQuestions(c, good) {
+		if !check.Passed {
+			t.Errorf("Expected %s to pass, got %q", check.Name, check.Detail)
+		}
+	}
+
+	bad := &GameQuestionSet{Questions: []models.GameQuestion{
+		{Question: "What is 2 + 2?", Options: []string{"3", "4"}, CorrectAnswer: "4"},
+		{Question: "what is 2+2", Options: []string{"3", "5", "6", "7"}, CorrectAnswer: "Four"},
+		{Question: "Notwithstanding considerable computational difficulties, approximately evaluate the characteristic polynomial?", Options: []string{"a", "b", "c", "d"}, CorrectAnswer: "a"},
+	}}
+	checks := scoreGameQuestions(c, bad)
+	for name, detail := range map[string]string{
+		EvalCheckQuestionCount:   "asked for 2, got 3",
+		EvalCheckOptionCount:     "question 1 has 2 options, expected 4",
+		EvalCheckAnswerInOptions: `question 2: "Four" is not an option`,
+		EvalCheckNoDuplicates:    `question repeated: "what is 2+2"`,
+		EvalCheckReadingLevel:    "at most 8.0",
+	} {
+		check := checkByName(checks, name)
+		if check == nil || check.Passed || !strings.Contains(check.Detail, detail) {
+			t.Errorf("Expected %s to fail with %q, got %+v", name, detail, check)
+		}
+	}
+
+	// Games only accept an option's text, not its letter or a different case
+	for _, answer := range []string{"b", "B", "SIX"} {
+		letter := &GameQuestionSet{Questions: []models.GameQuestion{
+			{Question: "What is 3 + 3?", Options: []string{"5", "six", "7", "8"}, CorrectAnswer: answer},
+		}}
+		if check := checkByName(scoreGameQuestions(GameEvalCase{GameType: "quiz", Count: 1}, letter), EvalCheckAnswerInOptions); check == nil || check.Passed {
+			t.Errorf("Expected answer %q to fail %s, got %+v", answer, EvalCheckAnswerInOptions, check)
+		}
+	}
+
+	// Only quizzes have options
+	if checks := scoreGameQuestions(GameEvalCase{GameType: "flashcards", Count: 1}, &GameQuestionSet{Questions: []models.GameQuestion{{Question: "Hola", CorrectAnswer: "Hello"}}}); checkByName(checks, EvalCheckOptionCount) != nil {
+		t.Error("Expected no option checks for flashcards")
+	}
+}
+
+func TestScoreRoomChatAndPlans(t *testing.T) {
+	c := RoomChatEvalCase{Excerpts: []EvalExcerpt{{Resource: "Notes", Text: "Cells have membranes."}}}
+	for answer, want := range map[string]string{
+		"Cells have membranes [1].":    "",
+		"Cells have membranes.":        "cites no excerpt",
+		"Cells have membranes [1, 2].": "cites [2] but was given 1 excerpts",
+		RoomAIOffTopicReply:            "declined a question about the course",
+		"   ":                          "empty answer",
+	} {
+		var failed []string
+		for _, check := range scoreRoomChat(c, answer) {
+			if !check.Passed {
+				failed = append(failed, check.Detail)
+			}
+		}
+		if (want == "") != (len(failed) == 0) || (want != "" && !strings.Contains(strings.Join(failed, "; "), want)) {
+			t.Errorf("%q: expected failure %q, got %v", answer, want, failed)
+		}
+	}
+	c.OffTopic = true
+	if check := scoreRoomChat(c, "Cells have membranes [1]."); len(check) != 1 || check[0].Name != EvalCheckOffTopic || check[0].Passed {
+		t.Errorf("Expected an off-topic answer to fail, got %+v", check)
+	}
+
+	plan := &GeneratedPlanResponse{
+		ScheduleBlocks: []ScheduleBlockPreview{{StartTime: "09:00", EndTime: "12:00"}},
+		Milestones:     []MilestonePreview{{TargetDate: "2026-11-15"}, {TargetDate: "2026-12-05"}},
+	}
+	checks := scoreSmartPlan(SmartPlanEvalCase{WeeklyHours: 6, StartDate: "2026-11-01", EndDate: "2026-11-30"}, plan)
+	for name, detail := range map[string]string{
+		EvalCheckBlockCount:     "got 1 blocks",
+		EvalCheckPlanHours:      "3.0 hours a week, asked for 6.0",
+		EvalCheckMilestoneDates: "milestone 2 is due 2026-12-05",
+	} {
+		check := checkByName(checks, name)
+		if check == nil || check.Passed || !strings.Contains(check.Detail, detail) {
+			t.Errorf("Expected %s to fail with %q, got %+v", name, detail, check)
+		}
+	}
+}
+
+func TestReadingGrade(t *testing.T) {
+	simple := ReadingGrade("The cat sat on the mat. It was a sunny day.")
+	hard := ReadingGrade("Photosynthetic organisms convert electromagnetic radiation into chemical energy through complicated biochemical pathways.")
+	if simple > 3 || hard < 12 {
+		t.Errorf("Expected simple text below grade 3 and technical text above 12, got %.1f and %.1f", simple, hard)
+	}
+	if ReadingGrade("") != 0 {
+		t.Error("Expected grade 0 without words")
+	}
+}
+
+func TestRunEvalAndCompare(t *testing.T) {
+	fixtures := EvalFixtures{
+		Games: []GameEvalCase{{Name: "fractions", GameType: "quiz", Subject: "Fractions", Difficulty: "easy", Count: 1}},
+		GoalSuggestions: []PromptEvalCase{{Name: "exam", Vars: map[string]interface{}{
+			"recent_activities": []interface{}{"Algebra"}, "current_goals": []interface{}{}, "milestones": []interface{}{},
+			"plan_progress": []interface{}{}, "strengths": []interface{}{}, "weak_areas": []interface{}{"Word problems"},
+		}}},
+	}
+	quiz := `{"questions": [{"question": "1/2 + 1/4?", "options": ["3/4", "2/6", "1/6", "2/4"], "correct_answer": "3/4", "points": 10}]}`
+	goal := `{"title": "%s", "description": "Practice", "subject": "Math", "priority": "high", "reasoning": "Exam soon"}`
+	goals := fmt.Sprintf(`{"suggestions": [`+goal+`, `+goal+`, `+goal+`]}`, "Word problems", "Chapter 4", "Flashcards")
+
+	before := RunEval(context.Background(), "before", NewScriptedLLMProvider([]ScriptedReply{
+		{Match: "Fractions", Reply: quiz},
+		{Match: "goal suggestions", Reply: goals},
+	}), nil, fixtures)
+
+	// The candidate prompt needs a repair and drops an option
+	registry := NewPromptRegistry(nil)
+	if err := registry.PinText(PromptGameQuiz, "draft", "Quiz: {{.count}} questions on {{.subject}} ({{.difficulty}})"); err != nil {
+		t.Fatal(err)
+	}
+	after := RunEval(context.Background(), "after", NewScriptedLLMProvider([]ScriptedReply{
+		{Match: "could not be used", Reply: strings.Replace(quiz, `, "2/4"`, "", 1)},
+		{Match: "Quiz: 1 questions", Reply: `{"questions": "none"}`},
+		{Match: "goal suggestions", Reply: goals},
+	}), registry, fixtures)
+
+	if len(before.Cases) != 2 || before.Cases[0].PromptVersion != "game_quiz@1" || before.Cases[0].Calls != 1 {
+		t.Fatalf("Unexpected run %+v", before.Cases)
+	}
+	if after.Cases[0].PromptVersion != "game_quiz@draft" || after.Cases[0].Calls != 2 {
+		t.Fatalf("Expected the pinned prompt with one repair, got %+v", after.Cases[0])
+	}
+
+	var sb strings.Builder
+	if err := WriteEvalReport(&sb, before, after); err != nil {
+		t.Fatal(err)
+	}
+	report := sb.String()
+	for _, want := range []string{
+		"# AI evaluation: before vs after",
+		"| game_quiz | game_quiz@1 | game_quiz@draft |",
+		"| student_report | student_report@1 | student_report@1 |", // Unpinned prompts use the built-in version
+		"| game_quiz | option_count | 1/1 | 0/1 | -100% |",
+		"| goal_suggestions | suggestion_count | 1/1 | 1/1 | = |",
+		"- game_quiz / fractions: schema regressed (valid after 1 repairs)",
+		"- game_quiz / fractions: option_count regressed (question 1 has 3 options, expected 4)",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected the report to contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "goal_suggestions / exam") {
+		t.Errorf("Expected unchanged cases to be left out:\n%s", report)
+	}
+}
+
+func TestShippedEvalFixtures(t *testing.T) {
+	data, err := os.ReadFile("../cmd/ai-eval/fixtures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures EvalFixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := LoadScriptedLLMProvider("../cmd/ai-eval/fake_script.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake provider's replies pass every check, so failures come from the fixtures
+	report := RunEval(context.Background(), "fake", provider, nil, fixtures)
+	for _, c := range report.Cases {
+		for _, check := range c.Checks {
+			if !check.Passed {
+				t.Errorf("%s / %s: %s failed: %s", c.Feature, c.Name, check.Name, check.Detail)
+			}
+		}
+	}
+}
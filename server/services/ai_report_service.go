@@ -2,9 +2,7 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"strings"
 	"time"
 
 	"buddy-server/database"
@@ -17,24 +15,39 @@ import (
 // AIReportService handles AI-powered report generation
 type AIReportService struct {
 	db                  *database.DB
-	geminiService       *GeminiService
+	aiService           *AIService
 	activityQueryService *ActivityQueryService
 	productivityService *ProductivityService
 }
 
 // NewAIReportService creates a new AI report service
-func NewAIReportService(db *database.DB, geminiService *GeminiService, activityQueryService *ActivityQueryService, productivityService *ProductivityService) *AIReportService {
+func NewAIReportService(db *database.DB, aiService *AIService, activityQueryService *ActivityQueryService, productivityService *ProductivityService) *AIReportService {
 	return &AIReportService{
 		db:                  db,
-		geminiService:       geminiService,
+		aiService:           aiService,
 		activityQueryService: activityQueryService,
 		productivityService: productivityService,
 	}
 }
 
-// GenerateStudentReport generates a comprehensive AI-powered performance report
-func (s *AIReportService) GenerateStudentReport(userID, reportType string) (*models.StudentReport, error) {
-	if s.geminiService == nil {
+// ReportJobParams are the parameters of a report job
+type ReportJobParams struct {
+	ReportType string `json:"report_type"`
+}
+
+// RunReportJob generates the report a job asked for
+func (s *AIReportService) RunReportJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var params ReportJobParams
+	if err := decodeJobParams(job, &params); err != nil {
+		return nil, err
+	}
+	return s.GenerateStudentReport(ctx, job.UserID.Hex(), params.ReportType)
+}
+
+// GenerateStudentReport generates a comprehensive AI-powered performance report;
+// ctx bounds both the AI call and the database work
+func (s *AIReportService) GenerateStudentReport(ctx context.Context, userID, reportType string) (*models.StudentReport, error) {
+	if s.aiService == nil {
 		return nil, errors.New("AI service not available")
 	}
 
@@ -59,9 +72,6 @@ func (s *AIReportService) GenerateStudentReport(userID, reportType string) (*mod
 		return nil, errors.New("invalid user ID")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	// Get activities
 	activities, err := s.activityQueryService.GetMyActivity(userID, "study", 200)
 	if err != nil {
@@ -189,30 +199,11 @@ func (s *AIReportService) GenerateStudentReport(userID, reportType string) (*mod
 	}
 
 	// Generate AI report
-	jsonResponse, err := s.geminiService.GenerateStudentReport(userID, reportData)
+	aiReport, err := s.aiService.GenerateStudentReport(WithAIUsage(ctx, userID, ""), userID, reportData)
 	if err != nil {
 		return nil, err
 	}
 
-	// Clean and parse response
-	cleanedResponse := strings.TrimSpace(jsonResponse)
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```json")
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSuffix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSpace(cleanedResponse)
-
-	var aiReport struct {
-		Summary         string   `json:"summary"`
-		Strengths       []string `json:"strengths"`
-		WeakAreas       []string `json:"weak_areas"`
-		Recommendations []string `json:"recommendations"`
-		OverallScore    float64  `json:"overall_score"`
-	}
-
-	if err := json.Unmarshal([]byte(cleanedResponse), &aiReport); err != nil {
-		return nil, errors.New("failed to parse AI response: " + err.Error())
-	}
-
 	// Create report
 	report := &models.StudentReport{
 		UserID:               userOID,
@@ -229,6 +220,7 @@ func (s *AIReportService) GenerateStudentReport(userID, reportType string) (*mod
 		AvgProductivityScore: avgProductivityScore,
 		GoalsCompleted:       goalsCompleted,
 		MilestonesProgress:   avgMilestoneProgress,
+		PromptVersion:        aiReport.PromptVersion,
 		CreatedAt:            time.Now(),
 	}
 
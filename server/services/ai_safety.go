@@ -0,0 +1,560 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"buddy-server/config"
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// aiSafetyRefusal replaces answers students can't be given
+	aiSafetyRefusal = "I can't help with that. If something is worrying you, please talk to a parent, teacher or another adult you trust. I'm happy to help with your schoolwork!"
+	// aiSafetyExcerptLength bounds the text kept in a flag
+	aiSafetyExcerptLength = 1000
+	// aiSafetyFlagLimit bounds a review list
+	aiSafetyFlagLimit = 200
+)
+
+var (
+	// ErrAIContentBlocked is returned instead of a refusal for requests that expect JSON
+	ErrAIContentBlocked = errors.New("this request was blocked by the safety policy")
+	// ErrSafetyFlagNotFound is returned for a flag that doesn't exist
+	ErrSafetyFlagNotFound = errors.New("flagged interaction not found")
+	// ErrSafetyReviewPermission is returned to users who can't review a student's flags
+	ErrSafetyReviewPermission = errors.New("only the student's parent or the room's teacher can review flagged AI interactions")
+
+	// errSafetyStop stops a stream whose text matched the output block list
+	errSafetyStop = errors.New("stream stopped by the safety policy")
+)
+
+// aiAgeBand is the guidance given to the model for students of an age range
+type aiAgeBand struct {
+	Name         string
+	Instructions string
+	Strict       bool // Use the provider's strictest content filters
+}
+
+// aiSafetyRules apply to every student whatever their age
+const aiSafetyRules = "Never produce sexual, violent, hateful or self-harm content, and do not help with anything dangerous or illegal. " +
+	"Do not ask for personal information such as addresses or phone numbers. " +
+	"If the student mentions being hurt, bullied, in danger or thinking of self-harm, respond kindly and encourage them to talk to a parent, teacher or another trusted adult right away."
+
+// ageBand returns the guidance for a student's age; an unknown age (0) is
+// treated like the youngest students
+func ageBand(age int) aiAgeBand {
+	switch {
+	case age <= 0:
+		return aiAgeBand{"unknown", "You are talking to a school student whose age is unknown, who may be a young child. " +
+			"Use simple words and keep every answer suitable for children.", true}
+	case age < 10:
+		return aiAgeBand{"under-10", "You are talking to a young child under 10. " +
+			"Use short sentences, simple words and friendly examples from everyday life, and keep every answer suitable for young children.", true}
+	case age < 13:
+		return aiAgeBand{"10-12", "You are talking to a student aged 10 to 12. " +
+			"Explain clearly with concrete examples, avoid mature topics and keep every answer suitable for pre-teens.", true}
+	case age < 16:
+		return aiAgeBand{"13-15", "You are talking to a student aged 13 to 15. " +
+			"Keep answers age-appropriate for young teenagers and treat sensitive topics factually and briefly.", false}
+	case age < 18:
+		return aiAgeBand{"16-17", "You are talking to a student aged 16 or 17. " +
+			"Answer as you would for a high-school student.", false}
+	default:
+		return aiAgeBand{"adult", "You are talking to an adult student.", false}
+	}
+}
+
+// SafetyPolicy checks students' messages and the answers they get against block lists
+type SafetyPolicy struct {
+	input    *regexp.Regexp // nil without terms
+	output   *regexp.Regexp
+	holdBack int // Bytes of a streamed answer held back so a blocked term is never sent in part
+}
+
+// NewSafetyPolicy compiles the block lists
+func NewSafetyPolicy(config config.AISafetyConfig) *SafetyPolicy {
+	policy := &SafetyPolicy{}
+	policy.input, _ = termPattern(config.BlockedInput)
+	var longest int
+	policy.output, longest = termPattern(config.BlockedOutput)
+	if policy.output != nil {
+		policy.holdBack = longest + 1
+	}
+	return policy
+}
+
+// termPattern matches any of the terms as whole words, ignoring case and
+// treating any run of whitespace as a space; it also returns the longest term's length
+func termPattern(terms []string) (*regexp.Regexp, int) {
+	var alternatives []string
+	longest := 0
+	for _, term := range terms {
+		words := strings.Fields(strings.ToLower(term))
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		alternatives = append(alternatives, strings.Join(words, `\s+`))
+		if len(term) > longest {
+			longest = len(term)
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil, 0
+	}
+	return regexp.MustCompile(`(?i)(?:^|[^\pL\pN_])(` + strings.Join(alternatives, "|") + `)(?:[^\pL\pN_]|$)`), longest
+}
+
+// blockedInput returns the blocked term in a student's message, or ""
+func (p *SafetyPolicy) blockedInput(text string) string {
+	return matchTerm(p.input, text)
+}
+
+// blockedOutput returns the blocked term in an answer, or ""
+func (p *SafetyPolicy) blockedOutput(text string) string {
+	return matchTerm(p.output, text)
+}
+
+func matchTerm(pattern *regexp.Regexp, text string) string {
+	if pattern == nil || text == "" {
+		return ""
+	}
+	if match := pattern.FindStringSubmatch(text); match != nil {
+		return strings.Join(strings.Fields(strings.ToLower(match[1])), " ")
+	}
+	return ""
+}
+
+// safeStream forwards a streamed answer only once it is known not to contain a
+// blocked term. Words still being written are not checked until they end, and
+// enough text is held back that no part of a blocked term is ever forwarded.
+type safeStream struct {
+	policy  *SafetyPolicy
+	onDelta func(delta string) error
+	text    strings.Builder
+	sent    int
+	blocked string // The blocked term that stopped the stream
+}
+
+func (s *safeStream) write(delta string) error {
+	s.text.WriteString(delta)
+	text := s.text.String()
+
+	// Only text up to the last word boundary is settled
+	settled := strings.LastIndexFunc(text, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if settled < 0 {
+		return nil
+	}
+	_, size := utf8.DecodeRuneInString(text[settled:])
+	settled += size
+	if term := s.policy.blockedOutput(text[:settled]); term != "" {
+		s.blocked = term
+		return errSafetyStop
+	}
+
+	safe := settled - s.policy.holdBack
+	for safe > s.sent && !utf8.RuneStart(text[safe]) {
+		safe--
+	}
+	if safe <= s.sent {
+		return nil
+	}
+	chunk := text[s.sent:safe]
+	s.sent = safe
+	return s.onDelta(chunk)
+}
+
+// flush forwards the rest of an answer that passed the final check
+func (s *safeStream) flush() error {
+	text := s.text.String()
+	if s.sent >= len(text) {
+		return nil
+	}
+	chunk := text[s.sent:]
+	s.sent = len(text)
+	return s.onDelta(chunk)
+}
+
+// safetyStudent is what the safety policy needs to know about a student
+type safetyStudent struct {
+	Age int
+}
+
+// safeLLMProvider applies the safety policy to the calls made for students:
+// an age-appropriate system prompt, block list checks on the way in and out,
+// the provider's strict filters for young students, and a flag for review
+// whenever something is blocked or declined
+type safeLLMProvider struct {
+	LLMProvider
+	feature string
+	policy  *SafetyPolicy
+	student func(ctx context.Context, userID primitive.ObjectID) (*safetyStudent, error) // nil for users who aren't students
+	flag    func(ctx context.Context, flag *models.AISafetyFlag) error
+}
+
+// Generate completes a prompt within the safety policy
+func (p *safeLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	return p.generate(ctx, req, nil)
+}
+
+// GenerateStream streams a completion within the safety policy
+func (p *safeLLMProvider) GenerateStream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	return p.generate(ctx, req, onDelta)
+}
+
+// SupportsSchema passes on whether the wrapped provider takes JSON schemas
+func (p *safeLLMProvider) SupportsSchema() bool {
+	native, ok := p.LLMProvider.(schemaLLMProvider)
+	return ok && native.SupportsSchema()
+}
+
+func (p *safeLLMProvider) generate(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	scope := aiUsageFrom(ctx)
+	if scope.userID == nil {
+		return p.call(ctx, req, onDelta)
+	}
+	student, err := p.student(ctx, *scope.userID)
+	if err != nil {
+		// Without knowing who this is, apply the strictest policy
+		log.Printf("Safety policy: failed to look up user %s: %v", scope.userID.Hex(), err)
+		student = &safetyStudent{}
+	}
+	if student == nil {
+		return p.call(ctx, req, onDelta)
+	}
+
+	flag := models.AISafetyFlag{
+		UserID:  *scope.userID,
+		RoomID:  scope.roomID,
+		Feature: p.feature,
+		Input:   excerpt(req.UserText),
+		Age:     student.Age,
+	}
+	if term := p.policy.blockedInput(req.UserText); term != "" {
+		p.save(ctx, flag, models.AISafetyStageInput, term, "")
+		return p.refuse(req, onDelta, false)
+	}
+
+	band := ageBand(student.Age)
+	req.System = strings.TrimSpace(band.Instructions + " " + aiSafetyRules + "\n\n" + req.System)
+	req.Strict = req.Strict || band.Strict
+
+	var stream *safeStream
+	var resp *LLMResponse
+	if onDelta == nil {
+		resp, err = p.LLMProvider.Generate(ctx, req)
+	} else {
+		stream = &safeStream{policy: p.policy, onDelta: onDelta}
+		resp, err = generateStream(ctx, p.LLMProvider, req, stream.write)
+	}
+	streamed := stream != nil && stream.sent > 0
+	switch {
+	case errors.Is(err, errSafetyStop):
+		p.save(ctx, flag, models.AISafetyStageOutput, stream.blocked, stream.text.String())
+		return p.refuse(req, onDelta, streamed)
+	case errors.Is(err, ErrLLMContentBlocked):
+		p.save(ctx, flag, models.AISafetyStageProvider, err.Error(), "")
+		return p.refuse(req, onDelta, streamed)
+	case err != nil:
+		return nil, err
+	}
+
+	if term := p.policy.blockedOutput(resp.Text); term != "" {
+		p.save(ctx, flag, models.AISafetyStageOutput, term, resp.Text)
+		return p.refuse(req, onDelta, streamed)
+	}
+	if stream != nil {
+		if err := stream.flush(); err != nil {
+			return nil, err
+		}
+	}
+	if p.feature == LLMFeatureRoomAI && strings.HasPrefix(strings.TrimSpace(resp.Text), RoomAIOffTopicReply) {
+		p.save(ctx, flag, models.AISafetyStageOffTopic, "question unrelated to the course", "")
+	}
+	return resp, nil
+}
+
+func (p *safeLLMProvider) call(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	if onDelta == nil {
+		return p.LLMProvider.Generate(ctx, req)
+	}
+	return generateStream(ctx, p.LLMProvider, req, onDelta)
+}
+
+// refuse answers with the refusal, or fails requests that expect JSON
+func (p *safeLLMProvider) refuse(req LLMRequest, onDelta func(delta string) error, streamed bool) (*LLMResponse, error) {
+	if req.JSON || req.Schema != nil {
+		return nil, ErrAIContentBlocked
+	}
+	if onDelta != nil {
+		delta := aiSafetyRefusal
+		if streamed {
+			delta = "\n\n" + delta
+		}
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+	return &LLMResponse{Text: aiSafetyRefusal, Model: "safety"}, nil
+}
+
+func (p *safeLLMProvider) save(ctx context.Context, flag models.AISafetyFlag, stage, reason, output string) {
+	flag.Stage, flag.Reason, flag.Output = stage, reason, excerpt(output)
+	flag.CreatedAt = time.Now()
+	if err := p.flag(ctx, &flag); err != nil {
+		log.Printf("Failed to record AI safety flag: %v", err)
+	}
+}
+
+func excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= aiSafetyExcerptLength {
+		return text
+	}
+	cut := aiSafetyExcerptLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// AISafetyService applies the safety policy to AI calls for students and lets
+// parents and teachers review what it flagged
+type AISafetyService struct {
+	db     *database.DB
+	policy *SafetyPolicy
+}
+
+// NewAISafetyService creates a new AI safety service
+func NewAISafetyService(db *database.DB, config config.AISafetyConfig) *AISafetyService {
+	return &AISafetyService{db: db, policy: NewSafetyPolicy(config)}
+}
+
+// Guard wraps a feature's provider in the safety policy; wrap it around the
+// cache so the policy sees every answer
+func (s *AISafetyService) Guard(feature string, provider LLMProvider) LLMProvider {
+	return &safeLLMProvider{
+		LLMProvider: provider,
+		feature:     feature,
+		policy:      s.policy,
+		student:     s.student,
+		flag:        s.saveFlag,
+	}
+}
+
+// student looks up a user's age, or returns nil if they aren't a student
+func (s *AISafetyService) student(ctx context.Context, userID primitive.ObjectID) (*safetyStudent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := s.db.Collection("users").FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"role": 1, "age": 1})).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != "student" {
+		return nil, nil
+	}
+	return &safetyStudent{Age: user.Age}, nil
+}
+
+// saveFlag stores a flag; the call's context may already be done at the end of a stream
+func (s *AISafetyService) saveFlag(_ context.Context, flag *models.AISafetyFlag) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("ai_safety_flags").InsertOne(ctx, flag)
+	return err
+}
+
+// ListFlags lists flagged interactions a parent or teacher can review, most
+// recent first: a parent's children's, or those in a teacher's rooms (one
+// room with roomID). unreviewed leaves out the ones already reviewed.
+func (s *AISafetyService) ListFlags(userID, roomID string, unreviewed bool) ([]models.AISafetyFlag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	var user models.User
+	if err := s.db.Collection("users").FindOne(ctx, bson.M{"_id": userOID}).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{}
+	switch user.Role {
+	case "parent":
+		children, err := s.ids(ctx, "users", bson.M{"parent_id": userOID})
+		if err != nil {
+			return nil, err
+		}
+		filter["user_id"] = bson.M{"$in": children}
+	case "teacher":
+		rooms, err := s.ids(ctx, "rooms", bson.M{"owner_id": userOID})
+		if err != nil {
+			return nil, err
+		}
+		filter["room_id"] = bson.M{"$in": rooms}
+	default:
+		return nil, ErrSafetyReviewPermission
+	}
+	if roomID != "" {
+		roomOID, err := primitive.ObjectIDFromHex(roomID)
+		if err != nil {
+			return nil, errors.New("invalid room ID")
+		}
+		if user.Role == "teacher" {
+			isOwner, _, err := roomAccess(ctx, s.db, roomOID, userOID)
+			if err != nil {
+				return nil, err
+			}
+			if !isOwner {
+				return nil, ErrSafetyReviewPermission
+			}
+		}
+		filter["room_id"] = roomOID
+	}
+	if unreviewed {
+		filter["reviewed_at"] = bson.M{"$exists": false}
+	}
+
+	cursor, err := s.db.Collection("ai_safety_flags").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(aiSafetyFlagLimit))
+	if err != nil {
+		return nil, err
+	}
+	flags := []models.AISafetyFlag{}
+	if err := cursor.All(ctx, &flags); err != nil {
+		return nil, err
+	}
+	if err := s.nameStudents(ctx, flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// ReviewFlag marks a flagged interaction as reviewed by the student's parent
+// or the teacher of the room it happened in
+func (s *AISafetyService) ReviewFlag(userID, flagID string) (*models.AISafetyFlag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	flagOID, err := primitive.ObjectIDFromHex(flagID)
+	if err != nil {
+		return nil, ErrSafetyFlagNotFound
+	}
+
+	collection := s.db.Collection("ai_safety_flags")
+	var flag models.AISafetyFlag
+	if err := collection.FindOne(ctx, bson.M{"_id": flagOID}).Decode(&flag); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSafetyFlagNotFound
+		}
+		return nil, err
+	}
+
+	allowed, err := s.canReview(ctx, userOID, &flag)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrSafetyReviewPermission
+	}
+
+	now := time.Now()
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": flagOID}, bson.M{
+		"$set": bson.M{"reviewed_by": userOID, "reviewed_at": now},
+	}); err != nil {
+		return nil, err
+	}
+	flag.ReviewedBy, flag.ReviewedAt = &userOID, &now
+	flags := []models.AISafetyFlag{flag}
+	if err := s.nameStudents(ctx, flags); err != nil {
+		return nil, err
+	}
+	return &flags[0], nil
+}
+
+// canReview reports whether a user is the flagged student's parent or owns the flag's room
+func (s *AISafetyService) canReview(ctx context.Context, userID primitive.ObjectID, flag *models.AISafetyFlag) (bool, error) {
+	count, err := s.db.Collection("users").CountDocuments(ctx, bson.M{"_id": flag.UserID, "parent_id": userID})
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+	if flag.RoomID == nil {
+		return false, nil
+	}
+	isOwner, _, err := roomAccess(ctx, s.db, *flag.RoomID, userID)
+	return isOwner, err
+}
+
+// ids returns the IDs of the documents in a collection matching filter
+func (s *AISafetyService) ids(ctx context.Context, collection string, filter bson.M) ([]primitive.ObjectID, error) {
+	cursor, err := s.db.Collection(collection).Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
+
+// nameStudents fills in the students' names
+func (s *AISafetyService) nameStudents(ctx context.Context, flags []models.AISafetyFlag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(flags))
+	for i, flag := range flags {
+		ids[i] = flag.UserID
+	}
+	cursor, err := s.db.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+	names := make(map[primitive.ObjectID]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+	for i := range flags {
+		flags[i].StudentName = names[flags[i].UserID]
+	}
+	return nil
+}
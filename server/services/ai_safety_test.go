@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"buddy-server/config"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestSafeProvider wraps a scripted provider in the safety policy for a
+// student of the given age (-1 for a user who isn't a student) and collects its flags
+func newTestSafeProvider(feature string, age int, config config.AISafetyConfig, replies []ScriptedReply) (*safeLLMProvider, *ScriptedLLMProvider, *[]models.AISafetyFlag) {
+	fake := NewScriptedLLMProvider(replies)
+	var flags []models.AISafetyFlag
+	provider := &safeLLMProvider{
+		LLMProvider: fake,
+		feature:     feature,
+		policy:      NewSafetyPolicy(config),
+		student: func(ctx context.Context, userID primitive.ObjectID) (*safetyStudent, error) {
+			if age < 0 {
+				return nil, nil
+			}
+			return &safetyStudent{Age: age}, nil
+		},
+		flag: func(ctx context.Context, flag *models.AISafetyFlag) error {
+			flags = append(flags, *flag)
+			return nil
+		},
+	}
+	return provider, fake, &flags
+}
+
+func studentContext() context.Context {
+	return WithAIUsage(context.Background(), primitive.NewObjectID().Hex(), "")
+}
+
+func TestSafetyPolicyMatchesWholeTerms(t *testing.T) {
+	policy := NewSafetyPolicy(config.AISafetyConfig{
+		BlockedInput:  []string{"Ass", "hot wire"},
+		BlockedOutput: []string{"ass"},
+	})
+
+	cases := map[string]string{
+		"Which class is the assignment for?": "",
+		"you ASS!":                           "ass",
+		"how do I hot  wire a car":           "hot wire",
+		"ass":                                "ass",
+		"a hot wireless mouse":               "",
+	}
+	for text, want := range cases {
+		if got := policy.blockedInput(text); got != want {
+			t.Errorf("blockedInput(%q) = %q, want %q", text, got, want)
+		}
+	}
+	if got := policy.blockedOutput("how do I hot wire a car"); got != "" {
+		t.Errorf("Expected the input list not to apply to answers, got %q", got)
+	}
+	if got := NewSafetyPolicy(config.AISafetyConfig{}).blockedInput("anything"); got != "" {
+		t.Errorf("Expected an empty policy to allow everything, got %q", got)
+	}
+}
+
+func TestAgeBands(t *testing.T) {
+	cases := []struct {
+		age    int
+		name   string
+		strict bool
+	}{
+		{0, "unknown", true},
+		{8, "under-10", true},
+		{12, "10-12", true},
+		{13, "13-15", false},
+		{17, "16-17", false},
+		{25, "adult", false},
+	}
+	for _, c := range cases {
+		if band := ageBand(c.age); band.Name != c.name || band.Strict != c.strict {
+			t.Errorf("ageBand(%d) = %s (strict %v), want %s (strict %v)", c.age, band.Name, band.Strict, c.name, c.strict)
+		}
+	}
+}
+
+func TestSafeProviderAppliesAgeBand(t *testing.T) {
+	provider, fake, flags := newTestSafeProvider(LLMFeatureChat, 9, config.AISafetyConfig{}, nil)
+
+	resp, err := provider.Generate(studentContext(), LLMRequest{System: "You are Buddy.", Prompt: "What is a fraction?", UserText: "What is a fraction?"})
+	if err != nil || resp.Text != "This is a scripted reply." {
+		t.Fatalf("Unexpected answer %+v, %v", resp, err)
+	}
+	req := fake.Requests()[0]
+	if !strings.HasPrefix(req.System, ageBand(9).Instructions) || !strings.HasSuffix(req.System, "You are Buddy.") {
+		t.Errorf("Expected the age band before the feature's instructions, got %q", req.System)
+	}
+	if !req.Strict {
+		t.Error("Expected strict provider filters for a young student")
+	}
+	if len(*flags) != 0 {
+		t.Errorf("Expected nothing flagged, got %+v", *flags)
+	}
+}
+
+func TestSafeProviderLeavesOtherUsersAlone(t *testing.T) {
+	provider, fake, flags := newTestSafeProvider(LLMFeatureChat, -1, config.AISafetyConfig{BlockedInput: []string{"exam answers"}}, nil)
+
+	if _, err := provider.Generate(studentContext(), LLMRequest{Prompt: "Write the exam answers", UserText: "Write the exam answers"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Generate(context.Background(), LLMRequest{Prompt: "exam answers", UserText: "exam answers"}); err != nil {
+		t.Fatal(err)
+	}
+	if requests := fake.Requests(); len(requests) != 2 || requests[0].System != "" || requests[0].Strict {
+		t.Errorf("Expected requests for teachers and background jobs unchanged, got %+v", requests)
+	}
+	if len(*flags) != 0 {
+		t.Errorf("Expected nothing flagged, got %+v", *flags)
+	}
+}
+
+func TestSafeProviderBlocksInput(t *testing.T) {
+	provider, fake, flags := newTestSafeProvider(LLMFeatureChat, 11, config.AISafetyConfig{BlockedInput: []string{"vape"}}, nil)
+
+	var streamed string
+	resp, err := generateStream(studentContext(), provider, LLMRequest{Prompt: "Where can I buy a vape?", UserText: "Where can I buy a vape?"}, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil || resp.Text != aiSafetyRefusal || streamed != aiSafetyRefusal {
+		t.Fatalf("Expected the refusal, got %+v (streamed %q), %v", resp, streamed, err)
+	}
+	if len(fake.Requests()) != 0 {
+		t.Error("Expected a blocked message never to reach the model")
+	}
+	if len(*flags) != 1 || (*flags)[0].Stage != models.AISafetyStageInput || (*flags)[0].Reason != "vape" || (*flags)[0].Age != 11 {
+		t.Errorf("Expected an input flag, got %+v", *flags)
+	}
+
+	if _, err := provider.Generate(studentContext(), LLMRequest{Prompt: "vape", UserText: "vape", JSON: true}); !errors.Is(err, ErrAIContentBlocked) {
+		t.Errorf("Expected JSON requests to fail instead of getting a refusal, got %v", err)
+	}
+}
+
+func TestSafeProviderBlocksOutput(t *testing.T) {
+	replies := []ScriptedReply{{Reply: "Sure, the answer involves a scalpel and some blood today"}}
+	provider, _, flags := newTestSafeProvider(LLMFeatureChat, 14, config.AISafetyConfig{BlockedOutput: []string{"blood"}}, replies)
+
+	resp, err := provider.Generate(studentContext(), LLMRequest{Prompt: "Tell me about surgery"})
+	if err != nil || resp.Text != aiSafetyRefusal {
+		t.Fatalf("Expected the refusal, got %+v, %v", resp, err)
+	}
+
+	var streamed string
+	resp, err = generateStream(studentContext(), provider, LLMRequest{Prompt: "Tell me about surgery"}, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil || resp.Text != aiSafetyRefusal {
+		t.Fatalf("Expected the refusal, got %+v, %v", resp, err)
+	}
+	if strings.Contains(streamed, "blood") || strings.Contains(streamed, "bl") {
+		t.Errorf("Expected no part of the blocked term streamed, got %q", streamed)
+	}
+	if !strings.HasPrefix(streamed, "Sure, the answer") || !strings.HasSuffix(streamed, aiSafetyRefusal) {
+		t.Errorf("Expected the safe start followed by the refusal, got %q", streamed)
+	}
+	if len(*flags) != 2 || (*flags)[1].Stage != models.AISafetyStageOutput || (*flags)[1].Reason != "blood" || !strings.Contains((*flags)[1].Output, "scalpel") {
+		t.Errorf("Expected output flags, got %+v", *flags)
+	}
+}
+
+func TestSafeProviderStreamsCleanAnswers(t *testing.T) {
+	answer := "Bloodhounds have a great sense of smell, and bloodless answers are fine."
+	provider, _, flags := newTestSafeProvider(LLMFeatureChat, 12, config.AISafetyConfig{BlockedOutput: []string{"blood"}}, []ScriptedReply{{Reply: answer}})
+
+	var streamed string
+	resp, err := generateStream(studentContext(), provider, LLMRequest{Prompt: "Tell me about dogs"}, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil || resp.Text != answer || streamed != answer {
+		t.Fatalf("Expected the whole answer streamed, got %q (%+v), %v", streamed, resp, err)
+	}
+	if len(*flags) != 0 {
+		t.Errorf("Expected nothing flagged, got %+v", *flags)
+	}
+}
+
+func TestSafeProviderFlagsProviderBlocks(t *testing.T) {
+	provider, _, flags := newTestSafeProvider(LLMFeatureChat, 10, config.AISafetyConfig{}, []ScriptedReply{{Match: "weapon", Blocked: true}})
+
+	resp, err := provider.Generate(studentContext(), LLMRequest{Prompt: "How do I build a weapon?", UserText: "How do I build a weapon?"})
+	if err != nil || resp.Text != aiSafetyRefusal {
+		t.Fatalf("Expected the refusal, got %+v, %v", resp, err)
+	}
+	if len(*flags) != 1 || (*flags)[0].Stage != models.AISafetyStageProvider || (*flags)[0].Input != "How do I build a weapon?" {
+		t.Errorf("Expected a provider flag, got %+v", *flags)
+	}
+}
+
+func TestSafeProviderFlagsOffTopicRoomQuestions(t *testing.T) {
+	provider, _, flags := newTestSafeProvider(LLMFeatureRoomAI, 15, config.AISafetyConfig{}, []ScriptedReply{{Match: "football", Reply: RoomAIOffTopicReply}})
+
+	roomID := primitive.NewObjectID()
+	ctx := WithAIUsage(studentContext(), "", roomID.Hex())
+	resp, err := provider.Generate(ctx, LLMRequest{Prompt: "Who won the football?", UserText: "Who won the football?"})
+	if err != nil || resp.Text != RoomAIOffTopicReply {
+		t.Fatalf("Expected the room AI's own refusal, got %+v, %v", resp, err)
+	}
+	if len(*flags) != 1 || (*flags)[0].Stage != models.AISafetyStageOffTopic || (*flags)[0].RoomID == nil || *(*flags)[0].RoomID != roomID {
+		t.Errorf("Expected an off-topic flag for the room, got %+v", *flags)
+	}
+}
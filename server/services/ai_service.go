@@ -0,0 +1,545 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"buddy-server/models"
+)
+
+// llmRequestTimeout bounds a single generation
+const llmRequestTimeout = 2 * time.Minute
+
+// AIService builds the prompts of the AI features and sends them to the
+// feature's LLM provider
+type AIService struct {
+	provider LLMProvider
+	prompts  *PromptRegistry
+}
+
+// NewAIService creates an AI service backed by a provider
+func NewAIService(provider LLMProvider) *AIService {
+	return &AIService{provider: provider}
+}
+
+// SetPrompts sets the registry the versioned prompts come from; without one
+// the built-in versions are used
+func (s *AIService) SetPrompts(prompts *PromptRegistry) {
+	s.prompts = prompts
+}
+
+// Provider returns the provider behind the service
+func (s *AIService) Provider() LLMProvider {
+	return s.provider
+}
+
+// generate sends a prompt with the default generation settings
+func (s *AIService) generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := s.provider.Generate(ctx, LLMRequest{Prompt: prompt})
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(resp.Text) == "" {
+		return "", fmt.Errorf("no response generated")
+	}
+	return resp.Text, nil
+}
+
+// stream sends a prompt containing what the user wrote (userText, checked by
+// the safety policy) and passes the completion to onDelta as it is
+// generated; a nil onDelta waits for the whole completion instead
+func (s *AIService) stream(ctx context.Context, prompt, userText string, onDelta func(delta string) error) (string, error) {
+	return s.streamRequest(ctx, LLMRequest{Prompt: prompt, UserText: userText}, onDelta)
+}
+
+// userText joins the parts of a prompt the user wrote
+func userText(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n")
+}
+
+// streamRequest is stream for a request with instructions or history
+func (s *AIService) streamRequest(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (string, error) {
+	var resp *LLMResponse
+	var err error
+	if onDelta == nil {
+		resp, err = s.provider.Generate(ctx, req)
+	} else {
+		resp, err = generateStream(ctx, s.provider, req, onDelta)
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(resp.Text) == "" {
+		return "", fmt.Errorf("no response generated")
+	}
+	return resp.Text, nil
+}
+
+// Chat sends a chat message and gets a response
+func (s *AIService) Chat(ctx context.Context, message string, contextStr string) (string, error) {
+	return s.ChatStream(ctx, message, contextStr, nil)
+}
+
+// ChatStream is Chat with the response sent to onDelta as it is generated;
+// cancelling ctx stops the generation
+func (s *AIService) ChatStream(ctx context.Context, message, contextStr string, onDelta func(delta string) error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := message
+	if contextStr != "" {
+		prompt = fmt.Sprintf("Context: %s\n\nQuestion: %s", contextStr, message)
+	}
+
+	return s.stream(ctx, prompt, userText(contextStr, message), onDelta)
+}
+
+// ConversationHistory is what the model is told about the earlier turns of a
+// conversation thread
+type ConversationHistory struct {
+	Summary  string             // Summary of the turns older than Messages
+	Messages []LLMMessage       // Recent turns, oldest first
+	Tutor    *models.TutorState // Set to guide the student with hints instead of answering
+}
+
+// request adds the history to a request, with the summary and any tutoring
+// instructions in the instructions
+func (h *ConversationHistory) request(system, prompt string) LLMRequest {
+	req := LLMRequest{System: system, Prompt: prompt}
+	if h == nil {
+		return req
+	}
+	if h.Summary != "" {
+		req.System = strings.TrimSpace(req.System + "\n\nSummary of the earlier conversation with this student:\n" + h.Summary)
+	}
+	if h.Tutor != nil {
+		req.System = strings.TrimSpace(req.System + "\n\n" + socraticInstructions(h.Tutor))
+	}
+	req.History = h.Messages
+	return req
+}
+
+// TutorReplyStream answers the next message of a general tutoring conversation,
+// sending the reply to onDelta as it is generated when onDelta is not nil
+func (s *AIService) TutorReplyStream(ctx context.Context, history *ConversationHistory, message string, onDelta func(delta string) error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	system := "You are Buddy, a friendly AI learning assistant for students. " +
+		"Answer clearly, check understanding, and build on what was discussed earlier in the conversation."
+	req := history.request(system, message)
+	req.UserText = message
+	return s.streamRequest(ctx, req, onDelta)
+}
+
+// SummarizeConversation folds conversation turns into a running summary so a
+// long thread can be continued without sending all of it
+func (s *AIService) SummarizeConversation(ctx context.Context, summary string, messages []LLMMessage) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	var transcript strings.Builder
+	for _, message := range messages {
+		speaker := "Student"
+		if message.Role == "assistant" {
+			speaker = "Assistant"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", speaker, message.Content)
+	}
+	if summary == "" {
+		summary = "(none)"
+	}
+
+	prompt := fmt.Sprintf(
+		"Update the running summary of a tutoring conversation.\n\n"+
+			"Current summary:\n%s\n\n"+
+			"Messages since then:\n%s\n"+
+			"Write a concise summary (at most 200 words) of what the student asked, what was explained, "+
+			"what they found difficult and anything left to follow up on. Return only the summary.",
+		summary, transcript.String(),
+	)
+
+	return s.generate(ctx, prompt)
+}
+
+// ExplainTopic generates an explanation for a topic
+func (s *AIService) ExplainTopic(ctx context.Context, topic, subject, level string) (string, error) {
+	return s.ExplainTopicStream(ctx, topic, subject, level, nil)
+}
+
+// ExplainTopicStream is ExplainTopic with the explanation sent to onDelta as it is generated
+func (s *AIService) ExplainTopicStream(ctx context.Context, topic, subject, level string, onDelta func(delta string) error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"Explain the topic '%s' in %s for a %s level student. "+
+			"Provide a clear, concise explanation with examples.",
+		topic, subject, level,
+	)
+
+	return s.stream(ctx, prompt, userText(topic, subject, level), onDelta)
+}
+
+// AnswerQuestion answers a specific question
+func (s *AIService) AnswerQuestion(ctx context.Context, question, subject, contextStr string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"Subject: %s\nContext: %s\n\nQuestion: %s\n\n"+
+			"Provide a detailed answer with step-by-step explanation if applicable.",
+		subject, contextStr, question,
+	)
+
+	return s.stream(ctx, prompt, userText(subject, contextStr, question), nil)
+}
+
+// GenerateQuestions generates practice questions
+func (s *AIService) GenerateQuestions(ctx context.Context, topic, subject, difficulty string, count int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"Generate %d %s difficulty practice questions about '%s' in %s. "+
+			"Format each question clearly with the question number.",
+		count, difficulty, topic, subject,
+	)
+
+	return s.stream(ctx, prompt, userText(topic, subject), nil)
+}
+
+// SummarizeContent summarizes content
+func (s *AIService) SummarizeContent(ctx context.Context, content string, maxLength int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"Summarize the following content in approximately %d words. "+
+			"Focus on the key points and main concepts:\n\n%s",
+		maxLength, content,
+	)
+
+	return s.stream(ctx, prompt, content, nil)
+}
+
+// GetStudyRecommendations generates study recommendations
+func (s *AIService) GetStudyRecommendations(ctx context.Context, subjects []string, userLevel int, availableHours float64) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"Create personalized study recommendations for a student at level %d "+
+			"who has %.1f hours available per week. Subjects to focus on: %v. "+
+			"Provide a structured study plan with priorities and time allocation.",
+		userLevel, availableHours, subjects,
+	)
+
+	return s.stream(ctx, prompt, strings.Join(subjects, "\n"), nil)
+}
+
+// GenerateSyllabusFromFile generates a structured syllabus from file content
+func (s *AIService) GenerateSyllabusFromFile(ctx context.Context, fileContent, subject, courseName string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"You are an expert curriculum designer. Analyze the following course material and create a structured syllabus.\n\n"+
+			"Course: %s\nSubject: %s\n\n"+
+			"Course Material:\n%s\n\n"+
+			"Generate a JSON response with this exact structure:\n"+
+			`{"description": "Overall course description", "items": [{"title": "Topic 1", "description": "Description", "order": 1}, ...]}`+
+			"\n\nReturn ONLY valid JSON, no markdown formatting, no code blocks.",
+		courseName, subject, fileContent,
+	)
+
+	return s.stream(ctx, prompt, userText(courseName, subject, fileContent), nil)
+}
+
+// GenerateSyllabusFromTopics generates a structured syllabus from a list of topics
+func (s *AIService) GenerateSyllabusFromTopics(ctx context.Context, topics []string, subject, courseName string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	topicsStr := ""
+	for i, topic := range topics {
+		topicsStr += fmt.Sprintf("%d. %s\n", i+1, topic)
+	}
+
+	prompt := fmt.Sprintf(
+		"You are an expert curriculum designer. Create a structured syllabus from the following topics.\n\n"+
+			"Course: %s\nSubject: %s\n\n"+
+			"Topics:\n%s\n\n"+
+			"Generate a JSON response with this exact structure:\n"+
+			`{"description": "Overall course description", "items": [{"title": "Topic 1", "description": "Detailed description", "order": 1}, ...]}`+
+			"\n\nReturn ONLY valid JSON, no markdown formatting, no code blocks.",
+		courseName, subject, topicsStr,
+	)
+
+	return s.stream(ctx, prompt, userText(courseName, subject, strings.Join(topics, "\n")), nil)
+}
+
+// AssessmentQuestionSet is the model's output for study assessment questions
+type AssessmentQuestionSet struct {
+	Questions []models.AIQuestion `json:"questions" schema:"min=1"`
+}
+
+// Validate checks that multiple choice questions have options to choose from
+func (q *AssessmentQuestionSet) Validate() error {
+	for i, question := range q.Questions {
+		if question.QuestionType == "multiple_choice" && len(question.Options) < 2 {
+			return fmt.Errorf("questions[%d] is multiple choice but has %d options", i, len(question.Options))
+		}
+	}
+	return nil
+}
+
+// GenerateStudyAssessmentQuestions generates AI questions to assess study session effectiveness
+func (s *AIService) GenerateStudyAssessmentQuestions(ctx context.Context, subject, notes string, durationMinutes int) (*AssessmentQuestionSet, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"You are an educational assessment expert. Generate 3-5 questions to assess if a student effectively studied the topic.\n\n"+
+			"Subject: %s\n"+
+			"Study Duration: %d minutes\n"+
+			"Student Notes: %s\n\n"+
+			"Generate questions that test understanding and retention. Include a mix of:\n"+
+			"- Multiple choice questions (provide 4 options)\n"+
+			"- Short answer questions\n\n"+
+			"For multiple choice, correct_answer must be copied exactly from the options, not a letter.\n\n"+
+			"Return response in this EXACT JSON format:\n"+
+			`{"questions": [{"question": "...", "question_type": "multiple_choice", "options": ["Mitosis", "Meiosis", "Osmosis", "Diffusion"], "correct_answer": "Mitosis"}, ...]}`,
+		subject, durationMinutes, notes,
+	)
+
+	return generateStructured[AssessmentQuestionSet](ctx, s.provider, LLMRequest{Prompt: prompt, UserText: userText(subject, notes)})
+}
+
+// StudentReportDraft is the model's analysis for a student report
+type StudentReportDraft struct {
+	Summary         string   `json:"summary"`
+	Strengths       []string `json:"strengths"`
+	WeakAreas       []string `json:"weak_areas"`
+	Recommendations []string `json:"recommendations"`
+	OverallScore    float64  `json:"overall_score" schema:"min=0,max=100"`
+	PromptVersion   string   `json:"-"` // Version of the prompt that generated it
+}
+
+// GenerateStudentReport generates a comprehensive performance report
+func (s *AIService) GenerateStudentReport(ctx context.Context, userID string, reportData map[string]interface{}) (*StudentReportDraft, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt, err := s.prompts.render(ctx, PromptStudentReport, reportData)
+	if err != nil {
+		return nil, err
+	}
+	report, err := generateStructured[StudentReportDraft](ctx, s.provider, LLMRequest{Prompt: prompt.text})
+	if err != nil {
+		return nil, err
+	}
+	report.PromptVersion = prompt.version
+	return report, nil
+}
+
+// GoalSuggestionDrafts is the model's output for daily goal suggestions
+type GoalSuggestionDrafts struct {
+	Suggestions []struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Subject     string `json:"subject"`
+		Priority    string `json:"priority" schema:"enum=high|medium|low"`
+		Reasoning   string `json:"reasoning"`
+	} `json:"suggestions" schema:"min=1"`
+	PromptVersion string `json:"-"` // Version of the prompt that generated them
+}
+
+// GenerateDailyGoalSuggestions generates personalized daily goal recommendations
+func (s *AIService) GenerateDailyGoalSuggestions(ctx context.Context, userData map[string]interface{}) (*GoalSuggestionDrafts, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt, err := s.prompts.render(ctx, PromptGoalSuggestions, userData)
+	if err != nil {
+		return nil, err
+	}
+	drafts, err := generateStructured[GoalSuggestionDrafts](ctx, s.provider, LLMRequest{Prompt: prompt.text})
+	if err != nil {
+		return nil, err
+	}
+	drafts.PromptVersion = prompt.version
+	return drafts, nil
+}
+
+// GenerateStudyPlanWithSchedule generates a complete study plan with schedule and milestones
+func (s *AIService) GenerateStudyPlanWithSchedule(ctx context.Context, goals []string, availableHours float64, subjects []string, preferences string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"You are an expert study planner. Create a comprehensive study plan.\n\n"+
+			"Goals: %v\n"+
+			"Available Hours/Week: %.1f\n"+
+			"Subjects: %v\n"+
+			"Preferences: %s\n\n"+
+			"Generate a study plan with weekly schedule and milestones.\n\n"+
+			"Return in this EXACT JSON format:\n"+
+			`{"schedule_blocks": [{"day_of_week": 1, "start_time": "09:00", "end_time": "11:00", "subject": "...", "topic": "...", "block_type": "study"}], "milestones": [{"title": "...", "description": "...", "target_date": "2024-03-15", "progress": 0}]}`,
+		goals, availableHours, subjects, preferences,
+	)
+
+	return s.stream(ctx, prompt, userText(strings.Join(goals, "\n"), strings.Join(subjects, "\n"), preferences), nil)
+}
+
+// GenerateSmartStudyPlan generates a complete study plan based on user description
+func (s *AIService) GenerateSmartStudyPlan(ctx context.Context, subject, goals, description string, weeklyHours float64, startDate, endDate string) (*GeneratedPlanResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf(
+		"Create a study plan in valid JSON format.\n\n"+
+			"Inputs:\n"+
+			"- Subject: %s\n"+
+			"- Goals: %s\n"+
+			"- Description: %s\n"+
+			"- Weekly Hours: %.1f\n"+
+			"- Duration: %s to %s\n\n"+
+			"Rules:\n"+
+			"1. Create 5-7 weekly schedule blocks (distribute across weekdays)\n"+
+			"2. Create 4-5 milestones evenly spread across duration\n"+
+			"3. day_of_week: 0=Sunday, 1=Monday, ..., 6=Saturday\n"+
+			"4. Times in HH:MM format (e.g., 09:00, 14:30)\n"+
+			"5. block_type must be: study, review, or practice\n"+
+			"6. target_date format: YYYY-MM-DD\n\n"+
+			"Return ONLY this JSON structure (no markdown, no code blocks, no extra text):\n"+
+			`{`+"\n"+
+			`"name": "Study Plan Title",`+"\n"+
+			`"description": "Brief plan overview",`+"\n"+
+			`"schedule_blocks": [`+"\n"+
+			`  {"day_of_week": 1, "start_time": "09:00", "end_time": "10:30", "subject": "%s", "topic": "Introduction", "block_type": "study"},`+"\n"+
+			`  {"day_of_week": 3, "start_time": "14:00", "end_time": "15:30", "subject": "%s", "topic": "Practice", "block_type": "practice"}`+"\n"+
+			`],`+"\n"+
+			`"milestones": [`+"\n"+
+			`  {"title": "Milestone 1", "description": "Complete basics", "target_date": "2026-02-10", "progress": 0},`+"\n"+
+			`  {"title": "Milestone 2", "description": "Advanced topics", "target_date": "2026-02-20", "progress": 0}`+"\n"+
+			`]`+"\n"+
+			`}`,
+		subject, goals, description, weeklyHours, startDate, endDate, subject, subject,
+	)
+
+	return generateStructured[GeneratedPlanResponse](ctx, s.provider, LLMRequest{Prompt: prompt, UserText: userText(subject, goals, description)})
+}
+
+// RoomAIOffTopicReply is how the room AI declines questions unrelated to its course
+const RoomAIOffTopicReply = "That question isn't about this course, so I can't help with it here. Try asking about the course materials."
+
+// GenerateRoomAIResponse generates a response grounded in passages retrieved
+// from the room's resources, as the room's assistant (nil for the defaults)
+func (s *AIService) GenerateRoomAIResponse(ctx context.Context, message, roomContext, sources, syllabus string, assistant *RoomAssistant) (string, error) {
+	return s.GenerateRoomAIResponseStream(ctx, message, roomContext, sources, syllabus, assistant, nil, nil)
+}
+
+// GenerateRoomAIResponseStream is GenerateRoomAIResponse continuing a
+// conversation (history may be nil), with the answer sent to onDelta as it is
+// generated when onDelta is not nil
+func (s *AIService) GenerateRoomAIResponseStream(ctx context.Context, message, roomContext, sources, syllabus string, assistant *RoomAssistant, history *ConversationHistory, onDelta func(delta string) error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	// Instructions go in the system prompt; resources can be uploaded by
+	// students, so their content is delimited and treated as data
+	system := assistant.systemPrompt()
+	prompt := fmt.Sprintf(
+		"Course Resources:\n%s\n\n"+
+			"Relevant Excerpts:\n%s\n\n"+
+			"Syllabus:\n%s\n\n"+
+			"Student Question: %s\n\n"+
+			"Provide a helpful, accurate answer based on the course content.",
+		untrustedBlock(untrustedResourcesTag, roomContext),
+		untrustedBlock(untrustedExcerptsTag, sources),
+		untrustedBlock(untrustedSyllabusTag, syllabus),
+		message,
+	)
+
+	req := history.request(system, prompt)
+	req.UserText = message
+	return s.streamRequest(ctx, req, onDelta)
+}
+
+// GameQuestionSet is the model's output for game questions
+type GameQuestionSet struct {
+	Questions     []models.GameQuestion `json:"questions" schema:"min=1"`
+	PromptVersion string                `json:"-"` // Version of the prompt that generated them
+}
+
+// Validate checks that every question can be shown and answered; a question
+// with options is only answerable when its correct_answer is one of them
+func (q *GameQuestionSet) Validate() error {
+	for i, question := range q.Questions {
+		if strings.TrimSpace(question.Question) == "" || strings.TrimSpace(question.CorrectAnswer) == "" {
+			return fmt.Errorf("questions[%d] needs both a question and a correct_answer", i)
+		}
+		if len(question.Options) > 0 && !answerInOptions(question.CorrectAnswer, question.Options) {
+			return fmt.Errorf("questions[%d].correct_answer must be the text of one of its options, not %q", i, question.CorrectAnswer)
+		}
+	}
+	return nil
+}
+
+// answerInOptions reports whether an answer is exactly one of the options,
+// ignoring surrounding spaces. Games mark an answer right only when it is
+// the correct answer's text, so a letter such as "B" is not an option.
+func answerInOptions(answer string, options []string) bool {
+	answer = strings.TrimSpace(answer)
+	for _, option := range options {
+		if strings.TrimSpace(option) == answer {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateGameQuestions generates questions for educational games
+func (s *AIService) GenerateGameQuestions(ctx context.Context, gameType, subject, difficulty string, count int, syllabus string) (*GameQuestionSet, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmRequestTimeout)
+	defer cancel()
+
+	var name string
+	switch gameType {
+	case "quiz":
+		name = PromptGameQuiz
+	case "flashcards":
+		name = PromptGameFlashcards
+	case "fill_blank":
+		name = PromptGameFillBlank
+	case "matching":
+		name = PromptGameMatching
+	default:
+		return nil, fmt.Errorf("unsupported game type: %s", gameType)
+	}
+
+	prompt, err := s.prompts.render(ctx, name, map[string]interface{}{
+		"count":      count,
+		"subject":    subject,
+		"difficulty": difficulty,
+		"syllabus":   syllabus,
+	})
+	if err != nil {
+		return nil, err
+	}
+	set, err := generateStructured[GameQuestionSet](ctx, s.provider, LLMRequest{Prompt: prompt.text})
+	if err != nil {
+		return nil, err
+	}
+	set.PromptVersion = prompt.version
+	return set, nil
+}
@@ -0,0 +1,433 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// threadRecentMessages is how many of the latest messages are always sent verbatim
+	threadRecentMessages = 10
+	// threadSummarizeAfter is how many messages can build up past the summary
+	// before the older ones are folded into it
+	threadSummarizeAfter = 20
+	// threadTitleLength caps titles taken from a thread's first message
+	threadTitleLength = 60
+)
+
+var (
+	// ErrThreadNotFound is returned for an unknown thread or one belonging to another user
+	ErrThreadNotFound = errors.New("conversation thread not found")
+	// ErrThreadTitleRequired is returned when a thread is renamed to an empty title
+	ErrThreadTitleRequired = errors.New("title is required")
+)
+
+// AIThreadService stores conversations with the general tutor and room AIs so
+// students can come back to them, summarizing older turns as threads grow
+type AIThreadService struct {
+	db     *database.DB
+	tutor  *AIService
+	roomAI *RoomAIService
+}
+
+// NewAIThreadService creates a new AI thread service
+func NewAIThreadService(db *database.DB, tutor *AIService, roomAI *RoomAIService) *AIThreadService {
+	return &AIThreadService{db: db, tutor: tutor, roomAI: roomAI}
+}
+
+// CreateThread starts a thread with the general tutor, or with a room's AI
+// when roomID is set; an empty title is taken from the first message. In
+// "tutor" mode the AI guides the student with hints instead of answering.
+func (s *AIThreadService) CreateThread(userID, roomID, title, mode string) (*models.AIThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if mode != "" && mode != models.AIThreadModeTutor {
+		return nil, ErrInvalidThreadMode
+	}
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	roomOID, err := s.threadRoom(ctx, roomID, userOID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	thread := &models.AIThread{
+		UserID:    userOID,
+		RoomID:    roomOID,
+		Title:     strings.TrimSpace(title),
+		Mode:      mode,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	result, err := s.db.Collection("ai_threads").InsertOne(ctx, thread)
+	if err != nil {
+		return nil, err
+	}
+	thread.ID = result.InsertedID.(primitive.ObjectID)
+	return thread, nil
+}
+
+// ListThreads lists a user's tutor threads, or their threads in a room when
+// roomID is set, most recently used first
+func (s *AIThreadService) ListThreads(userID, roomID string) ([]models.AIThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	filter := bson.M{"user_id": userOID, "room_id": bson.M{"$exists": false}}
+	if roomID != "" {
+		roomOID, err := primitive.ObjectIDFromHex(roomID)
+		if err != nil {
+			return nil, errors.New("invalid room ID")
+		}
+		filter["room_id"] = roomOID
+	}
+
+	cursor, err := s.db.Collection("ai_threads").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	threads := []models.AIThread{}
+	if err := cursor.All(ctx, &threads); err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+// GetThread gets one of a user's threads with all of its messages
+func (s *AIThreadService) GetThread(userID, threadID string) (*models.AIThreadDetail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	thread, err := s.ownThread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.threadMessages(ctx, thread.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AIThreadDetail{AIThread: *thread, Messages: messages}, nil
+}
+
+// RenameThread changes the title of one of a user's threads
+func (s *AIThreadService) RenameThread(userID, threadID, title string) (*models.AIThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, ErrThreadTitleRequired
+	}
+	thread, err := s.ownThread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Collection("ai_threads").UpdateOne(ctx, bson.M{"_id": thread.ID},
+		bson.M{"$set": bson.M{"title": title}}); err != nil {
+		return nil, err
+	}
+	thread.Title = title
+	return thread, nil
+}
+
+// DeleteThread deletes one of a user's threads and its messages
+func (s *AIThreadService) DeleteThread(userID, threadID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	thread, err := s.ownThread(ctx, userID, threadID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Collection("ai_thread_messages").DeleteMany(ctx, bson.M{"thread_id": thread.ID}); err != nil {
+		return err
+	}
+	_, err = s.db.Collection("ai_threads").DeleteOne(ctx, bson.M{"_id": thread.ID})
+	return err
+}
+
+// SendMessage continues a thread: the model sees the thread's summary and
+// recent messages, and the question and answer are stored once the answer is
+// complete. The answer is sent to onDelta as it is generated when onDelta is
+// not nil; streamCtx ends the generation early when it is cancelled.
+func (s *AIThreadService) SendMessage(streamCtx context.Context, userID, threadID, message string, onDelta func(delta string) error) (*models.AIThreadReply, error) {
+	streamCtx = WithAIUsage(streamCtx, userID, "")
+	ctx, cancel := context.WithTimeout(streamCtx, 10*time.Second)
+	defer cancel()
+
+	thread, err := s.ownThread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	return s.send(streamCtx, thread, message, nil, onDelta)
+}
+
+// TutorQuestion starts a tutoring thread for a question when the student
+// asked to be tutored or the room it is asked in (roomID) requires it, and
+// returns the first reply; it returns nil when the question can be answered
+// directly. Rooms only require tutoring of questions asked in them.
+func (s *AIThreadService) TutorQuestion(streamCtx context.Context, userID, roomID, message string, requested bool, onDelta func(delta string) error) (*models.AIThreadReply, error) {
+	streamCtx = WithAIUsage(streamCtx, userID, "")
+	ctx, cancel := context.WithTimeout(streamCtx, 10*time.Second)
+	defer cancel()
+
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	roomOID, err := s.threadRoom(ctx, roomID, userOID)
+	if err != nil {
+		return nil, err
+	}
+	var required *tutorRequirement
+	if roomOID != nil {
+		required, err = roomTutoring(ctx, s.db, *roomOID, &userOID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if required == nil && !requested {
+		return nil, nil
+	}
+
+	thread, err := s.CreateThread(userID, roomID, "", models.AIThreadModeTutor)
+	if err != nil {
+		return nil, err
+	}
+	var start *models.TutorState
+	if required != nil {
+		start = required.start(message)
+	}
+	return s.send(streamCtx, thread, message, start, onDelta)
+}
+
+// send continues a thread with a message; start, when set, is the tutoring
+// state to answer it with instead of the thread's own
+func (s *AIThreadService) send(streamCtx context.Context, thread *models.AIThread, message string, start *models.TutorState, onDelta func(delta string) error) (*models.AIThreadReply, error) {
+	ctx, cancel := context.WithTimeout(streamCtx, 10*time.Second)
+	defer cancel()
+
+	ai := s.tutor
+	if thread.RoomID != nil {
+		// Members who have left the room can no longer ask its AI
+		if _, isMember, err := roomAccess(ctx, s.db, *thread.RoomID, thread.UserID); err != nil {
+			return nil, err
+		} else if !isMember {
+			return nil, ErrRoomAccessDenied
+		}
+		ai = nil
+		if s.roomAI != nil {
+			ai = s.roomAI.aiService
+		}
+	}
+	if ai == nil {
+		return nil, ErrLLMNotConfigured
+	}
+
+	stored, err := s.threadMessages(ctx, thread.ID, thread.SummarizedCount)
+	if err != nil {
+		return nil, err
+	}
+	turns := make([]LLMMessage, len(stored))
+	for i, m := range stored {
+		turns[i] = LLMMessage{Role: m.Role, Content: m.Content}
+	}
+	tutor := start
+	if tutor == nil {
+		if tutor, err = s.tutorState(ctx, thread, message); err != nil {
+			return nil, err
+		}
+	}
+	history, folded := foldHistory(streamCtx, ai, thread.Summary, turns)
+	history.Tutor = tutor
+
+	reply := &models.AIThreadMessage{ThreadID: thread.ID, Role: "assistant"}
+	if thread.RoomID != nil {
+		answer, err := s.roomAI.ChatInThread(streamCtx, thread.RoomID.Hex(), message, history, onDelta)
+		if err != nil {
+			return nil, err
+		}
+		reply.Content, reply.Citations = answer.Response, answer.Citations
+	} else {
+		reply.Content, err = ai.TutorReplyStream(streamCtx, history, message, onDelta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A long answer can outlast the lookup timeout
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer saveCancel()
+
+	now := time.Now()
+	question := &models.AIThreadMessage{ThreadID: thread.ID, Role: "user", Content: message, CreatedAt: now}
+	reply.CreatedAt = now.Add(time.Millisecond) // Keeps the pair in order
+	result, err := s.db.Collection("ai_thread_messages").InsertMany(saveCtx, []interface{}{question, reply})
+	if err != nil {
+		return nil, err
+	}
+	reply.ID = result.InsertedIDs[1].(primitive.ObjectID)
+
+	set := bson.M{"updated_at": now}
+	if thread.Title == "" {
+		set["title"] = threadTitle(message)
+	}
+	if tutor != nil {
+		set["tutor"] = tutor
+	}
+	if folded > 0 {
+		set["summary"] = history.Summary
+		set["summarized_count"] = thread.SummarizedCount + folded
+	}
+	var updated models.AIThread
+	err = s.db.Collection("ai_threads").FindOneAndUpdate(saveCtx, bson.M{"_id": thread.ID},
+		bson.M{"$set": set, "$inc": bson.M{"message_count": 2}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AIThreadReply{Thread: &updated, Message: reply}, nil
+}
+
+// tutorState returns how a message in a thread is tutored, or nil to answer
+// it: a problem being tutored continues until its solution is revealed, then
+// the next message starts a new one if the room requires tutoring or the
+// thread is in tutor mode
+func (s *AIThreadService) tutorState(ctx context.Context, thread *models.AIThread, message string) (*models.TutorState, error) {
+	if thread.Tutor != nil && !thread.Tutor.Revealed {
+		return nextTutorState(thread.Tutor, message, thread.Tutor.RevealAfter), nil
+	}
+	if thread.RoomID != nil {
+		required, err := roomTutoring(ctx, s.db, *thread.RoomID, &thread.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if required != nil {
+			return required.start(message), nil
+		}
+	}
+	if thread.Mode == models.AIThreadModeTutor {
+		return nextTutorState(nil, message, defaultTutorRevealAfter), nil
+	}
+	return nil, nil
+}
+
+// foldHistory keeps the latest messages of a thread verbatim and, once more
+// than threadSummarizeAfter have built up past the summary, folds the older
+// ones into it. It returns the history to send and how many more messages the
+// summary now covers. If summarizing fails the thread carries on with a
+// longer window and tries again next time.
+func foldHistory(ctx context.Context, ai *AIService, summary string, messages []LLMMessage) (*ConversationHistory, int) {
+	if len(messages) <= threadSummarizeAfter {
+		return &ConversationHistory{Summary: summary, Messages: messages}, 0
+	}
+
+	older := messages[:len(messages)-threadRecentMessages]
+	folded, err := ai.SummarizeConversation(ctx, summary, older)
+	if err != nil || strings.TrimSpace(folded) == "" {
+		log.Printf("Failed to summarize conversation, keeping the recent window: %v", err)
+		return &ConversationHistory{
+			Summary:  summary,
+			Messages: messages[len(messages)-threadSummarizeAfter:],
+		}, 0
+	}
+	return &ConversationHistory{
+		Summary:  strings.TrimSpace(folded),
+		Messages: messages[len(older):],
+	}, len(older)
+}
+
+// threadTitle names a thread after its first message
+func threadTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if len(title) <= threadTitleLength {
+		return title
+	}
+	title = title[:threadTitleLength]
+	if i := strings.LastIndex(title, " "); i > threadTitleLength/2 {
+		title = title[:i]
+	}
+	return strings.ToValidUTF8(title, "") + "…"
+}
+
+// threadRoom checks that a user can talk to a room's AI; "" is the general tutor
+func (s *AIThreadService) threadRoom(ctx context.Context, roomID string, userOID primitive.ObjectID) (*primitive.ObjectID, error) {
+	if roomID == "" {
+		return nil, nil
+	}
+	roomOID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	_, isMember, err := roomAccess(ctx, s.db, roomOID, userOID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrRoomAccessDenied
+	}
+	return &roomOID, nil
+}
+
+// ownThread loads a thread that belongs to the user
+func (s *AIThreadService) ownThread(ctx context.Context, userID, threadID string) (*models.AIThread, error) {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	threadOID, err := primitive.ObjectIDFromHex(threadID)
+	if err != nil {
+		return nil, ErrThreadNotFound
+	}
+
+	var thread models.AIThread
+	err = s.db.Collection("ai_threads").FindOne(ctx, bson.M{"_id": threadOID, "user_id": userOID}).Decode(&thread)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrThreadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// threadMessages loads a thread's messages oldest first, skipping the first skip
+func (s *AIThreadService) threadMessages(ctx context.Context, threadID primitive.ObjectID, skip int) ([]models.AIThreadMessage, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(skip))
+	cursor, err := s.db.Collection("ai_thread_messages").Find(ctx, bson.M{"thread_id": threadID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.AIThreadMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
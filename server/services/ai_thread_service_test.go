@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func threadTurns(n int) []LLMMessage {
+	turns := make([]LLMMessage, n)
+	for i := range turns {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		turns[i] = LLMMessage{Role: role, Content: fmt.Sprintf("message %d", i)}
+	}
+	return turns
+}
+
+func TestFoldHistory(t *testing.T) {
+	provider := NewScriptedLLMProvider([]ScriptedReply{{Match: "running summary", Reply: "Covered photosynthesis."}})
+	ai := NewAIService(provider)
+
+	// Short threads are sent as they are
+	history, folded := foldHistory(context.Background(), ai, "Earlier", threadTurns(threadSummarizeAfter))
+	if folded != 0 || history.Summary != "Earlier" || len(history.Messages) != threadSummarizeAfter {
+		t.Errorf("Expected the history unchanged, got %d folded, %+v", folded, history)
+	}
+	if len(provider.Requests()) != 0 {
+		t.Error("Expected no summary request for a short thread")
+	}
+
+	// Longer ones keep the recent window and fold the rest into the summary
+	turns := threadTurns(threadSummarizeAfter + 3)
+	history, folded = foldHistory(context.Background(), ai, "Earlier", turns)
+	if folded != len(turns)-threadRecentMessages || history.Summary != "Covered photosynthesis." {
+		t.Errorf("Expected %d messages folded into the new summary, got %d, %q", len(turns)-threadRecentMessages, folded, history.Summary)
+	}
+	if len(history.Messages) != threadRecentMessages || history.Messages[0] != turns[folded] {
+		t.Errorf("Expected the last %d messages verbatim, got %+v", threadRecentMessages, history.Messages)
+	}
+	prompt := provider.Requests()[0].Prompt
+	if !strings.Contains(prompt, "Earlier") || !strings.Contains(prompt, "Student: message 0") || !strings.Contains(prompt, "Assistant: message 1") {
+		t.Errorf("Expected the old summary and folded messages in the prompt, got %q", prompt)
+	}
+
+	// A failed summary keeps a longer window and folds nothing
+	failing := NewAIService(NewScriptedLLMProvider([]ScriptedReply{{Error: "quota exceeded"}}))
+	history, folded = foldHistory(context.Background(), failing, "Earlier", turns)
+	if folded != 0 || history.Summary != "Earlier" || len(history.Messages) != threadSummarizeAfter {
+		t.Errorf("Expected the recent window with the old summary, got %d folded, %+v", folded, history)
+	}
+}
+
+func TestConversationHistoryRequest(t *testing.T) {
+	var none *ConversationHistory
+	if req := none.request("Be kind.", "Hi"); req.System != "Be kind." || req.History != nil {
+		t.Errorf("Expected a plain request without history, got %+v", req)
+	}
+
+	history := &ConversationHistory{Summary: "Asked about fractions.", Messages: threadTurns(2)}
+	req := history.request("Be kind.", "And decimals?")
+	if !strings.HasPrefix(req.System, "Be kind.") || !strings.Contains(req.System, "Asked about fractions.") {
+		t.Errorf("Expected the summary in the instructions, got %q", req.System)
+	}
+	if req.Prompt != "And decimals?" || len(req.History) != 2 {
+		t.Errorf("Expected the message with its history, got %+v", req)
+	}
+}
+
+func TestThreadTitle(t *testing.T) {
+	if title := threadTitle("  How do\nfractions work? "); title != "How do fractions work?" {
+		t.Errorf("Unexpected title %q", title)
+	}
+	long := threadTitle(strings.Repeat("photosynthesis ", 10))
+	if len(long) > threadTitleLength+len("…") || !strings.HasSuffix(long, "photosynthesis…") {
+		t.Errorf("Expected a title cut at a word, got %q", long)
+	}
+}
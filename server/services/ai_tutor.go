@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultTutorRevealAfter is how many attempts a student makes before the solution is shown
+	defaultTutorRevealAfter = 3
+	// maxTutorRevealAfter bounds the attempts a teacher can require
+	maxTutorRevealAfter = 10
+)
+
+var (
+	// ErrInvalidThreadMode is returned for a thread mode other than "" or "tutor"
+	ErrInvalidThreadMode = errors.New(`mode must be "" or "tutor"`)
+	// ErrInvalidRoomAISettings is returned for room AI settings that can't be applied
+	ErrInvalidRoomAISettings = errors.New("invalid room AI settings")
+)
+
+// tutorHints describe the hints given between asking the student to try and
+// revealing the solution, from the gentlest
+var tutorHints = []string{
+	"a conceptual hint: remind them of the idea or rule that applies, without saying how to use it here",
+	"a more specific hint: name the method or formula to use and what the first step is, without doing it",
+	"a worked hint: do the first step for them and ask them to finish the rest",
+}
+
+// socraticInstructions tell the model how to respond at a tutoring state's hint level
+func socraticInstructions(state *models.TutorState) string {
+	instructions := "You are tutoring the student through this problem rather than answering it: " + state.Question + "\n"
+	switch {
+	case state.Revealed:
+		return instructions + fmt.Sprintf("The student has made %d attempts, so now show the complete worked solution step by step. "+
+			"Point out where their attempts went wrong, and finish by checking they understand the method.", state.Attempts)
+	case state.HintLevel == 0:
+		return instructions + "Do NOT give the answer or solve any part of it, even if asked. " +
+			"Ask what they already know or have tried, and give one small nudge towards the first step. Then ask them to try."
+	default:
+		stage := (state.HintLevel - 1) * len(tutorHints) / max(state.RevealAfter-1, 1)
+		return instructions + fmt.Sprintf("The student has made %d of %d attempts before the solution is shown. "+
+			"Do NOT give the final answer, even if asked. If their latest attempt is correct, say so and ask them to explain why it works. "+
+			"Otherwise say what is right about it and where it goes wrong, without correcting it for them, then give %s. "+
+			"End by asking them to try again.",
+			state.Attempts, state.RevealAfter, tutorHints[min(stage, len(tutorHints)-1)])
+	}
+}
+
+// nextTutorState is the tutoring state for a student's next message: it starts
+// a new problem at hint level 0 when there is none or the last one was
+// revealed, and otherwise counts an attempt and raises the hint level until
+// the solution is revealed
+func nextTutorState(current *models.TutorState, message string, revealAfter int) *models.TutorState {
+	if current == nil || current.Revealed {
+		return &models.TutorState{Question: message, RevealAfter: revealAfter}
+	}
+	next := *current
+	next.Attempts++
+	next.HintLevel = min(next.Attempts, next.RevealAfter)
+	next.Revealed = next.HintLevel >= next.RevealAfter
+	return &next
+}
+
+// tutorRequirement is a room's requirement that a student is tutored
+type tutorRequirement struct {
+	revealAfter  int
+	assignmentID *primitive.ObjectID // The open assignment that requires it, if any
+}
+
+// start begins tutoring a problem as the room requires
+func (r *tutorRequirement) start(question string) *models.TutorState {
+	state := nextTutorState(nil, question, r.revealAfter)
+	state.Forced = true
+	state.AssignmentID = r.assignmentID
+	return state
+}
+
+// tutorRoom is the part of a room its AI settings are read from
+type tutorRoom struct {
+	ID         primitive.ObjectID     `bson:"_id"`
+	OwnerID    primitive.ObjectID     `bson:"owner_id"`
+	AISettings *models.RoomAISettings `bson:"ai_settings"`
+}
+
+// roomTutoring returns the tutoring a room requires of a user now, or nil;
+// the room's owner is never required to be tutored, and a nil userID is
+// treated as a student
+func roomTutoring(ctx context.Context, db *database.DB, roomID primitive.ObjectID, userID *primitive.ObjectID) (*tutorRequirement, error) {
+	var room tutorRoom
+	err := db.Collection("rooms").FindOne(ctx, bson.M{"_id": roomID},
+		options.FindOne().SetProjection(bson.M{"owner_id": 1, "ai_settings": 1})).Decode(&room)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID != nil && *userID == room.OwnerID {
+		return nil, nil
+	}
+	return requiredTutoring(ctx, db, &room, time.Now())
+}
+
+// requiredTutoring applies a room's tutor mode at a time
+func requiredTutoring(ctx context.Context, db *database.DB, room *tutorRoom, now time.Time) (*tutorRequirement, error) {
+	settings := room.AISettings
+	if settings == nil {
+		return nil, nil
+	}
+	required := &tutorRequirement{revealAfter: tutorRevealAfter(settings)}
+	switch settings.TutorMode {
+	case models.RoomTutorModeAlways:
+		return required, nil
+	case models.RoomTutorModeAssignments:
+		if len(settings.TutorAssignmentIDs) == 0 {
+			return nil, nil
+		}
+		var assignment struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		err := db.Collection("assignments").FindOne(ctx, bson.M{
+			"_id":        bson.M{"$in": settings.TutorAssignmentIDs},
+			"room_id":    room.ID,
+			"created_at": bson.M{"$lte": now},
+			"due_date":   bson.M{"$gte": now},
+		}, options.FindOne().SetSort(bson.D{{Key: "due_date", Value: 1}}).SetProjection(bson.M{"_id": 1})).Decode(&assignment)
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		required.assignmentID = &assignment.ID
+		return required, nil
+	default:
+		return nil, nil
+	}
+}
+
+// tutorRevealAfter returns the attempts a room allows before revealing a solution
+func tutorRevealAfter(settings *models.RoomAISettings) int {
+	if settings == nil || settings.TutorRevealAfter <= 0 {
+		return defaultTutorRevealAfter
+	}
+	return settings.TutorRevealAfter
+}
+
+// GetSettings returns a room's AI settings to its members
+func (s *RoomAIService) GetSettings(roomID, userID string) (*models.RoomAISettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomOID, userOID, err := parseRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, isMember, err := roomAccess(ctx, s.db, roomOID, userOID); err != nil {
+		return nil, err
+	} else if !isMember {
+		return nil, ErrRoomAccessDenied
+	}
+
+	var room tutorRoom
+	if err := s.db.Collection("rooms").FindOne(ctx, bson.M{"_id": roomOID},
+		options.FindOne().SetProjection(bson.M{"owner_id": 1, "ai_settings": 1})).Decode(&room); err != nil {
+		return nil, err
+	}
+	if room.AISettings == nil {
+		room.AISettings = &models.RoomAISettings{TutorMode: models.RoomTutorModeOptional}
+	}
+	return withSettingDefaults(room.AISettings), nil
+}
+
+// withSettingDefaults fills in the settings a room's AI uses when they are unset
+func withSettingDefaults(settings *models.RoomAISettings) *models.RoomAISettings {
+	settings.TutorRevealAfter = tutorRevealAfter(settings)
+	if settings.Scope == "" {
+		settings.Scope = models.RoomAIScopeSyllabus
+	}
+	if settings.AnswerStyle == "" {
+		settings.AnswerStyle = models.RoomAIStyleBalanced
+	}
+	if settings.SolveAssignments == nil {
+		solve := true
+		settings.SolveAssignments = &solve
+	}
+	return settings
+}
+
+// UpdateSettings replaces a room's AI settings; only the room owner can do
+// this, and tutoring assignments must belong to the room
+func (s *RoomAIService) UpdateSettings(roomID, userID string, settings models.RoomAISettings) (*models.RoomAISettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomOID, userOID, err := parseRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if isOwner, _, err := roomAccess(ctx, s.db, roomOID, userOID); err != nil {
+		return nil, err
+	} else if !isOwner {
+		return nil, ErrRoomAIPermission
+	}
+
+	switch settings.TutorMode {
+	case "":
+		settings.TutorMode = models.RoomTutorModeOptional
+	case models.RoomTutorModeOptional, models.RoomTutorModeAlways, models.RoomTutorModeAssignments:
+	default:
+		return nil, fmt.Errorf("%w: tutor_mode must be optional, always or assignments", ErrInvalidRoomAISettings)
+	}
+	if settings.TutorRevealAfter < 0 || settings.TutorRevealAfter > maxTutorRevealAfter {
+		return nil, fmt.Errorf("%w: tutor_reveal_after must be between 1 and %d", ErrInvalidRoomAISettings, maxTutorRevealAfter)
+	}
+	if settings.TutorMode == models.RoomTutorModeAssignments && len(settings.TutorAssignmentIDs) == 0 {
+		return nil, fmt.Errorf("%w: choose the assignments to tutor", ErrInvalidRoomAISettings)
+	}
+	for _, uploaderType := range settings.TrainingUploaderTypes {
+		if uploaderType != UploaderTypeTeacher && uploaderType != UploaderTypeStudent {
+			return nil, fmt.Errorf("%w: training_uploader_types can only contain teacher and student", ErrInvalidRoomAISettings)
+		}
+	}
+	if err := validateAssistantSettings(&settings); err != nil {
+		return nil, err
+	}
+	if len(settings.TutorAssignmentIDs) > 0 {
+		count, err := s.db.Collection("assignments").CountDocuments(ctx, bson.M{
+			"_id":     bson.M{"$in": settings.TutorAssignmentIDs},
+			"room_id": roomOID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if int(count) != len(settings.TutorAssignmentIDs) {
+			return nil, fmt.Errorf("%w: tutor assignments must belong to this room", ErrInvalidRoomAISettings)
+		}
+	}
+	settings.UpdatedAt = time.Now()
+
+	if _, err := s.db.Collection("rooms").UpdateOne(ctx, bson.M{"_id": roomOID},
+		bson.M{"$set": bson.M{"ai_settings": settings}}); err != nil {
+		return nil, err
+	}
+	return withSettingDefaults(&settings), nil
+}
+
+func parseRoomAndUser(roomID, userID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	roomOID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return roomOID, primitive.NilObjectID, errors.New("invalid room ID")
+	}
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return roomOID, userOID, errors.New("invalid user ID")
+	}
+	return roomOID, userOID, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"buddy-server/models"
+)
+
+func TestNextTutorState(t *testing.T) {
+	state := nextTutorState(nil, "Solve 2x + 3 = 11", 3)
+	if state.Question != "Solve 2x + 3 = 11" || state.HintLevel != 0 || state.Attempts != 0 || state.Revealed {
+		t.Fatalf("Expected a new problem at hint level 0, got %+v", state)
+	}
+
+	for attempt, wantLevel := range []int{1, 2, 3} {
+		state = nextTutorState(state, "x = 5?", 3)
+		if state.Attempts != attempt+1 || state.HintLevel != wantLevel || state.Question != "Solve 2x + 3 = 11" {
+			t.Errorf("Attempt %d: expected hint level %d, got %+v", attempt+1, wantLevel, state)
+		}
+	}
+	if !state.Revealed {
+		t.Error("Expected the solution revealed after the last attempt")
+	}
+
+	next := nextTutorState(state, "Now solve 3x = 12", 3)
+	if next.Question != "Now solve 3x = 12" || next.HintLevel != 0 || next.Revealed {
+		t.Errorf("Expected a message after the solution to start a new problem, got %+v", next)
+	}
+}
+
+func TestTutorRequirementStart(t *testing.T) {
+	required := &tutorRequirement{revealAfter: 5}
+	state := required.start("What is a prime number?")
+	if !state.Forced || state.RevealAfter != 5 || state.HintLevel != 0 {
+		t.Errorf("Expected a forced problem with the room's attempts, got %+v", state)
+	}
+	if reveal := tutorRevealAfter(&models.RoomAISettings{}); reveal != defaultTutorRevealAfter {
+		t.Errorf("Expected the default attempts, got %d", reveal)
+	}
+}
+
+func TestSocraticInstructions(t *testing.T) {
+	state := &models.TutorState{Question: "Solve 2x + 3 = 11", RevealAfter: 4}
+	if text := socraticInstructions(state); !strings.Contains(text, "Do NOT give the answer") || !strings.Contains(text, "Solve 2x + 3 = 11") {
+		t.Errorf("Expected the first reply to ask the student to try, got %q", text)
+	}
+
+	// Hints get more specific with each attempt
+	for level, hint := range tutorHints {
+		state.HintLevel, state.Attempts = level+1, level+1
+		if text := socraticInstructions(state); !strings.Contains(text, hint) || !strings.Contains(text, "Do NOT give the final answer") {
+			t.Errorf("Expected hint %d at level %d, got %q", level, level+1, text)
+		}
+	}
+
+	state.HintLevel, state.Attempts, state.Revealed = 4, 4, true
+	if text := socraticInstructions(state); !strings.Contains(text, "complete worked solution") {
+		t.Errorf("Expected the solution revealed, got %q", text)
+	}
+}
+
+func TestTutorReplyFollowsHintLevel(t *testing.T) {
+	provider := NewScriptedLLMProvider(nil)
+	ai := NewAIService(provider)
+
+	history := &ConversationHistory{Tutor: nextTutorState(nil, "Why is the sky blue?", defaultTutorRevealAfter)}
+	if _, err := ai.TutorReplyStream(context.Background(), history, "Why is the sky blue?", nil); err != nil {
+		t.Fatal(err)
+	}
+	if system := provider.Requests()[0].System; !strings.HasPrefix(system, "You are Buddy") || !strings.Contains(system, "Do NOT give the answer") {
+		t.Errorf("Expected tutoring instructions after the tutor's own, got %q", system)
+	}
+
+	if _, err := ai.TutorReplyStream(context.Background(), &ConversationHistory{}, "Why is the sky blue?", nil); err != nil {
+		t.Fatal(err)
+	}
+	if system := provider.Requests()[1].System; strings.Contains(system, "tutoring the student") {
+		t.Errorf("Expected no tutoring instructions outside tutor mode, got %q", system)
+	}
+}
@@ -0,0 +1,599 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"buddy-server/config"
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AI quota periods
+const (
+	AIQuotaDaily   = config.AIQuotaDaily
+	AIQuotaMonthly = config.AIQuotaMonthly
+)
+
+// aiUsageTopUsers caps the users listed in the admin usage report
+const aiUsageTopUsers = 20
+
+var (
+	// ErrAIQuotaExceeded is matched by the *AIQuotaError returned when a quota runs out
+	ErrAIQuotaExceeded = errors.New("AI quota exceeded")
+	// ErrInvalidUsageRange is returned for usage report dates that aren't YYYY-MM-DD or are out of order
+	ErrInvalidUsageRange = errors.New("from and to must be YYYY-MM-DD dates, from before to")
+)
+
+// organizationLimit is the token quota of a school
+func organizationLimit(c config.AIQuotaConfig, school string) int64 {
+	if limit, ok := c.Organizations[strings.ToLower(strings.TrimSpace(school))]; ok {
+		return limit
+	}
+	return c.OrganizationTokens
+}
+
+// callCost estimates what a call cost from the price of the longest matching model prefix
+func callCost(c config.AIQuotaConfig, model string, inputTokens, outputTokens int) float64 {
+	var price config.AIPrice
+	matched := -1
+	for prefix, p := range c.Prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > matched {
+			price, matched = p, len(prefix)
+		}
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1e6
+}
+
+// quotaPeriod returns when the quota period containing now started and when it resets
+func quotaPeriod(period string, now time.Time) (start, reset time.Time) {
+	now = now.UTC()
+	if period == AIQuotaMonthly {
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// AIQuotaError reports which quota ran out and when it resets
+type AIQuotaError struct {
+	Scope   string    `json:"scope"` // "user" or "organization"
+	Period  string    `json:"period"`
+	Limit   int64     `json:"limit"`
+	Used    int64     `json:"used"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+func (e *AIQuotaError) Error() string {
+	owner := "Your"
+	if e.Scope == "organization" {
+		owner = "Your school's"
+	}
+	return fmt.Sprintf("%s %s AI quota of %d tokens is used up; it resets at %s",
+		owner, e.Period, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrAIQuotaExceeded) match
+func (e *AIQuotaError) Is(target error) bool {
+	return target == ErrAIQuotaExceeded
+}
+
+// aiUsageScope is who the AI calls made with a context are for
+type aiUsageScope struct {
+	userID *primitive.ObjectID
+	roomID *primitive.ObjectID
+}
+
+type aiUsageKey struct{}
+
+// WithAIUsage attributes the AI calls made with ctx to a user and, when roomID
+// is set, a room; empty IDs keep what ctx already has
+func WithAIUsage(ctx context.Context, userID, roomID string) context.Context {
+	scope := aiUsageFrom(ctx)
+	if id, err := primitive.ObjectIDFromHex(userID); err == nil {
+		scope.userID = &id
+	}
+	if id, err := primitive.ObjectIDFromHex(roomID); err == nil {
+		scope.roomID = &id
+	}
+	return context.WithValue(ctx, aiUsageKey{}, scope)
+}
+
+func aiUsageFrom(ctx context.Context) aiUsageScope {
+	scope, _ := ctx.Value(aiUsageKey{}).(aiUsageScope)
+	return scope
+}
+
+// meteredLLMProvider records the usage of every call a feature's provider makes
+type meteredLLMProvider struct {
+	LLMProvider
+	feature string
+	record  func(ctx context.Context, feature, provider string, resp *LLMResponse) error
+}
+
+// Generate completes a prompt and records its usage, including calls that
+// failed after the provider started on them
+func (p *meteredLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.LLMProvider.Generate(ctx, req)
+	if err == nil {
+		p.save(ctx, resp)
+	} else if usage := p.stoppedUsage(ctx, req, resp, ""); usage != nil {
+		p.save(ctx, usage)
+	}
+	return resp, err
+}
+
+// GenerateStream streams a completion and records its usage. A stream that
+// stops early, because the client went away or a safety check stopped it, is
+// recorded with the tokens it used so far, so dropping the connection before
+// the end doesn't make an answer free.
+func (p *meteredLLMProvider) GenerateStream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	var streamed strings.Builder
+	resp, err := generateStream(ctx, p.LLMProvider, req, func(delta string) error {
+		streamed.WriteString(delta)
+		return onDelta(delta)
+	})
+	if err == nil {
+		p.save(ctx, resp)
+	} else if usage := p.stoppedUsage(ctx, req, resp, streamed.String()); usage != nil {
+		p.save(ctx, usage)
+	}
+	return resp, err
+}
+
+// stoppedUsage is the usage of a call that failed: what the provider reported,
+// or otherwise an estimate from the request and the text streamed so far. It is
+// nil when nothing was generated and the call wasn't cut short, e.g. when the
+// provider couldn't be reached.
+func (p *meteredLLMProvider) stoppedUsage(ctx context.Context, req LLMRequest, resp *LLMResponse, streamed string) *LLMResponse {
+	if resp != nil && resp.InputTokens+resp.OutputTokens > 0 {
+		return resp
+	}
+	if streamed == "" && ctx.Err() == nil {
+		return nil
+	}
+	input := estimateTokens(req.System) + estimateTokens(req.Prompt)
+	for _, m := range req.History {
+		input += estimateTokens(m.Content)
+	}
+	_, model, _ := strings.Cut(p.LLMProvider.Name(), ":")
+	return &LLMResponse{Text: streamed, Model: model, InputTokens: input, OutputTokens: estimateTokens(streamed), Estimated: true}
+}
+
+// estimateTokens approximates the tokens in a text at four characters each
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// SupportsSchema passes on whether the wrapped provider takes JSON schemas
+func (p *meteredLLMProvider) SupportsSchema() bool {
+	native, ok := p.LLMProvider.(schemaLLMProvider)
+	return ok && native.SupportsSchema()
+}
+
+func (p *meteredLLMProvider) save(ctx context.Context, resp *LLMResponse) {
+	if err := p.record(ctx, p.feature, p.LLMProvider.Name(), resp); err != nil {
+		log.Printf("Failed to record %s AI usage: %v", p.feature, err)
+	}
+}
+
+// AIUsageService records the tokens each AI call uses, enforces quotas and
+// reports usage and estimated cost
+type AIUsageService struct {
+	db     *database.DB
+	config config.AIQuotaConfig
+}
+
+// NewAIUsageService creates a new AI usage service
+func NewAIUsageService(db *database.DB, config config.AIQuotaConfig) *AIUsageService {
+	if config.Period != AIQuotaMonthly {
+		config.Period = AIQuotaDaily
+	}
+	return &AIUsageService{db: db, config: config}
+}
+
+// Meter wraps a feature's provider so the usage of each call is recorded
+func (s *AIUsageService) Meter(feature string, provider LLMProvider) LLMProvider {
+	return &meteredLLMProvider{LLMProvider: provider, feature: feature, record: s.Record}
+}
+
+// Record stores the usage of one call, attributed to the user and room in ctx
+func (s *AIUsageService) Record(ctx context.Context, feature, provider string, resp *LLMResponse) error {
+	scope := aiUsageFrom(ctx)
+
+	// The call's context may already be done, e.g. at the end of a stream
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record := models.AIUsageRecord{
+		UserID:       scope.userID,
+		RoomID:       scope.roomID,
+		Feature:      feature,
+		Provider:     provider,
+		Model:        resp.Model,
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+		TotalTokens:  resp.InputTokens + resp.OutputTokens,
+		CostUSD:      callCost(s.config, resp.Model, resp.InputTokens, resp.OutputTokens),
+		Estimated:    resp.Estimated,
+		CreatedAt:    time.Now(),
+	}
+	if scope.userID != nil {
+		user, err := s.usageUser(ctx, *scope.userID)
+		if err != nil {
+			return err
+		}
+		record.Role, record.Organization = user.Role, user.School
+	}
+
+	_, err := s.db.Collection("ai_usage").InsertOne(ctx, record)
+	return err
+}
+
+// CheckQuota returns an *AIQuotaError when the user or their school has used
+// up its tokens for the current period
+func (s *AIUsageService) CheckQuota(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+	user, err := s.usageUser(ctx, userOID)
+	if err != nil {
+		return err
+	}
+	start, reset := quotaPeriod(s.config.Period, time.Now())
+
+	if limit := s.config.RoleTokens[user.Role]; limit > 0 {
+		used, err := s.tokensUsed(ctx, bson.M{"user_id": userOID, "created_at": bson.M{"$gte": start}})
+		if err != nil {
+			return err
+		}
+		if used >= limit {
+			return &AIQuotaError{Scope: "user", Period: s.config.Period, Limit: limit, Used: used, ResetAt: reset}
+		}
+	}
+
+	school := user.School
+	if limit := organizationLimit(s.config, school); school != "" && limit > 0 {
+		used, err := s.tokensUsed(ctx, bson.M{"organization": school, "created_at": bson.M{"$gte": start}})
+		if err != nil {
+			return err
+		}
+		if used >= limit {
+			return &AIQuotaError{Scope: "organization", Period: s.config.Period, Limit: limit, Used: used, ResetAt: reset}
+		}
+	}
+	return nil
+}
+
+// AIQuotaUsage is how much of a quota has been used this period; Limit 0 is unlimited
+type AIQuotaUsage struct {
+	Used      int64  `json:"used"`
+	Limit     int64  `json:"limit"`
+	Remaining *int64 `json:"remaining,omitempty"` // Omitted when unlimited
+}
+
+func newAIQuotaUsage(used, limit int64) AIQuotaUsage {
+	usage := AIQuotaUsage{Used: used, Limit: limit}
+	if limit > 0 {
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.Remaining = &remaining
+	}
+	return usage
+}
+
+// AIUserUsage is a user's AI usage in the current quota period
+type AIUserUsage struct {
+	Period       string                `json:"period"`
+	PeriodStart  time.Time             `json:"period_start"`
+	ResetAt      time.Time             `json:"reset_at"`
+	User         AIQuotaUsage          `json:"user"`
+	Organization *AIQuotaUsage         `json:"organization,omitempty"` // Omitted without a school
+	CostUSD      float64               `json:"cost_usd"`
+	ByFeature    []models.AIUsageGroup `json:"by_feature"`
+}
+
+// GetUserUsage reports a user's usage and remaining quota for the current period
+func (s *AIUsageService) GetUserUsage(userID string) (*AIUserUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	user, err := s.usageUser(ctx, userOID)
+	if err != nil {
+		return nil, err
+	}
+	start, reset := quotaPeriod(s.config.Period, time.Now())
+
+	match := bson.M{"user_id": userOID, "created_at": bson.M{"$gte": start}}
+	totals, err := s.usageTotals(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	byFeature, err := s.usageGroups(ctx, match, "$feature", bson.D{{Key: "total_tokens", Value: -1}}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &AIUserUsage{
+		Period:      s.config.Period,
+		PeriodStart: start,
+		ResetAt:     reset,
+		User:        newAIQuotaUsage(totals.TotalTokens, s.config.RoleTokens[user.Role]),
+		CostUSD:     totals.CostUSD,
+		ByFeature:   byFeature,
+	}
+	if school := user.School; school != "" {
+		used, err := s.tokensUsed(ctx, bson.M{"organization": school, "created_at": bson.M{"$gte": start}})
+		if err != nil {
+			return nil, err
+		}
+		organization := newAIQuotaUsage(used, organizationLimit(s.config, school))
+		usage.Organization = &organization
+	}
+	return usage, nil
+}
+
+// AIUsageReport breaks AI usage in a date range down for dashboards; the
+// breakdowns that don't apply to a report are omitted
+type AIUsageReport struct {
+	From           time.Time             `json:"from"`
+	To             time.Time             `json:"to"` // Exclusive
+	Totals         models.AIUsageTotals  `json:"totals"`
+	ByFeature      []models.AIUsageGroup `json:"by_feature"`
+	ByModel        []models.AIUsageGroup `json:"by_model,omitempty"`
+	ByRole         []models.AIUsageGroup `json:"by_role,omitempty"`
+	ByOrganization []models.AIUsageGroup `json:"by_organization,omitempty"`
+	ByUser         []models.AIUsageGroup `json:"by_user"`
+	ByDay          []models.AIUsageGroup `json:"by_day"`
+}
+
+// GetRoomUsage reports the AI usage of a room's members in the room, by
+// student, feature and day (room owner)
+func (s *AIUsageService) GetRoomUsage(roomID, userID, from, to string) (*AIUsageReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomOID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	count, err := s.db.Collection("rooms").CountDocuments(ctx, bson.M{"_id": roomOID})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrRoomNotFound
+	}
+	if isOwner, _, err := roomAccess(ctx, s.db, roomOID, userOID); err != nil {
+		return nil, err
+	} else if !isOwner {
+		return nil, ErrAnalyticsPermission
+	}
+
+	return s.report(ctx, bson.M{"room_id": roomOID}, from, to, false)
+}
+
+// GetUsageReport reports all AI usage by feature, model, role, organization,
+// day and top users (admins)
+func (s *AIUsageService) GetUsageReport(from, to string) (*AIUsageReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	return s.report(ctx, bson.M{}, from, to, true)
+}
+
+// report builds a usage report of the calls matching filter in a date range;
+// the full report adds the model, role and organization breakdowns and
+// lists only the top users
+func (s *AIUsageService) report(ctx context.Context, filter bson.M, from, to string, full bool) (*AIUsageReport, error) {
+	start, end, err := usageRange(from, to, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	filter["created_at"] = bson.M{"$gte": start, "$lt": end}
+
+	report := &AIUsageReport{From: start, To: end}
+	totals, err := s.usageTotals(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	report.Totals = *totals
+
+	byTokens := bson.D{{Key: "total_tokens", Value: -1}}
+	if report.ByFeature, err = s.usageGroups(ctx, filter, "$feature", byTokens, 0); err != nil {
+		return nil, err
+	}
+	if report.ByDay, err = s.usageGroups(ctx, filter,
+		bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+		bson.D{{Key: "_id", Value: 1}}, 0); err != nil {
+		return nil, err
+	}
+	userLimit := int64(0)
+	if full {
+		userLimit = aiUsageTopUsers
+		if report.ByModel, err = s.usageGroups(ctx, filter, "$model", byTokens, 0); err != nil {
+			return nil, err
+		}
+		if report.ByRole, err = s.usageGroups(ctx, filter, "$role", byTokens, 0); err != nil {
+			return nil, err
+		}
+		if report.ByOrganization, err = s.usageGroups(ctx, filter, "$organization", byTokens, 0); err != nil {
+			return nil, err
+		}
+	}
+	if report.ByUser, err = s.usageGroups(ctx, filter, bson.M{"$toString": "$user_id"}, byTokens, userLimit); err != nil {
+		return nil, err
+	}
+	if err := s.nameUsers(ctx, report.ByUser); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// usageRange parses a report's YYYY-MM-DD range; to is inclusive and the
+// range defaults to the 30 days up to today
+func usageRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidUsageRange
+		}
+		end = day.AddDate(0, 0, 1)
+	}
+	start := end.AddDate(0, 0, -30)
+	if from != "" {
+		day, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidUsageRange
+		}
+		start = day
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, ErrInvalidUsageRange
+	}
+	return start, end, nil
+}
+
+// aiUsageUser is what quotas depend on: a user's role and, from their
+// profile, their school
+type aiUsageUser struct {
+	Role   string
+	School string
+}
+
+func (s *AIUsageService) usageUser(ctx context.Context, userID primitive.ObjectID) (*aiUsageUser, error) {
+	var user models.User
+	err := s.db.Collection("users").FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"role": 1})).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profile models.Profile
+	err = s.db.Collection("profiles").FindOne(ctx, bson.M{"user_id": userID},
+		options.FindOne().SetProjection(bson.M{"school": 1})).Decode(&profile)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return &aiUsageUser{Role: user.Role, School: strings.TrimSpace(profile.School)}, nil
+}
+
+// tokensUsed sums the tokens of the calls matching filter
+func (s *AIUsageService) tokensUsed(ctx context.Context, filter bson.M) (int64, error) {
+	totals, err := s.usageTotals(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return totals.TotalTokens, nil
+}
+
+// usageTotals sums the usage of the calls matching filter
+func (s *AIUsageService) usageTotals(ctx context.Context, filter bson.M) (*models.AIUsageTotals, error) {
+	groups, err := s.usageGroups(ctx, filter, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return &models.AIUsageTotals{}, nil
+	}
+	return &groups[0].AIUsageTotals, nil
+}
+
+// usageGroups sums the usage of the calls matching filter grouped by key (nil
+// for one group of everything, missing values group as ""), sorted by sort
+// and cut to limit when it is set
+func (s *AIUsageService) usageGroups(ctx context.Context, filter bson.M, key interface{}, sort bson.D, limit int64) ([]models.AIUsageGroup, error) {
+	if key != nil {
+		key = bson.M{"$ifNull": bson.A{key, ""}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           key,
+			"calls":         bson.M{"$sum": 1},
+			"input_tokens":  bson.M{"$sum": "$input_tokens"},
+			"output_tokens": bson.M{"$sum": "$output_tokens"},
+			"total_tokens":  bson.M{"$sum": "$total_tokens"},
+			"cost_usd":      bson.M{"$sum": "$cost_usd"},
+		}}},
+	}
+	if len(sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sort}})
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+
+	cursor, err := s.db.Collection("ai_usage").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []models.AIUsageGroup{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// nameUsers fills in the names of groups keyed by user ID
+func (s *AIUsageService) nameUsers(ctx context.Context, groups []models.AIUsageGroup) error {
+	ids := make([]primitive.ObjectID, 0, len(groups))
+	for _, group := range groups {
+		if id, err := primitive.ObjectIDFromHex(group.Key); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	cursor, err := s.db.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID.Hex()] = user.Name
+	}
+	for i := range groups {
+		groups[i].Name = names[groups[i].Key]
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"buddy-server/config"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	now := time.Date(2024, 3, 31, 22, 15, 0, 0, time.FixedZone("UTC+3", 3*3600))
+
+	start, reset := quotaPeriod(AIQuotaDaily, now)
+	if !start.Equal(time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)) || !reset.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected daily period %v – %v", start, reset)
+	}
+	start, reset = quotaPeriod(AIQuotaMonthly, now)
+	if !start.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !reset.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected monthly period %v – %v", start, reset)
+	}
+}
+
+func TestAIQuotaConfig(t *testing.T) {
+	quota := config.AIQuotaConfig{
+		OrganizationTokens: 1000,
+		Organizations:      map[string]int64{"lincoln high": 5000, "westside": 0},
+		Prices: map[string]config.AIPrice{
+			"gemini-2.5":       {Input: 1, Output: 2},
+			"gemini-2.5-flash": {Input: 0.3, Output: 2.5},
+		},
+	}
+
+	if limit := organizationLimit(quota, " Lincoln High "); limit != 5000 {
+		t.Errorf("Expected the school's own quota, got %d", limit)
+	}
+	if limit := organizationLimit(quota, "Westside"); limit != 0 {
+		t.Errorf("Expected an unlimited override, got %d", limit)
+	}
+	if limit := organizationLimit(quota, "Other"); limit != 1000 {
+		t.Errorf("Expected the default quota, got %d", limit)
+	}
+
+	if cost := callCost(quota, "gemini-2.5-flash-001", 1_000_000, 100_000); math.Abs(cost-0.55) > 1e-9 {
+		t.Errorf("Expected the longest matching price, got %v", cost)
+	}
+	if cost := callCost(quota, "llama3.1", 1000, 1000); cost != 0 {
+		t.Errorf("Expected no cost without a price, got %v", cost)
+	}
+}
+
+func TestUsageRange(t *testing.T) {
+	now := time.Date(2024, 5, 20, 15, 0, 0, 0, time.UTC)
+
+	start, end, err := usageRange("", "", now)
+	if err != nil || !end.Equal(time.Date(2024, 5, 21, 0, 0, 0, 0, time.UTC)) || end.Sub(start) != 30*24*time.Hour {
+		t.Errorf("Expected the last 30 days, got %v – %v, %v", start, end, err)
+	}
+	start, end, err = usageRange("2024-05-01", "2024-05-01", now)
+	if err != nil || end.Sub(start) != 24*time.Hour {
+		t.Errorf("Expected one whole day, got %v – %v, %v", start, end, err)
+	}
+	if _, _, err := usageRange("2024-05-10", "2024-05-01", now); !errors.Is(err, ErrInvalidUsageRange) {
+		t.Errorf("Expected an out of order range to fail, got %v", err)
+	}
+	if _, _, err := usageRange("May 1", "", now); !errors.Is(err, ErrInvalidUsageRange) {
+		t.Errorf("Expected an invalid date to fail, got %v", err)
+	}
+}
+
+func TestAIQuotaError(t *testing.T) {
+	err := error(&AIQuotaError{Scope: "organization", Period: AIQuotaDaily, Limit: 500, Used: 512,
+		ResetAt: time.Date(2024, 5, 21, 0, 0, 0, 0, time.UTC)})
+	if !errors.Is(err, ErrAIQuotaExceeded) {
+		t.Error("Expected the quota error to match ErrAIQuotaExceeded")
+	}
+	if !strings.Contains(err.Error(), "school's daily AI quota of 500 tokens") || !strings.Contains(err.Error(), "2024-05-21T00:00:00Z") {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestMeteredLLMProvider(t *testing.T) {
+	type call struct {
+		feature, provider string
+		scope             aiUsageScope
+		resp              *LLMResponse
+	}
+	var calls []call
+	provider := &meteredLLMProvider{
+		LLMProvider: NewScriptedLLMProvider([]ScriptedReply{{Match: "fail", Error: "overloaded"}, {Reply: "Two words"}}),
+		feature:     LLMFeatureRoomAI,
+		record: func(ctx context.Context, feature, provider string, resp *LLMResponse) error {
+			calls = append(calls, call{feature, provider, aiUsageFrom(ctx), resp})
+			return nil
+		},
+	}
+
+	userID, roomID := primitive.NewObjectID(), primitive.NewObjectID()
+	ctx := WithAIUsage(context.Background(), userID.Hex(), "")
+	ctx = WithAIUsage(ctx, "", roomID.Hex())
+
+	if _, err := provider.Generate(ctx, LLMRequest{Prompt: "Hello there"}); err != nil {
+		t.Fatal(err)
+	}
+	var streamed string
+	if _, err := generateStream(ctx, provider, LLMRequest{Prompt: "Hi"}, func(delta string) error {
+		streamed += delta
+		return nil
+	}); err != nil || streamed != "Two words" {
+		t.Fatalf("Expected the stream to pass through, got %q, %v", streamed, err)
+	}
+	if _, err := provider.Generate(ctx, LLMRequest{Prompt: "please fail"}); err == nil {
+		t.Fatal("Expected the provider error")
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("Expected the two successful calls to be recorded, got %d", len(calls))
+	}
+	for _, c := range calls {
+		if c.feature != LLMFeatureRoomAI || c.provider != "fake:scripted" || c.resp.OutputTokens != 2 {
+			t.Errorf("Unexpected record %+v", c)
+		}
+		if c.scope.userID == nil || *c.scope.userID != userID || c.scope.roomID == nil || *c.scope.roomID != roomID {
+			t.Errorf("Expected the call attributed to the user and room, got %+v", c.scope)
+		}
+	}
+	if provider.SupportsSchema() {
+		t.Error("Expected the scripted provider's lack of schema support to pass through")
+	}
+}
+
+func TestMeteredLLMProviderStoppedStream(t *testing.T) {
+	var recorded []*LLMResponse
+	provider := &meteredLLMProvider{
+		LLMProvider: NewScriptedLLMProvider([]ScriptedReply{{Reply: "Osmosis moves water across a membrane"}}),
+		feature:     LLMFeatureRoomAI,
+		record: func(ctx context.Context, feature, provider string, resp *LLMResponse) error {
+			recorded = append(recorded, resp)
+			return nil
+		},
+	}
+
+	// The client reads the first word, then disconnects before the end
+	disconnected := errors.New("client went away")
+	_, err := provider.GenerateStream(context.Background(), LLMRequest{System: "Be brief.", Prompt: "What is osmosis?"}, func(delta string) error {
+		return disconnected
+	})
+	if !errors.Is(err, disconnected) {
+		t.Fatalf("Expected the stream to stop, got %v", err)
+	}
+	if len(recorded) != 1 || !recorded[0].Estimated || recorded[0].InputTokens != 7 || recorded[0].OutputTokens != 2 {
+		t.Fatalf("Expected the streamed tokens to be estimated and recorded, got %+v", recorded)
+	}
+}
@@ -14,6 +14,21 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var (
+	// ErrAssignmentNotFound is returned for an assignment that doesn't exist
+	ErrAssignmentNotFound = errors.New("assignment not found")
+	// ErrAssignmentAccessDenied is returned when the user isn't in the assignment's room, or doesn't own it
+	ErrAssignmentAccessDenied = errors.New("you don't have access to this assignment")
+	// ErrAssignmentPastDue is returned for a submission after the assignment's due date
+	ErrAssignmentPastDue = errors.New("the assignment's due date has passed")
+	// ErrPeerReviewsAssigned is returned for a submission after its assignment's review pairs were made
+	ErrPeerReviewsAssigned = errors.New("peer reviews have been assigned, so the assignment no longer takes submissions")
+	// ErrPeerReviewLocked is returned for a change to peer review settings, other than the review deadline, after review pairs were made
+	ErrPeerReviewLocked = errors.New("peer reviews have been assigned, so only the review deadline can change")
+	// ErrInvalidSubmissionFile is returned for a submission file the student didn't upload to the room
+	ErrInvalidSubmissionFile = errors.New("file_url must be a file you uploaded to this room")
+)
+
 // AssignmentService handles assignment-related operations
 type AssignmentService struct {
 	db          *database.DB
@@ -26,7 +41,7 @@ func NewAssignmentService(db *database.DB, roomService *RoomService) *Assignment
 }
 
 // CreateAssignment creates a new assignment
-func (s *AssignmentService) CreateAssignment(roomID, teacherID, title, description string, dueDate time.Time, totalPoints int, assignmentType string, subjects []string) (*models.Assignment, error) {
+func (s *AssignmentService) CreateAssignment(roomID, teacherID, title, description string, dueDate time.Time, totalPoints int, assignmentType string, subjects []string, peerReview *models.PeerReviewConfig) (*models.Assignment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -40,6 +55,10 @@ func (s *AssignmentService) CreateAssignment(roomID, teacherID, title, descripti
 		return nil, errors.New("invalid teacher ID")
 	}
 
+	if err := validatePeerReviewConfig(peerReview, assignmentType, dueDate); err != nil {
+		return nil, err
+	}
+
 	assignment := &models.Assignment{
 		RoomID:         roomObjectID,
 		TeacherID:      teacherObjectID,
@@ -48,6 +67,7 @@ func (s *AssignmentService) CreateAssignment(roomID, teacherID, title, descripti
 		DueDate:        dueDate,
 		TotalPoints:    totalPoints,
 		AssignmentType: assignmentType,
+		PeerReview:     peerReview,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
@@ -73,6 +93,49 @@ func (s *AssignmentService) CreateAssignment(roomID, teacherID, title, descripti
 	return assignment, nil
 }
 
+// validatePeerReviewConfig checks a peer review configuration against its assignment
+func validatePeerReviewConfig(cfg *models.PeerReviewConfig, assignmentType string, dueDate time.Time) error {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	if assignmentType != "project" {
+		return errors.New("peer review is only available for project assignments")
+	}
+	if cfg.ReviewerCount < 1 || cfg.ReviewerCount > 5 {
+		return errors.New("peer review reviewer count must be between 1 and 5")
+	}
+	if len(cfg.Rubric) == 0 {
+		return errors.New("peer review requires at least one rubric criterion")
+	}
+	for _, criterion := range cfg.Rubric {
+		if criterion.Title == "" || criterion.MaxPoints <= 0 {
+			return errors.New("each rubric criterion needs a title and positive max points")
+		}
+	}
+	if !cfg.ReviewDeadline.After(dueDate) {
+		return errors.New("peer review deadline must be after the assignment due date")
+	}
+	return nil
+}
+
+// peerReviewDeadlineOnly reports whether next differs from current in at most
+// its review deadline
+func peerReviewDeadlineOnly(current, next *models.PeerReviewConfig) bool {
+	if next == nil {
+		return false
+	}
+	if next.Enabled != current.Enabled || next.ReviewerCount != current.ReviewerCount ||
+		next.Anonymous != current.Anonymous || len(next.Rubric) != len(current.Rubric) {
+		return false
+	}
+	for i := range next.Rubric {
+		if next.Rubric[i] != current.Rubric[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // GetAssignments gets all assignments for a room
 func (s *AssignmentService) GetAssignments(roomID string) ([]models.Assignment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -118,8 +181,9 @@ func (s *AssignmentService) GetAssignment(assignmentID string) (*models.Assignme
 	return &assignment, nil
 }
 
-// UpdateAssignment updates an assignment
-func (s *AssignmentService) UpdateAssignment(assignmentID, teacherID string, title, description string, dueDate time.Time, totalPoints int, assignmentType string, subjects []string) (*models.Assignment, error) {
+// UpdateAssignment updates an assignment. Its peer review configuration is
+// only replaced when replacePeerReview is set; otherwise it is kept.
+func (s *AssignmentService) UpdateAssignment(assignmentID, teacherID string, title, description string, dueDate time.Time, totalPoints int, assignmentType string, subjects []string, peerReview *models.PeerReviewConfig, replacePeerReview bool) (*models.Assignment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -144,6 +208,23 @@ func (s *AssignmentService) UpdateAssignment(assignmentID, teacherID string, tit
 		return nil, err
 	}
 
+	if !replacePeerReview {
+		peerReview = assignment.PeerReview
+	}
+	if err := validatePeerReviewConfig(peerReview, assignmentType, dueDate); err != nil {
+		return nil, err
+	}
+
+	// Review pairs cannot be reconfigured once they have been handed out, so
+	// reviews are scored against the rubric they were given with
+	assigned := assignment.PeerReview != nil && assignment.PeerReview.AssignedAt != nil
+	if assigned {
+		if !peerReviewDeadlineOnly(assignment.PeerReview, peerReview) {
+			return nil, ErrPeerReviewLocked
+		}
+		peerReview.AssignedAt = assignment.PeerReview.AssignedAt
+	}
+
 	// Update assignment
 	update := bson.M{"$set": bson.M{
 		"title":           title,
@@ -152,6 +233,7 @@ func (s *AssignmentService) UpdateAssignment(assignmentID, teacherID string, tit
 		"total_points":    totalPoints,
 		"assignment_type": assignmentType,
 		"subjects":        subjects,
+		"peer_review":     peerReview,
 		"updated_at":      time.Now(),
 	}}
 
@@ -160,6 +242,17 @@ func (s *AssignmentService) UpdateAssignment(assignmentID, teacherID string, tit
 		return nil, err
 	}
 
+	// Reviews still to do move with the deadline
+	if assigned && !peerReview.ReviewDeadline.Equal(assignment.PeerReview.ReviewDeadline) {
+		_, err = s.db.Collection("peer_reviews").UpdateMany(ctx,
+			bson.M{"assignment_id": assignmentObjectID, "status": "pending"},
+			bson.M{"$set": bson.M{"due_at": peerReview.ReviewDeadline}},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Fetch updated assignment
 	err = collection.FindOne(ctx, bson.M{"_id": assignmentObjectID}).Decode(&assignment)
 	if err != nil {
@@ -197,3 +290,131 @@ func (s *AssignmentService) DeleteAssignment(assignmentID, teacherID string) err
 
 	return nil
 }
+
+// SubmitAssignment creates or replaces a student's submission for an assignment
+func (s *AssignmentService) SubmitAssignment(assignmentID, studentID, content, fileURL string) (*models.Submission, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assignmentObjectID, err := primitive.ObjectIDFromHex(assignmentID)
+	if err != nil {
+		return nil, errors.New("invalid assignment ID")
+	}
+
+	studentObjectID, err := primitive.ObjectIDFromHex(studentID)
+	if err != nil {
+		return nil, errors.New("invalid student ID")
+	}
+
+	if content == "" && fileURL == "" {
+		return nil, errors.New("submission must include content or a file")
+	}
+
+	var assignment models.Assignment
+	if err := s.db.Collection("assignments").FindOne(ctx, bson.M{"_id": assignmentObjectID}).Decode(&assignment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrAssignmentNotFound
+		}
+		return nil, err
+	}
+	if _, isMember, err := roomAccess(ctx, s.db, assignment.RoomID, studentObjectID); err != nil {
+		return nil, err
+	} else if !isMember {
+		return nil, ErrAssignmentAccessDenied
+	}
+	if time.Now().After(assignment.DueDate) {
+		return nil, ErrAssignmentPastDue
+	}
+	// Reviewers are paired with the submissions there were; a later one would go unreviewed
+	if assignment.PeerReview != nil && assignment.PeerReview.AssignedAt != nil {
+		return nil, ErrPeerReviewsAssigned
+	}
+	if fileURL != "" {
+		if uploaded, err := uploadedBy(ctx, s.db, assignment.RoomID, fileURL, studentObjectID); err != nil {
+			return nil, err
+		} else if !uploaded {
+			return nil, ErrInvalidSubmissionFile
+		}
+	}
+
+	// One submission per student; resubmitting replaces the previous one
+	collection := s.db.Collection("submissions")
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"assignment_id": assignmentObjectID, "student_id": studentObjectID},
+		bson.M{
+			"$set": bson.M{
+				"content":      content,
+				"file_url":     fileURL,
+				"status":       "submitted",
+				"submitted_at": time.Now(),
+			},
+			"$setOnInsert": bson.M{
+				"assignment_id": assignmentObjectID,
+				"student_id":    studentObjectID,
+				"score":         0,
+				"feedback":      "",
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var submission models.Submission
+	err = collection.FindOne(ctx, bson.M{"assignment_id": assignmentObjectID, "student_id": studentObjectID}).Decode(&submission)
+	if err != nil {
+		return nil, err
+	}
+
+	return &submission, nil
+}
+
+// GetSubmissionsForOwner gets all submissions for an assignment for the owner of its room
+func (s *AssignmentService) GetSubmissionsForOwner(assignmentID, userID string) ([]models.Submission, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assignment, err := s.GetAssignment(assignmentID)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrAssignmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if isOwner, _, err := roomAccess(ctx, s.db, assignment.RoomID, userObjectID); err != nil {
+		return nil, err
+	} else if !isOwner {
+		return nil, ErrAssignmentAccessDenied
+	}
+	return s.GetSubmissions(assignmentID)
+}
+
+// GetSubmissions gets all submissions for an assignment
+func (s *AssignmentService) GetSubmissions(assignmentID string) ([]models.Submission, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assignmentObjectID, err := primitive.ObjectIDFromHex(assignmentID)
+	if err != nil {
+		return nil, errors.New("invalid assignment ID")
+	}
+
+	collection := s.db.Collection("submissions")
+	cursor, err := collection.Find(ctx, bson.M{"assignment_id": assignmentObjectID}, options.Find().SetSort(bson.D{{Key: "submitted_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var submissions []models.Submission
+	if err = cursor.All(ctx, &submissions); err != nil {
+		return nil, err
+	}
+
+	return submissions, nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// EmbeddingProvider turns text into vectors for similarity search
+type EmbeddingProvider interface {
+	// Name identifies the provider; vectors from different providers are not comparable
+	Name() string
+	// Embed returns one vector per input text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbeddingProvider returns the provider selected by name ("gemini" or "local").
+// An empty name uses Gemini when available and the local provider otherwise.
+func NewEmbeddingProvider(name string, gemini *GeminiLLMProvider) (EmbeddingProvider, error) {
+	switch strings.ToLower(name) {
+	case "":
+		if gemini != nil {
+			return NewGeminiEmbeddingProvider(gemini), nil
+		}
+		return NewHashEmbeddingProvider(defaultHashDimensions), nil
+	case "gemini":
+		if gemini == nil {
+			return nil, errors.New("gemini embeddings require GEMINI_API_KEY")
+		}
+		return NewGeminiEmbeddingProvider(gemini), nil
+	case "local":
+		return NewHashEmbeddingProvider(defaultHashDimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", name)
+	}
+}
+
+// defaultHashDimensions is the vector size of the local provider
+const defaultHashDimensions = 512
+
+// HashEmbeddingProvider is a deterministic, offline embedding based on feature
+// hashing of words and word pairs. It captures lexical overlap only, which is
+// enough for tests and for deployments without an embedding API.
+type HashEmbeddingProvider struct {
+	dimensions int
+}
+
+// NewHashEmbeddingProvider creates a local hashing embedding provider
+func NewHashEmbeddingProvider(dimensions int) *HashEmbeddingProvider {
+	if dimensions <= 0 {
+		dimensions = defaultHashDimensions
+	}
+	return &HashEmbeddingProvider{dimensions: dimensions}
+}
+
+// Name identifies the provider and its dimensionality
+func (p *HashEmbeddingProvider) Name() string {
+	return fmt.Sprintf("local-hash-%d", p.dimensions)
+}
+
+// Embed hashes each text into a normalized vector
+func (p *HashEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = p.embed(text)
+	}
+	return vectors, nil
+}
+
+func (p *HashEmbeddingProvider) embed(text string) []float32 {
+	vector := make([]float32, p.dimensions)
+	lower := strings.ToLower(text)
+
+	var words []string
+	for _, span := range tokenizeWords(lower) {
+		words = append(words, lower[span.start:span.end])
+	}
+
+	add := func(feature string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The top bit chooses the sign so collisions tend to cancel out
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(p.dimensions)] += weight
+	}
+	for i, word := range words {
+		add(word, 1)
+		if i > 0 {
+			add(words[i-1]+" "+word, 0.5)
+		}
+	}
+
+	normalizeVector(vector)
+	return vector
+}
+
+// geminiEmbeddingBatchSize is the Gemini API's per-request limit
+const geminiEmbeddingBatchSize = 100
+
+// GeminiEmbeddingProvider embeds text with the Gemini embedding model
+type GeminiEmbeddingProvider struct {
+	gemini *GeminiLLMProvider
+}
+
+// NewGeminiEmbeddingProvider creates a Gemini-backed embedding provider
+func NewGeminiEmbeddingProvider(gemini *GeminiLLMProvider) *GeminiEmbeddingProvider {
+	return &GeminiEmbeddingProvider{gemini: gemini}
+}
+
+// Name identifies the provider and model
+func (p *GeminiEmbeddingProvider) Name() string {
+	return "gemini-" + geminiEmbeddingModel
+}
+
+// Embed sends texts to Gemini in batches
+func (p *GeminiEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += geminiEmbeddingBatchSize {
+		end := start + geminiEmbeddingBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := p.gemini.EmbedTexts(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, vector := range batch {
+			normalizeVector(vector)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// normalizeVector scales a vector to unit length in place
+func normalizeVector(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// cosineSimilarity compares two vectors; unit vectors reduce this to a dot product
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
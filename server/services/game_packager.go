@@ -3,6 +3,9 @@ package services
 import (
 	"archive/zip"
 	"buddy-server/models"
+	"buddy-server/storage"
+	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -11,18 +14,18 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"path"
 	"time"
 )
 
 // GamePackager handles game bundle creation and distribution
 type GamePackager struct {
-	uploadDir    string
+	store        storage.Storage
 	bundleSecret string
 }
 
-// NewGamePackager creates a new game packager
-func NewGamePackager(uploadDir string) *GamePackager {
+// NewGamePackager creates a new game packager that keeps bundles in store
+func NewGamePackager(store storage.Storage) *GamePackager {
 	// Load secret from env, fallback to default (should be in .env)
 	secret := os.Getenv("BUNDLE_SECRET")
 	if secret == "" {
@@ -30,7 +33,7 @@ func NewGamePackager(uploadDir string) *GamePackager {
 	}
 	
 	return &GamePackager{
-		uploadDir:    uploadDir,
+		store:        store,
 		bundleSecret: secret,
 	}
 }
@@ -50,87 +53,73 @@ type GameManifest struct {
 
 // GameBundle represents a packaged game
 type GameBundle struct {
-	Path      string
+	Key       string // storage key of the ZIP
 	Hash      string
 	Signature string
 	Manifest  GameManifest
 }
 
-// CreateBundle creates a ZIP bundle for a game
+// CreateBundle creates a ZIP bundle for a game and stores it
 func (p *GamePackager) CreateBundle(game *models.AIGame) (*GameBundle, error) {
 	if game.ID.IsZero() {
 		return nil, errors.New("game must have an ID")
 	}
 
 	gameID := game.ID.Hex()
-	gameDir := filepath.Join(p.uploadDir, "games", gameID)
-	
-	// Create game directory if it doesn't exist
-	if err := os.MkdirAll(gameDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create game directory: %w", err)
-	}
 
 	// Generate game files based on template
-	if err := p.generateGameFiles(game, gameDir); err != nil {
+	files, err := p.generateGameFiles(game)
+	if err != nil {
 		return nil, fmt.Errorf("failed to generate game files: %w", err)
 	}
 
 	// Create ZIP archive
-	zipPath := filepath.Join(gameDir, fmt.Sprintf("game-%s.zip", gameID))
-	zipFile, err := os.Create(zipPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create zip file: %w", err)
-	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	var zipData bytes.Buffer
+	zipWriter := zip.NewWriter(&zipData)
 
 	// Add files to ZIP
 	filesToAdd := []string{"index.html", "game.js", "content.json", "manifest.json", "styles.css"}
 	for _, filename := range filesToAdd {
-		sourcePath := filepath.Join(gameDir, filename)
-		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+		content, ok := files[filename]
+		if !ok {
 			continue
 		}
 
-		if err := p.addFileToZip(zipWriter, sourcePath, filename); err != nil {
+		if err := p.addFileToZip(zipWriter, content, filename); err != nil {
 			return nil, fmt.Errorf("failed to add %s to zip: %w", filename, err)
 		}
 	}
 
-	zipWriter.Close()
-	zipFile.Close()
-
-	// Calculate hash
-	hash, err := p.calculateFileHash(zipPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to calculate hash: %w", err)
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to create zip file: %w", err)
 	}
 
-	// Calculate HMAC signature
-	signature := p.signBundle(zipPath)
-
-	// Load and update manifest with hash and signature
-	manifestPath := filepath.Join(gameDir, "manifest.json")
-	manifestData, err := os.ReadFile(manifestPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read manifest: %w", err)
-	}
+	// Calculate hash and HMAC signature
+	hash := p.calculateHash(zipData.Bytes())
+	signature := p.signBundle(zipData.Bytes())
 
+	// Update manifest with hash and signature
 	var manifest GameManifest
-	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
 		return nil, fmt.Errorf("failed to parse manifest: %w", err)
 	}
 	manifest.Hash = hash
 	manifest.Signature = signature
 
-	// Save updated manifest
-	manifestData, _ = json.MarshalIndent(manifest, "", "  ")
-	os.WriteFile(manifestPath, manifestData, 0644)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	key := p.BundleKey(gameID)
+	if err := p.store.Put(ctx, key, bytes.NewReader(zipData.Bytes()), int64(zipData.Len()), "application/zip"); err != nil {
+		return nil, fmt.Errorf("failed to store bundle: %w", err)
+	}
+
+	// Save updated manifest next to the bundle
+	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
+	p.store.Put(ctx, path.Join(p.gamePrefix(gameID), "manifest.json"), bytes.NewReader(manifestData), int64(len(manifestData)), "application/json")
 
 	return &GameBundle{
-		Path:      zipPath,
+		Key:       key,
 		Hash:      hash,
 		Signature: signature,
 		Manifest:  manifest,
@@ -138,7 +127,9 @@ func (p *GamePackager) CreateBundle(game *models.AIGame) (*GameBundle, error) {
 }
 
 // generateGameFiles creates the HTML, JS, and JSON files for the game
-func (p *GamePackager) generateGameFiles(game *models.AIGame, outputDir string) error {
+func (p *GamePackager) generateGameFiles(game *models.AIGame) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
 	// Generate content.json
 	contentData, err := json.MarshalIndent(map[string]interface{}{
 		"questions": game.Questions,
@@ -148,11 +139,9 @@ func (p *GamePackager) generateGameFiles(game *models.AIGame, outputDir string)
 		"ruleset":   game.Ruleset,
 	}, "", "  ")
 	if err != nil {
-		return err
-	}
-	if err := os.WriteFile(filepath.Join(outputDir, "content.json"), contentData, 0644); err != nil {
-		return err
+		return nil, err
 	}
+	files["content.json"] = contentData
 
 	// Generate manifest.json
 	manifest := GameManifest{
@@ -165,29 +154,14 @@ func (p *GamePackager) generateGameFiles(game *models.AIGame, outputDir string)
 		CreatedAt:   game.CreatedAt.Format(time.RFC3339),
 	}
 	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
-	if err := os.WriteFile(filepath.Join(outputDir, "manifest.json"), manifestData, 0644); err != nil {
-		return err
-	}
+	files["manifest.json"] = manifestData
 
-	// Generate index.html
-	html := p.generateHTML(game)
-	if err := os.WriteFile(filepath.Join(outputDir, "index.html"), []byte(html), 0644); err != nil {
-		return err
-	}
-
-	// Generate game.js
-	js := p.generateGameScript(game)
-	if err := os.WriteFile(filepath.Join(outputDir, "game.js"), []byte(js), 0644); err != nil {
-		return err
-	}
+	// Generate index.html, game.js and styles.css
+	files["index.html"] = []byte(p.generateHTML(game))
+	files["game.js"] = []byte(p.generateGameScript(game))
+	files["styles.css"] = []byte(p.generateStyles(game))
 
-	// Generate styles.css
-	css := p.generateStyles(game)
-	if err := os.WriteFile(filepath.Join(outputDir, "styles.css"), []byte(css), 0644); err != nil {
-		return err
-	}
-
-	return nil
+	return files, nil
 }
 
 // generateHTML creates the HTML file
@@ -1126,77 +1100,82 @@ body {
 }
 
 // addFileToZip adds a file to ZIP archive
-func (p *GamePackager) addFileToZip(zipWriter *zip.Writer, sourcePath, zipPath string) error {
-	file, err := os.Open(sourcePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
+func (p *GamePackager) addFileToZip(zipWriter *zip.Writer, content []byte, zipPath string) error {
 	writer, err := zipWriter.Create(zipPath)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(writer, file)
+	_, err = writer.Write(content)
 	return err
 }
 
-// calculateFileHash calculates SHA-256 hash
-func (p *GamePackager) calculateFileHash(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
-	}
+// calculateHash calculates SHA-256 hash
+func (p *GamePackager) calculateHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
 
-	return hex.EncodeToString(hash.Sum(nil)), nil
+// gamePrefix returns the storage prefix holding a game's files
+func (p *GamePackager) gamePrefix(gameID string) string {
+	return path.Join("games", gameID)
 }
 
-// GetBundlePath returns the path to a game's bundle
-func (p *GamePackager) GetBundlePath(gameID string) string {
-	return filepath.Join(p.uploadDir, "games", gameID, fmt.Sprintf("game-%s.zip", gameID))
+// BundleKey returns the storage key of a game's bundle
+func (p *GamePackager) BundleKey(gameID string) string {
+	return path.Join(p.gamePrefix(gameID), fmt.Sprintf("game-%s.zip", gameID))
 }
 
 // BundleExists checks if a bundle exists
 func (p *GamePackager) BundleExists(gameID string) bool {
-	path := p.GetBundlePath(gameID)
-	_, err := os.Stat(path)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := p.store.Stat(ctx, p.BundleKey(gameID))
 	return err == nil
 }
 
+// OpenBundle opens a stored bundle for reading; the caller must close it
+func (p *GamePackager) OpenBundle(ctx context.Context, gameID string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	return p.store.Get(ctx, p.BundleKey(gameID))
+}
+
 // signBundle creates HMAC-SHA256 signature for a bundle
-func (p *GamePackager) signBundle(filePath string) string {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return ""
-	}
-	
+func (p *GamePackager) signBundle(data []byte) string {
 	h := hmac.New(sha256.New, []byte(p.bundleSecret))
 	h.Write(data)
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// VerifyBundle verifies bundle integrity
-func (p *GamePackager) VerifyBundle(filePath, expectedHash, expectedSignature string) bool {
+// VerifyBundle verifies the integrity of a stored bundle
+func (p *GamePackager) VerifyBundle(key, expectedHash, expectedSignature string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	rc, _, err := p.store.Get(ctx, key)
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return false
+	}
+
 	// Verify hash
-	actualHash, err := p.calculateFileHash(filePath)
-	if err != nil || actualHash != expectedHash {
+	if p.calculateHash(data) != expectedHash {
 		return false
 	}
 
 	// Verify signature
-	actualSignature := p.signBundle(filePath)
+	actualSignature := p.signBundle(data)
 	return hmac.Equal([]byte(actualSignature), []byte(expectedSignature))
 }
 
 // DeleteBundle removes a game bundle
 func (p *GamePackager) DeleteBundle(gameID string) error {
-	gameDir := filepath.Join(p.uploadDir, "games", gameID)
-	return os.RemoveAll(gameDir)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return storage.DeletePrefix(ctx, p.store, p.gamePrefix(gameID)+"/")
 }
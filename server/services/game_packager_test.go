@@ -2,8 +2,9 @@ package services
 
 import (
 	"buddy-server/models"
-	"os"
-	"path/filepath"
+	"buddy-server/storage"
+	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,11 +12,9 @@ import (
 )
 
 func TestCreateBundle(t *testing.T) {
-	// Setup test directory
-	testDir := "./test_uploads"
-	defer os.RemoveAll(testDir)
-
-	packager := NewGamePackager(testDir)
+	// Setup test storage
+	store := storage.NewLocalStorage(t.TempDir())
+	packager := NewGamePackager(store)
 
 	game := &models.AIGame{
 		ID:       primitive.NewObjectID(),
@@ -53,8 +52,8 @@ func TestCreateBundle(t *testing.T) {
 	}
 
 	// Verify file exists
-	if _, err := os.Stat(bundle.Path); os.IsNotExist(err) {
-		t.Errorf("Bundle file does not exist at %s", bundle.Path)
+	if _, err := store.Stat(context.Background(), bundle.Key); err != nil {
+		t.Errorf("Bundle file does not exist at %s", bundle.Key)
 	}
 
 	// Verify manifest
@@ -70,10 +69,8 @@ func TestCreateBundle(t *testing.T) {
 }
 
 func TestBundleIntegrity(t *testing.T) {
-	testDir := "./test_uploads"
-	defer os.RemoveAll(testDir)
-
-	packager := NewGamePackager(testDir)
+	store := storage.NewLocalStorage(t.TempDir())
+	packager := NewGamePackager(store)
 
 	game := &models.AIGame{
 		ID:       primitive.NewObjectID(),
@@ -94,36 +91,34 @@ func TestBundleIntegrity(t *testing.T) {
 	}
 
 	// Verify bundle with correct hash and signature
-	if !packager.VerifyBundle(bundle.Path, bundle.Hash, bundle.Signature) {
+	if !packager.VerifyBundle(bundle.Key, bundle.Hash, bundle.Signature) {
 		t.Error("Bundle verification should pass for untampered bundle")
 	}
 
 	// Tamper with file
-	os.WriteFile(bundle.Path, []byte("tampered content"), 0644)
+	store.Put(context.Background(), bundle.Key, strings.NewReader("tampered content"), -1, "")
 
 	// Verify should fail
-	if packager.VerifyBundle(bundle.Path, bundle.Hash, bundle.Signature) {
+	if packager.VerifyBundle(bundle.Key, bundle.Hash, bundle.Signature) {
 		t.Error("Bundle verification should fail for tampered bundle")
 	}
 }
 
-func TestGetBundlePath(t *testing.T) {
-	packager := NewGamePackager("./uploads")
+func TestBundleKey(t *testing.T) {
+	packager := NewGamePackager(storage.NewLocalStorage(t.TempDir()))
 	gameID := "507f1f77bcf86cd799439011"
 
-	expectedPath := filepath.Join("./uploads", "games", gameID, "game-"+gameID+".zip")
-	actualPath := packager.GetBundlePath(gameID)
+	expectedKey := "games/" + gameID + "/game-" + gameID + ".zip"
+	actualKey := packager.BundleKey(gameID)
 
-	if actualPath != expectedPath {
-		t.Errorf("Expected path %s, got %s", expectedPath, actualPath)
+	if actualKey != expectedKey {
+		t.Errorf("Expected key %s, got %s", expectedKey, actualKey)
 	}
 }
 
 func TestBundleExists(t *testing.T) {
-	testDir := "./test_uploads"
-	defer os.RemoveAll(testDir)
-
-	packager := NewGamePackager(testDir)
+	store := storage.NewLocalStorage(t.TempDir())
+	packager := NewGamePackager(store)
 	gameID := "507f1f77bcf86cd799439011"
 
 	// Should not exist initially
@@ -150,36 +145,31 @@ func TestBundleExists(t *testing.T) {
 		t.Error("Bundle should exist after creation")
 	}
 
-	// Verify path matches
-	if bundle.Path != packager.GetBundlePath(game.ID.Hex()) {
-		t.Error("Bundle path mismatch")
+	// Verify key matches
+	if bundle.Key != packager.BundleKey(game.ID.Hex()) {
+		t.Error("Bundle key mismatch")
 	}
 }
 
 func TestSignBundle(t *testing.T) {
-	packager := NewGamePackager("./test")
+	packager := NewGamePackager(storage.NewLocalStorage(t.TempDir()))
 
-	// Create test file
-	testFile := "./test_bundle.txt"
 	content := []byte("test content for signing")
-	os.WriteFile(testFile, content, 0644)
-	defer os.Remove(testFile)
 
 	// Sign
-	signature1 := packager.signBundle(testFile)
+	signature1 := packager.signBundle(content)
 	if signature1 == "" {
 		t.Error("Signature should not be empty")
 	}
 
 	// Sign again - should be deterministic
-	signature2 := packager.signBundle(testFile)
+	signature2 := packager.signBundle(content)
 	if signature1 != signature2 {
 		t.Error("Signature should be deterministic")
 	}
 
-	// Modify file
-	os.WriteFile(testFile, []byte("modified content"), 0644)
-	signature3 := packager.signBundle(testFile)
+	// Modify content
+	signature3 := packager.signBundle([]byte("modified content"))
 	
 	if signature1 == signature3 {
 		t.Error("Signature should change when content changes")
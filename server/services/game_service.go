@@ -4,14 +4,15 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
 	"buddy-server/database"
 	"buddy-server/models"
+	"buddy-server/storage"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -21,29 +22,45 @@ import (
 // GameService handles educational game creation and playing
 type GameService struct {
 	db              *database.DB
-	geminiService   *GeminiService
+	aiService       *AIService
 	templateService *GameTemplateService
 	packagerService *GamePackager
 }
 
 // NewGameService creates a new game service
-func NewGameService(db *database.DB, geminiService *GeminiService, templateService *GameTemplateService, packager *GamePackager) *GameService {
+func NewGameService(db *database.DB, aiService *AIService, templateService *GameTemplateService, packager *GamePackager) *GameService {
 	return &GameService{
 		db:              db,
-		geminiService:   geminiService,
+		aiService:       aiService,
 		templateService: templateService,
 		packagerService: packager,
 	}
 }
 
-// GenerateGame creates a new AI-generated game
-func (s *GameService) GenerateGame(roomID, teacherID, gameType, subject, difficulty string, questionCount int) (*models.AIGame, error) {
-	if s.geminiService == nil {
-		return nil, errors.New("AI service not available")
+// GameJobParams are the parameters of a game generation job
+type GameJobParams struct {
+	RoomID        string `json:"room_id"`
+	GameType      string `json:"game_type"`
+	Subject       string `json:"subject"`
+	Difficulty    string `json:"difficulty"`
+	QuestionCount int    `json:"question_count"`
+}
+
+// RunGameJob generates the game a job asked for
+func (s *GameService) RunGameJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var params GameJobParams
+	if err := decodeJobParams(job, &params); err != nil {
+		return nil, err
 	}
+	return s.GenerateGame(ctx, params.RoomID, job.UserID.Hex(), params.GameType, params.Subject, params.Difficulty, params.QuestionCount)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// GenerateGame creates a new AI-generated game; ctx bounds both the AI call
+// and saving the game
+func (s *GameService) GenerateGame(ctx context.Context, roomID, teacherID, gameType, subject, difficulty string, questionCount int) (*models.AIGame, error) {
+	if s.aiService == nil {
+		return nil, errors.New("AI service not available")
+	}
 
 	roomOID, err := primitive.ObjectIDFromHex(roomID)
 	if err != nil {
@@ -67,50 +84,24 @@ func (s *GameService) GenerateGame(roomID, teacherID, gameType, subject, difficu
 	}
 
 	// Generate questions with AI
-	jsonResponse, err := s.geminiService.GenerateGameQuestions(gameType, subject, difficulty, questionCount, syllabusStr)
+	aiResponse, err := s.aiService.GenerateGameQuestions(WithAIUsage(ctx, teacherID, roomID), gameType, subject, difficulty, questionCount, syllabusStr)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse AI response
-	cleanedResponse := strings.TrimSpace(jsonResponse)
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```json")
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSuffix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSpace(cleanedResponse)
-
-	if cleanedResponse == "" {
-		return nil, errors.New("AI returned empty response; try fewer questions or check API key/limits")
-	}
-
-	var aiResponse struct {
-		Questions []models.GameQuestion `json:"questions"`
-	}
-
-	if err := json.Unmarshal([]byte(cleanedResponse), &aiResponse); err != nil {
-		snippetLen := 120
-		if len(cleanedResponse) < snippetLen {
-			snippetLen = len(cleanedResponse)
-		}
-		snippet := cleanedResponse
-		if len(cleanedResponse) > snippetLen {
-			snippet = "..." + cleanedResponse[len(cleanedResponse)-snippetLen:]
-		}
-		return nil, fmt.Errorf("failed to parse AI response: %v (response length: %d chars, end: %q)", err, len(cleanedResponse), snippet)
-	}
-
 	// Create game
 	game := &models.AIGame{
-		RoomID:       roomOID,
-		TeacherID:    teacherOID,
-		Title:        subject + " " + strings.Title(gameType),
-		GameType:     gameType,
-		Subject:      subject,
-		Difficulty:   difficulty,
-		Questions:    aiResponse.Questions,
-		TimeLimit:    30, // Default 30 seconds per question
-		PassingScore: 70, // Default 70%
-		CreatedAt:    time.Now(),
+		RoomID:        roomOID,
+		TeacherID:     teacherOID,
+		Title:         subject + " " + strings.Title(gameType),
+		GameType:      gameType,
+		Subject:       subject,
+		Difficulty:    difficulty,
+		Questions:     aiResponse.Questions,
+		PromptVersion: aiResponse.PromptVersion,
+		TimeLimit:     30, // Default 30 seconds per question
+		PassingScore:  70, // Default 70%
+		CreatedAt:     time.Now(),
 	}
 
 	collection := s.db.Collection("ai_games")
@@ -257,29 +248,28 @@ func (s *GameService) GetStudentGameResults(gameID, studentID string) ([]models.
 	return results, nil
 }
 
-// GetBundlePath returns the path to a game's bundle
-func (s *GameService) GetBundlePath(gameID string) string {
+// GetBundleKey returns the storage key of a game's bundle
+func (s *GameService) GetBundleKey(gameID string) string {
 	if s.packagerService != nil {
-		return s.packagerService.GetBundlePath(gameID)
+		return s.packagerService.BundleKey(gameID)
 	}
 	return ""
 }
 
-// EnsureBundle returns the bundle path, (re)creating the bundle so template/CSS updates apply to existing games
-func (s *GameService) EnsureBundle(gameID string) (bundlePath string, err error) {
+// OpenBundle (re)creates a game's bundle so template/CSS updates apply to existing games, then opens it for reading
+func (s *GameService) OpenBundle(ctx context.Context, gameID string) (io.ReadCloser, *storage.ObjectInfo, error) {
 	game, err := s.GetGame(gameID)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	if s.packagerService == nil {
-		return "", errors.New("game packager not available")
+		return nil, nil, errors.New("game packager not available")
 	}
 	// Her istekte yeniden oluştur: böylece şablon/CSS güncellemeleri mevcut oyunlara yansır
-	bundle, err := s.packagerService.CreateBundle(game)
-	if err != nil {
-		return "", fmt.Errorf("failed to create bundle: %w", err)
+	if _, err := s.packagerService.CreateBundle(game); err != nil {
+		return nil, nil, fmt.Errorf("failed to create bundle: %w", err)
 	}
-	return bundle.Path, nil
+	return s.packagerService.OpenBundle(ctx, gameID)
 }
 
 // StartGameSession creates a new game session with nonce for replay protection
@@ -460,7 +450,7 @@ func generateNonce() (string, error) {
 
 // CreateGameWithTemplate creates a game using a specific template
 func (s *GameService) CreateGameWithTemplate(roomID, teacherID, templateID, subject, difficulty string, questionCount int) (*models.AIGame, error) {
-	if s.geminiService == nil {
+	if s.aiService == nil {
 		return nil, errors.New("AI service not available")
 	}
 
@@ -503,61 +493,35 @@ func (s *GameService) CreateGameWithTemplate(roomID, teacherID, templateID, subj
 	}
 
 	// Generate content with AI
-	jsonResponse, err := s.geminiService.GenerateGameQuestions(templateID, subject, difficulty, questionCount, syllabusStr)
+	aiResponse, err := s.aiService.GenerateGameQuestions(WithAIUsage(context.Background(), teacherID, roomID), templateID, subject, difficulty, questionCount, syllabusStr)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse AI response
-	cleanedResponse := strings.TrimSpace(jsonResponse)
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```json")
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSuffix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSpace(cleanedResponse)
-
-	if cleanedResponse == "" {
-		return nil, errors.New("AI returned empty response; try fewer questions or check API key/limits")
-	}
-
-	var aiResponse struct {
-		Questions []models.GameQuestion `json:"questions"`
-	}
-
-	if err := json.Unmarshal([]byte(cleanedResponse), &aiResponse); err != nil {
-		snippetLen := 120
-		if len(cleanedResponse) < snippetLen {
-			snippetLen = len(cleanedResponse)
-		}
-		snippet := cleanedResponse
-		if len(cleanedResponse) > snippetLen {
-			snippet = "..." + cleanedResponse[len(cleanedResponse)-snippetLen:]
-		}
-		return nil, fmt.Errorf("failed to parse AI response: %v (response length: %d chars, end: %q)", err, len(cleanedResponse), snippet)
-	}
-
 	// Get default config and ruleset
 	config, _ := s.templateService.GetDefaultConfig(templateID)
 	ruleset, _ := s.templateService.GetDefaultRuleset(templateID, difficulty)
 
 	// Create game
 	game := &models.AIGame{
-		RoomID:      roomOID,
-		TeacherID:   teacherOID,
-		Title:       fmt.Sprintf("%s - %s", subject, template.Name),
-		Description: fmt.Sprintf("AI-generated %s game for %s", template.Name, subject),
-		Template:    templateID,
-		Version:     "1.0.0",
-		Subject:     subject,
-		Difficulty:  difficulty,
-		Questions:   aiResponse.Questions,
-		Config:      config,
-		Ruleset:     ruleset,
-		MaxPlayers:  1,
-		Matchmaking: false,
-		PlayCount:   0,
-		AvgScore:    0,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		RoomID:        roomOID,
+		TeacherID:     teacherOID,
+		Title:         fmt.Sprintf("%s - %s", subject, template.Name),
+		Description:   fmt.Sprintf("AI-generated %s game for %s", template.Name, subject),
+		Template:      templateID,
+		Version:       "1.0.0",
+		Subject:       subject,
+		Difficulty:    difficulty,
+		Questions:     aiResponse.Questions,
+		PromptVersion: aiResponse.PromptVersion,
+		Config:        config,
+		Ruleset:       ruleset,
+		MaxPlayers:    1,
+		Matchmaking:   false,
+		PlayCount:     0,
+		AvgScore:      0,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 
 	// Insert into database
@@ -574,12 +538,12 @@ func (s *GameService) CreateGameWithTemplate(roomID, teacherID, templateID, subj
 		bundle, err := s.packagerService.CreateBundle(game)
 		if err == nil {
 			// Update game with bundle info
-			game.BundlePath = bundle.Path
+			game.BundlePath = bundle.Key
 			game.BundleHash = bundle.Hash
 			
 			collection.UpdateOne(ctx, bson.M{"_id": game.ID}, bson.M{
 				"$set": bson.M{
-					"bundle_path": bundle.Path,
+					"bundle_path": bundle.Key,
 					"bundle_hash": bundle.Hash,
 				},
 			})
@@ -2,9 +2,7 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"strings"
 	"time"
 
 	"buddy-server/database"
@@ -17,16 +15,16 @@ import (
 // GoalSuggestionService handles AI-powered goal suggestions
 type GoalSuggestionService struct {
 	db                   *database.DB
-	geminiService        *GeminiService
+	aiService            *AIService
 	activityQueryService *ActivityQueryService
 	productivityService  *ProductivityService
 }
 
 // NewGoalSuggestionService creates a new goal suggestion service
-func NewGoalSuggestionService(db *database.DB, geminiService *GeminiService, activityQueryService *ActivityQueryService, productivityService *ProductivityService) *GoalSuggestionService {
+func NewGoalSuggestionService(db *database.DB, aiService *AIService, activityQueryService *ActivityQueryService, productivityService *ProductivityService) *GoalSuggestionService {
 	return &GoalSuggestionService{
 		db:                   db,
-		geminiService:        geminiService,
+		aiService:            aiService,
 		activityQueryService: activityQueryService,
 		productivityService:  productivityService,
 	}
@@ -34,7 +32,7 @@ func NewGoalSuggestionService(db *database.DB, geminiService *GeminiService, act
 
 // GenerateDailyGoalSuggestions generates AI-powered daily goal suggestions
 func (s *GoalSuggestionService) GenerateDailyGoalSuggestions(userID string) ([]models.GoalSuggestion, error) {
-	if s.geminiService == nil {
+	if s.aiService == nil {
 		return nil, errors.New("AI service not available")
 	}
 
@@ -123,45 +121,25 @@ func (s *GoalSuggestionService) GenerateDailyGoalSuggestions(userID string) ([]m
 	}
 
 	// Generate suggestions with AI
-	jsonResponse, err := s.geminiService.GenerateDailyGoalSuggestions(userData)
+	aiResponse, err := s.aiService.GenerateDailyGoalSuggestions(WithAIUsage(context.Background(), userID, ""), userData)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse AI response
-	cleanedResponse := strings.TrimSpace(jsonResponse)
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```json")
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSuffix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSpace(cleanedResponse)
-
-	var aiResponse struct {
-		Suggestions []struct {
-			Title       string `json:"title"`
-			Description string `json:"description"`
-			Subject     string `json:"subject"`
-			Priority    string `json:"priority"`
-			Reasoning   string `json:"reasoning"`
-		} `json:"suggestions"`
-	}
-
-	if err := json.Unmarshal([]byte(cleanedResponse), &aiResponse); err != nil {
-		return nil, errors.New("failed to parse AI response: " + err.Error())
-	}
-
 	// Save suggestions to database
 	var suggestions []models.GoalSuggestion
 	collection := s.db.Collection("goal_suggestions")
 
 	for _, aiSugg := range aiResponse.Suggestions {
 		suggestion := models.GoalSuggestion{
-			UserID:      userOID,
-			Title:       aiSugg.Title,
-			Description: aiSugg.Description,
-			Subject:     aiSugg.Subject,
-			Priority:    aiSugg.Priority,
-			Reasoning:   aiSugg.Reasoning,
-			CreatedAt:   time.Now(),
+			UserID:        userOID,
+			Title:         aiSugg.Title,
+			Description:   aiSugg.Description,
+			Subject:       aiSugg.Subject,
+			Priority:      aiSugg.Priority,
+			Reasoning:     aiSugg.Reasoning,
+			PromptVersion: aiResponse.PromptVersion,
+			CreatedAt:     time.Now(),
 		}
 
 		result, err := collection.InsertOne(ctx, suggestion)
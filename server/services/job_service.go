@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Job types
+const (
+	JobTypeReport         = "report"
+	JobTypeGame           = "game"
+	JobTypeSmartPlan      = "smart_plan"
+	JobTypeRoomAITraining = "room_ai_training"
+)
+
+const (
+	defaultJobAttempts = 3
+	jobLease           = 10 * time.Minute // How long a worker may run a job before another may take it over
+	jobRetryBase       = 30 * time.Second
+	jobRetryMax        = 10 * time.Minute
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrInvalidJob  = errors.New("invalid job")
+	// ErrIdempotencyKeyReused is returned when a key already names a job of another type
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	// ErrJobPermanent marks a job failure that retrying won't fix
+	ErrJobPermanent = errors.New("job cannot succeed")
+)
+
+// jobTitles names each job type in completion notifications
+var jobTitles = map[string]string{
+	JobTypeReport:         "Your report",
+	JobTypeGame:           "Your game",
+	JobTypeSmartPlan:      "Your study plan",
+	JobTypeRoomAITraining: "AI Coach training",
+}
+
+// JobHandler runs a job and returns its result, which is stored as JSON
+type JobHandler func(ctx context.Context, job *models.Job) (interface{}, error)
+
+// JobRequest describes a job to enqueue
+type JobRequest struct {
+	Type   string
+	UserID string
+	RoomID string // Optional
+	Params interface{}
+	// IdempotencyKey makes enqueueing the same request again return the
+	// first job instead of starting another one
+	IdempotencyKey string
+	Notify         bool
+}
+
+// JobService queues slow work in MongoDB and runs it on a pool of workers.
+// Jobs survive restarts: a job whose worker stopped is taken over once its
+// lease runs out.
+type JobService struct {
+	db                  *database.DB
+	handlers            map[string]JobHandler
+	notificationService *NotificationService
+	wake                chan struct{}
+	mu                  sync.RWMutex
+}
+
+// NewJobService creates a new job service
+func NewJobService(db *database.DB) *JobService {
+	return &JobService{
+		db:       db,
+		handlers: make(map[string]JobHandler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// SetNotificationService sets the service that tells users their jobs finished
+func (s *JobService) SetNotificationService(notificationService *NotificationService) {
+	s.notificationService = notificationService
+}
+
+// Register sets the handler that runs jobs of a type
+func (s *JobService) Register(jobType string, handler JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[jobType] = handler
+}
+
+func (s *JobService) handler(jobType string) JobHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlers[jobType]
+}
+
+// Start runs the job workers, which look for due jobs when one is enqueued
+// and every poll interval
+func (s *JobService) Start(workers int, pollInterval time.Duration) {
+	if workers < 1 {
+		workers = 1
+	}
+	s.ensureIndexes()
+	for i := 0; i < workers; i++ {
+		go s.work(pollInterval)
+	}
+}
+
+func (s *JobService) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("jobs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "idempotency_key", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$type": "string"}}),
+		},
+	})
+	if err != nil {
+		log.Printf("jobs: creating indexes: %v", err)
+	}
+}
+
+func (s *JobService) work(pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		// Run jobs until none are due, then wait
+		for {
+			job, err := s.claim()
+			if err != nil {
+				log.Printf("jobs: claiming a job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.run(job)
+		}
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enqueue stores a job for the workers. With an idempotency key the user's
+// earlier job for the key is returned instead of queueing a new one.
+func (s *JobService) Enqueue(req JobRequest) (*models.Job, error) {
+	if s.handler(req.Type) == nil {
+		return nil, fmt.Errorf("%w: unknown job type %q", ErrInvalidJob, req.Type)
+	}
+	userOID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user ID", ErrInvalidJob)
+	}
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+	key := strings.TrimSpace(req.IdempotencyKey)
+	if len(key) > 255 {
+		return nil, fmt.Errorf("%w: idempotency key is too long", ErrInvalidJob)
+	}
+
+	now := time.Now()
+	job := &models.Job{
+		Type:           req.Type,
+		UserID:         userOID,
+		Status:         models.JobQueued,
+		Params:         params,
+		MaxAttempts:    defaultJobAttempts,
+		IdempotencyKey: key,
+		Notify:         req.Notify,
+		RunAt:          now,
+		CreatedAt:      now,
+	}
+	if req.RoomID != "" {
+		roomOID, err := primitive.ObjectIDFromHex(req.RoomID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid room ID", ErrInvalidJob)
+		}
+		job.RoomID = &roomOID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	collection := s.db.Collection("jobs")
+
+	if key == "" {
+		result, err := collection.InsertOne(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		job.ID = result.InsertedID.(primitive.ObjectID)
+		s.notifyWorkers()
+		return job, nil
+	}
+
+	// Insert unless the key was used before; the unique index settles races
+	var existing models.Job
+	filter := bson.M{"user_id": userOID, "idempotency_key": key}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err = collection.FindOneAndUpdate(ctx, filter, bson.M{"$setOnInsert": job}, opts).Decode(&existing)
+	if mongo.IsDuplicateKeyError(err) {
+		err = collection.FindOne(ctx, filter).Decode(&existing)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing.Type != req.Type {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.Status == models.JobQueued {
+		s.notifyWorkers()
+	}
+	return &existing, nil
+}
+
+func (s *JobService) notifyWorkers() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+		// A worker is already going to look
+	}
+}
+
+// GetJob gets one of the user's jobs
+func (s *JobService) GetJob(jobID, userID string) (*models.Job, error) {
+	jobOID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var job models.Job
+	err = s.db.Collection("jobs").FindOne(ctx, bson.M{"_id": jobOID, "user_id": userOID}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// claim takes the next due job, or a running job whose worker's lease ran out
+func (s *JobService) claim() (*models.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"$or": []bson.M{
+		{"status": models.JobQueued, "run_at": bson.M{"$lte": now}},
+		{"status": models.JobRunning, "locked_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": models.JobRunning, "locked_until": now.Add(jobLease), "started_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "run_at", Value: 1}}).SetReturnDocument(options.After)
+
+	var job models.Job
+	err := s.db.Collection("jobs").FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run runs a claimed job and records the outcome
+func (s *JobService) run(job *models.Job) {
+	if job.Attempts > job.MaxAttempts {
+		// Taken over after its last attempt's worker stopped
+		s.finish(job, nil, fmt.Errorf("%w: the job stopped responding", ErrJobPermanent))
+		return
+	}
+
+	handler := s.handler(job.Type)
+	if handler == nil {
+		s.finish(job, nil, fmt.Errorf("%w: no handler for job type %q", ErrJobPermanent, job.Type))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobLease)
+	defer cancel()
+	result, err := runJobHandler(ctx, handler, job)
+	s.finish(job, result, err)
+}
+
+// runJobHandler runs a handler, turning a panic into a failed attempt
+func runJobHandler(ctx context.Context, handler JobHandler, job *models.Job) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// finish records a job's result, or schedules a retry of a failed attempt
+func (s *JobService) finish(job *models.Job, result interface{}, runErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{}
+	switch {
+	case runErr == nil:
+		data, err := json.Marshal(result)
+		if err != nil {
+			runErr = fmt.Errorf("%w: encoding the result: %v", ErrJobPermanent, err)
+			break
+		}
+		set = bson.M{"status": models.JobSucceeded, "result": json.RawMessage(data), "finished_at": now}
+	case retryableJobError(runErr) && job.Attempts < job.MaxAttempts:
+		set = bson.M{"status": models.JobQueued, "run_at": now.Add(jobBackoff(job.Attempts))}
+	}
+	if runErr != nil {
+		set["error"] = runErr.Error()
+		if len(set) == 1 {
+			set["status"] = models.JobFailed
+			set["finished_at"] = now
+		}
+	}
+
+	// A worker that outlived its lease must not overwrite the attempt that took over
+	filter := bson.M{"_id": job.ID, "attempts": job.Attempts}
+	update := bson.M{"$set": set, "$unset": bson.M{"locked_until": ""}}
+	res, err := s.db.Collection("jobs").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("jobs: recording %s job %s: %v", job.Type, job.ID.Hex(), err)
+		return
+	}
+	if res.MatchedCount == 0 {
+		return
+	}
+
+	status, _ := set["status"].(string)
+	if status == models.JobQueued {
+		log.Printf("jobs: %s job %s attempt %d failed, retrying: %v", job.Type, job.ID.Hex(), job.Attempts, runErr)
+		return
+	}
+	if status == models.JobFailed {
+		log.Printf("jobs: %s job %s failed: %v", job.Type, job.ID.Hex(), runErr)
+	}
+	if job.Notify && s.notificationService != nil {
+		if err := s.notificationService.Notify([]primitive.ObjectID{job.UserID}, jobNotification(job, runErr)); err != nil {
+			log.Printf("jobs: notifying about job %s: %v", job.ID.Hex(), err)
+		}
+	}
+}
+
+// jobNotification tells a user their job finished
+func jobNotification(job *models.Job, runErr error) models.Notification {
+	title := jobTitles[job.Type]
+	if title == "" {
+		title = "Your job"
+	}
+	jobID := job.ID
+	notification := models.Notification{
+		Type:   "job_succeeded",
+		Title:  title + " is ready",
+		Body:   "Open it to see the result.",
+		RoomID: job.RoomID,
+		JobID:  &jobID,
+	}
+	if runErr != nil {
+		notification.Type = "job_failed"
+		notification.Title = title + " could not be finished"
+		notification.Body = runErr.Error()
+	}
+	return notification
+}
+
+// jobBackoff is the wait before retrying after the given attempt: doubling
+// from jobRetryBase up to jobRetryMax
+func jobBackoff(attempt int) time.Duration {
+	wait := jobRetryBase
+	for i := 1; i < attempt && wait < jobRetryMax; i++ {
+		wait *= 2
+	}
+	if wait > jobRetryMax {
+		wait = jobRetryMax
+	}
+	return wait
+}
+
+// retryableJobError reports whether another attempt might succeed. Quota,
+// safety and configuration errors won't go away by retrying.
+func retryableJobError(err error) bool {
+	for _, permanent := range []error{
+		ErrJobPermanent,
+		ErrAIQuotaExceeded,
+		ErrAIContentBlocked,
+		ErrLLMContentBlocked,
+		ErrLLMNotConfigured,
+		ErrRoomAIPermission,
+	} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeJobParams reads a job's parameters
+func decodeJobParams(job *models.Job, params interface{}) error {
+	if err := json.Unmarshal(job.Params, params); err != nil {
+		return fmt.Errorf("%w: reading parameters: %v", ErrJobPermanent, err)
+	}
+	return nil
+}
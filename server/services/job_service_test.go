@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestJobBackoff(t *testing.T) {
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i, want := range expected {
+		if got := jobBackoff(i + 1); got != want {
+			t.Errorf("Attempt %d: expected to wait %v, got %v", i+1, want, got)
+		}
+	}
+}
+
+func TestRetryableJobError(t *testing.T) {
+	if !retryableJobError(errors.New("connection reset by peer")) {
+		t.Error("Expected a network error to be retried")
+	}
+	for _, err := range []error{
+		fmt.Errorf("%w: reading parameters", ErrJobPermanent),
+		&AIQuotaError{},
+		fmt.Errorf("report: %w", ErrAIContentBlocked),
+		ErrLLMNotConfigured,
+	} {
+		if retryableJobError(err) {
+			t.Errorf("Expected %v not to be retried", err)
+		}
+	}
+}
+
+func TestJobStoresParamsAndResult(t *testing.T) {
+	roomID := primitive.NewObjectID()
+	job := models.Job{
+		ID:     primitive.NewObjectID(),
+		Type:   JobTypeGame,
+		Status: models.JobSucceeded,
+		Params: json.RawMessage(`{"room_id":"` + roomID.Hex() + `","game_type":"quiz","question_count":5}`),
+		Result: json.RawMessage(`{"title":"Fractions Quiz"}`),
+	}
+
+	data, err := bson.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored models.Job
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	var params GameJobParams
+	if err := decodeJobParams(&stored, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.RoomID != roomID.Hex() || params.GameType != "quiz" || params.QuestionCount != 5 {
+		t.Errorf("Expected the parameters back, got %+v", params)
+	}
+
+	// The result is returned as JSON; the parameters stay on the server
+	out, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"result":{"title":"Fractions Quiz"}`) || strings.Contains(string(out), "question_count") {
+		t.Errorf("Unexpected job JSON %s", out)
+	}
+
+	stored.Params = json.RawMessage(`not json`)
+	if err := decodeJobParams(&stored, &params); !errors.Is(err, ErrJobPermanent) {
+		t.Errorf("Expected unreadable parameters to fail for good, got %v", err)
+	}
+}
+
+func TestJobNotification(t *testing.T) {
+	roomID := primitive.NewObjectID()
+	job := &models.Job{ID: primitive.NewObjectID(), Type: JobTypeRoomAITraining, RoomID: &roomID}
+
+	n := jobNotification(job, nil)
+	if n.Type != "job_succeeded" || n.Title != "AI Coach training is ready" || n.RoomID != &roomID || *n.JobID != job.ID {
+		t.Errorf("Unexpected notification %+v", n)
+	}
+
+	n = jobNotification(job, errors.New("no resources to train with"))
+	if n.Type != "job_failed" || n.Body != "no resources to train with" {
+		t.Errorf("Expected the failure explained, got %+v", n)
+	}
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// defaultGeminiModel is used when no model is configured
+const defaultGeminiModel = "gemini-3-flash-preview"
+
+// geminiEmbeddingModel is the model used for text embeddings
+const geminiEmbeddingModel = "text-embedding-004"
+
+// GeminiLLMProvider generates text with Google Gemini
+type GeminiLLMProvider struct {
+	client    *genai.Client
+	model     string
+	threshold genai.HarmBlockThreshold // 0 keeps Gemini's default filters
+}
+
+// geminiThresholds maps configured filter levels to Gemini's block thresholds
+var geminiThresholds = map[string]genai.HarmBlockThreshold{
+	"low":    genai.HarmBlockLowAndAbove,
+	"medium": genai.HarmBlockMediumAndAbove,
+	"high":   genai.HarmBlockOnlyHigh,
+	"none":   genai.HarmBlockNone,
+}
+
+// geminiHarmCategories are the categories the content filters apply to
+var geminiHarmCategories = []genai.HarmCategory{
+	genai.HarmCategoryHarassment,
+	genai.HarmCategoryHateSpeech,
+	genai.HarmCategorySexuallyExplicit,
+	genai.HarmCategoryDangerousContent,
+}
+
+// NewGeminiLLMProvider creates a Gemini provider for a model (empty for the default)
+func NewGeminiLLMProvider(apiKey, model string) (*GeminiLLMProvider, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("Gemini API key is required")
+	}
+	if model == "" {
+		model = defaultGeminiModel
+	}
+
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return &GeminiLLMProvider{client: client, model: model}, nil
+}
+
+// SetSafetyThreshold sets how readily Gemini blocks harmful content: "low"
+// blocks the most, "none" nothing and "" keeps Gemini's default. Strict
+// requests always block from "low".
+func (p *GeminiLLMProvider) SetSafetyThreshold(level string) error {
+	if level == "" {
+		p.threshold = 0
+		return nil
+	}
+	threshold, ok := geminiThresholds[strings.ToLower(level)]
+	if !ok {
+		return fmt.Errorf("unknown Gemini safety threshold %q", level)
+	}
+	p.threshold = threshold
+	return nil
+}
+
+// Name identifies the provider and model
+func (p *GeminiLLMProvider) Name() string {
+	return "gemini:" + p.model
+}
+
+// model configures a generative model for a request
+func (p *GeminiLLMProvider) modelFor(req LLMRequest) *genai.GenerativeModel {
+	model := p.client.GenerativeModel(p.model)
+	model.SetTemperature(0.7)
+	model.SetTopP(0.95)
+	model.SetTopK(40)
+	// 8192 allows full JSON for game questions (10–20 items); 2048 was truncating
+	model.SetMaxOutputTokens(8192)
+	if req.Temperature != nil {
+		model.SetTemperature(*req.Temperature)
+	}
+	if req.MaxTokens > 0 {
+		model.SetMaxOutputTokens(int32(req.MaxTokens))
+	}
+	if req.System != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(req.System))
+	}
+	if req.JSON || req.Schema != nil {
+		model.ResponseMIMEType = "application/json"
+	}
+	if req.Schema != nil {
+		model.ResponseSchema = geminiSchema(req.Schema)
+	}
+	threshold := p.threshold
+	if req.Strict {
+		threshold = genai.HarmBlockLowAndAbove
+	}
+	if threshold != 0 {
+		for _, category := range geminiHarmCategories {
+			model.SafetySettings = append(model.SafetySettings, &genai.SafetySetting{Category: category, Threshold: threshold})
+		}
+	}
+	return model
+}
+
+// geminiError reports responses stopped by Gemini's safety filters as ErrLLMContentBlocked
+func geminiError(err error) error {
+	var blocked *genai.BlockedError
+	if errors.As(err, &blocked) {
+		return fmt.Errorf("%w (%v)", ErrLLMContentBlocked, err)
+	}
+	return err
+}
+
+// Generate completes a prompt
+func (p *GeminiLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	model := p.modelFor(req)
+	var resp *genai.GenerateContentResponse
+	var err error
+	if len(req.History) > 0 {
+		chat := model.StartChat()
+		chat.History = geminiHistory(req.History)
+		resp, err = chat.SendMessage(ctx, genai.Text(req.Prompt))
+	} else {
+		resp, err = model.GenerateContent(ctx, genai.Text(req.Prompt))
+	}
+	if err != nil {
+		return nil, geminiError(err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return nil, fmt.Errorf("no response generated")
+	}
+
+	result := &LLMResponse{Text: geminiText(resp), Model: p.model}
+	if resp.UsageMetadata != nil {
+		result.InputTokens = int(resp.UsageMetadata.PromptTokenCount)
+		result.OutputTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+	}
+	return result, nil
+}
+
+// GenerateStream completes a prompt with Gemini's streaming API
+func (p *GeminiLLMProvider) GenerateStream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	model := p.modelFor(req)
+	var iter *genai.GenerateContentResponseIterator
+	if len(req.History) > 0 {
+		chat := model.StartChat()
+		chat.History = geminiHistory(req.History)
+		iter = chat.SendMessageStream(ctx, genai.Text(req.Prompt))
+	} else {
+		iter = model.GenerateContentStream(ctx, genai.Text(req.Prompt))
+	}
+
+	result := &LLMResponse{Model: p.model}
+	var text strings.Builder
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, geminiError(err)
+		}
+		if delta := geminiText(resp); delta != "" {
+			text.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return nil, err
+			}
+		}
+		// Each chunk reports the usage so far
+		if resp.UsageMetadata != nil {
+			result.InputTokens = int(resp.UsageMetadata.PromptTokenCount)
+			result.OutputTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+		}
+	}
+	result.Text = text.String()
+	return result, nil
+}
+
+// geminiHistory converts conversation turns to Gemini's "user" and "model" roles
+func geminiHistory(history []LLMMessage) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(history))
+	for _, message := range history {
+		role := "user"
+		if message.Role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, &genai.Content{Role: role, Parts: []genai.Part{genai.Text(message.Content)}})
+	}
+	return contents
+}
+
+// geminiText joins the text parts of the first candidate
+func geminiText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text.WriteString(string(t))
+		}
+	}
+	return text.String()
+}
+
+// SupportsSchema reports that Gemini can constrain its output to a schema
+func (p *GeminiLLMProvider) SupportsSchema() bool {
+	return true
+}
+
+// geminiSchema converts a schema to Gemini's, which has no numeric or item-count bounds
+func geminiSchema(schema *JSONSchema) *genai.Schema {
+	if schema == nil {
+		return nil
+	}
+	converted := &genai.Schema{Enum: schema.Enum, Required: schema.Required, Items: geminiSchema(schema.Items)}
+	switch schema.Type {
+	case "string":
+		converted.Type = genai.TypeString
+		if len(schema.Enum) > 0 {
+			converted.Format = "enum"
+		}
+	case "integer":
+		converted.Type = genai.TypeInteger
+	case "number":
+		converted.Type = genai.TypeNumber
+	case "boolean":
+		converted.Type = genai.TypeBoolean
+	case "array":
+		converted.Type = genai.TypeArray
+	default:
+		converted.Type = genai.TypeObject
+	}
+	if len(schema.Properties) > 0 {
+		converted.Properties = make(map[string]*genai.Schema, len(schema.Properties))
+		for name, property := range schema.Properties {
+			converted.Properties[name] = geminiSchema(property)
+		}
+	}
+	return converted
+}
+
+// EmbedTexts returns an embedding vector for each text
+func (p *GeminiLLMProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	em := p.client.EmbeddingModel(geminiEmbeddingModel)
+	batch := em.NewBatch()
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+	}
+
+	resp, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+
+	vectors := make([][]float32, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		vectors[i] = embedding.Values
+	}
+	return vectors, nil
+}
+
+// Close closes the Gemini client
+func (p *GeminiLLMProvider) Close() error {
+	return p.client.Close()
+}
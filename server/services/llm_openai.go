@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultOpenAIBaseURL is used when no base URL is configured
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// openAIErrorBodyLimit bounds how much of an error response is kept
+const openAIErrorBodyLimit = 512
+
+// openAIContentFilter is the finish reason of a completion stopped by the server's content filter
+const openAIContentFilter = "content_filter"
+
+// OpenAILLMProvider generates text with any server implementing the OpenAI
+// chat completions API: OpenAI itself, or local Ollama and llama.cpp servers
+type OpenAILLMProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAILLMProvider creates a provider for an OpenAI-compatible endpoint.
+// The API key may be empty for local servers; the model is required.
+func NewOpenAILLMProvider(baseURL, apiKey, model string) (*OpenAILLMProvider, error) {
+	if model == "" {
+		return nil, errors.New("an OpenAI-compatible provider needs a model name")
+	}
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	return &OpenAILLMProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: llmRequestTimeout},
+	}, nil
+}
+
+// Name identifies the provider and model
+func (p *OpenAILLMProvider) Name() string {
+	return "openai:" + p.model
+}
+
+// SupportsSchema reports that the chat completions API takes a JSON schema
+// response format
+func (p *OpenAILLMProvider) SupportsSchema() bool {
+	return true
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model          string                 `json:"model"`
+	Messages       []openAIMessage        `json:"messages"`
+	Temperature    *float32               `json:"temperature,omitempty"`
+	MaxTokens      int                    `json:"max_tokens,omitempty"`
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+	Stream         bool                   `json:"stream,omitempty"`
+	StreamOptions  map[string]bool        `json:"stream_options,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// chatRequest builds the chat completions request body
+func (p *OpenAILLMProvider) chatRequest(req LLMRequest) openAIChatRequest {
+	body := openAIChatRequest{
+		Model:       p.model,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}
+	if req.System != "" {
+		body.Messages = append(body.Messages, openAIMessage{Role: "system", Content: req.System})
+	}
+	for _, message := range req.History {
+		body.Messages = append(body.Messages, openAIMessage{Role: message.Role, Content: message.Content})
+	}
+	body.Messages = append(body.Messages, openAIMessage{Role: "user", Content: req.Prompt})
+	if req.Schema != nil {
+		body.ResponseFormat = map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": map[string]interface{}{"name": "response", "schema": req.Schema},
+		}
+	} else if req.JSON {
+		body.ResponseFormat = map[string]interface{}{"type": "json_object"}
+	}
+	return body
+}
+
+// post sends a chat completions request and returns the response of a successful call
+func (p *OpenAILLMProvider) post(ctx context.Context, body openAIChatRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, openAIErrorBodyLimit))
+		return nil, fmt.Errorf("LLM request failed: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return resp, nil
+}
+
+// Generate completes a prompt with the chat completions endpoint
+func (p *OpenAILLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.post(ctx, p.chatRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var completion openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return nil, fmt.Errorf("invalid LLM response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("no response generated")
+	}
+	if completion.Choices[0].FinishReason == openAIContentFilter {
+		return nil, ErrLLMContentBlocked
+	}
+
+	model := completion.Model
+	if model == "" {
+		model = p.model
+	}
+	return &LLMResponse{
+		Text:         completion.Choices[0].Message.Content,
+		Model:        model,
+		InputTokens:  completion.Usage.PromptTokens,
+		OutputTokens: completion.Usage.CompletionTokens,
+	}, nil
+}
+
+// openAIStreamChunk is one server-sent event of a streamed completion
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// GenerateStream completes a prompt with a streamed chat completion
+func (p *OpenAILLMProvider) GenerateStream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	body := p.chatRequest(req)
+	body.Stream = true
+	body.StreamOptions = map[string]bool{"include_usage": true}
+
+	resp, err := p.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &LLMResponse{Model: p.model}
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("invalid LLM stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.InputTokens = chunk.Usage.PromptTokens
+			result.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason == openAIContentFilter {
+				return nil, ErrLLMContentBlocked
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			text.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result.Text = text.String()
+	return result, nil
+}
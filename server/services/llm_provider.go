@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"buddy-server/config"
+)
+
+// AI features whose provider and model can be chosen separately
+const (
+	LLMFeatureChat            = "chat"             // General /ai endpoints: chat, explanations, syllabus drafts
+	LLMFeatureRoomAI          = "room_ai"          // Room AI answers grounded in course resources
+	LLMFeatureGames           = "games"            // Game question generation
+	LLMFeatureReports         = "reports"          // Student reports
+	LLMFeatureGoalSuggestions = "goal_suggestions" // Daily goal suggestions
+	LLMFeatureProductivity    = "productivity"     // Study session assessment questions
+	LLMFeatureSmartPlan       = "smart_plan"       // Smart study plans
+)
+
+// LLMFeatures lists every feature that can have its own provider
+var LLMFeatures = []string{
+	LLMFeatureChat,
+	LLMFeatureRoomAI,
+	LLMFeatureGames,
+	LLMFeatureReports,
+	LLMFeatureGoalSuggestions,
+	LLMFeatureProductivity,
+	LLMFeatureSmartPlan,
+}
+
+// isLLMFeature reports whether a feature is one of LLMFeatures
+func isLLMFeature(feature string) bool {
+	for _, known := range LLMFeatures {
+		if feature == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrLLMNotConfigured is returned for a feature without a usable provider
+var ErrLLMNotConfigured = errors.New("no language model is configured for this feature")
+
+// ErrLLMContentBlocked is returned when the provider's own safety filters
+// refuse a prompt or stop a response
+var ErrLLMContentBlocked = errors.New("the language model's safety filters blocked this content")
+
+// LLMProvider generates text with a language model
+type LLMProvider interface {
+	// Name identifies the provider and model, e.g. "gemini:gemini-3-flash-preview"
+	Name() string
+	// Generate completes a prompt
+	Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+}
+
+// LLMStreamer is implemented by providers that can send a completion while it
+// is being generated
+type LLMStreamer interface {
+	// GenerateStream calls onDelta with each piece of text as it arrives and
+	// returns the whole completion; an error from onDelta stops the stream
+	GenerateStream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error)
+}
+
+// generateStream streams a completion from providers that support it and
+// sends the whole text as a single delta otherwise
+func generateStream(ctx context.Context, provider LLMProvider, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	if streamer, ok := provider.(LLMStreamer); ok {
+		return streamer.GenerateStream(ctx, req, onDelta)
+	}
+	resp, err := provider.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Text != "" {
+		if err := onDelta(resp.Text); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// LLMRequest is a generation request: a prompt, optionally continuing a conversation
+type LLMRequest struct {
+	System      string       // Optional instructions sent separately from the prompt
+	History     []LLMMessage // Earlier turns of the conversation, oldest first
+	Prompt      string
+	Temperature *float32    // nil uses the provider default
+	MaxTokens   int         // 0 uses the provider default
+	JSON        bool        // Ask for a JSON object as the whole response
+	Schema      *JSONSchema // Constrain the JSON to a schema where the provider supports it
+	UserText    string      // What the user wrote, for the safety checks; empty when the prompt is built from stored data
+	Strict      bool        // Use the provider's strictest content filters, for young students
+}
+
+// LLMMessage is one turn of a conversation
+type LLMMessage struct {
+	Role    string // "user" or "assistant"
+	Content string
+}
+
+// LLMResponse is a generated completion and what it cost
+type LLMResponse struct {
+	Text         string
+	Model        string
+	InputTokens  int // 0 when the provider doesn't report usage
+	OutputTokens int
+	Estimated    bool // The tokens were estimated from the text, e.g. for a stream that stopped early
+}
+
+// LLMProviders creates providers on demand and shares them between features
+// that use the same provider and model
+type LLMProviders struct {
+	config config.LLMConfig
+
+	mu        sync.Mutex
+	providers map[config.LLMSelection]LLMProvider
+}
+
+// NewLLMProviders creates a provider registry from configuration, warning
+// about overrides for features that don't exist
+func NewLLMProviders(cfg config.LLMConfig) *LLMProviders {
+	for feature := range cfg.Features {
+		if !isLLMFeature(feature) {
+			log.Printf("Ignoring LLM_%s: unknown AI feature", strings.ToUpper(feature))
+		}
+	}
+	return &LLMProviders{config: cfg, providers: make(map[config.LLMSelection]LLMProvider)}
+}
+
+// For returns the provider configured for a feature, or ErrLLMNotConfigured
+func (r *LLMProviders) For(feature string) (LLMProvider, error) {
+	selection := r.config.Selection(feature)
+	if selection.Provider == "" || selection.Provider == "none" {
+		return nil, ErrLLMNotConfigured
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if provider, ok := r.providers[selection]; ok {
+		return provider, nil
+	}
+	provider, err := r.create(selection)
+	if err != nil {
+		return nil, err
+	}
+	r.providers[selection] = provider
+	return provider, nil
+}
+
+// Gemini returns the Gemini provider with its default model when an API key
+// is configured; embeddings use its client
+func (r *LLMProviders) Gemini() (*GeminiLLMProvider, error) {
+	if r.config.GeminiAPIKey == "" {
+		return nil, ErrLLMNotConfigured
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	selection := config.LLMSelection{Provider: "gemini"}
+	if provider, ok := r.providers[selection]; ok {
+		return provider.(*GeminiLLMProvider), nil
+	}
+	provider, err := r.create(selection)
+	if err != nil {
+		return nil, err
+	}
+	r.providers[selection] = provider
+	return provider.(*GeminiLLMProvider), nil
+}
+
+func (r *LLMProviders) create(selection config.LLMSelection) (LLMProvider, error) {
+	switch selection.Provider {
+	case "gemini":
+		provider, err := NewGeminiLLMProvider(r.config.GeminiAPIKey, selection.Model)
+		if err != nil {
+			return nil, err
+		}
+		return provider, provider.SetSafetyThreshold(r.config.SafetyThreshold)
+	case "openai":
+		return NewOpenAILLMProvider(r.config.OpenAIBaseURL, r.config.OpenAIAPIKey, selection.Model)
+	case "fake":
+		if r.config.FakeScript == "" {
+			return NewScriptedLLMProvider(nil), nil
+		}
+		return LoadScriptedLLMProvider(r.config.FakeScript)
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", selection.Provider)
+	}
+}
+
+// Close releases the clients of all providers that hold one
+func (r *LLMProviders) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, provider := range r.providers {
+		if closer, ok := provider.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+}
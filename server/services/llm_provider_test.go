@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"buddy-server/config"
+)
+
+func TestLLMProvidersFor(t *testing.T) {
+	providers := NewLLMProviders(config.LLMConfig{
+		Default:  config.LLMSelection{Provider: "fake"},
+		Features: map[string]config.LLMSelection{LLMFeatureGames: {Provider: "none"}, LLMFeatureReports: {Provider: "bogus"}},
+	})
+
+	chat, err := providers.For(LLMFeatureChat)
+	if err != nil {
+		t.Fatalf("Expected the fake provider, got %v", err)
+	}
+	roomAI, _ := providers.For(LLMFeatureRoomAI)
+	if chat != roomAI {
+		t.Error("Expected features with the same selection to share a provider")
+	}
+	if _, err := providers.For(LLMFeatureGames); err != ErrLLMNotConfigured {
+		t.Errorf("Expected ErrLLMNotConfigured for a disabled feature, got %v", err)
+	}
+	if _, err := providers.For(LLMFeatureReports); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+	if _, err := providers.Gemini(); err != ErrLLMNotConfigured {
+		t.Errorf("Expected no Gemini provider without a key, got %v", err)
+	}
+}
+
+func TestScriptedLLMProvider(t *testing.T) {
+	provider := NewScriptedLLMProvider([]ScriptedReply{
+		{Match: "photosynthesis", Reply: "Plants turn light into sugar."},
+		{Match: "fail", Error: "model overloaded"},
+	})
+	ctx := context.Background()
+
+	resp, err := provider.Generate(ctx, LLMRequest{Prompt: "Explain PHOTOSYNTHESIS"})
+	if err != nil || resp.Text != "Plants turn light into sugar." {
+		t.Errorf("Expected the scripted reply, got %+v, %v", resp, err)
+	}
+	if resp.InputTokens != 2 || resp.OutputTokens != 5 {
+		t.Errorf("Expected word counts as token usage, got %d/%d", resp.InputTokens, resp.OutputTokens)
+	}
+	if _, err := provider.Generate(ctx, LLMRequest{Prompt: "this should fail"}); err == nil || err.Error() != "model overloaded" {
+		t.Errorf("Expected the scripted error, got %v", err)
+	}
+	if resp, _ := provider.Generate(ctx, LLMRequest{Prompt: "anything", JSON: true}); resp.Text != "{}" {
+		t.Errorf("Expected an empty JSON object without a match, got %q", resp.Text)
+	}
+	if n := len(provider.Requests()); n != 3 {
+		t.Errorf("Expected 3 recorded requests, got %d", n)
+	}
+}
+
+func TestOpenAILLMProvider(t *testing.T) {
+	var received openAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Expected the API key as bearer token, got %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"model":"llama3.1:8b","choices":[{"message":{"role":"assistant","content":"{\"ok\":true}"}}],"usage":{"prompt_tokens":12,"completion_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAILLMProvider(server.URL+"/v1/", "secret", "llama3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := provider.Generate(context.Background(), LLMRequest{System: "Be brief", Prompt: "Hi", JSON: true, MaxTokens: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != `{"ok":true}` || resp.Model != "llama3.1:8b" || resp.InputTokens != 12 || resp.OutputTokens != 4 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if received.Model != "llama3.1" || len(received.Messages) != 2 || received.Messages[0].Role != "system" ||
+		received.Messages[1].Content != "Hi" || received.MaxTokens != 100 || received.ResponseFormat["type"] != "json_object" {
+		t.Errorf("Unexpected request %+v", received)
+	}
+}
+
+func TestOpenAILLMProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	provider, _ := NewOpenAILLMProvider(server.URL, "", "missing")
+	_, err := provider.Generate(context.Background(), LLMRequest{Prompt: "Hi"})
+	if err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Expected the server's error, got %v", err)
+	}
+	if _, err := NewOpenAILLMProvider(server.URL, "", ""); err == nil {
+		t.Error("Expected an error without a model")
+	}
+}
+
+func TestAIServiceUsesProvider(t *testing.T) {
+	provider := NewScriptedLLMProvider([]ScriptedReply{{Match: "Student Question: What is osmosis?", Reply: "Water moving across a membrane [1]."}})
+	ai := NewAIService(provider)
+
+	answer, err := ai.GenerateRoomAIResponse(context.Background(), "What is osmosis?", "Biology 101", "[1] Osmosis is...", "", nil)
+	if err != nil || answer != "Water moving across a membrane [1]." {
+		t.Errorf("Expected the scripted answer, got %q, %v", answer, err)
+	}
+
+	empty := NewAIService(NewScriptedLLMProvider([]ScriptedReply{{Reply: "  "}}))
+	if _, err := empty.Chat(context.Background(), "Hello", ""); err == nil {
+		t.Error("Expected an error for an empty response")
+	}
+}
+
+func TestOpenAILLMProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openAIChatRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream {
+			t.Error("Expected a streaming request")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"model":"llama3.1:8b","choices":[{"delta":{"role":"assistant"}}]}`,
+			`{"choices":[{"delta":{"content":"Hello"}}]}`,
+			`{"choices":[{"delta":{"content":" there"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2}}`,
+			`[DONE]`,
+		} {
+			w.Write([]byte("data: " + chunk + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	provider, _ := NewOpenAILLMProvider(server.URL, "", "llama3.1")
+	var deltas []string
+	resp, err := provider.GenerateStream(context.Background(), LLMRequest{Prompt: "Hi"}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deltas, "|") != "Hello| there" {
+		t.Errorf("Unexpected deltas %q", deltas)
+	}
+	if resp.Text != "Hello there" || resp.Model != "llama3.1:8b" || resp.InputTokens != 3 || resp.OutputTokens != 2 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+}
+
+func TestAIServiceChatStream(t *testing.T) {
+	ai := NewAIService(NewScriptedLLMProvider([]ScriptedReply{{Reply: "Cells divide by mitosis."}}))
+
+	var deltas []string
+	response, err := ai.ChatStream(context.Background(), "How do cells divide?", "", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || response != "Cells divide by mitosis." {
+		t.Fatalf("Expected the whole response, got %q, %v", response, err)
+	}
+	if len(deltas) != 4 || strings.Join(deltas, "") != response {
+		t.Errorf("Expected the response a word at a time, got %q", deltas)
+	}
+
+	// A failing receiver stops the stream
+	stop := errors.New("client gone")
+	calls := 0
+	_, err = ai.ChatStream(context.Background(), "How do cells divide?", "", func(string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the stream to stop after the first delta, got %v after %d calls", err, calls)
+	}
+}
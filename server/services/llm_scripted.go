@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ScriptedReply answers prompts that contain Match (case-insensitive); an empty
+// Match answers any prompt. A non-empty Error fails the request instead, and
+// Blocked fails it as if the provider's safety filters had refused it.
+type ScriptedReply struct {
+	Match   string `json:"match"`
+	Reply   string `json:"reply"`
+	Error   string `json:"error,omitempty"`
+	Blocked bool   `json:"blocked,omitempty"`
+}
+
+// ScriptedLLMProvider is a deterministic fake for tests and offline development:
+// it answers with the first scripted reply matching the prompt and records
+// every request it receives
+type ScriptedLLMProvider struct {
+	replies []ScriptedReply
+
+	mu       sync.Mutex
+	requests []LLMRequest
+}
+
+// NewScriptedLLMProvider creates a fake provider with a script of replies
+func NewScriptedLLMProvider(replies []ScriptedReply) *ScriptedLLMProvider {
+	return &ScriptedLLMProvider{replies: replies}
+}
+
+// LoadScriptedLLMProvider reads a script from a JSON file holding an array of replies
+func LoadScriptedLLMProvider(path string) (*ScriptedLLMProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var replies []ScriptedReply
+	if err := json.Unmarshal(data, &replies); err != nil {
+		return nil, fmt.Errorf("invalid LLM script %s: %w", path, err)
+	}
+	return NewScriptedLLMProvider(replies), nil
+}
+
+// Name identifies the provider
+func (p *ScriptedLLMProvider) Name() string {
+	return "fake:scripted"
+}
+
+// Generate answers from the script; without a match it returns "{}" for JSON
+// requests and a fixed sentence otherwise
+func (p *ScriptedLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+
+	text := "This is a scripted reply."
+	if req.JSON {
+		text = "{}"
+	}
+	prompt := strings.ToLower(req.System + "\n" + req.Prompt)
+	for _, reply := range p.replies {
+		if reply.Match == "" || strings.Contains(prompt, strings.ToLower(reply.Match)) {
+			if reply.Error != "" {
+				return nil, errors.New(reply.Error)
+			}
+			if reply.Blocked {
+				return nil, ErrLLMContentBlocked
+			}
+			text = reply.Reply
+			break
+		}
+	}
+
+	return &LLMResponse{
+		Text:         text,
+		Model:        "scripted",
+		InputTokens:  len(strings.Fields(req.System)) + len(strings.Fields(req.Prompt)),
+		OutputTokens: len(strings.Fields(text)),
+	}, nil
+}
+
+// GenerateStream answers like Generate and sends the reply a word at a time
+func (p *ScriptedLLMProvider) GenerateStream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	resp, err := p.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, word := range strings.SplitAfter(resp.Text, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if word == "" {
+			continue
+		}
+		if err := onDelta(word); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// Requests returns the requests received so far
+func (p *ScriptedLLMProvider) Requests() []LLMRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]LLMRequest(nil), p.requests...)
+}
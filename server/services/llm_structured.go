@@ -0,0 +1,392 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// structuredOutputAttempts bounds how often a structured request is sent: the
+// first attempt and up to two repairs
+const structuredOutputAttempts = 3
+
+// structuredOutputSnippet bounds how much of a rejected response is quoted back
+// to the model in a repair prompt
+const structuredOutputSnippet = 4000
+
+// structuredErrorSnippet bounds how much raw output goes into error messages
+const structuredErrorSnippet = 300
+
+// errTruncatedJSON is reported when a response ends before its JSON is complete,
+// usually because it hit the output token limit
+var errTruncatedJSON = errors.New("the response was cut off before the JSON was complete")
+
+// JSONSchema describes the JSON a structured request must produce. It is the
+// subset of JSON Schema that the providers' native JSON modes understand.
+type JSONSchema struct {
+	Type       string                 `json:"type,omitempty"` // string, integer, number, boolean, array or object; empty for any value
+	Enum       []string               `json:"enum,omitempty"`
+	Minimum    *float64               `json:"minimum,omitempty"`
+	Maximum    *float64               `json:"maximum,omitempty"`
+	Items      *JSONSchema            `json:"items,omitempty"`
+	MinItems   int                    `json:"minItems,omitempty"`
+	MaxItems   int                    `json:"maxItems,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+}
+
+// schemaLLMProvider is implemented by providers that can constrain their output
+// to a JSON schema; other providers get the schema in the instructions
+type schemaLLMProvider interface {
+	SupportsSchema() bool
+}
+
+// structuredValidator is implemented by output types with rules beyond their schema
+type structuredValidator interface {
+	Validate() error
+}
+
+// StructuredOutputError reports model output that was still unusable after
+// every repair attempt
+type StructuredOutputError struct {
+	Type     string // Go type that was requested
+	Attempts int
+	Raw      string // Output of the last attempt
+	Err      error  // Why it was rejected
+}
+
+func (e *StructuredOutputError) Error() string {
+	raw := e.Raw
+	if len(raw) > structuredErrorSnippet {
+		raw = raw[:structuredErrorSnippet] + "..."
+	}
+	return fmt.Sprintf("the AI response could not be used after %d attempts: %v (output: %q)", e.Attempts, e.Err, raw)
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+var schemaCache sync.Map // reflect.Type -> *JSONSchema
+
+// SchemaFor derives the JSON schema of a Go type from its JSON field names.
+// Fields without omitempty are required, and a `schema` tag adds constraints:
+// "enum=a|b|c", "min=N" and "max=N" (the item count for slices), or "optional".
+func SchemaFor(t reflect.Type) *JSONSchema {
+	if cached, ok := schemaCache.Load(t); ok {
+		return cached.(*JSONSchema)
+	}
+	schema := buildSchema(t)
+	schemaCache.Store(t, schema)
+	return schema
+}
+
+func buildSchema(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: buildSchema(t.Elem())}
+	case reflect.Struct:
+		schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := buildSchema(field.Type)
+			required := !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer
+			for _, rule := range strings.Split(field.Tag.Get("schema"), ",") {
+				key, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+				switch key {
+				case "optional":
+					required = false
+				case "enum":
+					property.Enum = strings.Split(value, "|")
+				case "min", "max":
+					n, err := strconv.ParseFloat(value, 64)
+					if err != nil {
+						panic(fmt.Sprintf("invalid schema tag on %s.%s: %s", t.Name(), field.Name, rule))
+					}
+					switch {
+					case property.Type == "array" && key == "min":
+						property.MinItems = int(n)
+					case property.Type == "array":
+						property.MaxItems = int(n)
+					case key == "min":
+						property.Minimum = &n
+					default:
+						property.Maximum = &n
+					}
+				}
+			}
+			schema.Properties[name] = property
+			if required {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		return schema
+	default:
+		// Maps and interfaces accept any value
+		return &JSONSchema{}
+	}
+}
+
+// generateStructured asks the model for JSON matching T's schema. Responses that
+// can't be parsed or don't validate are sent back with the problems listed,
+// up to structuredOutputAttempts times in total.
+func generateStructured[T any](ctx context.Context, provider LLMProvider, req LLMRequest) (*T, error) {
+	typeName := reflect.TypeOf((*T)(nil)).Elem().String()
+	schema := SchemaFor(reflect.TypeOf((*T)(nil)).Elem())
+	req.JSON = true
+	req.Schema = schema
+	if native, ok := provider.(schemaLLMProvider); !ok || !native.SupportsSchema() {
+		schemaJSON, _ := json.Marshal(schema)
+		instructions := "Respond with a single JSON value matching this JSON Schema, without markdown or commentary:\n" + string(schemaJSON)
+		if req.System != "" {
+			instructions = req.System + "\n\n" + instructions
+		}
+		req.System = instructions
+	}
+
+	prompt := req.Prompt
+	var raw string
+	var lastErr error
+	for attempt := 1; attempt <= structuredOutputAttempts; attempt++ {
+		resp, err := provider.Generate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		raw = resp.Text
+
+		var value T
+		if lastErr = decodeStructured(raw, schema, &value); lastErr == nil {
+			return &value, nil
+		}
+		log.Printf("Structured output: attempt %d/%d for %s from %s rejected: %v", attempt, structuredOutputAttempts, typeName, provider.Name(), lastErr)
+		req.Prompt = repairPrompt(prompt, raw, lastErr)
+	}
+
+	log.Printf("Structured output: giving up on %s; last output: %s", typeName, raw)
+	return nil, &StructuredOutputError{Type: typeName, Attempts: structuredOutputAttempts, Raw: raw, Err: lastErr}
+}
+
+// repairPrompt repeats the original request with the rejected response and what was wrong with it
+func repairPrompt(prompt, raw string, problem error) string {
+	previous := strings.TrimSpace(raw)
+	if len(previous) > structuredOutputSnippet {
+		previous = previous[:structuredOutputSnippet] + "\n[...]"
+	}
+	hint := "Reply again with the complete JSON only, fixing these problems."
+	if errors.Is(problem, errTruncatedJSON) {
+		hint = "Reply again with the complete JSON only, keeping text fields short so the whole response fits."
+	}
+	return fmt.Sprintf("%s\n\nYour previous response could not be used:\n%v\n\nPrevious response:\n%s\n\n%s", prompt, problem, previous, hint)
+}
+
+// decodeStructured extracts the JSON from a response, checks it against the
+// schema and decodes it into out
+func decodeStructured(raw string, schema *JSONSchema, out interface{}) error {
+	text, err := extractJSON(raw)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if problems := validateJSON(value, schema, "", nil); len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if validator, ok := out.(structuredValidator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// extractJSON returns the first JSON object or array in a response, without
+// markdown fences or surrounding prose and with trailing commas removed
+func extractJSON(raw string) (string, error) {
+	start := strings.IndexAny(raw, "{[")
+	if start < 0 {
+		if strings.TrimSpace(raw) == "" {
+			return "", errors.New("the response was empty")
+		}
+		return "", errors.New("the response contains no JSON object")
+	}
+
+	var out bytes.Buffer
+	var stack []byte
+	inString, escaped := false, false
+	for i := start; i < len(raw); i++ {
+		c := raw[i]
+		if inString {
+			out.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, c)
+		case '}', ']':
+			if len(stack) == 0 || (c == '}') != (stack[len(stack)-1] == '{') {
+				return "", fmt.Errorf("unbalanced %q in JSON", c)
+			}
+			stack = stack[:len(stack)-1]
+			// Drop a trailing comma before the closing bracket
+			trimmed := bytes.TrimRight(out.Bytes(), " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out.Truncate(len(trimmed) - 1)
+			}
+		}
+		out.WriteByte(c)
+		if len(stack) == 0 {
+			return out.String(), nil
+		}
+	}
+	return "", errTruncatedJSON
+}
+
+// maxSchemaProblems bounds how many problems are reported for one response
+const maxSchemaProblems = 10
+
+// validateJSON checks a decoded value against a schema and returns what doesn't match
+func validateJSON(value interface{}, schema *JSONSchema, path string, problems []string) []string {
+	if len(problems) >= maxSchemaProblems {
+		return problems
+	}
+	where := path
+	if where == "" {
+		where = "the response"
+	}
+
+	switch schema.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return append(problems, where+" must be a string")
+		}
+		if len(schema.Enum) > 0 && !containsString(schema.Enum, s) {
+			return append(problems, fmt.Sprintf("%s must be one of %s, not %q", where, strings.Join(schema.Enum, ", "), s))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return append(problems, where+" must be true or false")
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return append(problems, where+" must be a number")
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return append(problems, where+" must be a number")
+		}
+		if schema.Type == "integer" && f != float64(int64(f)) {
+			return append(problems, where+" must be a whole number")
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return append(problems, fmt.Sprintf("%s must be at least %v", where, *schema.Minimum))
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return append(problems, fmt.Sprintf("%s must be at most %v", where, *schema.Maximum))
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(problems, where+" must be an array")
+		}
+		if len(items) < schema.MinItems {
+			return append(problems, fmt.Sprintf("%s must have at least %d items", where, schema.MinItems))
+		}
+		if schema.MaxItems > 0 && len(items) > schema.MaxItems {
+			return append(problems, fmt.Sprintf("%s must have at most %d items", where, schema.MaxItems))
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				problems = validateJSON(item, schema.Items, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(problems, where+" must be an object")
+		}
+		for _, name := range schema.Required {
+			if v, present := object[name]; !present || v == nil {
+				problems = append(problems, joinJSONPath(path, name)+" is required")
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, present := object[name]; present && v != nil {
+				problems = validateJSON(v, schema.Properties[name], joinJSONPath(path, name), problems)
+			}
+		}
+	}
+	if len(problems) > maxSchemaProblems {
+		problems = problems[:maxSchemaProblems]
+	}
+	return problems
+}
+
+func joinJSONPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
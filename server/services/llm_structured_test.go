@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"buddy-server/models"
+)
+
+const validPlanJSON = `{"name": "Algebra", "description": "Basics", "schedule_blocks": [{"day_of_week": 1, "start_time": "09:00", "end_time": "10:30", "subject": "Math", "topic": "Equations", "block_type": "study"}], "milestones": [{"title": "Week 1", "description": "Equations", "target_date": "2026-02-10", "progress": 0}]}`
+
+func TestSchemaFor(t *testing.T) {
+	schema := SchemaFor(reflect.TypeOf(GeneratedPlanResponse{}))
+	if schema.Type != "object" || !reflect.DeepEqual(schema.Required, []string{"name", "description", "schedule_blocks", "milestones"}) {
+		t.Fatalf("Unexpected plan schema %+v", schema)
+	}
+	blocks := schema.Properties["schedule_blocks"]
+	if blocks.Type != "array" || blocks.MinItems != 1 {
+		t.Errorf("Expected schedule_blocks to need at least one item, got %+v", blocks)
+	}
+	day := blocks.Items.Properties["day_of_week"]
+	if day.Type != "integer" || *day.Minimum != 0 || *day.Maximum != 6 {
+		t.Errorf("Expected day_of_week to be an integer from 0 to 6, got %+v", day)
+	}
+	if enum := blocks.Items.Properties["block_type"].Enum; !reflect.DeepEqual(enum, []string{"study", "review", "practice"}) {
+		t.Errorf("Unexpected block_type enum %v", enum)
+	}
+
+	type limited struct {
+		Tags []string `json:"tags" schema:"min=1,max=3"`
+	}
+	tags := SchemaFor(reflect.TypeOf(limited{})).Properties["tags"]
+	if tags.MinItems != 1 || tags.MaxItems != 3 || tags.Maximum != nil {
+		t.Errorf("Expected max to bound the item count of a slice, got %+v", tags)
+	}
+	var value limited
+	if err := decodeStructured(`{"tags": ["a", "b", "c", "d"]}`, SchemaFor(reflect.TypeOf(value)), &value); err == nil || !strings.Contains(err.Error(), "tags must have at most 3 items") {
+		t.Errorf("Expected too many items to be rejected, got %v", err)
+	}
+
+	questions := SchemaFor(reflect.TypeOf(GameQuestionSet{})).Properties["questions"].Items
+	for _, name := range questions.Required {
+		if name == "options" || name == "explanation" {
+			t.Errorf("Expected omitempty field %s to be optional", name)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	cases := map[string]string{
+		"```json\n{\"a\": 1}\n```":           `{"a": 1}`,
+		"Here is the plan:\n{\"a\": [1, 2]}": `{"a": [1, 2]}`,
+		`{"a": [1, 2,], "b": "x",}`:          `{"a": [1, 2], "b": "x"}`,
+		`{"a": "braces } and ] in text"} ok`: `{"a": "braces } and ] in text"}`,
+		`{"a": "escaped \" quote"}`:          `{"a": "escaped \" quote"}`,
+	}
+	for raw, want := range cases {
+		if got, err := extractJSON(raw); err != nil || got != want {
+			t.Errorf("extractJSON(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := extractJSON(`{"questions": [{"question": "What is`); !errors.Is(err, errTruncatedJSON) {
+		t.Errorf("Expected a truncation error, got %v", err)
+	}
+	if _, err := extractJSON("Sorry, I can't help with that."); err == nil {
+		t.Error("Expected an error without JSON")
+	}
+}
+
+func TestDecodeStructuredReportsProblems(t *testing.T) {
+	raw := `{"name": "Plan", "description": "", "schedule_blocks": [{"day_of_week": 9, "start_time": "09:00", "end_time": "10:00", "subject": "Math", "topic": "x", "block_type": "lecture"}]}`
+	var plan GeneratedPlanResponse
+	err := decodeStructured(raw, SchemaFor(reflect.TypeOf(plan)), &plan)
+	if err == nil {
+		t.Fatal("Expected validation problems")
+	}
+	for _, problem := range []string{"milestones is required", "schedule_blocks[0].block_type must be one of study, review, practice", "schedule_blocks[0].day_of_week must be at most 6"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q in %q", problem, err)
+		}
+	}
+
+	raw = strings.Replace(validPlanJSON, `"10:30"`, `"08:00"`, 1)
+	if err := decodeStructured(raw, SchemaFor(reflect.TypeOf(plan)), &plan); err == nil || !strings.Contains(err.Error(), "must end after it starts") {
+		t.Errorf("Expected the type's own validation to run, got %v", err)
+	}
+}
+
+func TestGameQuestionSetValidate(t *testing.T) {
+	quiz := &GameQuestionSet{Questions: []models.GameQuestion{
+		{Question: "Which gas do plants release?", Options: []string{"Nitrogen", "Oxygen"}, CorrectAnswer: " Oxygen "},
+		{Question: "Hola", CorrectAnswer: "Hello"}, // Flashcards have no options
+	}}
+	if err := quiz.Validate(); err != nil {
+		t.Errorf("Expected a valid quiz, got %v", err)
+	}
+	for _, answer := range []string{"B", "oxygen", "Helium"} {
+		quiz.Questions[0].CorrectAnswer = answer
+		if err := quiz.Validate(); err == nil || !strings.Contains(err.Error(), "questions[0].correct_answer must be the text of one of its options") {
+			t.Errorf("Expected answer %q to be rejected, got %v", answer, err)
+		}
+	}
+}
+
+func TestGenerateStructuredRepairs(t *testing.T) {
+	provider := NewScriptedLLMProvider([]ScriptedReply{
+		{Match: "previous response could not be used", Reply: validPlanJSON},
+		{Reply: strings.Replace(validPlanJSON, `"study"`, `"lecture"`, 1)},
+	})
+
+	plan, err := generateStructured[GeneratedPlanResponse](context.Background(), provider, LLMRequest{Prompt: "Make a plan"})
+	if err != nil {
+		t.Fatalf("Expected the repaired plan, got %v", err)
+	}
+	if plan.Name != "Algebra" || len(plan.ScheduleBlocks) != 1 {
+		t.Errorf("Unexpected plan %+v", plan)
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected one repair, got %d requests", len(requests))
+	}
+	if !requests[0].JSON || requests[0].Schema == nil || !strings.Contains(requests[0].System, `"block_type"`) {
+		t.Error("Expected a JSON request with the schema in the instructions")
+	}
+	if repair := requests[1].Prompt; !strings.HasPrefix(repair, "Make a plan") || !strings.Contains(repair, `not "lecture"`) {
+		t.Errorf("Expected the repair prompt to repeat the request and name the problem, got %q", repair)
+	}
+}
+
+func TestGenerateStructuredGivesUp(t *testing.T) {
+	truncated := `{"questions": [{"question": "What is 2 + 2?", "correct_answer": "4", "points": 10}, {"question": "What is`
+	provider := NewScriptedLLMProvider([]ScriptedReply{{Reply: truncated}})
+
+	_, err := generateStructured[GameQuestionSet](context.Background(), provider, LLMRequest{Prompt: "Make questions"})
+	var structuredErr *StructuredOutputError
+	if !errors.As(err, &structuredErr) {
+		t.Fatalf("Expected a StructuredOutputError, got %v", err)
+	}
+	if structuredErr.Raw != truncated || structuredErr.Attempts != structuredOutputAttempts || !errors.Is(err, errTruncatedJSON) {
+		t.Errorf("Unexpected error %+v", structuredErr)
+	}
+	if n := len(provider.Requests()); n != structuredOutputAttempts {
+		t.Errorf("Expected %d attempts, got %d", structuredOutputAttempts, n)
+	}
+	if !strings.Contains(provider.Requests()[1].Prompt, "keeping text fields short") {
+		t.Error("Expected the repair prompt to ask for a shorter response")
+	}
+
+	failing := NewScriptedLLMProvider([]ScriptedReply{{Error: "quota exceeded"}})
+	if _, err := generateStructured[GameQuestionSet](context.Background(), failing, LLMRequest{Prompt: "Make questions"}); err == nil || err.Error() != "quota exceeded" {
+		t.Errorf("Expected provider errors to be returned as is, got %v", err)
+	}
+	if n := len(failing.Requests()); n != 1 {
+		t.Errorf("Expected provider errors not to be retried, got %d requests", n)
+	}
+}
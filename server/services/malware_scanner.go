@@ -0,0 +1,160 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ScanResult is the verdict of a malware scan
+type ScanResult struct {
+	Clean  bool
+	Threat string // Signature name when not clean
+}
+
+// MalwareScanner scans uploaded content before it is released from quarantine
+type MalwareScanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (*ScanResult, error)
+}
+
+// NewMalwareScanner creates a scanner from a clamd address such as
+// "tcp://localhost:3310" or "unix:///var/run/clamav/clamd.ctl". An empty
+// address disables scanning.
+func NewMalwareScanner(clamdAddress string) (MalwareScanner, error) {
+	if clamdAddress == "" {
+		return NoopScanner{}, nil
+	}
+	return NewClamdScanner(clamdAddress)
+}
+
+// NoopScanner accepts every file; used when no scanner is configured
+type NoopScanner struct{}
+
+// Name identifies the scanner
+func (NoopScanner) Name() string { return "none" }
+
+// Scan reports every file as clean
+func (NoopScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	return &ScanResult{Clean: true}, nil
+}
+
+// clamdChunkSize is the INSTREAM chunk size; clamd's StreamMaxLength still bounds the total
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner scans files with a ClamAV daemon using the INSTREAM command
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner creates a clamd client for a tcp:// or unix:// address
+// (a bare host:port is treated as TCP)
+func NewClamdScanner(address string) (*ClamdScanner, error) {
+	network, addr := "tcp", address
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		addr = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("unsupported clamd address %q", address)
+	}
+	if addr == "" {
+		return nil, errors.New("clamd address is empty")
+	}
+	return &ClamdScanner{network: network, address: addr, timeout: 5 * time.Minute}, nil
+}
+
+// Name identifies the scanner
+func (s *ClamdScanner) Name() string {
+	return "clamd"
+}
+
+// Scan streams r to clamd and parses its verdict
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+
+	// Each chunk is prefixed with its length as a 4-byte big-endian integer;
+	// a zero-length chunk ends the stream
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := conn.Write(size[:]); err != nil {
+				return nil, clamdWriteError(conn, err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, clamdWriteError(conn, err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return nil, clamdWriteError(conn, err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// clamdWriteError prefers clamd's own explanation (e.g. "INSTREAM size limit
+// exceeded") when it closes the connection mid-stream
+func clamdWriteError(conn net.Conn, err error) error {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if reply, _ := bufio.NewReader(conn).ReadString(0); reply != "" {
+		if _, parseErr := parseClamdReply(reply); parseErr != nil {
+			return parseErr
+		}
+	}
+	return fmt.Errorf("clamd: %w", err)
+}
+
+// parseClamdReply parses "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+func parseClamdReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &ScanResult{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &ScanResult{Clean: false, Threat: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return nil, fmt.Errorf("clamd: %s", strings.TrimSuffix(reply, " ERROR"))
+	default:
+		return nil, fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+}
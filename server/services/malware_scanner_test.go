@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+// eicar is the standard antivirus test file
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd speaks enough of the clamd INSTREAM protocol to flag the EICAR string
+func fakeClamd(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var content bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					io.CopyN(&content, r, int64(size))
+				}
+				if strings.Contains(content.String(), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+					conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestClamdScanner(t *testing.T) {
+	scanner, err := NewClamdScanner(fakeClamd(t))
+	if err != nil {
+		t.Fatalf("NewClamdScanner failed: %v", err)
+	}
+
+	// Larger than one chunk to exercise chunking
+	clean := strings.Repeat("lecture notes ", 10000)
+	result, err := scanner.Scan(context.Background(), strings.NewReader(clean))
+	if err != nil || !result.Clean {
+		t.Errorf("Expected clean result, got %+v, %v", result, err)
+	}
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil || result.Clean || result.Threat != "Win.Test.EICAR_HDB-1" {
+		t.Errorf("Expected EICAR to be detected, got %+v, %v", result, err)
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	if _, err := parseClamdReply("INSTREAM size limit exceeded. ERROR\x00"); err == nil {
+		t.Error("Expected an error reply to fail")
+	}
+	if _, err := NewClamdScanner("http://localhost:3310"); err == nil {
+		t.Error("Expected unsupported address scheme to fail")
+	}
+	if s, err := NewClamdScanner("unix:///var/run/clamav/clamd.ctl"); err != nil || s.network != "unix" {
+		t.Errorf("Unexpected unix scanner %+v, %v", s, err)
+	}
+}
+
+// TestClamdScannerDaemon runs against a real daemon when configured, e.g.
+//
+//	docker run -p 3310:3310 clamav/clamav
+//	CLAMD_TEST_ADDRESS=tcp://localhost:3310 go test ./services -run ClamdScannerDaemon
+func TestClamdScannerDaemon(t *testing.T) {
+	address := os.Getenv("CLAMD_TEST_ADDRESS")
+	if address == "" {
+		t.Skip("CLAMD_TEST_ADDRESS not set")
+	}
+
+	scanner, err := NewClamdScanner(address)
+	if err != nil {
+		t.Fatalf("NewClamdScanner failed: %v", err)
+	}
+	result, err := scanner.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil || result.Clean {
+		t.Errorf("Expected EICAR to be detected, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NotificationService stores in-app notifications for users
+type NotificationService struct {
+	db *database.DB
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *database.DB) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// Notify creates the same notification for each user
+func (s *NotificationService) Notify(userIDs []primitive.ObjectID, notification models.Notification) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	notification.Read = false
+	notification.CreatedAt = time.Now()
+	docs := make([]interface{}, 0, len(userIDs))
+	for _, userID := range userIDs {
+		n := notification
+		n.UserID = userID
+		docs = append(docs, n)
+	}
+
+	_, err := s.db.Collection("notifications").InsertMany(ctx, docs)
+	return err
+}
+
+// NotifyResourceAudience notifies everyone who can see a resource except the
+// user who changed it: room members for teacher and public resources, the
+// users it is shared with otherwise, and the room owner
+func (s *NotificationService) NotifyResourceAudience(resource *models.Resource, actorID primitive.ObjectID, notification models.Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var room struct {
+		OwnerID primitive.ObjectID `bson:"owner_id"`
+	}
+	err := s.db.Collection("rooms").FindOne(ctx, bson.M{"_id": resource.RoomID},
+		options.FindOne().SetProjection(bson.M{"owner_id": 1})).Decode(&room)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	cursor, err := s.db.Collection("room_members").Find(ctx, bson.M{
+		"room_id":   resource.RoomID,
+		"is_active": true,
+	}, options.Find().SetProjection(bson.M{"user_id": 1}))
+	if err != nil {
+		return err
+	}
+	var members []struct {
+		UserID primitive.ObjectID `bson:"user_id"`
+	}
+	if err := cursor.All(ctx, &members); err != nil {
+		return err
+	}
+	memberIDs := make([]primitive.ObjectID, 0, len(members))
+	for _, m := range members {
+		memberIDs = append(memberIDs, m.UserID)
+	}
+
+	roomID, resourceID := resource.RoomID, resource.ID
+	notification.RoomID = &roomID
+	notification.ResourceID = &resourceID
+	return s.Notify(resourceAudience(resource, memberIDs, room.OwnerID, actorID), notification)
+}
+
+// resourceAudience picks the users who can see a resource, without duplicates or the actor
+func resourceAudience(resource *models.Resource, memberIDs []primitive.ObjectID, ownerID, actorID primitive.ObjectID) []primitive.ObjectID {
+	candidates := memberIDs
+	if resource.UploaderType == "student" && !resource.IsPublic {
+		candidates = resource.SharedWith
+	}
+	candidates = append(append([]primitive.ObjectID{}, candidates...), ownerID, resource.UploaderID)
+
+	seen := map[primitive.ObjectID]bool{actorID: true, primitive.NilObjectID: true}
+	var audience []primitive.ObjectID
+	for _, id := range candidates {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		audience = append(audience, id)
+	}
+	return audience
+}
+
+// GetNotifications lists a user's notifications, newest first
+func (s *NotificationService) GetNotifications(userID string, unreadOnly bool, limit int64) ([]models.Notification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := bson.M{"user_id": userObjectID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	cursor, err := s.db.Collection("notifications").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// CountUnread counts a user's unread notifications
+func (s *NotificationService) CountUnread(userID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, errors.New("invalid user ID")
+	}
+	return s.db.Collection("notifications").CountDocuments(ctx, bson.M{"user_id": userObjectID, "read": false})
+}
+
+// MarkRead marks one of a user's notifications as read
+func (s *NotificationService) MarkRead(notificationID, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	notificationObjectID, err := primitive.ObjectIDFromHex(notificationID)
+	if err != nil {
+		return errors.New("invalid notification ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	result, err := s.db.Collection("notifications").UpdateOne(ctx,
+		bson.M{"_id": notificationObjectID, "user_id": userObjectID},
+		bson.M{"$set": bson.M{"read": true, "read_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("notification not found")
+	}
+	return nil
+}
+
+// MarkAllRead marks all of a user's notifications as read
+func (s *NotificationService) MarkAllRead(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	_, err = s.db.Collection("notifications").UpdateMany(ctx,
+		bson.M{"user_id": userObjectID, "read": false},
+		bson.M{"$set": bson.M{"read": true, "read_at": time.Now()}},
+	)
+	return err
+}
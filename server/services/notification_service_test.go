@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResourceAudience(t *testing.T) {
+	owner := primitive.NewObjectID()
+	uploader := primitive.NewObjectID()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+	members := []primitive.ObjectID{uploader, alice, bob}
+
+	teacherResource := &models.Resource{UploaderID: uploader, UploaderType: "teacher", IsPublic: true}
+	got := resourceAudience(teacherResource, members, owner, uploader)
+	if len(got) != 3 {
+		t.Fatalf("Expected members and owner without the uploader, got %v", got)
+	}
+	for _, id := range got {
+		if id == uploader {
+			t.Errorf("The user who made the change should not be notified")
+		}
+	}
+
+	privateResource := &models.Resource{UploaderID: uploader, UploaderType: "student", SharedWith: []primitive.ObjectID{bob}}
+	got = resourceAudience(privateResource, members, owner, uploader)
+	if len(got) != 2 || got[0] != bob || got[1] != owner {
+		t.Errorf("Expected only the shared user and the room owner, got %v", got)
+	}
+
+	// The owner updating their own resource isn't notified twice or at all
+	got = resourceAudience(&models.Resource{UploaderID: owner, UploaderType: "teacher"}, append(members, owner), owner, owner)
+	for _, id := range got {
+		if id == owner {
+			t.Errorf("Owner should not be notified of their own change")
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected 3 recipients, got %d", len(got))
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	if v := currentVersion(&models.Resource{}); v != 1 {
+		t.Errorf("Resources created before versioning should be version 1, got %d", v)
+	}
+	if v := currentVersion(&models.Resource{Version: 4}); v != 4 {
+		t.Errorf("Expected version 4, got %d", v)
+	}
+}
@@ -0,0 +1,1028 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"buddy-server/models"
+)
+
+// A small, dependency-free PDF text extractor. It understands the object
+// layout (including compressed object streams), the page tree, Flate /
+// ASCIIHex / ASCII85 streams and ToUnicode CMaps, which covers the lecture
+// notes and slide exports teachers usually upload. Scanned PDFs (images only)
+// yield no text.
+
+// pdfObject is a parsed indirect object
+type pdfObject struct {
+	dict   string // dictionary or body text
+	stream []byte // raw, still-encoded stream data; nil if the object has no stream
+}
+
+// pdfFile is an in-memory view of a PDF's objects
+type pdfFile struct {
+	objects  map[int]*pdfObject
+	fonts    map[int]*pdfFont
+	inflated int64 // Bytes decompressed so far, bounded by maxPDFInflatedSize
+	tooLarge bool  // A stream or the document went over its decompression limit
+}
+
+// pdfPage is a leaf of the page tree with its (possibly inherited) resources
+type pdfPage struct {
+	dict      map[string]string
+	resources map[string]string
+}
+
+var (
+	pdfObjHeader     = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	pdfRefPattern    = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	pdfSingleRef     = regexp.MustCompile(`^(\d+)\s+\d+\s+R$`)
+	pdfLengthPattern = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	cmapTokenPattern = regexp.MustCompile(`<[0-9A-Fa-f\s]*>|\[|\]`)
+)
+
+const (
+	// maxPDFPageDepth bounds recursion through malformed page trees
+	maxPDFPageDepth = 64
+	// maxPDFStreamSize bounds one decompressed stream; the largest previewed
+	// raw image (40 megapixels of RGB) fits
+	maxPDFStreamSize = 128 << 20
+	// maxPDFInflatedSize bounds everything decompressed from one document, so
+	// a small file of compressed zeros can't exhaust memory
+	maxPDFInflatedSize = 256 << 20
+)
+
+// ErrPDFTooLarge is returned for a PDF whose streams decompress to more than the limits allow
+var ErrPDFTooLarge = errors.New("PDF content is too large to process")
+
+// extractPDFPages extracts one section per PDF page
+func extractPDFPages(data []byte) (*ExtractedDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+
+	f := parsePDF(data)
+	pages := f.pages()
+	if f.tooLarge {
+		return nil, ErrPDFTooLarge
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("no pages found in PDF")
+	}
+
+	doc := &ExtractedDocument{PageCount: len(pages)}
+	for i, page := range pages {
+		doc.Sections = append(doc.Sections, models.TextSection{
+			Label: fmt.Sprintf("Page %d", i+1),
+			Text:  f.pageText(page),
+		})
+	}
+	if f.tooLarge {
+		return nil, ErrPDFTooLarge
+	}
+	return doc, nil
+}
+
+// parsePDF scans the file for indirect objects and unpacks object streams
+func parsePDF(data []byte) *pdfFile {
+	f := &pdfFile{objects: map[int]*pdfObject{}, fonts: map[int]*pdfFont{}}
+
+	pos := 0
+	for pos < len(data) {
+		loc := pdfObjHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		bodyStart := pos + loc[1]
+		obj, next := readPDFObject(data, bodyStart)
+		// Later definitions win, which matches incremental updates
+		f.objects[num] = obj
+		pos = next
+	}
+
+	// Unpack compressed object streams (PDF 1.5+)
+	var objStreams []*pdfObject
+	for _, obj := range f.objects {
+		if obj.stream != nil && parsePDFDict(obj.dict)["/Type"] == "/ObjStm" {
+			objStreams = append(objStreams, obj)
+		}
+	}
+	for _, obj := range objStreams {
+		f.unpackObjectStream(obj)
+	}
+
+	return f
+}
+
+// readPDFObject reads an object body starting after "N G obj" and returns it with the offset past it
+func readPDFObject(data []byte, start int) (*pdfObject, int) {
+	rest := data[start:]
+	endobj := bytes.Index(rest, []byte("endobj"))
+	if endobj < 0 {
+		endobj = len(rest)
+	}
+
+	streamIdx := bytes.Index(rest[:endobj], []byte("stream"))
+	if streamIdx < 0 {
+		return &pdfObject{dict: string(rest[:endobj])}, start + endobj + len("endobj")
+	}
+
+	obj := &pdfObject{dict: string(rest[:streamIdx])}
+	s := streamIdx + len("stream")
+	if s < len(rest) && rest[s] == '\r' {
+		s++
+	}
+	if s < len(rest) && rest[s] == '\n' {
+		s++
+	}
+
+	end := -1
+	if m := pdfLengthPattern.FindStringSubmatch(obj.dict); m != nil && m[2] == "" {
+		n, _ := strconv.Atoi(m[1])
+		if s+n <= len(rest) && bytes.HasPrefix(bytes.TrimLeft(rest[s+n:], " \t\r\n"), []byte("endstream")) {
+			end = s + n
+		}
+	}
+	if end < 0 {
+		idx := bytes.Index(rest[s:], []byte("endstream"))
+		if idx < 0 {
+			return obj, len(data)
+		}
+		end = s + idx
+	}
+	obj.stream = rest[s:end]
+
+	after := bytes.Index(rest[end:], []byte("endobj"))
+	if after < 0 {
+		return obj, len(data)
+	}
+	return obj, start + end + after + len("endobj")
+}
+
+// unpackObjectStream adds the objects stored inside an /ObjStm stream
+func (f *pdfFile) unpackObjectStream(obj *pdfObject) {
+	decoded, err := f.decodeStream(obj)
+	if err != nil {
+		return
+	}
+	dict := parsePDFDict(obj.dict)
+	n, _ := strconv.Atoi(dict["/N"])
+	first, _ := strconv.Atoi(dict["/First"])
+	if first <= 0 || first > len(decoded) {
+		return
+	}
+
+	header := strings.Fields(string(decoded[:first]))
+	type entry struct{ num, offset int }
+	var entries []entry
+	for i := 0; i+1 < len(header) && len(entries) < n; i += 2 {
+		num, err1 := strconv.Atoi(header[i])
+		offset, err2 := strconv.Atoi(header[i+1])
+		if err1 != nil || err2 != nil {
+			return
+		}
+		entries = append(entries, entry{num, offset})
+	}
+
+	for i, e := range entries {
+		start := first + e.offset
+		end := len(decoded)
+		if i+1 < len(entries) {
+			end = first + entries[i+1].offset
+		}
+		if start < 0 || start > end || end > len(decoded) {
+			continue
+		}
+		if _, exists := f.objects[e.num]; !exists {
+			f.objects[e.num] = &pdfObject{dict: string(decoded[start:end])}
+		}
+	}
+}
+
+// decodeStream applies the stream's filters, failing with ErrPDFTooLarge once
+// the stream or the document decompresses to more than its limit
+func (f *pdfFile) decodeStream(obj *pdfObject) ([]byte, error) {
+	if f.tooLarge {
+		return nil, ErrPDFTooLarge
+	}
+	data := obj.stream
+	for _, filter := range pdfFilters(parsePDFDict(obj.dict)["/Filter"]) {
+		var err error
+		switch filter {
+		case "/FlateDecode", "/Fl":
+			limit := int64(maxPDFStreamSize)
+			if remaining := maxPDFInflatedSize - f.inflated; remaining < limit {
+				limit = remaining
+			}
+			data, err = inflatePDF(data, limit)
+			if errors.Is(err, ErrPDFTooLarge) {
+				f.tooLarge = true
+			}
+			f.inflated += int64(len(data))
+		case "/ASCIIHexDecode", "/AHx":
+			data, err = decodePDFHex(data)
+		case "/ASCII85Decode", "/A85":
+			data, err = decodePDFASCII85(data)
+		default:
+			return nil, fmt.Errorf("unsupported PDF stream filter %s", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// pdfFilters parses a /Filter value that is either a name or an array of names
+func pdfFilters(value string) []string {
+	value = strings.Trim(strings.TrimSpace(value), "[]")
+	var filters []string
+	for _, field := range strings.Fields(strings.ReplaceAll(value, "/", " /")) {
+		if strings.HasPrefix(field, "/") {
+			filters = append(filters, field)
+		}
+	}
+	return filters
+}
+
+// inflatePDF decompresses a Flate stream of at most limit bytes, keeping
+// whatever could be read from truncated data
+func inflatePDF(data []byte, limit int64) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(out)) > limit {
+		return nil, ErrPDFTooLarge
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodePDFHex(data []byte) ([]byte, error) {
+	var clean []byte
+	for _, b := range data {
+		if b == '>' {
+			break
+		}
+		if isHexDigit(b) {
+			clean = append(clean, b)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	out := make([]byte, len(clean)/2)
+	_, err := hex.Decode(out, clean)
+	return out, err
+}
+
+func decodePDFASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, 4*len(data))
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+// pages walks the page tree from the catalog, falling back to every /Page object
+func (f *pdfFile) pages() []pdfPage {
+	var pages []pdfPage
+	for _, obj := range f.objects {
+		dict := parsePDFDict(obj.dict)
+		if dict["/Type"] != "/Catalog" {
+			continue
+		}
+		if root, ok := pdfRef(dict["/Pages"]); ok {
+			f.walkPages(root, nil, &pages, map[int]bool{}, 0)
+		}
+		if len(pages) > 0 {
+			return pages
+		}
+	}
+
+	var nums []int
+	for num, obj := range f.objects {
+		if parsePDFDict(obj.dict)["/Type"] == "/Page" {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		dict := parsePDFDict(f.objects[num].dict)
+		pages = append(pages, pdfPage{dict: dict, resources: f.resolveDict(dict["/Resources"])})
+	}
+	return pages
+}
+
+// walkPages collects leaves of the page tree in document order
+func (f *pdfFile) walkPages(num int, inherited map[string]string, out *[]pdfPage, visited map[int]bool, depth int) {
+	obj, ok := f.objects[num]
+	if !ok || visited[num] || depth > maxPDFPageDepth {
+		return
+	}
+	visited[num] = true
+
+	dict := parsePDFDict(obj.dict)
+	resources := inherited
+	if value, ok := dict["/Resources"]; ok {
+		resources = f.resolveDict(value)
+	}
+
+	if kids, ok := dict["/Kids"]; ok && dict["/Type"] != "/Page" {
+		for _, kid := range pdfRefs(f.resolve(kids)) {
+			f.walkPages(kid, resources, out, visited, depth+1)
+		}
+		return
+	}
+	*out = append(*out, pdfPage{dict: dict, resources: resources})
+}
+
+// pageText decodes and interprets a page's content streams
+func (f *pdfFile) pageText(page pdfPage) string {
+	contents := page.dict["/Contents"]
+	var refs []int
+	if num, ok := pdfRef(contents); ok {
+		// A reference may point at a stream or at an array of streams
+		if obj := f.objects[num]; obj != nil && obj.stream == nil {
+			refs = pdfRefs(obj.dict)
+		} else {
+			refs = []int{num}
+		}
+	} else {
+		refs = pdfRefs(contents)
+	}
+
+	var content bytes.Buffer
+	for _, num := range refs {
+		obj, ok := f.objects[num]
+		if !ok || obj.stream == nil {
+			continue
+		}
+		decoded, err := f.decodeStream(obj)
+		if err != nil {
+			continue
+		}
+		content.Write(decoded)
+		content.WriteByte('\n')
+	}
+
+	return normalizeExtractedText(runPDFContent(content.Bytes(), f.pageFonts(page.resources)))
+}
+
+// pageFonts loads the fonts named in a page's resources
+func (f *pdfFile) pageFonts(resources map[string]string) map[string]*pdfFont {
+	fonts := map[string]*pdfFont{}
+	for name, value := range f.resolveDict(resources["/Font"]) {
+		if num, ok := pdfRef(value); ok {
+			fonts[name] = f.loadFont(num)
+		}
+	}
+	return fonts
+}
+
+// loadFont reads a font's encoding details and ToUnicode CMap
+func (f *pdfFile) loadFont(num int) *pdfFont {
+	if font, ok := f.fonts[num]; ok {
+		return font
+	}
+
+	font := &pdfFont{}
+	f.fonts[num] = font
+	obj, ok := f.objects[num]
+	if !ok {
+		return font
+	}
+
+	dict := parsePDFDict(obj.dict)
+	font.twoByte = dict["/Subtype"] == "/Type0"
+	if ref, ok := pdfRef(dict["/ToUnicode"]); ok {
+		if cmapObj, ok := f.objects[ref]; ok && cmapObj.stream != nil {
+			if data, err := f.decodeStream(cmapObj); err == nil {
+				font.cmap, font.codeBytes = parseToUnicodeCMap(data)
+			}
+		}
+	}
+	return font
+}
+
+// resolve follows a single indirect reference
+func (f *pdfFile) resolve(value string) string {
+	if num, ok := pdfRef(value); ok {
+		if obj, ok := f.objects[num]; ok {
+			return strings.TrimSpace(obj.dict)
+		}
+		return ""
+	}
+	return value
+}
+
+// resolveDict resolves a value and parses it as a dictionary
+func (f *pdfFile) resolveDict(value string) map[string]string {
+	return parsePDFDict(f.resolve(value))
+}
+
+// pdfRef parses a single "N G R" reference
+func pdfRef(value string) (int, bool) {
+	m := pdfSingleRef.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, false
+	}
+	num, err := strconv.Atoi(m[1])
+	return num, err == nil
+}
+
+// pdfRefs returns every reference in a value (typically an array)
+func pdfRefs(value string) []int {
+	var refs []int
+	for _, m := range pdfRefPattern.FindAllStringSubmatch(value, -1) {
+		if num, err := strconv.Atoi(m[1]); err == nil {
+			refs = append(refs, num)
+		}
+	}
+	return refs
+}
+
+// parsePDFDict parses the top-level entries of a "<< ... >>" dictionary into raw value strings
+func parsePDFDict(s string) map[string]string {
+	entries := map[string]string{}
+	start := strings.Index(s, "<<")
+	if start < 0 {
+		return entries
+	}
+
+	i := start + 2
+	for i < len(s) {
+		i = skipPDFSpace(s, i)
+		if i >= len(s) || strings.HasPrefix(s[i:], ">>") {
+			break
+		}
+		if s[i] != '/' {
+			i++
+			continue
+		}
+		key, j := readPDFNameAt(s, i)
+		j = skipPDFSpace(s, j)
+		value, k := readPDFValueAt(s, j)
+		entries[key] = value
+		if k <= i {
+			k = i + 1
+		}
+		i = k
+	}
+	return entries
+}
+
+// readPDFValueAt returns the raw text of the value starting at i and the offset after it
+func readPDFValueAt(s string, i int) (string, int) {
+	if i >= len(s) {
+		return "", i
+	}
+	switch {
+	case strings.HasPrefix(s[i:], "<<"):
+		end := matchPDFDelimited(s, i)
+		return s[i:end], end
+	case s[i] == '[':
+		end := matchPDFDelimited(s, i)
+		return s[i:end], end
+	case s[i] == '<':
+		end := strings.IndexByte(s[i:], '>')
+		if end < 0 {
+			return s[i:], len(s)
+		}
+		return s[i : i+end+1], i + end + 1
+	case s[i] == '(':
+		end := skipPDFLiteral(s, i)
+		return s[i:end], end
+	case s[i] == '/':
+		return readPDFNameAt(s, i)
+	}
+
+	// Number, boolean, null - or an "N G R" reference
+	end := i
+	for end < len(s) && isPDFRegular(s[end]) {
+		end++
+	}
+	if m := pdfRefPattern.FindStringIndex(s[i:]); m != nil && m[0] == 0 {
+		return s[i : i+m[1]], i + m[1]
+	}
+	return s[i:end], end
+}
+
+// matchPDFDelimited finds the end of a balanced << >> or [ ] group starting at i
+func matchPDFDelimited(s string, i int) int {
+	depth := 0
+	for i < len(s) {
+		switch {
+		case strings.HasPrefix(s[i:], "<<"):
+			depth++
+			i += 2
+			continue
+		case strings.HasPrefix(s[i:], ">>"):
+			depth--
+			i += 2
+		case s[i] == '[':
+			depth++
+			i++
+		case s[i] == ']':
+			depth--
+			i++
+		case s[i] == '(':
+			i = skipPDFLiteral(s, i)
+		default:
+			i++
+		}
+		if depth <= 0 {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// skipPDFLiteral skips a (...) literal string with nesting and escapes
+func skipPDFLiteral(s string, i int) int {
+	depth := 0
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return len(s)
+}
+
+func readPDFNameAt(s string, i int) (string, int) {
+	end := i + 1
+	for end < len(s) && isPDFRegular(s[end]) {
+		end++
+	}
+	return s[i:end], end
+}
+
+func skipPDFSpace(s string, i int) int {
+	for i < len(s) && isPDFSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+func isPDFSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\f' || b == 0
+}
+
+func isPDFRegular(b byte) bool {
+	if isPDFSpace(b) {
+		return false
+	}
+	switch b {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
+
+func isHexDigit(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+// pdfFont maps character codes of a font to Unicode
+type pdfFont struct {
+	twoByte   bool // Type0 (CID) font: codes are usually two bytes
+	cmap      map[uint32]string
+	codeBytes int // code length used by the ToUnicode CMap
+}
+
+// decode converts a shown string to Unicode text
+func (font *pdfFont) decode(b []byte) string {
+	if font == nil || (len(font.cmap) == 0 && !font.twoByte) {
+		return latin1String(b)
+	}
+	if len(font.cmap) == 0 {
+		// CID font without ToUnicode: glyph IDs cannot be mapped to text
+		return ""
+	}
+
+	width := font.codeBytes
+	if font.twoByte {
+		width = 2
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	var sb strings.Builder
+	for i := 0; i+width <= len(b); i += width {
+		var code uint32
+		for j := 0; j < width; j++ {
+			code = code<<8 | uint32(b[i+j])
+		}
+		if text, ok := font.cmap[code]; ok {
+			sb.WriteString(text)
+		} else if width == 1 {
+			sb.WriteRune(rune(b[i]))
+		}
+	}
+	return sb.String()
+}
+
+// latin1String maps single-byte codes directly to runes (close to WinAnsi/PDFDoc for text)
+func latin1String(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// parseToUnicodeCMap reads bfchar and bfrange mappings from a ToUnicode CMap
+func parseToUnicodeCMap(data []byte) (map[uint32]string, int) {
+	cmap := map[uint32]string{}
+	codeBytes := 0
+	s := string(data)
+
+	for _, block := range cmapBlocks(s, "beginbfchar", "endbfchar") {
+		tokens := cmapTokenPattern.FindAllString(block, -1)
+		for i := 0; i+1 < len(tokens); i += 2 {
+			src, width := cmapCode(tokens[i])
+			cmap[src] = cmapUnicode(tokens[i+1])
+			if width > codeBytes {
+				codeBytes = width
+			}
+		}
+	}
+
+	for _, block := range cmapBlocks(s, "beginbfrange", "endbfrange") {
+		tokens := cmapTokenPattern.FindAllString(block, -1)
+		for i := 0; i+2 < len(tokens); {
+			lo, width := cmapCode(tokens[i])
+			hi, _ := cmapCode(tokens[i+1])
+			if width > codeBytes {
+				codeBytes = width
+			}
+			if hi < lo || hi-lo > 0xFFFF {
+				hi = lo
+			}
+
+			if tokens[i+2] == "[" {
+				j := i + 3
+				for code := lo; j < len(tokens) && tokens[j] != "]"; j++ {
+					cmap[code] = cmapUnicode(tokens[j])
+					code++
+				}
+				i = j + 1
+				continue
+			}
+
+			base := []rune(cmapUnicode(tokens[i+2]))
+			for code := lo; code <= hi; code++ {
+				if len(base) == 0 {
+					break
+				}
+				out := append([]rune{}, base...)
+				out[len(out)-1] += rune(code - lo)
+				cmap[code] = string(out)
+			}
+			i += 3
+		}
+	}
+
+	return cmap, codeBytes
+}
+
+// cmapBlocks returns the text between each begin/end keyword pair
+func cmapBlocks(s, begin, end string) []string {
+	var blocks []string
+	for {
+		i := strings.Index(s, begin)
+		if i < 0 {
+			return blocks
+		}
+		s = s[i+len(begin):]
+		j := strings.Index(s, end)
+		if j < 0 {
+			return append(blocks, s)
+		}
+		blocks = append(blocks, s[:j])
+		s = s[j+len(end):]
+	}
+}
+
+// cmapCode parses a <hex> source code, returning its value and byte width
+func cmapCode(token string) (uint32, int) {
+	b, _ := decodePDFHex([]byte(strings.Trim(token, "<>")))
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code, len(b)
+}
+
+// cmapUnicode parses a <hex> UTF-16BE destination string
+func cmapUnicode(token string) string {
+	b, _ := decodePDFHex([]byte(strings.Trim(token, "<>")))
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pdfTokenKind classifies content stream tokens
+type pdfTokenKind int
+
+const (
+	pdfNumber pdfTokenKind = iota
+	pdfName
+	pdfString
+	pdfArray
+	pdfOther
+	pdfOperator
+)
+
+// pdfToken is a lexed content stream token
+type pdfToken struct {
+	kind  pdfTokenKind
+	text  string     // operator, name or number text
+	bytes []byte     // decoded string bytes
+	items []pdfToken // array elements
+}
+
+// pdfLexer tokenizes a content stream
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// runPDFContent interprets text-showing operators and returns the page text
+func runPDFContent(content []byte, fonts map[string]*pdfFont) string {
+	var sb strings.Builder
+	var operands []pdfToken
+	var font *pdfFont
+	lastY := ""
+	lex := &pdfLexer{data: content}
+
+	lastString := func() []byte {
+		for i := len(operands) - 1; i >= 0; i-- {
+			if operands[i].kind == pdfString {
+				return operands[i].bytes
+			}
+		}
+		return nil
+	}
+
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch tok.text {
+		case "Tf":
+			if len(operands) >= 2 && operands[len(operands)-2].kind == pdfName {
+				font = fonts[operands[len(operands)-2].text]
+			}
+		case "Tj":
+			sb.WriteString(font.decode(lastString()))
+		case "'", "\"":
+			sb.WriteString("\n")
+			sb.WriteString(font.decode(lastString()))
+		case "TJ":
+			if len(operands) > 0 && operands[len(operands)-1].kind == pdfArray {
+				for _, item := range operands[len(operands)-1].items {
+					switch item.kind {
+					case pdfString:
+						sb.WriteString(font.decode(item.bytes))
+					case pdfNumber:
+						// Large negative kerning is how many generators encode a word space
+						if n, err := strconv.ParseFloat(item.text, 64); err == nil && n < -180 {
+							sb.WriteString(" ")
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, err := strconv.ParseFloat(operands[len(operands)-1].text, 64); err == nil && ty != 0 {
+					sb.WriteString("\n")
+				} else {
+					sb.WriteString(" ")
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y := operands[len(operands)-1].text
+				if y != lastY {
+					sb.WriteString("\n")
+				} else {
+					sb.WriteString(" ")
+				}
+				lastY = y
+			}
+		case "T*":
+			sb.WriteString("\n")
+		case "ET":
+			sb.WriteString(" ")
+		case "BI":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+
+	return sb.String()
+}
+
+// next returns the next token
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, bytes: l.readLiteral()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			end := matchPDFDelimited(string(l.data[l.pos:]), 0)
+			l.pos += end
+			return pdfToken{kind: pdfOther}, true
+		case c == '<':
+			end := bytes.IndexByte(l.data[l.pos:], '>')
+			if end < 0 {
+				end = len(l.data) - l.pos - 1
+			}
+			decoded, _ := decodePDFHex(l.data[l.pos+1 : l.pos+end+1])
+			l.pos += end + 1
+			return pdfToken{kind: pdfString, bytes: decoded}, true
+		case c == '[':
+			l.pos++
+			var items []pdfToken
+			for {
+				l.skipSpace()
+				if l.pos >= len(l.data) {
+					break
+				}
+				if l.data[l.pos] == ']' {
+					l.pos++
+					break
+				}
+				item, ok := l.next()
+				if !ok {
+					break
+				}
+				items = append(items, item)
+			}
+			return pdfToken{kind: pdfArray, items: items}, true
+		case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+			l.pos++
+		case c == '/':
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.data) && isPDFRegular(l.data[l.pos]) {
+				l.pos++
+			}
+			return pdfToken{kind: pdfName, text: string(l.data[start:l.pos])}, true
+		default:
+			start := l.pos
+			for l.pos < len(l.data) && isPDFRegular(l.data[l.pos]) {
+				l.pos++
+			}
+			if l.pos == start {
+				l.pos++
+				continue
+			}
+			word := string(l.data[start:l.pos])
+			if word[0] == '+' || word[0] == '-' || word[0] == '.' || (word[0] >= '0' && word[0] <= '9') {
+				return pdfToken{kind: pdfNumber, text: word}, true
+			}
+			return pdfToken{kind: pdfOperator, text: word}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) && isPDFSpace(l.data[l.pos]) {
+		l.pos++
+	}
+}
+
+// readLiteral decodes a (...) string at the current position
+func (l *pdfLexer) readLiteral() []byte {
+	var out []byte
+	depth := 0
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				out = append(out, c)
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; k++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// skipInlineImage skips binary inline image data between ID and EI
+func (l *pdfLexer) skipInlineImage() {
+	id := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if id < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += id + 2
+	for l.pos < len(l.data) {
+		ei := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if ei < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		at := l.pos + ei
+		before := at == 0 || isPDFSpace(l.data[at-1])
+		after := at+2 >= len(l.data) || isPDFSpace(l.data[at+2])
+		l.pos = at + 2
+		if before && after {
+			return
+		}
+	}
+}
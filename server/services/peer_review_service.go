@@ -0,0 +1,490 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PeerReviewService handles student-to-student reviews of project assignments
+type PeerReviewService struct {
+	db                *database.DB
+	assignmentService *AssignmentService
+	rewardService     *RewardService
+}
+
+// NewPeerReviewService creates a new peer review service
+func NewPeerReviewService(db *database.DB, assignmentService *AssignmentService, rewardService *RewardService) *PeerReviewService {
+	return &PeerReviewService{
+		db:                db,
+		assignmentService: assignmentService,
+		rewardService:     rewardService,
+	}
+}
+
+// PeerReviewTask is a review as shown to the reviewing student
+type PeerReviewTask struct {
+	ID                string                   `json:"id"`
+	AssignmentID      string                   `json:"assignment_id"`
+	AssignmentTitle   string                   `json:"assignment_title"`
+	Rubric            []models.RubricCriterion `json:"rubric"`
+	SubmissionContent string                   `json:"submission_content"`
+	SubmissionFileURL string                   `json:"submission_file_url,omitempty"`
+	AuthorID          string                   `json:"author_id,omitempty"`   // Empty for anonymous reviews
+	AuthorName        string                   `json:"author_name,omitempty"` // Empty for anonymous reviews
+	Status            string                   `json:"status"`
+	Scores            []models.RubricScore     `json:"scores,omitempty"`
+	TotalScore        int                      `json:"total_score"`
+	Comment           string                   `json:"comment,omitempty"`
+	DueAt             time.Time                `json:"due_at"`
+	CompletedAt       *time.Time               `json:"completed_at,omitempty"`
+}
+
+// SubmissionPeerScore aggregates peer reviews of one submission
+type SubmissionPeerScore struct {
+	SubmissionID      string              `json:"submission_id"`
+	StudentID         string              `json:"student_id"`
+	StudentName       string              `json:"student_name"`
+	ReviewsAssigned   int                 `json:"reviews_assigned"`
+	ReviewsCompleted  int                 `json:"reviews_completed"`
+	AverageScore      float64             `json:"average_score"`      // Average total score across completed reviews
+	MaxScore          int                 `json:"max_score"`          // Sum of rubric max points
+	CriterionAverages map[string]float64  `json:"criterion_averages"` // Average points per rubric criterion
+	Reviews           []models.PeerReview `json:"reviews"`
+}
+
+// PeerReviewSummary is the teacher's view of an assignment's peer reviews
+type PeerReviewSummary struct {
+	AssignmentID string                   `json:"assignment_id"`
+	Config       *models.PeerReviewConfig `json:"config"`
+	Submissions  []SubmissionPeerScore    `json:"submissions"`
+}
+
+// StartScheduler periodically hands out review pairs for assignments whose due date has passed
+func (s *PeerReviewService) StartScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.AssignDueReviews(); err != nil {
+				log.Printf("Peer review scheduler: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// AssignDueReviews creates review pairs for every peer-reviewed assignment past its due date
+func (s *PeerReviewService) AssignDueReviews() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("assignments").Find(ctx, bson.M{
+		"peer_review.enabled":     true,
+		"peer_review.assigned_at": bson.M{"$exists": false},
+		"due_date":                bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var assignments []models.Assignment
+	if err := cursor.All(ctx, &assignments); err != nil {
+		return err
+	}
+
+	for i := range assignments {
+		if err := s.assignReviews(&assignments[i]); err != nil {
+			log.Printf("Failed to assign peer reviews for assignment %s: %v", assignments[i].ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// assignReviews claims an assignment and inserts its review pairs. If the
+// pairs can't be stored the claim is released so the next run tries again.
+func (s *PeerReviewService) assignReviews(assignment *models.Assignment) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Claim the assignment first so concurrent schedulers never assign twice
+	now := time.Now()
+	claim, err := s.db.Collection("assignments").UpdateOne(ctx,
+		bson.M{"_id": assignment.ID, "peer_review.assigned_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"peer_review.assigned_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if claim.ModifiedCount == 0 {
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			s.releaseClaim(assignment.ID, now)
+		}
+	}()
+
+	submissions, err := s.assignmentService.GetSubmissions(assignment.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewSource(now.UnixNano()))
+	pairs := assignReviewPairs(len(submissions), assignment.PeerReview.ReviewerCount, rng)
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	reviews := make([]interface{}, 0, len(pairs))
+	for _, pair := range pairs {
+		reviews = append(reviews, models.PeerReview{
+			AssignmentID: assignment.ID,
+			SubmissionID: submissions[pair.submission].ID,
+			ReviewerID:   submissions[pair.reviewer].StudentID,
+			AuthorID:     submissions[pair.submission].StudentID,
+			Status:       "pending",
+			DueAt:        assignment.PeerReview.ReviewDeadline,
+			CreatedAt:    now,
+		})
+	}
+
+	_, err = s.db.Collection("peer_reviews").InsertMany(ctx, reviews)
+	return err
+}
+
+// releaseClaim undoes a failed assignment of review pairs: it removes any pairs
+// stored by the claim made at claimedAt and lets the scheduler claim the
+// assignment again
+func (s *PeerReviewService) releaseClaim(assignmentID primitive.ObjectID, claimedAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := s.db.Collection("peer_reviews").DeleteMany(ctx,
+		bson.M{"assignment_id": assignmentID, "created_at": claimedAt}); err != nil {
+		log.Printf("Failed to remove partial peer reviews for assignment %s: %v", assignmentID.Hex(), err)
+		return
+	}
+	if _, err := s.db.Collection("assignments").UpdateOne(ctx,
+		bson.M{"_id": assignmentID, "peer_review.assigned_at": claimedAt},
+		bson.M{"$unset": bson.M{"peer_review.assigned_at": ""}},
+	); err != nil {
+		log.Printf("Failed to release peer review claim for assignment %s: %v", assignmentID.Hex(), err)
+	}
+}
+
+// reviewPair says which submitter (by index) reviews which submission (by index)
+type reviewPair struct {
+	reviewer   int
+	submission int
+}
+
+// assignReviewPairs arranges submitters in a random circle; each submission is reviewed by the
+// next k submitters. Nobody reviews their own work and everyone gets exactly k reviews to do.
+func assignReviewPairs(n, k int, rng *rand.Rand) []reviewPair {
+	if n < 2 || k < 1 {
+		return nil
+	}
+	if k > n-1 {
+		k = n - 1
+	}
+
+	order := rng.Perm(n)
+	pairs := make([]reviewPair, 0, n*k)
+	for pos, submission := range order {
+		for d := 1; d <= k; d++ {
+			pairs = append(pairs, reviewPair{reviewer: order[(pos+d)%n], submission: submission})
+		}
+	}
+	return pairs
+}
+
+// GetMyReviews returns the reviews assigned to a student
+func (s *PeerReviewService) GetMyReviews(reviewerID string) ([]PeerReviewTask, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reviewerOID, err := primitive.ObjectIDFromHex(reviewerID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	cursor, err := s.db.Collection("peer_reviews").Find(ctx,
+		bson.M{"reviewer_id": reviewerOID},
+		options.Find().SetSort(bson.D{{Key: "due_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reviews []models.PeerReview
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+
+	assignments := map[primitive.ObjectID]*models.Assignment{}
+	tasks := make([]PeerReviewTask, 0, len(reviews))
+	for _, review := range reviews {
+		assignment, ok := assignments[review.AssignmentID]
+		if !ok {
+			assignment, err = s.assignmentService.GetAssignment(review.AssignmentID.Hex())
+			if err != nil || assignment.PeerReview == nil {
+				continue
+			}
+			assignments[review.AssignmentID] = assignment
+		}
+
+		var submission models.Submission
+		if err := s.db.Collection("submissions").FindOne(ctx, bson.M{"_id": review.SubmissionID}).Decode(&submission); err != nil {
+			continue
+		}
+
+		task := PeerReviewTask{
+			ID:                review.ID.Hex(),
+			AssignmentID:      review.AssignmentID.Hex(),
+			AssignmentTitle:   assignment.Title,
+			Rubric:            assignment.PeerReview.Rubric,
+			SubmissionContent: submission.Content,
+			SubmissionFileURL: submission.FileURL,
+			Status:            review.Status,
+			Scores:            review.Scores,
+			TotalScore:        review.TotalScore,
+			Comment:           review.Comment,
+			DueAt:             review.DueAt,
+			CompletedAt:       review.CompletedAt,
+		}
+		if !assignment.PeerReview.Anonymous {
+			task.AuthorID = review.AuthorID.Hex()
+			task.AuthorName = s.userName(ctx, review.AuthorID)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// SubmitReview records a reviewer's rubric scores and awards XP
+func (s *PeerReviewService) SubmitReview(reviewID, reviewerID string, scores []models.RubricScore, comment string) (*models.PeerReview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reviewOID, err := primitive.ObjectIDFromHex(reviewID)
+	if err != nil {
+		return nil, errors.New("invalid review ID")
+	}
+
+	reviewerOID, err := primitive.ObjectIDFromHex(reviewerID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	collection := s.db.Collection("peer_reviews")
+	var review models.PeerReview
+	err = collection.FindOne(ctx, bson.M{"_id": reviewOID, "reviewer_id": reviewerOID}).Decode(&review)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("review not found")
+		}
+		return nil, err
+	}
+
+	if review.Status == "completed" {
+		return nil, errors.New("review already submitted")
+	}
+	if time.Now().After(review.DueAt) {
+		return nil, errors.New("the review deadline has passed")
+	}
+
+	assignment, err := s.assignmentService.GetAssignment(review.AssignmentID.Hex())
+	if err != nil || assignment.PeerReview == nil {
+		return nil, errors.New("assignment not found")
+	}
+
+	normalized, total, err := scoreReview(assignment.PeerReview.Rubric, scores)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": reviewOID, "status": "pending"},
+		bson.M{"$set": bson.M{
+			"status":       "completed",
+			"scores":       normalized,
+			"total_score":  total,
+			"comment":      comment,
+			"completed_at": now,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, errors.New("review already submitted")
+	}
+
+	review.Status = "completed"
+	review.Scores = normalized
+	review.TotalScore = total
+	review.Comment = comment
+	review.CompletedAt = &now
+
+	if s.rewardService != nil {
+		_, _ = s.rewardService.ApplyEvent(reviewerID, EventPeerReviewCompleted, map[string]interface{}{
+			"assignment_id": review.AssignmentID.Hex(),
+			"review_id":     reviewID,
+		})
+	}
+
+	return &review, nil
+}
+
+// scoreReview validates scores against the rubric and returns them in rubric order with the total
+func scoreReview(rubric []models.RubricCriterion, scores []models.RubricScore) ([]models.RubricScore, int, error) {
+	given := make(map[string]int, len(scores))
+	for _, score := range scores {
+		given[score.Criterion] = score.Points
+	}
+
+	normalized := make([]models.RubricScore, 0, len(rubric))
+	total := 0
+	for _, criterion := range rubric {
+		points, ok := given[criterion.Title]
+		if !ok {
+			return nil, 0, fmt.Errorf("missing score for criterion %q", criterion.Title)
+		}
+		if points < 0 || points > criterion.MaxPoints {
+			return nil, 0, fmt.Errorf("score for %q must be between 0 and %d", criterion.Title, criterion.MaxPoints)
+		}
+		normalized = append(normalized, models.RubricScore{Criterion: criterion.Title, Points: points})
+		total += points
+	}
+
+	if len(given) != len(rubric) {
+		return nil, 0, errors.New("scores include criteria that are not in the rubric")
+	}
+
+	return normalized, total, nil
+}
+
+// GetPeerReviewSummary returns aggregated peer scores per submission (assignment owner only)
+func (s *PeerReviewService) GetPeerReviewSummary(assignmentID, teacherID string) (*PeerReviewSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assignment, err := s.assignmentService.GetAssignment(assignmentID)
+	if err != nil {
+		return nil, errors.New("assignment not found")
+	}
+	if assignment.TeacherID.Hex() != teacherID {
+		return nil, errors.New("only the assignment owner can view peer reviews")
+	}
+	if assignment.PeerReview == nil || !assignment.PeerReview.Enabled {
+		return nil, errors.New("peer review is not enabled for this assignment")
+	}
+
+	submissions, err := s.assignmentService.GetSubmissions(assignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.db.Collection("peer_reviews").Find(ctx, bson.M{"assignment_id": assignment.ID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reviews []models.PeerReview
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+
+	scores := aggregatePeerScores(assignment.PeerReview.Rubric, submissions, reviews)
+	for i := range scores {
+		studentOID, _ := primitive.ObjectIDFromHex(scores[i].StudentID)
+		scores[i].StudentName = s.userName(ctx, studentOID)
+	}
+
+	return &PeerReviewSummary{
+		AssignmentID: assignmentID,
+		Config:       assignment.PeerReview,
+		Submissions:  scores,
+	}, nil
+}
+
+// aggregatePeerScores groups reviews by submission and averages completed ones
+func aggregatePeerScores(rubric []models.RubricCriterion, submissions []models.Submission, reviews []models.PeerReview) []SubmissionPeerScore {
+	maxScore := 0
+	for _, criterion := range rubric {
+		maxScore += criterion.MaxPoints
+	}
+
+	bySubmission := make(map[primitive.ObjectID][]models.PeerReview)
+	for _, review := range reviews {
+		bySubmission[review.SubmissionID] = append(bySubmission[review.SubmissionID], review)
+	}
+
+	results := make([]SubmissionPeerScore, 0, len(submissions))
+	for _, submission := range submissions {
+		subReviews := bySubmission[submission.ID]
+		entry := SubmissionPeerScore{
+			SubmissionID:      submission.ID.Hex(),
+			StudentID:         submission.StudentID.Hex(),
+			ReviewsAssigned:   len(subReviews),
+			MaxScore:          maxScore,
+			CriterionAverages: map[string]float64{},
+			Reviews:           subReviews,
+		}
+		if entry.Reviews == nil {
+			entry.Reviews = []models.PeerReview{}
+		}
+
+		total := 0
+		criterionTotals := map[string]int{}
+		for _, review := range subReviews {
+			if review.Status != "completed" {
+				continue
+			}
+			entry.ReviewsCompleted++
+			total += review.TotalScore
+			for _, score := range review.Scores {
+				criterionTotals[score.Criterion] += score.Points
+			}
+		}
+
+		if entry.ReviewsCompleted > 0 {
+			entry.AverageScore = float64(total) / float64(entry.ReviewsCompleted)
+			for criterion, points := range criterionTotals {
+				entry.CriterionAverages[criterion] = float64(points) / float64(entry.ReviewsCompleted)
+			}
+		}
+
+		results = append(results, entry)
+	}
+
+	return results
+}
+
+// userName looks up a user's display name (empty if unknown)
+func (s *PeerReviewService) userName(ctx context.Context, userID primitive.ObjectID) string {
+	var user models.User
+	if err := s.db.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return ""
+	}
+	return user.Name
+}
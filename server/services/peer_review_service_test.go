@@ -0,0 +1,173 @@
+package services
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAssignReviewPairsBalanced(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n, k := 6, 2
+
+	pairs := assignReviewPairs(n, k, rng)
+	if len(pairs) != n*k {
+		t.Fatalf("Expected %d pairs, got %d", n*k, len(pairs))
+	}
+
+	reviewsGiven := make(map[int]int)
+	reviewsReceived := make(map[int]int)
+	seen := make(map[reviewPair]bool)
+	for _, pair := range pairs {
+		if pair.reviewer == pair.submission {
+			t.Errorf("Student %d assigned to review their own submission", pair.reviewer)
+		}
+		if seen[pair] {
+			t.Errorf("Duplicate pair %+v", pair)
+		}
+		seen[pair] = true
+		reviewsGiven[pair.reviewer]++
+		reviewsReceived[pair.submission]++
+	}
+
+	for i := 0; i < n; i++ {
+		if reviewsGiven[i] != k || reviewsReceived[i] != k {
+			t.Errorf("Student %d gives %d and receives %d reviews, expected %d each", i, reviewsGiven[i], reviewsReceived[i], k)
+		}
+	}
+}
+
+func TestAssignReviewPairsSmallClass(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	if pairs := assignReviewPairs(1, 3, rng); len(pairs) != 0 {
+		t.Errorf("Expected no pairs for a single submission, got %d", len(pairs))
+	}
+
+	// Reviewer count is capped at n-1
+	if pairs := assignReviewPairs(3, 5, rng); len(pairs) != 6 {
+		t.Errorf("Expected 6 pairs for 3 submissions, got %d", len(pairs))
+	}
+}
+
+func TestScoreReview(t *testing.T) {
+	rubric := []models.RubricCriterion{
+		{Title: "Clarity", MaxPoints: 5},
+		{Title: "Accuracy", MaxPoints: 10},
+	}
+
+	scores, total, err := scoreReview(rubric, []models.RubricScore{
+		{Criterion: "Accuracy", Points: 7},
+		{Criterion: "Clarity", Points: 4},
+	})
+	if err != nil {
+		t.Fatalf("scoreReview failed: %v", err)
+	}
+	if total != 11 {
+		t.Errorf("Expected total 11, got %d", total)
+	}
+	if scores[0].Criterion != "Clarity" {
+		t.Errorf("Expected scores in rubric order, got %+v", scores)
+	}
+
+	if _, _, err := scoreReview(rubric, []models.RubricScore{{Criterion: "Clarity", Points: 4}}); err == nil {
+		t.Error("Expected error for missing criterion")
+	}
+	if _, _, err := scoreReview(rubric, []models.RubricScore{
+		{Criterion: "Clarity", Points: 6},
+		{Criterion: "Accuracy", Points: 7},
+	}); err == nil {
+		t.Error("Expected error for score above max points")
+	}
+	if _, _, err := scoreReview(rubric, []models.RubricScore{
+		{Criterion: "Clarity", Points: 1},
+		{Criterion: "Accuracy", Points: 1},
+		{Criterion: "Style", Points: 1},
+	}); err == nil {
+		t.Error("Expected error for unknown criterion")
+	}
+}
+
+func TestAggregatePeerScores(t *testing.T) {
+	rubric := []models.RubricCriterion{{Title: "Quality", MaxPoints: 10}}
+	submission := models.Submission{ID: primitive.NewObjectID(), StudentID: primitive.NewObjectID()}
+	reviews := []models.PeerReview{
+		{SubmissionID: submission.ID, Status: "completed", TotalScore: 8, Scores: []models.RubricScore{{Criterion: "Quality", Points: 8}}},
+		{SubmissionID: submission.ID, Status: "completed", TotalScore: 6, Scores: []models.RubricScore{{Criterion: "Quality", Points: 6}}},
+		{SubmissionID: submission.ID, Status: "pending"},
+	}
+
+	results := aggregatePeerScores(rubric, []models.Submission{submission}, reviews)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	result := results[0]
+	if result.ReviewsAssigned != 3 || result.ReviewsCompleted != 2 {
+		t.Errorf("Expected 3 assigned / 2 completed, got %d / %d", result.ReviewsAssigned, result.ReviewsCompleted)
+	}
+	if result.AverageScore != 7 {
+		t.Errorf("Expected average 7, got %f", result.AverageScore)
+	}
+	if result.MaxScore != 10 {
+		t.Errorf("Expected max score 10, got %d", result.MaxScore)
+	}
+	if result.CriterionAverages["Quality"] != 7 {
+		t.Errorf("Expected Quality average 7, got %f", result.CriterionAverages["Quality"])
+	}
+}
+
+func TestValidatePeerReviewConfig(t *testing.T) {
+	due := time.Now()
+	valid := &models.PeerReviewConfig{
+		Enabled:        true,
+		ReviewerCount:  2,
+		Rubric:         []models.RubricCriterion{{Title: "Quality", MaxPoints: 10}},
+		ReviewDeadline: due.Add(72 * time.Hour),
+	}
+
+	if err := validatePeerReviewConfig(valid, "project", due); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+	if err := validatePeerReviewConfig(valid, "homework", due); err == nil {
+		t.Error("Expected error for non-project assignment")
+	}
+	if err := validatePeerReviewConfig(nil, "homework", due); err != nil {
+		t.Errorf("Expected nil config to be allowed, got %v", err)
+	}
+
+	early := *valid
+	early.ReviewDeadline = due.Add(-time.Hour)
+	if err := validatePeerReviewConfig(&early, "project", due); err == nil {
+		t.Error("Expected error for deadline before due date")
+	}
+}
+
+func TestPeerReviewDeadlineOnly(t *testing.T) {
+	current := &models.PeerReviewConfig{
+		Enabled:        true,
+		ReviewerCount:  2,
+		Rubric:         []models.RubricCriterion{{Title: "Quality", MaxPoints: 10}},
+		ReviewDeadline: time.Now(),
+	}
+
+	later := *current
+	later.ReviewDeadline = current.ReviewDeadline.Add(24 * time.Hour)
+	if !peerReviewDeadlineOnly(current, &later) {
+		t.Error("Expected a new review deadline to be allowed")
+	}
+
+	rubric := *current
+	rubric.Rubric = []models.RubricCriterion{{Title: "Quality", MaxPoints: 5}}
+	count := *current
+	count.ReviewerCount = 3
+	for name, next := range map[string]*models.PeerReviewConfig{"rubric": &rubric, "reviewer count": &count, "removal": nil} {
+		if peerReviewDeadlineOnly(current, next) {
+			t.Errorf("Expected a %s change to be rejected", name)
+		}
+	}
+}
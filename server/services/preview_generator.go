@@ -0,0 +1,781 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Previews are generated without external tools: thumbnails of images (and of
+// pictures embedded in PDFs and office documents), page and slide counts, and
+// durations read from audio/video container headers. PDF pages are not
+// rendered; a page that is only text is previewed by its opening text.
+
+const (
+	// thumbnailSize bounds the longer side of generated thumbnails
+	thumbnailSize = 320
+	// maxPreviewImagePixels rejects images that would take too much memory to decode
+	maxPreviewImagePixels = 40_000_000
+	// maxPreviewDocumentSize bounds documents that have to be read into memory
+	maxPreviewDocumentSize = 100 << 20
+	// previewTextLength is how much opening text is kept for text-only previews
+	previewTextLength = 280
+	// minEmbeddedImageSide skips logos and bullets when looking for a page picture
+	minEmbeddedImageSide = 64
+)
+
+// ErrNoPreview is returned for file types nothing can be derived from
+var ErrNoPreview = errors.New("no preview available for this file type")
+
+// PreviewResult is what could be derived from a file for display
+type PreviewResult struct {
+	Thumbnail []byte // JPEG, nil when the file has no picture to show
+	Width     int    // Image or first page size (pixels or PDF points)
+	Height    int
+	PageCount int     // Pages or slides
+	Duration  float64 // Seconds, for audio and video
+	Text      string  // Opening text when there is no picture
+}
+
+// GeneratePreview derives a thumbnail and metadata from a file
+func GeneratePreview(r io.ReadSeeker, size int64, mimeType, ext string) (*PreviewResult, error) {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+
+	switch {
+	case mimeType == "image/jpeg" || mimeType == "image/png" || mimeType == "image/gif":
+		return imagePreview(r)
+	case mimeType == "video/mp4" || mimeType == "video/quicktime" || mimeType == "audio/mp4" || mimeType == "audio/x-m4a":
+		return durationPreview(mp4Duration(r, size))
+	case mimeType == "audio/mpeg":
+		return durationPreview(mp3Duration(r, size))
+	case mimeType == "audio/wav" || mimeType == "audio/x-wav":
+		return durationPreview(wavDuration(r))
+	case mimeType == "audio/flac":
+		return durationPreview(flacDuration(r))
+	case mimeType == "audio/ogg":
+		return durationPreview(oggDuration(r, size))
+	case mimeType == "video/webm" || mimeType == "audio/webm" || mimeType == "video/x-matroska":
+		return durationPreview(matroskaDuration(r))
+	}
+
+	kind := detectDocumentKind(mimeType, ext)
+	isOffice := kind == "docx" || kind == "pptx" || strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument.")
+	if kind != "pdf" && kind != "text" && kind != "markdown" && !isOffice {
+		return nil, ErrNoPreview
+	}
+	if size > maxPreviewDocumentSize {
+		return nil, fmt.Errorf("file is too large to preview (%d MB)", size>>20)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxPreviewDocumentSize))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case kind == "pdf":
+		return pdfPreview(data)
+	case isOffice:
+		return officePreview(data)
+	default:
+		return &PreviewResult{Text: previewText(string(data))}, nil
+	}
+}
+
+func durationPreview(seconds float64, err error) (*PreviewResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &PreviewResult{Duration: math.Round(seconds*100) / 100}, nil
+}
+
+// previewText keeps the opening words of a text, cut at a word boundary
+func previewText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= previewTextLength {
+		return text
+	}
+	cut := strings.LastIndex(text[:previewTextLength], " ")
+	if cut < previewTextLength/2 {
+		cut = previewTextLength
+	}
+	return strings.ToValidUTF8(text[:cut], "") + "…"
+}
+
+// Images
+
+func imagePreview(r io.ReadSeeker) (*PreviewResult, error) {
+	img, err := decodeImageLimited(r)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := encodeThumbnail(img)
+	if err != nil {
+		return nil, err
+	}
+	return &PreviewResult{Thumbnail: thumb, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}, nil
+}
+
+// decodeImageLimited decodes a JPEG, PNG or GIF after checking its dimensions
+func decodeImageLimited(r io.ReadSeeker) (image.Image, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPreviewImagePixels {
+		return nil, fmt.Errorf("image is too large to preview (%dx%d)", cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch format {
+	case "jpeg":
+		return jpeg.Decode(r)
+	case "png":
+		return png.Decode(r)
+	case "gif":
+		return gif.Decode(r) // First frame
+	default:
+		return nil, ErrNoPreview
+	}
+}
+
+// encodeThumbnail scales an image to fit thumbnailSize and encodes it as JPEG
+func encodeThumbnail(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleToFit(img, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleToFit shrinks an image so its longer side is at most maxSide, averaging
+// the source pixels under each output pixel (sampled on large images).
+// Transparent areas are flattened onto white.
+func scaleToFit(src image.Image, maxSide int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scale := math.Min(1, math.Min(float64(maxSide)/float64(w), float64(maxSide)/float64(h)))
+	dw := int(math.Max(1, math.Round(float64(w)*scale)))
+	dh := int(math.Max(1, math.Round(float64(h)*scale)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		stepY := max(1, (sy1-sy0)/4)
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			stepX := max(1, (sx1-sx0)/4)
+
+			var r, g, bl, n uint64
+			for sy := sy0; sy < sy1; sy += stepY {
+				for sx := sx0; sx < sx1; sx += stepX {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					// Premultiplied: add the uncovered part as white
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+// PDF
+
+func pdfPreview(data []byte) (*PreviewResult, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+
+	f := parsePDF(data)
+	pages := f.pages()
+	if len(pages) == 0 {
+		return nil, errors.New("no pages found in PDF")
+	}
+
+	result := &PreviewResult{PageCount: len(pages)}
+	first := pages[0]
+	if box := pdfNumbers(f.resolve(first.dict["/MediaBox"])); len(box) == 4 {
+		result.Width = int(math.Abs(box[2] - box[0]))
+		result.Height = int(math.Abs(box[3] - box[1]))
+	}
+
+	// Scanned pages and title slides are usually one large picture
+	if img := f.pageImage(first); img != nil {
+		if thumb, err := encodeThumbnail(img); err == nil {
+			result.Thumbnail = thumb
+		}
+	}
+	if result.Thumbnail == nil {
+		result.Text = previewText(f.pageText(first))
+	}
+	if f.tooLarge {
+		return nil, ErrPDFTooLarge
+	}
+	return result, nil
+}
+
+// pageImage decodes the largest picture drawn directly on a page
+func (f *pdfFile) pageImage(page pdfPage) image.Image {
+	var best image.Image
+	bestArea := 0
+	for _, value := range f.resolveDict(page.resources["/XObject"]) {
+		num, ok := pdfRef(value)
+		if !ok {
+			continue
+		}
+		obj, ok := f.objects[num]
+		if !ok || obj.stream == nil {
+			continue
+		}
+		dict := parsePDFDict(obj.dict)
+		if dict["/Subtype"] != "/Image" {
+			continue
+		}
+		width, _ := strconv.Atoi(f.resolve(dict["/Width"]))
+		height, _ := strconv.Atoi(f.resolve(dict["/Height"]))
+		if width < minEmbeddedImageSide || height < minEmbeddedImageSide || width*height <= bestArea ||
+			width*height > maxPreviewImagePixels {
+			continue
+		}
+		if img := f.decodePDFImage(obj, dict, width, height); img != nil {
+			best, bestArea = img, width*height
+		}
+	}
+	return best
+}
+
+// decodePDFImage decodes JPEG images and 8-bit RGB or grayscale raster images
+func (f *pdfFile) decodePDFImage(obj *pdfObject, dict map[string]string, width, height int) image.Image {
+	filters := pdfFilters(dict["/Filter"])
+	if n := len(filters); n > 0 && (filters[n-1] == "/DCTDecode" || filters[n-1] == "/DCT") {
+		data, err := f.decodeStream(&pdfObject{
+			dict:   "<< /Filter [" + strings.Join(filters[:n-1], " ") + "] >>",
+			stream: obj.stream,
+		})
+		if err != nil {
+			return nil
+		}
+		// The JPEG's own size is what gets decoded, whatever /Width and /Height say
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPreviewImagePixels {
+			return nil
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		return img
+	}
+
+	if bpc := f.resolve(dict["/BitsPerComponent"]); bpc != "8" || dict["/DecodeParms"] != "" {
+		return nil
+	}
+	components := f.pdfColorComponents(dict["/ColorSpace"])
+	if components != 1 && components != 3 {
+		return nil
+	}
+	data, err := f.decodeStream(obj)
+	if err != nil || len(data) < width*height*components {
+		return nil
+	}
+	if components == 1 {
+		return &image.Gray{Pix: data[:width*height], Stride: width, Rect: image.Rect(0, 0, width, height)}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = data[i*3], data[i*3+1], data[i*3+2], 0xff
+	}
+	return img
+}
+
+// pdfColorComponents returns the number of components of a device or ICC color space
+func (f *pdfFile) pdfColorComponents(value string) int {
+	value = f.resolve(value)
+	switch {
+	case value == "/DeviceGray" || value == "/G":
+		return 1
+	case value == "/DeviceRGB" || value == "/RGB":
+		return 3
+	case strings.Contains(value, "/ICCBased"):
+		if num, ok := pdfRef(strings.TrimSpace(strings.Trim(strings.TrimSpace(value), "[]")[len("/ICCBased"):])); ok {
+			if obj, ok := f.objects[num]; ok {
+				n, _ := strconv.Atoi(parsePDFDict(obj.dict)["/N"])
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+var pdfNumberPattern = regexp.MustCompile(`-?\d*\.?\d+`)
+
+// pdfNumbers parses the numbers of an array such as a /MediaBox
+func pdfNumbers(value string) []float64 {
+	var numbers []float64
+	for _, m := range pdfNumberPattern.FindAllString(value, -1) {
+		if n, err := strconv.ParseFloat(m, 64); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// Office documents (OOXML and OpenDocument)
+
+var pptxSlidePattern = regexp.MustCompile(`^ppt/slides/slide\d+\.xml$`)
+
+// officePreview reads the thumbnail office suites embed when saving, and the
+// page or slide count from the document properties
+func officePreview(data []byte) (*PreviewResult, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PreviewResult{}
+	slides := 0
+	for _, file := range zr.File {
+		switch name := file.Name; {
+		case name == "docProps/thumbnail.jpeg" || name == "docProps/thumbnail.jpg" ||
+			name == "docProps/thumbnail.png" || name == "Thumbnails/thumbnail.png":
+			if file.UncompressedSize64 > maxPreviewDocumentSize {
+				continue
+			}
+			raw, err := readZipFile(file)
+			if err != nil {
+				continue
+			}
+			if img, err := decodeImageLimited(bytes.NewReader(raw)); err == nil {
+				result.Thumbnail, _ = encodeThumbnail(img)
+			}
+		case pptxSlidePattern.MatchString(name):
+			slides++
+		case name == "docProps/app.xml":
+			if raw, err := readZipFile(file); err == nil {
+				var props struct {
+					Pages  int `xml:"Pages"`
+					Slides int `xml:"Slides"`
+				}
+				if xml.Unmarshal(raw, &props) == nil {
+					result.PageCount = max(props.Pages, props.Slides)
+				}
+			}
+		case name == "meta.xml":
+			if raw, err := readZipFile(file); err == nil {
+				result.PageCount = max(result.PageCount, odfPageCount(raw))
+			}
+		}
+	}
+	if slides > 0 {
+		result.PageCount = slides // Counted from the package, so always current
+	}
+	if result.Thumbnail == nil && result.PageCount == 0 {
+		return nil, ErrNoPreview
+	}
+	return result, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxPreviewDocumentSize))
+}
+
+var odfPageCountPattern = regexp.MustCompile(`meta:(?:page|object)-count="(\d+)"`)
+
+// odfPageCount reads the page count from OpenDocument statistics
+func odfPageCount(meta []byte) int {
+	if m := odfPageCountPattern.FindSubmatch(meta); m != nil && bytes.Contains(m[0], []byte("page-count")) {
+		n, _ := strconv.Atoi(string(m[1]))
+		return n
+	}
+	return 0
+}
+
+// Audio and video durations
+
+// mp4Duration reads the movie header (moov/mvhd) of MP4, M4A and QuickTime files
+func mp4Duration(r io.ReadSeeker, size int64) (float64, error) {
+	moov, moovSize, err := findMP4Box(r, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findMP4Box(r, moov, moov+moovSize, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := r.Seek(mvhd, io.SeekStart); err != nil {
+		return 0, err
+	}
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	var timescale uint32
+	var duration uint64
+	if header[0] == 1 { // Version 1: 64-bit times
+		timescale = binary.BigEndian.Uint32(header[20:24])
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		timescale = binary.BigEndian.Uint32(header[12:16])
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+	if timescale == 0 {
+		return 0, errors.New("invalid MP4 timescale")
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// findMP4Box finds a box between start and end and returns its payload offset and size
+func findMP4Box(r io.ReadSeeker, start, end int64, boxType string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return 0, 0, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0: // Extends to the end
+			boxSize = end - offset
+		case 1: // 64-bit size follows
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return 0, 0, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize {
+			return 0, 0, errors.New("malformed MP4 box")
+		}
+		if string(header[4:8]) == boxType {
+			return offset + headerSize, boxSize - headerSize, nil
+		}
+		offset += boxSize
+	}
+	return 0, 0, fmt.Errorf("no %s box found", boxType)
+}
+
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3Rates      = [3]int{44100, 48000, 32000}
+)
+
+// mp3Duration reads the Xing/Info or VBRI header of variable bitrate files and
+// estimates constant bitrate files from the first frame's bitrate
+func mp3Duration(r io.ReadSeeker, size int64) (float64, error) {
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, err
+	}
+	audioStart := int64(0)
+	if string(head[:3]) == "ID3" {
+		// Tag size is a 28-bit "syncsafe" integer
+		audioStart = 10 + (int64(head[6])<<21 | int64(head[7])<<14 | int64(head[8])<<7 | int64(head[9]))
+	}
+
+	if _, err := r.Seek(audioStart, io.SeekStart); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 64*1024)
+	n, _ := io.ReadFull(r, buf)
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		version := (buf[i+1] >> 3) & 0x03 // 3 = MPEG1, 2 = MPEG2, 0 = MPEG2.5
+		layer := (buf[i+1] >> 1) & 0x03   // 1 = Layer III
+		bitrateIndex := buf[i+2] >> 4
+		rateIndex := (buf[i+2] >> 2) & 0x03
+		if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+		mono := buf[i+3]>>6 == 3
+
+		sampleRate := mp3Rates[rateIndex]
+		bitrate := mp3BitratesV1[bitrateIndex]
+		samplesPerFrame := 1152
+		sideInfo := 32
+		if mono {
+			sideInfo = 17
+		}
+		if version != 3 {
+			sampleRate /= 2
+			if version == 0 {
+				sampleRate /= 2
+			}
+			bitrate = mp3BitratesV2[bitrateIndex]
+			samplesPerFrame = 576
+			sideInfo = 17
+			if mono {
+				sideInfo = 9
+			}
+		}
+
+		// Xing/Info header in the first frame
+		if x := i + 4 + sideInfo; x+12 <= len(buf) {
+			if tag := string(buf[x : x+4]); tag == "Xing" || tag == "Info" {
+				if flags := binary.BigEndian.Uint32(buf[x+4 : x+8]); flags&1 != 0 {
+					frames := binary.BigEndian.Uint32(buf[x+8 : x+12])
+					return float64(frames) * float64(samplesPerFrame) / float64(sampleRate), nil
+				}
+			}
+		}
+		// VBRI header, 32 bytes after the frame header
+		if v := i + 4 + 32; v+18 <= len(buf) && string(buf[v:v+4]) == "VBRI" {
+			frames := binary.BigEndian.Uint32(buf[v+14 : v+18])
+			return float64(frames) * float64(samplesPerFrame) / float64(sampleRate), nil
+		}
+
+		audioBytes := size - audioStart - int64(i)
+		return float64(audioBytes) * 8 / float64(bitrate*1000), nil
+	}
+	return 0, errors.New("no MP3 frame found")
+}
+
+// wavDuration divides the data chunk size by the byte rate from the fmt chunk
+func wavDuration(r io.ReadSeeker) (float64, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, errors.New("not a WAV file")
+	}
+
+	var byteRate uint32
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return 0, errors.New("no data chunk found")
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch string(chunk[:4]) {
+		case "fmt ":
+			fmtChunk := make([]byte, 16)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+			chunkSize -= 16
+		case "data":
+			if byteRate == 0 {
+				return 0, errors.New("missing WAV format")
+			}
+			return float64(chunkSize) / float64(byteRate), nil
+		}
+		// Chunks are padded to even sizes
+		if _, err := r.Seek(chunkSize+chunkSize%2, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// flacDuration reads the total samples and sample rate from STREAMINFO
+func flacDuration(r io.ReadSeeker) (float64, error) {
+	header := make([]byte, 4+4+34)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if string(header[:4]) != "fLaC" || header[4]&0x7F != 0 {
+		return 0, errors.New("not a FLAC file")
+	}
+	info := header[8:]
+	sampleRate := int64(info[10])<<12 | int64(info[11])<<4 | int64(info[12])>>4
+	totalSamples := int64(info[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 || totalSamples == 0 {
+		return 0, errors.New("FLAC length unknown")
+	}
+	return float64(totalSamples) / float64(sampleRate), nil
+}
+
+// oggDuration divides the last page's granule position by the stream's sample rate
+func oggDuration(r io.ReadSeeker, size int64) (float64, error) {
+	first := make([]byte, 128)
+	n, _ := io.ReadFull(r, first)
+	first = first[:n]
+	if !bytes.HasPrefix(first, []byte("OggS")) || len(first) < 28 {
+		return 0, errors.New("not an Ogg file")
+	}
+	packet := first[27+int(first[26]):]
+
+	var sampleRate, preSkip int64
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		sampleRate = int64(binary.LittleEndian.Uint32(packet[12:16]))
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 12:
+		sampleRate = 48000 // Opus granules always count 48 kHz samples
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+	default:
+		return 0, errors.New("unsupported Ogg codec")
+	}
+	if sampleRate == 0 {
+		return 0, errors.New("invalid Ogg sample rate")
+	}
+
+	tailSize := int64(64 * 1024)
+	if size < tailSize {
+		tailSize = size
+	}
+	if _, err := r.Seek(size-tailSize, io.SeekStart); err != nil {
+		return 0, err
+	}
+	tail := make([]byte, tailSize)
+	if _, err := io.ReadFull(r, tail); err != nil {
+		return 0, err
+	}
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) {
+		return 0, errors.New("no final Ogg page found")
+	}
+	granule := int64(binary.LittleEndian.Uint64(tail[last+6 : last+14]))
+	return float64(granule-preSkip) / float64(sampleRate), nil
+}
+
+// Matroska (WebM, MKV) element IDs used to find the duration
+const (
+	ebmlIDHeader        = 0x1A45DFA3
+	ebmlIDSegment       = 0x18538067
+	ebmlIDInfo          = 0x1549A966
+	ebmlIDTimecodeScale = 0x2AD7B1
+	ebmlIDDuration      = 0x4489
+)
+
+// matroskaDuration reads Segment/Info/Duration, which muxers write near the start
+func matroskaDuration(r io.ReadSeeker) (float64, error) {
+	buf := make([]byte, 1<<20)
+	n, _ := io.ReadFull(r, buf)
+	buf = buf[:n]
+
+	pos := 0
+	for pos < len(buf) {
+		id, size, dataStart, ok := readEBMLElement(buf, pos)
+		if !ok {
+			break
+		}
+		switch id {
+		case ebmlIDSegment:
+			pos = dataStart // Descend
+			continue
+		case ebmlIDInfo:
+			return matroskaInfoDuration(buf, dataStart, min(len(buf), dataStart+int(size)))
+		}
+		if size < 0 || dataStart+int(size) > len(buf) {
+			break
+		}
+		pos = dataStart + int(size)
+	}
+	return 0, errors.New("no Matroska duration found")
+}
+
+func matroskaInfoDuration(buf []byte, pos, end int) (float64, error) {
+	scale := 1_000_000.0 // Default timecode scale: milliseconds
+	duration := -1.0
+	for pos < end {
+		id, size, dataStart, ok := readEBMLElement(buf, pos)
+		if !ok || size < 0 || dataStart+int(size) > end {
+			break
+		}
+		data := buf[dataStart : dataStart+int(size)]
+		switch id {
+		case ebmlIDTimecodeScale:
+			var v uint64
+			for _, b := range data {
+				v = v<<8 | uint64(b)
+			}
+			scale = float64(v)
+		case ebmlIDDuration:
+			switch len(data) {
+			case 4:
+				duration = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+			case 8:
+				duration = math.Float64frombits(binary.BigEndian.Uint64(data))
+			}
+		}
+		pos = dataStart + int(size)
+	}
+	if duration < 0 {
+		return 0, errors.New("Matroska file has no duration")
+	}
+	return duration * scale / 1e9, nil
+}
+
+// readEBMLElement reads an element ID and size; size is -1 when unknown
+func readEBMLElement(buf []byte, pos int) (id uint64, size int64, dataStart int, ok bool) {
+	id, idLen := readEBMLVint(buf, pos, true)
+	if idLen == 0 {
+		return 0, 0, 0, false
+	}
+	rawSize, sizeLen := readEBMLVint(buf, pos+idLen, false)
+	if sizeLen == 0 {
+		return 0, 0, 0, false
+	}
+	size = int64(rawSize)
+	if rawSize == (uint64(1)<<(7*sizeLen))-1 { // All ones: unknown size
+		size = -1
+	}
+	return id, size, pos + idLen + sizeLen, true
+}
+
+// readEBMLVint reads a variable-length integer, keeping the length marker for IDs
+func readEBMLVint(buf []byte, pos int, keepMarker bool) (uint64, int) {
+	if pos >= len(buf) || buf[pos] == 0 {
+		return 0, 0
+	}
+	length := 1
+	for mask := byte(0x80); buf[pos]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || pos+length > len(buf) {
+		return 0, 0
+	}
+	v := uint64(buf[pos])
+	if !keepMarker {
+		v &= uint64(0xFF >> length)
+	}
+	for i := 1; i < length; i++ {
+		v = v<<8 | uint64(buf[pos+i])
+	}
+	return v, length
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestGeneratePreviewImage(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(800, 400))
+
+	result, err := GeneratePreview(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "image/png", ".png")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.Width != 800 || result.Height != 400 {
+		t.Errorf("Expected original size 800x400, got %dx%d", result.Width, result.Height)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(result.Thumbnail))
+	if err != nil {
+		t.Fatalf("Thumbnail is not a JPEG: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != thumbnailSize || b.Dy() != thumbnailSize/2 {
+		t.Errorf("Expected thumbnail scaled to %dx%d, got %dx%d", thumbnailSize, thumbnailSize/2, b.Dx(), b.Dy())
+	}
+}
+
+func TestGeneratePreviewUnsupported(t *testing.T) {
+	_, err := GeneratePreview(bytes.NewReader([]byte("PK")), 2, "application/zip", ".zip")
+	if !errors.Is(err, ErrNoPreview) {
+		t.Errorf("Expected ErrNoPreview, got %v", err)
+	}
+}
+
+func TestGeneratePreviewPDF(t *testing.T) {
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, testImage(200, 100), nil)
+
+	pdf := buildTestPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 5 0 R] /Count 2 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /XObject << /Im1 6 0 R >> >> /Contents 4 0 R >>",
+		"stream::q 595 0 0 842 0 0 cm /Im1 Do Q",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"stream:/Type /XObject /Subtype /Image /Width 200 /Height 100 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode:" + photo.String(),
+	})
+
+	result, err := GeneratePreview(bytes.NewReader(pdf), int64(len(pdf)), "application/pdf", ".pdf")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.PageCount != 2 {
+		t.Errorf("Expected 2 pages, got %d", result.PageCount)
+	}
+	if result.Width != 595 || result.Height != 842 {
+		t.Errorf("Expected A4 page size, got %dx%d", result.Width, result.Height)
+	}
+	if result.Thumbnail == nil {
+		t.Error("Expected a thumbnail from the page's image")
+	}
+}
+
+func TestGeneratePreviewPDFOversizedJPEG(t *testing.T) {
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, testImage(200, 100), nil)
+	// The JPEG header claims 60000x60000 while the PDF says 200x100
+	data := photo.Bytes()
+	sof := bytes.Index(data, []byte{0xFF, 0xC0})
+	if sof < 0 {
+		t.Fatal("No SOF0 marker in the test JPEG")
+	}
+	copy(data[sof+5:], []byte{0xEA, 0x60, 0xEA, 0x60})
+
+	pdf := buildTestPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im1 5 0 R >> >> /Contents 4 0 R >>",
+		"stream::q 595 0 0 842 0 0 cm /Im1 Do Q",
+		"stream:/Type /XObject /Subtype /Image /Width 200 /Height 100 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode:" + string(data),
+	})
+
+	result, err := GeneratePreview(bytes.NewReader(pdf), int64(len(pdf)), "application/pdf", ".pdf")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.Thumbnail != nil {
+		t.Error("Expected the oversized JPEG to be skipped")
+	}
+}
+
+func TestGeneratePreviewPDFText(t *testing.T) {
+	pdf := buildTestPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		"stream::BT /F1 12 Tf 72 700 Td (Cell division) Tj ET",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	})
+
+	result, err := GeneratePreview(bytes.NewReader(pdf), int64(len(pdf)), "application/pdf", ".pdf")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.Thumbnail != nil || result.Text != "Cell division" {
+		t.Errorf("Expected a text preview, got %q (thumbnail: %v)", result.Text, result.Thumbnail != nil)
+	}
+}
+
+func TestGeneratePreviewPPTX(t *testing.T) {
+	var thumb bytes.Buffer
+	jpeg.Encode(&thumb, testImage(256, 192), nil)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"ppt/slides/slide1.xml", "ppt/slides/slide2.xml", "ppt/slides/slide3.xml"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte("<p:sld/>"))
+	}
+	w, _ := zw.Create("docProps/app.xml")
+	w.Write([]byte("<Properties><Slides>2</Slides></Properties>"))
+	w, _ = zw.Create("docProps/thumbnail.jpeg")
+	w.Write(thumb.Bytes())
+	zw.Close()
+
+	result, err := GeneratePreview(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "", ".pptx")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.PageCount != 3 {
+		t.Errorf("Expected the 3 slides in the package, got %d", result.PageCount)
+	}
+	if result.Thumbnail == nil {
+		t.Error("Expected the embedded thumbnail")
+	}
+}
+
+func mp4Box(boxType string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(box, uint32(8+len(payload)))
+	copy(box[4:], boxType)
+	return append(box, payload...)
+}
+
+func TestGeneratePreviewMP4Duration(t *testing.T) {
+	mvhd := make([]byte, 100)                    // Version 0
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)  // Timescale
+	binary.BigEndian.PutUint32(mvhd[16:], 95500) // Duration
+	var file []byte
+	file = append(file, mp4Box("ftyp", []byte("isom\x00\x00\x02\x00"))...)
+	file = append(file, mp4Box("mdat", make([]byte, 64))...)
+	file = append(file, mp4Box("moov", append(mp4Box("mvhd", mvhd), mp4Box("trak", nil)...))...)
+
+	result, err := GeneratePreview(bytes.NewReader(file), int64(len(file)), "video/mp4", ".mp4")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.Duration != 95.5 {
+		t.Errorf("Expected 95.5 seconds, got %v", result.Duration)
+	}
+}
+
+func TestGeneratePreviewWAVDuration(t *testing.T) {
+	const byteRate = 44100 * 2 * 2
+	var file bytes.Buffer
+	file.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	file.WriteString("fmt ")
+	binary.Write(&file, binary.LittleEndian, []uint32{16, 1 | 2<<16, 44100, byteRate, 4 | 16<<16})
+	file.WriteString("LIST")
+	binary.Write(&file, binary.LittleEndian, uint32(3))
+	file.WriteString("abc\x00") // Odd chunk with padding
+	file.WriteString("data")
+	binary.Write(&file, binary.LittleEndian, uint32(byteRate*3/2))
+
+	result, err := GeneratePreview(bytes.NewReader(file.Bytes()), int64(file.Len()), "audio/wav", ".wav")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.Duration != 1.5 {
+		t.Errorf("Expected 1.5 seconds, got %v", result.Duration)
+	}
+}
+
+func TestGeneratePreviewMP3Duration(t *testing.T) {
+	// MPEG1 Layer III, 128 kbps, 44.1 kHz, stereo; no Xing header, so CBR
+	frame := []byte{0xFF, 0xFB, 0x90, 0x00}
+	file := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x0A"), make([]byte, 10)...)
+	file = append(file, frame...)
+	file = append(file, make([]byte, 16000-len(frame))...)
+
+	result, err := GeneratePreview(bytes.NewReader(file), int64(len(file)), "audio/mpeg", ".mp3")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.Duration != 1 {
+		t.Errorf("Expected 1 second from 16000 bytes at 128 kbps, got %v", result.Duration)
+	}
+}
+
+func TestGeneratePreviewWebMDuration(t *testing.T) {
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(12345)) // Milliseconds at the default scale
+	info := append([]byte{0x44, 0x89, 0x88}, duration...)
+	file := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x80}                                                // Empty EBML header
+	file = append(file, 0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF) // Segment, unknown size
+	file = append(file, 0x15, 0x49, 0xA9, 0x66, 0x80|byte(len(info)))
+	file = append(file, info...)
+
+	result, err := GeneratePreview(bytes.NewReader(file), int64(len(file)), "video/webm", ".webm")
+	if err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if result.Duration != 12.35 {
+		t.Errorf("Expected 12.35 seconds, got %v", result.Duration)
+	}
+}
+
+func TestPreviewText(t *testing.T) {
+	if got := previewText("  Short\n\ntext "); got != "Short text" {
+		t.Errorf("Expected collapsed whitespace, got %q", got)
+	}
+	long := previewText(strings.Repeat("word ", 100))
+	if len(long) > previewTextLength+len("…") || !strings.HasSuffix(long, "word…") {
+		t.Errorf("Expected text cut at a word boundary, got %q", long)
+	}
+}
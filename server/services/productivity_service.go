@@ -2,9 +2,7 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"strings"
 	"time"
 
 	"buddy-server/database"
@@ -16,45 +14,29 @@ import (
 
 // ProductivityService handles productivity scoring and AI assessments
 type ProductivityService struct {
-	db            *database.DB
-	geminiService *GeminiService
+	db        *database.DB
+	aiService *AIService
 }
 
 // NewProductivityService creates a new productivity service
-func NewProductivityService(db *database.DB, geminiService *GeminiService) *ProductivityService {
+func NewProductivityService(db *database.DB, aiService *AIService) *ProductivityService {
 	return &ProductivityService{
-		db:            db,
-		geminiService: geminiService,
+		db:        db,
+		aiService: aiService,
 	}
 }
 
 // GenerateAssessmentQuestions generates AI questions for a study session
-func (s *ProductivityService) GenerateAssessmentQuestions(subject, notes string, durationMinutes int) ([]models.AIQuestion, error) {
-	if s.geminiService == nil {
+func (s *ProductivityService) GenerateAssessmentQuestions(userID, subject, notes string, durationMinutes int) ([]models.AIQuestion, error) {
+	if s.aiService == nil {
 		return nil, errors.New("AI service not available")
 	}
 
-	jsonResponse, err := s.geminiService.GenerateStudyAssessmentQuestions(subject, notes, durationMinutes)
+	response, err := s.aiService.GenerateStudyAssessmentQuestions(WithAIUsage(context.Background(), userID, ""), subject, notes, durationMinutes)
 	if err != nil {
 		return nil, err
 	}
 
-	// Clean the response - remove markdown code blocks if present
-	cleanedResponse := strings.TrimSpace(jsonResponse)
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```json")
-	cleanedResponse = strings.TrimPrefix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSuffix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSpace(cleanedResponse)
-
-	// Parse JSON response
-	var response struct {
-		Questions []models.AIQuestion `json:"questions"`
-	}
-
-	if err := json.Unmarshal([]byte(cleanedResponse), &response); err != nil {
-		return nil, errors.New("failed to parse AI response: " + err.Error())
-	}
-
 	return response.Questions, nil
 }
 
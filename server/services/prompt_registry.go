@@ -0,0 +1,552 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Names of the prompts kept in the registry
+const (
+	PromptStudentReport   = "student_report"
+	PromptGoalSuggestions = "goal_suggestions"
+	PromptGameQuiz        = "game_quiz"
+	PromptGameFlashcards  = "game_flashcards"
+	PromptGameFillBlank   = "game_fill_blank"
+	PromptGameMatching    = "game_matching"
+)
+
+var (
+	// ErrPromptNotFound is returned for a prompt name the registry doesn't have
+	ErrPromptNotFound = errors.New("prompt not found")
+	// ErrPromptVersionNotFound is returned for a version a prompt doesn't have
+	ErrPromptVersionNotFound = errors.New("prompt version not found")
+	// ErrInvalidPrompt is returned for a template or release that can't be used
+	ErrInvalidPrompt = errors.New("invalid prompt")
+)
+
+// promptDefinition is a prompt's variables and its built-in version
+type promptDefinition struct {
+	variables []models.PromptVariable
+	text      string
+}
+
+func promptVars(spec ...string) []models.PromptVariable {
+	vars := make([]models.PromptVariable, 0, len(spec))
+	for _, s := range spec {
+		name, typ, _ := strings.Cut(s, ":")
+		vars = append(vars, models.PromptVariable{Name: name, Type: typ})
+	}
+	return vars
+}
+
+var gameQuestionVars = promptVars("count:int", "subject:string", "difficulty:string", "syllabus:string")
+
+// builtinPrompts are version 1 of each prompt. Their variables are what the
+// code fills in, so every later version is checked against them.
+var builtinPrompts = map[string]promptDefinition{
+	PromptStudentReport: {
+		variables: promptVars("start_date:string", "end_date:string", "total_hours:float", "session_count:int",
+			"avg_focus:float", "avg_productivity:float", "goals_completed:int", "goals_total:int",
+			"milestones_progress:float", "plan_progress:float", "activities:any", "assessments:any"),
+		text: "You are an expert educational advisor analyzing student performance data.\n\n" +
+			"Student Data (Period: {{.start_date}} to {{.end_date}}):\n" +
+			"- Total Study Hours: {{printf \"%.1f\" .total_hours}}\n" +
+			"- Sessions Completed: {{.session_count}}\n" +
+			"- Average Focus Score: {{printf \"%.1f\" .avg_focus}}%\n" +
+			"- Average Productivity Score: {{printf \"%.1f\" .avg_productivity}}%\n" +
+			"- Goals: {{.goals_completed}}/{{.goals_total}} completed\n" +
+			"- Milestones Progress: {{printf \"%.1f\" .milestones_progress}}%\n" +
+			"- Study Plan Progress: {{printf \"%.1f\" .plan_progress}}%\n\n" +
+			"Activity Details:\n{{.activities}}\n\n" +
+			"AI Assessment Results:\n{{.assessments}}\n\n" +
+			"Using critical thinking, analyze:\n" +
+			"1. What patterns indicate effective learning?\n" +
+			"2. What obstacles might be preventing progress?\n" +
+			"3. Are study habits sustainable?\n" +
+			"4. What specific changes would have maximum impact?\n\n" +
+			"Generate report in this EXACT JSON format:\n" +
+			`{"summary": "2-3 sentence overview", "strengths": ["...", "..."], "weak_areas": ["...", "..."], "recommendations": ["...", "..."], "overall_score": 85}`,
+	},
+	PromptGoalSuggestions: {
+		variables: promptVars("recent_activities:list", "current_goals:list", "milestones:list",
+			"plan_progress:list", "strengths:list", "weak_areas:list"),
+		text: "You are an AI learning coach. Generate 3-5 personalized daily goal suggestions for a student.\n\n" +
+			"Student Context:\n" +
+			"- Recent Activities: {{.recent_activities}}\n" +
+			"- Current Goals: {{.current_goals}}\n" +
+			"- Upcoming Milestones: {{.milestones}}\n" +
+			"- Study Plan Progress: {{.plan_progress}}\n" +
+			"- Strengths: {{.strengths}}\n" +
+			"- Weak Areas: {{.weak_areas}}\n\n" +
+			"Critical thinking: What goals would maximize learning efficiency today?\n\n" +
+			"Generate goals in this EXACT JSON format:\n" +
+			`{"suggestions": [{"title": "...", "description": "...", "subject": "...", "priority": "high", "reasoning": "..."}, ...]}`,
+	},
+	PromptGameQuiz: {
+		variables: gameQuestionVars,
+		text: "Generate {{.count}} multiple choice quiz questions about {{.subject}} at {{.difficulty}} difficulty level. Syllabus: {{.syllabus}}\n\n" +
+			"Each correct_answer must be copied exactly from its options, not a letter.\n\n" +
+			`Return in JSON format: {"questions": [{"question": "Which gas do plants absorb for photosynthesis?", "options": ["Oxygen", "Carbon dioxide", "Nitrogen", "Hydrogen"], "correct_answer": "Carbon dioxide", "explanation": "...", "points": 10}]}`,
+	},
+	PromptGameFlashcards: {
+		variables: gameQuestionVars,
+		text: "Generate {{.count}} flashcard pairs (question/answer) about {{.subject}} at {{.difficulty}} difficulty level. Syllabus: {{.syllabus}}\n\n" +
+			`Return in JSON format: {"questions": [{"question": "Front of card", "correct_answer": "Back of card", "explanation": "...", "points": 5}]}`,
+	},
+	PromptGameFillBlank: {
+		variables: gameQuestionVars,
+		text: "Generate {{.count}} fill-in-the-blank questions about {{.subject}} at {{.difficulty}} difficulty level. Syllabus: {{.syllabus}}\n\n" +
+			`Return in JSON format: {"questions": [{"question": "The ____ is...", "correct_answer": "answer", "explanation": "...", "points": 10}]}`,
+	},
+	PromptGameMatching: {
+		variables: gameQuestionVars,
+		text: "Generate {{.count}} matching pairs about {{.subject}} at {{.difficulty}} difficulty level. Syllabus: {{.syllabus}}\n\n" +
+			`Return in JSON format: {"questions": [{"question": "Item A", "correct_answer": "Match for A", "points": 10}]}`,
+	},
+}
+
+// PromptRegistry keeps the versions of the AI features' prompts and which
+// version each feature uses. Without a database only the built-in versions
+// are used.
+type PromptRegistry struct {
+	db     *database.DB
+	pinned map[string]renderedPrompt // Template and version label used instead of the release
+}
+
+// NewPromptRegistry creates a prompt registry
+func NewPromptRegistry(db *database.DB) *PromptRegistry {
+	return &PromptRegistry{db: db}
+}
+
+// renderedPrompt is a prompt filled in, with the version it came from
+type renderedPrompt struct {
+	text    string
+	version string // "name@version", recorded on what the prompt generated
+}
+
+// render fills in the version of a prompt in use for the user in ctx. A
+// candidate version is used for a fixed share of users, so each user sees
+// the same version while it is tried.
+func (r *PromptRegistry) render(ctx context.Context, name string, vars map[string]interface{}) (*renderedPrompt, error) {
+	def, ok := builtinPrompts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	if err := checkPromptVariables(def.variables, vars); err != nil {
+		return nil, err
+	}
+
+	if pinned, ok := r.pinnedPrompt(name); ok {
+		out, err := executePrompt(name, pinned.text, vars)
+		if err != nil {
+			return nil, err
+		}
+		return &renderedPrompt{text: out, version: pinned.version}, nil
+	}
+
+	version, text := 1, def.text
+	if r != nil && r.db != nil {
+		release, err := r.release(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		version = releasedVersion(release, aiUsageFrom(ctx).userID)
+		if version != 1 {
+			stored, err := r.version(ctx, name, version)
+			if err != nil {
+				return nil, err
+			}
+			text = stored.Text
+		}
+	}
+
+	out, err := executePrompt(name, text, vars)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedPrompt{text: out, version: fmt.Sprintf("%s@%d", name, version)}, nil
+}
+
+func (r *PromptRegistry) pinnedPrompt(name string) (renderedPrompt, bool) {
+	if r == nil {
+		return renderedPrompt{}, false
+	}
+	pinned, ok := r.pinned[name]
+	return pinned, ok
+}
+
+// Pin makes every request use one version of a prompt whatever its release
+// says, e.g. to evaluate the version. Versions after the built-in one are
+// read from the database.
+func (r *PromptRegistry) Pin(name string, version int) error {
+	if _, ok := builtinPrompts[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	stored := builtinPromptTemplate(name)
+	if version != 1 {
+		if r.db == nil {
+			return fmt.Errorf("%w: %s@%d needs a database", ErrPromptVersionNotFound, name, version)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var err error
+		if stored, err = r.version(ctx, name, version); err != nil {
+			return err
+		}
+	}
+	r.pin(name, renderedPrompt{text: stored.Text, version: fmt.Sprintf("%s@%d", name, version)})
+	return nil
+}
+
+// PinText makes every request use a template that hasn't been stored, such
+// as a draft being evaluated; label names it in prompt versions
+func (r *PromptRegistry) PinText(name, label, text string) error {
+	def, ok := builtinPrompts[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	if err := validatePromptText(def, name, text); err != nil {
+		return err
+	}
+	r.pin(name, renderedPrompt{text: text, version: name + "@" + label})
+	return nil
+}
+
+// PinBuiltins pins every prompt that isn't pinned yet to its built-in
+// version, so none of them follows its release
+func (r *PromptRegistry) PinBuiltins() {
+	for name, def := range builtinPrompts {
+		if _, ok := r.pinned[name]; !ok {
+			r.pin(name, renderedPrompt{text: def.text, version: name + "@1"})
+		}
+	}
+}
+
+// PinnedVersions returns the version label of each pinned prompt, by name
+func (r *PromptRegistry) PinnedVersions() map[string]string {
+	versions := make(map[string]string, len(r.pinned))
+	for name, prompt := range r.pinned {
+		versions[name] = prompt.version
+	}
+	return versions
+}
+
+func (r *PromptRegistry) pin(name string, prompt renderedPrompt) {
+	if r.pinned == nil {
+		r.pinned = make(map[string]renderedPrompt)
+	}
+	r.pinned[name] = prompt
+}
+
+// releasedVersion picks the version of a release a user gets
+func releasedVersion(release *models.PromptRelease, userID *primitive.ObjectID) int {
+	if release.CandidateVersion > 0 && release.CandidatePercent > 0 && userID != nil {
+		h := fnv.New32a()
+		h.Write([]byte(release.Name + ":" + userID.Hex()))
+		if int(h.Sum32()%100) < release.CandidatePercent {
+			return release.CandidateVersion
+		}
+	}
+	return max(release.Version, 1)
+}
+
+// checkPromptVariables checks that vars has a value of the declared type for
+// every variable
+func checkPromptVariables(declared []models.PromptVariable, vars map[string]interface{}) error {
+	for _, v := range declared {
+		value, ok := vars[v.Name]
+		if !ok {
+			return fmt.Errorf("%w: missing variable %s", ErrInvalidPrompt, v.Name)
+		}
+		if !promptValueHasType(value, v.Type) {
+			return fmt.Errorf("%w: variable %s must be a %s, got %T", ErrInvalidPrompt, v.Name, v.Type, value)
+		}
+	}
+	return nil
+}
+
+func promptValueHasType(value interface{}, typ string) bool {
+	if typ == "any" {
+		return true
+	}
+	if value == nil {
+		return false
+	}
+	switch kind := reflect.TypeOf(value).Kind(); typ {
+	case "string":
+		return kind == reflect.String
+	case "int":
+		return kind >= reflect.Int && kind <= reflect.Int64
+	case "float":
+		return kind == reflect.Float32 || kind == reflect.Float64
+	case "list":
+		return kind == reflect.Slice || kind == reflect.Array
+	default:
+		return false
+	}
+}
+
+// executePrompt fills in a template; using a variable the prompt doesn't
+// declare is an error
+func executePrompt(name, text string, vars map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPrompt, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPrompt, err)
+	}
+	return sb.String(), nil
+}
+
+// samplePromptValues are values of each variable type used to check a new
+// version before it is stored
+var samplePromptValues = map[string]interface{}{
+	"string": "example",
+	"int":    3,
+	"float":  2.5,
+	"list":   []string{"example"},
+	"any":    "example",
+}
+
+// validatePromptText checks that a template parses and only uses the
+// prompt's variables
+func validatePromptText(def promptDefinition, name, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("%w: text is required", ErrInvalidPrompt)
+	}
+	vars := make(map[string]interface{}, len(def.variables))
+	for _, v := range def.variables {
+		vars[v.Name] = samplePromptValues[v.Type]
+	}
+	_, err := executePrompt(name, text, vars)
+	return err
+}
+
+// release returns a prompt's release; a prompt that was never released uses
+// its built-in version
+func (r *PromptRegistry) release(ctx context.Context, name string) (*models.PromptRelease, error) {
+	var release models.PromptRelease
+	err := r.db.Collection("prompt_releases").FindOne(ctx, bson.M{"_id": name}).Decode(&release)
+	if err == mongo.ErrNoDocuments {
+		return &models.PromptRelease{Name: name, Version: 1}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// version returns a version of a prompt
+func (r *PromptRegistry) version(ctx context.Context, name string, version int) (*models.PromptTemplate, error) {
+	if version == 1 {
+		return builtinPromptTemplate(name), nil
+	}
+	var stored models.PromptTemplate
+	err := r.db.Collection("prompt_templates").FindOne(ctx, bson.M{"name": name, "version": version}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("%w: %s@%d", ErrPromptVersionNotFound, name, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func builtinPromptTemplate(name string) *models.PromptTemplate {
+	return &models.PromptTemplate{Name: name, Version: 1, Text: builtinPrompts[name].text, Builtin: true}
+}
+
+func (r *PromptRegistry) summary(name string, release *models.PromptRelease) models.PromptSummary {
+	return models.PromptSummary{
+		Name:          name,
+		Variables:     builtinPrompts[name].variables,
+		LatestVersion: release.StoredVersions + 1,
+		Release:       *release,
+	}
+}
+
+// ListPrompts lists the prompts and the versions in use
+func (r *PromptRegistry) ListPrompts() ([]models.PromptSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	names := make([]string, 0, len(builtinPrompts))
+	for name := range builtinPrompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summaries := make([]models.PromptSummary, 0, len(names))
+	for _, name := range names {
+		release, err := r.release(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, r.summary(name, release))
+	}
+	return summaries, nil
+}
+
+// GetPrompt returns a prompt with all of its versions
+func (r *PromptRegistry) GetPrompt(name string) (*models.PromptDetail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := builtinPrompts[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	release, err := r.release(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.db.Collection("prompt_templates").Find(ctx, bson.M{"name": name},
+		options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	versions := []models.PromptTemplate{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	versions = append(versions, *builtinPromptTemplate(name))
+
+	return &models.PromptDetail{PromptSummary: r.summary(name, release), Versions: versions}, nil
+}
+
+// CreateVersion adds a version of a prompt; it isn't used until it is released
+func (r *PromptRegistry) CreateVersion(name, text, note, adminID string) (*models.PromptTemplate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	def, ok := builtinPrompts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	if err := validatePromptText(def, name, text); err != nil {
+		return nil, err
+	}
+
+	// Number the version from a counter on the release, so concurrent
+	// versions can't get the same number
+	var release models.PromptRelease
+	err := r.db.Collection("prompt_releases").FindOneAndUpdate(ctx, bson.M{"_id": name}, bson.M{
+		"$inc":         bson.M{"stored_versions": 1},
+		"$setOnInsert": bson.M{"version": 1, "updated_at": time.Now()},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&release)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := &models.PromptTemplate{
+		Name:      name,
+		Version:   release.StoredVersions + 1,
+		Text:      text,
+		Note:      strings.TrimSpace(note),
+		CreatedAt: time.Now(),
+	}
+	if adminOID, err := primitive.ObjectIDFromHex(adminID); err == nil {
+		prompt.CreatedBy = &adminOID
+	}
+	result, err := r.db.Collection("prompt_templates").InsertOne(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	prompt.ID = result.InsertedID.(primitive.ObjectID)
+	return prompt, nil
+}
+
+// PromptReleaseRequest chooses the version of a prompt in use, and optionally
+// a candidate version to try on a percentage of users
+type PromptReleaseRequest struct {
+	Version          int `json:"version" binding:"required,min=1"`
+	CandidateVersion int `json:"candidate_version"`
+	CandidatePercent int `json:"candidate_percent"`
+}
+
+// SetRelease rolls a prompt forward or back to a version
+func (r *PromptRegistry) SetRelease(name string, req PromptReleaseRequest, adminID string) (*models.PromptSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := builtinPrompts[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	if req.CandidatePercent < 0 || req.CandidatePercent > 100 {
+		return nil, fmt.Errorf("%w: candidate_percent must be between 0 and 100", ErrInvalidPrompt)
+	}
+	if req.CandidateVersion == req.Version || (req.CandidateVersion == 0) != (req.CandidatePercent == 0) {
+		return nil, fmt.Errorf("%w: a candidate_version needs a candidate_percent and must differ from version", ErrInvalidPrompt)
+	}
+	for _, version := range []int{req.Version, req.CandidateVersion} {
+		if version == 0 {
+			continue
+		}
+		if _, err := r.version(ctx, name, version); err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := r.release(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	set := bson.M{"version": req.Version, "updated_at": time.Now()}
+	if current.Version != req.Version {
+		set["previous_version"] = max(current.Version, 1)
+	}
+	if adminOID, err := primitive.ObjectIDFromHex(adminID); err == nil {
+		set["updated_by"] = adminOID
+	}
+	update := bson.M{"$set": set}
+	if req.CandidateVersion > 0 {
+		set["candidate_version"] = req.CandidateVersion
+		set["candidate_percent"] = req.CandidatePercent
+	} else {
+		update["$unset"] = bson.M{"candidate_version": "", "candidate_percent": ""}
+	}
+
+	var release models.PromptRelease
+	err = r.db.Collection("prompt_releases").FindOneAndUpdate(ctx, bson.M{"_id": name}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&release)
+	if err != nil {
+		return nil, err
+	}
+	summary := r.summary(name, &release)
+	return &summary, nil
+}
+
+// Rollback returns a prompt to the version it used before the last release
+func (r *PromptRegistry) Rollback(name, adminID string) (*models.PromptSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := builtinPrompts[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	current, err := r.release(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if current.PreviousVersion == 0 {
+		return nil, fmt.Errorf("%w: %s has no earlier release to roll back to", ErrInvalidPrompt, name)
+	}
+	return r.SetRelease(name, PromptReleaseRequest{Version: current.PreviousVersion}, adminID)
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBuiltinPromptsAreValid(t *testing.T) {
+	for name, def := range builtinPrompts {
+		if err := validatePromptText(def, name, def.text); err != nil {
+			t.Errorf("Built-in prompt %s: %v", name, err)
+		}
+	}
+}
+
+func reportPromptData() map[string]interface{} {
+	return map[string]interface{}{
+		"start_date": "2026-10-01", "end_date": "2026-10-07",
+		"total_hours": 12.25, "session_count": 9,
+		"avg_focus": 80.0, "avg_productivity": 72.5,
+		"goals_completed": 3, "goals_total": 4,
+		"milestones_progress": 50.0, "plan_progress": 40.0,
+		"activities": []models.ActivityLog{}, "assessments": nil,
+	}
+}
+
+func TestPromptRenderChecksVariables(t *testing.T) {
+	var registry *PromptRegistry // Built-in versions only
+
+	prompt, err := registry.render(context.Background(), PromptStudentReport, reportPromptData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt.version != "student_report@1" {
+		t.Errorf("Expected the built-in version, got %q", prompt.version)
+	}
+	if !strings.Contains(prompt.text, "Total Study Hours: 12.2") || !strings.Contains(prompt.text, "Goals: 3/4 completed") {
+		t.Errorf("Expected the variables filled in, got %q", prompt.text)
+	}
+
+	data := reportPromptData()
+	delete(data, "goals_total")
+	if _, err := registry.render(context.Background(), PromptStudentReport, data); !errors.Is(err, ErrInvalidPrompt) {
+		t.Errorf("Expected a missing variable to be rejected, got %v", err)
+	}
+
+	data = reportPromptData()
+	data["total_hours"] = 12
+	if _, err := registry.render(context.Background(), PromptStudentReport, data); !errors.Is(err, ErrInvalidPrompt) || !strings.Contains(err.Error(), "total_hours must be a float") {
+		t.Errorf("Expected an int for a float variable to be rejected, got %v", err)
+	}
+
+	if _, err := registry.render(context.Background(), "unknown", nil); !errors.Is(err, ErrPromptNotFound) {
+		t.Errorf("Expected an unknown prompt to be rejected, got %v", err)
+	}
+}
+
+func TestValidatePromptText(t *testing.T) {
+	def := builtinPrompts[PromptGameQuiz]
+	if err := validatePromptText(def, PromptGameQuiz, "Write {{.count}} {{.difficulty}} questions on {{.subject}}."); err != nil {
+		t.Errorf("Expected a version using the prompt's variables to be valid, got %v", err)
+	}
+	for _, text := range []string{
+		"",
+		"Write {{.count}} questions for {{.grade}}", // Not a variable of the prompt
+		"Write {{.count} questions",                 // Doesn't parse
+	} {
+		if err := validatePromptText(def, PromptGameQuiz, text); !errors.Is(err, ErrInvalidPrompt) {
+			t.Errorf("Expected %q to be rejected, got %v", text, err)
+		}
+	}
+}
+
+func TestReleasedVersion(t *testing.T) {
+	user := primitive.NewObjectID()
+	release := &models.PromptRelease{Name: PromptGoalSuggestions, Version: 2}
+	if version := releasedVersion(release, &user); version != 2 {
+		t.Errorf("Expected the released version, got %d", version)
+	}
+	if version := releasedVersion(&models.PromptRelease{Name: PromptGoalSuggestions}, &user); version != 1 {
+		t.Errorf("Expected the built-in version before any release, got %d", version)
+	}
+
+	release.CandidateVersion, release.CandidatePercent = 3, 100
+	if version := releasedVersion(release, &user); version != 3 {
+		t.Errorf("Expected the candidate for every user at 100%%, got %d", version)
+	}
+	if version := releasedVersion(release, nil); version != 2 {
+		t.Errorf("Expected the released version without a user, got %d", version)
+	}
+
+	// Each user keeps their version; about half get the candidate
+	release.CandidatePercent = 50
+	candidates := 0
+	for i := 0; i < 1000; i++ {
+		id := primitive.NewObjectID()
+		version := releasedVersion(release, &id)
+		if version != releasedVersion(release, &id) {
+			t.Fatal("Expected a user to always get the same version")
+		}
+		if version == 3 {
+			candidates++
+		}
+	}
+	if candidates < 400 || candidates > 600 {
+		t.Errorf("Expected about half the users to get the candidate, got %d of 1000", candidates)
+	}
+}
+
+func TestAIServiceRecordsPromptVersion(t *testing.T) {
+	provider := NewScriptedLLMProvider([]ScriptedReply{{
+		Match: "Generate 2 multiple choice quiz questions about Fractions at easy difficulty level",
+		Reply: `{"questions": [{"question": "1/2 + 1/4?", "options": ["3/4", "2/6"], "correct_answer": "3/4", "points": 10}, {"question": "1/3 of 9?", "options": ["3", "6"], "correct_answer": "3", "points": 10}]}`,
+	}})
+	ai := NewAIService(provider)
+
+	set, err := ai.GenerateGameQuestions(context.Background(), "quiz", "Fractions", "easy", 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.PromptVersion != "game_quiz@1" || len(set.Questions) != 2 {
+		t.Errorf("Expected two questions from game_quiz@1, got %+v", set)
+	}
+}
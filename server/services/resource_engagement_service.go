@@ -0,0 +1,562 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// engagementDedupeWindow is how long repeated views or downloads of a
+	// resource by the same member count as one (e.g. range requests of a video)
+	engagementDedupeWindow = 5 * time.Minute
+	// videoCompletionRatio is how much of a video must be watched to count as completed
+	videoCompletionRatio = 0.9
+)
+
+var (
+	// ErrAnalyticsPermission is returned when someone other than the room owner asks for analytics
+	ErrAnalyticsPermission = errors.New("only the room owner can view analytics")
+	// ErrRoomNotFound is returned for an unknown room
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrStudentNotFound is returned when a report asks for someone who isn't a student in the room
+	ErrStudentNotFound = errors.New("student is not a member of this room")
+	// ErrExamNotFound is returned when a report is cut off at an exam the room doesn't have
+	ErrExamNotFound = errors.New("exam not found")
+	// ErrInvalidEngagement is returned for an engagement report the client got wrong
+	ErrInvalidEngagement = errors.New("invalid engagement")
+)
+
+// ResourceEngagementService records how room members use resources and reports
+// on it to teachers
+type ResourceEngagementService struct {
+	db *database.DB
+}
+
+// NewResourceEngagementService creates a new resource engagement service
+func NewResourceEngagementService(db *database.DB) *ResourceEngagementService {
+	return &ResourceEngagementService{db: db}
+}
+
+// EngagementCutoff limits "opened" to before a point in time, e.g. an exam
+type EngagementCutoff struct {
+	Before *time.Time       `json:"before,omitempty"`
+	Exam   *models.ExamDate `json:"exam,omitempty"`
+}
+
+// ResourceEngagementSummary is how a room's students have used one resource.
+// Views and downloads are totals; the cutoff only decides who counts as having opened it.
+type ResourceEngagementSummary struct {
+	ResourceID      string     `json:"resource_id"`
+	Name            string     `json:"name"`
+	Category        string     `json:"category"`
+	FileType        string     `json:"file_type"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Views           int        `json:"views"`
+	Downloads       int        `json:"downloads"`
+	StudentsOpened  int        `json:"students_opened"`
+	StudentsTotal   int        `json:"students_total"`
+	OpenRate        float64    `json:"open_rate"`                   // Percent of students who opened it
+	AvgWatchPercent float64    `json:"avg_watch_percent,omitempty"` // Videos: average furthest position among students who played it
+	Completed       int        `json:"completed,omitempty"`         // Videos: students who watched nearly all of it
+	LastOpenedAt    *time.Time `json:"last_opened_at,omitempty"`
+}
+
+// StudentResourceEngagement is how one student has used one resource
+type StudentResourceEngagement struct {
+	UserID         string     `json:"user_id"`
+	Name           string     `json:"name"`
+	ResourceID     string     `json:"resource_id"`
+	ResourceName   string     `json:"resource_name"`
+	Opened         bool       `json:"opened"`
+	Views          int        `json:"views"`
+	Downloads      int        `json:"downloads"`
+	FirstOpenedAt  *time.Time `json:"first_opened_at,omitempty"`
+	LastOpenedAt   *time.Time `json:"last_opened_at,omitempty"`
+	WatchedPercent float64    `json:"watched_percent,omitempty"`
+	Completed      bool       `json:"completed"`
+}
+
+// EngagementStudent identifies a student in a report
+type EngagementStudent struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// RoomResourceEngagement reports engagement with every teacher resource of a room
+type RoomResourceEngagement struct {
+	RoomID        string                      `json:"room_id"`
+	StudentsTotal int                         `json:"students_total"`
+	Cutoff        EngagementCutoff            `json:"cutoff"`
+	Resources     []ResourceEngagementSummary `json:"resources"`
+}
+
+// ResourceEngagementDetail reports each student's engagement with one resource,
+// listing the students who haven't opened it (before the cutoff)
+type ResourceEngagementDetail struct {
+	ResourceEngagementSummary
+	Cutoff    EngagementCutoff            `json:"cutoff"`
+	Students  []StudentResourceEngagement `json:"students"`
+	NotOpened []EngagementStudent         `json:"not_opened"`
+}
+
+// StudentEngagementReport reports one student's engagement with a room's teacher resources
+type StudentEngagementReport struct {
+	EngagementStudent
+	RoomID          string                      `json:"room_id"`
+	Cutoff          EngagementCutoff            `json:"cutoff"`
+	ResourcesOpened int                         `json:"resources_opened"`
+	ResourcesTotal  int                         `json:"resources_total"`
+	Resources       []StudentResourceEngagement `json:"resources"`
+}
+
+// RecordEvent records a view or download of a resource. Uploaders and the room
+// owner aren't tracked, and repeats within engagementDedupeWindow count once.
+func (s *ResourceEngagementService) RecordEvent(resource *models.Resource, userID, eventType string) error {
+	if eventType != "view" && eventType != "download" {
+		return fmt.Errorf("%w: unknown event %q", ErrInvalidEngagement, eventType)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userObjectID, track, err := s.tracked(ctx, resource, userID)
+	if err != nil || !track {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.touch(ctx, resource, userObjectID, now, nil); err != nil {
+		return err
+	}
+
+	counter, lastField := "views", "last_view_at"
+	if eventType == "download" {
+		counter, lastField = "downloads", "last_download_at"
+	}
+	result, err := s.db.Collection("resource_engagement").UpdateOne(ctx, bson.M{
+		"resource_id": resource.ID,
+		"user_id":     userObjectID,
+		"$or": []bson.M{
+			{lastField: bson.M{"$exists": false}},
+			{lastField: bson.M{"$lte": now.Add(-engagementDedupeWindow)}},
+		},
+	}, bson.M{
+		"$inc": bson.M{counter: 1},
+		"$set": bson.M{lastField: now},
+	})
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+
+	_, err = s.db.Collection("resource_events").InsertOne(ctx, models.ResourceEvent{
+		ResourceID: resource.ID,
+		RoomID:     resource.RoomID,
+		UserID:     userObjectID,
+		Type:       eventType,
+		CreatedAt:  now,
+	})
+	return err
+}
+
+// RecordProgress records how far a member has watched a video, as reported by
+// the player; only the furthest position is kept
+func (s *ResourceEngagementService) RecordProgress(resource *models.Resource, userID string, position, duration float64) error {
+	if math.IsNaN(position) || math.IsInf(position, 0) || position < 0 ||
+		math.IsNaN(duration) || math.IsInf(duration, 0) || duration < 0 {
+		return fmt.Errorf("%w: position and duration must be non-negative numbers", ErrInvalidEngagement)
+	}
+	if duration > 0 {
+		position = math.Min(position, duration)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userObjectID, track, err := s.tracked(ctx, resource, userID)
+	if err != nil || !track {
+		return err
+	}
+
+	progress := bson.M{"watched_seconds": position, "duration_seconds": duration}
+	return s.touch(ctx, resource, userObjectID, time.Now(), progress)
+}
+
+// tracked reports whether a user's use of a resource is recorded: room members
+// other than the uploader and the room owner
+func (s *ResourceEngagementService) tracked(ctx context.Context, resource *models.Resource, userID string) (primitive.ObjectID, bool, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, false, fmt.Errorf("%w: invalid user ID", ErrInvalidEngagement)
+	}
+	if resource.UploaderID == userObjectID {
+		return userObjectID, false, nil
+	}
+	isOwner, isMember, err := roomAccess(ctx, s.db, resource.RoomID, userObjectID)
+	if err != nil {
+		return userObjectID, false, err
+	}
+	return userObjectID, isMember && !isOwner, nil
+}
+
+// touch creates or updates a member's engagement with a resource, marking it
+// opened now and raising the watch progress when given
+func (s *ResourceEngagementService) touch(ctx context.Context, resource *models.Resource, userID primitive.ObjectID, now time.Time, progress bson.M) error {
+	update := bson.M{
+		"$setOnInsert": bson.M{"room_id": resource.RoomID},
+		"$min":         bson.M{"first_opened_at": now},
+		"$max":         bson.M{"last_opened_at": now},
+		"$set":         bson.M{"updated_at": now},
+	}
+	if progress != nil {
+		for field, value := range progress {
+			update["$max"].(bson.M)[field] = value
+		}
+		position, duration := progress["watched_seconds"].(float64), progress["duration_seconds"].(float64)
+		if duration > 0 && position >= duration*videoCompletionRatio {
+			update["$set"].(bson.M)["completed"] = true
+		}
+	}
+	_, err := s.db.Collection("resource_engagement").UpdateOne(ctx,
+		bson.M{"resource_id": resource.ID, "user_id": userID},
+		update,
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// GetRoomEngagement reports engagement with each of a room's teacher resources (room owner)
+func (s *ResourceEngagementService) GetRoomEngagement(roomID, userID, before, exam string) (*RoomResourceEngagement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	room, err := s.ownedRoom(ctx, roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	cutoff, err := engagementCutoff(room, before, exam)
+	if err != nil {
+		return nil, err
+	}
+	students, err := s.roomStudents(ctx, room)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := s.teacherResources(ctx, bson.M{"room_id": room.ID})
+	if err != nil {
+		return nil, err
+	}
+	engagement, err := s.engagementByResource(ctx, bson.M{"room_id": room.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RoomResourceEngagement{
+		RoomID:        roomID,
+		StudentsTotal: len(students),
+		Cutoff:        cutoff,
+		Resources:     []ResourceEngagementSummary{},
+	}
+	for i := range resources {
+		summary, _ := summarizeEngagement(&resources[i], students, engagement[resources[i].ID], cutoff.Before)
+		report.Resources = append(report.Resources, summary)
+	}
+	return report, nil
+}
+
+// GetResourceEngagement reports each student's engagement with one resource (room owner)
+func (s *ResourceEngagementService) GetResourceEngagement(roomID, resourceID, userID, before, exam string) (*ResourceEngagementDetail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	room, err := s.ownedRoom(ctx, roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	cutoff, err := engagementCutoff(room, before, exam)
+	if err != nil {
+		return nil, err
+	}
+	resourceObjectID, err := primitive.ObjectIDFromHex(resourceID)
+	if err != nil {
+		return nil, ErrResourceNotFound
+	}
+	resources, err := s.teacherResources(ctx, bson.M{"_id": resourceObjectID, "room_id": room.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, ErrResourceNotFound
+	}
+	students, err := s.roomStudents(ctx, room)
+	if err != nil {
+		return nil, err
+	}
+	engagement, err := s.engagementByResource(ctx, bson.M{"resource_id": resourceObjectID})
+	if err != nil {
+		return nil, err
+	}
+
+	summary, rows := summarizeEngagement(&resources[0], students, engagement[resourceObjectID], cutoff.Before)
+	detail := &ResourceEngagementDetail{
+		ResourceEngagementSummary: summary,
+		Cutoff:                    cutoff,
+		Students:                  rows,
+		NotOpened:                 []EngagementStudent{},
+	}
+	for _, row := range rows {
+		if !row.Opened {
+			detail.NotOpened = append(detail.NotOpened, EngagementStudent{UserID: row.UserID, Name: row.Name})
+		}
+	}
+	return detail, nil
+}
+
+// GetStudentEngagement reports one student's engagement with each teacher resource (room owner)
+func (s *ResourceEngagementService) GetStudentEngagement(roomID, studentID, userID, before, exam string) (*StudentEngagementReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	room, err := s.ownedRoom(ctx, roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	cutoff, err := engagementCutoff(room, before, exam)
+	if err != nil {
+		return nil, err
+	}
+	students, err := s.roomStudents(ctx, room)
+	if err != nil {
+		return nil, err
+	}
+	var student *EngagementStudent
+	for i := range students {
+		if students[i].UserID == studentID {
+			student = &students[i]
+		}
+	}
+	if student == nil {
+		return nil, ErrStudentNotFound
+	}
+	studentObjectID, _ := primitive.ObjectIDFromHex(studentID)
+
+	resources, err := s.teacherResources(ctx, bson.M{"room_id": room.ID})
+	if err != nil {
+		return nil, err
+	}
+	engagement, err := s.engagementByResource(ctx, bson.M{"room_id": room.ID, "user_id": studentObjectID})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StudentEngagementReport{
+		EngagementStudent: *student,
+		RoomID:            roomID,
+		Cutoff:            cutoff,
+		ResourcesTotal:    len(resources),
+		Resources:         []StudentResourceEngagement{},
+	}
+	for i := range resources {
+		_, rows := summarizeEngagement(&resources[i], []EngagementStudent{*student}, engagement[resources[i].ID], cutoff.Before)
+		if rows[0].Opened {
+			report.ResourcesOpened++
+		}
+		report.Resources = append(report.Resources, rows[0])
+	}
+	return report, nil
+}
+
+func (s *ResourceEngagementService) ownedRoom(ctx context.Context, roomID, userID string) (*models.Room, error) {
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	var room models.Room
+	if err := s.db.Collection("rooms").FindOne(ctx, bson.M{"_id": roomObjectID}).Decode(&room); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRoomNotFound
+		}
+		return nil, err
+	}
+	if room.OwnerID.Hex() != userID {
+		return nil, ErrAnalyticsPermission
+	}
+	return &room, nil
+}
+
+// roomStudents lists a room's active members other than its owner, by name
+func (s *ResourceEngagementService) roomStudents(ctx context.Context, room *models.Room) ([]EngagementStudent, error) {
+	cursor, err := s.db.Collection("room_members").Find(ctx, bson.M{
+		"room_id":   room.ID,
+		"is_active": true,
+		"user_id":   bson.M{"$ne": room.OwnerID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var members []models.RoomMember
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(members))
+	for i, member := range members {
+		ids[i] = member.UserID
+	}
+
+	names := make(map[primitive.ObjectID]string, len(ids))
+	if len(ids) > 0 {
+		cursor, err = s.db.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+			options.Find().SetProjection(bson.M{"name": 1}))
+		if err != nil {
+			return nil, err
+		}
+		var users []models.User
+		if err := cursor.All(ctx, &users); err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			names[user.ID] = user.Name
+		}
+	}
+
+	students := make([]EngagementStudent, 0, len(ids))
+	for _, id := range ids {
+		students = append(students, EngagementStudent{UserID: id.Hex(), Name: names[id]})
+	}
+	sort.SliceStable(students, func(i, j int) bool {
+		return strings.ToLower(students[i].Name) < strings.ToLower(students[j].Name)
+	})
+	return students, nil
+}
+
+func (s *ResourceEngagementService) teacherResources(ctx context.Context, filter bson.M) ([]models.Resource, error) {
+	filter["uploader_type"] = "teacher"
+	cursor, err := s.db.Collection("resources").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var resources []models.Resource
+	if err := cursor.All(ctx, &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// engagementByResource loads engagement records, grouped by resource and keyed by user
+func (s *ResourceEngagementService) engagementByResource(ctx context.Context, filter bson.M) (map[primitive.ObjectID]map[string]*models.ResourceEngagement, error) {
+	cursor, err := s.db.Collection("resource_engagement").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var records []models.ResourceEngagement
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	grouped := make(map[primitive.ObjectID]map[string]*models.ResourceEngagement)
+	for i := range records {
+		record := &records[i]
+		if grouped[record.ResourceID] == nil {
+			grouped[record.ResourceID] = make(map[string]*models.ResourceEngagement)
+		}
+		grouped[record.ResourceID][record.UserID.Hex()] = record
+	}
+	return grouped, nil
+}
+
+// engagementCutoff resolves a report's cutoff from an RFC 3339 time or the
+// title of one of the room's exams; both empty means no cutoff
+func engagementCutoff(room *models.Room, before, exam string) (EngagementCutoff, error) {
+	var cutoff EngagementCutoff
+	if exam = strings.TrimSpace(exam); exam != "" {
+		for i := range room.ExamDates {
+			if strings.EqualFold(room.ExamDates[i].Title, exam) {
+				cutoff.Exam = &room.ExamDates[i]
+				cutoff.Before = &room.ExamDates[i].Date
+				return cutoff, nil
+			}
+		}
+		return cutoff, ErrExamNotFound
+	}
+	if before != "" {
+		at, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			return cutoff, errors.New("before must be an RFC 3339 time")
+		}
+		cutoff.Before = &at
+	}
+	return cutoff, nil
+}
+
+// summarizeEngagement combines the engagement records of a resource (keyed by
+// user ID) into a summary and one row per student; a student opened the
+// resource if they did so before the cutoff
+func summarizeEngagement(resource *models.Resource, students []EngagementStudent, records map[string]*models.ResourceEngagement, before *time.Time) (ResourceEngagementSummary, []StudentResourceEngagement) {
+	summary := ResourceEngagementSummary{
+		ResourceID:    resource.ID.Hex(),
+		Name:          resource.Name,
+		Category:      resource.Category,
+		FileType:      resource.FileType,
+		CreatedAt:     resource.CreatedAt,
+		StudentsTotal: len(students),
+	}
+	rows := make([]StudentResourceEngagement, 0, len(students))
+	watchTotal, watchers := 0.0, 0
+
+	for _, student := range students {
+		row := StudentResourceEngagement{
+			UserID:       student.UserID,
+			Name:         student.Name,
+			ResourceID:   summary.ResourceID,
+			ResourceName: resource.Name,
+		}
+		if record := records[student.UserID]; record != nil {
+			row.Views = record.Views
+			row.Downloads = record.Downloads
+			row.FirstOpenedAt = record.FirstOpenedAt
+			row.LastOpenedAt = record.LastOpenedAt
+			row.Completed = record.Completed
+			row.Opened = record.FirstOpenedAt != nil && (before == nil || record.FirstOpenedAt.Before(*before))
+			if record.DurationSeconds > 0 {
+				row.WatchedPercent = math.Round(math.Min(record.WatchedSeconds/record.DurationSeconds, 1)*1000) / 10
+				watchTotal += row.WatchedPercent
+				watchers++
+			}
+
+			summary.Views += record.Views
+			summary.Downloads += record.Downloads
+			if record.Completed {
+				summary.Completed++
+			}
+			if record.LastOpenedAt != nil && (summary.LastOpenedAt == nil || record.LastOpenedAt.After(*summary.LastOpenedAt)) {
+				summary.LastOpenedAt = record.LastOpenedAt
+			}
+		}
+		if row.Opened {
+			summary.StudentsOpened++
+		}
+		rows = append(rows, row)
+	}
+
+	if len(students) > 0 {
+		summary.OpenRate = math.Round(float64(summary.StudentsOpened)/float64(len(students))*1000) / 10
+	}
+	if watchers > 0 {
+		summary.AvgWatchPercent = math.Round(watchTotal/float64(watchers)*10) / 10
+	}
+	// Students who haven't opened it first, as those are who teachers follow up with
+	sort.SliceStable(rows, func(i, j int) bool {
+		return !rows[i].Opened && rows[j].Opened
+	})
+	return summary, rows
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSummarizeEngagement(t *testing.T) {
+	resource := &models.Resource{ID: primitive.NewObjectID(), Name: "Lecture 1", Category: "video"}
+	students := []EngagementStudent{{UserID: "a", Name: "Ada"}, {UserID: "b", Name: "Ben"}, {UserID: "c", Name: "Cy"}}
+
+	exam := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+	early, late := exam.Add(-48*time.Hour), exam.Add(time.Hour)
+	records := map[string]*models.ResourceEngagement{
+		"a": {Views: 2, Downloads: 1, FirstOpenedAt: &early, LastOpenedAt: &late, WatchedSeconds: 570, DurationSeconds: 600, Completed: true},
+		"b": {Views: 1, FirstOpenedAt: &late, LastOpenedAt: &late, WatchedSeconds: 150, DurationSeconds: 600},
+	}
+
+	summary, rows := summarizeEngagement(resource, students, records, nil)
+	if summary.StudentsOpened != 2 || summary.StudentsTotal != 3 || summary.OpenRate != 66.7 {
+		t.Errorf("Expected 2 of 3 students (66.7%%) to have opened it, got %+v", summary)
+	}
+	if summary.Views != 3 || summary.Downloads != 1 || summary.Completed != 1 {
+		t.Errorf("Expected 3 views, 1 download and 1 completion, got %+v", summary)
+	}
+	if summary.AvgWatchPercent != 60 {
+		t.Errorf("Expected an average watch of 60%%, got %v", summary.AvgWatchPercent)
+	}
+	if summary.LastOpenedAt == nil || !summary.LastOpenedAt.Equal(late) {
+		t.Errorf("Expected last opened at %v, got %v", late, summary.LastOpenedAt)
+	}
+	if rows[0].UserID != "c" || rows[0].Opened {
+		t.Errorf("Expected the student who never opened it first, got %+v", rows[0])
+	}
+
+	// Before the exam only Ada had opened it
+	summary, rows = summarizeEngagement(resource, students, records, &exam)
+	if summary.StudentsOpened != 1 {
+		t.Errorf("Expected 1 student to have opened it before the exam, got %d", summary.StudentsOpened)
+	}
+	var notOpened []string
+	for _, row := range rows {
+		if !row.Opened {
+			notOpened = append(notOpened, row.Name)
+		}
+	}
+	if len(notOpened) != 2 || notOpened[0] != "Ben" || notOpened[1] != "Cy" {
+		t.Errorf("Expected Ben and Cy not to have opened it before the exam, got %v", notOpened)
+	}
+}
+
+func TestEngagementCutoff(t *testing.T) {
+	midterm := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	room := &models.Room{ExamDates: []models.ExamDate{{Title: "Midterm Exam", Date: midterm}}}
+
+	cutoff, err := engagementCutoff(room, "", "midterm exam")
+	if err != nil || cutoff.Before == nil || !cutoff.Before.Equal(midterm) || cutoff.Exam == nil {
+		t.Errorf("Expected the midterm as cutoff, got %+v, %v", cutoff, err)
+	}
+	if _, err := engagementCutoff(room, "", "Final"); err != ErrExamNotFound {
+		t.Errorf("Expected ErrExamNotFound, got %v", err)
+	}
+	cutoff, err = engagementCutoff(room, "2026-02-01T00:00:00Z", "")
+	if err != nil || cutoff.Before == nil || cutoff.Before.Month() != time.February {
+		t.Errorf("Expected a February cutoff, got %+v, %v", cutoff, err)
+	}
+	if _, err := engagementCutoff(room, "next week", ""); err == nil {
+		t.Error("Expected an error for an unparseable time")
+	}
+	if cutoff, err := engagementCutoff(room, "", ""); err != nil || cutoff.Before != nil {
+		t.Errorf("Expected no cutoff, got %+v, %v", cutoff, err)
+	}
+}
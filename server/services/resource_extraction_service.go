@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"buddy-server/database"
+	"buddy-server/models"
+	"buddy-server/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// extractionQueueSize bounds pending extraction jobs; further requests are picked up by the sweep
+	extractionQueueSize = 256
+	// extractionSweepBatch is how many resources the sweep checks per query
+	extractionSweepBatch = 500
+	// maxExtractionAttempts is how many times a failing file is extracted before the sweep gives up on it
+	maxExtractionAttempts = 5
+	// maxStoredTextBytes bounds the text stored for one resource, well inside
+	// MongoDB's 16MB document limit
+	maxStoredTextBytes = 8 << 20
+	// extractionRetryBackoff is the wait before the first retry of a failed extraction; it doubles per attempt
+	extractionRetryBackoff = 5 * time.Minute
+)
+
+// ResourceExtractionService extracts and stores the text of uploaded resource files
+type ResourceExtractionService struct {
+	db    *database.DB
+	store storage.Storage
+	queue chan primitive.ObjectID
+}
+
+// NewResourceExtractionService creates a new resource extraction service
+func NewResourceExtractionService(db *database.DB, store storage.Storage) *ResourceExtractionService {
+	return &ResourceExtractionService{
+		db:    db,
+		store: store,
+		queue: make(chan primitive.ObjectID, extractionQueueSize),
+	}
+}
+
+// Start runs the extraction workers and a periodic sweep that (re-)extracts
+// resources whose text is missing, was taken from a different file, or failed
+// and is due a retry
+func (s *ResourceExtractionService) Start(workers int, sweepInterval time.Duration) {
+	if workers < 1 {
+		workers = 1
+	}
+	s.ensureIndexes()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for resourceID := range s.queue {
+				if err := s.extractByID(resourceID); err != nil {
+					log.Printf("resource extraction: %s: %v", resourceID.Hex(), err)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		s.sweep()
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.sweep()
+		}
+	}()
+}
+
+// ensureIndexes creates the text index resource searches use. Its language is
+// "none": words are matched as written, without stemming or stop words, since
+// documents are in any language and every search word must match.
+func (s *ResourceExtractionService) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("resource_texts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "room_id", Value: 1}, {Key: "sections.text", Value: "text"}},
+		Options: options.Index().SetName("room_text").SetDefaultLanguage("none"),
+	})
+	if err != nil {
+		log.Printf("resource extraction: creating indexes: %v", err)
+	}
+}
+
+// Enqueue schedules a resource for extraction without blocking the caller
+func (s *ResourceExtractionService) Enqueue(resourceID primitive.ObjectID) {
+	select {
+	case s.queue <- resourceID:
+	default:
+		// Queue full: the next sweep will pick the resource up
+	}
+}
+
+// sweep enqueues resources that have no stored text, stale text, or a failed
+// extraction due a retry. Resources are read in pages by ID so neither
+// collection is ever loaded whole.
+func (s *ResourceExtractionService) sweep() {
+	after := primitive.NilObjectID
+	for {
+		last, err := s.sweepBatch(after)
+		if err != nil {
+			log.Printf("resource extraction sweep: %v", err)
+			return
+		}
+		if last.IsZero() {
+			return
+		}
+		after = last
+	}
+}
+
+// sweepBatch checks the next extractionSweepBatch resources after the given ID
+// and returns the last ID checked, or the zero ID when none are left
+func (s *ResourceExtractionService) sweepBatch(after primitive.ObjectID) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("resources").Find(ctx, bson.M{"_id": bson.M{"$gt": after}},
+		options.Find().
+			SetProjection(bson.M{"_id": 1, "file_url": 1}).
+			SetSort(bson.M{"_id": 1}).
+			SetLimit(extractionSweepBatch))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	var resources []models.Resource
+	if err := cursor.All(ctx, &resources); err != nil {
+		return primitive.NilObjectID, err
+	}
+	if len(resources) == 0 {
+		return primitive.NilObjectID, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(resources))
+	for i, resource := range resources {
+		ids[i] = resource.ID
+	}
+	cursor, err = s.db.Collection("resource_texts").Find(ctx, bson.M{"resource_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"resource_id": 1, "source_url": 1, "status": 1, "retry_at": 1}))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	var texts []models.ResourceText
+	if err := cursor.All(ctx, &texts); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	stored := make(map[primitive.ObjectID]models.ResourceText, len(texts))
+	for _, text := range texts {
+		stored[text.ResourceID] = text
+	}
+
+	now := time.Now()
+	for _, resource := range resources {
+		text, ok := stored[resource.ID]
+		switch {
+		case !ok, text.SourceURL != resource.FileURL:
+			s.Enqueue(resource.ID)
+		case text.Status == "failed" && text.RetryAt != nil && !text.RetryAt.After(now):
+			s.Enqueue(resource.ID)
+		}
+	}
+	return resources[len(resources)-1].ID, nil
+}
+
+// extractByID loads a resource and extracts its text
+func (s *ResourceExtractionService) extractByID(resourceID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var resource models.Resource
+	err := s.db.Collection("resources").FindOne(ctx, bson.M{"_id": resourceID}).Decode(&resource)
+	if err == mongo.ErrNoDocuments {
+		// Deleted while queued
+		return s.DeleteResourceText(resourceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ExtractResource(&resource)
+	return err
+}
+
+// ExtractResource extracts the text of a resource's file and stores it.
+// Extraction failures are recorded on the stored document rather than returned.
+func (s *ResourceExtractionService) ExtractResource(resource *models.Resource) (*models.ResourceText, error) {
+	text := &models.ResourceText{
+		ResourceID:  resource.ID,
+		RoomID:      resource.RoomID,
+		SourceURL:   resource.FileURL,
+		Sections:    []models.TextSection{},
+		ExtractedAt: time.Now(),
+	}
+
+	key, ok := storage.KeyFromURL(resource.FileURL)
+	if !ok {
+		text.Status = "unsupported"
+		text.Error = "file is not stored on this server"
+	} else if doc, err := ExtractDocumentFromStorage(s.store, key, resource.FileType); errors.Is(err, ErrUnsupportedFileType) {
+		text.Status = "unsupported"
+		text.Error = err.Error()
+	} else if err != nil {
+		text.Status = "failed"
+		text.Error = err.Error()
+		s.scheduleRetry(text)
+	} else {
+		text.Status = "ready"
+		text.PageCount = doc.PageCount
+		text.Sections, text.CharCount, text.Truncated = storedSections(doc.Sections, maxStoredTextBytes)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := s.db.Collection("resource_texts")
+	result := collection.FindOneAndReplace(ctx,
+		bson.M{"resource_id": resource.ID},
+		text,
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After),
+	)
+	if err := result.Decode(text); err != nil {
+		return nil, err
+	}
+
+	return text, nil
+}
+
+// storedSections drops empty sections and cuts the text off once limit bytes
+// have been kept, reporting the bytes kept and whether anything was dropped
+func storedSections(sections []models.TextSection, limit int) ([]models.TextSection, int, bool) {
+	kept := []models.TextSection{}
+	size := 0
+	for _, section := range sections {
+		if section.Text == "" {
+			continue
+		}
+		if size+len(section.Text) > limit {
+			cut := limit - size
+			for cut > 0 && !utf8.RuneStart(section.Text[cut]) {
+				cut--
+			}
+			if cut > 0 {
+				section.Text = section.Text[:cut]
+				kept = append(kept, section)
+				size += cut
+			}
+			return kept, size, true
+		}
+		kept = append(kept, section)
+		size += len(section.Text)
+	}
+	return kept, size, false
+}
+
+// scheduleRetry counts a failed extraction against the previous failures of the
+// same file and sets when the sweep tries again, backing off exponentially
+// until maxExtractionAttempts is reached
+func (s *ResourceExtractionService) scheduleRetry(text *models.ResourceText) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var previous models.ResourceText
+	err := s.db.Collection("resource_texts").FindOne(ctx, bson.M{"resource_id": text.ResourceID},
+		options.FindOne().SetProjection(bson.M{"source_url": 1, "status": 1, "attempts": 1})).Decode(&previous)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("resource extraction: %s: %v", text.ResourceID.Hex(), err)
+	}
+
+	text.Attempts = 1
+	if err == nil && previous.Status == "failed" && previous.SourceURL == text.SourceURL {
+		text.Attempts = previous.Attempts + 1
+	}
+	if text.Attempts < maxExtractionAttempts {
+		retryAt := text.ExtractedAt.Add(extractionRetryBackoff << (text.Attempts - 1))
+		text.RetryAt = &retryAt
+	}
+}
+
+// GetResourceText returns the stored text for a resource, extracting it
+// first if it is missing or the resource's file has changed since
+func (s *ResourceExtractionService) GetResourceText(resource *models.Resource) (*models.ResourceText, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var text models.ResourceText
+	err := s.db.Collection("resource_texts").FindOne(ctx, bson.M{"resource_id": resource.ID}).Decode(&text)
+	if err == nil && text.SourceURL == resource.FileURL {
+		return &text, nil
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	return s.ExtractResource(resource)
+}
+
+// GetResourceTexts returns up-to-date text for several resources, keyed by resource ID
+func (s *ResourceExtractionService) GetResourceTexts(resources []models.Resource) map[primitive.ObjectID]*models.ResourceText {
+	texts := make(map[primitive.ObjectID]*models.ResourceText, len(resources))
+	for i := range resources {
+		text, err := s.GetResourceText(&resources[i])
+		if err != nil {
+			log.Printf("resource extraction: %s: %v", resources[i].ID.Hex(), err)
+			continue
+		}
+		texts[resources[i].ID] = text
+	}
+	return texts
+}
+
+// DeleteResourceText removes the stored text of a deleted resource
+func (s *ResourceExtractionService) DeleteResourceText(resourceID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("resource_texts").DeleteOne(ctx, bson.M{"resource_id": resourceID})
+	return err
+}
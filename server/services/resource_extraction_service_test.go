@@ -0,0 +1,33 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"buddy-server/models"
+)
+
+func TestStoredSections(t *testing.T) {
+	sections := []models.TextSection{
+		{Label: "Page 1", Text: "abcd"},
+		{Label: "Page 2", Text: ""},
+		{Label: "Page 3", Text: "éé"}, // 4 bytes
+		{Label: "Page 4", Text: "more"},
+	}
+
+	kept, size, truncated := storedSections(sections, 100)
+	if len(kept) != 3 || size != 12 || truncated {
+		t.Errorf("Expected all text kept without empty sections, got %d sections, %d bytes, truncated=%v", len(kept), size, truncated)
+	}
+
+	// The cut lands inside the second "é" and must back off to a rune boundary
+	kept, size, truncated = storedSections(sections, 7)
+	if len(kept) != 2 || kept[1].Text != "é" || size != 6 || !truncated {
+		t.Errorf("Expected text cut at a rune boundary, got %+v, %d bytes, truncated=%v", kept, size, truncated)
+	}
+	for _, section := range kept {
+		if strings.ContainsRune(section.Text, '�') {
+			t.Errorf("Section %s was cut mid-rune: %q", section.Label, section.Text)
+		}
+	}
+}
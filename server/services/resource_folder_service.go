@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxFolderDepth limits how deeply folders can be nested
+const maxFolderDepth = 8
+
+var (
+	// ErrRoomAccessDenied is returned when a user is neither the owner nor a member of a room
+	ErrRoomAccessDenied = errors.New("you are not a member of this room")
+	// ErrFolderNotFound is returned for an unknown folder or one in another room
+	ErrFolderNotFound = errors.New("folder not found")
+	// ErrFolderPermission is returned when someone other than the room owner manages folders
+	ErrFolderPermission = errors.New("only the room owner can manage folders")
+	// ErrFolderExists is returned when a sibling folder already has the name
+	ErrFolderExists = errors.New("a folder with this name already exists here")
+	// ErrFolderCycle is returned when a folder would be moved into itself or a subfolder
+	ErrFolderCycle = errors.New("a folder cannot be moved into itself or one of its subfolders")
+	// ErrFolderTooDeep is returned when nesting would exceed maxFolderDepth
+	ErrFolderTooDeep = fmt.Errorf("folders can be nested at most %d levels deep", maxFolderDepth)
+)
+
+// ResourceFolderService manages the folder hierarchy of room resources
+type ResourceFolderService struct {
+	db *database.DB
+}
+
+// NewResourceFolderService creates a new resource folder service
+func NewResourceFolderService(db *database.DB) *ResourceFolderService {
+	return &ResourceFolderService{db: db}
+}
+
+// roomAccess reports whether a user owns a room or is an active member of it
+func roomAccess(ctx context.Context, db *database.DB, roomID, userID primitive.ObjectID) (isOwner, isMember bool, err error) {
+	var room struct {
+		OwnerID primitive.ObjectID `bson:"owner_id"`
+	}
+	err = db.Collection("rooms").FindOne(ctx, bson.M{"_id": roomID},
+		options.FindOne().SetProjection(bson.M{"owner_id": 1})).Decode(&room)
+	if err == mongo.ErrNoDocuments {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if room.OwnerID == userID {
+		return true, true, nil
+	}
+
+	count, err := db.Collection("room_members").CountDocuments(ctx, bson.M{
+		"room_id":   roomID,
+		"user_id":   userID,
+		"is_active": true,
+	})
+	return false, count > 0, err
+}
+
+// folderInRoom resolves a folder ID for a resource in a room; "" means the top level
+func folderInRoom(ctx context.Context, db *database.DB, roomID primitive.ObjectID, folderID string) (*primitive.ObjectID, error) {
+	if folderID == "" {
+		return nil, nil
+	}
+	objectID, err := primitive.ObjectIDFromHex(folderID)
+	if err != nil {
+		return nil, ErrFolderNotFound
+	}
+	count, err := db.Collection("resource_folders").CountDocuments(ctx, bson.M{"_id": objectID, "room_id": roomID})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrFolderNotFound
+	}
+	return &objectID, nil
+}
+
+// GetFolders lists all folders of a room; clients build the tree from parent_id
+func (s *ResourceFolderService) GetFolders(roomID, userID string) ([]models.ResourceFolder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if _, isMember, err := roomAccess(ctx, s.db, roomObjectID, userObjectID); err != nil {
+		return nil, err
+	} else if !isMember {
+		return nil, ErrRoomAccessDenied
+	}
+
+	return s.roomFolders(ctx, roomObjectID)
+}
+
+func (s *ResourceFolderService) roomFolders(ctx context.Context, roomID primitive.ObjectID) ([]models.ResourceFolder, error) {
+	cursor, err := s.db.Collection("resource_folders").Find(ctx, bson.M{"room_id": roomID},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	folders := []models.ResourceFolder{}
+	if err := cursor.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// CreateFolder creates a folder in a room, inside parentID when given
+func (s *ResourceFolderService) CreateFolder(roomID, userID, name, parentID string) (*models.ResourceFolder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	userObjectID, err := s.checkOwner(ctx, roomObjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("folder name is required")
+	}
+
+	folders, err := s.roomFolders(ctx, roomObjectID)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := parseParentID(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFolderPlacement(folders, primitive.NilObjectID, parent, name); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	folder := &models.ResourceFolder{
+		RoomID:    roomObjectID,
+		ParentID:  parent,
+		Name:      name,
+		CreatedBy: userObjectID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	result, err := s.db.Collection("resource_folders").InsertOne(ctx, folder)
+	if err != nil {
+		return nil, err
+	}
+	folder.ID = result.InsertedID.(primitive.ObjectID)
+	return folder, nil
+}
+
+// UpdateFolder renames a folder and/or moves it; a nil argument leaves that part
+// unchanged and an empty parentID moves the folder to the top level
+func (s *ResourceFolderService) UpdateFolder(roomID, folderID, userID string, name, parentID *string) (*models.ResourceFolder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	if _, err := s.checkOwner(ctx, roomObjectID, userID); err != nil {
+		return nil, err
+	}
+
+	folders, err := s.roomFolders(ctx, roomObjectID)
+	if err != nil {
+		return nil, err
+	}
+	folder := findFolder(folders, folderID)
+	if folder == nil {
+		return nil, ErrFolderNotFound
+	}
+
+	if name != nil {
+		if trimmed := strings.TrimSpace(*name); trimmed != "" {
+			folder.Name = trimmed
+		} else {
+			return nil, errors.New("folder name is required")
+		}
+	}
+	if parentID != nil {
+		if folder.ParentID, err = parseParentID(*parentID); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateFolderPlacement(folders, folder.ID, folder.ParentID, folder.Name); err != nil {
+		return nil, err
+	}
+
+	folder.UpdatedAt = time.Now()
+	_, err = s.db.Collection("resource_folders").UpdateOne(ctx, bson.M{"_id": folder.ID}, bson.M{"$set": bson.M{
+		"name":       folder.Name,
+		"parent_id":  folder.ParentID,
+		"updated_at": folder.UpdatedAt,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// DeleteFolder removes a folder; its subfolders and resources move to its parent
+func (s *ResourceFolderService) DeleteFolder(roomID, folderID, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return errors.New("invalid room ID")
+	}
+	if _, err := s.checkOwner(ctx, roomObjectID, userID); err != nil {
+		return err
+	}
+
+	folders, err := s.roomFolders(ctx, roomObjectID)
+	if err != nil {
+		return err
+	}
+	folder := findFolder(folders, folderID)
+	if folder == nil {
+		return ErrFolderNotFound
+	}
+
+	// Children keep their names; a clash with a folder in the parent is
+	// acceptable here, it is only prevented when users create or move folders
+	moveTo := bson.M{"$unset": bson.M{"parent_id": ""}}
+	moveResourcesTo := bson.M{"$unset": bson.M{"folder_id": ""}}
+	if folder.ParentID != nil {
+		moveTo = bson.M{"$set": bson.M{"parent_id": folder.ParentID}}
+		moveResourcesTo = bson.M{"$set": bson.M{"folder_id": folder.ParentID}}
+	}
+	if _, err := s.db.Collection("resource_folders").UpdateMany(ctx, bson.M{"parent_id": folder.ID}, moveTo); err != nil {
+		return err
+	}
+	if _, err := s.db.Collection("resources").UpdateMany(ctx, bson.M{"folder_id": folder.ID}, moveResourcesTo); err != nil {
+		return err
+	}
+	_, err = s.db.Collection("resource_folders").DeleteOne(ctx, bson.M{"_id": folder.ID})
+	return err
+}
+
+// checkOwner returns the user's ID if they own the room
+func (s *ResourceFolderService) checkOwner(ctx context.Context, roomID primitive.ObjectID, userID string) (primitive.ObjectID, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, errors.New("invalid user ID")
+	}
+	isOwner, _, err := roomAccess(ctx, s.db, roomID, userObjectID)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if !isOwner {
+		return primitive.NilObjectID, ErrFolderPermission
+	}
+	return userObjectID, nil
+}
+
+func parseParentID(parentID string) (*primitive.ObjectID, error) {
+	if parentID == "" {
+		return nil, nil
+	}
+	objectID, err := primitive.ObjectIDFromHex(parentID)
+	if err != nil {
+		return nil, ErrFolderNotFound
+	}
+	return &objectID, nil
+}
+
+func findFolder(folders []models.ResourceFolder, folderID string) *models.ResourceFolder {
+	for i := range folders {
+		if folders[i].ID.Hex() == folderID {
+			return &folders[i]
+		}
+	}
+	return nil
+}
+
+// validateFolderPlacement checks that a folder (NilObjectID for a new one) can
+// be placed under parent with the given name: the parent exists, the folder
+// isn't its own ancestor, the tree stays shallow and sibling names are unique
+func validateFolderPlacement(folders []models.ResourceFolder, id primitive.ObjectID, parent *primitive.ObjectID, name string) error {
+	parents := make(map[primitive.ObjectID]*primitive.ObjectID, len(folders))
+	for _, folder := range folders {
+		parents[folder.ID] = folder.ParentID
+	}
+
+	depth := 1
+	for ancestor := parent; ancestor != nil; ancestor = parents[*ancestor] {
+		if _, ok := parents[*ancestor]; !ok {
+			return ErrFolderNotFound
+		}
+		if *ancestor == id {
+			return ErrFolderCycle
+		}
+		depth++
+		if depth > maxFolderDepth {
+			return ErrFolderTooDeep
+		}
+	}
+	depth += subtreeHeight(folders, id)
+	if depth > maxFolderDepth {
+		return ErrFolderTooDeep
+	}
+
+	for _, folder := range folders {
+		if folder.ID != id && sameParent(folder.ParentID, parent) && strings.EqualFold(folder.Name, name) {
+			return ErrFolderExists
+		}
+	}
+	return nil
+}
+
+// subtreeHeight counts the levels of folders below id
+func subtreeHeight(folders []models.ResourceFolder, id primitive.ObjectID) int {
+	if id.IsZero() {
+		return 0
+	}
+	height := 0
+	for _, folder := range folders {
+		if folder.ParentID != nil && *folder.ParentID == id && folder.ID != id {
+			height = max(height, 1+subtreeHeight(folders, folder.ID))
+		}
+	}
+	return height
+}
+
+func sameParent(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+	"buddy-server/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// previewQueueSize bounds pending preview jobs; further requests are picked up by the sweep
+const previewQueueSize = 256
+
+// ResourcePreviewService generates thumbnails, page counts and durations for
+// resource files in the background and stores them on the resource
+type ResourcePreviewService struct {
+	db    *database.DB
+	store storage.Storage
+	queue chan primitive.ObjectID
+}
+
+// NewResourcePreviewService creates a new resource preview service
+func NewResourcePreviewService(db *database.DB, store storage.Storage) *ResourcePreviewService {
+	return &ResourcePreviewService{
+		db:    db,
+		store: store,
+		queue: make(chan primitive.ObjectID, previewQueueSize),
+	}
+}
+
+// Start runs the preview workers and a periodic sweep that (re-)generates
+// previews that are missing or were made from a different file
+func (s *ResourcePreviewService) Start(workers int, sweepInterval time.Duration) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for resourceID := range s.queue {
+				if err := s.generateByID(resourceID); err != nil {
+					log.Printf("resource preview: %s: %v", resourceID.Hex(), err)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		s.sweep()
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.sweep()
+		}
+	}()
+}
+
+// Enqueue schedules a resource for preview generation without blocking the caller
+func (s *ResourcePreviewService) Enqueue(resourceID primitive.ObjectID) {
+	select {
+	case s.queue <- resourceID:
+	default:
+		// Queue full: the next sweep will pick the resource up
+	}
+}
+
+// sweep enqueues resources without a preview of their current file
+func (s *ResourcePreviewService) sweep() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("resources").Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"_id": 1, "file_url": 1, "preview.source_url": 1}))
+	if err != nil {
+		log.Printf("resource preview sweep: %v", err)
+		return
+	}
+	var resources []models.Resource
+	if err := cursor.All(ctx, &resources); err != nil {
+		log.Printf("resource preview sweep: %v", err)
+		return
+	}
+
+	for _, resource := range resources {
+		if resource.Preview == nil || resource.Preview.SourceURL != resource.FileURL {
+			s.Enqueue(resource.ID)
+		}
+	}
+}
+
+// generateByID loads a resource and generates its preview if it is out of date
+func (s *ResourcePreviewService) generateByID(resourceID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var resource models.Resource
+	err := s.db.Collection("resources").FindOne(ctx, bson.M{"_id": resourceID}).Decode(&resource)
+	if err == mongo.ErrNoDocuments {
+		return nil // Deleted while queued
+	}
+	if err != nil {
+		return err
+	}
+	if resource.Preview != nil && resource.Preview.SourceURL == resource.FileURL {
+		return nil // Already generated, e.g. enqueued twice
+	}
+
+	_, err = s.GeneratePreview(&resource)
+	return err
+}
+
+// GeneratePreview generates and stores the preview of a resource's current file.
+// Generation failures are recorded on the preview rather than returned. Files
+// still in quarantine are skipped; they are enqueued again once released.
+func (s *ResourcePreviewService) GeneratePreview(resource *models.Resource) (*models.ResourcePreview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	preview := &models.ResourcePreview{
+		SourceURL:   resource.FileURL,
+		GeneratedAt: time.Now(),
+	}
+
+	key, ok := storage.KeyFromURL(resource.FileURL)
+	if !ok {
+		preview.Status = "unsupported"
+		preview.Error = "file is not stored on this server"
+		return preview, s.savePreview(ctx, resource, preview)
+	}
+
+	file, info, err := storage.Open(ctx, s.store, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, err := GeneratePreview(file, info.Size, resource.FileType, path.Ext(key))
+	file.Close()
+
+	switch {
+	case errors.Is(err, ErrNoPreview):
+		preview.Status = "unsupported"
+		preview.Error = err.Error()
+	case err != nil:
+		preview.Status = "failed"
+		preview.Error = err.Error()
+	default:
+		preview.Status = "ready"
+		preview.Width = result.Width
+		preview.Height = result.Height
+		preview.PageCount = result.PageCount
+		preview.Duration = result.Duration
+		preview.Text = result.Text
+		if result.Thumbnail != nil {
+			thumbnailKey := previewThumbnailKey(key)
+			if err := s.store.Put(ctx, thumbnailKey, bytes.NewReader(result.Thumbnail), int64(len(result.Thumbnail)), "image/jpeg"); err != nil {
+				return nil, err
+			}
+			preview.ThumbnailKey = thumbnailKey
+		}
+	}
+
+	return preview, s.savePreview(ctx, resource, preview)
+}
+
+// savePreview stores a preview unless the resource's file changed meanwhile
+func (s *ResourcePreviewService) savePreview(ctx context.Context, resource *models.Resource, preview *models.ResourcePreview) error {
+	_, err := s.db.Collection("resources").UpdateOne(ctx,
+		bson.M{"_id": resource.ID, "file_url": preview.SourceURL},
+		bson.M{"$set": bson.M{"preview": preview}},
+	)
+	return err
+}
+
+// previewThumbnailKey returns where the thumbnail of an uploaded file is stored.
+// Uploads are deduplicated by content, so resources sharing a file share it too.
+func previewThumbnailKey(key string) string {
+	return "previews/" + strings.TrimSuffix(key, path.Ext(key)) + ".jpg"
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultResourcePageSize and maxResourcePageSize bound resource listings
+	defaultResourcePageSize = 50
+	maxResourcePageSize     = 200
+	// maxSearchTerms limits how many words of a search are used
+	maxSearchTerms = 8
+	// maxSearchCandidates bounds how many matches are ranked by relevance
+	maxSearchCandidates = 1000
+	// maxTextMatches bounds the extracted texts one search word is looked up in
+	maxTextMatches = 5000
+	// maxResourceTags and maxTagLength bound the tags of a resource
+	maxResourceTags = 20
+	maxTagLength    = 32
+)
+
+// ResourceQuery filters, sorts and pages a room's resources
+type ResourceQuery struct {
+	UploaderType string   // "teacher" or "student"; empty for both
+	Category     string   // Empty or "all" for every category
+	FolderID     string   // Only this folder; "root" for resources outside folders, empty for all
+	Tags         []string // Resources must have all of these tags
+	Subject      string   // Syllabus topic, matched against Subjects and the legacy Subject
+	Search       string   // Words matched against names, descriptions, tags, topics and extracted text
+	Sort         string   // "newest" (default), "oldest", "updated", "name", "size" or "relevance" (default when searching)
+	Limit        int
+	Offset       int
+}
+
+// ResourcePage is one page of a resource listing
+type ResourcePage struct {
+	Resources []models.Resource `json:"resources"`
+	Total     int64             `json:"total"` // For relevance searches, at most the maxSearchCandidates that are ranked
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+}
+
+// ResourceTagCount is a tag used in a room and how many resources have it
+type ResourceTagCount struct {
+	Tag   string `json:"tag" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+// resourceSorts maps sort names to Mongo sort orders; _id keeps pages stable
+var resourceSorts = map[string]bson.D{
+	"newest":  {{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+	"oldest":  {{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}},
+	"updated": {{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}},
+	"name":    {{Key: "name", Value: 1}, {Key: "_id", Value: 1}},
+	"size":    {{Key: "file_size", Value: -1}, {Key: "_id", Value: -1}},
+}
+
+// NormalizeTags lowercases, trims and deduplicates tags, dropping empty ones
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if len(tag) > maxTagLength {
+			tag = strings.TrimSpace(strings.ToValidUTF8(tag[:maxTagLength], ""))
+		}
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+		if len(normalized) == maxResourceTags {
+			break
+		}
+	}
+	return normalized
+}
+
+// searchTerms splits a search into distinct lowercase words
+func searchTerms(search string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(strings.ToLower(search)) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// resourceVisibility limits a listing to resources a room member may see: teacher
+// resources, their own, and student resources that are public or shared with them
+func resourceVisibility(userID primitive.ObjectID) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"uploader_type": "teacher"},
+		{"uploader_id": userID},
+		{"shared_with": userID},
+		{"is_public": true},
+	}}
+}
+
+// SearchResources lists the room resources a member may see, filtered, sorted and paged
+func (s *ResourceService) SearchResources(roomID, userID string, query ResourceQuery) (*ResourcePage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	isOwner, isMember, err := roomAccess(ctx, s.db, roomObjectID, userObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrRoomAccessDenied
+	}
+
+	conditions := []bson.M{{"room_id": roomObjectID}}
+	if !isOwner {
+		conditions = append(conditions, resourceVisibility(userObjectID))
+	}
+	if query.UploaderType != "" {
+		conditions = append(conditions, bson.M{"uploader_type": query.UploaderType})
+	}
+	if query.Category != "" && query.Category != "all" {
+		conditions = append(conditions, bson.M{"category": query.Category})
+	}
+	switch query.FolderID {
+	case "":
+	case "root":
+		conditions = append(conditions, bson.M{"folder_id": nil})
+	default:
+		folderID, err := primitive.ObjectIDFromHex(query.FolderID)
+		if err != nil {
+			return nil, ErrFolderNotFound
+		}
+		conditions = append(conditions, bson.M{"folder_id": folderID})
+	}
+	if tags := NormalizeTags(query.Tags); len(tags) > 0 {
+		conditions = append(conditions, bson.M{"tags": bson.M{"$all": tags}})
+	}
+	if subject := strings.TrimSpace(query.Subject); subject != "" {
+		conditions = append(conditions, bson.M{"$or": []bson.M{{"subjects": subject}, {"subject": subject}}})
+	}
+
+	// Every word must match somewhere: the resource's own fields or its
+	// extracted text, which is searched as whole words with the text index
+	terms := searchTerms(query.Search)
+	textMatches := make(map[string]map[primitive.ObjectID]bool, len(terms))
+	for _, term := range terms {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(term), Options: "i"}
+		ids, err := s.textMatches(ctx, roomObjectID, term)
+		if err != nil {
+			return nil, err
+		}
+		textMatches[term] = make(map[primitive.ObjectID]bool, len(ids))
+		for _, id := range ids {
+			textMatches[term][id] = true
+		}
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"name": pattern},
+			{"description": pattern},
+			{"tags": pattern},
+			{"subjects": pattern},
+			{"subject": pattern},
+			{"_id": bson.M{"$in": ids}},
+		}})
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultResourcePageSize
+	}
+	limit = min(limit, maxResourcePageSize)
+	offset := max(query.Offset, 0)
+
+	sortName := query.Sort
+	if sortName == "" && len(terms) > 0 {
+		sortName = "relevance"
+	}
+	filter := bson.M{"$and": conditions}
+	collection := s.db.Collection("resources")
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &ResourcePage{Resources: []models.Resource{}, Total: total, Limit: limit, Offset: offset}
+
+	if sortName == "relevance" && len(terms) > 0 {
+		// Ranked in memory; searches narrow rooms down to few candidates
+		cursor, err := collection.Find(ctx, filter, options.Find().
+			SetSort(resourceSorts["newest"]).
+			SetLimit(maxSearchCandidates))
+		if err != nil {
+			return nil, err
+		}
+		var candidates []models.Resource
+		if err := cursor.All(ctx, &candidates); err != nil {
+			return nil, err
+		}
+		rankResources(candidates, terms, textMatches)
+		// Only the candidates are ranked, so pages end with them
+		if ranked := int64(len(candidates)); ranked < total {
+			page.Total = ranked
+		}
+		if offset < len(candidates) {
+			page.Resources = candidates[offset:min(len(candidates), offset+limit)]
+		}
+		return page, nil
+	}
+
+	sortOrder, ok := resourceSorts[sortName]
+	if !ok {
+		sortOrder = resourceSorts["newest"]
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().
+		SetSort(sortOrder).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &page.Resources); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// textMatches returns the room's resources whose extracted text contains a
+// word, using the resource_texts text index
+func (s *ResourceService) textMatches(ctx context.Context, roomID primitive.ObjectID, term string) ([]primitive.ObjectID, error) {
+	// Quoted, so the word is matched as it is rather than read as an operator
+	phrase := `"` + strings.ReplaceAll(term, `"`, " ") + `"`
+	cursor, err := s.db.Collection("resource_texts").Find(ctx, bson.M{
+		"room_id": roomID,
+		"$text":   bson.M{"$search": phrase},
+		"status":  "ready",
+	}, options.Find().SetProjection(bson.M{"resource_id": 1}).SetLimit(maxTextMatches))
+	if err != nil {
+		return nil, err
+	}
+	var texts []struct {
+		ResourceID primitive.ObjectID `bson:"resource_id"`
+	}
+	if err := cursor.All(ctx, &texts); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(texts))
+	for i, text := range texts {
+		ids[i] = text.ResourceID
+	}
+	return ids, nil
+}
+
+// rankResources orders search results by how well they match, keeping the
+// existing (newest first) order between equal scores
+func rankResources(resources []models.Resource, terms []string, textMatches map[string]map[primitive.ObjectID]bool) {
+	scores := make(map[primitive.ObjectID]int, len(resources))
+	for i := range resources {
+		scores[resources[i].ID] = resourceSearchScore(&resources[i], terms, textMatches)
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return scores[resources[i].ID] > scores[resources[j].ID]
+	})
+}
+
+// resourceSearchScore weighs where each search word was found: the name counts
+// most, then tags and topics, then the description and the document text
+func resourceSearchScore(resource *models.Resource, terms []string, textMatches map[string]map[primitive.ObjectID]bool) int {
+	name := strings.ToLower(resource.Name)
+	description := strings.ToLower(resource.Description)
+	score := 0
+	for _, term := range terms {
+		switch {
+		case strings.HasPrefix(name, term):
+			score += 6
+		case strings.Contains(name, term):
+			score += 4
+		}
+		for _, tag := range resource.Tags {
+			if tag == term {
+				score += 3
+			} else if strings.Contains(tag, term) {
+				score += 2
+			}
+		}
+		if resourceHasSubject(resource, term) {
+			score += 2
+		}
+		if strings.Contains(description, term) {
+			score++
+		}
+		if textMatches[term][resource.ID] {
+			score++
+		}
+	}
+	return score
+}
+
+func resourceHasSubject(resource *models.Resource, term string) bool {
+	if resource.Subject != "" && strings.Contains(strings.ToLower(resource.Subject), term) {
+		return true
+	}
+	for _, subject := range resource.Subjects {
+		if strings.Contains(strings.ToLower(subject), term) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetResourceTags lists the tags used on the room resources a member may see, most used first
+func (s *ResourceService) GetResourceTags(roomID, userID string) ([]ResourceTagCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	isOwner, isMember, err := roomAccess(ctx, s.db, roomObjectID, userObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrRoomAccessDenied
+	}
+
+	match := bson.M{"room_id": roomObjectID}
+	if !isOwner {
+		match = bson.M{"$and": []bson.M{match, resourceVisibility(userObjectID)}}
+	}
+	cursor, err := s.db.Collection("resources").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := []ResourceTagCount{}
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" Exam  Prep ", "exam prep", "", "Biology", strings.Repeat("x", 40)})
+	want := []string{"exam prep", "biology", strings.Repeat("x", maxTagLength)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	many := make([]string, 30)
+	for i := range many {
+		many[i] = string(rune('a'+i%26)) + string(rune('a'+i/26))
+	}
+	if n := len(NormalizeTags(many)); n != maxResourceTags {
+		t.Errorf("Expected at most %d tags, got %d", maxResourceTags, n)
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	got := searchTerms("  Mitosis mitosis  CELL ")
+	if !reflect.DeepEqual(got, []string{"mitosis", "cell"}) {
+		t.Errorf("Expected distinct lowercase words, got %q", got)
+	}
+}
+
+func TestRankResources(t *testing.T) {
+	inText := models.Resource{ID: primitive.NewObjectID(), Name: "Week 3 handout"}
+	inDescription := models.Resource{ID: primitive.NewObjectID(), Name: "Slides", Description: "Covers mitosis"}
+	inName := models.Resource{ID: primitive.NewObjectID(), Name: "Mitosis summary"}
+	tagged := models.Resource{ID: primitive.NewObjectID(), Name: "Worksheet", Tags: []string{"mitosis"}}
+
+	resources := []models.Resource{inText, inDescription, inName, tagged}
+	textMatches := map[string]map[primitive.ObjectID]bool{"mitosis": {inText.ID: true, inName.ID: true}}
+	rankResources(resources, []string{"mitosis"}, textMatches)
+
+	var order []string
+	for _, r := range resources {
+		order = append(order, r.Name)
+	}
+	want := []string{"Mitosis summary", "Worksheet", "Week 3 handout", "Slides"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected %q, got %q", want, order)
+	}
+}
+
+func TestValidateFolderPlacement(t *testing.T) {
+	id := func() *primitive.ObjectID { v := primitive.NewObjectID(); return &v }
+	top, child, grandchild := id(), id(), id()
+	folders := []models.ResourceFolder{
+		{ID: *top, Name: "Unit 1"},
+		{ID: *child, ParentID: top, Name: "Labs"},
+		{ID: *grandchild, ParentID: child, Name: "Week 1"},
+	}
+
+	if err := validateFolderPlacement(folders, primitive.NilObjectID, top, "Readings"); err != nil {
+		t.Errorf("Expected new folder to be allowed, got %v", err)
+	}
+	if err := validateFolderPlacement(folders, primitive.NilObjectID, top, "labs"); err != ErrFolderExists {
+		t.Errorf("Expected ErrFolderExists for a sibling with the same name, got %v", err)
+	}
+	if err := validateFolderPlacement(folders, primitive.NilObjectID, nil, "Labs"); err != nil {
+		t.Errorf("Expected the same name at another level to be allowed, got %v", err)
+	}
+	if err := validateFolderPlacement(folders, *top, grandchild, "Unit 1"); err != ErrFolderCycle {
+		t.Errorf("Expected ErrFolderCycle moving a folder into its subfolder, got %v", err)
+	}
+	if err := validateFolderPlacement(folders, primitive.NilObjectID, id(), "Lost"); err != ErrFolderNotFound {
+		t.Errorf("Expected ErrFolderNotFound for an unknown parent, got %v", err)
+	}
+
+	// Build a chain as deep as allowed; one more level is refused
+	chain := []models.ResourceFolder{{ID: primitive.NewObjectID(), Name: "1"}}
+	for i := 2; i <= maxFolderDepth; i++ {
+		parent := chain[len(chain)-1].ID
+		chain = append(chain, models.ResourceFolder{ID: primitive.NewObjectID(), ParentID: &parent, Name: "n"})
+	}
+	deepest := chain[len(chain)-1].ID
+	if err := validateFolderPlacement(chain, primitive.NilObjectID, &deepest, "too deep"); err != ErrFolderTooDeep {
+		t.Errorf("Expected ErrFolderTooDeep, got %v", err)
+	}
+	// Moving a folder with children under another folder counts the children too
+	if err := validateFolderPlacement(append(chain, folders...), *top, &chain[maxFolderDepth-3].ID, "Unit 1"); err != ErrFolderTooDeep {
+		t.Errorf("Expected ErrFolderTooDeep when the moved subtree would be too deep, got %v", err)
+	}
+}
@@ -3,30 +3,82 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
 	"time"
 
 	"buddy-server/database"
 	"buddy-server/models"
+	"buddy-server/storage"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrFileAccessDenied is returned when a user may not download a file
+var ErrFileAccessDenied = errors.New("you don't have access to this file")
+
+var (
+	// ErrResourceNotFound is returned when a resource doesn't exist or the user may not change it
+	ErrResourceNotFound = errors.New("resource not found or you don't have permission")
+	// ErrVersionNotFound is returned for an unknown resource version
+	ErrVersionNotFound = errors.New("version not found")
+	// ErrVersionUnchanged is returned when a new version has the current file
+	ErrVersionUnchanged = errors.New("this file is already the current version")
+	// ErrVersionConflict is returned when another version was added concurrently
+	ErrVersionConflict = errors.New("the resource was updated by someone else; reload and try again")
+)
+
 // ResourceService handles resource-related operations
 type ResourceService struct {
-	db *database.DB
+	db                *database.DB
+	store             storage.Storage
+	extractionService *ResourceExtractionService
+	previewService    *ResourcePreviewService
+	indexService      *RoomIndexService
+	uploadService     *UploadService
+	notifications     *NotificationService
 }
 
 // NewResourceService creates a new resource service
-func NewResourceService(db *database.DB) *ResourceService {
-	return &ResourceService{db: db}
+func NewResourceService(db *database.DB, store storage.Storage) *ResourceService {
+	return &ResourceService{db: db, store: store}
 }
 
-// CreateResource creates a new resource
+// SetExtractionService sets the text extraction service (optional)
+func (s *ResourceService) SetExtractionService(extractionService *ResourceExtractionService) {
+	s.extractionService = extractionService
+}
+
+// SetPreviewService sets the preview service (optional)
+func (s *ResourceService) SetPreviewService(previewService *ResourcePreviewService) {
+	s.previewService = previewService
+}
+
+// SetIndexService sets the room AI retrieval index (optional)
+func (s *ResourceService) SetIndexService(indexService *RoomIndexService) {
+	s.indexService = indexService
+}
+
+// SetUploadService sets the upload service, used to free files of deleted resources (optional)
+func (s *ResourceService) SetUploadService(uploadService *UploadService) {
+	s.uploadService = uploadService
+}
+
+// SetNotificationService sets the service used to tell members about updated resources (optional)
+func (s *ResourceService) SetNotificationService(notifications *NotificationService) {
+	s.notifications = notifications
+}
+
+// CreateResource creates a new resource, optionally tagged and inside a room folder
 func (s *ResourceService) CreateResource(
 	roomID, uploaderID, name, description, fileURL, fileType string,
 	fileSize int64, category, subject string, subjects []string, uploaderType string,
+	tags []string, folderID string,
 ) (*models.Resource, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -41,6 +93,17 @@ func (s *ResourceService) CreateResource(
 		return nil, errors.New("invalid uploader ID")
 	}
 
+	if _, isMember, err := roomAccess(ctx, s.db, roomObjectID, uploaderObjectID); err != nil {
+		return nil, err
+	} else if !isMember {
+		return nil, ErrRoomAccessDenied
+	}
+
+	folder, err := folderInRoom(ctx, s.db, roomObjectID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
 	resource := &models.Resource{
 		RoomID:       roomObjectID,
 		UploaderID:   uploaderObjectID,
@@ -55,6 +118,9 @@ func (s *ResourceService) CreateResource(
 		Subjects:     subjects,  // Related syllabus topics/subjects (multiple)
 		IsPublic:     uploaderType == "teacher", // Teacher resources are always public in room
 		SharedWith:   []primitive.ObjectID{},
+		FolderID:     folder,
+		Tags:         NormalizeTags(tags),
+		Version:      1,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
@@ -66,115 +132,608 @@ func (s *ResourceService) CreateResource(
 	}
 
 	resource.ID = result.InsertedID.(primitive.ObjectID)
+
+	if _, err := s.db.Collection("resource_versions").InsertOne(ctx, resourceVersion(resource, 1, uploaderObjectID, "")); err != nil {
+		return nil, err
+	}
+
+	// Extract file text in the background for room AI training
+	if s.extractionService != nil {
+		s.extractionService.Enqueue(resource.ID)
+	}
+	// Thumbnails and media metadata, also in the background
+	if s.previewService != nil {
+		s.previewService.Enqueue(resource.ID)
+	}
+
 	return resource, nil
 }
 
-// GetResources gets all resources for a room, filtered by uploader type
-func (s *ResourceService) GetResources(roomID, uploaderType, category string, userID *string) ([]models.Resource, error) {
+// GetResource gets a single resource by ID
+func (s *ResourceService) GetResource(resourceID string) (*models.Resource, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	objectID, err := primitive.ObjectIDFromHex(resourceID)
 	if err != nil {
-		return nil, errors.New("invalid room ID")
+		return nil, errors.New("invalid resource ID")
+	}
+
+	collection := s.db.Collection("resources")
+	var resource models.Resource
+	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resource, nil
+}
+
+// DeleteResource deletes a resource (only by uploader)
+func (s *ResourceService) DeleteResource(resourceID, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resourceObjectID, err := primitive.ObjectIDFromHex(resourceID)
+	if err != nil {
+		return errors.New("invalid resource ID")
 	}
 
-	filter := bson.M{"room_id": roomObjectID}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
 
-	// Filter by uploader type (teacher or student)
-	if uploaderType != "" {
-		filter["uploader_type"] = uploaderType
+	collection := s.db.Collection("resources")
+	var resource models.Resource
+	err = collection.FindOneAndDelete(ctx, bson.M{
+		"_id":        resourceObjectID,
+		"uploader_id": userObjectID,
+	}).Decode(&resource)
+	if err == mongo.ErrNoDocuments {
+		return ErrResourceNotFound
+	}
+	if err != nil {
+		return err
 	}
 
-	// Filter by category
-	if category != "" && category != "all" {
-		filter["category"] = category
+	// Files of earlier versions go with the resource
+	fileURLs := map[string]bool{resource.FileURL: true}
+	if versions, err := s.listVersions(ctx, resourceObjectID); err == nil {
+		for _, version := range versions {
+			fileURLs[version.FileURL] = true
+		}
 	}
+	s.db.Collection("resource_versions").DeleteMany(ctx, bson.M{"resource_id": resourceObjectID})
 
-	// For student resources, filter based on visibility
-	if uploaderType == "student" && userID != nil {
-		userObjectID, err := primitive.ObjectIDFromHex(*userID)
-		if err == nil {
-			// Show resources that are:
-			// 1. Owned by the user
-			// 2. Shared with the user (in shared_with array)
-			// 3. Public (is_public = true)
-			filter["$or"] = []bson.M{
-				{"uploader_id": userObjectID},
-				{"shared_with": userObjectID},
-				{"is_public": true},
-			}
+	if s.extractionService != nil {
+		s.extractionService.DeleteResourceText(resourceObjectID)
+	}
+	if s.indexService != nil {
+		s.indexService.RemoveResource(resourceObjectID)
+	}
+	if s.uploadService != nil {
+		for fileURL := range fileURLs {
+			s.uploadService.RemoveIfUnused(fileURL)
 		}
 	}
 
+	return nil
+}
+
+// resourceVersion snapshots a resource's current file as a version record
+func resourceVersion(resource *models.Resource, version int, createdBy primitive.ObjectID, note string) *models.ResourceVersion {
+	return &models.ResourceVersion{
+		ResourceID: resource.ID,
+		RoomID:     resource.RoomID,
+		Version:    version,
+		FileURL:    resource.FileURL,
+		FileType:   resource.FileType,
+		FileSize:   resource.FileSize,
+		ChangeNote: note,
+		CreatedBy:  createdBy,
+		CreatedAt:  resource.UpdatedAt,
+	}
+}
+
+// currentVersion is the resource's version number; resources created before
+// versioning are on version 1
+func currentVersion(resource *models.Resource) int {
+	if resource.Version < 1 {
+		return 1
+	}
+	return resource.Version
+}
+
+// AddResourceVersion replaces a resource's file with a new version (only by
+// uploader). Members are notified and the room AI's copy of the old text is
+// invalidated.
+func (s *ResourceService) AddResourceVersion(resourceID, userID, fileURL, fileType string, fileSize int64, note string) (*models.Resource, error) {
+	return s.addVersion(resourceID, userID, &models.ResourceVersion{
+		FileURL:    fileURL,
+		FileType:   fileType,
+		FileSize:   fileSize,
+		ChangeNote: note,
+	})
+}
+
+// RestoreResourceVersion makes an earlier version's file current again by adding
+// it as a new version, so the history is kept
+func (s *ResourceService) RestoreResourceVersion(resourceID, userID string, version int) (*models.Resource, error) {
+	old, err := s.GetResourceVersion(resourceID, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.addVersion(resourceID, userID, &models.ResourceVersion{
+		FileURL:      old.FileURL,
+		FileType:     old.FileType,
+		FileSize:     old.FileSize,
+		ChangeNote:   fmt.Sprintf("Restored version %d", version),
+		RestoredFrom: version,
+	})
+}
+
+func (s *ResourceService) addVersion(resourceID, userID string, next *models.ResourceVersion) (*models.Resource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resourceObjectID, err := primitive.ObjectIDFromHex(resourceID)
+	if err != nil {
+		return nil, errors.New("invalid resource ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
 	collection := s.db.Collection("resources")
-	cursor, err := collection.Find(
-		ctx,
-		filter,
-		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	var resource models.Resource
+	err = collection.FindOne(ctx, bson.M{"_id": resourceObjectID, "uploader_id": userObjectID}).Decode(&resource)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if next.FileURL == resource.FileURL {
+		return nil, ErrVersionUnchanged
+	}
+
+	versions := s.db.Collection("resource_versions")
+	current := currentVersion(&resource)
+	if err := s.ensureVersionRecorded(ctx, &resource); err != nil {
+		return nil, err
+	}
+
+	// Only move forward from the version we read, so concurrent updates can't
+	// both claim the same number
+	versionFilter := bson.M{"_id": resourceObjectID, "version": resource.Version}
+	if resource.Version == 0 {
+		versionFilter["version"] = bson.M{"$exists": false}
+	}
+	now := time.Now()
+	result, err := collection.UpdateOne(ctx, versionFilter, bson.M{"$set": bson.M{
+		"file_url":   next.FileURL,
+		"file_type":  next.FileType,
+		"file_size":  next.FileSize,
+		"version":    current + 1,
+		"updated_at": now,
+	}, "$unset": bson.M{"preview": ""}})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrVersionConflict
+	}
+
+	next.ResourceID = resource.ID
+	next.RoomID = resource.RoomID
+	next.Version = current + 1
+	next.CreatedBy = userObjectID
+	next.CreatedAt = now
+	if _, err := versions.InsertOne(ctx, next); err != nil {
+		return nil, err
+	}
+
+	resource.FileURL = next.FileURL
+	resource.FileType = next.FileType
+	resource.FileSize = next.FileSize
+	resource.Version = next.Version
+	resource.UpdatedAt = now
+
+	resource.Preview = nil
+
+	s.invalidateRoomAI(&resource)
+	if s.previewService != nil {
+		s.previewService.Enqueue(resource.ID)
+	}
+	if s.notifications != nil {
+		body := fmt.Sprintf("%s was updated to version %d", resource.Name, resource.Version)
+		if next.ChangeNote != "" {
+			body += ": " + next.ChangeNote
+		}
+		if err := s.notifications.NotifyResourceAudience(&resource, userObjectID, models.Notification{
+			Type:  "resource_updated",
+			Title: "Resource updated",
+			Body:  body,
+		}); err != nil {
+			log.Printf("resource %s: failed to notify members: %v", resource.ID.Hex(), err)
+		}
+	}
+	return &resource, nil
+}
+
+// ensureVersionRecorded adds the current file to the history of resources
+// created before versioning
+func (s *ResourceService) ensureVersionRecorded(ctx context.Context, resource *models.Resource) error {
+	version := resourceVersion(resource, currentVersion(resource), resource.UploaderID, "")
+	_, err := s.db.Collection("resource_versions").UpdateOne(ctx,
+		bson.M{"resource_id": resource.ID, "version": version.Version},
+		bson.M{"$setOnInsert": version},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// invalidateRoomAI drops the extracted text and index chunks of a resource's
+// previous file, re-extracts the new one and flags rooms trained on it as stale
+func (s *ResourceService) invalidateRoomAI(resource *models.Resource) {
+	if s.extractionService != nil {
+		s.extractionService.DeleteResourceText(resource.ID)
+		s.extractionService.Enqueue(resource.ID)
+	}
+	if s.indexService != nil {
+		s.indexService.RemoveResource(resource.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("room_ai_contexts").UpdateOne(ctx,
+		bson.M{"room_id": resource.RoomID, "trained_resource_ids": resource.ID},
+		bson.M{"$addToSet": bson.M{"stale_resource_ids": resource.ID}},
 	)
+	if err != nil {
+		log.Printf("resource %s: failed to mark room AI stale: %v", resource.ID.Hex(), err)
+	}
+}
+
+// GetResourceVersions lists a resource's versions, newest first
+func (s *ResourceService) GetResourceVersions(resourceID string) ([]models.ResourceVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resource, err := s.GetResource(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.listVersions(ctx, resource.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		// Created before versioning and never updated
+		versions = append(versions, *resourceVersion(resource, currentVersion(resource), resource.UploaderID, ""))
+	}
+	return versions, nil
+}
+
+func (s *ResourceService) listVersions(ctx context.Context, resourceID primitive.ObjectID) ([]models.ResourceVersion, error) {
+	cursor, err := s.db.Collection("resource_versions").Find(ctx,
+		bson.M{"resource_id": resourceID},
+		options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var resources []models.Resource
-	if err = cursor.All(ctx, &resources); err != nil {
+	versions := []models.ResourceVersion{}
+	if err := cursor.All(ctx, &versions); err != nil {
 		return nil, err
 	}
+	return versions, nil
+}
 
-	return resources, nil
+// GetResourceVersion gets one version of a resource
+func (s *ResourceService) GetResourceVersion(resourceID string, version int) (*models.ResourceVersion, error) {
+	versions, err := s.GetResourceVersions(resourceID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].Version == version {
+			return &versions[i], nil
+		}
+	}
+	return nil, ErrVersionNotFound
 }
 
-// GetResource gets a single resource by ID
-func (s *ResourceService) GetResource(resourceID string) (*models.Resource, error) {
+// CanAccessResource reports whether a user may see a resource and its file.
+// The uploader and the room owner always can; other room members can see teacher
+// resources and student resources that are public or shared with them.
+func (s *ResourceService) CanAccessResource(resource *models.Resource, userID string) (bool, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, errors.New("invalid user ID")
+	}
+	if resource.UploaderID == userObjectID {
+		return true, nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	objectID, err := primitive.ObjectIDFromHex(resourceID)
+	var room struct {
+		OwnerID primitive.ObjectID `bson:"owner_id"`
+	}
+	err = s.db.Collection("rooms").FindOne(ctx, bson.M{"_id": resource.RoomID},
+		options.FindOne().SetProjection(bson.M{"owner_id": 1})).Decode(&room)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
 	if err != nil {
-		return nil, errors.New("invalid resource ID")
+		return false, err
+	}
+	if room.OwnerID == userObjectID {
+		return true, nil
 	}
 
-	collection := s.db.Collection("resources")
-	var resource models.Resource
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&resource)
+	count, err := s.db.Collection("room_members").CountDocuments(ctx, bson.M{
+		"room_id":   resource.RoomID,
+		"user_id":   userObjectID,
+		"is_active": true,
+	})
+	if err != nil || count == 0 {
+		return false, err
+	}
+
+	if resource.UploaderType == "student" && !resource.IsPublic {
+		for _, id := range resource.SharedWith {
+			if id == userObjectID {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// GetResourceForUser gets a resource, checking that the user may access it
+func (s *ResourceService) GetResourceForUser(resourceID, userID string) (*models.Resource, error) {
+	resource, err := s.GetResource(resourceID)
 	if err != nil {
 		return nil, err
 	}
+	allowed, err := s.CanAccessResource(resource, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrFileAccessDenied
+	}
+	return resource, nil
+}
 
-	return &resource, nil
+// ResourceFileKey returns the storage key of a resource's file
+func (s *ResourceService) ResourceFileKey(resource *models.Resource) (string, error) {
+	key, ok := storage.KeyFromURL(resource.FileURL)
+	if !ok {
+		return "", errors.New("resource file is not stored on this server")
+	}
+	return key, nil
 }
 
-// DeleteResource deletes a resource (only by uploader)
-func (s *ResourceService) DeleteResource(resourceID, userID string) error {
+// OpenResourceFile opens a resource's file for streaming, including range requests
+func (s *ResourceService) OpenResourceFile(ctx context.Context, resource *models.Resource) (io.ReadSeekCloser, *storage.ObjectInfo, error) {
+	key, err := s.ResourceFileKey(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage.Open(ctx, s.store, key)
+}
+
+// AuthorizeFileURL checks that a user may download a room file referenced by URL
+// (e.g. a message attachment or submission file) and returns its storage key.
+// Files belonging to a resource follow the resource's sharing rules, message
+// attachments are available to room members, and submission files to the
+// submitter, their parent, their peer reviewers and the room owner. Any other room file is only
+// available to its uploader and the room owner.
+func (s *ResourceService) AuthorizeFileURL(fileURL, userID string) (string, error) {
+	key, ok := storage.KeyFromURL(fileURL)
+	if !ok {
+		return "", errors.New("file is not stored on this server")
+	}
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 || parts[0] != "rooms" {
+		return "", ErrFileAccessDenied
+	}
+	roomObjectID, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return "", ErrFileAccessDenied
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", errors.New("invalid user ID")
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	resourceObjectID, err := primitive.ObjectIDFromHex(resourceID)
+	// Deduplicated uploads can back several resources; any of them may grant access
+	resources, err := s.fileResources(ctx, roomObjectID, fileURL)
 	if err != nil {
-		return errors.New("invalid resource ID")
+		return "", err
+	}
+	var allowed bool
+	for i := 0; i < len(resources) && !allowed; i++ {
+		if allowed, err = s.CanAccessResource(&resources[i], userID); err != nil {
+			return "", err
+		}
 	}
+	if len(resources) == 0 {
+		if allowed, err = s.canAccessRoomFile(ctx, roomObjectID, fileURL, userObjectID); err != nil {
+			return "", err
+		}
+	}
+	if !allowed {
+		return "", ErrFileAccessDenied
+	}
+	return key, nil
+}
 
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
+// fileResources returns every resource a room file belongs to, including
+// through their earlier versions; none when it isn't a resource file
+func (s *ResourceService) fileResources(ctx context.Context, roomID primitive.ObjectID, fileURL string) ([]models.Resource, error) {
+	cursor, err := s.db.Collection("resource_versions").Find(ctx, bson.M{"room_id": roomID, "file_url": fileURL},
+		options.Find().SetProjection(bson.M{"resource_id": 1}))
 	if err != nil {
-		return errors.New("invalid user ID")
+		return nil, err
+	}
+	var versions []models.ResourceVersion
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	versionOf := make([]primitive.ObjectID, len(versions))
+	for i, version := range versions {
+		versionOf[i] = version.ResourceID
 	}
 
-	collection := s.db.Collection("resources")
-	result, err := collection.DeleteOne(ctx, bson.M{
-		"_id":        resourceObjectID,
-		"uploader_id": userObjectID,
+	cursor, err = s.db.Collection("resources").Find(ctx, bson.M{
+		"room_id": roomID,
+		"$or":     bson.A{bson.M{"file_url": fileURL}, bson.M{"_id": bson.M{"$in": versionOf}}},
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var resources []models.Resource
+	if err := cursor.All(ctx, &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
 
-	if result.DeletedCount == 0 {
-		return errors.New("resource not found or you don't have permission")
+// canAccessRoomFile reports whether a user may download a room file that isn't a resource file
+func (s *ResourceService) canAccessRoomFile(ctx context.Context, roomID primitive.ObjectID, fileURL string, userID primitive.ObjectID) (bool, error) {
+	isOwner, isMember, err := roomAccess(ctx, s.db, roomID, userID)
+	if err != nil || isOwner {
+		return isOwner, err
 	}
 
-	return nil
+	// Submission files: the submitter, their parent and their peer reviewers
+	cursor, err := s.db.Collection("submissions").Find(ctx, bson.M{"file_url": fileURL},
+		options.Find().SetProjection(bson.M{"student_id": 1}))
+	if err != nil {
+		return false, err
+	}
+	var submissions []models.Submission
+	if err := cursor.All(ctx, &submissions); err != nil {
+		return false, err
+	}
+	for _, submission := range submissions {
+		if submission.StudentID == userID {
+			return true, nil
+		}
+		count, err := s.db.Collection("users").CountDocuments(ctx, bson.M{"_id": submission.StudentID, "parent_id": userID})
+		if err != nil || count > 0 {
+			return count > 0, err
+		}
+		count, err = s.db.Collection("peer_reviews").CountDocuments(ctx, bson.M{"submission_id": submission.ID, "reviewer_id": userID})
+		if err != nil || count > 0 {
+			return count > 0, err
+		}
+	}
+	if len(submissions) > 0 {
+		return false, nil
+	}
+
+	// Message attachments are posted to the whole room
+	if isMember {
+		count, err := s.db.Collection("messages").CountDocuments(ctx, bson.M{"room_id": roomID, "file_url": fileURL})
+		if err != nil || count > 0 {
+			return count > 0, err
+		}
+	}
+
+	// Anything else is only for whoever uploaded it
+	return uploadedBy(ctx, s.db, roomID, fileURL, userID)
+}
+
+// ResourceUpdate holds the resource details to change; nil fields are left as they are
+type ResourceUpdate struct {
+	Name        *string
+	Description *string
+	Subjects    *[]string
+	Tags        *[]string
+	FolderID    *string // "" moves the resource out of its folder
+}
+
+// UpdateResource changes a resource's details, tags or folder. The uploader and
+// the room owner may organize a resource.
+func (s *ResourceService) UpdateResource(resourceID, userID string, update ResourceUpdate) (*models.Resource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resource, err := s.GetResource(resourceID)
+	if err != nil {
+		return nil, ErrResourceNotFound
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if resource.UploaderID != userObjectID {
+		isOwner, _, err := roomAccess(ctx, s.db, resource.RoomID, userObjectID)
+		if err != nil {
+			return nil, err
+		}
+		if !isOwner {
+			return nil, ErrResourceNotFound
+		}
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, errors.New("name is required")
+		}
+		set["name"], resource.Name = name, name
+	}
+	if update.Description != nil {
+		set["description"], resource.Description = *update.Description, *update.Description
+	}
+	if update.Subjects != nil {
+		set["subjects"], resource.Subjects = *update.Subjects, *update.Subjects
+	}
+	if update.Tags != nil {
+		tags := NormalizeTags(*update.Tags)
+		set["tags"], resource.Tags = tags, tags
+	}
+	if update.FolderID != nil {
+		folder, err := folderInRoom(ctx, s.db, resource.RoomID, *update.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		if folder == nil {
+			unset["folder_id"] = ""
+		} else {
+			set["folder_id"] = folder
+		}
+		resource.FolderID = folder
+	}
+
+	resource.UpdatedAt = time.Now()
+	set["updated_at"] = resource.UpdatedAt
+	change := bson.M{"$set": set}
+	if len(unset) > 0 {
+		change["$unset"] = unset
+	}
+	if _, err := s.db.Collection("resources").UpdateOne(ctx, bson.M{"_id": resource.ID}, change); err != nil {
+		return nil, err
+	}
+	return resource, nil
 }
 
 // ShareResource shares a student resource with specific users
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"buddy-server/models"
+)
+
+const (
+	chunkWords           = 180 // target words per chunk
+	chunkOverlapWords    = 30  // words repeated between consecutive chunks of a section
+	defaultRetrievalTopK = 5
+	citationExcerptChars = 240
+)
+
+// textChunk is a passage of one section, before embedding
+type textChunk struct {
+	Section string
+	Text    string
+}
+
+// chunkSections splits sections into overlapping word windows. Chunks never
+// span sections, so every chunk can be cited by its page, slide or heading.
+func chunkSections(sections []models.TextSection, size, overlap int) []textChunk {
+	if overlap >= size {
+		overlap = size / 4
+	}
+
+	var chunks []textChunk
+	for _, section := range sections {
+		words := strings.Fields(section.Text)
+		for start := 0; start < len(words); start += size - overlap {
+			end := start + size
+			if end > len(words) {
+				end = len(words)
+			}
+			chunks = append(chunks, textChunk{Section: section.Label, Text: strings.Join(words[start:end], " ")})
+			if end == len(words) {
+				break
+			}
+		}
+	}
+	return chunks
+}
+
+// scoredChunk is a retrieved chunk with its similarity to the query
+type scoredChunk struct {
+	Chunk models.ResourceChunk
+	Score float64
+}
+
+// rankChunks returns the k chunks most similar to the query vector, best first
+func rankChunks(query []float32, chunks []models.ResourceChunk, k int) []scoredChunk {
+	scored := make([]scoredChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		scored = append(scored, scoredChunk{Chunk: chunk, Score: cosineSimilarity(query, chunk.Embedding)})
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+	return scored
+}
+
+// formatSources renders retrieved chunks as numbered sources for the prompt
+func formatSources(chunks []scoredChunk) string {
+	var sb strings.Builder
+	for i, sc := range chunks {
+		fmt.Fprintf(&sb, "[%d] %s", i+1, sc.Chunk.ResourceName)
+		if sc.Chunk.Section != "" {
+			fmt.Fprintf(&sb, " (%s)", sc.Chunk.Section)
+		}
+		sb.WriteString("\n")
+		sb.WriteString(sc.Chunk.Text)
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+var citationMarkerPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// buildCitations turns retrieved chunks into citations. When the answer uses
+// [n] markers, only the cited sources are returned.
+func buildCitations(answer string, chunks []scoredChunk) []models.Citation {
+	cited := map[int]bool{}
+	for _, m := range citationMarkerPattern.FindAllStringSubmatch(answer, -1) {
+		for _, part := range strings.Split(m[1], ",") {
+			if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && n >= 1 && n <= len(chunks) {
+				cited[n] = true
+			}
+		}
+	}
+
+	citations := []models.Citation{}
+	for i, sc := range chunks {
+		if len(cited) > 0 && !cited[i+1] {
+			continue
+		}
+		excerpt := sc.Chunk.Text
+		if len(excerpt) > citationExcerptChars {
+			excerpt = strings.ToValidUTF8(excerpt[:citationExcerptChars], "") + "…"
+		}
+		citations = append(citations, models.Citation{
+			Number:       i + 1,
+			ResourceID:   sc.Chunk.ResourceID,
+			ResourceName: sc.Chunk.ResourceName,
+			Section:      sc.Chunk.Section,
+			Excerpt:      excerpt,
+			Score:        sc.Score,
+		})
+	}
+	return citations
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChunkSectionsOverlap(t *testing.T) {
+	words := make([]string, 25)
+	for i := range words {
+		words[i] = "w" + string(rune('a'+i))
+	}
+	sections := []models.TextSection{
+		{Label: "Page 1", Text: strings.Join(words, " ")},
+		{Label: "Page 2", Text: "short page"},
+		{Label: "Page 3", Text: "   "},
+	}
+
+	chunks := chunkSections(sections, 10, 2)
+	// 25 words with a stride of 8: [0,10) [8,18) [16,25), then Page 2
+	if len(chunks) != 4 {
+		t.Fatalf("Expected 4 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if !strings.HasPrefix(chunks[1].Text, "wi wj") {
+		t.Errorf("Expected second chunk to overlap the first, got %q", chunks[1].Text)
+	}
+	if chunks[3].Section != "Page 2" || chunks[3].Text != "short page" {
+		t.Errorf("Chunks must not span sections, got %+v", chunks[3])
+	}
+}
+
+func TestHashEmbeddingDeterministic(t *testing.T) {
+	provider := NewHashEmbeddingProvider(256)
+	a, _ := provider.Embed(context.Background(), []string{"Photosynthesis converts light energy"})
+	b, _ := provider.Embed(context.Background(), []string{"Photosynthesis converts light energy"})
+
+	for i := range a[0] {
+		if a[0][i] != b[0][i] {
+			t.Fatalf("Embeddings differ at %d", i)
+		}
+	}
+	if sim := cosineSimilarity(a[0], b[0]); sim < 0.999 {
+		t.Errorf("Expected identical texts to have similarity 1, got %f", sim)
+	}
+}
+
+func TestRankChunksRetrievesRelevantPassage(t *testing.T) {
+	provider := NewHashEmbeddingProvider(0)
+	texts := []string{
+		"The French Revolution began in 1789 with the storming of the Bastille.",
+		"Photosynthesis takes place in the chloroplasts of plant cells using light energy.",
+		"Newton's second law relates force, mass and acceleration.",
+	}
+	vectors, _ := provider.Embed(context.Background(), texts)
+
+	var chunks []models.ResourceChunk
+	for i, text := range texts {
+		chunks = append(chunks, models.ResourceChunk{ResourceID: primitive.NewObjectID(), Text: text, Embedding: vectors[i]})
+	}
+
+	query, _ := provider.Embed(context.Background(), []string{"Where does photosynthesis happen in plant cells?"})
+	results := rankChunks(query[0], chunks, 2)
+	if len(results) != 2 {
+		t.Fatalf("Expected top-2 results, got %d", len(results))
+	}
+	if results[0].Chunk.Text != texts[1] {
+		t.Errorf("Expected photosynthesis passage first, got %q", results[0].Chunk.Text)
+	}
+	if results[0].Score < results[1].Score {
+		t.Error("Results must be ordered by score")
+	}
+}
+
+func TestBuildCitations(t *testing.T) {
+	chunks := []scoredChunk{
+		{Chunk: models.ResourceChunk{ResourceName: "Notes", Section: "Page 1", Text: "alpha"}, Score: 0.9},
+		{Chunk: models.ResourceChunk{ResourceName: "Slides", Section: "Slide 4", Text: "beta"}, Score: 0.8},
+		{Chunk: models.ResourceChunk{ResourceName: "Book", Text: "gamma"}, Score: 0.7},
+	}
+
+	citations := buildCitations("Cells divide [2]. Also see [1, 2] and [9].", chunks)
+	if len(citations) != 2 {
+		t.Fatalf("Expected only cited sources, got %+v", citations)
+	}
+	if citations[0].Number != 1 || citations[1].Number != 2 || citations[1].Section != "Slide 4" {
+		t.Errorf("Unexpected citations: %+v", citations)
+	}
+
+	if all := buildCitations("No markers here.", chunks); len(all) != 3 {
+		t.Errorf("Expected all sources when the answer has no markers, got %d", len(all))
+	}
+
+	sources := formatSources(chunks)
+	if !strings.Contains(sources, "[2] Slides (Slide 4)\nbeta") {
+		t.Errorf("Unexpected source formatting: %q", sources)
+	}
+}
@@ -20,6 +20,7 @@ const (
 	EventXPGranted     RewardEventType = "xp_granted"
 	EventStudySessionLogged RewardEventType = "study_session_logged"
 	EventStreakUpdated      RewardEventType = "streak_updated"
+	EventPeerReviewCompleted RewardEventType = "peer_review_completed"
 )
 
 // RewardEvent describes an event that may yield rewards
@@ -57,6 +58,8 @@ func (e *RewardEngine) Evaluate(ev RewardEvent) (RewardDelta, error) {
 		return e.onStudySessionLogged(ev)
 	case EventStreakUpdated:
 		return e.onStreakUpdated(ev)
+	case EventPeerReviewCompleted:
+		return e.onPeerReviewCompleted(ev)
 	default:
 		return RewardDelta{}, nil
 	}
@@ -172,6 +175,22 @@ func (e *RewardEngine) onStreakUpdated(ev RewardEvent) (RewardDelta, error) {
 	return delta, nil
 }
 
+func (e *RewardEngine) onPeerReviewCompleted(ev RewardEvent) (RewardDelta, error) {
+	// Reviews are only accepted before the deadline, so every completed review earns the same reward
+	delta := RewardDelta{XP: 15, Gems: 1}
+
+	// Badge: first completed peer review
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	reviews := e.db.Collection("peer_reviews")
+	count, err := reviews.CountDocuments(ctx, bson.M{"reviewer_id": ev.UserID, "status": "completed"})
+	if err == nil && count == 1 {
+		delta.BadgesToGrant = append(delta.BadgesToGrant, "First Peer Review")
+	}
+
+	return delta, nil
+}
+
 // EnsureDefaultBadges seeds badge definitions if missing
 func (e *RewardEngine) EnsureDefaultBadges() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -225,6 +244,15 @@ func (e *RewardEngine) EnsureDefaultBadges() error {
 			Criteria:    map[string]interface{}{"type": "streak", "days": 30},
 			CreatedAt:   time.Now(),
 		},
+		{
+			Name:        "First Peer Review",
+			Description: "Review a classmate's project",
+			IconURL:     "",
+			Category:    "achievement",
+			XPReward:    0,
+			Criteria:    map[string]interface{}{"type": "peer_review_completed", "count": 1},
+			CreatedAt:   time.Now(),
+		},
 	}
 
 	for _, b := range defaults {
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -12,31 +13,53 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // RoomAIService handles AI features for study rooms
 type RoomAIService struct {
-	db            *database.DB
-	geminiService *GeminiService
+	db                *database.DB
+	aiService         *AIService
+	extractionService *ResourceExtractionService
+	indexService      *RoomIndexService
+	cache             *AICache
 }
 
+// ErrRoomAIPermission is returned when someone other than the room owner manages its AI
+var ErrRoomAIPermission = errors.New("only the room owner can manage the room AI")
+
 // NewRoomAIService creates a new room AI service
-func NewRoomAIService(db *database.DB, geminiService *GeminiService) *RoomAIService {
+func NewRoomAIService(db *database.DB, aiService *AIService) *RoomAIService {
 	return &RoomAIService{
 		db:            db,
-		geminiService: geminiService,
+		aiService:     aiService,
 	}
 }
 
-// TrainRoomAI trains the AI with room resources
-func (s *RoomAIService) TrainRoomAI(roomID string, resourceIDs []string) (*models.RoomAIContext, error) {
-	if s.geminiService == nil {
+// SetExtractionService sets the resource text extraction service
+func (s *RoomAIService) SetExtractionService(extractionService *ResourceExtractionService) {
+	s.extractionService = extractionService
+}
+
+// SetIndexService sets the retrieval index used to ground answers in resource content
+func (s *RoomAIService) SetIndexService(indexService *RoomIndexService) {
+	s.indexService = indexService
+}
+
+// SetCache sets the AI answer cache, which is cleared for a room when it is retrained
+func (s *RoomAIService) SetCache(cache *AICache) {
+	s.cache = cache
+}
+
+// TrainRoomAI trains the AI with room resources; only the room owner can do this
+func (s *RoomAIService) TrainRoomAI(ctx context.Context, roomID, userID string, resourceIDs []string) (*models.RoomAIContext, error) {
+	if s.aiService == nil {
 		return nil, errors.New("AI service not available")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+	if err := s.requireOwner(ctx, roomID, userID); err != nil {
+		return nil, err
+	}
 	roomOID, err := primitive.ObjectIDFromHex(roomID)
 	if err != nil {
 		return nil, errors.New("invalid room ID")
@@ -52,27 +75,84 @@ func (s *RoomAIService) TrainRoomAI(roomID string, resourceIDs []string) (*model
 		resourceOIDs = append(resourceOIDs, oid)
 	}
 
+	// Get the room's syllabus and AI settings if it exists
+	var room models.Room
+	roomCollection := s.db.Collection("rooms")
+	err = roomCollection.FindOne(ctx, bson.M{"_id": roomOID}).Decode(&room)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	var uploaderTypes []string
+	if room.AISettings != nil {
+		uploaderTypes = room.AISettings.TrainingUploaderTypes
+	}
+
 	// Fetch resources
 	resourcesCollection := s.db.Collection("resources")
 	cursor, err := resourcesCollection.Find(ctx, bson.M{
-		"_id": bson.M{"$in": resourceOIDs},
+		"_id":     bson.M{"$in": resourceOIDs},
+		"room_id": roomOID,
 	})
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var resources []models.Resource
-	if err := cursor.All(ctx, &resources); err != nil {
+	var selected []models.Resource
+	if err := cursor.All(ctx, &selected); err != nil {
 		return nil, err
 	}
 
-	// Extract training content
+	// Load extracted file text (re-extracting files that changed)
+	var texts map[primitive.ObjectID]*models.ResourceText
+	if s.extractionService != nil {
+		texts = s.extractionService.GetResourceTexts(selected)
+	}
+
+	// Leave out resources from uploaders the room doesn't train with, and
+	// any that try to give the AI instructions
+	var resources []models.Resource
+	var excluded []models.ExcludedResource
+	trainedOIDs := []primitive.ObjectID{}
+	for _, resource := range selected {
+		if reason := trainingExclusion(&resource, texts[resource.ID], uploaderTypes); reason != "" {
+			excluded = append(excluded, models.ExcludedResource{
+				ResourceID:   resource.ID,
+				ResourceName: resource.Name,
+				UploaderType: resource.UploaderType,
+				Reason:       reason,
+			})
+			continue
+		}
+		resources = append(resources, resource)
+		trainedOIDs = append(trainedOIDs, resource.ID)
+	}
+
+	// Chunk and embed resource text for retrieval
+	if s.indexService != nil {
+		for i := range resources {
+			text := texts[resources[i].ID]
+			if text == nil || text.Status != "ready" {
+				continue
+			}
+			if err := s.indexService.IndexResource(ctx, &resources[i], text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The training content is a catalogue of resources; their text is retrieved per question
 	var trainingContent strings.Builder
 	for _, resource := range resources {
 		trainingContent.WriteString("Resource: ")
 		trainingContent.WriteString(resource.Name)
 		trainingContent.WriteString("\n")
+
+		if resource.UploaderType != "" {
+			trainingContent.WriteString("Uploaded by: ")
+			trainingContent.WriteString(resource.UploaderType)
+			trainingContent.WriteString("\n")
+		}
 		
 		if resource.Description != "" {
 			trainingContent.WriteString("Description: ")
@@ -86,25 +166,19 @@ func (s *RoomAIService) TrainRoomAI(roomID string, resourceIDs []string) (*model
 			trainingContent.WriteString("\n")
 		}
 		
-		// For text files, include content
-		// For other types, include metadata only
+		if text := texts[resource.ID]; text != nil && text.PageCount > 0 {
+			trainingContent.WriteString(fmt.Sprintf("Pages: %d\n", text.PageCount))
+		}
 		trainingContent.WriteString("\n---\n\n")
 	}
 
-	// Get room's syllabus if exists
-	var room models.Room
-	roomCollection := s.db.Collection("rooms")
-	err = roomCollection.FindOne(ctx, bson.M{"_id": roomOID}).Decode(&room)
-	if err != nil && err != mongo.ErrNoDocuments {
-		return nil, err
-	}
-
 	// Create or update RoomAIContext
 	aiContext := &models.RoomAIContext{
 		RoomID:             roomOID,
-		TrainedResourceIDs: resourceOIDs,
+		TrainedResourceIDs: trainedOIDs,
 		TrainingContent:    trainingContent.String(),
 		Syllabus:           room.Syllabus,
+		ExcludedResources:  excluded,
 		MessageCount:       0,
 		LastTrainedAt:      time.Now(),
 		CreatedAt:          time.Now(),
@@ -129,9 +203,14 @@ func (s *RoomAIService) TrainRoomAI(roomID string, resourceIDs []string) (*model
 		aiContext.ID = existing.ID
 		aiContext.CreatedAt = existing.CreatedAt
 		aiContext.MessageCount = existing.MessageCount
-		
+
+		unset := bson.M{"stale_resource_ids": ""} // Retraining picks up the new versions
+		if len(excluded) == 0 {
+			unset["excluded_resources"] = ""
+		}
 		_, err = collection.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{
-			"$set": aiContext,
+			"$set":   aiContext,
+			"$unset": unset,
 		})
 		if err != nil {
 			return nil, err
@@ -140,21 +219,83 @@ func (s *RoomAIService) TrainRoomAI(roomID string, resourceIDs []string) (*model
 		return nil, err
 	}
 
+	// Answers generated from the previous training are out of date
+	s.cache.InvalidateRoom(roomID)
+
 	return aiContext, nil
 }
 
-// ChatWithRoomAI sends a message to the room's AI
-func (s *RoomAIService) ChatWithRoomAI(roomID, message string) (string, error) {
-	if s.geminiService == nil {
-		return "", errors.New("AI service not available")
+// RequireOwner returns ErrRoomAIPermission unless the user owns the room
+func (s *RoomAIService) RequireOwner(roomID, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.requireOwner(ctx, roomID, userID)
+}
+
+func (s *RoomAIService) requireOwner(ctx context.Context, roomID, userID string) error {
+	roomOID, userOID, err := parseRoomAndUser(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if isOwner, _, err := roomAccess(ctx, s.db, roomOID, userOID); err != nil {
+		return err
+	} else if !isOwner {
+		return ErrRoomAIPermission
+	}
+	return nil
+}
+
+// ClearCache drops the room's cached AI answers, e.g. after its resources
+// change; only the room owner can do this
+func (s *RoomAIService) ClearCache(roomID, userID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomOID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return 0, errors.New("invalid room ID")
+	}
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, errors.New("invalid user ID")
+	}
+
+	isOwner, _, err := roomAccess(ctx, s.db, roomOID, userOID)
+	if err != nil {
+		return 0, err
+	}
+	if !isOwner {
+		return 0, ErrRoomAIPermission
+	}
+	return s.cache.InvalidateRoom(roomID), nil
+}
+
+// ChatWithRoomAI answers a message from the room's resources, citing the passages it used
+func (s *RoomAIService) ChatWithRoomAI(roomID, message string) (*models.RoomAIAnswer, error) {
+	return s.ChatWithRoomAIStream(context.Background(), roomID, message, nil)
+}
+
+// ChatWithRoomAIStream is ChatWithRoomAI with the answer sent to onDelta as it is
+// generated; citations are only known once it is complete. Cancelling ctx
+// stops the generation.
+func (s *RoomAIService) ChatWithRoomAIStream(streamCtx context.Context, roomID, message string, onDelta func(delta string) error) (*models.RoomAIAnswer, error) {
+	return s.ChatInThread(streamCtx, roomID, message, nil, onDelta)
+}
+
+// ChatInThread is ChatWithRoomAIStream continuing a conversation thread
+func (s *RoomAIService) ChatInThread(streamCtx context.Context, roomID, message string, history *ConversationHistory, onDelta func(delta string) error) (*models.RoomAIAnswer, error) {
+	if s.aiService == nil {
+		return nil, errors.New("AI service not available")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	streamCtx = WithAIUsage(streamCtx, "", roomID)
+	ctx, cancel := context.WithTimeout(streamCtx, 20*time.Second)
 	defer cancel()
 
 	roomOID, err := primitive.ObjectIDFromHex(roomID)
 	if err != nil {
-		return "", errors.New("invalid room ID")
+		return nil, errors.New("invalid room ID")
 	}
 
 	// Get room AI context
@@ -163,9 +304,9 @@ func (s *RoomAIService) ChatWithRoomAI(roomID, message string) (string, error) {
 	err = collection.FindOne(ctx, bson.M{"room_id": roomOID}).Decode(&aiContext)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return "", errors.New("room AI not trained yet")
+			return nil, errors.New("room AI not trained yet")
 		}
-		return "", err
+		return nil, err
 	}
 
 	// Build syllabus string
@@ -175,23 +316,74 @@ func (s *RoomAIService) ChatWithRoomAI(roomID, message string) (string, error) {
 		// Syllabus structure may vary, use available fields
 	}
 
+	// The teacher's settings for the assistant; the room's owner is never
+	// paused or required to be tutored, and an unknown user is a student
+	var room tutorRoom
+	if err := s.db.Collection("rooms").FindOne(ctx, bson.M{"_id": roomOID},
+		options.FindOne().SetProjection(bson.M{"owner_id": 1, "ai_settings": 1})).Decode(&room); err != nil {
+		return nil, err
+	}
+	userID := aiUsageFrom(streamCtx).userID
+	isOwner := userID != nil && *userID == room.OwnerID
+	now := time.Now()
+	if window := activeBlackout(room.AISettings, now); window != nil && !isOwner {
+		return nil, &RoomAIBlackoutError{Until: window.End, Reason: window.Reason}
+	}
+	assistant := &RoomAssistant{Settings: room.AISettings}
+	if !assistant.solvesAssignments() {
+		if assistant.Assignments, err = roomAssignmentSummaries(ctx, s.db, roomOID); err != nil {
+			return nil, err
+		}
+	}
+
+	// A single question in a room that requires tutoring only gets a first
+	// nudge; hints and the solution need a conversation thread
+	if history == nil && !isOwner {
+		required, err := requiredTutoring(ctx, s.db, &room, now)
+		if err != nil {
+			return nil, err
+		}
+		if required != nil {
+			history = &ConversationHistory{Tutor: required.start(message)}
+		}
+	}
+
+	// Retrieve the passages most relevant to this question
+	var retrieved []scoredChunk
+	if s.indexService != nil {
+		retrieved, err = s.indexService.Search(roomOID, aiContext.TrainedResourceIDs, message, defaultRetrievalTopK)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate AI response
-	response, err := s.geminiService.GenerateRoomAIResponse(
+	response, err := s.aiService.GenerateRoomAIResponseStream(
+		streamCtx,
 		message,
 		aiContext.TrainingContent,
+		formatSources(retrieved),
 		syllabusStr,
+		assistant,
+		history,
+		onDelta,
 	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Update message count
-	collection.UpdateOne(ctx, bson.M{"_id": aiContext.ID}, bson.M{
+	// Update message count; a long answer can outlast the lookup timeout
+	updateCtx, updateCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer updateCancel()
+	collection.UpdateOne(updateCtx, bson.M{"_id": aiContext.ID}, bson.M{
 		"$inc": bson.M{"message_count": 1},
 		"$set": bson.M{"updated_at": time.Now()},
 	})
 
-	return response, nil
+	return &models.RoomAIAnswer{
+		Response:  response,
+		Citations: buildCitations(response, retrieved),
+	}, nil
 }
 
 // GetRoomAIStatus checks if room AI is trained and ready
@@ -220,10 +412,24 @@ func (s *RoomAIService) GetRoomAIStatus(roomID string) (map[string]interface{},
 		return nil, err
 	}
 
-	return map[string]interface{}{
+	status := map[string]interface{}{
 		"trained":          true,
 		"resource_count":   len(aiContext.TrainedResourceIDs),
 		"message_count":    aiContext.MessageCount,
 		"last_trained_at":  aiContext.LastTrainedAt,
-	}, nil
+		// Trained resources that got a new version; retrain to include it
+		"needs_retraining":     len(aiContext.StaleResourceIDs) > 0,
+		"stale_resource_count": len(aiContext.StaleResourceIDs),
+	}
+	if len(aiContext.ExcludedResources) > 0 {
+		// Selected resources left out of training, with the reason
+		status["excluded_resources"] = aiContext.ExcludedResources
+	}
+	if s.indexService != nil {
+		if count, err := s.indexService.CountChunks(roomOID); err == nil {
+			status["indexed_chunks"] = count
+		}
+	}
+
+	return status, nil
 }
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxAssistantNameLength = 40
+	maxPersonaLength       = 300
+	maxLanguageLength      = 30
+	maxBlackoutWindows     = 20
+	maxBlackoutReason      = 120
+	// maxProtectedAssignments bounds the assignments listed in the prompt of
+	// a room AI that may not solve them
+	maxProtectedAssignments = 20
+)
+
+// ErrRoomAIBlackout is returned when a student asks the room AI during one of its blackout windows
+var ErrRoomAIBlackout = errors.New("the room AI is paused")
+
+// RoomAIBlackoutError is ErrRoomAIBlackout with the window it is paused for
+type RoomAIBlackoutError struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (e *RoomAIBlackoutError) Error() string {
+	msg := "The AI Coach is paused in this room until " + e.Until.Format(time.RFC3339)
+	if e.Reason != "" {
+		msg += " (" + e.Reason + ")"
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrRoomAIBlackout) match
+func (e *RoomAIBlackoutError) Is(target error) bool {
+	return target == ErrRoomAIBlackout
+}
+
+// activeBlackout returns the blackout window a room's AI is in at now, or nil;
+// of overlapping windows, the one that ends last
+func activeBlackout(settings *models.RoomAISettings, now time.Time) *models.AIBlackoutWindow {
+	if settings == nil {
+		return nil
+	}
+	var active *models.AIBlackoutWindow
+	for i, window := range settings.BlackoutWindows {
+		if !now.Before(window.Start) && now.Before(window.End) && (active == nil || window.End.After(active.End)) {
+			active = &settings.BlackoutWindows[i]
+		}
+	}
+	return active
+}
+
+// RoomAssistant is a room's AI assistant as its teacher configured it
+type RoomAssistant struct {
+	Settings *models.RoomAISettings // nil for the defaults
+	// Assignments it may not solve, when the settings don't allow it, as
+	// "title: description" lines
+	Assignments []string
+}
+
+// solvesAssignments reports whether the assistant may answer assignment questions
+func (a *RoomAssistant) solvesAssignments() bool {
+	return a == nil || a.Settings == nil || a.Settings.SolveAssignments == nil || *a.Settings.SolveAssignments
+}
+
+// systemPrompt is the room AI's system prompt: who it is, what it may answer
+// and how, with the rules for the untrusted course content
+func (a *RoomAssistant) systemPrompt() string {
+	settings := &models.RoomAISettings{}
+	if a != nil && a.Settings != nil {
+		settings = a.Settings
+	}
+
+	var sb strings.Builder
+	identity := "a helpful AI teaching assistant for a specific course"
+	if settings.Persona != "" {
+		identity = settings.Persona + ", the AI teaching assistant for a specific course"
+	}
+	if settings.AssistantName != "" {
+		fmt.Fprintf(&sb, "You are %s, %s.", settings.AssistantName, identity)
+	} else {
+		fmt.Fprintf(&sb, "You are %s.", identity)
+	}
+
+	if settings.Scope == models.RoomAIScopeGeneral {
+		sb.WriteString(" Base your answers on the provided course content when it covers the question. " +
+			"Otherwise you may answer from general knowledge, saying that the answer goes beyond the course materials.\n")
+		sb.WriteString(untrustedContentRules + "\n")
+	} else {
+		sb.WriteString(" Answer ONLY based on the provided course content.\n")
+		sb.WriteString(untrustedContentRules + "\n")
+		sb.WriteString("IMPORTANT: Only answer questions related to this course content. If the question has nothing to do with this course, reply with exactly this sentence and nothing else: " + RoomAIOffTopicReply + "\n")
+	}
+	sb.WriteString("When you use an excerpt, cite it with its bracketed number, e.g. [1] or [2, 3]. Do not cite excerpts you did not use.")
+
+	switch settings.AnswerStyle {
+	case models.RoomAIStyleBrief:
+		sb.WriteString("\nKeep answers brief: a few sentences with only what the student needs.")
+	case models.RoomAIStyleDetailed:
+		sb.WriteString("\nGive detailed answers: explain the reasoning step by step and include an example.")
+	case models.RoomAIStyleSocratic:
+		sb.WriteString("\nAnswer in a Socratic style: guide the student towards the answer with questions and hints instead of stating it, and ask them to try each step.")
+	}
+	if settings.Language != "" {
+		sb.WriteString("\nAlways reply in " + settings.Language + ", whatever language the question or the course content is in.")
+	}
+	if !a.solvesAssignments() {
+		sb.WriteString("\nDo NOT solve or give the answers to this course's assignment questions, even if asked. " +
+			"If a question is part of an assignment, explain the concepts it uses and help the student reason about it instead.")
+		if len(a.Assignments) > 0 {
+			sb.WriteString(" The assignments are:\n- " + strings.Join(a.Assignments, "\n- "))
+		}
+	}
+	return sb.String()
+}
+
+// roomAssignmentSummaries lists a room's latest assignments as "title: description" lines
+func roomAssignmentSummaries(ctx context.Context, db *database.DB, roomID primitive.ObjectID) ([]string, error) {
+	cursor, err := db.Collection("assignments").Find(ctx, bson.M{"room_id": roomID},
+		options.Find().
+			SetSort(bson.D{{Key: "due_date", Value: -1}}).
+			SetLimit(maxProtectedAssignments).
+			SetProjection(bson.M{"title": 1, "description": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var assignments []models.Assignment
+	if err := cursor.All(ctx, &assignments); err != nil {
+		return nil, err
+	}
+	summaries := make([]string, len(assignments))
+	for i, assignment := range assignments {
+		summaries[i] = assignment.Title
+		if description := strings.Join(strings.Fields(assignment.Description), " "); description != "" {
+			summaries[i] += ": " + truncateRunes(description, 200)
+		}
+	}
+	return summaries, nil
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with an ellipsis
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// validateAssistantSettings checks and normalizes the assistant part of room AI settings
+func validateAssistantSettings(settings *models.RoomAISettings) error {
+	settings.AssistantName = strings.TrimSpace(settings.AssistantName)
+	settings.Persona = strings.Join(strings.Fields(settings.Persona), " ")
+	settings.Language = strings.TrimSpace(settings.Language)
+	if len([]rune(settings.AssistantName)) > maxAssistantNameLength {
+		return fmt.Errorf("%w: assistant_name can be at most %d characters", ErrInvalidRoomAISettings, maxAssistantNameLength)
+	}
+	if len([]rune(settings.Persona)) > maxPersonaLength {
+		return fmt.Errorf("%w: persona can be at most %d characters", ErrInvalidRoomAISettings, maxPersonaLength)
+	}
+	if len([]rune(settings.Language)) > maxLanguageLength {
+		return fmt.Errorf("%w: language can be at most %d characters", ErrInvalidRoomAISettings, maxLanguageLength)
+	}
+
+	switch settings.Scope {
+	case "":
+		settings.Scope = models.RoomAIScopeSyllabus
+	case models.RoomAIScopeSyllabus, models.RoomAIScopeGeneral:
+	default:
+		return fmt.Errorf("%w: scope must be syllabus or general", ErrInvalidRoomAISettings)
+	}
+	switch settings.AnswerStyle {
+	case "":
+		settings.AnswerStyle = models.RoomAIStyleBalanced
+	case models.RoomAIStyleBalanced, models.RoomAIStyleBrief, models.RoomAIStyleDetailed, models.RoomAIStyleSocratic:
+	default:
+		return fmt.Errorf("%w: answer_style must be balanced, brief, detailed or socratic", ErrInvalidRoomAISettings)
+	}
+
+	if len(settings.BlackoutWindows) > maxBlackoutWindows {
+		return fmt.Errorf("%w: at most %d blackout windows", ErrInvalidRoomAISettings, maxBlackoutWindows)
+	}
+	for i := range settings.BlackoutWindows {
+		window := &settings.BlackoutWindows[i]
+		if window.Start.IsZero() || !window.End.After(window.Start) {
+			return fmt.Errorf("%w: blackout window %d must end after it starts", ErrInvalidRoomAISettings, i+1)
+		}
+		window.Reason = strings.TrimSpace(window.Reason)
+		if len([]rune(window.Reason)) > maxBlackoutReason {
+			return fmt.Errorf("%w: a blackout reason can be at most %d characters", ErrInvalidRoomAISettings, maxBlackoutReason)
+		}
+	}
+	return nil
+}
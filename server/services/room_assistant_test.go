@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"buddy-server/models"
+)
+
+func TestRoomAssistantSystemPrompt(t *testing.T) {
+	// The defaults keep the room AI to its course
+	var defaults *RoomAssistant
+	system := defaults.systemPrompt()
+	if !strings.HasPrefix(system, "You are a helpful AI teaching assistant for a specific course. Answer ONLY based on the provided course content.") ||
+		!strings.Contains(system, RoomAIOffTopicReply) || !strings.Contains(system, untrustedContentRules) {
+		t.Errorf("Unexpected default system prompt:\n%s", system)
+	}
+	if strings.Contains(system, "assignment") || strings.Contains(system, "Always reply in") {
+		t.Errorf("Expected no optional instructions by default:\n%s", system)
+	}
+
+	solve := false
+	assistant := &RoomAssistant{
+		Settings: &models.RoomAISettings{
+			AssistantName:    "Ada",
+			Persona:          "a patient lab partner",
+			Language:         "Spanish",
+			Scope:            models.RoomAIScopeGeneral,
+			AnswerStyle:      models.RoomAIStyleSocratic,
+			SolveAssignments: &solve,
+		},
+		Assignments: []string{"Lab report 2: Measure the rate of osmosis"},
+	}
+	system = assistant.systemPrompt()
+	for _, want := range []string{
+		"You are Ada, a patient lab partner, the AI teaching assistant for a specific course.",
+		"you may answer from general knowledge",
+		"Socratic style",
+		"Always reply in Spanish",
+		"Do NOT solve or give the answers to this course's assignment questions",
+		"- Lab report 2: Measure the rate of osmosis",
+		untrustedContentRules,
+	} {
+		if !strings.Contains(system, want) {
+			t.Errorf("Expected the system prompt to contain %q:\n%s", want, system)
+		}
+	}
+	if strings.Contains(system, RoomAIOffTopicReply) {
+		t.Errorf("Expected a general assistant not to decline off-topic questions:\n%s", system)
+	}
+}
+
+func TestActiveBlackout(t *testing.T) {
+	now := time.Date(2026, 6, 10, 9, 30, 0, 0, time.UTC)
+	settings := &models.RoomAISettings{BlackoutWindows: []models.AIBlackoutWindow{
+		{Start: now.Add(-time.Hour), End: now.Add(time.Hour), Reason: "Midterm"},
+		{Start: now.Add(-time.Minute), End: now.Add(3 * time.Hour), Reason: "Midterm review"},
+		{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)},
+	}}
+
+	window := activeBlackout(settings, now)
+	if window == nil || window.Reason != "Midterm review" {
+		t.Fatalf("Expected the overlapping window that ends last, got %+v", window)
+	}
+	if activeBlackout(settings, now.Add(3*time.Hour)) != nil || activeBlackout(nil, now) != nil {
+		t.Error("Expected no blackout once the windows end or without settings")
+	}
+
+	err := error(&RoomAIBlackoutError{Until: window.End, Reason: window.Reason})
+	if !errors.Is(err, ErrRoomAIBlackout) || !strings.Contains(err.Error(), "(Midterm review)") {
+		t.Errorf("Unexpected blackout error %v", err)
+	}
+}
+
+func TestValidateAssistantSettings(t *testing.T) {
+	settings := &models.RoomAISettings{AssistantName: "  Ada ", Persona: "a  patient\nlab partner"}
+	if err := validateAssistantSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.AssistantName != "Ada" || settings.Persona != "a patient lab partner" ||
+		settings.Scope != models.RoomAIScopeSyllabus || settings.AnswerStyle != models.RoomAIStyleBalanced {
+		t.Errorf("Expected trimmed settings with the defaults, got %+v", settings)
+	}
+
+	start := time.Now()
+	for name, invalid := range map[string]models.RoomAISettings{
+		"scope":    {Scope: "anything"},
+		"style":    {AnswerStyle: "poetic"},
+		"name":     {AssistantName: strings.Repeat("a", maxAssistantNameLength+1)},
+		"language": {Language: strings.Repeat("a", maxLanguageLength+1)},
+		"window":   {BlackoutWindows: []models.AIBlackoutWindow{{Start: start, End: start}}},
+		"windows":  {BlackoutWindows: make([]models.AIBlackoutWindow, maxBlackoutWindows+1)},
+	} {
+		if err := validateAssistantSettings(&invalid); !errors.Is(err, ErrInvalidRoomAISettings) {
+			t.Errorf("%s: expected invalid settings, got %v", name, err)
+		}
+	}
+}
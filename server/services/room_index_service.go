@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RoomIndexService maintains the per-room vector index of resource chunks.
+// Vectors live in the "resource_chunks" collection and are scored in-process,
+// which is fast enough for the few thousand chunks a room accumulates.
+type RoomIndexService struct {
+	db       *database.DB
+	embedder EmbeddingProvider
+}
+
+// NewRoomIndexService creates a new room index service
+func NewRoomIndexService(db *database.DB, embedder EmbeddingProvider) *RoomIndexService {
+	return &RoomIndexService{db: db, embedder: embedder}
+}
+
+// IndexResource chunks and embeds a resource's extracted text, replacing any
+// previous chunks. It is a no-op when the index is already current.
+func (s *RoomIndexService) IndexResource(ctx context.Context, resource *models.Resource, text *models.ResourceText) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	collection := s.db.Collection("resource_chunks")
+
+	current, err := collection.CountDocuments(ctx, bson.M{
+		"resource_id":  resource.ID,
+		"provider":     s.embedder.Name(),
+		"extracted_at": text.ExtractedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if current > 0 {
+		return nil
+	}
+
+	chunks := chunkSections(text.Sections, chunkWords, chunkOverlapWords)
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+
+	var vectors [][]float32
+	if len(texts) > 0 {
+		vectors, err = s.embedder.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := collection.DeleteMany(ctx, bson.M{"resource_id": resource.ID}); err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	docs := make([]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		docs[i] = models.ResourceChunk{
+			RoomID:       resource.RoomID,
+			ResourceID:   resource.ID,
+			ResourceName: resource.Name,
+			Section:      chunk.Section,
+			ChunkIndex:   i,
+			Text:         chunk.Text,
+			Embedding:    vectors[i],
+			Provider:     s.embedder.Name(),
+			ExtractedAt:  text.ExtractedAt,
+			CreatedAt:    now,
+		}
+	}
+	_, err = collection.InsertMany(ctx, docs)
+	return err
+}
+
+// RemoveResource drops a resource's chunks from the index
+func (s *RoomIndexService) RemoveResource(resourceID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("resource_chunks").DeleteMany(ctx, bson.M{"resource_id": resourceID})
+	return err
+}
+
+// Search returns the k chunks of the given resources most relevant to the query
+func (s *RoomIndexService) Search(roomID primitive.ObjectID, resourceIDs []primitive.ObjectID, query string, k int) ([]scoredChunk, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if k <= 0 {
+		k = defaultRetrievalTopK
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, errors.New("failed to embed query")
+	}
+
+	cursor, err := s.db.Collection("resource_chunks").Find(ctx, bson.M{
+		"room_id":     roomID,
+		"resource_id": bson.M{"$in": resourceIDs},
+		"provider":    s.embedder.Name(),
+	}, options.Find().SetSort(bson.D{{Key: "resource_id", Value: 1}, {Key: "chunk_index", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var chunks []models.ResourceChunk
+	if err := cursor.All(ctx, &chunks); err != nil {
+		return nil, err
+	}
+
+	return rankChunks(vectors[0], chunks, k), nil
+}
+
+// CountChunks returns how many chunks are indexed for a room
+func (s *RoomIndexService) CountChunks(roomID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.db.Collection("resource_chunks").CountDocuments(ctx, bson.M{
+		"room_id":  roomID,
+		"provider": s.embedder.Name(),
+	})
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"buddy-server/database"
@@ -821,20 +822,52 @@ func (s *RoomService) GetMessages(roomID string, limit, offset int) ([]MessageWi
 }
 
 // AI-related methods (delegate to RoomAIService)
-func (s *RoomService) TrainRoomAI(roomID string, resourceIDs []string) (*models.RoomAIContext, error) {
+func (s *RoomService) TrainRoomAI(ctx context.Context, roomID, userID string, resourceIDs []string) (*models.RoomAIContext, error) {
 	if s.roomAIService == nil {
 		return nil, errors.New("AI service not available")
 	}
-	return s.roomAIService.TrainRoomAI(roomID, resourceIDs)
+	return s.roomAIService.TrainRoomAI(ctx, roomID, userID, resourceIDs)
 }
 
-func (s *RoomService) ChatWithRoomAI(roomID, message string) (string, error) {
+// RequireRoomAIOwner returns ErrRoomAIPermission unless the user owns the room
+func (s *RoomService) RequireRoomAIOwner(roomID, userID string) error {
 	if s.roomAIService == nil {
-		return "", errors.New("AI service not available")
+		return errors.New("AI service not available")
+	}
+	return s.roomAIService.RequireOwner(roomID, userID)
+}
+
+// RoomAITrainingJobParams are the parameters of a room AI training job
+type RoomAITrainingJobParams struct {
+	ResourceIDs []string `json:"resource_ids"`
+}
+
+// RunRoomAITrainingJob trains the room AI of the job's room
+func (s *RoomService) RunRoomAITrainingJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	if job.RoomID == nil {
+		return nil, fmt.Errorf("%w: no room", ErrJobPermanent)
+	}
+	var params RoomAITrainingJobParams
+	if err := decodeJobParams(job, &params); err != nil {
+		return nil, err
+	}
+	return s.TrainRoomAI(ctx, job.RoomID.Hex(), job.UserID.Hex(), params.ResourceIDs)
+}
+
+func (s *RoomService) ChatWithRoomAI(roomID, message string) (*models.RoomAIAnswer, error) {
+	if s.roomAIService == nil {
+		return nil, errors.New("AI service not available")
 	}
 	return s.roomAIService.ChatWithRoomAI(roomID, message)
 }
 
+func (s *RoomService) ChatWithRoomAIStream(ctx context.Context, roomID, message string, onDelta func(delta string) error) (*models.RoomAIAnswer, error) {
+	if s.roomAIService == nil {
+		return nil, errors.New("AI service not available")
+	}
+	return s.roomAIService.ChatWithRoomAIStream(ctx, roomID, message, onDelta)
+}
+
 func (s *RoomService) GetRoomAIStatus(roomID string) (map[string]interface{}, error) {
 	if s.roomAIService == nil {
 		return nil, errors.New("AI service not available")
@@ -842,6 +875,27 @@ func (s *RoomService) GetRoomAIStatus(roomID string) (map[string]interface{}, er
 	return s.roomAIService.GetRoomAIStatus(roomID)
 }
 
+func (s *RoomService) ClearRoomAICache(roomID, userID string) (int, error) {
+	if s.roomAIService == nil {
+		return 0, errors.New("AI service not available")
+	}
+	return s.roomAIService.ClearCache(roomID, userID)
+}
+
+func (s *RoomService) GetRoomAISettings(roomID, userID string) (*models.RoomAISettings, error) {
+	if s.roomAIService == nil {
+		return nil, errors.New("AI service not available")
+	}
+	return s.roomAIService.GetSettings(roomID, userID)
+}
+
+func (s *RoomService) UpdateRoomAISettings(roomID, userID string, settings models.RoomAISettings) (*models.RoomAISettings, error) {
+	if s.roomAIService == nil {
+		return nil, errors.New("AI service not available")
+	}
+	return s.roomAIService.UpdateSettings(roomID, userID, settings)
+}
+
 // SetRoomAIService sets the room AI service
 func (s *RoomService) SetRoomAIService(roomAIService *RoomAIService) {
 	s.roomAIService = roomAIService
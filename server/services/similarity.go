@@ -0,0 +1,235 @@
+package services
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+
+	"buddy-server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	shingleSize             = 5   // words per shingle
+	minHashPermutations     = 128 // signature length
+	minPassageWords         = 8   // shortest overlap reported as a passage
+	maxPassagesPerPair      = 20
+	maxCandidatesPerShingle = 8 // bound work on very common shingles
+)
+
+// wordSpan is a word's byte offsets in the original text
+type wordSpan struct {
+	start, end int
+}
+
+// shingleDoc holds the word shingles of one submission text
+type shingleDoc struct {
+	text   string
+	words  []wordSpan
+	hashes []uint64 // hashes[i] is the shingle starting at word i
+	set    map[uint64]struct{}
+}
+
+// similarityInput is one submission fed into the analysis
+type similarityInput struct {
+	SubmissionID primitive.ObjectID
+	StudentID    primitive.ObjectID
+	Text         string
+}
+
+// tokenizeWords splits text into letter/digit runs, keeping offsets for highlighting
+func tokenizeWords(text string) []wordSpan {
+	var words []wordSpan
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			words = append(words, wordSpan{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, wordSpan{start, len(text)})
+	}
+	return words
+}
+
+// newShingleDoc builds k-word shingles over the normalized words of text
+func newShingleDoc(text string, k int) *shingleDoc {
+	doc := &shingleDoc{text: text, words: tokenizeWords(text), set: map[uint64]struct{}{}}
+	if len(doc.words) < k {
+		return doc
+	}
+
+	normalized := make([]string, len(doc.words))
+	for i, w := range doc.words {
+		normalized[i] = strings.ToLower(text[w.start:w.end])
+	}
+
+	doc.hashes = make([]uint64, len(doc.words)-k+1)
+	for i := range doc.hashes {
+		h := fnv.New64a()
+		for j := 0; j < k; j++ {
+			h.Write([]byte(normalized[i+j]))
+			h.Write([]byte{' '})
+		}
+		doc.hashes[i] = h.Sum64()
+		doc.set[doc.hashes[i]] = struct{}{}
+	}
+	return doc
+}
+
+// splitmix64 is a fast, well-mixed 64-bit hash used to derive MinHash permutations
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// minHashSignature computes a MinHash signature of a shingle set
+func minHashSignature(set map[uint64]struct{}, n int) []uint64 {
+	sig := make([]uint64, n)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for h := range set {
+		for i := range sig {
+			v := splitmix64(h ^ splitmix64(uint64(i+1)))
+			if v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// estimateJaccard estimates Jaccard similarity from two MinHash signatures
+func estimateJaccard(a, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// jaccard computes exact Jaccard similarity of two shingle sets
+func jaccard(a, b map[uint64]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	inter := 0
+	for h := range a {
+		if _, ok := b[h]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+// findOverlaps finds maximal runs of shared shingles and maps them back to passages in both texts
+func findOverlaps(a, b *shingleDoc, k int) []models.OverlapPassage {
+	positions := make(map[uint64][]int)
+	for j, h := range b.hashes {
+		if len(positions[h]) < maxCandidatesPerShingle {
+			positions[h] = append(positions[h], j)
+		}
+	}
+
+	var passages []models.OverlapPassage
+	for i := 0; i < len(a.hashes); {
+		bestJ, bestLen := -1, 0
+		for _, j := range positions[a.hashes[i]] {
+			n := 0
+			for i+n < len(a.hashes) && j+n < len(b.hashes) && a.hashes[i+n] == b.hashes[j+n] {
+				n++
+			}
+			if n > bestLen {
+				bestJ, bestLen = j, n
+			}
+		}
+		if bestLen == 0 {
+			i++
+			continue
+		}
+
+		words := bestLen + k - 1
+		if words >= minPassageWords {
+			startA, endA := a.words[i].start, a.words[i+words-1].end
+			passages = append(passages, models.OverlapPassage{
+				Text:   a.text[startA:endA],
+				StartA: startA,
+				EndA:   endA,
+				StartB: b.words[bestJ].start,
+				EndB:   b.words[bestJ+words-1].end,
+				Words:  words,
+			})
+		}
+		i += words
+	}
+
+	// Longest passages first, so the report leads with the strongest evidence
+	sort.SliceStable(passages, func(x, y int) bool { return passages[x].Words > passages[y].Words })
+	if len(passages) > maxPassagesPerPair {
+		passages = passages[:maxPassagesPerPair]
+	}
+	return passages
+}
+
+// analyzeSimilarity compares all submissions pairwise and returns pairs at or above threshold
+func analyzeSimilarity(inputs []similarityInput, threshold float64) []models.SimilarityPair {
+	docs := make([]*shingleDoc, len(inputs))
+	sigs := make([][]uint64, len(inputs))
+	for i, in := range inputs {
+		docs[i] = newShingleDoc(in.Text, shingleSize)
+		if len(docs[i].set) > 0 {
+			sigs[i] = minHashSignature(docs[i].set, minHashPermutations)
+		}
+	}
+
+	// MinHash is only used to prune; allow some estimation error before the exact check
+	candidateThreshold := threshold * 0.7
+
+	pairs := []models.SimilarityPair{}
+	for i := 0; i < len(inputs); i++ {
+		for j := i + 1; j < len(inputs); j++ {
+			if sigs[i] == nil || sigs[j] == nil {
+				continue
+			}
+			// Resubmissions by the same student are not copying
+			if inputs[i].StudentID == inputs[j].StudentID {
+				continue
+			}
+			if estimateJaccard(sigs[i], sigs[j]) < candidateThreshold {
+				continue
+			}
+			score := jaccard(docs[i].set, docs[j].set)
+			if score < threshold {
+				continue
+			}
+			pairs = append(pairs, models.SimilarityPair{
+				SubmissionA: inputs[i].SubmissionID,
+				SubmissionB: inputs[j].SubmissionID,
+				StudentA:    inputs[i].StudentID,
+				StudentB:    inputs[j].StudentID,
+				Similarity:  score,
+				Passages:    findOverlaps(docs[i], docs[j], shingleSize),
+			})
+		}
+	}
+
+	sort.SliceStable(pairs, func(x, y int) bool { return pairs[x].Similarity > pairs[y].Similarity })
+	return pairs
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+	"buddy-server/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultSimilarityThreshold is the Jaccard similarity above which a pair is reported
+const DefaultSimilarityThreshold = 0.3
+
+// SimilarityService detects copied work across assignment submissions.
+// Analysis runs entirely in-process; no external plagiarism services are used.
+type SimilarityService struct {
+	db                *database.DB
+	assignmentService *AssignmentService
+	store             storage.Storage
+}
+
+// NewSimilarityService creates a new similarity service
+func NewSimilarityService(db *database.DB, assignmentService *AssignmentService, store storage.Storage) *SimilarityService {
+	return &SimilarityService{db: db, assignmentService: assignmentService, store: store}
+}
+
+// StartAnalysis creates a report and runs the pairwise comparison in the background
+func (s *SimilarityService) StartAnalysis(assignmentID, teacherID string, threshold float64) (*models.SimilarityReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assignment, err := s.ownedAssignment(assignmentID, teacherID)
+	if err != nil {
+		return nil, err
+	}
+
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultSimilarityThreshold
+	}
+
+	teacherOID, _ := primitive.ObjectIDFromHex(teacherID)
+	report := &models.SimilarityReport{
+		AssignmentID: assignment.ID,
+		RoomID:       assignment.RoomID,
+		RequestedBy:  teacherOID,
+		Status:       "running",
+		Threshold:    threshold,
+		Pairs:        []models.SimilarityPair{},
+		CreatedAt:    time.Now(),
+	}
+
+	collection := s.db.Collection("similarity_reports")
+	result, err := collection.InsertOne(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	report.ID = result.InsertedID.(primitive.ObjectID)
+
+	go s.runAnalysis(*report)
+
+	return report, nil
+}
+
+// runAnalysis extracts submission text, compares all pairs and stores the result
+func (s *SimilarityService) runAnalysis(report models.SimilarityReport) {
+	update := bson.M{}
+
+	submissions, err := s.assignmentService.GetSubmissions(report.AssignmentID.Hex())
+	if err != nil {
+		update["status"] = "failed"
+		update["error"] = err.Error()
+	} else {
+		inputs, skipped := s.buildInputs(submissions)
+		pairs := analyzeSimilarity(inputs, report.Threshold)
+		update["status"] = "completed"
+		update["submission_count"] = len(submissions)
+		update["skipped_files"] = skipped
+		update["pairs"] = pairs
+	}
+	update["completed_at"] = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = s.db.Collection("similarity_reports").UpdateOne(ctx, bson.M{"_id": report.ID}, bson.M{"$set": update})
+	if err != nil {
+		log.Printf("Failed to save similarity report %s: %v", report.ID.Hex(), err)
+	}
+}
+
+// buildInputs combines each submission's text content with text extracted from its file
+func (s *SimilarityService) buildInputs(submissions []models.Submission) ([]similarityInput, []string) {
+	inputs := make([]similarityInput, 0, len(submissions))
+	var skipped []string
+
+	for _, sub := range submissions {
+		var parts []string
+		if strings.TrimSpace(sub.Content) != "" {
+			parts = append(parts, sub.Content)
+		}
+
+		if sub.FileURL != "" {
+			key, ok := storage.KeyFromURL(sub.FileURL)
+			if !ok {
+				skipped = append(skipped, sub.FileURL)
+			} else if text, err := ExtractTextFromStorage(s.store, key, ""); err != nil {
+				skipped = append(skipped, sub.FileURL)
+			} else {
+				parts = append(parts, text)
+			}
+		}
+
+		inputs = append(inputs, similarityInput{
+			SubmissionID: sub.ID,
+			StudentID:    sub.StudentID,
+			Text:         strings.Join(parts, "\n\n"),
+		})
+	}
+
+	return inputs, skipped
+}
+
+// GetLatestReport returns the most recent similarity report for an assignment
+func (s *SimilarityService) GetLatestReport(assignmentID, teacherID string) (*models.SimilarityReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assignment, err := s.ownedAssignment(assignmentID, teacherID)
+	if err != nil {
+		return nil, err
+	}
+
+	var report models.SimilarityReport
+	err = s.db.Collection("similarity_reports").FindOne(ctx,
+		bson.M{"assignment_id": assignment.ID},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	).Decode(&report)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("no similarity report for this assignment yet")
+		}
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// ownedAssignment loads an assignment and checks that teacherID owns it
+func (s *SimilarityService) ownedAssignment(assignmentID, teacherID string) (*models.Assignment, error) {
+	assignment, err := s.assignmentService.GetAssignment(assignmentID)
+	if err != nil {
+		return nil, errors.New("assignment not found")
+	}
+	if assignment.TeacherID.Hex() != teacherID {
+		return nil, errors.New("only the assignment owner can run similarity analysis")
+	}
+	return assignment, nil
+}
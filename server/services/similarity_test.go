@@ -0,0 +1,103 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const essayA = "Photosynthesis is the process by which green plants use sunlight to synthesize foods " +
+	"from carbon dioxide and water. It generally involves the green pigment chlorophyll and generates " +
+	"oxygen as a byproduct. Plants store the energy as glucose for later use."
+
+const essayB = "In my own words: photosynthesis is the process by which green plants use sunlight to synthesize foods " +
+	"from carbon dioxide and water. It generally involves the green pigment chlorophyll and generates " +
+	"oxygen as a byproduct. I found this topic really interesting."
+
+const essayC = "The French Revolution began in 1789 and led to the end of absolute monarchy. " +
+	"It was driven by economic hardship, Enlightenment ideas and resentment of aristocratic privilege."
+
+func TestTokenizeWordsOffsets(t *testing.T) {
+	text := "Hello, world! 42 times."
+	words := tokenizeWords(text)
+
+	expected := []string{"Hello", "world", "42", "times"}
+	if len(words) != len(expected) {
+		t.Fatalf("Expected %d words, got %d", len(expected), len(words))
+	}
+	for i, w := range words {
+		if text[w.start:w.end] != expected[i] {
+			t.Errorf("Word %d: expected %q, got %q", i, expected[i], text[w.start:w.end])
+		}
+	}
+}
+
+func TestJaccardIdenticalAndDisjoint(t *testing.T) {
+	a := newShingleDoc(essayA, shingleSize)
+	c := newShingleDoc(essayC, shingleSize)
+
+	if got := jaccard(a.set, a.set); got != 1 {
+		t.Errorf("Expected identical similarity 1, got %f", got)
+	}
+	if got := jaccard(a.set, c.set); got != 0 {
+		t.Errorf("Expected disjoint similarity 0, got %f", got)
+	}
+}
+
+func TestMinHashEstimatesJaccard(t *testing.T) {
+	a := newShingleDoc(essayA, shingleSize)
+	b := newShingleDoc(essayB, shingleSize)
+
+	exact := jaccard(a.set, b.set)
+	estimate := estimateJaccard(
+		minHashSignature(a.set, minHashPermutations),
+		minHashSignature(b.set, minHashPermutations),
+	)
+
+	if diff := exact - estimate; diff > 0.2 || diff < -0.2 {
+		t.Errorf("MinHash estimate %f too far from exact %f", estimate, exact)
+	}
+}
+
+func TestAnalyzeSimilarityFindsCopiedPair(t *testing.T) {
+	s1, s2, s3 := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	inputs := []similarityInput{
+		{SubmissionID: primitive.NewObjectID(), StudentID: s1, Text: essayA},
+		{SubmissionID: primitive.NewObjectID(), StudentID: s2, Text: essayB},
+		{SubmissionID: primitive.NewObjectID(), StudentID: s3, Text: essayC},
+	}
+
+	pairs := analyzeSimilarity(inputs, DefaultSimilarityThreshold)
+	if len(pairs) != 1 {
+		t.Fatalf("Expected 1 suspicious pair, got %d", len(pairs))
+	}
+
+	pair := pairs[0]
+	if pair.StudentA != s1 || pair.StudentB != s2 {
+		t.Errorf("Expected pair between first two students, got %s/%s", pair.StudentA.Hex(), pair.StudentB.Hex())
+	}
+	if len(pair.Passages) == 0 {
+		t.Fatal("Expected at least one overlapping passage")
+	}
+
+	passage := pair.Passages[0]
+	if !strings.Contains(passage.Text, "green pigment chlorophyll") {
+		t.Errorf("Expected passage to contain copied text, got %q", passage.Text)
+	}
+	if !strings.EqualFold(essayB[passage.StartB:passage.EndB], passage.Text) {
+		t.Errorf("Offsets in B do not match passage: %q", essayB[passage.StartB:passage.EndB])
+	}
+}
+
+func TestAnalyzeSimilaritySkipsSameStudent(t *testing.T) {
+	student := primitive.NewObjectID()
+	inputs := []similarityInput{
+		{SubmissionID: primitive.NewObjectID(), StudentID: student, Text: essayA},
+		{SubmissionID: primitive.NewObjectID(), StudentID: student, Text: essayA},
+	}
+
+	if pairs := analyzeSimilarity(inputs, DefaultSimilarityThreshold); len(pairs) != 0 {
+		t.Errorf("Expected no pairs for the same student, got %d", len(pairs))
+	}
+}
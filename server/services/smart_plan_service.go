@@ -2,7 +2,7 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"errors"
 	"strings"
 	"time"
@@ -16,16 +16,16 @@ import (
 // SmartPlanService handles AI-powered study plan generation
 type SmartPlanService struct {
 	db                *database.DB
-	geminiService     *GeminiService
+	aiService         *AIService
 	studyPlanService  *StudyPlanService
 	goalService       *GoalService
 }
 
 // NewSmartPlanService creates a new smart plan service
-func NewSmartPlanService(db *database.DB, geminiService *GeminiService, studyPlanService *StudyPlanService, goalService *GoalService) *SmartPlanService {
+func NewSmartPlanService(db *database.DB, aiService *AIService, studyPlanService *StudyPlanService, goalService *GoalService) *SmartPlanService {
 	return &SmartPlanService{
 		db:               db,
-		geminiService:    geminiService,
+		aiService:        aiService,
 		studyPlanService: studyPlanService,
 		goalService:      goalService,
 	}
@@ -35,18 +35,18 @@ func NewSmartPlanService(db *database.DB, geminiService *GeminiService, studyPla
 type GeneratedPlanResponse struct {
 	Name           string                 `json:"name"`
 	Description    string                 `json:"description"`
-	ScheduleBlocks []ScheduleBlockPreview `json:"schedule_blocks"`
+	ScheduleBlocks []ScheduleBlockPreview `json:"schedule_blocks" schema:"min=1"`
 	Milestones     []MilestonePreview     `json:"milestones"`
 }
 
 // ScheduleBlockPreview represents a schedule block preview
 type ScheduleBlockPreview struct {
-	DayOfWeek int    `json:"day_of_week"`
+	DayOfWeek int    `json:"day_of_week" schema:"min=0,max=6"`
 	StartTime string `json:"start_time"`
 	EndTime   string `json:"end_time"`
 	Subject   string `json:"subject"`
 	Topic     string `json:"topic"`
-	BlockType string `json:"block_type"`
+	BlockType string `json:"block_type" schema:"enum=study|review|practice"`
 }
 
 // MilestonePreview represents a milestone preview
@@ -57,59 +57,59 @@ type MilestonePreview struct {
 	Progress    float64 `json:"progress"`
 }
 
-// GenerateSmartPlan generates a study plan using AI
-func (s *SmartPlanService) GenerateSmartPlan(subject, goals, description string, weeklyHours float64, startDate, endDate string) (*GeneratedPlanResponse, error) {
-	if s.geminiService == nil {
-		return nil, errors.New("AI service not available")
-	}
-
-	// Call Gemini to generate the plan
-	response, err := s.geminiService.GenerateSmartStudyPlan(subject, goals, description, weeklyHours, startDate, endDate)
-	if err != nil {
-		return nil, err
+// Validate checks the plan's name, block times and milestone dates
+func (p *GeneratedPlanResponse) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name must not be empty")
 	}
-
-	// Clean up response (remove markdown code blocks if present)
-	response = strings.TrimSpace(response)
-	
-	// Remove markdown code blocks
-	if strings.HasPrefix(response, "```") {
-		lines := strings.Split(response, "\n")
-		if len(lines) > 2 {
-			// Remove first line (```json or ```)
-			lines = lines[1:]
-			// Remove last line (```)
-			if strings.HasPrefix(lines[len(lines)-1], "```") {
-				lines = lines[:len(lines)-1]
-			}
-			response = strings.Join(lines, "\n")
+	for i, block := range p.ScheduleBlocks {
+		start, err := time.Parse("15:04", block.StartTime)
+		if err != nil {
+			return fmt.Errorf("schedule_blocks[%d].start_time must be HH:MM, not %q", i, block.StartTime)
+		}
+		end, err := time.Parse("15:04", block.EndTime)
+		if err != nil {
+			return fmt.Errorf("schedule_blocks[%d].end_time must be HH:MM, not %q", i, block.EndTime)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("schedule_blocks[%d] must end after it starts", i)
 		}
 	}
-	
-	response = strings.TrimSpace(response)
-
-	// Log the response for debugging
-	if len(response) > 500 {
-		println("AI Response (truncated):", response[:500]+"...")
-	} else {
-		println("AI Response:", response)
+	for i, milestone := range p.Milestones {
+		if _, err := time.Parse("2006-01-02", milestone.TargetDate); err != nil {
+			return fmt.Errorf("milestones[%d].target_date must be YYYY-MM-DD, not %q", i, milestone.TargetDate)
+		}
 	}
+	return nil
+}
 
-	// Parse JSON response
-	var planResponse GeneratedPlanResponse
-	if err := json.Unmarshal([]byte(response), &planResponse); err != nil {
-		return nil, errors.New("failed to parse AI response: " + err.Error() + " | Response: " + response[:min(len(response), 200)])
+// GenerateSmartPlan generates a study plan using AI
+func (s *SmartPlanService) GenerateSmartPlan(ctx context.Context, userID, subject, goals, description string, weeklyHours float64, startDate, endDate string) (*GeneratedPlanResponse, error) {
+	if s.aiService == nil {
+		return nil, errors.New("AI service not available")
 	}
 
-	// Validate response
-	if planResponse.Name == "" {
-		return nil, errors.New("AI generated invalid plan: missing name")
-	}
-	if len(planResponse.ScheduleBlocks) == 0 {
-		return nil, errors.New("AI generated invalid plan: no schedule blocks")
-	}
+	// Ask the model to generate the plan
+	return s.aiService.GenerateSmartStudyPlan(WithAIUsage(ctx, userID, ""), subject, goals, description, weeklyHours, startDate, endDate)
+}
+
+// SmartPlanJobParams are the parameters of a smart plan generation job
+type SmartPlanJobParams struct {
+	Subject     string  `json:"subject"`
+	Goals       string  `json:"goals"`
+	Description string  `json:"description"`
+	WeeklyHours float64 `json:"weekly_hours"`
+	StartDate   string  `json:"start_date"`
+	EndDate     string  `json:"end_date"`
+}
 
-	return &planResponse, nil
+// RunSmartPlanJob generates the plan preview a job asked for
+func (s *SmartPlanService) RunSmartPlanJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var params SmartPlanJobParams
+	if err := decodeJobParams(job, &params); err != nil {
+		return nil, err
+	}
+	return s.GenerateSmartPlan(ctx, job.UserID.Hex(), params.Subject, params.Goals, params.Description, params.WeeklyHours, params.StartDate, params.EndDate)
 }
 
 func min(a, b int) int {
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"buddy-server/models"
+	"buddy-server/storage"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxExtractBytes caps how much of a file is read for text extraction
+	maxExtractBytes = 50 << 20 // 50 MB
+	// maxOOXMLInflatedSize bounds everything decompressed from one DOCX or
+	// PPTX archive, so a small zip of compressed XML can't exhaust memory
+	maxOOXMLInflatedSize = 128 << 20
+)
+
+var (
+	// ErrUnsupportedFileType is returned when no extractor handles a file
+	ErrUnsupportedFileType = errors.New("unsupported file type for text extraction")
+	// ErrDocumentTooLarge is returned for a DOCX or PPTX whose parts decompress to more than maxOOXMLInflatedSize
+	ErrDocumentTooLarge = errors.New("document content is too large to process")
+)
+
+// ExtractedDocument is the text of a document split into located sections
+type ExtractedDocument struct {
+	Sections  []models.TextSection
+	PageCount int // PDF pages or PPTX slides; 0 when not applicable
+}
+
+// Text joins all sections into a single string
+func (d *ExtractedDocument) Text() string {
+	parts := make([]string, 0, len(d.Sections))
+	for _, section := range d.Sections {
+		if section.Text != "" {
+			parts = append(parts, section.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// ExtractTextFromStorage extracts plain text from a stored file.
+// The MIME type is preferred; the key's extension is used as a fallback.
+func ExtractTextFromStorage(store storage.Storage, key, mimeType string) (string, error) {
+	doc, err := ExtractDocumentFromStorage(store, key, mimeType)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text(), nil
+}
+
+// ExtractDocumentFromStorage extracts sectioned text from a stored file
+func ExtractDocumentFromStorage(store storage.Storage, key, mimeType string) (*ExtractedDocument, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	rc, _, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxExtractBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return ExtractDocument(data, mimeType, path.Ext(key))
+}
+
+// ExtractText extracts plain text from file contents
+func ExtractText(data []byte, mimeType, ext string) (string, error) {
+	doc, err := ExtractDocument(data, mimeType, ext)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text(), nil
+}
+
+// ExtractDocument extracts sectioned text from file contents
+func ExtractDocument(data []byte, mimeType, ext string) (*ExtractedDocument, error) {
+	switch detectDocumentKind(mimeType, ext) {
+	case "text":
+		return &ExtractedDocument{Sections: []models.TextSection{{Text: strings.ToValidUTF8(string(data), "")}}}, nil
+	case "markdown":
+		return extractMarkdownSections(strings.ToValidUTF8(string(data), "")), nil
+	case "html":
+		text, err := extractHTMLText(data)
+		if err != nil {
+			return nil, err
+		}
+		return &ExtractedDocument{Sections: []models.TextSection{{Text: text}}}, nil
+	case "docx":
+		text, err := extractDOCXText(data)
+		if err != nil {
+			return nil, err
+		}
+		return &ExtractedDocument{Sections: []models.TextSection{{Text: text}}}, nil
+	case "pptx":
+		return extractPPTXSlides(data)
+	case "pdf":
+		return extractPDFPages(data)
+	default:
+		return nil, ErrUnsupportedFileType
+	}
+}
+
+// detectDocumentKind maps a MIME type / extension to an extractor
+func detectDocumentKind(mimeType, ext string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	ext = strings.ToLower(ext)
+
+	switch {
+	case mimeType == "application/pdf" || ext == ".pdf":
+		return "pdf"
+	case mimeType == "text/html" || ext == ".html" || ext == ".htm":
+		return "html"
+	case mimeType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document" || ext == ".docx":
+		return "docx"
+	case mimeType == "application/vnd.openxmlformats-officedocument.presentationml.presentation" || ext == ".pptx":
+		return "pptx"
+	case mimeType == "text/markdown" || ext == ".md" || ext == ".markdown":
+		return "markdown"
+	case strings.HasPrefix(mimeType, "text/") || ext == ".txt":
+		return "text"
+	}
+	return ""
+}
+
+// extractMarkdownSections splits Markdown into sections at headings
+func extractMarkdownSections(text string) *ExtractedDocument {
+	doc := &ExtractedDocument{}
+	label := ""
+	var body []string
+
+	flush := func() {
+		content := strings.TrimSpace(strings.Join(body, "\n"))
+		if content != "" || label != "" {
+			doc.Sections = append(doc.Sections, models.TextSection{Label: label, Text: content})
+		}
+		body = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			flush()
+			label = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	return doc
+}
+
+// extractHTMLText returns the visible text of an HTML document
+func extractHTMLText(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript", "head":
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			text := strings.TrimSpace(n.Data)
+			if text != "" {
+				sb.WriteString(text)
+				sb.WriteString(" ")
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if n.Type == html.ElementNode && isBlockElement(n.Data) {
+			sb.WriteString("\n")
+		}
+	}
+	walk(doc)
+
+	return normalizeExtractedText(sb.String()), nil
+}
+
+// isBlockElement reports whether an HTML element starts a new line of text
+func isBlockElement(tag string) bool {
+	switch tag {
+	case "p", "div", "br", "li", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "section", "article", "pre", "blockquote":
+		return true
+	}
+	return false
+}
+
+// extractDOCXText reads word/document.xml from a DOCX archive
+func extractDOCXText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open DOCX archive: %w", err)
+	}
+
+	budget := int64(maxOOXMLInflatedSize)
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		part, err := readZipPart(f, &budget)
+		if err != nil {
+			return "", err
+		}
+		return extractOOXMLText(bytes.NewReader(part), "p")
+	}
+
+	return "", errors.New("DOCX archive has no word/document.xml")
+}
+
+var slidePathPattern = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// extractPPTXSlides reads each ppt/slides/slideN.xml from a PPTX archive in slide order
+func extractPPTXSlides(data []byte) (*ExtractedDocument, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PPTX archive: %w", err)
+	}
+
+	type slideFile struct {
+		number int
+		file   *zip.File
+	}
+	var slides []slideFile
+	for _, f := range zr.File {
+		if m := slidePathPattern.FindStringSubmatch(f.Name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			slides = append(slides, slideFile{number: n, file: f})
+		}
+	}
+	if len(slides) == 0 {
+		return nil, errors.New("PPTX archive has no slides")
+	}
+	sort.Slice(slides, func(i, j int) bool { return slides[i].number < slides[j].number })
+
+	doc := &ExtractedDocument{PageCount: len(slides)}
+	budget := int64(maxOOXMLInflatedSize)
+	for _, slide := range slides {
+		part, err := readZipPart(slide.file, &budget)
+		if err != nil {
+			return nil, err
+		}
+		text, err := extractOOXMLText(bytes.NewReader(part), "p")
+		if err != nil {
+			return nil, err
+		}
+		doc.Sections = append(doc.Sections, models.TextSection{
+			Label: fmt.Sprintf("Slide %d", slide.number),
+			Text:  text,
+		})
+	}
+
+	return doc, nil
+}
+
+// readZipPart decompresses one archive entry, charging it to the document's
+// remaining budget; the archive's declared sizes aren't trusted
+func readZipPart(f *zip.File, budget *int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, *budget+1))
+	if int64(len(data)) > *budget {
+		return nil, ErrDocumentTooLarge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	*budget -= int64(len(data))
+	return data, nil
+}
+
+// extractOOXMLText collects <w:t>/<a:t> runs from an Office Open XML part,
+// emitting a newline at the end of each paragraph element
+func extractOOXMLText(r io.Reader, paragraphTag string) (string, error) {
+	decoder := xml.NewDecoder(r)
+	var sb strings.Builder
+	inText := false
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse document XML: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case paragraphTag:
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	return normalizeExtractedText(sb.String()), nil
+}
+
+// normalizeExtractedText collapses runs of blank space while keeping line breaks
+func normalizeExtractedText(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractTextHTML(t *testing.T) {
+	page := `<html><head><title>x</title><style>p{}</style></head>
+<body><h1>Cells</h1><p>The <b>mitochondria</b> is the powerhouse.</p><script>alert(1)</script></body></html>`
+
+	text, err := ExtractText([]byte(page), "text/html", "")
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+	if !strings.Contains(text, "mitochondria is the powerhouse") {
+		t.Errorf("Expected body text, got %q", text)
+	}
+	if strings.Contains(text, "alert") || strings.Contains(text, "p{}") {
+		t.Errorf("Script/style content should be dropped, got %q", text)
+	}
+}
+
+func TestExtractTextDOCX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>First paragraph</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Second </w:t></w:r><w:r><w:t>paragraph</w:t></w:r></w:p>
+</w:body></w:document>`))
+	zw.Close()
+
+	text, err := ExtractText(buf.Bytes(), "", ".docx")
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+	if text != "First paragraph\nSecond paragraph" {
+		t.Errorf("Unexpected DOCX text: %q", text)
+	}
+}
+
+func TestOOXMLDecompressionLimit(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write(make([]byte, 1<<20))
+	zw.Close()
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+
+	budget := int64(1000)
+	if _, err := readZipPart(zr.File[0], &budget); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected a part over the budget to fail, got %v", err)
+	}
+	budget = 3 << 20
+	if part, err := readZipPart(zr.File[0], &budget); err != nil || len(part) != 1<<20 || budget != 2<<20 {
+		t.Errorf("Expected a part within the budget to be read and charged, got %d bytes, %d left, %v", len(part), budget, err)
+	}
+}
+
+func TestExtractTextUnsupported(t *testing.T) {
+	if _, err := ExtractText([]byte{0x00, 0x01}, "application/octet-stream", ".bin"); err != ErrUnsupportedFileType {
+		t.Errorf("Expected ErrUnsupportedFileType, got %v", err)
+	}
+}
+
+func TestExtractDocumentPPTX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// Written out of order; slides must come back sorted by number
+	for _, slide := range []struct{ name, text string }{
+		{"ppt/slides/slide10.xml", "Tenth"},
+		{"ppt/slides/slide2.xml", "Second"},
+		{"ppt/slides/slide1.xml", "First"},
+	} {
+		w, _ := zw.Create(slide.name)
+		w.Write([]byte(`<p:sld xmlns:p="p" xmlns:a="a"><p:txBody><a:p><a:r><a:t>` + slide.text + `</a:t></a:r></a:p></p:txBody></p:sld>`))
+	}
+	zw.Close()
+
+	doc, err := ExtractDocument(buf.Bytes(), "", ".pptx")
+	if err != nil {
+		t.Fatalf("ExtractDocument failed: %v", err)
+	}
+	if doc.PageCount != 3 {
+		t.Errorf("Expected 3 slides, got %d", doc.PageCount)
+	}
+	want := []string{"Slide 1:First", "Slide 2:Second", "Slide 10:Tenth"}
+	for i, section := range doc.Sections {
+		if got := section.Label + ":" + section.Text; got != want[i] {
+			t.Errorf("Section %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+}
+
+func TestExtractDocumentMarkdown(t *testing.T) {
+	doc, err := ExtractDocument([]byte("Intro line\n# Cells\nCells are small.\n## Mitosis\nCells divide."), "text/markdown", "")
+	if err != nil {
+		t.Fatalf("ExtractDocument failed: %v", err)
+	}
+	if len(doc.Sections) != 3 {
+		t.Fatalf("Expected 3 sections, got %+v", doc.Sections)
+	}
+	if doc.Sections[1].Label != "Cells" || doc.Sections[1].Text != "Cells are small." {
+		t.Errorf("Unexpected section: %+v", doc.Sections[1])
+	}
+	if doc.Sections[2].Label != "Mitosis" {
+		t.Errorf("Expected Mitosis heading, got %+v", doc.Sections[2])
+	}
+}
+
+// buildTestPDF assembles a PDF from object bodies; a body of the form
+// "stream:<dict>:<data>" becomes a stream object
+func buildTestPDF(objects []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, body := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		if strings.HasPrefix(body, "stream:") {
+			parts := strings.SplitN(strings.TrimPrefix(body, "stream:"), ":", 2)
+			fmt.Fprintf(&buf, "<< /Length %d %s >>\nstream\n%s\nendstream\n", len(parts[1]), parts[0], parts[1])
+		} else {
+			buf.WriteString(body + "\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestExtractDocumentPDF(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("BT /F2 12 Tf 72 700 Td <00010002> Tj ET"))
+	zw.Close()
+
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"2 beginbfchar <0001> <0048> <0002> <0069> endbfchar\n" +
+		"endcmap end end"
+
+	pdf := buildTestPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 5 0 R] /Count 2 /Resources << /Font << /F1 7 0 R /F2 8 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		"stream::BT /F1 12 Tf 72 700 Td (Hello \\(PDF\\)) Tj 0 -14 Td [(Sec) 20 (ond) -250 (line)] TJ ET",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+		"stream:/Filter /FlateDecode:" + compressed.String(),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Custom /Encoding /Identity-H /ToUnicode 9 0 R >>",
+		"stream::" + cmap,
+	})
+
+	doc, err := ExtractDocument(pdf, "application/pdf", ".pdf")
+	if err != nil {
+		t.Fatalf("ExtractDocument failed: %v", err)
+	}
+	if doc.PageCount != 2 || len(doc.Sections) != 2 {
+		t.Fatalf("Expected 2 pages, got %d (%d sections)", doc.PageCount, len(doc.Sections))
+	}
+	if doc.Sections[0].Label != "Page 1" || doc.Sections[0].Text != "Hello (PDF)\nSecond line" {
+		t.Errorf("Unexpected page 1: %+v", doc.Sections[0])
+	}
+	if doc.Sections[1].Text != "Hi" {
+		t.Errorf("Expected ToUnicode-mapped text on page 2, got %q", doc.Sections[1].Text)
+	}
+}
+
+func TestPDFDecompressionLimits(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(make([]byte, 1<<20))
+	zw.Close()
+
+	if _, err := inflatePDF(compressed.Bytes(), 1000); !errors.Is(err, ErrPDFTooLarge) {
+		t.Errorf("Expected a stream over its limit to fail, got %v", err)
+	}
+	if out, err := inflatePDF(compressed.Bytes(), 1<<20); err != nil || len(out) != 1<<20 {
+		t.Errorf("Expected a stream within its limit to inflate, got %d bytes, %v", len(out), err)
+	}
+
+	// The document's streams share one limit
+	f := &pdfFile{inflated: maxPDFInflatedSize - 1000}
+	obj := &pdfObject{dict: "<< /Filter /FlateDecode >>", stream: compressed.Bytes()}
+	if _, err := f.decodeStream(obj); !errors.Is(err, ErrPDFTooLarge) || !f.tooLarge {
+		t.Errorf("Expected the document limit to stop decompression, got %v", err)
+	}
+}
+
+func TestExtractDocumentEncryptedPDF(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer\n<< /Root 1 0 R /Encrypt 2 0 R >>\n")
+	if _, err := ExtractDocument(pdf, "application/pdf", ""); err == nil {
+		t.Error("Expected error for encrypted PDF")
+	}
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+	"buddy-server/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// TusVersion is the tus protocol version implemented by TusService
+	TusVersion = "1.0.0"
+	// TusExtensions lists the supported tus protocol extensions
+	TusExtensions = "creation,expiration,termination"
+
+	// tusSessionTTL is how long an upload may sit idle before it expires
+	tusSessionTTL = 24 * time.Hour
+	// tusProcessingTimeout is how long a completed upload may stay "processing"
+	// before the sweep assumes the server died and processes it again
+	tusProcessingTimeout = 30 * time.Minute
+)
+
+var (
+	// ErrUploadNotFound is returned for unknown (or someone else's) upload sessions
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrUploadExpired is returned for sessions past their expiry
+	ErrUploadExpired = errors.New("upload has expired")
+	// ErrUploadOffsetMismatch is returned when a chunk doesn't start at the current offset
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+	// ErrUploadExceedsLength is returned when a chunk goes past Upload-Length
+	ErrUploadExceedsLength = errors.New("upload exceeds the declared length")
+	// ErrUploadComplete is returned for chunks sent after the upload finished
+	ErrUploadComplete = errors.New("upload is already complete")
+)
+
+// TusService implements resumable uploads following the tus 1.0 protocol.
+// Chunks are stored as separate objects so uploads survive restarts and work
+// with any storage backend; completed uploads go through UploadService
+// (sniffing, quotas, dedupe, scanning) and become resources.
+type TusService struct {
+	db              *database.DB
+	store           storage.Storage
+	uploadService   *UploadService
+	resourceService *ResourceService
+}
+
+// NewTusService creates a new resumable upload service
+func NewTusService(db *database.DB, store storage.Storage, uploadService *UploadService, resourceService *ResourceService) *TusService {
+	return &TusService{
+		db:              db,
+		store:           store,
+		uploadService:   uploadService,
+		resourceService: resourceService,
+	}
+}
+
+// Start periodically removes expired uploads and resumes interrupted processing
+func (s *TusService) Start(sweepInterval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for {
+			s.sweep()
+			<-ticker.C
+		}
+	}()
+}
+
+// ParseTusMetadata decodes an Upload-Metadata header ("key base64value,key2 ...")
+func ParseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid Upload-Metadata pair %q", pair)
+		}
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid Upload-Metadata value for %q", parts[0])
+			}
+			value = string(decoded)
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata, nil
+}
+
+// tusChunkKey names the object holding the chunk that starts at offset;
+// zero padding keeps chunks in order when listed
+func tusChunkKey(sessionID primitive.ObjectID, offset int64) string {
+	return fmt.Sprintf("%s%020d", tusChunkPrefix(sessionID), offset)
+}
+
+func tusChunkPrefix(sessionID primitive.ObjectID) string {
+	return "tus/" + sessionID.Hex() + "/"
+}
+
+// CreateSession starts a resumable upload into a room. Metadata carries the
+// file name and, to create a resource on completion, its name, category etc.
+func (s *TusService) CreateSession(userID, uploaderType string, length int64, metadata map[string]string) (*models.UploadSession, error) {
+	roomID := metadata["room_id"]
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if length <= 0 {
+		return nil, errors.New("Upload-Length must be positive")
+	}
+	if metadata["filename"] == "" {
+		return nil, errors.New("filename metadata is required")
+	}
+	if category := metadata["category"]; category != "" {
+		if _, ok := uploadCategoryTypes[category]; !ok {
+			return nil, errors.New("invalid category")
+		}
+	}
+	if resourceID := metadata["resource_id"]; resourceID != "" {
+		// A new version of an existing resource
+		resource, err := s.resourceService.GetResource(resourceID)
+		if err != nil || resource.RoomID != roomObjectID || resource.UploaderID != userObjectID {
+			return nil, ErrResourceNotFound
+		}
+		if metadata["category"] == "" {
+			metadata["category"] = resource.Category
+		}
+	}
+
+	// Fail before hundreds of MB are transferred
+	if err := s.uploadService.CheckUploadAllowed(roomID, userID, length); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := folderInRoom(ctx, s.db, roomObjectID, metadata["folder_id"]); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.UploadSession{
+		RoomID:       roomObjectID,
+		UserID:       userObjectID,
+		UploaderType: uploaderType,
+		Length:       length,
+		Metadata:     metadata,
+		Status:       "uploading",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ExpiresAt:    now.Add(tusSessionTTL),
+	}
+
+	result, err := s.db.Collection("upload_sessions").InsertOne(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	session.ID = result.InsertedID.(primitive.ObjectID)
+
+	return session, nil
+}
+
+// GetSession returns a user's upload session
+func (s *TusService) GetSession(sessionID, userID string) (*models.UploadSession, error) {
+	sessionObjectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var session models.UploadSession
+	err = s.db.Collection("upload_sessions").FindOne(ctx, bson.M{"_id": sessionObjectID, "user_id": userObjectID}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.Status == "uploading" && time.Now().After(session.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+	return &session, nil
+}
+
+// WriteChunk appends data at offset. Whatever part of the chunk arrives before
+// a connection drop is kept, so the client can resume from the new offset.
+func (s *TusService) WriteChunk(sessionID, userID string, offset int64, r io.Reader) (*models.UploadSession, error) {
+	session, err := s.GetSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != "uploading" {
+		return session, ErrUploadComplete
+	}
+	if offset != session.Offset {
+		return session, ErrUploadOffsetMismatch
+	}
+
+	// Spool to disk first so a partial chunk can still be stored
+	tmp, err := os.CreateTemp("", "buddy-tus-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	remaining := session.Length - session.Offset
+	n, readErr := io.Copy(tmp, io.LimitReader(r, remaining+1))
+	if n > remaining {
+		return session, ErrUploadExceedsLength
+	}
+	if n == 0 {
+		return session, readErr
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	chunkKey := tusChunkKey(session.ID, offset)
+	if err := s.store.Put(ctx, chunkKey, tmp, n, "application/offset+octet-stream"); err != nil {
+		return nil, err
+	}
+
+	// Advance the offset only if no concurrent PATCH got there first
+	now := time.Now()
+	newOffset := offset + n
+	status := "uploading"
+	if newOffset == session.Length {
+		status = "processing"
+	}
+	result, err := s.db.Collection("upload_sessions").UpdateOne(ctx,
+		bson.M{"_id": session.ID, "offset": offset, "status": "uploading"},
+		bson.M{"$set": bson.M{
+			"offset":     newOffset,
+			"status":     status,
+			"updated_at": now,
+			"expires_at": now.Add(tusSessionTTL),
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		s.store.Delete(ctx, chunkKey)
+		return session, ErrUploadOffsetMismatch
+	}
+
+	session.Offset = newOffset
+	session.Status = status
+	session.UpdatedAt = now
+	session.ExpiresAt = now.Add(tusSessionTTL)
+
+	if status == "processing" {
+		// Assembling, scanning and indexing a large video takes a while; the
+		// client polls the session for the result
+		go s.complete(*session)
+	}
+
+	return session, readErr
+}
+
+// Terminate cancels an upload and removes its chunks
+func (s *TusService) Terminate(sessionID, userID string) error {
+	session, err := s.GetSession(sessionID, userID)
+	if err != nil && err != ErrUploadExpired {
+		return err
+	}
+	if session == nil {
+		// Expired: the sweep removes it
+		return nil
+	}
+	return s.remove(session.ID)
+}
+
+func (s *TusService) remove(sessionID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := storage.DeletePrefix(ctx, s.store, tusChunkPrefix(sessionID)); err != nil {
+		return err
+	}
+	_, err := s.db.Collection("upload_sessions").DeleteOne(ctx, bson.M{"_id": sessionID})
+	return err
+}
+
+// complete assembles the chunks, stores the file through UploadService and
+// creates the resource described by the metadata, or adds a version to the
+// resource named by resource_id
+func (s *TusService) complete(session models.UploadSession) {
+	upload, err := s.assemble(&session)
+	if err != nil {
+		log.Printf("resumable upload %s: %v", session.ID.Hex(), err)
+		s.finish(session.ID, bson.M{"status": "failed", "error": err.Error()})
+		return
+	}
+
+	update := bson.M{
+		"status":      "completed",
+		"file_url":    upload.FileURL,
+		"scan_status": upload.ScanStatus,
+	}
+
+	if resourceID := session.Metadata["resource_id"]; resourceID != "" {
+		resource, err := s.resourceService.AddResourceVersion(
+			resourceID,
+			session.UserID.Hex(),
+			upload.FileURL,
+			upload.ContentType,
+			upload.Size,
+			session.Metadata["change_note"],
+		)
+		if err != nil {
+			update["status"] = "failed"
+			update["error"] = "file stored but the new version could not be added: " + err.Error()
+		} else {
+			update["resource_id"] = resource.ID
+		}
+	} else if name := session.Metadata["name"]; name != "" {
+		var subjects []string
+		for _, subject := range strings.Split(session.Metadata["subjects"], ",") {
+			if subject = strings.TrimSpace(subject); subject != "" {
+				subjects = append(subjects, subject)
+			}
+		}
+		if len(subjects) == 0 && session.Metadata["subject"] != "" {
+			subjects = []string{session.Metadata["subject"]}
+		}
+		category := session.Metadata["category"]
+		if category == "" {
+			category = "other"
+		}
+
+		resource, err := s.resourceService.CreateResource(
+			session.RoomID.Hex(),
+			session.UserID.Hex(),
+			name,
+			session.Metadata["description"],
+			upload.FileURL,
+			upload.ContentType,
+			upload.Size,
+			category,
+			session.Metadata["subject"],
+			subjects,
+			session.UploaderType,
+			strings.Split(session.Metadata["tags"], ","),
+			session.Metadata["folder_id"],
+		)
+		if err != nil {
+			update["status"] = "failed"
+			update["error"] = "file stored but resource creation failed: " + err.Error()
+		} else {
+			update["resource_id"] = resource.ID
+		}
+	}
+
+	s.finish(session.ID, update)
+
+	// Chunks are no longer needed once the file is stored
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	storage.DeletePrefix(ctx, s.store, tusChunkPrefix(session.ID))
+}
+
+func (s *TusService) assemble(session *models.UploadSession) (*models.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tusProcessingTimeout)
+	defer cancel()
+
+	tmp, err := os.CreateTemp("", "buddy-tus-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var keys []string
+	err = s.store.List(ctx, tusChunkPrefix(session.ID), func(info storage.ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, key := range keys {
+		if key != tusChunkKey(session.ID, total) {
+			return nil, fmt.Errorf("missing data at offset %d", total)
+		}
+		reader, _, err := s.store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		n, err := io.Copy(tmp, reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	if total != session.Length {
+		return nil, fmt.Errorf("received %d of %d bytes", total, session.Length)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	upload, _, err := s.uploadService.SaveRoomFile(
+		session.RoomID.Hex(),
+		session.UserID.Hex(),
+		session.Metadata["filename"],
+		session.Metadata["category"],
+		tmp,
+		total,
+	)
+	return upload, err
+}
+
+func (s *TusService) finish(sessionID primitive.ObjectID, update bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update["updated_at"] = time.Now()
+	update["expires_at"] = time.Now().Add(tusSessionTTL) // Keep the result around for polling
+	if _, err := s.db.Collection("upload_sessions").UpdateOne(ctx, bson.M{"_id": sessionID}, bson.M{"$set": update}); err != nil {
+		log.Printf("resumable upload %s: %v", sessionID.Hex(), err)
+	}
+}
+
+// sweep removes expired sessions and restarts processing interrupted by a restart
+func (s *TusService) sweep() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := s.db.Collection("upload_sessions")
+	now := time.Now()
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"status":     bson.M{"$ne": "processing"},
+		"expires_at": bson.M{"$lt": now},
+	}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		log.Printf("resumable upload sweep: %v", err)
+		return
+	}
+	var expired []models.UploadSession
+	if err := cursor.All(ctx, &expired); err != nil {
+		log.Printf("resumable upload sweep: %v", err)
+		return
+	}
+	for _, session := range expired {
+		if err := s.remove(session.ID); err != nil {
+			log.Printf("resumable upload %s: %v", session.ID.Hex(), err)
+		}
+	}
+
+	// Claim stale processing sessions one at a time so only one instance resumes each
+	for {
+		var session models.UploadSession
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{"status": "processing", "updated_at": bson.M{"$lt": now.Add(-tusProcessingTimeout)}},
+			bson.M{"$set": bson.M{"updated_at": now}},
+		).Decode(&session)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				log.Printf("resumable upload sweep: %v", err)
+			}
+			return
+		}
+		go s.complete(session)
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"sort"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseTusMetadata(t *testing.T) {
+	// "filename lecture 1.mp4", "room_id 507f...", "is_public" (no value)
+	metadata, err := ParseTusMetadata("filename bGVjdHVyZSAxLm1wNA==, room_id NTA3ZjFmNzdiY2Y4NmNkNzk5NDM5MDEx,is_public")
+	if err != nil {
+		t.Fatalf("ParseTusMetadata failed: %v", err)
+	}
+	if metadata["filename"] != "lecture 1.mp4" || metadata["room_id"] != "507f1f77bcf86cd799439011" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+	if value, ok := metadata["is_public"]; !ok || value != "" {
+		t.Errorf("Expected key without value, got %q (%v)", value, ok)
+	}
+
+	for _, header := range []string{"filename not-base64!", "a b c"} {
+		if _, err := ParseTusMetadata(header); err == nil {
+			t.Errorf("ParseTusMetadata(%q) should fail", header)
+		}
+	}
+}
+
+func TestTusChunkKeysSortByOffset(t *testing.T) {
+	id := primitive.NewObjectID()
+	offsets := []int64{0, 5 << 20, 10 << 20, 999, 123456789012}
+
+	keys := make([]string, len(offsets))
+	for i, offset := range offsets {
+		keys[i] = tusChunkKey(id, offset)
+	}
+	sort.Strings(keys)
+
+	expected := []int64{0, 999, 5 << 20, 10 << 20, 123456789012}
+	for i, offset := range expected {
+		if keys[i] != tusChunkKey(id, offset) {
+			t.Errorf("Chunk %d out of order: %s", i, keys[i])
+		}
+	}
+}
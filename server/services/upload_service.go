@@ -0,0 +1,597 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"buddy-server/database"
+	"buddy-server/models"
+	"buddy-server/storage"
+
+	"github.com/gabriel-vasile/mimetype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrFileTooLarge is returned for uploads over UploadLimits.MaxFileSize
+	ErrFileTooLarge = errors.New("file is too large")
+	// ErrFileTypeNotAllowed is returned when the sniffed type is not allowed for the category
+	ErrFileTypeNotAllowed = errors.New("file type is not allowed")
+	// ErrQuotaExceeded is returned when an upload would exceed a room or user quota
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	// ErrMalwareDetected is returned for files the malware scanner rejected
+	ErrMalwareDetected = errors.New("file was rejected by the malware scanner")
+)
+
+// UploadLimits bounds upload sizes and storage use; zero disables a limit
+type UploadLimits struct {
+	MaxFileSize int64
+	RoomQuota   int64
+	UserQuota   int64
+}
+
+var (
+	documentTypes = []string{
+		"application/pdf",
+		"application/msword",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.oasis.opendocument.text",
+		"text/rtf",
+		"text/plain",
+		"text/csv",
+		"text/html",
+	}
+	slideTypes = []string{
+		"application/vnd.ms-powerpoint",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"application/vnd.oasis.opendocument.presentation",
+	}
+	spreadsheetTypes = []string{
+		"application/vnd.ms-excel",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.oasis.opendocument.spreadsheet",
+	}
+	imageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+	mediaTypes = []string{
+		"video/mp4", "video/webm", "video/quicktime", "video/x-matroska", "video/mpeg",
+		"audio/mpeg", "audio/mp4", "audio/x-m4a", "audio/wav", "audio/ogg", "audio/flac",
+	}
+	bookTypes    = []string{"application/epub+zip"}
+	archiveTypes = []string{"application/zip"}
+)
+
+// uploadCategoryTypes lists the sniffed MIME types accepted for each resource category
+var uploadCategoryTypes = map[string][]string{
+	"video":      mediaTypes,
+	"notes":      concatTypes(documentTypes, slideTypes, imageTypes),
+	"assignment": concatTypes(documentTypes, slideTypes, spreadsheetTypes, imageTypes, archiveTypes),
+	"book":       concatTypes(documentTypes, bookTypes),
+	"other":      concatTypes(documentTypes, slideTypes, spreadsheetTypes, imageTypes, mediaTypes, bookTypes, archiveTypes),
+}
+
+func concatTypes(lists ...[]string) []string {
+	var all []string
+	for _, list := range lists {
+		all = append(all, list...)
+	}
+	return all
+}
+
+// IsUploadTypeAllowed reports whether a sniffed content type may be used for a
+// resource category; an empty category accepts anything allowed for "other"
+func IsUploadTypeAllowed(category, contentType string) bool {
+	if category == "" || category == "all" {
+		category = "other"
+	}
+	allowed, ok := uploadCategoryTypes[category]
+	if !ok {
+		return false
+	}
+	base, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range allowed {
+		if base == t {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffContentType detects the type of content from its bytes, leaving r at the start
+func sniffContentType(r io.ReadSeeker) (*mimetype.MIME, error) {
+	detected, err := mimetype.DetectReader(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return detected, nil
+}
+
+// uploadExtension keeps the client's extension when it is plausible (extraction
+// relies on e.g. ".md"), falling back to the sniffed type's extension
+func uploadExtension(filename string, detected *mimetype.MIME) string {
+	ext := strings.ToLower(path.Ext(strings.ReplaceAll(filename, "\\", "/")))
+	if len(ext) >= 2 && len(ext) <= 10 && strings.Trim(ext[1:], "abcdefghijklmnopqrstuvwxyz0123456789") == "" {
+		return ext
+	}
+	return detected.Extension()
+}
+
+// UploadService stores room uploads: sniffs and allowlists their type, enforces
+// quotas, deduplicates by content hash and quarantines files until scanned
+type UploadService struct {
+	db                *database.DB
+	store             storage.Storage
+	scanner           MalwareScanner
+	limits            UploadLimits
+	extractionService *ResourceExtractionService
+	previewService    *ResourcePreviewService
+}
+
+// NewUploadService creates a new upload service
+func NewUploadService(db *database.DB, store storage.Storage, scanner MalwareScanner, limits UploadLimits) *UploadService {
+	if scanner == nil {
+		scanner = NoopScanner{}
+	}
+	return &UploadService{db: db, store: store, scanner: scanner, limits: limits}
+}
+
+// SetExtractionService sets the text extraction service, notified when files leave quarantine (optional)
+func (s *UploadService) SetExtractionService(extractionService *ResourceExtractionService) {
+	s.extractionService = extractionService
+}
+
+// SetPreviewService sets the preview service, notified when files leave quarantine (optional)
+func (s *UploadService) SetPreviewService(previewService *ResourcePreviewService) {
+	s.previewService = previewService
+}
+
+// Limits returns the configured upload limits
+func (s *UploadService) Limits() UploadLimits {
+	return s.limits
+}
+
+// Start periodically retries scans that could not complete (e.g. clamd was down)
+func (s *UploadService) Start(sweepInterval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.sweepPending()
+		}
+	}()
+}
+
+// SaveRoomFile stores an uploaded room file. It returns the upload record and
+// whether identical content had already been uploaded to the room.
+func (s *UploadService) SaveRoomFile(roomID, userID, filename, category string, file io.ReadSeeker, size int64) (*models.Upload, bool, error) {
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, false, errors.New("invalid room ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, false, errors.New("invalid user ID")
+	}
+	if s.limits.MaxFileSize > 0 && size > s.limits.MaxFileSize {
+		return nil, false, ErrFileTooLarge
+	}
+
+	// Trust the content, not the client's Content-Type
+	detected, err := sniffContentType(file)
+	if err != nil {
+		return nil, false, err
+	}
+	if !IsUploadTypeAllowed(category, detected.String()) {
+		return nil, false, fmt.Errorf("%w: %s", ErrFileTypeNotAllowed, detected.String())
+	}
+
+	hasher := sha256.New()
+	size, err = io.Copy(hasher, file)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	if s.limits.MaxFileSize > 0 && size > s.limits.MaxFileSize {
+		return nil, false, ErrFileTooLarge
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	collection := s.db.Collection("uploads")
+
+	// Same content in the same room: reuse the stored object
+	var existing models.Upload
+	err = collection.FindOne(ctx, bson.M{"room_id": roomObjectID, "hash": hash}).Decode(&existing)
+	if err == nil {
+		if existing.ScanStatus == "infected" {
+			return nil, false, ErrMalwareDetected
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": existing.ID},
+			bson.M{"$addToSet": bson.M{"uploader_ids": userObjectID}}); err != nil {
+			return nil, false, err
+		}
+		return &existing, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	if err := s.checkQuota(ctx, bson.M{"room_id": roomObjectID}, s.limits.RoomQuota, size, "room"); err != nil {
+		return nil, false, err
+	}
+	if err := s.checkQuota(ctx, bson.M{"uploader_id": userObjectID}, s.limits.UserQuota, size, "user"); err != nil {
+		return nil, false, err
+	}
+
+	name := hash + uploadExtension(filename, detected)
+	key := path.Join("rooms", roomID, name)
+	upload := &models.Upload{
+		RoomID:        roomObjectID,
+		UploaderID:    userObjectID,
+		UploaderIDs:   []primitive.ObjectID{userObjectID},
+		Hash:          hash,
+		Key:           key,
+		QuarantineKey: path.Join("quarantine", roomID, name),
+		FileURL:       storage.URLForKey(key),
+		FileName:      path.Base(strings.ReplaceAll(filename, "\\", "/")),
+		ContentType:   detected.String(),
+		Size:          size,
+		ScanStatus:    "pending",
+		CreatedAt:     time.Now(),
+	}
+
+	if err := s.store.Put(ctx, upload.QuarantineKey, file, size, upload.ContentType); err != nil {
+		return nil, false, err
+	}
+
+	result, err := collection.InsertOne(ctx, upload)
+	if err != nil {
+		s.store.Delete(ctx, upload.QuarantineKey)
+		return nil, false, err
+	}
+	upload.ID = result.InsertedID.(primitive.ObjectID)
+
+	// Scan right away; if the scanner is unavailable the file stays quarantined
+	// and the sweep retries
+	if err := s.scanUpload(upload); err != nil {
+		log.Printf("upload scan: %s: %v", upload.ID.Hex(), err)
+	}
+	if upload.ScanStatus == "infected" {
+		return nil, false, fmt.Errorf("%w (%s)", ErrMalwareDetected, upload.Threat)
+	}
+
+	return upload, false, nil
+}
+
+// CheckRoomAccess fails with ErrRoomAccessDenied unless the user is a member
+// or the owner of the room, so only they can store files in it
+func (s *UploadService) CheckRoomAccess(roomID, userID string) error {
+	roomObjectID, userObjectID, err := parseRoomAndUser(roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.checkRoomAccess(ctx, roomObjectID, userObjectID)
+}
+
+func (s *UploadService) checkRoomAccess(ctx context.Context, roomID, userID primitive.ObjectID) error {
+	_, isMember, err := roomAccess(ctx, s.db, roomID, userID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return ErrRoomAccessDenied
+	}
+	return nil
+}
+
+// CheckUploadAllowed fails early if the user can't store a file of size bytes
+// in the room, e.g. before a resumable upload starts
+func (s *UploadService) CheckUploadAllowed(roomID, userID string, size int64) error {
+	roomObjectID, userObjectID, err := parseRoomAndUser(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if s.limits.MaxFileSize > 0 && size > s.limits.MaxFileSize {
+		return ErrFileTooLarge
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.checkRoomAccess(ctx, roomObjectID, userObjectID); err != nil {
+		return err
+	}
+	if err := s.checkQuota(ctx, bson.M{"room_id": roomObjectID}, s.limits.RoomQuota, size, "room"); err != nil {
+		return err
+	}
+	return s.checkQuota(ctx, bson.M{"uploader_id": userObjectID}, s.limits.UserQuota, size, "user")
+}
+
+// checkQuota fails if adding size bytes to the uploads matching filter would exceed quota
+func (s *UploadService) checkQuota(ctx context.Context, filter bson.M, quota, size int64, owner string) error {
+	if quota <= 0 {
+		return nil
+	}
+	used, err := s.usage(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if used+size > quota {
+		return fmt.Errorf("%w: %s has %d MB of %d MB left", ErrQuotaExceeded, owner, max(quota-used, 0)>>20, quota>>20)
+	}
+	return nil
+}
+
+// usage sums the size of stored (not rejected) uploads matching filter
+func (s *UploadService) usage(ctx context.Context, filter bson.M) (int64, error) {
+	match := bson.M{"scan_status": bson.M{"$ne": "infected"}}
+	for k, v := range filter {
+		match[k] = v
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$size"}}}},
+	}
+
+	cursor, err := s.db.Collection("uploads").Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Total int64 `bson:"total"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+	return result.Total, nil
+}
+
+// GetStorageUsage returns a room's and a user's storage use alongside the limits
+func (s *UploadService) GetStorageUsage(roomID, userID string) (map[string]interface{}, error) {
+	roomObjectID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, errors.New("invalid room ID")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomUsed, err := s.usage(ctx, bson.M{"room_id": roomObjectID})
+	if err != nil {
+		return nil, err
+	}
+	userUsed, err := s.usage(ctx, bson.M{"uploader_id": userObjectID})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"room_used":     roomUsed,
+		"room_quota":    s.limits.RoomQuota,
+		"user_used":     userUsed,
+		"user_quota":    s.limits.UserQuota,
+		"max_file_size": s.limits.MaxFileSize,
+	}, nil
+}
+
+// GetUploadByURL returns the upload record behind a file URL
+func (s *UploadService) GetUploadByURL(fileURL string) (*models.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var upload models.Upload
+	err := s.db.Collection("uploads").FindOne(ctx, bson.M{"file_url": fileURL}).Decode(&upload)
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// UploadedBy reports whether a user uploaded the file at fileURL to a room
+func (s *UploadService) UploadedBy(roomID, fileURL, userID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roomOID, userOID, err := parseRoomAndUser(roomID, userID)
+	if err != nil {
+		return false, err
+	}
+	return uploadedBy(ctx, s.db, roomOID, fileURL, userOID)
+}
+
+// uploadedBy reports whether a user uploaded the file at fileURL to a room;
+// infected files don't count
+func uploadedBy(ctx context.Context, db *database.DB, roomID primitive.ObjectID, fileURL string, userID primitive.ObjectID) (bool, error) {
+	count, err := db.Collection("uploads").CountDocuments(ctx, bson.M{
+		"room_id":     roomID,
+		"file_url":    fileURL,
+		"scan_status": bson.M{"$ne": "infected"},
+		"$or":         bson.A{bson.M{"uploader_id": userID}, bson.M{"uploader_ids": userID}},
+	})
+	return count > 0, err
+}
+
+// scanUpload scans a quarantined file and releases or discards it
+func (s *UploadService) scanUpload(upload *models.Upload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	result, err := s.scanObject(ctx, upload.QuarantineKey)
+	if err != nil {
+		s.db.Collection("uploads").UpdateOne(ctx, bson.M{"_id": upload.ID},
+			bson.M{"$set": bson.M{"scan_error": err.Error()}})
+		return err
+	}
+
+	now := time.Now()
+	upload.Scanner = s.scanner.Name()
+	upload.ScannedAt = &now
+	if result.Clean {
+		if err := s.release(ctx, upload); err != nil {
+			return err
+		}
+		upload.ScanStatus = "clean"
+	} else {
+		upload.ScanStatus = "infected"
+		upload.Threat = result.Threat
+	}
+	upload.ScanError = ""
+
+	_, err = s.db.Collection("uploads").UpdateOne(ctx, bson.M{"_id": upload.ID}, bson.M{
+		"$set": bson.M{
+			"scan_status": upload.ScanStatus,
+			"threat":      upload.Threat,
+			"scanner":     upload.Scanner,
+			"scanned_at":  upload.ScannedAt,
+		},
+		"$unset": bson.M{"scan_error": ""},
+	})
+	if err != nil {
+		return err
+	}
+
+	if upload.ScanStatus == "infected" {
+		log.Printf("upload scan: %s rejected (%s)", upload.ID.Hex(), upload.Threat)
+		return s.store.Delete(ctx, upload.QuarantineKey)
+	}
+
+	// Resources may have been created while the file was quarantined
+	s.enqueueProcessing(ctx, upload.FileURL)
+	return nil
+}
+
+func (s *UploadService) scanObject(ctx context.Context, key string) (*ScanResult, error) {
+	reader, _, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return s.scanner.Scan(ctx, reader)
+}
+
+// release moves a scanned file from quarantine to its served location
+func (s *UploadService) release(ctx context.Context, upload *models.Upload) error {
+	reader, info, err := s.store.Get(ctx, upload.QuarantineKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := s.store.Put(ctx, upload.Key, reader, info.Size, upload.ContentType); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, upload.QuarantineKey)
+}
+
+// enqueueProcessing schedules text extraction and previews for the resources using a file
+func (s *UploadService) enqueueProcessing(ctx context.Context, fileURL string) {
+	if s.extractionService == nil && s.previewService == nil {
+		return
+	}
+	cursor, err := s.db.Collection("resources").Find(ctx, bson.M{"file_url": fileURL},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return
+	}
+	var resources []models.Resource
+	if err := cursor.All(ctx, &resources); err != nil {
+		return
+	}
+	for _, resource := range resources {
+		if s.extractionService != nil {
+			s.extractionService.Enqueue(resource.ID)
+		}
+		if s.previewService != nil {
+			s.previewService.Enqueue(resource.ID)
+		}
+	}
+}
+
+// sweepPending retries scans for uploads still in quarantine
+func (s *UploadService) sweepPending() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Skip very recent uploads, which are still being scanned by their request
+	cursor, err := s.db.Collection("uploads").Find(ctx, bson.M{
+		"scan_status": "pending",
+		"created_at":  bson.M{"$lt": time.Now().Add(-2 * time.Minute)},
+	})
+	if err != nil {
+		log.Printf("upload scan sweep: %v", err)
+		return
+	}
+	var uploads []models.Upload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		log.Printf("upload scan sweep: %v", err)
+		return
+	}
+
+	for i := range uploads {
+		if err := s.scanUpload(&uploads[i]); err != nil {
+			log.Printf("upload scan: %s: %v", uploads[i].ID.Hex(), err)
+		}
+	}
+}
+
+// RemoveIfUnused deletes an upload's file once no resource, submission or
+// message refers to it any more, returning its space to the quotas
+func (s *UploadService) RemoveIfUnused(fileURL string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, collection := range []string{"resources", "resource_versions", "submissions", "messages"} {
+		count, err := s.db.Collection(collection).CountDocuments(ctx, bson.M{"file_url": fileURL})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	var upload models.Upload
+	err := s.db.Collection("uploads").FindOneAndDelete(ctx, bson.M{"file_url": fileURL}).Decode(&upload)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.store.Delete(ctx, upload.QuarantineKey)
+	s.store.Delete(ctx, previewThumbnailKey(upload.Key))
+	return s.store.Delete(ctx, upload.Key)
+}
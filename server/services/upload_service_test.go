@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSniffedUploadTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  []byte
+		category string
+		allowed  bool
+	}{
+		{"pdf as notes", []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n"), "notes", true},
+		{"text as book", []byte("Chapter one. It was a dark night."), "book", true},
+		{"png as notes", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "notes", true},
+		{"pdf as video", []byte("%PDF-1.4\n"), "video", false},
+		{"executable renamed", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff"), "other", false},
+		{"unknown category", []byte("plain text"), "secret", false},
+	}
+
+	for _, tt := range tests {
+		detected, err := sniffContentType(bytes.NewReader(tt.content))
+		if err != nil {
+			t.Fatalf("%s: sniff failed: %v", tt.name, err)
+		}
+		if got := IsUploadTypeAllowed(tt.category, detected.String()); got != tt.allowed {
+			t.Errorf("%s: detected %s, allowed = %v, want %v", tt.name, detected.String(), got, tt.allowed)
+		}
+	}
+}
+
+func TestUploadExtension(t *testing.T) {
+	detected, _ := sniffContentType(bytes.NewReader([]byte("# Notes\n\nSome markdown")))
+
+	if ext := uploadExtension("Week 1.MD", detected); ext != ".md" {
+		t.Errorf("Expected client extension .md, got %s", ext)
+	}
+	if ext := uploadExtension("notes.<script>", detected); ext != ".txt" {
+		t.Errorf("Expected sniffed extension .txt, got %s", ext)
+	}
+	if ext := uploadExtension("README", detected); ext != ".txt" {
+		t.Errorf("Expected sniffed extension .txt, got %s", ext)
+	}
+}
@@ -3,10 +3,15 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
 	"time"
 
 	"buddy-server/database"
 	"buddy-server/models"
+	"buddy-server/storage"
 
 	"golang.org/x/crypto/bcrypt"
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,12 +22,13 @@ import (
 
 // UserService handles user-related operations
 type UserService struct {
-	db *database.DB
+	db    *database.DB
+	store storage.Storage
 }
 
 // NewUserService creates a new user service
-func NewUserService(db *database.DB) *UserService {
-	return &UserService{db: db}
+func NewUserService(db *database.DB, store storage.Storage) *UserService {
+	return &UserService{db: db, store: store}
 }
 
 // GetProfile gets a user's profile, creating one if it doesn't exist
@@ -87,6 +93,63 @@ func (s *UserService) UpdateProfile(userID string, updates map[string]interface{
 	return err
 }
 
+// MaxAvatarSize is the largest accepted avatar image
+const MaxAvatarSize = 5 << 20 // 5 MB
+
+// avatarExtensions maps accepted avatar MIME types to file extensions
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// SaveAvatar stores a new avatar image, points the profile at it and removes the previous one
+func (s *UserService) SaveAvatar(userID string, r io.ReadSeeker, size int64) (string, error) {
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		return "", errors.New("invalid user ID")
+	}
+	if size > MaxAvatarSize {
+		return "", fmt.Errorf("avatar must be smaller than %d MB", MaxAvatarSize>>20)
+	}
+
+	// Trust the image bytes, not the client's Content-Type
+	detected, err := sniffContentType(r)
+	if err != nil {
+		return "", err
+	}
+	contentType := detected.String()
+	ext, ok := avatarExtensions[contentType]
+	if !ok {
+		return "", errors.New("avatar must be a PNG, JPEG, GIF or WebP image")
+	}
+
+	profile, err := s.GetProfile(userID)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	key := path.Join("avatars", userID, fmt.Sprintf("%d%s", time.Now().UnixNano(), ext))
+	if err := s.store.Put(ctx, key, r, size, contentType); err != nil {
+		return "", err
+	}
+
+	avatarURL := storage.URLForKey(key)
+	if err := s.UpdateProfile(userID, map[string]interface{}{"avatar_url": avatarURL}); err != nil {
+		return "", err
+	}
+
+	// Remove the previous uploaded avatar (external avatar URLs are left alone)
+	if oldKey, ok := storage.KeyFromURL(profile.AvatarURL); ok && strings.HasPrefix(oldKey, path.Join("avatars", userID)+"/") {
+		s.store.Delete(ctx, oldKey)
+	}
+
+	return avatarURL, nil
+}
+
 // GetUserStats gets a user's statistics
 func (s *UserService) GetUserStats(userID string) (*models.UserStats, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
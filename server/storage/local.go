@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localTempPrefix marks partially written files, which List skips
+const localTempPrefix = ".upload-"
+
+// LocalStorage keeps objects as files under a root directory
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local filesystem store rooted at dir
+func NewLocalStorage(dir string) *LocalStorage {
+	if dir == "" {
+		dir = "./uploads"
+	}
+	return &LocalStorage{root: dir}
+}
+
+// Root returns the directory objects are stored in
+func (s *LocalStorage) Root() string {
+	return s.root
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the object to a temporary file and renames it into place
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), localTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get opens the object's file. The returned reader is an *os.File and supports seeking.
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if st.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+	return f, s.info(key, st), nil
+}
+
+// Stat returns the object's size and modification time
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && st.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.info(key, st), nil
+}
+
+// Delete removes the object's file
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory containing the prefix
+func (s *LocalStorage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	dir := s.root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		sub, err := s.path(prefix[:i])
+		if err != nil {
+			return err
+		}
+		dir = sub
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), localTempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		st, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(*s.info(key, st))
+	})
+	return err
+}
+
+func (s *LocalStorage) info(key string, st fs.FileInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:         path.Clean(key),
+		Size:        st.Size(),
+		ContentType: contentTypeForKey(key),
+		ModTime:     st.ModTime(),
+	}
+}
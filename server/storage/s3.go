@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3-compatible bucket (AWS S3, MinIO, R2, ...)
+type S3Config struct {
+	Endpoint  string // e.g. "https://s3.eu-central-1.amazonaws.com" or "http://localhost:9000"
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // address the bucket as endpoint/bucket (required by MinIO)
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Storage stores objects in an S3-compatible bucket using the REST API
+// with AWS Signature Version 4.
+type S3Storage struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Storage creates an S3 store
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 storage requires an endpoint and a bucket")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 storage requires access and secret keys")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+
+	return &S3Storage{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{}, // requests are bounded by their context; downloads may be long
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL returns the URL of a key (or of the bucket when key is empty)
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.cfg.PathStyle {
+		u.Path = "/" + s.cfg.Bucket
+		if key != "" {
+			u.Path += "/" + key
+		}
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = awsURIEncode(u.Path, false)
+	return &u
+}
+
+// do signs and sends a request
+func (s *S3Storage) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		// Streaming uploads are sent unsigned; the request itself is still authenticated
+		payloadHash = "UNSIGNED-PAYLOAD"
+		req.ContentLength = size
+	}
+	s.sign(req, payloadHash)
+
+	return s.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers to a request
+func (s *S3Storage) sign(req *http.Request, payloadHash string) {
+	t := s.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || lower == "content-type" {
+			signedHeaders = append(signedHeaders, lower)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	signature, scope := signV4(req, signedHeaders, payloadHash, t, s.cfg.Region, s.cfg.SecretKey)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+// signV4 computes a SigV4 signature over the given headers and returns it with the credential scope
+func signV4(req *http.Request, signedHeaders []string, payloadHash string, t time.Time, region, secretKey string) (string, string) {
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	date := t.Format("20060102")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign)), scope
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery sorts and encodes query parameters as SigV4 requires
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string{}, values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent-encodes everything except unreserved characters (and "/" unless encodeSlash)
+func awsURIEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'),
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// s3Error reads an error response body into an error
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var parsed struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &parsed) == nil && parsed.Code != "" {
+		return fmt.Errorf("s3: %s: %s", parsed.Code, parsed.Message)
+	}
+	return fmt.Errorf("s3: unexpected status %s", resp.Status)
+}
+
+// Put uploads an object. Bodies of unknown size are buffered to compute Content-Length.
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+	if contentType == "" {
+		contentType = contentTypeForKey(key)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(key), r, size, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// Get downloads an object
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, nil, s3Error(resp)
+	}
+	return resp.Body, objectInfoFromHeader(key, resp), nil
+}
+
+// GetRange downloads an object from offset to its end
+func (s *S3Storage) GetRange(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, 0, header)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// Range ignored by the server: skip to the offset ourselves
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+}
+
+// Stat issues a HEAD request for an object
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3: unexpected status %s", resp.Status)
+	}
+	return objectInfoFromHeader(key, resp), nil
+}
+
+func objectInfoFromHeader(key string, resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{Key: key, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if info.ContentType == "" {
+		info.ContentType = contentTypeForKey(key)
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+// Delete removes an object
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// listBucketResult is the ListObjectsV2 response
+type listBucketResult struct {
+	Contents []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		u := s.objectURL("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = canonicalQuery(query)
+
+		resp, err := s.do(ctx, http.MethodGet, u, nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error(resp)
+			resp.Body.Close()
+			return err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3: failed to parse list response: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			info := ObjectInfo{Key: object.Key, Size: object.Size, ContentType: contentTypeForKey(object.Key)}
+			if modTime, err := time.Parse(time.RFC3339, object.LastModified); err == nil {
+				info.ModTime = modTime
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// RangeGetter is implemented by backends that can read an object from an offset
+type RangeGetter interface {
+	GetRange(ctx context.Context, key string, offset int64) (io.ReadCloser, error)
+}
+
+// Open opens an object for random access, e.g. to answer HTTP range requests.
+// Backends whose readers cannot seek are read lazily from the requested offset.
+func Open(ctx context.Context, s Storage, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	rc, info, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rsc, ok := rc.(io.ReadSeekCloser); ok {
+		return rsc, info, nil
+	}
+	return &rangeReader{ctx: ctx, store: s, key: info.Key, size: info.Size, body: rc}, info, nil
+}
+
+// rangeReader seeks by reopening the object at the new offset on the next read
+type rangeReader struct {
+	ctx   context.Context
+	store Storage
+	key   string
+	size  int64
+	pos   int64
+	body  io.ReadCloser // open at pos, or nil
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.open(r.pos)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *rangeReader) open(offset int64) (io.ReadCloser, error) {
+	if rg, ok := r.store.(RangeGetter); ok {
+		return rg.GetRange(r.ctx, r.key, offset)
+	}
+	rc, _, err := r.store.Get(r.ctx, r.key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, rc, offset); err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative seek position")
+	}
+	if offset != r.pos && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
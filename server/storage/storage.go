@@ -0,0 +1,122 @@
+// Package storage abstracts where uploaded files live, so the server can run
+// on a single machine with local disk or as several instances sharing an
+// S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when an object does not exist
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrInvalidKey is returned for keys that are empty or escape the store
+	ErrInvalidKey = errors.New("storage: invalid object key")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage stores objects under slash-separated keys such as "rooms/<id>/notes.pdf"
+type Storage interface {
+	// Put writes an object, replacing any existing one. size may be -1 if unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens an object for reading; the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Stat returns an object's metadata
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every object whose key starts with prefix
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Backend  string // "local" (default) or "s3"
+	LocalDir string
+	S3       S3Config
+}
+
+// New creates the backend selected by cfg.Backend
+func New(cfg Config) (Storage, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalDir), nil
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// DeletePrefix removes every object under a prefix
+func DeletePrefix(ctx context.Context, s Storage, prefix string) error {
+	var keys []string
+	err := s.List(ctx, prefix, func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanKey validates and normalizes an object key
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.Contains(key, "\\") || strings.ContainsRune(key, 0) {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		// Reject "..", "." and duplicate slashes rather than silently rewriting them
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// URLPrefix is the path under which stored files are addressed by the API
+const URLPrefix = "/uploads/"
+
+// URLForKey returns the file URL recorded for an object (e.g. Resource.FileURL)
+func URLForKey(key string) string {
+	return URLPrefix + key
+}
+
+// KeyFromURL maps a file URL produced by URLForKey back to its object key
+func KeyFromURL(fileURL string) (string, bool) {
+	if !strings.HasPrefix(fileURL, URLPrefix) {
+		return "", false
+	}
+	key, err := CleanKey(strings.TrimPrefix(fileURL, URLPrefix))
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// contentTypeForKey guesses a MIME type from the key's extension
+func contentTypeForKey(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCleanKey(t *testing.T) {
+	valid := map[string]string{
+		"rooms/abc/notes.pdf":  "rooms/abc/notes.pdf",
+		"/avatars/u1/face.png": "avatars/u1/face.png",
+	}
+	for in, want := range valid {
+		if got, err := CleanKey(in); err != nil || got != want {
+			t.Errorf("CleanKey(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, key := range []string{"", "../etc/passwd", "rooms/../../x", "rooms//x", "rooms\\x", "rooms/./x", "/"} {
+		if _, err := CleanKey(key); err == nil {
+			t.Errorf("CleanKey(%q) should fail", key)
+		}
+	}
+}
+
+func TestKeyFromURL(t *testing.T) {
+	if key, ok := KeyFromURL(URLForKey("rooms/r1/a.txt")); !ok || key != "rooms/r1/a.txt" {
+		t.Errorf("Round trip failed: %q %v", key, ok)
+	}
+	for _, u := range []string{"https://example.com/a.pdf", "/uploads/../main.go", "/static/x"} {
+		if _, ok := KeyFromURL(u); ok {
+			t.Errorf("KeyFromURL(%q) should be rejected", u)
+		}
+	}
+}
+
+// exerciseStorage runs the behaviour every backend must share
+func exerciseStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "rooms/r1/notes.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put(ctx, "rooms/r2/other.txt", strings.NewReader("other"), -1, ""); err != nil {
+		t.Fatalf("Put with unknown size failed: %v", err)
+	}
+
+	rc, info, err := s.Get(ctx, "rooms/r1/notes.txt")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" || info.Size != 5 {
+		t.Errorf("Unexpected object %q (size %d)", data, info.Size)
+	}
+
+	if _, err := s.Stat(ctx, "rooms/r1/missing.txt"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	var keys []string
+	err = s.List(ctx, "rooms/r1/", func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil || len(keys) != 1 || keys[0] != "rooms/r1/notes.txt" {
+		t.Errorf("Unexpected listing %v (%v)", keys, err)
+	}
+
+	if err := DeletePrefix(ctx, s, "rooms/"); err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if _, _, err := s.Get(ctx, "rooms/r2/other.txt"); err != ErrNotFound {
+		t.Errorf("Expected object to be deleted, got %v", err)
+	}
+	if err := s.Delete(ctx, "rooms/r2/other.txt"); err != nil {
+		t.Errorf("Deleting a missing object should succeed, got %v", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	exerciseStorage(t, NewLocalStorage(t.TempDir()))
+}
+
+func TestSignV4KnownVector(t *testing.T) {
+	// "GET Object" example from the AWS Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://examplebucket.s3.amazonaws.com/test.txt", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	req.Header.Set("x-amz-date", "20130524T000000Z")
+
+	signature, scope := signV4(req,
+		[]string{"host", "range", "x-amz-content-sha256", "x-amz-date"},
+		emptyPayloadHash,
+		time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC),
+		"us-east-1",
+		"wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY",
+	)
+	if scope != "20130524/us-east-1/s3/aws4_request" {
+		t.Errorf("Unexpected scope %s", scope)
+	}
+	if signature != "f0e8bdb87c964420e857bd35b5d6ed310bd44f0170aba48dd91039c6036bdb41" {
+		t.Errorf("Unexpected signature %s", signature)
+	}
+}
+
+// fakeS3 is an in-memory S3 endpoint supporting the calls S3Storage makes
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		type content struct {
+			Key  string
+			Size int64
+		}
+		var result struct {
+			XMLName  xml.Name  `xml:"ListBucketResult"`
+			Contents []content `xml:"Contents"`
+		}
+		prefix := r.URL.Query().Get("prefix")
+		for k, v := range f.objects {
+			if strings.HasPrefix(k, prefix) {
+				result.Contents = append(result.Contents, content{k, int64(len(v))})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" && r.Method == http.MethodGet {
+			offset, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			data = data[offset:]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	s, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "bucket",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage failed: %v", err)
+	}
+	exerciseStorage(t, s)
+
+	// Seeking re-reads the object from the new offset
+	if err := s.Put(context.Background(), "videos/clip.mp4", strings.NewReader("0123456789"), 10, "video/mp4"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	rsc, info, err := Open(context.Background(), s, "videos/clip.mp4")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer rsc.Close()
+	if end, _ := rsc.Seek(0, io.SeekEnd); end != info.Size {
+		t.Errorf("Seek to end returned %d, want %d", end, info.Size)
+	}
+	rsc.Seek(6, io.SeekStart)
+	rest, _ := io.ReadAll(rsc)
+	if string(rest) != "6789" {
+		t.Errorf("Read after seek returned %q", rest)
+	}
+}
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("secret")
+	now := time.Unix(1700000000, 0)
+	signer.now = func() time.Time { return now }
+
+	signed, expires := signer.SignedURL("rooms/r1/notes.pdf", 10*time.Minute)
+	if !expires.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("Unexpected expiry %v", expires)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil || !strings.HasPrefix(u.Path, SignedURLPrefix) {
+		t.Fatalf("Unexpected signed URL %q", signed)
+	}
+	key := strings.TrimPrefix(u.Path, SignedURLPrefix)
+	exp, sig := u.Query().Get("expires"), u.Query().Get("signature")
+
+	if err := signer.Verify(key, exp, sig); err != nil {
+		t.Errorf("Valid URL rejected: %v", err)
+	}
+	if err := signer.Verify("rooms/r1/other.pdf", exp, sig); err != ErrBadSignature {
+		t.Errorf("URL for another key should be rejected, got %v", err)
+	}
+	if err := signer.Verify(key, "9999999999", sig); err != ErrBadSignature {
+		t.Errorf("Extended expiry should be rejected, got %v", err)
+	}
+	if err := NewURLSigner("other").Verify(key, exp, sig); err != ErrBadSignature {
+		t.Errorf("URL signed with another secret should be rejected, got %v", err)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if err := signer.Verify(key, exp, sig); err != ErrURLExpired {
+		t.Errorf("Expected ErrURLExpired, got %v", err)
+	}
+}
+
+// TestS3StorageMinIO runs against a real bucket when configured, e.g.
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	S3_TEST_ENDPOINT=http://localhost:9000 S3_TEST_BUCKET=buddy-test \
+//	S3_TEST_ACCESS_KEY=minioadmin S3_TEST_SECRET_KEY=minioadmin go test ./storage
+func TestS3StorageMinIO(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+
+	s, err := NewS3Storage(S3Config{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("S3_TEST_REGION"),
+		Bucket:    os.Getenv("S3_TEST_BUCKET"),
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage failed: %v", err)
+	}
+	exerciseStorage(t, s)
+}